@@ -0,0 +1,274 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/notifications"
+	"github.com/ARGOeu/argo-messaging/projects"
+	"github.com/ARGOeu/argo-messaging/quotas"
+	"github.com/ARGOeu/argo-messaging/ratelimit"
+	"github.com/ARGOeu/argo-messaging/schemas"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/subscriptions"
+	"github.com/ARGOeu/argo-messaging/topics"
+)
+
+// redacted replaces the values of the sensitive fields in the changes of an entry
+const redacted = `"<redacted>"`
+
+// sensitiveFields holds the fields of the resources whose values never get recorded
+var sensitiveFields = map[string]bool{
+	"token": true,
+}
+
+// Entry is the api representation of an audit log entry
+type Entry struct {
+	Timestamp    string   `json:"timestamp"`
+	TraceID      string   `json:"trace_id"`
+	Actor        string   `json:"actor"`
+	ActorUUID    string   `json:"actor_uuid"`
+	RouteName    string   `json:"route_name"`
+	Project      string   `json:"project,omitempty"`
+	ResourceType string   `json:"resource_type,omitempty"`
+	Resource     string   `json:"resource,omitempty"`
+	StatusCode   int      `json:"status_code"`
+	SourceIP     string   `json:"source_ip"`
+	Changes      []Change `json:"changes,omitempty"`
+}
+
+// Change holds the value of a field of a resource before and after an action
+type Change struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// PaginatedEntries holds a page of audit log entries
+type PaginatedEntries struct {
+	Entries       []Entry `json:"audit_entries"`
+	NextPageToken string  `json:"nextPageToken"`
+	TotalSize     int64   `json:"totalSize"`
+}
+
+// ExportJSON exports a page of audit log entries to json format
+func (pe *PaginatedEntries) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(pe, "", "   ")
+	return string(output[:]), err
+}
+
+// Record stores a new entry in the audit log, that is kept for the given days or forever when they are 0
+func Record(ctx context.Context, entry stores.QAuditEntry, retention int, store stores.Store) error {
+	if retention > 0 {
+		entry.ExpiresOn = entry.CreatedOn.Add(time.Duration(retention) * 24 * time.Hour)
+	}
+	return store.InsertAuditEntry(ctx, entry)
+}
+
+// Find returns a page of the audit log entries that pass the filter, the most recent first
+func Find(ctx context.Context, filter stores.QAuditFilter, pageToken string, pageSize int64, store stores.Store) (PaginatedEntries, error) {
+
+	result := PaginatedEntries{Entries: []Entry{}}
+
+	qEntries, totalSize, nextPageToken, err := store.QueryAuditEntries(ctx, filter, pageToken, pageSize)
+	if err != nil {
+		return result, err
+	}
+
+	zuluForm := "2006-01-02T15:04:05Z"
+
+	for _, qEntry := range qEntries {
+		entry := Entry{
+			Timestamp:    qEntry.CreatedOn.UTC().Format(zuluForm),
+			TraceID:      qEntry.TraceID,
+			Actor:        auth.GetNameByUUID(ctx, qEntry.ActorUUID, store),
+			ActorUUID:    qEntry.ActorUUID,
+			RouteName:    qEntry.RouteName,
+			Project:      projects.GetNameByUUID(ctx, qEntry.ProjectUUID, store),
+			ResourceType: qEntry.ResourceType,
+			Resource:     qEntry.Resource,
+			StatusCode:   qEntry.StatusCode,
+			SourceIP:     qEntry.SourceIP,
+		}
+
+		for _, qChange := range qEntry.Changes {
+			entry.Changes = append(entry.Changes, Change{
+				Field:  qChange.Field,
+				Before: json.RawMessage(qChange.Before),
+				After:  json.RawMessage(qChange.After),
+			})
+		}
+
+		result.Entries = append(result.Entries, entry)
+	}
+
+	result.NextPageToken = nextPageToken
+	result.TotalSize = totalSize
+
+	return result, nil
+}
+
+// Diff compares the json representations of a resource before and after an action, field by field.
+// A resource that doesn't exist is represented by an empty value
+func Diff(before []byte, after []byte) []stores.QAuditChange {
+
+	beforeFields := map[string]json.RawMessage{}
+	afterFields := map[string]json.RawMessage{}
+	json.Unmarshal(before, &beforeFields)
+	json.Unmarshal(after, &afterFields)
+
+	fields := []string{}
+	for field := range beforeFields {
+		fields = append(fields, field)
+	}
+	for field := range afterFields {
+		if _, found := beforeFields[field]; !found {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []stores.QAuditChange{}
+	for _, field := range fields {
+		beforeValue := compact(beforeFields[field])
+		afterValue := compact(afterFields[field])
+		if beforeValue == afterValue {
+			continue
+		}
+
+		if sensitiveFields[field] {
+			beforeValue, afterValue = redacted, redacted
+		}
+
+		changes = append(changes, stores.QAuditChange{Field: field, Before: beforeValue, After: afterValue})
+	}
+
+	return changes
+}
+
+// compact returns the compact form of a json value, null when there is no value
+func compact(value json.RawMessage) string {
+	if len(value) == 0 {
+		return "null"
+	}
+	buf := bytes.Buffer{}
+	if err := json.Compact(&buf, value); err != nil {
+		return "null"
+	}
+	return buf.String()
+}
+
+// Snapshot returns the json representation of a resource that audited actions are performed on,
+// or nil when the resource doesn't exist. The acls of topics and subscriptions, the rate limits of projects and users
+// and the quotas of projects are represented on their own
+func Snapshot(ctx context.Context, resourceType string, projectUUID string, resource string, routeName string, store stores.Store) []byte {
+
+	var snapshot interface{}
+
+	// the changes of rate limits concern the limits rather than the project or user that they belong to
+	if routeName == "projects:updateRateLimits" || routeName == "users:updateRateLimits" {
+		scopeUUID := projectUUID
+		if resourceType == ratelimit.ScopeUser {
+			scopeUUID = auth.GetUUIDByName(ctx, resource, store)
+		}
+		limits, err := ratelimit.Find(ctx, resourceType, scopeUUID, store)
+		if err != nil {
+			return nil
+		}
+		output, _ := json.Marshal(limits)
+		return output
+	}
+
+	// the changes of quotas concern the quotas rather than the project that they belong to
+	if routeName == "projects:updateQuota" {
+		limits, err := quotas.FindLimits(ctx, projectUUID, store)
+		if err != nil {
+			return nil
+		}
+		output, _ := json.Marshal(limits)
+		return output
+	}
+
+	switch resourceType {
+	case "topics":
+		if routeName == "topics:modifyAcl" {
+			acl, err := auth.GetACL(ctx, projectUUID, resourceType, resource, store)
+			if err != nil {
+				return nil
+			}
+			snapshot = acl
+			break
+		}
+		res, err := topics.Find(ctx, projectUUID, "", resource, "", 0, store)
+		if err != nil || len(res.Topics) == 0 {
+			return nil
+		}
+		snapshot = res.Topics[0]
+	case "subscriptions":
+		if routeName == "subscriptions:modifyAcl" {
+			acl, err := auth.GetACL(ctx, projectUUID, resourceType, resource, store)
+			if err != nil {
+				return nil
+			}
+			snapshot = acl
+			break
+		}
+		res, err := subscriptions.Find(ctx, projectUUID, "", resource, "", 0, store)
+		if err != nil || len(res.Subscriptions) == 0 {
+			return nil
+		}
+		// the offset of the subscription is not part of its representation
+		snapshot = struct {
+			subscriptions.Subscription
+			Offset int64 `json:"offset"`
+		}{res.Subscriptions[0], res.Subscriptions[0].Offset}
+	case "schemas":
+		res, err := schemas.Find(ctx, projectUUID, "", resource, store)
+		if err != nil || len(res.Schemas) == 0 {
+			return nil
+		}
+		snapshot = res.Schemas[0]
+	case "groups":
+		res, err := auth.FindGroups(ctx, projectUUID, resource, store)
+		if err != nil || len(res.List) == 0 {
+			return nil
+		}
+		snapshot = res.List[0]
+	case "users":
+		res, err := auth.FindUsers(ctx, "", "", resource, true, store)
+		if err != nil || len(res.List) == 0 {
+			return nil
+		}
+		snapshot = res.List[0]
+	case "roles":
+		res, err := auth.FindRoles(ctx, resource, store)
+		if err != nil || len(res.List) == 0 {
+			return nil
+		}
+		snapshot = res.List[0]
+	case "projects":
+		res, err := projects.Find(ctx, "", resource, store)
+		if err != nil || len(res.List) == 0 {
+			return nil
+		}
+		snapshot = res.List[0]
+	case "templates":
+		res, err := notifications.FindTemplates(ctx, resource, store)
+		if err != nil || len(res.List) == 0 {
+			return nil
+		}
+		snapshot = res.List[0]
+	default:
+		return nil
+	}
+
+	output, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil
+	}
+	return output
+}
@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuditTestSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+func (suite *AuditTestSuite) TestDiff() {
+
+	before := []byte(`{"name": "topic1", "message_ttl": 0, "acl": ["UserA"], "token": "S3CR3T"}`)
+	after := []byte(`{"name": "topic1", "message_ttl": 3600, "acl": ["UserA"], "token": "N3W", "schema": "schema1"}`)
+
+	expected := []stores.QAuditChange{
+		{Field: "message_ttl", Before: "0", After: "3600"},
+		{Field: "schema", Before: "null", After: `"schema1"`},
+		{Field: "token", Before: `"<redacted>"`, After: `"<redacted>"`},
+	}
+	suite.Equal(expected, Diff(before, after))
+
+	// a deleted resource has all of its fields removed
+	suite.Equal([]stores.QAuditChange{{Field: "name", Before: `"topic1"`, After: "null"}},
+		Diff([]byte(`{"name": "topic1"}`), nil))
+
+	suite.Equal([]stores.QAuditChange{}, Diff(before, before))
+	suite.Equal([]stores.QAuditChange{}, Diff(nil, nil))
+}
+
+func (suite *AuditTestSuite) TestSnapshot() {
+
+	store := stores.NewMockStore("", "")
+
+	topic := string(Snapshot(suite.ctx, "topics", "argo_uuid", "topic1", "topics:create", store))
+	suite.Contains(topic, `"name":"/projects/ARGO/topics/topic1"`)
+	suite.Nil(Snapshot(suite.ctx, "topics", "argo_uuid", "unknown", "topics:create", store))
+	suite.Equal(`{"authorized_users":["UserA","UserB"]}`, string(Snapshot(suite.ctx, "topics", "argo_uuid", "topic1", "topics:modifyAcl", store)))
+	suite.Nil(Snapshot(suite.ctx, "subjects", "argo_uuid", "subject", "registry:register", store))
+
+	user := string(Snapshot(suite.ctx, "users", "", "UserA", "users:refreshToken", store))
+	suite.Contains(user, `"name":"UserA"`)
+
+	store.UpdateRateLimits(suite.ctx, "users", "uuid1", []stores.QRateLimit{{RouteClass: "pull", RequestsPerSecond: 5}})
+	suite.Equal(`{"rate_limits":[{"route_class":"pull","requests_per_second":5,"bytes_per_second":0}]}`,
+		string(Snapshot(suite.ctx, "users", "", "UserA", "users:updateRateLimits", store)))
+
+	store.UpdateProjectQuota(suite.ctx, stores.QProjectQuota{ProjectUUID: "argo_uuid", MaxTopics: 10})
+	suite.Equal(`{"max_topics":10,"max_subscriptions":0,"max_users":0,"daily_messages":0,"daily_bytes":0}`,
+		string(Snapshot(suite.ctx, "projects", "argo_uuid", "ARGO", "projects:updateQuota", store)))
+
+	store.UpdateNotificationTemplate(suite.ctx, stores.QNotificationTemplate{Name: "registration_declined", Subject: "Declined", Body: "Sorry"})
+	suite.Equal(`{"name":"registration_declined","subject":"Declined","body":"Sorry","custom":true}`,
+		string(Snapshot(suite.ctx, "templates", "", "registration_declined", "notifications:updateTemplate", store)))
+	suite.Nil(Snapshot(suite.ctx, "templates", "", "unknown", "notifications:updateTemplate", store))
+}
+
+func (suite *AuditTestSuite) TestRecordAndFind() {
+
+	store := stores.NewMockStore("", "")
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	entry := stores.QAuditEntry{
+		TraceID:      "trace1",
+		ActorUUID:    "uuid1",
+		RouteName:    "topics:create",
+		ProjectUUID:  "argo_uuid",
+		ResourceType: "topics",
+		Resource:     "topic4",
+		Changes:      []stores.QAuditChange{{Field: "name", Before: "null", After: `"topic4"`}},
+		StatusCode:   200,
+		SourceIP:     "10.0.0.1",
+		CreatedOn:    created,
+	}
+	suite.Nil(Record(suite.ctx, entry, 30, store))
+	suite.Equal(created.AddDate(0, 0, 30), store.AuditEntries[0].ExpiresOn)
+
+	entry.TraceID = "trace2"
+	suite.Nil(Record(suite.ctx, entry, 0, store))
+	suite.True(store.AuditEntries[1].ExpiresOn.IsZero())
+
+	res, err := Find(suite.ctx, stores.QAuditFilter{}, "", 1, store)
+	suite.Nil(err)
+	suite.Equal(int64(2), res.TotalSize)
+	suite.Equal("1", res.NextPageToken)
+
+	expJSON := `{
+   "audit_entries": [
+      {
+         "timestamp": "2024-01-01T00:00:00Z",
+         "trace_id": "trace2",
+         "actor": "UserA",
+         "actor_uuid": "uuid1",
+         "route_name": "topics:create",
+         "project": "ARGO",
+         "resource_type": "topics",
+         "resource": "topic4",
+         "status_code": 200,
+         "source_ip": "10.0.0.1",
+         "changes": [
+            {
+               "field": "name",
+               "before": null,
+               "after": "topic4"
+            }
+         ]
+      }
+   ],
+   "nextPageToken": "1",
+   "totalSize": 2
+}`
+	outJSON, _ := res.ExportJSON()
+	suite.Equal(expJSON, outJSON)
+
+	res, err = Find(suite.ctx, stores.QAuditFilter{ProjectUUID: "argo_uuid2"}, "", 0, store)
+	suite.Nil(err)
+	suite.Equal(PaginatedEntries{Entries: []Entry{}}, res)
+
+	_, err = Find(suite.ctx, stores.QAuditFilter{}, "invalid", 0, store)
+	suite.NotNil(err)
+}
+
+func TestAuditTestSuite(t *testing.T) {
+	suite.Run(t, new(AuditTestSuite))
+}
@@ -4,13 +4,51 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/ARGOeu/argo-messaging/stores"
 )
 
-// ACL holds the authorized users for a resource (topic/subscription)
+// ACL holds the authorized users and groups for a resource (topic/subscription).
+// GroupMembers shows the users that are authorized through each group.
+// UserActions and GroupActions restrict users and groups to specific actions on the resource,
+// the ones that are not present in them are allowed every action
 type ACL struct {
-	AuthUsers []string `json:"authorized_users"`
+	AuthUsers    []string            `json:"authorized_users"`
+	AuthGroups   []string            `json:"authorized_groups,omitempty"`
+	GroupMembers map[string][]string `json:"group_members,omitempty"`
+	UserActions  map[string][]string `json:"user_actions,omitempty"`
+	GroupActions map[string][]string `json:"group_actions,omitempty"`
+}
+
+// ResourceActions holds the actions on topics and subscriptions that acl entries can be restricted to,
+// each one named after the route that performs it
+var ResourceActions = map[string][]string{
+	"topics": {
+		"show", "delete", "acl", "modifyAcl", "metrics", "publish", "attachSchema", "detachSchema",
+		"modifyMessageTTL", "modifyQuarantineTopic", "modifyCompression",
+	},
+	"subscriptions": {
+		"show", "delete", "acl", "modifyAcl", "metrics", "offsets", "timeToOffset", "pull", "acknowledge",
+		"verifyPushEndpoint", "modifyAckDeadline", "modifyOutputFormat", "modifyPushConfig", "modifyOffset",
+	},
+}
+
+// IsResourceAction checks if an action on a resource type is controlled by the resource's acl
+func IsResourceAction(resourceType string, action string) bool {
+	for _, item := range ResourceActions[resourceType] {
+		if item == action {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiresResourceACL checks if a user with the given roles can only access the topics and subscriptions
+// that their acls authorize them for. Project and service wide roles are not restricted by acls
+func RequiresResourceACL(roles []string) bool {
+	return !IsProjectAdmin(roles) && !IsServiceAdmin(roles) && !IsAdminViewer(roles) && !IsPushWorker(roles)
 }
 
 // ExportJSON export topic acl body to json for use in http response
@@ -26,23 +64,76 @@ func (acl *ACL) ExportJSON() (string, error) {
 func GetACLFromJSON(input []byte) (ACL, error) {
 	acl := ACL{}
 	err := json.Unmarshal([]byte(input), &acl)
-	if acl.AuthUsers == nil {
+	if acl.AuthUsers == nil && acl.AuthGroups == nil {
 		return acl, errors.New("wrong argument")
 	}
+	if acl.AuthUsers == nil {
+		acl.AuthUsers = []string{}
+	}
 	return acl, err
 }
 
-// ModACL is called to modify an acl
-func ModACL(ctx context.Context, projectUUID string, resourceType string, resourceName string, acl []string, store stores.Store) error {
+// ValidateACLActions checks that the action restrictions of an acl refer to its users and groups
+// and only contain actions of the resource type
+func ValidateACLActions(resourceType string, acl ACL) error {
+	if err := validateActions(resourceType, "user", acl.UserActions, acl.AuthUsers); err != nil {
+		return err
+	}
+	return validateActions(resourceType, "group", acl.GroupActions, acl.AuthGroups)
+}
+
+// validateActions checks the action restrictions of either the users or the groups of an acl
+func validateActions(resourceType string, kind string, actions map[string][]string, names []string) error {
+	for name, nameActions := range actions {
+		if !contains(names, name) {
+			return fmt.Errorf("invalid actions for %s %s, which is not part of the acl", kind, name)
+		}
+		if len(nameActions) == 0 {
+			return fmt.Errorf("invalid actions for %s %s, at least one action is required", kind, name)
+		}
+		for _, action := range nameActions {
+			if !IsResourceAction(resourceType, action) {
+				return fmt.Errorf("invalid action %s for %s %s", action, kind, name)
+			}
+		}
+	}
+	return nil
+}
+
+// ModACL is called to modify an acl, with the users and the groups of the project that it authorizes
+// and the actions that they are restricted to
+func ModACL(ctx context.Context, projectUUID string, resourceType string, resourceName string, acl ACL, store stores.Store) error {
 	// Transform user name to user uuid
 
 	userUUIDs := []string{}
-	for _, username := range acl {
+	for _, username := range acl.AuthUsers {
 		userUUID := GetUUIDByName(ctx, username, store)
 		userUUIDs = append(userUUIDs, userUUID)
 	}
 
-	return store.ModACL(ctx, projectUUID, resourceType, resourceName, userUUIDs)
+	// Groups are referenced by their uuid, so that a group re-created with the same name is not authorized
+	userUUIDs = append(userUUIDs, groupNamesToACLEntries(ctx, projectUUID, acl.AuthGroups, store)...)
+
+	// the action restrictions are replaced along with the acl
+	var actions map[string][]string
+	for username, userActions := range acl.UserActions {
+		if actions == nil {
+			actions = map[string][]string{}
+		}
+		actions[GetUUIDByName(ctx, username, store)] = userActions
+	}
+	for name, groupActions := range acl.GroupActions {
+		entries := groupNamesToACLEntries(ctx, projectUUID, []string{name}, store)
+		if len(entries) == 0 {
+			continue
+		}
+		if actions == nil {
+			actions = map[string][]string{}
+		}
+		actions[entries[0]] = groupActions
+	}
+
+	return store.ModACL(ctx, projectUUID, resourceType, resourceName, userUUIDs, actions)
 }
 
 // AppendToACL is used to append unique users to a topic's or sub's ACL
@@ -80,6 +171,29 @@ func GetACL(ctx context.Context, projectUUID string, resourceType string, resour
 	}
 	for _, item := range acl.ACL {
 
+		// Resolve the groups to their members
+		if strings.HasPrefix(item, stores.GroupACLPrefix) {
+			qGroups, err := store.QueryGroups(ctx, projectUUID, strings.TrimPrefix(item, stores.GroupACLPrefix), "")
+			// skip the groups that have been deleted
+			if err != nil || len(qGroups) == 0 {
+				continue
+			}
+
+			group := newGroup(ctx, qGroups[0], store)
+			if result.GroupMembers == nil {
+				result.GroupMembers = map[string][]string{}
+			}
+			result.AuthGroups = append(result.AuthGroups, group.Name)
+			result.GroupMembers[group.Name] = group.Members
+			if actions, found := acl.Actions[item]; found {
+				if result.GroupActions == nil {
+					result.GroupActions = map[string][]string{}
+				}
+				result.GroupActions[group.Name] = actions
+			}
+			continue
+		}
+
 		// Get Username from user uuid
 		username := GetNameByUUID(ctx, item, store)
 		// if username is empty, meaning that the user with this id probably doesn't exists
@@ -89,6 +203,12 @@ func GetACL(ctx context.Context, projectUUID string, resourceType string, resour
 		}
 
 		result.AuthUsers = append(result.AuthUsers, username)
+		if actions, found := acl.Actions[item]; found {
+			if result.UserActions == nil {
+				result.UserActions = map[string][]string{}
+			}
+			result.UserActions[username] = actions
+		}
 	}
 
 	return result, err
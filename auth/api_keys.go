@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/projects"
+	"github.com/ARGOeu/argo-messaging/stores"
+	log "github.com/sirupsen/logrus"
+)
+
+// apiKeyLastUsedResolution is how often the last use of an api key gets recorded,
+// so that a busy key does not cause a write on every request
+const apiKeyLastUsedResolution = time.Minute
+
+// APIKey is a named api key of a user, the secret is only present when the key is created
+type APIKey struct {
+	Name       string   `json:"name"`
+	Token      string   `json:"token,omitempty"`
+	Project    string   `json:"project,omitempty"`
+	Roles      []string `json:"roles,omitempty"`
+	CreatedOn  string   `json:"created_on,omitempty"`
+	ExpiresOn  string   `json:"expires_on,omitempty"`
+	LastUsedOn string   `json:"last_used_on,omitempty"`
+}
+
+// APIKeys holds a list of api keys
+type APIKeys struct {
+	Tokens []APIKey `json:"tokens"`
+}
+
+// ExportJSON exports an api key to json format
+func (k *APIKey) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(k, "", "   ")
+	return string(output[:]), err
+}
+
+// ExportJSON exports a list of api keys to json format
+func (ks *APIKeys) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(ks, "", "   ")
+	return string(output[:]), err
+}
+
+// GetAPIKeyFromJSON retrieves the options of a new api key from a JSON input
+func GetAPIKeyFromJSON(input []byte) (APIKey, error) {
+	k := APIKey{}
+	err := json.Unmarshal(input, &k)
+	return k, err
+}
+
+// newAPIKey converts a stored api key to its api representation, without the secret
+func newAPIKey(ctx context.Context, qKey stores.QAPIKey, store stores.Store) APIKey {
+	zuluForm := "2006-01-02T15:04:05Z"
+
+	key := APIKey{
+		Name:      qKey.Name,
+		Roles:     qKey.Roles,
+		CreatedOn: qKey.CreatedOn.UTC().Format(zuluForm),
+	}
+
+	if qKey.ProjectUUID != "" {
+		key.Project = projects.GetNameByUUID(ctx, qKey.ProjectUUID, store)
+	}
+
+	if !qKey.ExpiresOn.IsZero() {
+		key.ExpiresOn = qKey.ExpiresOn.UTC().Format(zuluForm)
+	}
+
+	if !qKey.LastUsedOn.IsZero() {
+		key.LastUsedOn = qKey.LastUsedOn.UTC().Format(zuluForm)
+	}
+
+	return key
+}
+
+// FindAPIKeys returns the api keys of a user
+func FindAPIKeys(ctx context.Context, userUUID string, store stores.Store) (APIKeys, error) {
+
+	result := APIKeys{Tokens: []APIKey{}}
+
+	qKeys, err := store.QueryAPIKeys(ctx, userUUID, "")
+	if err != nil {
+		return result, err
+	}
+
+	for _, qKey := range qKeys {
+		result.Tokens = append(result.Tokens, newAPIKey(ctx, qKey, store))
+	}
+
+	return result, nil
+}
+
+// CreateAPIKey creates a new api key for the user. The key can be limited to a project,
+// to a subset of the roles that the user has and to a period of validity
+func CreateAPIKey(ctx context.Context, userUUID string, name string, project string, roles []string, expiresOn string, createdOn time.Time, store stores.Store) (APIKey, error) {
+
+	users, err := store.QueryUsers(ctx, "", userUUID, "")
+	if err != nil || len(users) == 0 {
+		return APIKey{}, errors.New("not found")
+	}
+	user := users[0]
+
+	existing, err := store.QueryAPIKeys(ctx, userUUID, name)
+	if err != nil {
+		return APIKey{}, err
+	}
+	if len(existing) > 0 {
+		return APIKey{}, errors.New("exists")
+	}
+
+	// the roles that the key can be limited to
+	userRoles := append([]string{}, user.ServiceRoles...)
+
+	projectUUID := ""
+	if project != "" {
+		projectUUID = projects.GetUUIDByName(ctx, project, store)
+		if projectUUID == "" {
+			return APIKey{}, errors.New("invalid project: " + project)
+		}
+		userRoles = user.ProjectRoles(projectUUID)
+	} else {
+		for _, pr := range user.Projects {
+			userRoles = append(userRoles, pr.Roles...)
+		}
+	}
+
+	if len(userRoles) == 0 {
+		return APIKey{}, errors.New("invalid project: the user has no roles in " + project)
+	}
+
+	if roles == nil {
+		roles = []string{}
+	}
+
+	for _, role := range roles {
+		if !IsRoleValid(role, userRoles) {
+			return APIKey{}, errors.New("invalid role: " + role)
+		}
+	}
+
+	expires := time.Time{}
+	if expiresOn != "" {
+		expires, err = time.Parse("2006-01-02T15:04:05Z", expiresOn)
+		if err != nil {
+			return APIKey{}, errors.New("invalid expires_on: it should be in the form of 2006-01-02T15:04:05Z")
+		}
+		if !expires.After(createdOn) {
+			return APIKey{}, errors.New("invalid expires_on: it should be in the future")
+		}
+	}
+
+	token, err := GenToken()
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	qKey := stores.QAPIKey{
+		UserUUID:    userUUID,
+		Name:        name,
+		TokenHash:   HashToken(token),
+		ProjectUUID: projectUUID,
+		Roles:       roles,
+		CreatedOn:   createdOn,
+		ExpiresOn:   expires,
+	}
+
+	if err := store.InsertAPIKey(ctx, qKey); err != nil {
+		return APIKey{}, errors.New("backend error")
+	}
+
+	key := newAPIKey(ctx, qKey, store)
+	key.Token = token
+
+	return key, nil
+}
+
+// RevokeAPIKey removes an api key of a user
+func RevokeAPIKey(ctx context.Context, userUUID string, name string, store stores.Store) error {
+	return store.RemoveAPIKeys(ctx, userUUID, name)
+}
+
+// authenticateAPIKey returns the roles in the project and the name of the user that an api key belongs to.
+// Expired keys, keys of other projects and unknown keys return no roles
+func authenticateAPIKey(ctx context.Context, projectUUID string, token string, store stores.Store) ([]string, string) {
+
+	qKey, err := store.GetAPIKeyByHash(ctx, HashToken(token))
+	if err != nil {
+		return []string{}, ""
+	}
+
+	now := time.Now().UTC()
+
+	if !qKey.ExpiresOn.IsZero() && !now.Before(qKey.ExpiresOn) {
+		return []string{}, ""
+	}
+
+	if qKey.ProjectUUID != "" && qKey.ProjectUUID != projectUUID {
+		return []string{}, ""
+	}
+
+	users, err := store.QueryUsers(ctx, "", qKey.UserUUID, "")
+	if err != nil || len(users) == 0 {
+		return []string{}, ""
+	}
+
+	roles := users[0].ProjectRoles(projectUUID)
+
+	// a key limited to some roles only keeps those that the user still has
+	if len(qKey.Roles) > 0 {
+		limited := []string{}
+		for _, role := range roles {
+			if IsRoleValid(role, qKey.Roles) {
+				limited = append(limited, role)
+			}
+		}
+		roles = limited
+	}
+
+	if now.Sub(qKey.LastUsedOn) > apiKeyLastUsedResolution {
+		if err := store.UpdateAPIKeyLastUsed(ctx, qKey.UserUUID, qKey.Name, now); err != nil {
+			log.WithFields(
+				log.Fields{
+					"trace_id": ctx.Value("trace_id"),
+					"type":     "service_log",
+					"user":     qKey.UserUUID,
+					"key":      qKey.Name,
+					"error":    err.Error(),
+				},
+			).Warning("Could not record the last use of api key")
+		}
+	}
+
+	return roles, users[0].Name
+}
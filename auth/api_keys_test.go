@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+func (suite *AuthTestSuite) TestCreateAPIKey() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+	created := time.Now().UTC()
+
+	key, err := CreateAPIKey(suite.ctx, "uuid1", "ci", "ARGO", []string{"publisher"}, "", created, store)
+	suite.Nil(err)
+	suite.Equal("ci", key.Name)
+	suite.Equal("ARGO", key.Project)
+	suite.Equal([]string{"publisher"}, key.Roles)
+	suite.NotEqual("", key.Token)
+
+	// only the hash of the key is stored
+	suite.Equal(HashToken(key.Token), store.APIKeys[0].TokenHash)
+
+	_, err = CreateAPIKey(suite.ctx, "uuid1", "ci", "", nil, "", created, store)
+	suite.Equal("exists", err.Error())
+
+	_, err = CreateAPIKey(suite.ctx, "uuid1", "other", "unknown", nil, "", created, store)
+	suite.Equal("invalid project: unknown", err.Error())
+
+	_, err = CreateAPIKey(suite.ctx, "uuid1", "other", "ARGO", []string{"project_admin"}, "", created, store)
+	suite.Equal("invalid role: project_admin", err.Error())
+
+	_, err = CreateAPIKey(suite.ctx, "uuid1", "other", "", nil, "tomorrow", created, store)
+	suite.Equal("invalid expires_on: it should be in the form of 2006-01-02T15:04:05Z", err.Error())
+
+	_, err = CreateAPIKey(suite.ctx, "uuid1", "other", "", nil, "2020-01-01T00:00:00Z", created, store)
+	suite.Equal("invalid expires_on: it should be in the future", err.Error())
+
+	_, err = CreateAPIKey(suite.ctx, "unknown", "other", "", nil, "", created, store)
+	suite.Equal("not found", err.Error())
+
+	keys, err := FindAPIKeys(suite.ctx, "uuid1", store)
+	suite.Nil(err)
+	suite.Equal(1, len(keys.Tokens))
+	suite.Equal("", keys.Tokens[0].Token)
+
+	suite.Nil(RevokeAPIKey(suite.ctx, "uuid1", "ci", store))
+	suite.Equal("not found", RevokeAPIKey(suite.ctx, "uuid1", "ci", store).Error())
+}
+
+func (suite *AuthTestSuite) TestAuthenticateAPIKey() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+	created := time.Now().UTC()
+
+	full, _ := CreateAPIKey(suite.ctx, "uuid1", "full", "", nil, "", created, store)
+	publish, _ := CreateAPIKey(suite.ctx, "uuid1", "publish", "ARGO", []string{"publisher"}, "", created, store)
+	expiring, _ := CreateAPIKey(suite.ctx, "uuid1", "expiring", "", nil,
+		created.Add(time.Hour).Format("2006-01-02T15:04:05Z"), created, store)
+
+	roles, user := Authenticate(suite.ctx, "argo_uuid", full.Token, store)
+	suite.Equal("UserA", user)
+	suite.Equal([]string{"consumer", "publisher"}, roles)
+
+	// the use of the key gets recorded
+	keys, _ := FindAPIKeys(suite.ctx, "uuid1", store)
+	suite.NotEqual("", keys.Tokens[0].LastUsedOn)
+
+	roles, user = Authenticate(suite.ctx, "argo_uuid", publish.Token, store)
+	suite.Equal("UserA", user)
+	suite.Equal([]string{"publisher"}, roles)
+
+	// a key scoped to a project does not work for other projects
+	roles, user = Authenticate(suite.ctx, "argo_uuid2", publish.Token, store)
+	suite.Equal("", user)
+	suite.Empty(roles)
+
+	roles, _ = Authenticate(suite.ctx, "argo_uuid", expiring.Token, store)
+	suite.Equal([]string{"consumer", "publisher"}, roles)
+	store.APIKeys[2].ExpiresOn = created.Add(-time.Second)
+	roles, user = Authenticate(suite.ctx, "argo_uuid", expiring.Token, store)
+	suite.Equal("", user)
+	suite.Empty(roles)
+
+	// the user's own token keeps working alongside the keys
+	roles, user = Authenticate(suite.ctx, "argo_uuid", "S3CR3T1", store)
+	suite.Equal("UserA", user)
+	suite.Equal([]string{"consumer", "publisher"}, roles)
+
+	// revoked keys stop working
+	suite.Nil(RevokeAPIKey(suite.ctx, "uuid1", "full", store))
+	roles, user = Authenticate(suite.ctx, "argo_uuid", full.Token, store)
+	suite.Equal("", user)
+	suite.Empty(roles)
+}
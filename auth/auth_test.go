@@ -56,24 +56,24 @@ func (suite *AuthTestSuite) TestAuth() {
 	// topic3: userC
 
 	// Check authorization per topic for userA
-	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "topics", "topic1", "uuid1", store))
-	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "topics", "topic2", "uuid1", store))
-	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "topics", "topic3", "uuid1", store))
+	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "topics", "topic1", "uuid1", "", store))
+	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "topics", "topic2", "uuid1", "", store))
+	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "topics", "topic3", "uuid1", "", store))
 
 	// Check authorization per topic for userB
-	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "topics", "topic1", "uuid2", store))
-	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "topics", "topic2", "uuid2", store))
-	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "topics", "topic3", "uuid2", store))
+	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "topics", "topic1", "uuid2", "", store))
+	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "topics", "topic2", "uuid2", "", store))
+	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "topics", "topic3", "uuid2", "", store))
 
 	// Check authorization per topic for userC
-	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "topics", "topic1", "uuid3", store))
-	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "topics", "topic2", "uuid3", store))
-	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "topics", "topic3", "uuid3", store))
+	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "topics", "topic1", "uuid3", "", store))
+	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "topics", "topic2", "uuid3", "", store))
+	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "topics", "topic3", "uuid3", "", store))
 
 	// Check authorization per topic for userD
-	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "topics", "topic1", "uuid4", store))
-	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "topics", "topic2", "uuid4", store))
-	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "topics", "topic3", "uuid4", store))
+	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "topics", "topic1", "uuid4", "", store))
+	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "topics", "topic2", "uuid4", "", store))
+	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "topics", "topic3", "uuid4", "", store))
 
 	// Check user authorization per subscription
 	//
@@ -83,26 +83,26 @@ func (suite *AuthTestSuite) TestAuth() {
 	// sub4: userB, userD
 
 	// Check authorization per subscription for userA
-	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub1", "uuid1", store))
-	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub2", "uuid1", store))
-	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub3", "uuid1", store))
-	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub4", "uuid1", store))
+	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub1", "uuid1", "", store))
+	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub2", "uuid1", "", store))
+	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub3", "uuid1", "", store))
+	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub4", "uuid1", "", store))
 
 	// Check authorization per subscription for userB
-	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub1", "uuid2", store))
-	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub2", "uuid2", store))
-	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub3", "uuid2", store))
-	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub4", "uuid2", store))
+	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub1", "uuid2", "", store))
+	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub2", "uuid2", "", store))
+	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub3", "uuid2", "", store))
+	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub4", "uuid2", "", store))
 	// Check authorization per subscription for userC
-	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub1", "uuid3", store))
-	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub2", "uuid3", store))
-	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub3", "uuid3", store))
-	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub4", "uuid3", store))
+	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub1", "uuid3", "", store))
+	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub2", "uuid3", "", store))
+	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub3", "uuid3", "", store))
+	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub4", "uuid3", "", store))
 	// Check authorization per subscription for userD
-	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub1", "uuid4", store))
-	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub2", "uuid4", store))
-	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub3", "uuid4", store))
-	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub4", "uuid4", store))
+	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub1", "uuid4", "", store))
+	suite.Equal(false, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub2", "uuid4", "", store))
+	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub3", "uuid4", "", store))
+	suite.Equal(true, PerResource(suite.ctx, "argo_uuid", "subscriptions", "sub4", "uuid4", "", store))
 
 	suite.Equal(true, IsConsumer([]string{"consumer"}))
 	suite.Equal(true, IsConsumer([]string{"consumer", "publisher"}))
@@ -155,7 +155,6 @@ func (suite *AuthTestSuite) TestAuth() {
             }
          ],
          "name": "Test",
-         "token": "S3CR3T",
          "email": "Test@test.com",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -186,7 +185,6 @@ func (suite *AuthTestSuite) TestAuth() {
          "last_name": "LastA",
          "organization": "OrgA",
          "description": "DescA",
-         "token": "S3CR3T1",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -213,7 +211,6 @@ func (suite *AuthTestSuite) TestAuth() {
             }
          ],
          "name": "UserB",
-         "token": "S3CR3T2",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -238,7 +235,6 @@ func (suite *AuthTestSuite) TestAuth() {
             }
          ],
          "name": "UserX",
-         "token": "S3CR3T3",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -264,7 +260,6 @@ func (suite *AuthTestSuite) TestAuth() {
             }
          ],
          "name": "UserZ",
-         "token": "S3CR3T4",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -285,7 +280,6 @@ func (suite *AuthTestSuite) TestAuth() {
             }
          ],
          "name": "UserSame1",
-         "token": "S3CR3T41",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -306,7 +300,6 @@ func (suite *AuthTestSuite) TestAuth() {
             }
          ],
          "name": "UserSame2",
-         "token": "S3CR3T42",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -339,7 +332,6 @@ func (suite *AuthTestSuite) TestAuth() {
       }
    ],
    "name": "UserZ",
-   "token": "S3CR3T4",
    "email": "foo-email",
    "service_roles": [],
    "created_on": "2009-11-10T23:00:00Z",
@@ -389,7 +381,6 @@ func (suite *AuthTestSuite) TestAuth() {
       }
    ],
    "name": "UserZ",
-   "token": "S3CR3T4",
    "email": "foo-email",
    "service_roles": [],
    "created_on": "2009-11-10T23:00:00Z",
@@ -441,7 +432,6 @@ func (suite *AuthTestSuite) TestAuth() {
    "last_name": "lastdoe",
    "organization": "orgdoe",
    "description": "descdoe",
-   "token": "johndoe@fake.email.foo",
    "email": "TOK3N",
    "service_roles": [
       "service_admin"
@@ -461,7 +451,7 @@ func (suite *AuthTestSuite) TestAuth() {
 	// Test Create with empty project list
 	CreateUser(suite.ctx, "uuid13", "empty-proj", "", "", "", "", []ProjectRoles{{Project: "", Roles: []string{"consumer"}}}, "TOK3N", "johndoe@fake.email.foo", []string{"service_admin"}, tm, "", store)
 	usrs2, _ := FindUsers(suite.ctx, "", "uuid13", "", true, store)
-	expusrs2 := Users{List: []User{{UUID: "uuid13", Projects: []ProjectRoles{}, Name: "empty-proj", Token: "TOK3N", Email: "johndoe@fake.email.foo", ServiceRoles: []string{"service_admin"}, CreatedOn: "2009-11-10T23:00:00Z", ModifiedOn: "2009-11-10T23:00:00Z", CreatedBy: ""}}}
+	expusrs2 := Users{List: []User{{UUID: "uuid13", Projects: []ProjectRoles{}, Name: "empty-proj", Email: "johndoe@fake.email.foo", ServiceRoles: []string{"service_admin"}, CreatedOn: "2009-11-10T23:00:00Z", ModifiedOn: "2009-11-10T23:00:00Z", CreatedBy: ""}}}
 	suite.Equal(expusrs2, usrs2)
 
 	// Test Update
@@ -482,7 +472,6 @@ func (suite *AuthTestSuite) TestAuth() {
    "last_name": "lastdoe2",
    "organization": "orgdoe2",
    "description": "descdoe2",
-   "token": "johndoe@fake.email.foo",
    "email": "TOK3N",
    "service_roles": [
       "consumer",
@@ -504,12 +493,14 @@ func (suite *AuthTestSuite) TestAuth() {
 	// Test update with empty project
 	UpdateUser(suite.ctx, "uuid13", "", "", "", "", "empty-proj", []ProjectRoles{{Project: "", Roles: []string{"consumer"}}}, "johndoe@fake.email.foo", []string{"service_admin"}, tm, false, store)
 	usrs2, _ = FindUsers(suite.ctx, "", "uuid13", "", true, store)
-	expusrs2 = Users{List: []User{{UUID: "uuid13", Projects: []ProjectRoles{}, Name: "empty-proj", Token: "TOK3N", Email: "johndoe@fake.email.foo", ServiceRoles: []string{"service_admin"}, CreatedOn: "2009-11-10T23:00:00Z", ModifiedOn: "2009-11-10T23:00:00Z", CreatedBy: ""}}}
+	expusrs2 = Users{List: []User{{UUID: "uuid13", Projects: []ProjectRoles{}, Name: "empty-proj", Email: "johndoe@fake.email.foo", ServiceRoles: []string{"service_admin"}, CreatedOn: "2009-11-10T23:00:00Z", ModifiedOn: "2009-11-10T23:00:00Z", CreatedBy: ""}}}
 	suite.Equal(expusrs2, usrs2)
 
+	store.UpdateRateLimits(suite.ctx, "users", "uuid12", []stores.QRateLimit{{RouteClass: "publish", RequestsPerSecond: 10}})
 	RemoveUser(suite.ctx, "uuid12", store)
 	_, err = FindUsers(suite.ctx, "", "uuid12", "", true, store)
 	suite.Equal(errors.New("not found"), err)
+	suite.Equal(0, len(store.RateLimits))
 
 	store2 := stores.NewMockStore("", "")
 
@@ -517,49 +508,47 @@ func (suite *AuthTestSuite) TestAuth() {
 	modified := "2009-11-10T23:00:00Z"
 
 	var qUsers1 []User
-	qUsers1 = append(qUsers1, User{"uuid8", []ProjectRoles{{"ARGO2", []string{"consumer", "publisher"}, []string{}, []string{}}}, "UserZ", "", "", "", "", "S3CR3T1", "foo-email", []string{}, created, modified, ""})
+	qUsers1 = append(qUsers1, User{"uuid8", []ProjectRoles{{"ARGO2", []string{"consumer", "publisher"}, []string{}, []string{}}}, "UserZ", "", "", "", "", "", "foo-email", []string{}, created, modified, "", "", "", ""})
 	qUsers1 = append(qUsers1, User{
 		UUID:         "uuid7",
 		Name:         "push_worker_0",
 		FirstName:    "",
 		LastName:     "",
 		Description:  "",
-		Token:        "push_token",
 		Email:        "foo-email",
 		ServiceRoles: []string{"push_worker"}, CreatedOn: created, ModifiedOn: modified,
 		CreatedBy: "",
 	})
-	qUsers1 = append(qUsers1, User{"same_uuid", []ProjectRoles{{"ARGO", []string{"publisher", "consumer"}, []string{}, []string{}}}, "UserSame2", "", "", "", "", "S3CR3T42", "foo-email", []string{}, created, modified, "UserA"})
-	qUsers1 = append(qUsers1, User{"same_uuid", []ProjectRoles{{"ARGO", []string{"publisher", "consumer"}, []string{}, []string{}}}, "UserSame1", "", "", "", "", "S3CR3T41", "foo-email", []string{}, created, modified, "UserA"})
-	qUsers1 = append(qUsers1, User{"uuid4", []ProjectRoles{{"ARGO", []string{"publisher", "consumer"}, []string{"topic2"}, []string{"sub3", "sub4"}}}, "UserZ", "", "", "", "", "S3CR3T4", "foo-email", []string{}, created, modified, "UserA"})
-	qUsers1 = append(qUsers1, User{"uuid3", []ProjectRoles{{"ARGO", []string{"publisher", "consumer"}, []string{"topic3"}, []string{"sub2"}}}, "UserX", "", "", "", "", "S3CR3T3", "foo-email", []string{}, created, modified, "UserA"})
-	qUsers1 = append(qUsers1, User{"uuid2", []ProjectRoles{{"ARGO", []string{"consumer", "publisher"}, []string{"topic1", "topic2"}, []string{"sub1", "sub3", "sub4"}}}, "UserB", "", "", "", "", "S3CR3T2", "foo-email", []string{}, created, modified, "UserA"})
-	qUsers1 = append(qUsers1, User{"uuid1", []ProjectRoles{{"ARGO", []string{"consumer", "publisher"}, []string{"topic1", "topic2"}, []string{"sub1", "sub2", "sub3"}}}, "UserA", "FirstA", "LastA", "OrgA", "DescA", "S3CR3T1", "foo-email", []string{}, created, modified, ""})
-	qUsers1 = append(qUsers1, User{"uuid0", []ProjectRoles{{"ARGO", []string{"consumer", "publisher"}, []string{}, []string{}}}, "Test", "", "", "", "", "S3CR3T", "Test@test.com", []string{}, created, modified, ""})
+	qUsers1 = append(qUsers1, User{"same_uuid", []ProjectRoles{{"ARGO", []string{"publisher", "consumer"}, []string{}, []string{}}}, "UserSame2", "", "", "", "", "", "foo-email", []string{}, created, modified, "UserA", "", "", ""})
+	qUsers1 = append(qUsers1, User{"same_uuid", []ProjectRoles{{"ARGO", []string{"publisher", "consumer"}, []string{}, []string{}}}, "UserSame1", "", "", "", "", "", "foo-email", []string{}, created, modified, "UserA", "", "", ""})
+	qUsers1 = append(qUsers1, User{"uuid4", []ProjectRoles{{"ARGO", []string{"publisher", "consumer"}, []string{"topic2"}, []string{"sub3", "sub4"}}}, "UserZ", "", "", "", "", "", "foo-email", []string{}, created, modified, "UserA", "", "", ""})
+	qUsers1 = append(qUsers1, User{"uuid3", []ProjectRoles{{"ARGO", []string{"publisher", "consumer"}, []string{"topic3"}, []string{"sub2"}}}, "UserX", "", "", "", "", "", "foo-email", []string{}, created, modified, "UserA", "", "", ""})
+	qUsers1 = append(qUsers1, User{"uuid2", []ProjectRoles{{"ARGO", []string{"consumer", "publisher"}, []string{"topic1", "topic2"}, []string{"sub1", "sub3", "sub4"}}}, "UserB", "", "", "", "", "", "foo-email", []string{}, created, modified, "UserA", "", "", ""})
+	qUsers1 = append(qUsers1, User{"uuid1", []ProjectRoles{{"ARGO", []string{"consumer", "publisher"}, []string{"topic1", "topic2"}, []string{"sub1", "sub2", "sub3"}}}, "UserA", "FirstA", "LastA", "OrgA", "DescA", "", "foo-email", []string{}, created, modified, "", "", "", ""})
+	qUsers1 = append(qUsers1, User{"uuid0", []ProjectRoles{{"ARGO", []string{"consumer", "publisher"}, []string{}, []string{}}}, "Test", "", "", "", "", "", "Test@test.com", []string{}, created, modified, "", "", "", ""})
 	// return all users
 	pu1, e1 := PaginatedFindUsers(suite.ctx, "", 0, "", true, true, store2)
 
 	var qUsers2 []User
-	qUsers2 = append(qUsers2, User{"uuid8", []ProjectRoles{{"ARGO2", []string{"consumer", "publisher"}, []string{}, []string{}}}, "UserZ", "", "", "", "", "S3CR3T1", "foo-email", []string{}, created, modified, ""})
+	qUsers2 = append(qUsers2, User{"uuid8", []ProjectRoles{{"ARGO2", []string{"consumer", "publisher"}, []string{}, []string{}}}, "UserZ", "", "", "", "", "", "foo-email", []string{}, created, modified, "", "", "", ""})
 	qUsers2 = append(qUsers2, User{
 		UUID:         "uuid7",
 		Name:         "push_worker_0",
 		FirstName:    "",
 		LastName:     "",
 		Description:  "",
-		Token:        "push_token",
 		Email:        "foo-email",
 		ServiceRoles: []string{"push_worker"}, CreatedOn: created, ModifiedOn: modified,
 		CreatedBy: "",
 	})
-	qUsers2 = append(qUsers2, User{"same_uuid", []ProjectRoles{{"ARGO", []string{"publisher", "consumer"}, []string{}, []string{}}}, "UserSame2", "", "", "", "", "S3CR3T42", "foo-email", []string{}, created, modified, "UserA"})
+	qUsers2 = append(qUsers2, User{"same_uuid", []ProjectRoles{{"ARGO", []string{"publisher", "consumer"}, []string{}, []string{}}}, "UserSame2", "", "", "", "", "", "foo-email", []string{}, created, modified, "UserA", "", "", ""})
 
 	// return the first page with 2 users
 	pu2, e2 := PaginatedFindUsers(suite.ctx, "", 3, "", true, true, store2)
 
 	var qUsers3 []User
-	qUsers3 = append(qUsers3, User{"uuid4", []ProjectRoles{{"ARGO", []string{"publisher", "consumer"}, []string{"topic2"}, []string{"sub3", "sub4"}}}, "UserZ", "", "", "", "", "S3CR3T4", "foo-email", []string{}, created, modified, "UserA"})
-	qUsers3 = append(qUsers3, User{"uuid3", []ProjectRoles{{"ARGO", []string{"publisher", "consumer"}, []string{"topic3"}, []string{"sub2"}}}, "UserX", "", "", "", "", "S3CR3T3", "foo-email", []string{}, created, modified, "UserA"})
+	qUsers3 = append(qUsers3, User{"uuid4", []ProjectRoles{{"ARGO", []string{"publisher", "consumer"}, []string{"topic2"}, []string{"sub3", "sub4"}}}, "UserZ", "", "", "", "", "", "foo-email", []string{}, created, modified, "UserA", "", "", ""})
+	qUsers3 = append(qUsers3, User{"uuid3", []ProjectRoles{{"ARGO", []string{"publisher", "consumer"}, []string{"topic3"}, []string{"sub2"}}}, "UserX", "", "", "", "", "", "foo-email", []string{}, created, modified, "UserA", "", "", ""})
 	// return the next 2 users
 	pu3, e3 := PaginatedFindUsers(suite.ctx, "NA==", 2, "", true, true, store2)
 
@@ -573,11 +562,11 @@ func (suite *AuthTestSuite) TestAuth() {
 
 	// check user list by project
 	var qUsersB []User
-	qUsersB = append(qUsersB, User{"uuid8", []ProjectRoles{{"ARGO2", []string{"consumer", "publisher"}, []string{}, []string{}}}, "UserZ", "", "", "", "", "S3CR3T1", "foo-email", []string{}, created, modified, ""})
+	qUsersB = append(qUsersB, User{"uuid8", []ProjectRoles{{"ARGO2", []string{"consumer", "publisher"}, []string{}, []string{}}}, "UserZ", "", "", "", "", "", "foo-email", []string{}, created, modified, "", "", "", ""})
 
 	// check user list by project and with unprivileged mode (token redacted)
 	var qUsersC []User
-	qUsersC = append(qUsersC, User{"uuid8", []ProjectRoles{{"ARGO2", []string{"consumer", "publisher"}, []string{}, []string{}}}, "UserZ", "", "", "", "", "", "foo-email", []string{}, created, modified, ""})
+	qUsersC = append(qUsersC, User{"uuid8", []ProjectRoles{{"ARGO2", []string{"consumer", "publisher"}, []string{}, []string{}}}, "UserZ", "", "", "", "", "", "foo-email", []string{}, created, modified, "", "", "", ""})
 
 	// check for non detailed view
 	var ndUser []User
@@ -588,7 +577,6 @@ func (suite *AuthTestSuite) TestAuth() {
 		LastName:     "",
 		Organization: "",
 		Description:  "",
-		Token:        "S3CR3T1",
 		Email:        "foo-email",
 		ServiceRoles: []string{},
 		CreatedOn:    created,
@@ -790,22 +778,73 @@ func (suite *AuthTestSuite) TestModACL() {
 
 	store := stores.NewMockStore("", "")
 
-	e1 := ModACL(suite.ctx, "argo_uuid", "topics", "topic1", []string{"UserX", "UserZ"}, store)
+	e1 := ModACL(suite.ctx, "argo_uuid", "topics", "topic1", ACL{AuthUsers: []string{"UserX", "UserZ"}}, store)
 	suite.Nil(e1)
 
 	tACL1, _ := store.TopicsACL["topic1"]
 	suite.Equal([]string{"uuid3", "uuid4"}, tACL1.ACL)
 
-	e2 := ModACL(suite.ctx, "argo_uuid", "subscriptions", "sub1", []string{"UserX", "UserZ"}, store)
+	e2 := ModACL(suite.ctx, "argo_uuid", "subscriptions", "sub1", ACL{AuthUsers: []string{"UserX", "UserZ"}}, store)
 	suite.Nil(e2)
 
 	sACL1, _ := store.SubsACL["sub1"]
 	suite.Equal([]string{"uuid3", "uuid4"}, sACL1.ACL)
 
-	e3 := ModACL(suite.ctx, "argo_uuid", "mistype", "sub1", []string{"UserX", "UserZ"}, store)
+	e3 := ModACL(suite.ctx, "argo_uuid", "mistype", "sub1", ACL{AuthUsers: []string{"UserX", "UserZ"}}, store)
 	suite.Equal("wrong resource type", e3.Error())
 }
 
+func (suite *AuthTestSuite) TestModACLActions() {
+
+	expJSON := `{
+   "authorized_users": [
+      "UserX",
+      "UserZ"
+   ],
+   "user_actions": {
+      "UserX": [
+         "publish"
+      ]
+   }
+}`
+
+	store := stores.NewMockStore("", "")
+
+	acl := ACL{AuthUsers: []string{"UserX", "UserZ"}, UserActions: map[string][]string{"UserX": {"publish"}}}
+	suite.Nil(ValidateACLActions("topics", acl))
+	suite.Nil(ModACL(suite.ctx, "argo_uuid", "topics", "topic1", acl, store))
+	suite.Equal(map[string][]string{"uuid3": {"publish"}}, store.TopicsACL["topic1"].Actions)
+
+	tACL, _ := GetACL(suite.ctx, "argo_uuid", "topics", "topic1", store)
+	outJSON, _ := tACL.ExportJSON()
+	suite.Equal(expJSON, outJSON)
+
+	// restricted entries are only allowed their actions, the rest are allowed every action
+	suite.True(PerResource(suite.ctx, "argo_uuid", "topics", "topic1", "uuid3", "publish", store))
+	suite.False(PerResource(suite.ctx, "argo_uuid", "topics", "topic1", "uuid3", "metrics", store))
+	suite.True(PerResource(suite.ctx, "argo_uuid", "topics", "topic1", "uuid4", "metrics", store))
+
+	// modifying the acl again replaces the restrictions
+	suite.Nil(ModACL(suite.ctx, "argo_uuid", "topics", "topic1", ACL{AuthUsers: []string{"UserX"}}, store))
+	suite.True(PerResource(suite.ctx, "argo_uuid", "topics", "topic1", "uuid3", "metrics", store))
+
+	suite.Equal("invalid actions for user UserA, which is not part of the acl",
+		ValidateACLActions("topics", ACL{AuthUsers: []string{"UserX"}, UserActions: map[string][]string{"UserA": {"publish"}}}).Error())
+	suite.Equal("invalid actions for group team, at least one action is required",
+		ValidateACLActions("topics", ACL{AuthGroups: []string{"team"}, GroupActions: map[string][]string{"team": {}}}).Error())
+	suite.Equal("invalid action pull for user UserX",
+		ValidateACLActions("topics", ACL{AuthUsers: []string{"UserX"}, UserActions: map[string][]string{"UserX": {"pull"}}}).Error())
+	suite.Nil(ValidateACLActions("subscriptions", ACL{AuthUsers: []string{"UserX"}, UserActions: map[string][]string{"UserX": {"pull"}}}))
+}
+
+func (suite *AuthTestSuite) TestRequiresResourceACL() {
+	suite.True(RequiresResourceACL([]string{"publisher", "consumer"}))
+	suite.True(RequiresResourceACL([]string{}))
+	suite.False(RequiresResourceACL([]string{"consumer", "project_admin"}))
+	suite.False(RequiresResourceACL([]string{"service_admin"}))
+	suite.False(RequiresResourceACL([]string{"push_worker"}))
+}
+
 func (suite *AuthTestSuite) TestAppendToACL() {
 
 	store := stores.NewMockStore("", "")
@@ -852,7 +891,7 @@ func (suite *AuthTestSuite) TestGetPushWorker() {
 
 	// normal case of push enabled true and correct push worker token
 	u1, err1 := GetPushWorker(suite.ctx, "push_token", store)
-	suite.Equal(User{"uuid7", []ProjectRoles{}, "push_worker_0", "", "", "", "", "push_token", "foo-email", []string{"push_worker"}, "2009-11-10T23:00:00Z", "2009-11-10T23:00:00Z", ""}, u1)
+	suite.Equal(User{"uuid7", []ProjectRoles{}, "push_worker_0", "", "", "", "", "", "foo-email", []string{"push_worker"}, "2009-11-10T23:00:00Z", "2009-11-10T23:00:00Z", "", "", "", ""}, u1)
 	suite.Nil(err1)
 
 	//  incorrect push worker token
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+// Group is a named group of users of a project, that can be granted access to topics and subscriptions
+type Group struct {
+	Name      string   `json:"name"`
+	Members   []string `json:"members"`
+	CreatedOn string   `json:"created_on,omitempty"`
+}
+
+// Groups holds a list of groups
+type Groups struct {
+	List []Group `json:"groups"`
+}
+
+// ExportJSON exports a group to json format
+func (g *Group) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(g, "", "   ")
+	return string(output[:]), err
+}
+
+// ExportJSON exports a list of groups to json format
+func (gs *Groups) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(gs, "", "   ")
+	return string(output[:]), err
+}
+
+// GetGroupFromJSON retrieves the members of a group from a JSON input
+func GetGroupFromJSON(input []byte) (Group, error) {
+	g := Group{}
+	err := json.Unmarshal(input, &g)
+	if g.Members == nil {
+		return g, errors.New("wrong argument")
+	}
+	return g, err
+}
+
+// newGroup converts a stored group to its api representation, with the names of its members.
+// Members that no longer exist are left out
+func newGroup(ctx context.Context, qGroup stores.QGroup, store stores.Store) Group {
+	zuluForm := "2006-01-02T15:04:05Z"
+
+	group := Group{
+		Name:      qGroup.Name,
+		Members:   []string{},
+		CreatedOn: qGroup.CreatedOn.UTC().Format(zuluForm),
+	}
+
+	for _, member := range qGroup.Members {
+		username := GetNameByUUID(ctx, member, store)
+		if username == "" {
+			continue
+		}
+		group.Members = append(group.Members, username)
+	}
+
+	return group
+}
+
+// FindGroups returns a specific group or all the groups of a project
+func FindGroups(ctx context.Context, projectUUID string, name string, store stores.Store) (Groups, error) {
+
+	result := Groups{List: []Group{}}
+
+	qGroups, err := store.QueryGroups(ctx, projectUUID, "", name)
+	if err != nil {
+		return result, err
+	}
+
+	if name != "" && len(qGroups) == 0 {
+		return result, errors.New("not found")
+	}
+
+	for _, qGroup := range qGroups {
+		result.List = append(result.List, newGroup(ctx, qGroup, store))
+	}
+
+	return result, nil
+}
+
+// AreValidGroups accepts an array of group names and checks if the groups exist in the project
+func AreValidGroups(ctx context.Context, projectUUID string, groups []string, store stores.Store) (bool, error) {
+
+	var list string
+
+	for _, name := range groups {
+		qGroups, err := store.QueryGroups(ctx, projectUUID, "", name)
+		if err == nil && len(qGroups) > 0 {
+			continue
+		}
+
+		if list != "" {
+			list = list + ", "
+		}
+		list = list + name
+	}
+
+	if list == "" {
+		return true, nil
+	}
+
+	return false, errors.New("Group(s): " + list + " do not exist")
+}
+
+// CreateGroup creates a new group of users in a project
+func CreateGroup(ctx context.Context, projectUUID string, uuid string, name string, members []string, createdOn time.Time, store stores.Store) (Group, error) {
+
+	if _, err := FindGroups(ctx, projectUUID, name, store); err == nil {
+		return Group{}, errors.New("exists")
+	}
+
+	qGroup := stores.QGroup{
+		UUID:        uuid,
+		ProjectUUID: projectUUID,
+		Name:        name,
+		Members:     usernamesToUUIDs(ctx, members, store),
+		CreatedOn:   createdOn,
+	}
+
+	if err := store.InsertGroup(ctx, qGroup); err != nil {
+		return Group{}, errors.New("backend error")
+	}
+
+	return newGroup(ctx, qGroup, store), nil
+}
+
+// AddGroupMembers adds the given users to the members of a group
+func AddGroupMembers(ctx context.Context, projectUUID string, name string, members []string, store stores.Store) (Group, error) {
+
+	if err := store.AppendToGroup(ctx, projectUUID, name, usernamesToUUIDs(ctx, members, store)); err != nil {
+		return Group{}, err
+	}
+
+	stored, err := FindGroups(ctx, projectUUID, name, store)
+	if err != nil {
+		return Group{}, err
+	}
+	return stored.List[0], nil
+}
+
+// RemoveGroupMembers removes the given users from the members of a group
+func RemoveGroupMembers(ctx context.Context, projectUUID string, name string, members []string, store stores.Store) (Group, error) {
+
+	if err := store.RemoveFromGroup(ctx, projectUUID, name, usernamesToUUIDs(ctx, members, store)); err != nil {
+		return Group{}, err
+	}
+
+	stored, err := FindGroups(ctx, projectUUID, name, store)
+	if err != nil {
+		return Group{}, err
+	}
+	return stored.List[0], nil
+}
+
+// DeleteGroup removes a group of a project. The acls that reference it no longer grant anything through it
+func DeleteGroup(ctx context.Context, projectUUID string, name string, store stores.Store) error {
+	return store.RemoveGroups(ctx, projectUUID, name)
+}
+
+// groupNamesToACLEntries transforms group names to the acl entries that reference them
+func groupNamesToACLEntries(ctx context.Context, projectUUID string, groups []string, store stores.Store) []string {
+	entries := []string{}
+	for _, name := range groups {
+		qGroups, err := store.QueryGroups(ctx, projectUUID, "", name)
+		if err != nil || len(qGroups) == 0 {
+			continue
+		}
+		entries = append(entries, stores.GroupACLEntry(qGroups[0].UUID))
+	}
+	return entries
+}
+
+// usernamesToUUIDs transforms user names to user uuids
+func usernamesToUUIDs(ctx context.Context, usernames []string, store stores.Store) []string {
+	userUUIDs := []string{}
+	for _, username := range usernames {
+		userUUIDs = append(userUUIDs, GetUUIDByName(ctx, username, store))
+	}
+	return userUUIDs
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+func (suite *AuthTestSuite) TestManageGroups() {
+
+	store := stores.NewMockStore("", "")
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	group, err := CreateGroup(suite.ctx, "argo_uuid", "group_uuid", "team", []string{"UserA", "UserB"}, created, store)
+	suite.Nil(err)
+	suite.Equal(Group{Name: "team", Members: []string{"UserA", "UserB"}, CreatedOn: "2024-01-01T00:00:00Z"}, group)
+
+	_, err = CreateGroup(suite.ctx, "argo_uuid", "group_uuid2", "team", []string{}, created, store)
+	suite.Equal("exists", err.Error())
+
+	// the same name can be used in another project
+	_, err = CreateGroup(suite.ctx, "argo_uuid2", "group_uuid3", "team", []string{}, created, store)
+	suite.Nil(err)
+
+	groups, err := FindGroups(suite.ctx, "argo_uuid", "", store)
+	suite.Nil(err)
+	suite.Equal([]Group{group}, groups.List)
+
+	_, err = FindGroups(suite.ctx, "argo_uuid", "unknown", store)
+	suite.Equal("not found", err.Error())
+
+	group, err = AddGroupMembers(suite.ctx, "argo_uuid", "team", []string{"UserX", "UserA"}, store)
+	suite.Nil(err)
+	suite.Equal([]string{"UserA", "UserB", "UserX"}, group.Members)
+
+	group, err = RemoveGroupMembers(suite.ctx, "argo_uuid", "team", []string{"UserA"}, store)
+	suite.Nil(err)
+	suite.Equal([]string{"UserB", "UserX"}, group.Members)
+
+	_, err = AddGroupMembers(suite.ctx, "argo_uuid", "unknown", []string{"UserA"}, store)
+	suite.Equal("not found", err.Error())
+
+	ok, err := AreValidGroups(suite.ctx, "argo_uuid", []string{"team"}, store)
+	suite.True(ok)
+	suite.Nil(err)
+	ok, err = AreValidGroups(suite.ctx, "argo_uuid", []string{"team", "unknown", "other"}, store)
+	suite.False(ok)
+	suite.Equal("Group(s): unknown, other do not exist", err.Error())
+
+	suite.Nil(DeleteGroup(suite.ctx, "argo_uuid", "team", store))
+	suite.Equal("not found", DeleteGroup(suite.ctx, "argo_uuid", "team", store).Error())
+}
+
+func (suite *AuthTestSuite) TestGroupACL() {
+
+	expJSON := `{
+   "authorized_users": [
+      "UserX"
+   ],
+   "authorized_groups": [
+      "team"
+   ],
+   "group_members": {
+      "team": [
+         "UserZ",
+         "UserB"
+      ]
+   }
+}`
+
+	store := stores.NewMockStore("", "")
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := CreateGroup(suite.ctx, "argo_uuid", "group_uuid", "team", []string{"UserZ", "UserB"}, created, store)
+	suite.Nil(err)
+
+	suite.False(PerResource(suite.ctx, "argo_uuid", "topics", "topic3", "uuid2", "", store))
+
+	suite.Nil(ModACL(suite.ctx, "argo_uuid", "topics", "topic3", ACL{AuthUsers: []string{"UserX"}, AuthGroups: []string{"team"}}, store))
+	suite.Equal([]string{"uuid3", stores.GroupACLEntry("group_uuid")}, store.TopicsACL["topic3"].ACL)
+
+	// both direct and group-derived access is shown
+	acl, err := GetACL(suite.ctx, "argo_uuid", "topics", "topic3", store)
+	suite.Nil(err)
+	outJSON, _ := acl.ExportJSON()
+	suite.Equal(expJSON, outJSON)
+
+	// members of the group get access, as long as they are members
+	suite.True(PerResource(suite.ctx, "argo_uuid", "topics", "topic3", "uuid2", "", store))
+
+	// a group can be restricted to specific actions
+	restricted := ACL{AuthUsers: []string{"UserX"}, AuthGroups: []string{"team"}, GroupActions: map[string][]string{"team": {"publish"}}}
+	suite.Nil(ModACL(suite.ctx, "argo_uuid", "topics", "topic3", restricted, store))
+	suite.True(PerResource(suite.ctx, "argo_uuid", "topics", "topic3", "uuid2", "publish", store))
+	suite.False(PerResource(suite.ctx, "argo_uuid", "topics", "topic3", "uuid2", "metrics", store))
+	acl, _ = GetACL(suite.ctx, "argo_uuid", "topics", "topic3", store)
+	suite.Equal(map[string][]string{"team": {"publish"}}, acl.GroupActions)
+	_, err = RemoveGroupMembers(suite.ctx, "argo_uuid", "team", []string{"UserB"}, store)
+	suite.Nil(err)
+	suite.False(PerResource(suite.ctx, "argo_uuid", "topics", "topic3", "uuid2", "", store))
+
+	// a deleted group is left out of the acl
+	suite.Nil(DeleteGroup(suite.ctx, "argo_uuid", "team", store))
+	acl, _ = GetACL(suite.ctx, "argo_uuid", "topics", "topic3", store)
+	suite.Equal(ACL{AuthUsers: []string{"UserX"}}, acl)
+}
+
+func (suite *AuthTestSuite) TestGetACLFromJSONWithGroups() {
+
+	acl, err := GetACLFromJSON([]byte(`{"authorized_groups": ["team"]}`))
+	suite.Nil(err)
+	suite.Equal(ACL{AuthUsers: []string{}, AuthGroups: []string{"team"}}, acl)
+
+	_, err = GetACLFromJSON([]byte(`{}`))
+	suite.Equal("wrong argument", err.Error())
+}
@@ -0,0 +1,462 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/projects"
+	"github.com/ARGOeu/argo-messaging/stores"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// oidcClockSkew is the tolerance applied when checking the time based claims of a token
+	oidcClockSkew = time.Minute
+	// oidcJWKSRefreshInterval is the minimum time between two reloads of the JWKS,
+	// it protects the JWKS source from tokens that carry unknown key ids
+	oidcJWKSRefreshInterval = time.Minute
+)
+
+var (
+	ErrOIDCMalformedToken  = errors.New("malformed token")
+	ErrOIDCInvalidToken    = errors.New("invalid token")
+	ErrOIDCUnknownUser     = errors.New("unknown user")
+	ErrOIDCUnverifiedEmail = errors.New("unverified email")
+)
+
+// OIDCClaims are the claims of a verified OIDC bearer token
+type OIDCClaims map[string]interface{}
+
+// String returns the value of a string claim, or an empty string
+func (c OIDCClaims) String(name string) string {
+	v, _ := c[name].(string)
+	return v
+}
+
+// Strings returns the values of a claim that holds either a single string or a list of strings
+func (c OIDCClaims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		result := []string{}
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return []string{}
+}
+
+// time returns the value of a numeric date claim and whether it was present
+func (c OIDCClaims) time(name string) (time.Time, bool) {
+	v, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(v), 0), true
+}
+
+// jwk is a single key of a JSON Web Key Set
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwks is a JSON Web Key Set
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// oidcKey is a public key of the JWKS that can verify token signatures
+type oidcKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// OIDCAuthenticator verifies OIDC bearer tokens and maps them to AMS users
+type OIDCAuthenticator struct {
+	issuer      string
+	audience    string
+	jwksSource  string
+	userClaim   string
+	groupsClaim string
+	groupRoles  []config.OIDCGroupRole
+	client      *http.Client
+
+	mu        sync.Mutex
+	keys      []oidcKey
+	fetchedOn time.Time
+}
+
+// NewOIDCAuthenticator creates an authenticator from the OIDC parameters of the configuration.
+// It returns nil when no issuer has been configured
+func NewOIDCAuthenticator(cfg *config.APICfg) *OIDCAuthenticator {
+
+	if cfg.OIDCIssuer == "" {
+		return nil
+	}
+
+	userClaim := cfg.OIDCUserClaim
+	if userClaim == "" {
+		userClaim = "sub"
+	}
+
+	return &OIDCAuthenticator{
+		issuer:      cfg.OIDCIssuer,
+		audience:    cfg.OIDCAudience,
+		jwksSource:  cfg.OIDCJWKS,
+		userClaim:   userClaim,
+		groupsClaim: cfg.OIDCGroupsClaim,
+		groupRoles:  cfg.OIDCGroupRoles,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Authenticate verifies the bearer token and returns the roles of its user in the project, along with the user's name
+func (a *OIDCAuthenticator) Authenticate(ctx context.Context, projectUUID string, token string, store stores.Store) ([]string, string, error) {
+
+	claims, err := a.Verify(token)
+	if err != nil {
+		return []string{}, "", err
+	}
+
+	var user stores.QUser
+	subject := claims.String(a.userClaim)
+	if subject == "" {
+		return []string{}, "", ErrOIDCUnknownUser
+	}
+
+	if a.userClaim == "email" {
+		// only an email that the issuer has verified proves that the token belongs to the user that owns it
+		if verified, _ := claims["email_verified"].(bool); !verified {
+			return []string{}, "", ErrOIDCUnverifiedEmail
+		}
+		// an email that is shared by several users doesn't map to any of them
+		user, err = store.GetUserFromEmail(ctx, subject)
+		if err != nil {
+			return []string{}, "", ErrOIDCUnknownUser
+		}
+	} else {
+		user, err = store.GetUserFromOIDCSubject(ctx, subject)
+		if err != nil {
+			return []string{}, "", ErrOIDCUnknownUser
+		}
+	}
+
+	roles := append([]string{}, user.ProjectRoles(projectUUID)...)
+
+	if a.groupsClaim != "" {
+		for _, group := range claims.Strings(a.groupsClaim) {
+			for _, gr := range a.groupRoles {
+				if gr.Group != group || projects.GetUUIDByName(ctx, gr.Project, store) != projectUUID {
+					continue
+				}
+				for _, role := range gr.Roles {
+					if !contains(roles, role) {
+						roles = append(roles, role)
+					}
+				}
+			}
+		}
+	}
+
+	return roles, user.Name, nil
+}
+
+// Verify checks the signature, issuer, audience and validity period of a token and returns its claims
+func (a *OIDCAuthenticator) Verify(token string) (OIDCClaims, error) {
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrOIDCMalformedToken
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, ErrOIDCMalformedToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrOIDCMalformedToken
+	}
+
+	hash, ok := jwtHashes[header.Alg]
+	if !ok {
+		return nil, ErrOIDCInvalidToken
+	}
+
+	keys, err := a.keysFor(header.Kid)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "service_log",
+				"jwks":  a.jwksSource,
+				"error": err.Error(),
+			},
+		).Error("Could not load the OIDC JWKS")
+		return nil, ErrOIDCInvalidToken
+	}
+
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+
+	verified := false
+	for _, k := range keys {
+		if k.alg != "" && k.alg != header.Alg {
+			continue
+		}
+		if verifyJWTSignature(header.Alg, k.key, hash, digest, sig) {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return nil, ErrOIDCInvalidToken
+	}
+
+	claims := OIDCClaims{}
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, ErrOIDCMalformedToken
+	}
+
+	if claims.String("iss") != a.issuer {
+		return nil, ErrOIDCInvalidToken
+	}
+
+	// tokens that the issuer minted for other clients are never accepted, not even when no audience has been configured
+	if a.audience == "" || !contains(claims.Strings("aud"), a.audience) {
+		return nil, ErrOIDCInvalidToken
+	}
+
+	now := time.Now()
+	exp, ok := claims.time("exp")
+	if !ok || now.After(exp.Add(oidcClockSkew)) {
+		return nil, ErrOIDCInvalidToken
+	}
+
+	if nbf, ok := claims.time("nbf"); ok && now.Add(oidcClockSkew).Before(nbf) {
+		return nil, ErrOIDCInvalidToken
+	}
+
+	return claims, nil
+}
+
+// keysFor returns the keys that might have signed a token with the given key id.
+// An unknown key id reloads the JWKS, at most once per refresh interval, to pick up rotated keys
+func (a *OIDCAuthenticator) keysFor(kid string) ([]oidcKey, error) {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.keys == nil || (kid != "" && !hasKid(a.keys, kid) && time.Since(a.fetchedOn) > oidcJWKSRefreshInterval) {
+		keys, err := a.loadJWKS()
+		if err != nil && a.keys == nil {
+			return nil, err
+		}
+		if err == nil {
+			a.keys = keys
+			a.fetchedOn = time.Now()
+		}
+	}
+
+	if kid == "" {
+		return a.keys, nil
+	}
+
+	result := []oidcKey{}
+	for _, k := range a.keys {
+		if k.kid == kid {
+			result = append(result, k)
+		}
+	}
+
+	return result, nil
+}
+
+// loadJWKS reads the key set either from a local file or from a http(s) url
+func (a *OIDCAuthenticator) loadJWKS() ([]oidcKey, error) {
+
+	var data []byte
+	var err error
+
+	if strings.HasPrefix(a.jwksSource, "http://") || strings.HasPrefix(a.jwksSource, "https://") {
+		resp, err := a.client.Get(a.jwksSource)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %v", resp.StatusCode)
+		}
+		data, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		data, err = os.ReadFile(a.jwksSource)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	set := jwks{}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := []oidcKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys = append(keys, oidcKey{kid: k.Kid, alg: k.Alg, key: pub})
+	}
+
+	return keys, nil
+}
+
+// publicKey builds the RSA or EC public key that a JWK describes
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %v", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %v", k.Kty)
+}
+
+// jwtHashes holds the hash function of every accepted signing algorithm
+var jwtHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// verifyJWTSignature checks the signature of a token's digest against a public key
+func verifyJWTSignature(alg string, key crypto.PublicKey, hash crypto.Hash, digest []byte, sig []byte) bool {
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(pub, hash, digest, sig) == nil
+		case "PS":
+			return rsa.VerifyPSS(pub, hash, digest, sig, nil) == nil
+		}
+	case *ecdsa.PublicKey:
+		if alg[:2] != "ES" {
+			return false
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(pub, digest, r, s)
+	}
+
+	return false
+}
+
+// decodeJWTSegment decodes a base64url encoded json segment of a token
+func decodeJWTSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func hasKid(keys []oidcKey, kid string) bool {
+	for _, k := range keys {
+		if k.kid == kid {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/stretchr/testify/suite"
+)
+
+type OIDCTestSuite struct {
+	suite.Suite
+	ctx      context.Context
+	key      *rsa.PrivateKey
+	jwksPath string
+}
+
+func (suite *OIDCTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	log.SetOutput(ioutil.Discard)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Require().Nil(err)
+	suite.key = key
+
+	suite.jwksPath = filepath.Join(suite.T().TempDir(), "jwks.json")
+	suite.Require().Nil(os.WriteFile(suite.jwksPath, rsaJWKS("key-1", &key.PublicKey), 0644))
+}
+
+func (suite *OIDCTestSuite) cfg() *config.APICfg {
+	return &config.APICfg{
+		OIDCIssuer:      "https://aai.example.org",
+		OIDCAudience:    "ams",
+		OIDCJWKS:        suite.jwksPath,
+		OIDCUserClaim:   "sub",
+		OIDCGroupsClaim: "groups",
+		OIDCGroupRoles: []config.OIDCGroupRole{
+			{Group: "ams-admins", Project: "ARGO", Roles: []string{"project_admin"}},
+			{Group: "ams-admins", Project: "ARGO2", Roles: []string{"viewer"}},
+		},
+	}
+}
+
+// claims returns a valid set of claims for the given subject
+func (suite *OIDCTestSuite) claims(sub string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss": "https://aai.example.org",
+		"aud": []string{"other", "ams"},
+		"sub": sub,
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"nbf": time.Now().Add(-time.Minute).Unix(),
+	}
+}
+
+func rsaJWKS(kid string, pub *rsa.PublicKey) []byte {
+	set := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	}
+	data, _ := json.Marshal(set)
+	return data
+}
+
+func signRS256(key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (suite *OIDCTestSuite) TestNewOIDCAuthenticator() {
+	suite.Nil(NewOIDCAuthenticator(&config.APICfg{}))
+	a := NewOIDCAuthenticator(&config.APICfg{OIDCIssuer: "https://aai.example.org"})
+	suite.Equal("sub", a.userClaim)
+}
+
+func (suite *OIDCTestSuite) TestVerify() {
+
+	a := NewOIDCAuthenticator(suite.cfg())
+
+	claims, err := a.Verify(signRS256(suite.key, "key-1", suite.claims("UserA")))
+	suite.Nil(err)
+	suite.Equal("UserA", claims.String("sub"))
+	suite.Equal([]string{"other", "ams"}, claims.Strings("aud"))
+
+	// a single audience string is accepted too
+	c := suite.claims("UserA")
+	c["aud"] = "ams"
+	_, err = a.Verify(signRS256(suite.key, "key-1", c))
+	suite.Nil(err)
+
+	c = suite.claims("UserA")
+	c["iss"] = "https://evil.example.org"
+	_, err = a.Verify(signRS256(suite.key, "key-1", c))
+	suite.Equal(ErrOIDCInvalidToken, err)
+
+	c = suite.claims("UserA")
+	c["aud"] = "other"
+	_, err = a.Verify(signRS256(suite.key, "key-1", c))
+	suite.Equal(ErrOIDCInvalidToken, err)
+
+	c = suite.claims("UserA")
+	delete(c, "aud")
+	_, err = a.Verify(signRS256(suite.key, "key-1", c))
+	suite.Equal(ErrOIDCInvalidToken, err)
+
+	// without a configured audience no token is accepted
+	cfg := suite.cfg()
+	cfg.OIDCAudience = ""
+	_, err = NewOIDCAuthenticator(cfg).Verify(signRS256(suite.key, "key-1", suite.claims("UserA")))
+	suite.Equal(ErrOIDCInvalidToken, err)
+
+	c = suite.claims("UserA")
+	c["exp"] = time.Now().Add(-time.Hour).Unix()
+	_, err = a.Verify(signRS256(suite.key, "key-1", c))
+	suite.Equal(ErrOIDCInvalidToken, err)
+
+	c = suite.claims("UserA")
+	delete(c, "exp")
+	_, err = a.Verify(signRS256(suite.key, "key-1", c))
+	suite.Equal(ErrOIDCInvalidToken, err)
+
+	c = suite.claims("UserA")
+	c["nbf"] = time.Now().Add(time.Hour).Unix()
+	_, err = a.Verify(signRS256(suite.key, "key-1", c))
+	suite.Equal(ErrOIDCInvalidToken, err)
+
+	// a token signed by another key
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, err = a.Verify(signRS256(other, "key-1", suite.claims("UserA")))
+	suite.Equal(ErrOIDCInvalidToken, err)
+
+	// unsigned tokens are rejected
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload, _ := json.Marshal(suite.claims("UserA"))
+	_, err = a.Verify(header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".")
+	suite.Equal(ErrOIDCInvalidToken, err)
+
+	_, err = a.Verify("not-a-token")
+	suite.Equal(ErrOIDCMalformedToken, err)
+}
+
+func (suite *OIDCTestSuite) TestVerifyJWKSOverHTTP() {
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "EC",
+			"kid": "ec-1",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(ecKey.PublicKey.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(ecKey.PublicKey.Y.FillBytes(make([]byte, 32))),
+		}},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	defer server.Close()
+
+	cfg := suite.cfg()
+	cfg.OIDCJWKS = server.URL
+	a := NewOIDCAuthenticator(cfg)
+
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": "ec-1"})
+	payload, _ := json.Marshal(suite.claims("UserA"))
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	r, s, _ := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+	sig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	claims, err := a.Verify(input + "." + base64.RawURLEncoding.EncodeToString(sig))
+	suite.Nil(err)
+	suite.Equal("UserA", claims.String("sub"))
+}
+
+func (suite *OIDCTestSuite) TestVerifyKeyRotation() {
+
+	a := NewOIDCAuthenticator(suite.cfg())
+	_, err := a.Verify(signRS256(suite.key, "key-1", suite.claims("UserA")))
+	suite.Nil(err)
+
+	rotated, _ := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Require().Nil(os.WriteFile(suite.jwksPath, rsaJWKS("key-2", &rotated.PublicKey), 0644))
+
+	// the key set is not reloaded before the refresh interval has passed
+	_, err = a.Verify(signRS256(rotated, "key-2", suite.claims("UserA")))
+	suite.Equal(ErrOIDCInvalidToken, err)
+
+	a.fetchedOn = time.Now().Add(-2 * oidcJWKSRefreshInterval)
+	_, err = a.Verify(signRS256(rotated, "key-2", suite.claims("UserA")))
+	suite.Nil(err)
+}
+
+func (suite *OIDCTestSuite) TestAuthenticate() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+	store.UpdateUserOIDCSubject(suite.ctx, "uuid1", "aai-subject-1")
+	a := NewOIDCAuthenticator(suite.cfg())
+
+	// the subject maps to the user that it has been assigned to
+	roles, user, err := a.Authenticate(suite.ctx, "argo_uuid", signRS256(suite.key, "key-1", suite.claims("aai-subject-1")), store)
+	suite.Nil(err)
+	suite.Equal("UserA", user)
+	suite.Equal([]string{"consumer", "publisher"}, roles)
+
+	// the subject doesn't map to a user with the same name
+	_, _, err = a.Authenticate(suite.ctx, "argo_uuid", signRS256(suite.key, "key-1", suite.claims("UserA")), store)
+	suite.Equal(ErrOIDCUnknownUser, err)
+
+	// groups add the roles that they have been mapped to in the same project
+	c := suite.claims("aai-subject-1")
+	c["groups"] = []string{"ams-admins", "others"}
+	roles, _, err = a.Authenticate(suite.ctx, "argo_uuid", signRS256(suite.key, "key-1", c), store)
+	suite.Nil(err)
+	suite.Equal([]string{"consumer", "publisher", "project_admin"}, roles)
+
+	_, _, err = a.Authenticate(suite.ctx, "argo_uuid", signRS256(suite.key, "key-1", suite.claims("unknown")), store)
+	suite.Equal(ErrOIDCUnknownUser, err)
+
+	// the email claim maps to the email of the user
+	cfg := suite.cfg()
+	cfg.OIDCUserClaim = "email"
+	a = NewOIDCAuthenticator(cfg)
+	c = suite.claims("some-subject")
+	c["email"] = "Test@test.com"
+	c["email_verified"] = true
+	roles, user, err = a.Authenticate(suite.ctx, "argo_uuid", signRS256(suite.key, "key-1", c), store)
+	suite.Nil(err)
+	suite.Equal("Test", user)
+	suite.Equal([]string{"consumer", "publisher"}, roles)
+
+	// an email that the issuer hasn't verified doesn't map to a user
+	c["email_verified"] = false
+	_, _, err = a.Authenticate(suite.ctx, "argo_uuid", signRS256(suite.key, "key-1", c), store)
+	suite.Equal(ErrOIDCUnverifiedEmail, err)
+
+	delete(c, "email_verified")
+	_, _, err = a.Authenticate(suite.ctx, "argo_uuid", signRS256(suite.key, "key-1", c), store)
+	suite.Equal(ErrOIDCUnverifiedEmail, err)
+
+	// an email that is shared by several users doesn't map to any of them
+	c["email"] = "foo-email"
+	c["email_verified"] = true
+	_, _, err = a.Authenticate(suite.ctx, "argo_uuid", signRS256(suite.key, "key-1", c), store)
+	suite.Equal(ErrOIDCUnknownUser, err)
+}
+
+func (suite *OIDCTestSuite) TestUpdateUserOIDCSubject() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+
+	user, err := UpdateUserOIDCSubject(suite.ctx, "uuid1", "aai-subject-1", store)
+	suite.Nil(err)
+	suite.Equal("aai-subject-1", user.OIDCSubject)
+
+	// a subject can only belong to a single user
+	_, err = UpdateUserOIDCSubject(suite.ctx, "uuid2", "aai-subject-1", store)
+	suite.Equal("exists", err.Error())
+	_, err = UpdateUserOIDCSubject(suite.ctx, "uuid1", "aai-subject-1", store)
+	suite.Nil(err)
+
+	user, err = UpdateUserOIDCSubject(suite.ctx, "uuid1", "", store)
+	suite.Nil(err)
+	suite.Equal("", user.OIDCSubject)
+}
+
+func TestOIDCTestSuite(t *testing.T) {
+	suite.Run(t, new(OIDCTestSuite))
+}
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+// ServiceAdminRole is the role that manages the service, including the roles themselves,
+// so it cannot be modified or deleted through the api
+const ServiceAdminRole = "service_admin"
+
+// Role is a role along with the routes that it grants access to
+type Role struct {
+	Name   string   `json:"name"`
+	Routes []string `json:"routes"`
+}
+
+// Roles holds a list of roles
+type Roles struct {
+	List []Role `json:"roles"`
+}
+
+// Permissions describes what a user is allowed to do, optionally within a project
+type Permissions struct {
+	User    string   `json:"user"`
+	Project string   `json:"project,omitempty"`
+	Roles   []string `json:"roles"`
+	Routes  []string `json:"routes"`
+}
+
+// ExportJSON exports a role to json format
+func (r *Role) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(r, "", "   ")
+	return string(output[:]), err
+}
+
+// ExportJSON exports a list of roles to json format
+func (rs *Roles) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(rs, "", "   ")
+	return string(output[:]), err
+}
+
+// ExportJSON exports the permissions of a user to json format
+func (p *Permissions) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(p, "", "   ")
+	return string(output[:]), err
+}
+
+// GetRoleFromJSON retrieves the routes of a role from a JSON input
+func GetRoleFromJSON(input []byte) (Role, error) {
+	r := Role{}
+	err := json.Unmarshal(input, &r)
+	return r, err
+}
+
+// FindRoles returns a specific role or all the roles, derived from the roles that each route has been bound to
+func FindRoles(ctx context.Context, name string, store stores.Store) (Roles, error) {
+
+	result := Roles{List: []Role{}}
+
+	qRoles, err := store.QueryResourceRoles(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	routes := map[string][]string{}
+	for _, qRole := range qRoles {
+		for _, role := range qRole.Roles {
+			if name != "" && role != name {
+				continue
+			}
+			if !contains(routes[role], qRole.Name) {
+				routes[role] = append(routes[role], qRole.Name)
+			}
+		}
+	}
+
+	for role, roleRoutes := range routes {
+		sort.Strings(roleRoutes)
+		result.List = append(result.List, Role{Name: role, Routes: roleRoutes})
+	}
+
+	sort.Slice(result.List, func(i, j int) bool {
+		return result.List[i].Name < result.List[j].Name
+	})
+
+	if name != "" && len(result.List) == 0 {
+		return result, errors.New("not found")
+	}
+
+	return result, nil
+}
+
+// validateRoutes checks that a role is bound to at least one route and only to the known ones
+func validateRoutes(routes []string, knownRoutes []string) error {
+
+	if len(routes) == 0 {
+		return errors.New("invalid routes: a role should grant access to at least one route")
+	}
+
+	for _, route := range routes {
+		if !contains(knownRoutes, route) {
+			return errors.New("invalid route: " + route)
+		}
+	}
+
+	return nil
+}
+
+// CreateRole creates a new role that grants access to the given routes
+func CreateRole(ctx context.Context, name string, routes []string, knownRoutes []string, store stores.Store) (Role, error) {
+
+	if _, err := FindRoles(ctx, name, store); err == nil {
+		return Role{}, errors.New("exists")
+	}
+
+	if err := validateRoutes(routes, knownRoutes); err != nil {
+		return Role{}, err
+	}
+
+	for _, route := range routes {
+		if err := store.AddResourceRole(ctx, route, name); err != nil {
+			return Role{}, errors.New("backend error")
+		}
+	}
+
+	stored, err := FindRoles(ctx, name, store)
+	if err != nil {
+		return Role{}, err
+	}
+	return stored.List[0], nil
+}
+
+// UpdateRole replaces the routes that an existing role grants access to
+func UpdateRole(ctx context.Context, name string, routes []string, knownRoutes []string, store stores.Store) (Role, error) {
+
+	if name == ServiceAdminRole {
+		return Role{}, errors.New("invalid role: " + ServiceAdminRole + " cannot be modified")
+	}
+
+	current, err := FindRoles(ctx, name, store)
+	if err != nil {
+		return Role{}, err
+	}
+
+	if err := validateRoutes(routes, knownRoutes); err != nil {
+		return Role{}, err
+	}
+
+	// bind the new routes before unbinding the old ones, so that the role never grants nothing
+	for _, route := range routes {
+		if err := store.AddResourceRole(ctx, route, name); err != nil {
+			return Role{}, errors.New("backend error")
+		}
+	}
+
+	for _, route := range current.List[0].Routes {
+		if contains(routes, route) {
+			continue
+		}
+		if err := store.RemoveResourceRole(ctx, route, name); err != nil {
+			return Role{}, errors.New("backend error")
+		}
+	}
+
+	stored, err := FindRoles(ctx, name, store)
+	if err != nil {
+		return Role{}, err
+	}
+	return stored.List[0], nil
+}
+
+// DeleteRole unbinds a role from all the routes. Users that still have the role are not granted anything by it
+func DeleteRole(ctx context.Context, name string, store stores.Store) error {
+
+	if name == ServiceAdminRole {
+		return errors.New("invalid role: " + ServiceAdminRole + " cannot be deleted")
+	}
+
+	if _, err := FindRoles(ctx, name, store); err != nil {
+		return err
+	}
+
+	return store.RemoveResourceRole(ctx, "", name)
+}
+
+// FindPermissions returns the known routes that a user is authorized for, with the roles that the user
+// has in the given project. Without a project only the service roles of the user are taken into account
+func FindPermissions(ctx context.Context, userUUID string, project string, projectUUID string, knownRoutes []string, store stores.Store) (Permissions, error) {
+
+	users, err := store.QueryUsers(ctx, "", userUUID, "")
+	if err != nil || len(users) == 0 {
+		return Permissions{}, errors.New("not found")
+	}
+
+	result := Permissions{
+		User:    users[0].Name,
+		Project: project,
+		Roles:   users[0].ProjectRoles(projectUUID),
+		Routes:  []string{},
+	}
+
+	for _, route := range knownRoutes {
+		if Authorize(ctx, route, result.Roles, store) {
+			result.Routes = append(result.Routes, route)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+func (suite *AuthTestSuite) TestFindRoles() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+
+	roles, err := FindRoles(suite.ctx, "", store)
+	suite.Nil(err)
+	suite.Equal(Roles{List: []Role{
+		{Name: "admin", Routes: []string{"topics:list_all", "topics:publish"}},
+		{Name: "publisher", Routes: []string{"topics:list_all", "topics:publish"}},
+		{Name: "reader", Routes: []string{"topics:list_all"}},
+	}}, roles)
+
+	roles, err = FindRoles(suite.ctx, "reader", store)
+	suite.Nil(err)
+	suite.Equal([]Role{{Name: "reader", Routes: []string{"topics:list_all"}}}, roles.List)
+
+	_, err = FindRoles(suite.ctx, "unknown", store)
+	suite.Equal("not found", err.Error())
+}
+
+func (suite *AuthTestSuite) TestManageRoles() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+	known := []string{"topics:list_all", "topics:publish", "metrics:opMetrics"}
+
+	role, err := CreateRole(suite.ctx, "metrics_viewer", []string{"metrics:opMetrics", "topics:list_all"}, known, store)
+	suite.Nil(err)
+	suite.Equal(Role{Name: "metrics_viewer", Routes: []string{"metrics:opMetrics", "topics:list_all"}}, role)
+	suite.True(Authorize(suite.ctx, "metrics:opMetrics", []string{"metrics_viewer"}, store))
+
+	_, err = CreateRole(suite.ctx, "metrics_viewer", []string{"metrics:opMetrics"}, known, store)
+	suite.Equal("exists", err.Error())
+
+	_, err = CreateRole(suite.ctx, "other", []string{"topics:unknown"}, known, store)
+	suite.Equal("invalid route: topics:unknown", err.Error())
+
+	_, err = CreateRole(suite.ctx, "other", []string{}, known, store)
+	suite.Equal("invalid routes: a role should grant access to at least one route", err.Error())
+
+	// the routes of a role get replaced
+	role, err = UpdateRole(suite.ctx, "metrics_viewer", []string{"metrics:opMetrics", "topics:publish"}, known, store)
+	suite.Nil(err)
+	suite.Equal(Role{Name: "metrics_viewer", Routes: []string{"metrics:opMetrics", "topics:publish"}}, role)
+	suite.False(Authorize(suite.ctx, "topics:list_all", []string{"metrics_viewer"}, store))
+
+	_, err = UpdateRole(suite.ctx, "unknown", []string{"metrics:opMetrics"}, known, store)
+	suite.Equal("not found", err.Error())
+
+	_, err = UpdateRole(suite.ctx, "metrics_viewer", []string{"topics:unknown"}, known, store)
+	suite.Equal("invalid route: topics:unknown", err.Error())
+
+	_, err = UpdateRole(suite.ctx, ServiceAdminRole, []string{"metrics:opMetrics"}, known, store)
+	suite.Equal("invalid role: service_admin cannot be modified", err.Error())
+
+	suite.Nil(DeleteRole(suite.ctx, "metrics_viewer", store))
+	suite.False(Authorize(suite.ctx, "metrics:opMetrics", []string{"metrics_viewer"}, store))
+	suite.Equal("not found", DeleteRole(suite.ctx, "metrics_viewer", store).Error())
+	suite.Equal("invalid role: service_admin cannot be deleted", DeleteRole(suite.ctx, ServiceAdminRole, store).Error())
+}
+
+func (suite *AuthTestSuite) TestFindPermissions() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+	known := []string{"topics:list_all", "topics:publish", "metrics:opMetrics"}
+
+	perms, err := FindPermissions(suite.ctx, "uuid1", "ARGO", "argo_uuid", known, store)
+	suite.Nil(err)
+	suite.Equal(Permissions{
+		User:    "UserA",
+		Project: "ARGO",
+		Roles:   []string{"consumer", "publisher"},
+		Routes:  []string{"topics:list_all", "topics:publish"},
+	}, perms)
+
+	// without a project only the service roles count
+	perms, err = FindPermissions(suite.ctx, "uuid1", "", "", known, store)
+	suite.Nil(err)
+	suite.Equal([]string{}, perms.Roles)
+	suite.Equal([]string{}, perms.Routes)
+
+	_, err = FindPermissions(suite.ctx, "unknown", "", "", known, store)
+	suite.Equal("not found", err.Error())
+}
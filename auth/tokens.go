@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+	log "github.com/sirupsen/logrus"
+)
+
+// tokenHashPrefix marks the user tokens that are stored as hashes, stored tokens without it predate hashing
+const tokenHashPrefix = "hmac-sha256:"
+
+// tokenPepper is the server side secret that the user tokens are hashed with
+var tokenPepper []byte
+
+// SetTokenPepper sets the server side secret that the user tokens are hashed with.
+// Changing it invalidates every stored token
+func SetTokenPepper(pepper string) {
+	tokenPepper = []byte(pepper)
+}
+
+// HashToken returns the form in which a user token is stored and looked up
+func HashToken(token string) string {
+	mac := hmac.New(sha256.New, tokenPepper)
+	mac.Write([]byte(token))
+	return tokenHashPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsTokenHashed checks whether a stored user token is a hash
+func IsTokenHashed(stored string) bool {
+	return strings.HasPrefix(stored, tokenHashPrefix)
+}
+
+// migrateLegacyToken hashes the token of the user that still has it stored in plaintext.
+// It returns whether such a user has been found
+func migrateLegacyToken(ctx context.Context, token string, store stores.Store) bool {
+
+	// a stored hash presented as a token should never match itself
+	if token == "" || IsTokenHashed(token) {
+		return false
+	}
+
+	user, err := store.GetUserFromToken(ctx, token)
+	if err != nil {
+		return false
+	}
+
+	if err := store.UpdateUserToken(ctx, user.UUID, HashToken(token)); err != nil {
+		log.WithFields(
+			log.Fields{
+				"trace_id": ctx.Value("trace_id"),
+				"type":     "service_log",
+				"user":     user.UUID,
+				"error":    err.Error(),
+			},
+		).Error("Could not hash the token of user")
+		return false
+	}
+
+	return true
+}
+
+// MigrateTokens hashes the tokens of all the users that still have them stored in plaintext
+// and returns how many have been hashed
+func MigrateTokens(ctx context.Context, store stores.Store) (int, error) {
+
+	users, err := store.QueryUsers(ctx, "", "", "")
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, user := range users {
+		if user.Token == "" || IsTokenHashed(user.Token) {
+			continue
+		}
+		if err := store.UpdateUserToken(ctx, user.UUID, HashToken(user.Token)); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+func (suite *AuthTestSuite) TestHashToken() {
+
+	defer SetTokenPepper("")
+
+	hash := HashToken("S3CR3T1")
+	suite.True(IsTokenHashed(hash))
+	suite.False(IsTokenHashed("S3CR3T1"))
+	suite.Equal(hash, HashToken("S3CR3T1"))
+	suite.NotEqual(hash, HashToken("S3CR3T2"))
+
+	// the pepper changes every hash
+	SetTokenPepper("pepper")
+	suite.NotEqual(hash, HashToken("S3CR3T1"))
+}
+
+func (suite *AuthTestSuite) TestCreateUserHashesToken() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+	tm := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	// the token is only shown when it is created
+	usr, err := CreateUser(suite.ctx, "uuid12", "johndoe", "", "", "", "", []ProjectRoles{{Project: "ARGO", Roles: []string{"consumer"}}}, "TOK3N", "johndoe@fake.email.foo", []string{}, tm, "", store)
+	suite.Nil(err)
+	suite.Equal("TOK3N", usr.Token)
+
+	qUsers, _ := store.QueryUsers(suite.ctx, "", "uuid12", "")
+	suite.Equal(HashToken("TOK3N"), qUsers[0].Token)
+
+	found, _ := FindUsers(suite.ctx, "", "uuid12", "", true, store)
+	suite.Equal("", found.One().Token)
+
+	byToken, err := GetUserByToken(suite.ctx, "TOK3N", store)
+	suite.Nil(err)
+	suite.Equal("johndoe", byToken.Name)
+	suite.Equal("", byToken.Token)
+
+	roles, user := Authenticate(suite.ctx, "argo_uuid", "TOK3N", store)
+	suite.Equal("johndoe", user)
+	suite.Equal([]string{"consumer"}, roles)
+
+	// the stored hash does not work as a token
+	_, err = GetUserByToken(suite.ctx, HashToken("TOK3N"), store)
+	suite.NotNil(err)
+	_, user = Authenticate(suite.ctx, "argo_uuid", HashToken("TOK3N"), store)
+	suite.Equal("", user)
+
+	// refreshing replaces the hash and shows the new token once
+	usr, err = UpdateUserToken(suite.ctx, "uuid12", "N3WTOK3N", store)
+	suite.Nil(err)
+	suite.Equal("N3WTOK3N", usr.Token)
+	qUsers, _ = store.QueryUsers(suite.ctx, "", "uuid12", "")
+	suite.Equal(HashToken("N3WTOK3N"), qUsers[0].Token)
+	_, user = Authenticate(suite.ctx, "argo_uuid", "TOK3N", store)
+	suite.Equal("", user)
+}
+
+func (suite *AuthTestSuite) TestLegacyTokens() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+
+	// tokens are updated by uuid, so leave out the mock users that share one
+	userList := []stores.QUser{}
+	for _, u := range store.UserList {
+		if u.UUID != "same_uuid" {
+			userList = append(userList, u)
+		}
+	}
+	store.UserList = userList
+
+	// the mock users have their tokens stored in plaintext and get migrated on their first use
+	roles, user := Authenticate(suite.ctx, "argo_uuid", "S3CR3T1", store)
+	suite.Equal("UserA", user)
+	suite.Equal([]string{"consumer", "publisher"}, roles)
+	suite.Equal(HashToken("S3CR3T1"), store.UserList[1].Token)
+
+	roles, user = Authenticate(suite.ctx, "argo_uuid", "S3CR3T1", store)
+	suite.Equal("UserA", user)
+	suite.Equal([]string{"consumer", "publisher"}, roles)
+
+	byToken, err := GetUserByToken(suite.ctx, "S3CR3T2", store)
+	suite.Nil(err)
+	suite.Equal("UserB", byToken.Name)
+	suite.Equal(HashToken("S3CR3T2"), store.UserList[2].Token)
+
+	// the rest get migrated all at once
+	migrated, err := MigrateTokens(suite.ctx, store)
+	suite.Nil(err)
+	suite.Equal(len(store.UserList)-2, migrated)
+	for _, u := range store.UserList {
+		suite.True(IsTokenHashed(u.Token))
+	}
+
+	migrated, _ = MigrateTokens(suite.ctx, store)
+	suite.Equal(0, migrated)
+
+	_, user = Authenticate(suite.ctx, "argo_uuid", "S3CR3T3", store)
+	suite.Equal("UserX", user)
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/ARGOeu/argo-messaging/projects"
+	"github.com/ARGOeu/argo-messaging/ratelimit"
 	"github.com/ARGOeu/argo-messaging/stores"
 	log "github.com/sirupsen/logrus"
 )
@@ -37,6 +38,9 @@ type User struct {
 	CreatedOn    string         `json:"created_on,omitempty"`
 	ModifiedOn   string         `json:"modified_on,omitempty"`
 	CreatedBy    string         `json:"created_by,omitempty"`
+	DN           string         `json:"dn,omitempty"`
+	IssuerDN     string         `json:"issuer_dn,omitempty"`
+	OIDCSubject  string         `json:"oidc_subject,omitempty"`
 }
 
 // ProjectRoles is the struct that hold project and role information of the user
@@ -61,19 +65,20 @@ type PaginatedUsers struct {
 
 // UserRegistration holds information about a new user registration
 type UserRegistration struct {
-	UUID            string `json:"uuid"`
-	Name            string `json:"name"`
-	FirstName       string `json:"first_name"`
-	LastName        string `json:"last_name"`
-	Organization    string `json:"organization"`
-	Description     string `json:"description"`
-	Email           string `json:"email"`
-	Status          string `json:"status"`
-	DeclineComment  string `json:"decline_comment,omitempty"`
-	ActivationToken string `json:"activation_token,omitempty"`
-	RegisteredAt    string `json:"registered_at"`
-	ModifiedBy      string `json:"modified_by,omitempty"`
-	ModifiedAt      string `json:"modified_at,omitempty"`
+	UUID             string `json:"uuid"`
+	Name             string `json:"name"`
+	FirstName        string `json:"first_name"`
+	LastName         string `json:"last_name"`
+	Organization     string `json:"organization"`
+	Description      string `json:"description"`
+	Email            string `json:"email"`
+	Status           string `json:"status"`
+	DeclineComment   string `json:"decline_comment,omitempty"`
+	ActivationToken  string `json:"activation_token,omitempty"`
+	RegisteredAt     string `json:"registered_at"`
+	ModifiedBy       string `json:"modified_by,omitempty"`
+	ModifiedAt       string `json:"modified_at,omitempty"`
+	EmailConfirmedAt string `json:"email_confirmed_at,omitempty"`
 }
 
 // UserRegistrationsList holds a list with all the user registrations in the service
@@ -122,6 +127,31 @@ func GetUserFromJSON(input []byte) (User, error) {
 	return u, err
 }
 
+// UserDN holds the client certificate subject of a user and the subject of the CA that issues the certificate
+type UserDN struct {
+	DN       string `json:"dn"`
+	IssuerDN string `json:"issuer_dn"`
+}
+
+// GetUserDNFromJSON retrieves a client certificate subject from a JSON input
+func GetUserDNFromJSON(input []byte) (UserDN, error) {
+	u := UserDN{}
+	err := json.Unmarshal([]byte(input), &u)
+	return u, err
+}
+
+// UserOIDCSubject holds the subject claim of the OIDC tokens that authenticate a user
+type UserOIDCSubject struct {
+	OIDCSubject string `json:"oidc_subject"`
+}
+
+// GetUserOIDCSubjectFromJSON retrieves an OIDC subject from a JSON input
+func GetUserOIDCSubjectFromJSON(input []byte) (UserOIDCSubject, error) {
+	u := UserOIDCSubject{}
+	err := json.Unmarshal([]byte(input), &u)
+	return u, err
+}
+
 // RegisterUser registers a new user to the store
 func RegisterUser(ctx context.Context, uuid, name, fname, lname, email, org, desc, registeredAt, atkn, status string, str stores.Store) (UserRegistration, error) {
 
@@ -170,19 +200,20 @@ func FindUserRegistration(ctx context.Context, regUUID, status string, str store
 	}
 
 	ur := UserRegistration{
-		UUID:            q[0].UUID,
-		Name:            q[0].Name,
-		FirstName:       q[0].FirstName,
-		LastName:        q[0].LastName,
-		Email:           q[0].Email,
-		ActivationToken: q[0].ActivationToken,
-		Status:          q[0].Status,
-		DeclineComment:  q[0].DeclineComment,
-		Organization:    q[0].Organization,
-		Description:     q[0].Description,
-		RegisteredAt:    q[0].RegisteredAt,
-		ModifiedBy:      usernameC,
-		ModifiedAt:      q[0].ModifiedAt,
+		UUID:             q[0].UUID,
+		Name:             q[0].Name,
+		FirstName:        q[0].FirstName,
+		LastName:         q[0].LastName,
+		Email:            q[0].Email,
+		ActivationToken:  q[0].ActivationToken,
+		Status:           q[0].Status,
+		DeclineComment:   q[0].DeclineComment,
+		Organization:     q[0].Organization,
+		Description:      q[0].Description,
+		RegisteredAt:     q[0].RegisteredAt,
+		ModifiedBy:       usernameC,
+		ModifiedAt:       q[0].ModifiedAt,
+		EmailConfirmedAt: q[0].EmailConfirmedAt,
 	}
 
 	return ur, nil
@@ -211,19 +242,20 @@ func FindUserRegistrations(ctx context.Context, status, activationToken, name, e
 		}
 
 		tempUR := UserRegistration{
-			UUID:            ur.UUID,
-			Name:            ur.Name,
-			FirstName:       ur.FirstName,
-			LastName:        ur.LastName,
-			Email:           ur.Email,
-			ActivationToken: ur.ActivationToken,
-			Status:          ur.Status,
-			DeclineComment:  ur.DeclineComment,
-			Organization:    ur.Organization,
-			Description:     ur.Description,
-			RegisteredAt:    ur.RegisteredAt,
-			ModifiedBy:      usernameC,
-			ModifiedAt:      ur.ModifiedAt,
+			UUID:             ur.UUID,
+			Name:             ur.Name,
+			FirstName:        ur.FirstName,
+			LastName:         ur.LastName,
+			Email:            ur.Email,
+			ActivationToken:  ur.ActivationToken,
+			Status:           ur.Status,
+			DeclineComment:   ur.DeclineComment,
+			Organization:     ur.Organization,
+			Description:      ur.Description,
+			RegisteredAt:     ur.RegisteredAt,
+			ModifiedBy:       usernameC,
+			ModifiedAt:       ur.ModifiedAt,
+			EmailConfirmedAt: ur.EmailConfirmedAt,
 		}
 
 		urList.UserRegistrations = append(urList.UserRegistrations, tempUR)
@@ -240,8 +272,13 @@ func UpdateUserRegistration(ctx context.Context, regUUID, status, declineComment
 	return refStr.UpdateRegistration(ctx, regUUID, status, declineComment, modifiedBy, modifiedAt.UTC().Format("2006-01-02T15:04:05Z"))
 }
 
+// ConfirmUserRegistrationEmail marks the email of a registration as confirmed at the given time
+func ConfirmUserRegistrationEmail(ctx context.Context, regUUID string, confirmedAt time.Time, refStr stores.Store) error {
+	return refStr.ConfirmRegistrationEmail(ctx, regUUID, confirmedAt.UTC().Format("2006-01-02T15:04:05Z"))
+}
+
 // NewUser accepts parameters and creates a new user
-func NewUser(uuid string, projects []ProjectRoles, name string, fname string, lname string, org string, desc string, token string, email string, serviceRoles []string, createdOn time.Time, modifiedOn time.Time, createdBy string) User {
+func NewUser(uuid string, projects []ProjectRoles, name string, fname string, lname string, org string, desc string, token string, email string, serviceRoles []string, createdOn time.Time, modifiedOn time.Time, createdBy string, dn string, issuerDN string, oidcSubject string) User {
 	zuluForm := "2006-01-02T15:04:05Z"
 	return User{
 		UUID:         uuid,
@@ -256,7 +293,10 @@ func NewUser(uuid string, projects []ProjectRoles, name string, fname string, ln
 		ServiceRoles: serviceRoles,
 		CreatedOn:    createdOn.Format(zuluForm),
 		ModifiedOn:   modifiedOn.Format(zuluForm),
-		CreatedBy:    createdBy}
+		CreatedBy:    createdBy,
+		DN:           dn,
+		IssuerDN:     issuerDN,
+		OIDCSubject:  oidcSubject}
 }
 
 // GetPushWorker returns a push worker user by token
@@ -282,7 +322,10 @@ func GetPushWorker(ctx context.Context, pwToken string, store stores.Store) (Use
 func GetUserByToken(ctx context.Context, token string, store stores.Store) (User, error) {
 	result := User{}
 
-	user, err := store.GetUserFromToken(ctx, token)
+	user, err := store.GetUserFromToken(ctx, HashToken(token))
+	if err != nil && migrateLegacyToken(ctx, token, store) {
+		user, err = store.GetUserFromToken(ctx, HashToken(token))
+	}
 
 	if err != nil {
 		return result, err
@@ -317,8 +360,8 @@ func GetUserByToken(ctx context.Context, token string, store stores.Store) (User
 	}
 
 	curUser := NewUser(user.UUID, pRoles, user.Name, user.FirstName,
-		user.LastName, user.Organization, user.Description, user.Token, user.Email,
-		user.ServiceRoles, user.CreatedOn.UTC(), user.ModifiedOn.UTC(), usernameC)
+		user.LastName, user.Organization, user.Description, "", user.Email,
+		user.ServiceRoles, user.CreatedOn.UTC(), user.ModifiedOn.UTC(), usernameC, user.DN, user.IssuerDN, user.OIDCSubject)
 
 	result = curUser
 
@@ -337,10 +380,9 @@ func FindUsers(ctx context.Context, projectUUID string, uuid string, name string
 
 		// Get Username from user uuid
 		serviceRoles := []string{}
-		token := ""
 		usernameC := ""
 
-		// if call made by priviledged user (superuser), show service roles, token and user creator info
+		// if call made by priviledged user (superuser), show service roles and user creator info
 		if priviledged {
 			if item.CreatedBy != "" {
 				usr, err := store.QueryUsers(ctx, "", item.CreatedBy, "")
@@ -349,7 +391,6 @@ func FindUsers(ctx context.Context, projectUUID string, uuid string, name string
 
 				}
 			}
-			token = item.Token
 			serviceRoles = item.ServiceRoles
 		}
 
@@ -385,8 +426,8 @@ func FindUsers(ctx context.Context, projectUUID string, uuid string, name string
 		}
 
 		curUser := NewUser(item.UUID, pRoles, item.Name, item.FirstName, item.LastName,
-			item.Organization, item.Description, token, item.Email, serviceRoles,
-			item.CreatedOn.UTC(), item.ModifiedOn.UTC(), usernameC)
+			item.Organization, item.Description, "", item.Email, serviceRoles,
+			item.CreatedOn.UTC(), item.ModifiedOn.UTC(), usernameC, item.DN, item.IssuerDN, item.OIDCSubject)
 
 		result.List = append(result.List, curUser)
 	}
@@ -430,9 +471,8 @@ func PaginatedFindUsers(ctx context.Context, pageToken string, pageSize int64, p
 
 		// Get Username from user uuid
 		serviceRoles := []string{}
-		token := ""
 		usernameC := ""
-		// if call made by priviledged user (superuser), show service roles, token and user creator info
+		// if call made by priviledged user (superuser), show service roles and user creator info
 		if privileged {
 			if item.CreatedBy != "" {
 				usr, err := store.QueryUsers(ctx, "", item.CreatedBy, "")
@@ -441,7 +481,6 @@ func PaginatedFindUsers(ctx context.Context, pageToken string, pageSize int64, p
 
 				}
 			}
-			token = item.Token
 			serviceRoles = item.ServiceRoles
 		}
 
@@ -474,8 +513,8 @@ func PaginatedFindUsers(ctx context.Context, pageToken string, pageSize int64, p
 		}
 
 		curUser := NewUser(item.UUID, pRoles, item.Name, item.FirstName, item.LastName,
-			item.Organization, item.Description, token, item.Email, serviceRoles,
-			item.CreatedOn.UTC(), item.ModifiedOn.UTC(), usernameC)
+			item.Organization, item.Description, "", item.Email, serviceRoles,
+			item.CreatedOn.UTC(), item.ModifiedOn.UTC(), usernameC, item.DN, item.IssuerDN, item.OIDCSubject)
 
 		result.Users = append(result.Users, curUser)
 	}
@@ -489,7 +528,26 @@ func PaginatedFindUsers(ctx context.Context, pageToken string, pageSize int64, p
 
 // Authenticate based on token
 func Authenticate(ctx context.Context, projectUUID string, token string, store stores.Store) ([]string, string) {
-	return store.GetUserRoles(ctx, projectUUID, token)
+	roles, user := store.GetUserRoles(ctx, projectUUID, HashToken(token))
+	if user == "" && migrateLegacyToken(ctx, token, store) {
+		roles, user = store.GetUserRoles(ctx, projectUUID, HashToken(token))
+	}
+	if user != "" {
+		return roles, user
+	}
+
+	// the token might be one of the named api keys of a user
+	return authenticateAPIKey(ctx, projectUUID, token, store)
+}
+
+// AuthenticateDN returns the roles in the project and the name of the user that a client certificate belongs to.
+// The subject and the issuer of the certificate are expected in the canonical form of CertificateDN and CertificateIssuerDN
+func AuthenticateDN(ctx context.Context, projectUUID string, dn string, issuerDN string, store stores.Store) ([]string, string) {
+	user, err := store.GetUserFromDN(ctx, dn, issuerDN)
+	if err != nil {
+		return []string{}, ""
+	}
+	return user.ProjectRoles(projectUUID), user.Name
 }
 
 // ExistsWithName returns true if a user with name exists
@@ -579,8 +637,8 @@ func GetUserByUUID(ctx context.Context, uuid string, store stores.Store) (User,
 	}
 
 	curUser := NewUser(user.UUID, pRoles, user.Name, user.FirstName,
-		user.LastName, user.Organization, user.Description, user.Token, user.Email,
-		user.ServiceRoles, user.CreatedOn.UTC(), user.ModifiedOn.UTC(), usernameC)
+		user.LastName, user.Organization, user.Description, "", user.Email,
+		user.ServiceRoles, user.CreatedOn.UTC(), user.ModifiedOn.UTC(), usernameC, user.DN, user.IssuerDN, user.OIDCSubject)
 
 	result = curUser
 
@@ -600,9 +658,66 @@ func GetUUIDByName(ctx context.Context, name string, store stores.Store) string
 	return result
 }
 
-// UpdateUserToken updates an existing user's token
+// UpdateUserToken updates an existing user's token. Only the hash of the token is stored,
+// so the returned user is the only place that it is shown
 func UpdateUserToken(ctx context.Context, uuid string, token string, store stores.Store) (User, error) {
-	if err := store.UpdateUserToken(ctx, uuid, token); err != nil {
+	if err := store.UpdateUserToken(ctx, uuid, HashToken(token)); err != nil {
+		return User{}, err
+	}
+	// reflect stored object
+	stored, err := FindUsers(ctx, "", uuid, "", true, store)
+	if err != nil {
+		return User{}, err
+	}
+	user := stored.One()
+	user.Token = token
+	return user, nil
+}
+
+// UpdateUserDN sets the subject of the client certificate that authenticates the user, along with the subject of the CA
+// that has to have issued it, so that the same subject can't be reused under another CA. An empty dn removes both
+func UpdateUserDN(ctx context.Context, uuid string, dn string, issuerDN string, store stores.Store) (User, error) {
+
+	dn, err := NormalizeDN(dn)
+	if err != nil {
+		return User{}, errors.New("invalid dn")
+	}
+
+	if dn == "" {
+		issuerDN = ""
+	} else {
+		issuerDN, err = NormalizeDN(issuerDN)
+		if err != nil {
+			return User{}, errors.New("invalid issuer dn")
+		}
+		if issuerDN == "" {
+			return User{}, errors.New("empty issuer dn")
+		}
+		// a certificate can only authenticate a single user
+		if other, err := store.GetUserFromDN(ctx, dn, issuerDN); err == nil && other.UUID != uuid {
+			return User{}, errors.New("exists")
+		}
+	}
+
+	if err := store.UpdateUserDN(ctx, uuid, dn, issuerDN); err != nil {
+		return User{}, err
+	}
+	// reflect stored object
+	stored, err := FindUsers(ctx, "", uuid, "", true, store)
+	return stored.One(), err
+}
+
+// UpdateUserOIDCSubject sets the subject claim of the OIDC tokens that authenticate the user. An empty subject removes it
+func UpdateUserOIDCSubject(ctx context.Context, uuid string, subject string, store stores.Store) (User, error) {
+
+	// a subject can only authenticate a single user
+	if subject != "" {
+		if other, err := store.GetUserFromOIDCSubject(ctx, subject); err == nil && other.UUID != uuid {
+			return User{}, errors.New("exists")
+		}
+	}
+
+	if err := store.UpdateUserOIDCSubject(ctx, uuid, subject); err != nil {
 		return User{}, err
 	}
 	// reflect stored object
@@ -755,13 +870,18 @@ func CreateUser(ctx context.Context, uuid string, name string, fname string, lna
 		}
 	}
 
-	if err := store.InsertUser(ctx, uuid, prList, name, fname, lname, org, desc, token, email, serviceRoles, createdOn, createdOn, createdBy); err != nil {
+	if err := store.InsertUser(ctx, uuid, prList, name, fname, lname, org, desc, HashToken(token), email, serviceRoles, createdOn, createdOn, createdBy); err != nil {
 		return User{}, errors.New("backend error")
 	}
 
-	// reflect stored object
+	// reflect stored object, the token is only shown once since only its hash is stored
 	stored, err := FindUsers(ctx, "", "", name, true, store)
-	return stored.One(), err
+	if err != nil {
+		return User{}, err
+	}
+	user := stored.One()
+	user.Token = token
+	return user, nil
 }
 
 // GenToken generates a new token
@@ -843,7 +963,13 @@ func IsAdminViewer(roles []string) bool {
 
 // RemoveUser removes an existing user
 func RemoveUser(ctx context.Context, uuid string, store stores.Store) error {
-	return store.RemoveUser(ctx, uuid)
+	if err := store.RemoveUser(ctx, uuid); err != nil {
+		return err
+	}
+	if err := store.UpdateRateLimits(ctx, ratelimit.ScopeUser, uuid, nil); err != nil {
+		return err
+	}
+	return store.RemoveAPIKeys(ctx, uuid, "")
 }
 
 // IsRoleValid checks if a role is a valid against a list of valid roles
@@ -877,11 +1003,12 @@ func AreValidUsers(ctx context.Context, projectUUID string, users []string, stor
 
 }
 
-// PerResource  (for topics and subscriptions)
-func PerResource(ctx context.Context, project string, resType string, resName string, userUUID string, store stores.Store) bool {
+// PerResource checks if a user is authorized for an action on a topic or subscription through its acl,
+// an empty action matches any
+func PerResource(ctx context.Context, project string, resType string, resName string, userUUID string, action string, store stores.Store) bool {
 
 	if resType == "topics" || resType == "subscriptions" {
-		err := store.ExistsInACL(ctx, project, resType, resName, userUUID)
+		err := store.ExistsInACL(ctx, project, resType, resName, userUUID, action)
 		if err != nil {
 			log.WithFields(
 				log.Fields{
@@ -0,0 +1,382 @@
+package auth
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// dnAttributeTypes maps the names and aliases of the attribute types that a DN may use, in lower case, to their object identifiers
+var dnAttributeTypes = map[string]string{
+	"cn":                     "2.5.4.3",
+	"commonname":             "2.5.4.3",
+	"sn":                     "2.5.4.4",
+	"surname":                "2.5.4.4",
+	"serialnumber":           "2.5.4.5",
+	"c":                      "2.5.4.6",
+	"countryname":            "2.5.4.6",
+	"l":                      "2.5.4.7",
+	"localityname":           "2.5.4.7",
+	"st":                     "2.5.4.8",
+	"s":                      "2.5.4.8",
+	"stateorprovincename":    "2.5.4.8",
+	"street":                 "2.5.4.9",
+	"streetaddress":          "2.5.4.9",
+	"o":                      "2.5.4.10",
+	"organizationname":       "2.5.4.10",
+	"ou":                     "2.5.4.11",
+	"organizationalunitname": "2.5.4.11",
+	"title":                  "2.5.4.12",
+	"postalcode":             "2.5.4.17",
+	"gn":                     "2.5.4.42",
+	"givenname":              "2.5.4.42",
+	"dc":                     "0.9.2342.19200300.100.1.25",
+	"domaincomponent":        "0.9.2342.19200300.100.1.25",
+	"uid":                    "0.9.2342.19200300.100.1.1",
+	"userid":                 "0.9.2342.19200300.100.1.1",
+	"e":                      "1.2.840.113549.1.9.1",
+	"email":                  "1.2.840.113549.1.9.1",
+	"emailaddress":           "1.2.840.113549.1.9.1",
+}
+
+// dnAttributeNames holds the name that the canonical form of a DN uses for each known attribute type
+var dnAttributeNames = map[string]string{
+	"2.5.4.3":                    "CN",
+	"2.5.4.4":                    "SN",
+	"2.5.4.5":                    "SERIALNUMBER",
+	"2.5.4.6":                    "C",
+	"2.5.4.7":                    "L",
+	"2.5.4.8":                    "ST",
+	"2.5.4.9":                    "STREET",
+	"2.5.4.10":                   "O",
+	"2.5.4.11":                   "OU",
+	"2.5.4.12":                   "TITLE",
+	"2.5.4.17":                   "POSTALCODE",
+	"2.5.4.42":                   "GN",
+	"0.9.2342.19200300.100.1.25": "DC",
+	"0.9.2342.19200300.100.1.1":  "UID",
+	"1.2.840.113549.1.9.1":       "emailAddress",
+}
+
+// CertificateDN returns the subject of a client certificate in the canonical form of NormalizeDN
+func CertificateDN(cert *x509.Certificate) string {
+	return canonicalDN(certificateName(cert.RawSubject, cert.Subject))
+}
+
+// CertificateIssuerDN returns the issuer of a client certificate in the canonical form of NormalizeDN
+func CertificateIssuerDN(cert *x509.Certificate) string {
+	return canonicalDN(certificateName(cert.RawIssuer, cert.Issuer))
+}
+
+// certificateName returns the RDN sequence of a certificate name, as it is encoded in the certificate when possible,
+// so that multi-valued RDNs keep their attributes together
+func certificateName(raw []byte, name pkix.Name) pkix.RDNSequence {
+	seq := pkix.RDNSequence{}
+	if len(raw) > 0 {
+		if rest, err := asn1.Unmarshal(raw, &seq); err == nil && len(rest) == 0 {
+			return seq
+		}
+	}
+	return name.ToRDNSequence()
+}
+
+// NormalizeDN parses a DN, either in the RFC 4514 form, e.g. CN=John Doe,O=Example,C=GR, or in the slash separated
+// openssl form, e.g. /C=GR/O=Example/CN=John Doe, and returns it in a canonical RFC 4514 form, so that the same name
+// always compares equal regardless of attribute type aliases, escaping or the order of multi-valued RDNs
+func NormalizeDN(dn string) (string, error) {
+
+	dn = strings.TrimSpace(dn)
+	if dn == "" {
+		return "", nil
+	}
+
+	var seq pkix.RDNSequence
+	var err error
+
+	if strings.HasPrefix(dn, "/") {
+		seq, err = parseOpenSSLDN(dn)
+	} else {
+		seq, err = parseRFC4514DN(dn)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return canonicalDN(seq), nil
+}
+
+// parseRFC4514DN parses a DN in the RFC 4514 form, which lists the most significant RDN last
+func parseRFC4514DN(dn string) (pkix.RDNSequence, error) {
+
+	seq := pkix.RDNSequence{}
+	rdn := pkix.RelativeDistinguishedNameSET{}
+
+	for i := 0; i < len(dn); {
+
+		eq := strings.IndexByte(dn[i:], '=')
+		if eq < 0 {
+			return nil, errors.New("invalid dn")
+		}
+		oid, err := parseAttributeType(dn[i : i+eq])
+		if err != nil {
+			return nil, err
+		}
+		i += eq + 1
+
+		value, next, sep, err := parseRFC4514Value(dn, i)
+		if err != nil {
+			return nil, err
+		}
+		i = next
+
+		rdn = append(rdn, pkix.AttributeTypeAndValue{Type: oid, Value: value})
+
+		// a plus joins the next attribute to the same RDN
+		if sep != '+' {
+			seq = append(seq, rdn)
+			rdn = pkix.RelativeDistinguishedNameSET{}
+		}
+		if sep != 0 && i == len(dn) {
+			return nil, errors.New("invalid dn")
+		}
+	}
+
+	// the most significant RDN comes first in the RDN sequence
+	for i, j := 0, len(seq)-1; i < j; i, j = i+1, j-1 {
+		seq[i], seq[j] = seq[j], seq[i]
+	}
+
+	return seq, nil
+}
+
+// parseRFC4514Value reads an attribute value that starts at the given position, undoing its escaping.
+// It returns the value, the position after the separator that ends it and the separator itself, or 0 at the end of the dn
+func parseRFC4514Value(dn string, i int) (string, int, byte, error) {
+
+	for i < len(dn) && dn[i] == ' ' {
+		i++
+	}
+
+	if i < len(dn) && dn[i] == '#' {
+		return "", 0, 0, errors.New("invalid dn")
+	}
+
+	value := []byte{}
+	// the length of the value up to its last escaped character, spaces after it are not significant
+	significant := 0
+	quoted := i < len(dn) && dn[i] == '"'
+	if quoted {
+		i++
+	}
+
+	for i < len(dn) {
+		c := dn[i]
+		switch {
+		case c == '\\':
+			if i+1 >= len(dn) {
+				return "", 0, 0, errors.New("invalid dn")
+			}
+			if i+2 < len(dn) && isHex(dn[i+1]) && isHex(dn[i+2]) {
+				b, _ := hex.DecodeString(dn[i+1 : i+3])
+				value = append(value, b...)
+				i += 3
+			} else {
+				value = append(value, dn[i+1])
+				i += 2
+			}
+			significant = len(value)
+			continue
+		case quoted && c == '"':
+			quoted = false
+			significant = len(value)
+			i++
+			continue
+		case quoted:
+		case c == ',' || c == ';' || c == '+':
+			return string(value[:significant]), i + 1, c, nil
+		case c == '"':
+			return "", 0, 0, errors.New("invalid dn")
+		}
+		value = append(value, c)
+		if c != ' ' {
+			significant = len(value)
+		}
+		i++
+	}
+
+	if quoted {
+		return "", 0, 0, errors.New("invalid dn")
+	}
+
+	return string(value[:significant]), i, 0, nil
+}
+
+// parseOpenSSLDN parses a DN in the slash separated openssl form, which lists the most significant RDN first.
+// A backslash escapes the character that follows it, while a plus joins attributes into a multi-valued RDN
+func parseOpenSSLDN(dn string) (pkix.RDNSequence, error) {
+
+	seq := pkix.RDNSequence{}
+
+	for _, rdnString := range splitUnescaped(strings.TrimPrefix(dn, "/"), '/') {
+
+		rdn := pkix.RelativeDistinguishedNameSET{}
+
+		for _, attr := range splitOpenSSLRDN(rdnString) {
+			eq := strings.IndexByte(attr, '=')
+			if eq < 0 {
+				return nil, errors.New("invalid dn")
+			}
+			oid, err := parseAttributeType(attr[:eq])
+			if err != nil {
+				return nil, err
+			}
+			rdn = append(rdn, pkix.AttributeTypeAndValue{Type: oid, Value: unescapeOpenSSL(attr[eq+1:])})
+		}
+
+		seq = append(seq, rdn)
+	}
+
+	return seq, nil
+}
+
+// splitOpenSSLRDN splits an RDN of the openssl form on the unescaped pluses that are followed by an attribute type,
+// so that pluses which are part of a value don't break it apart
+func splitOpenSSLRDN(rdn string) []string {
+
+	parts := splitUnescaped(rdn, '+')
+	attrs := []string{parts[0]}
+
+	for _, part := range parts[1:] {
+		eq := strings.IndexByte(part, '=')
+		if eq >= 0 {
+			if _, err := parseAttributeType(part[:eq]); err == nil {
+				attrs = append(attrs, part)
+				continue
+			}
+		}
+		attrs[len(attrs)-1] += "+" + part
+	}
+
+	return attrs
+}
+
+// splitUnescaped splits a string on the separators that are not escaped by a backslash, keeping the escapes
+func splitUnescaped(s string, sep byte) []string {
+
+	parts := []string{}
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// unescapeOpenSSL removes the backslashes that escape characters of an openssl form value
+func unescapeOpenSSL(value string) string {
+
+	b := strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+		}
+		b.WriteByte(value[i])
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// parseAttributeType resolves an attribute type name, alias or dotted object identifier
+func parseAttributeType(name string) (asn1.ObjectIdentifier, error) {
+
+	name = strings.TrimSpace(name)
+
+	oidString, ok := dnAttributeTypes[strings.ToLower(name)]
+	if !ok {
+		oidString = strings.TrimPrefix(strings.TrimPrefix(name, "OID."), "oid.")
+	}
+
+	oid := asn1.ObjectIdentifier{}
+	for _, arc := range strings.Split(oidString, ".") {
+		n, err := strconv.Atoi(arc)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("unknown attribute type %v", name)
+		}
+		oid = append(oid, n)
+	}
+
+	if len(oid) < 2 {
+		return nil, fmt.Errorf("unknown attribute type %v", name)
+	}
+
+	return oid, nil
+}
+
+// canonicalDN formats an RDN sequence in the RFC 4514 form, naming the known attribute types by their short names,
+// the rest by their object identifiers, and ordering the attributes of multi-valued RDNs
+func canonicalDN(seq pkix.RDNSequence) string {
+
+	rdns := []string{}
+
+	// RFC 4514 lists the most significant RDN last
+	for i := len(seq) - 1; i >= 0; i-- {
+
+		attrs := []string{}
+		for _, atv := range seq[i] {
+			name, ok := dnAttributeNames[atv.Type.String()]
+			if !ok {
+				name = atv.Type.String()
+			}
+			attrs = append(attrs, name+"="+escapeDNValue(fmt.Sprint(atv.Value)))
+		}
+		sort.Strings(attrs)
+
+		rdns = append(rdns, strings.Join(attrs, "+"))
+	}
+
+	return strings.Join(rdns, ",")
+}
+
+// escapeDNValue escapes the characters of an attribute value that RFC 4514 requires to be escaped
+func escapeDNValue(value string) string {
+
+	b := strings.Builder{}
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '"' || c == '+' || c == ',' || c == ';' || c == '<' || c == '>' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == 0:
+			b.WriteString("\\00")
+		case (c == ' ' || c == '#') && i == 0:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == ' ' && i == len(value)-1:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+func (suite *AuthTestSuite) TestCertificateDN() {
+	cert := &x509.Certificate{
+		Subject: pkix.Name{
+			Country:      []string{"GR"},
+			Organization: []string{"ARGO"},
+			CommonName:   "John Doe",
+		},
+		Issuer: pkix.Name{
+			Organization: []string{"ARGO"},
+			CommonName:   "ARGO CA",
+		},
+	}
+	suite.Equal("CN=John Doe,O=ARGO,C=GR", CertificateDN(cert))
+	suite.Equal("CN=ARGO CA,O=ARGO", CertificateIssuerDN(cert))
+
+	// the encoded subject keeps the attributes of multi-valued RDNs together
+	raw, _ := asn1.Marshal(pkix.RDNSequence{
+		{{Type: asn1.ObjectIdentifier{2, 5, 4, 6}, Value: "GR"}},
+		{
+			{Type: asn1.ObjectIdentifier{2, 5, 4, 3}, Value: "John Doe"},
+			{Type: asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 1}, Value: "jdoe"},
+		},
+	})
+	cert.RawSubject = raw
+	suite.Equal("CN=John Doe+UID=jdoe,C=GR", CertificateDN(cert))
+}
+
+func (suite *AuthTestSuite) TestNormalizeDN() {
+
+	expected := "CN=John Doe,O=ARGO,C=GR"
+	for _, dn := range []string{
+		"/C=GR/O=ARGO/CN=John Doe",
+		" CN=John Doe,O=ARGO,C=GR ",
+		"CN=John Doe, O=ARGO, C=GR",
+		"commonName=John Doe,organizationName=ARGO,countryName=GR",
+		"2.5.4.3=John Doe,2.5.4.10=ARGO,2.5.4.6=GR",
+		`CN="John Doe",O=ARGO,C=GR`,
+		`CN=John\20Doe,O=ARGO,C=GR`,
+	} {
+		normalized, err := NormalizeDN(dn)
+		suite.Nil(err, dn)
+		suite.Equal(expected, normalized, dn)
+	}
+
+	// escaped separators stay part of their value
+	normalized, err := NormalizeDN(`CN=Doe\, John,O=ARGO/Greece,C=GR`)
+	suite.Nil(err)
+	suite.Equal(`CN=Doe\, John,O=ARGO/Greece,C=GR`, normalized)
+	normalized, err = NormalizeDN(`/C=GR/O=ARGO\/Greece/CN=Doe, John`)
+	suite.Nil(err)
+	suite.Equal(`CN=Doe\, John,O=ARGO/Greece,C=GR`, normalized)
+
+	// the attributes of multi-valued RDNs compare equal in any order
+	normalized, err = NormalizeDN("UID=jdoe+CN=John Doe,C=GR")
+	suite.Nil(err)
+	suite.Equal("CN=John Doe+UID=jdoe,C=GR", normalized)
+	normalized, err = NormalizeDN("/C=GR/CN=John Doe+UID=jdoe")
+	suite.Nil(err)
+	suite.Equal("CN=John Doe+UID=jdoe,C=GR", normalized)
+
+	// a plus that isn't followed by an attribute type is part of the value in the openssl form
+	normalized, err = NormalizeDN("/C=GR/CN=C+Developers")
+	suite.Nil(err)
+	suite.Equal(`CN=C\+Developers,C=GR`, normalized)
+
+	normalized, err = NormalizeDN("")
+	suite.Nil(err)
+	suite.Equal("", normalized)
+
+	for _, dn := range []string{"John Doe", "CN=John Doe,", "XYZ=John Doe", `CN="John Doe`, "CN=#0403", "/C=GR/John Doe"} {
+		_, err := NormalizeDN(dn)
+		suite.NotNil(err, dn)
+	}
+}
+
+func (suite *AuthTestSuite) TestUpdateUserDN() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+
+	// the openssl form is stored in the canonical form, along with the issuer
+	user, err := UpdateUserDN(suite.ctx, "uuid1", "/C=GR/O=ARGO/CN=UserA", "/O=ARGO/CN=ARGO CA", store)
+	suite.Nil(err)
+	suite.Equal("CN=UserA,O=ARGO,C=GR", user.DN)
+	suite.Equal("CN=ARGO CA,O=ARGO", user.IssuerDN)
+
+	roles, name := AuthenticateDN(suite.ctx, "argo_uuid", "CN=UserA,O=ARGO,C=GR", "CN=ARGO CA,O=ARGO", store)
+	suite.Equal("UserA", name)
+	suite.Equal([]string{"consumer", "publisher"}, roles)
+
+	// the same subject under another CA doesn't authenticate the user
+	roles, name = AuthenticateDN(suite.ctx, "argo_uuid", "CN=UserA,O=ARGO,C=GR", "CN=Other CA,O=Other", store)
+	suite.Equal("", name)
+	suite.Empty(roles)
+
+	// a dn can only belong to a single user under the same issuer
+	_, err = UpdateUserDN(suite.ctx, "uuid2", "CN=UserA,O=ARGO,C=GR", "CN=ARGO CA,O=ARGO", store)
+	suite.Equal("exists", err.Error())
+	_, err = UpdateUserDN(suite.ctx, "uuid2", "CN=UserA,O=ARGO,C=GR", "CN=Other CA,O=Other", store)
+	suite.Nil(err)
+
+	_, err = UpdateUserDN(suite.ctx, "uuid1", "CN=UserA,O=ARGO,C=GR", "", store)
+	suite.Equal("empty issuer dn", err.Error())
+	_, err = UpdateUserDN(suite.ctx, "uuid1", "UserA", "CN=ARGO CA,O=ARGO", store)
+	suite.Equal("invalid dn", err.Error())
+	_, err = UpdateUserDN(suite.ctx, "uuid1", "CN=UserA,O=ARGO,C=GR", "ARGO CA", store)
+	suite.Equal("invalid issuer dn", err.Error())
+
+	// removing the dn stops the certificate from authenticating the user
+	user, err = UpdateUserDN(suite.ctx, "uuid1", "", "", store)
+	suite.Nil(err)
+	suite.Equal("", user.DN)
+	suite.Equal("", user.IssuerDN)
+	roles, name = AuthenticateDN(suite.ctx, "argo_uuid", "CN=UserA,O=ARGO,C=GR", "CN=ARGO CA,O=ARGO", store)
+	suite.Equal("", name)
+	suite.Empty(roles)
+}
@@ -18,6 +18,7 @@ type Broker interface {
 	GetMaxOffset(ctx context.Context, topic string) int64
 	Consume(ctx context.Context, topic string, offset int64, imm bool, max int64) ([]string, error)
 	DeleteTopic(ctx context.Context, topic string) error
+	ListTopics(ctx context.Context) ([]string, error)
 	TimeToOffset(ctx context.Context, topic string, time time.Time) (int64, error)
 }
 
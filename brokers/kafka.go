@@ -153,7 +153,7 @@ func (b *KafkaBroker) init(peers []string) error {
 	b.Config.Producer.RequiredAcks = sarama.WaitForAll
 	b.Config.Producer.Retry.Max = 5
 	b.Config.Producer.Return.Successes = true
-	b.Config.Producer.MaxMessageBytes = 1500000
+	b.Config.Producer.MaxMessageBytes = messages.MaxMessageSize
 	b.Config.Version = sarama.V2_1_0_0
 	b.Servers = peers
 
@@ -277,6 +277,26 @@ func (b *KafkaBroker) DeleteTopic(ctx context.Context, topic string) error {
 	return clusterAdmin.DeleteTopic(topic)
 }
 
+// ListTopics returns the names of all the topics present on the Kafka cluster
+func (b *KafkaBroker) ListTopics(ctx context.Context) ([]string, error) {
+
+	// refresh the cached metadata so that recently created or deleted topics are taken into account
+	if err := b.Client.RefreshMetadata(); err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "kafka",
+				"backend_hosts":   b.Servers,
+				"error":           err.Error(),
+				"trace_id":        ctx.Value("trace_id"),
+			},
+		).Errorf("Could not refresh cluster metadata")
+		return []string{}, err
+	}
+
+	return b.Client.Topics()
+}
+
 // Consume function to consume a message from the broker
 func (b *KafkaBroker) Consume(ctx context.Context, topic string, offset int64, imm bool, max int64) ([]string, error) {
 
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/messages"
+	"sort"
 	"strings"
 	"time"
 )
@@ -130,6 +131,18 @@ func (b *MockBroker) DeleteTopic(ctx context.Context, topic string) error {
 	return nil
 }
 
+// ListTopics returns the names of all the topics present on the broker
+func (b *MockBroker) ListTopics(ctx context.Context) ([]string, error) {
+
+	topics := make([]string, 0, len(b.Topics))
+	for topic := range b.Topics {
+		topics = append(topics, topic)
+	}
+
+	sort.Strings(topics)
+	return topics, nil
+}
+
 func (b *MockBroker) TimeToOffset(ctx context.Context, topic string, time time.Time) (int64, error) {
 
 	topicTimeIndices, ok := b.TopicTimeIndices[topic]
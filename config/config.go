@@ -16,6 +16,7 @@ import (
 	"github.com/spf13/viper"
 	"io/ioutil"
 	"log/syslog"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -77,6 +78,53 @@ type APICfg struct {
 	// AuthOption defines how the service will handle authentication/authorization
 	// KEY, HEADER or BOTH are the available values for where the auth token should reside
 	authOption AuthOption
+	// How often (in seconds) the topics of the store and the broker should be reconciled, 0 disables it
+	TopicReconciliationInterval int
+	// Whether or not the scheduled topic reconciliation should only report the orphan topics
+	TopicReconciliationDryRun bool
+	// For how long (in seconds) the idempotency keys of published messages are remembered, 0 disables deduplication
+	IdempotencyWindow int
+	// How often (in seconds) the schema cache catches up with the schema changes of other instances, 0 disables the cache
+	SchemaCacheSyncInterval int
+	// Whether or not the service requests client certificates and authenticates the users they have been assigned to
+	ClientCertAuth bool
+	// The server side secret that the user tokens are hashed with before they get stored
+	TokenPepper string
+	// For how long (in days) the entries of the audit log are kept, 0 keeps them forever
+	AuditRetention int
+	// The addresses or CIDR ranges of the proxies that are trusted to report the client address in X-Forwarded-For
+	TrustedProxies []*net.IPNet
+	// Whether or not the rate limits of projects and users are enforced
+	RateLimiting bool
+	// The issuer of the OIDC bearer tokens that the service accepts, an empty value disables bearer token authentication
+	OIDCIssuer string
+	// The audience that the OIDC bearer tokens should have been issued for, required when an issuer has been configured
+	OIDCAudience string
+	// Local file path or http(s) url of the JWKS that holds the keys which sign the OIDC bearer tokens
+	OIDCJWKS string
+	// The claim of the bearer token that identifies the AMS user, email matches the user's email, any other claim the user's name
+	OIDCUserClaim string
+	// The claim of the bearer token that lists the groups of the user
+	OIDCGroupsClaim string
+	// Maps groups of the bearer tokens to roles in projects
+	OIDCGroupRoles []OIDCGroupRole
+	// The host of the SMTP server that notifications are sent through, an empty value disables notifications
+	SMTPHost string
+	// The port of the SMTP server
+	SMTPPort int
+	// The username that the service authenticates with to the SMTP server, an empty value skips authentication
+	SMTPUsername string
+	// The password that the service authenticates with to the SMTP server
+	SMTPPassword string
+	// The address that notifications are sent from
+	SMTPFrom string
+}
+
+// OIDCGroupRole grants the members of an OIDC group a set of roles in a project
+type OIDCGroupRole struct {
+	Group   string   `json:"group" mapstructure:"group"`
+	Project string   `json:"project" mapstructure:"project"`
+	Roles   []string `json:"roles" mapstructure:"roles"`
 }
 
 // NewAPICfg creates a new kafka configuration object
@@ -501,6 +549,233 @@ func (cfg *APICfg) LoadTest() {
 			"type": "service_log",
 		},
 	).Info("Parameter Loaded - push_worker_token")
+
+	// topic reconciliation interval
+	cfg.TopicReconciliationInterval = viper.GetInt("topic_reconciliation_interval")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_reconciliation_interval: %v", cfg.TopicReconciliationInterval)
+
+	// topic reconciliation dry run
+	cfg.TopicReconciliationDryRun = viper.GetBool("topic_reconciliation_dry_run")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_reconciliation_dry_run: %v", cfg.TopicReconciliationDryRun)
+
+	// idempotency window
+	cfg.IdempotencyWindow = viper.GetInt("idempotency_window")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - idempotency_window: %v", cfg.IdempotencyWindow)
+
+	// schema cache sync interval
+	cfg.SchemaCacheSyncInterval = viper.GetInt("schema_cache_sync_interval")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - schema_cache_sync_interval: %v", cfg.SchemaCacheSyncInterval)
+
+	// client certificate authentication
+	cfg.ClientCertAuth = viper.GetBool("client_cert_auth")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - client_cert_auth: %v", cfg.ClientCertAuth)
+
+	// user token hashing secret
+	cfg.TokenPepper = viper.GetString("token_pepper")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Info("Parameter Loaded - token_pepper")
+
+	// audit log retention
+	cfg.AuditRetention = viper.GetInt("audit_retention")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - audit_retention: %v", cfg.AuditRetention)
+
+	// proxies trusted to report the client address
+	cfg.loadTrustedProxies()
+
+	// rate limiting
+	cfg.RateLimiting = viper.GetBool("rate_limiting")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - rate_limiting: %v", cfg.RateLimiting)
+
+	// oidc bearer token authentication
+	cfg.loadOIDC()
+
+	// smtp server of the notifications
+	cfg.loadSMTP()
+}
+
+// loadTrustedProxies loads the proxies that are trusted to report the client address,
+// either as single addresses or as CIDR ranges
+func (cfg *APICfg) loadTrustedProxies() {
+
+	cfg.TrustedProxies = []*net.IPNet{}
+
+	for _, proxy := range viper.GetStringSlice("trusted_proxies") {
+
+		proxy = strings.TrimSpace(proxy)
+
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				log.WithFields(
+					log.Fields{
+						"type": "service_log",
+					},
+				).Fatalf("Invalid trusted_proxies entry: %v", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			cfg.TrustedProxies = append(cfg.TrustedProxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"type": "service_log",
+				},
+			).Fatalf("Invalid trusted_proxies entry: %v", proxy)
+		}
+		cfg.TrustedProxies = append(cfg.TrustedProxies, ipNet)
+	}
+
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - trusted_proxies: %v", cfg.TrustedProxies)
+}
+
+// loadOIDC loads the parameters of the OIDC bearer token authentication
+func (cfg *APICfg) loadOIDC() {
+
+	cfg.OIDCIssuer = viper.GetString("oidc_issuer")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - oidc_issuer: %v", cfg.OIDCIssuer)
+
+	cfg.OIDCAudience = viper.GetString("oidc_audience")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - oidc_audience: %v", cfg.OIDCAudience)
+
+	if cfg.OIDCIssuer != "" && cfg.OIDCAudience == "" {
+		log.WithFields(
+			log.Fields{
+				"type": "service_log",
+			},
+		).Fatal("oidc_audience is required when oidc_issuer is set")
+	}
+
+	cfg.OIDCJWKS = viper.GetString("oidc_jwks")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - oidc_jwks: %v", cfg.OIDCJWKS)
+
+	cfg.OIDCUserClaim = viper.GetString("oidc_user_claim")
+	if cfg.OIDCUserClaim == "" {
+		cfg.OIDCUserClaim = "sub"
+	}
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - oidc_user_claim: %v", cfg.OIDCUserClaim)
+
+	cfg.OIDCGroupsClaim = viper.GetString("oidc_groups_claim")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - oidc_groups_claim: %v", cfg.OIDCGroupsClaim)
+
+	cfg.OIDCGroupRoles = []OIDCGroupRole{}
+	err := viper.UnmarshalKey("oidc_group_roles", &cfg.OIDCGroupRoles)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "service_log",
+				"error": err.Error(),
+			},
+		).Fatal("Invalid oidc_group_roles")
+	}
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - oidc_group_roles: %v", cfg.OIDCGroupRoles)
+}
+
+// loadSMTP loads the parameters of the SMTP server that notifications are sent through
+func (cfg *APICfg) loadSMTP() {
+
+	cfg.SMTPHost = viper.GetString("smtp_host")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - smtp_host: %v", cfg.SMTPHost)
+
+	cfg.SMTPPort = viper.GetInt("smtp_port")
+	if cfg.SMTPPort == 0 {
+		cfg.SMTPPort = 25
+	}
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - smtp_port: %v", cfg.SMTPPort)
+
+	cfg.SMTPUsername = viper.GetString("smtp_username")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - smtp_username: %v", cfg.SMTPUsername)
+
+	cfg.SMTPPassword = viper.GetString("smtp_password")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Info("Parameter Loaded - smtp_password")
+
+	cfg.SMTPFrom = viper.GetString("smtp_from")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - smtp_from: %v", cfg.SMTPFrom)
 }
 
 // Load the configuration
@@ -573,6 +848,63 @@ func (cfg *APICfg) Load() {
 		pflag.String("auth-option", "", "where the auth token should reside")
 		viper.BindPFlag("auth_option", pflag.Lookup("auth-option"))
 
+		pflag.Int("topic-reconciliation-interval", 0, "interval in seconds between topic reconciliations, 0 disables them")
+		viper.BindPFlag("topic_reconciliation_interval", pflag.Lookup("topic-reconciliation-interval"))
+
+		pflag.Bool("topic-reconciliation-dry-run", true, "only report orphan topics during scheduled reconciliations")
+		viper.BindPFlag("topic_reconciliation_dry_run", pflag.Lookup("topic-reconciliation-dry-run"))
+
+		pflag.Int("idempotency-window", 3600, "seconds during which the idempotency keys of published messages are remembered, 0 disables deduplication")
+		viper.BindPFlag("idempotency_window", pflag.Lookup("idempotency-window"))
+
+		pflag.Int("schema-cache-sync-interval", 10, "interval in seconds between syncs of the schema cache with the schema changes of other instances, 0 disables the cache")
+		viper.BindPFlag("schema_cache_sync_interval", pflag.Lookup("schema-cache-sync-interval"))
+
+		pflag.Bool("client-cert-auth", false, "request client certificates and authenticate the users they have been assigned to")
+		viper.BindPFlag("client_cert_auth", pflag.Lookup("client-cert-auth"))
+
+		pflag.String("token-pepper", "", "server side secret that the user tokens are hashed with before they get stored")
+		viper.BindPFlag("token_pepper", pflag.Lookup("token-pepper"))
+
+		pflag.Int("audit-retention", 365, "days during which the entries of the audit log are kept, 0 keeps them forever")
+		viper.BindPFlag("audit_retention", pflag.Lookup("audit-retention"))
+
+		pflag.StringSlice("trusted-proxies", []string{}, "addresses or CIDR ranges of the proxies that are trusted to report the client address in X-Forwarded-For")
+		viper.BindPFlag("trusted_proxies", pflag.Lookup("trusted-proxies"))
+
+		pflag.Bool("rate-limiting", false, "enforce the rate limits of projects and users")
+		viper.BindPFlag("rate_limiting", pflag.Lookup("rate-limiting"))
+
+		pflag.String("oidc-issuer", "", "issuer of the accepted OIDC bearer tokens, empty disables bearer token authentication")
+		viper.BindPFlag("oidc_issuer", pflag.Lookup("oidc-issuer"))
+
+		pflag.String("oidc-audience", "", "audience that the accepted OIDC bearer tokens should have")
+		viper.BindPFlag("oidc_audience", pflag.Lookup("oidc-audience"))
+
+		pflag.String("oidc-jwks", "", "file path or url of the JWKS that verifies the OIDC bearer tokens")
+		viper.BindPFlag("oidc_jwks", pflag.Lookup("oidc-jwks"))
+
+		pflag.String("oidc-user-claim", "sub", "claim of the OIDC bearer tokens that identifies the user, either sub or email")
+		viper.BindPFlag("oidc_user_claim", pflag.Lookup("oidc-user-claim"))
+
+		pflag.String("oidc-groups-claim", "", "claim of the OIDC bearer tokens that lists the groups of the user")
+		viper.BindPFlag("oidc_groups_claim", pflag.Lookup("oidc-groups-claim"))
+
+		pflag.String("smtp-host", "", "host of the smtp server that notifications are sent through, empty disables notifications")
+		viper.BindPFlag("smtp_host", pflag.Lookup("smtp-host"))
+
+		pflag.Int("smtp-port", 25, "port of the smtp server")
+		viper.BindPFlag("smtp_port", pflag.Lookup("smtp-port"))
+
+		pflag.String("smtp-username", "", "username to authenticate with to the smtp server, empty skips authentication")
+		viper.BindPFlag("smtp_username", pflag.Lookup("smtp-username"))
+
+		pflag.String("smtp-password", "", "password to authenticate with to the smtp server")
+		viper.BindPFlag("smtp_password", pflag.Lookup("smtp-password"))
+
+		pflag.String("smtp-from", "", "address that notifications are sent from")
+		viper.BindPFlag("smtp_from", pflag.Lookup("smtp-from"))
+
 		configPath = pflag.String("config-dir", "", "directory path to an alternative json config file")
 
 		pflag.Parse()
@@ -762,6 +1094,79 @@ func (cfg *APICfg) Load() {
 		},
 	).Info("Parameter Loaded - push_worker_token")
 
+	// topic reconciliation interval
+	cfg.TopicReconciliationInterval = viper.GetInt("topic_reconciliation_interval")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_reconciliation_interval: %v", cfg.TopicReconciliationInterval)
+
+	// topic reconciliation dry run
+	cfg.TopicReconciliationDryRun = viper.GetBool("topic_reconciliation_dry_run")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_reconciliation_dry_run: %v", cfg.TopicReconciliationDryRun)
+
+	// idempotency window
+	cfg.IdempotencyWindow = viper.GetInt("idempotency_window")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - idempotency_window: %v", cfg.IdempotencyWindow)
+
+	// schema cache sync interval
+	cfg.SchemaCacheSyncInterval = viper.GetInt("schema_cache_sync_interval")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - schema_cache_sync_interval: %v", cfg.SchemaCacheSyncInterval)
+
+	// client certificate authentication
+	cfg.ClientCertAuth = viper.GetBool("client_cert_auth")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - client_cert_auth: %v", cfg.ClientCertAuth)
+
+	// user token hashing secret
+	cfg.TokenPepper = viper.GetString("token_pepper")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Info("Parameter Loaded - token_pepper")
+
+	// audit log retention
+	cfg.AuditRetention = viper.GetInt("audit_retention")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - audit_retention: %v", cfg.AuditRetention)
+
+	// proxies trusted to report the client address
+	cfg.loadTrustedProxies()
+
+	// rate limiting
+	cfg.RateLimiting = viper.GetBool("rate_limiting")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - rate_limiting: %v", cfg.RateLimiting)
+
+	// oidc bearer token authentication
+	cfg.loadOIDC()
+
+	// smtp server of the notifications
+	cfg.loadSMTP()
+
 }
 
 // LoadStrJSON Loads configuration from a JSON string
@@ -913,6 +1318,79 @@ func (cfg *APICfg) LoadStrJSON(input string) {
 		},
 	).Info("Parameter Loaded - push_worker_token")
 
+	// topic reconciliation interval
+	cfg.TopicReconciliationInterval = viper.GetInt("topic_reconciliation_interval")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_reconciliation_interval: %v", cfg.TopicReconciliationInterval)
+
+	// topic reconciliation dry run
+	cfg.TopicReconciliationDryRun = viper.GetBool("topic_reconciliation_dry_run")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_reconciliation_dry_run: %v", cfg.TopicReconciliationDryRun)
+
+	// idempotency window
+	cfg.IdempotencyWindow = viper.GetInt("idempotency_window")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - idempotency_window: %v", cfg.IdempotencyWindow)
+
+	// schema cache sync interval
+	cfg.SchemaCacheSyncInterval = viper.GetInt("schema_cache_sync_interval")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - schema_cache_sync_interval: %v", cfg.SchemaCacheSyncInterval)
+
+	// client certificate authentication
+	cfg.ClientCertAuth = viper.GetBool("client_cert_auth")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - client_cert_auth: %v", cfg.ClientCertAuth)
+
+	// user token hashing secret
+	cfg.TokenPepper = viper.GetString("token_pepper")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Info("Parameter Loaded - token_pepper")
+
+	// audit log retention
+	cfg.AuditRetention = viper.GetInt("audit_retention")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - audit_retention: %v", cfg.AuditRetention)
+
+	// proxies trusted to report the client address
+	cfg.loadTrustedProxies()
+
+	// rate limiting
+	cfg.RateLimiting = viper.GetBool("rate_limiting")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - rate_limiting: %v", cfg.RateLimiting)
+
+	// oidc bearer token authentication
+	cfg.loadOIDC()
+
+	// smtp server of the notifications
+	cfg.loadSMTP()
+
 	cfg.LogFacilities = viper.GetStringSlice("log_facilities")
 	log.WithFields(
 		log.Fields{
@@ -34,7 +34,27 @@ func (suite *ConfigTestSuite) SetupTest() {
         "push_worker_token": "pw-token",
 		"log_facilities": ["SYSLOG", "CONSOLE"],
         "auth_option": "header",
- 		"proxy_hostname": "lb.ams.gr"
+ 		"proxy_hostname": "lb.ams.gr",
+		"topic_reconciliation_interval": 3600,
+		"topic_reconciliation_dry_run": true,
+		"idempotency_window": 600,
+		"client_cert_auth": true,
+		"token_pepper": "s3cr3t-pepper",
+		"audit_retention": 30,
+		"trusted_proxies": ["10.0.0.1", "192.168.0.0/16"],
+		"rate_limiting": true,
+		"oidc_issuer": "https://aai.example.org",
+		"oidc_audience": "ams",
+		"oidc_jwks": "/etc/argo-messaging/jwks.json",
+		"oidc_user_claim": "email",
+		"oidc_groups_claim": "groups",
+		"oidc_group_roles": [{"group": "ams-admins", "project": "ARGO", "roles": ["project_admin"]}],
+		"smtp_host": "smtp.example.org",
+		"smtp_port": 587,
+		"smtp_username": "ams",
+		"smtp_password": "s3cr3t",
+		"smtp_from": "ams@example.org",
+		"schema_cache_sync_interval": 30
 	}`
 }
 
@@ -68,6 +88,20 @@ func (suite *ConfigTestSuite) TestLoadConfiguration() {
 	suite.True(APIcfg2.VerifyPushServer)
 	suite.Equal(0, len(APIcfg2.LogFacilities))
 	suite.Equal("lb.ams.gr", APIcfg2.ProxyHostname)
+	suite.Equal(0, APIcfg2.TopicReconciliationInterval)
+	suite.True(APIcfg2.TopicReconciliationDryRun)
+	suite.Equal(3600, APIcfg2.IdempotencyWindow)
+	suite.Equal(10, APIcfg2.SchemaCacheSyncInterval)
+	suite.False(APIcfg2.ClientCertAuth)
+	suite.Equal("", APIcfg2.TokenPepper)
+	suite.Equal(365, APIcfg2.AuditRetention)
+	suite.Empty(APIcfg2.TrustedProxies)
+	suite.False(APIcfg2.RateLimiting)
+	suite.Equal("", APIcfg2.OIDCIssuer)
+	suite.Equal("sub", APIcfg2.OIDCUserClaim)
+	suite.Empty(APIcfg2.OIDCGroupRoles)
+	suite.Equal("", APIcfg2.SMTPHost)
+	suite.Equal(25, APIcfg2.SMTPPort)
 }
 
 func (suite *ConfigTestSuite) TestLoadStringJSON() {
@@ -90,6 +124,28 @@ func (suite *ConfigTestSuite) TestLoadStringJSON() {
 	suite.Equal("pw-token", APIcfg.PushWorkerToken)
 	suite.Equal([]string{"SYSLOG", "CONSOLE"}, APIcfg.LogFacilities)
 	suite.Equal(HeaderKey, int(APIcfg.AuthOption()))
+	suite.Equal(3600, APIcfg.TopicReconciliationInterval)
+	suite.True(APIcfg.TopicReconciliationDryRun)
+	suite.Equal(600, APIcfg.IdempotencyWindow)
+	suite.Equal(30, APIcfg.SchemaCacheSyncInterval)
+	suite.True(APIcfg.ClientCertAuth)
+	suite.Equal("s3cr3t-pepper", APIcfg.TokenPepper)
+	suite.Equal(30, APIcfg.AuditRetention)
+	suite.Equal(2, len(APIcfg.TrustedProxies))
+	suite.Equal("10.0.0.1/32", APIcfg.TrustedProxies[0].String())
+	suite.Equal("192.168.0.0/16", APIcfg.TrustedProxies[1].String())
+	suite.True(APIcfg.RateLimiting)
+	suite.Equal("https://aai.example.org", APIcfg.OIDCIssuer)
+	suite.Equal("ams", APIcfg.OIDCAudience)
+	suite.Equal("/etc/argo-messaging/jwks.json", APIcfg.OIDCJWKS)
+	suite.Equal("email", APIcfg.OIDCUserClaim)
+	suite.Equal("groups", APIcfg.OIDCGroupsClaim)
+	suite.Equal([]OIDCGroupRole{{Group: "ams-admins", Project: "ARGO", Roles: []string{"project_admin"}}}, APIcfg.OIDCGroupRoles)
+	suite.Equal("smtp.example.org", APIcfg.SMTPHost)
+	suite.Equal(587, APIcfg.SMTPPort)
+	suite.Equal("ams", APIcfg.SMTPUsername)
+	suite.Equal("s3cr3t", APIcfg.SMTPPassword)
+	suite.Equal("ams@example.org", APIcfg.SMTPFrom)
 }
 
 func (suite *ConfigTestSuite) TestSetAuthOption() {
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/audit"
+	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/projects"
+	"github.com/ARGOeu/argo-messaging/stores"
+	gorillaContext "github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// auditResources maps the url variables that name the resource of an audited route to the type of the resource,
+// in the order that they are checked
+var auditResources = []struct {
+	urlVar       string
+	resourceType string
+}{
+	{"subscription", "subscriptions"},
+	{"topic", "topics"},
+	{"schema", "schemas"},
+	{"subject", "subjects"},
+	{"group", "groups"},
+	{"role", "roles"},
+	{"template", "templates"},
+	{"user", "users"},
+	{"uuid", "registrations"},
+	{"project", "projects"},
+}
+
+// auditResponseWriter keeps the status code of the response of an audited route
+type auditResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (aw *auditResponseWriter) WriteHeader(statusCode int) {
+	aw.statusCode = statusCode
+	aw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// WrapAudit handle wrapper to record the route in the audit log, along with the changes that it made to its resource
+func WrapAudit(hfn http.Handler, routeName string) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceId := gorillaContext.Get(r, "trace_id").(string)
+		rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+		urlVars := mux.Vars(r)
+
+		refStr := gorillaContext.Get(r, "str").(stores.Store)
+		retention, _ := gorillaContext.Get(r, "audit_retention").(int)
+
+		resourceType, resource := "", ""
+		for _, res := range auditResources {
+			if name, found := urlVars[res.urlVar]; found {
+				resourceType, resource = res.resourceType, name
+				break
+			}
+		}
+
+		// project creation and deletion changes the uuid that the project name resolves to
+		projectUUID, _ := gorillaContext.Get(r, "auth_project_uuid").(string)
+		if projectUUID == "" && urlVars["project"] != "" {
+			projectUUID = projects.GetUUIDByName(rCTX, urlVars["project"], refStr)
+		}
+
+		before := audit.Snapshot(rCTX, resourceType, projectUUID, resource, routeName, refStr)
+
+		aw := &auditResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		hfn.ServeHTTP(aw, r)
+
+		if projectUUID == "" && urlVars["project"] != "" {
+			projectUUID = projects.GetUUIDByName(rCTX, urlVars["project"], refStr)
+		}
+
+		after := audit.Snapshot(rCTX, resourceType, projectUUID, resource, routeName, refStr)
+
+		actorUUID, _ := gorillaContext.Get(r, "auth_user_uuid").(string)
+		trustedProxies, _ := gorillaContext.Get(r, "trusted_proxies").([]*net.IPNet)
+
+		entry := stores.QAuditEntry{
+			TraceID:      traceId,
+			ActorUUID:    actorUUID,
+			RouteName:    routeName,
+			ProjectUUID:  projectUUID,
+			ResourceType: resourceType,
+			Resource:     resource,
+			Changes:      audit.Diff(before, after),
+			StatusCode:   aw.statusCode,
+			SourceIP:     sourceIP(r, trustedProxies),
+			CreatedOn:    time.Now().UTC(),
+		}
+
+		if err := audit.Record(rCTX, entry, retention, refStr); err != nil {
+			log.WithFields(
+				log.Fields{
+					"trace_id": traceId,
+					"type":     "service_log",
+					"action":   routeName,
+					"error":    err.Error(),
+				},
+			).Error("Could not record the request in the audit log")
+		}
+	})
+}
+
+// sourceIP returns the address of the client of a request. X-Forwarded-For is only taken into account when the
+// request comes from a trusted proxy, in which case the right-most address that isn't a trusted proxy is the client
+func sourceIP(r *http.Request, trustedProxies []*net.IPNet) string {
+
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+
+	if !isTrustedProxy(remote, trustedProxies) {
+		return remote
+	}
+
+	forwarded := []string{}
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+
+	client := remote
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(addr) == nil {
+			break
+		}
+		client = addr
+		if !isTrustedProxy(addr, trustedProxies) {
+			break
+		}
+	}
+
+	return client
+}
+
+// isTrustedProxy checks whether an address belongs to one of the trusted proxies
+func isTrustedProxy(addr string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range trustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditListAll (GET) lists the entries of the audit log of the service, optionally filtered
+func AuditListAll(w http.ResponseWriter, r *http.Request) {
+	auditList(w, r, false)
+}
+
+// AuditListByProject (GET) lists the entries of the audit log that concern a specific project, optionally filtered
+func AuditListByProject(w http.ResponseWriter, r *http.Request) {
+	auditList(w, r, true)
+}
+
+// auditList lists the entries of the audit log that pass the filters of the request,
+// restricted to the project of the request when projectScoped is set
+func auditList(w http.ResponseWriter, r *http.Request, projectScoped bool) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	var err error
+	var pageSize int
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Grab url query parameters
+	urlValues := r.URL.Query()
+	pageToken := urlValues.Get("pageToken")
+	strPageSize := urlValues.Get("pageSize")
+
+	filter := stores.QAuditFilter{
+		RouteName:    urlValues.Get("route"),
+		ResourceType: urlValues.Get("resource_type"),
+		Resource:     urlValues.Get("resource"),
+	}
+
+	if projectScoped {
+		filter.ProjectUUID = gorillaContext.Get(r, "auth_project_uuid").(string)
+	} else if projectName := urlValues.Get("project"); projectName != "" {
+		filter.ProjectUUID = projects.GetUUIDByName(rCTX, projectName, refStr)
+		if filter.ProjectUUID == "" {
+			err := APIErrorNotFound("ProjectUUID")
+			respondErr(rCTX, w, err)
+			return
+		}
+	}
+
+	if actor := urlValues.Get("actor"); actor != "" {
+		filter.ActorUUID = auth.GetUUIDByName(rCTX, actor, refStr)
+		if filter.ActorUUID == "" {
+			err := APIErrorNotFound("User")
+			respondErr(rCTX, w, err)
+			return
+		}
+	}
+
+	zuluForm := "2006-01-02T15:04:05Z"
+
+	if from := urlValues.Get("from"); from != "" {
+		if filter.From, err = time.Parse(zuluForm, from); err != nil {
+			err := APIErrorInvalidData("From should be in the format of YYYY-MM-DDTHH:mm:ssZ")
+			respondErr(rCTX, w, err)
+			return
+		}
+	}
+
+	if to := urlValues.Get("to"); to != "" {
+		if filter.To, err = time.Parse(zuluForm, to); err != nil {
+			err := APIErrorInvalidData("To should be in the format of YYYY-MM-DDTHH:mm:ssZ")
+			respondErr(rCTX, w, err)
+			return
+		}
+	}
+
+	if strPageSize != "" {
+		if pageSize, err = strconv.Atoi(strPageSize); err != nil {
+			log.WithFields(
+				log.Fields{
+					"trace_id":  rCTX.Value("trace_id"),
+					"type":      "request_log",
+					"page_size": pageSize,
+					"error":     err.Error(),
+				},
+			).Error("error while converting page size to int")
+			err := APIErrorInvalidData("Invalid page size")
+			respondErr(rCTX, w, err)
+			return
+		}
+	}
+
+	res, err := audit.Find(rCTX, filter, pageToken, int64(pageSize), refStr)
+	if err != nil {
+		err := APIErrorInvalidData("Invalid page token")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/config"
+	oldPush "github.com/ARGOeu/argo-messaging/push"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuditHandlersTestSuite struct {
+	suite.Suite
+	cfgStr string
+}
+
+func (suite *AuditHandlersTestSuite) SetupTest() {
+	suite.cfgStr = `{
+	"bind_ip":"",
+	"port":8080,
+	"zookeeper_hosts":["localhost"],
+	"kafka_znode":"",
+	"store_host":"localhost",
+	"store_db":"argo_msg",
+	"certificate":"/etc/pki/tls/certs/localhost.crt",
+	"certificate_key":"/etc/pki/tls/private/localhost.key",
+	"per_resource_auth":"true",
+	"audit_retention":7
+	}`
+}
+
+func (suite *AuditHandlersTestSuite) router(str stores.Store) *mux.Router {
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/groups/{group}",
+		WrapMockAuthConfig(WrapAudit(http.HandlerFunc(GroupCreate), "groups:create"), cfgKafka, &brk, str, &mgr, nil)).Methods("POST")
+	router.HandleFunc("/v1/projects/{project}/groups/{group}",
+		WrapMockAuthConfig(WrapAudit(http.HandlerFunc(GroupDelete), "groups:delete"), cfgKafka, &brk, str, &mgr, nil)).Methods("DELETE")
+	router.HandleFunc("/v1/projects/{project}/audit",
+		WrapMockAuthConfig(AuditListByProject, cfgKafka, &brk, str, &mgr, nil, "project_admin")).Methods("GET")
+	router.HandleFunc("/v1/audit",
+		WrapMockAuthConfig(AuditListAll, cfgKafka, &brk, str, &mgr, nil, "service_admin")).Methods("GET")
+	return router
+}
+
+func (suite *AuditHandlersTestSuite) TestWrapAudit() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := suite.router(str)
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/groups/team",
+		bytes.NewBuffer([]byte(`{"members": ["UserA"]}`)))
+	// the forwarded address of a client that isn't a trusted proxy is ignored
+	req.Header.Set("X-Forwarded-For", "10.0.0.9, 10.0.0.2")
+	req.RemoteAddr = "10.0.0.1:4567"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	suite.Equal(1, len(str.AuditEntries))
+	entry := str.AuditEntries[0]
+	suite.NotEqual("", entry.TraceID)
+	suite.Equal("uuid1", entry.ActorUUID)
+	suite.Equal("groups:create", entry.RouteName)
+	suite.Equal("argo_uuid", entry.ProjectUUID)
+	suite.Equal("groups", entry.ResourceType)
+	suite.Equal("team", entry.Resource)
+	suite.Equal(200, entry.StatusCode)
+	suite.Equal("10.0.0.1", entry.SourceIP)
+	suite.Equal(entry.CreatedOn.Add(7*24*time.Hour), entry.ExpiresOn)
+	suite.Equal(3, len(entry.Changes))
+	suite.Equal(stores.QAuditChange{Field: "members", Before: "null", After: `["UserA"]`}, entry.Changes[1])
+	suite.Equal(stores.QAuditChange{Field: "name", Before: "null", After: `"team"`}, entry.Changes[2])
+
+	// failed requests are recorded without changes
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/groups/team",
+		bytes.NewBuffer([]byte(`{"members": []}`)))
+	req.RemoteAddr = "10.0.0.3:4567"
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(409, w.Code)
+
+	entry = str.AuditEntries[1]
+	suite.Equal(409, entry.StatusCode)
+	suite.Equal("10.0.0.3", entry.SourceIP)
+	suite.Equal([]stores.QAuditChange{}, entry.Changes)
+
+	req, _ = http.NewRequest("DELETE", "http://localhost:8080/v1/projects/ARGO/groups/team", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	entry = str.AuditEntries[2]
+	suite.Equal("groups:delete", entry.RouteName)
+	suite.Equal(stores.QAuditChange{Field: "members", Before: `["UserA"]`, After: "null"}, entry.Changes[1])
+}
+
+func (suite *AuditHandlersTestSuite) TestSourceIP() {
+
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/24")
+	trusted := []*net.IPNet{proxies}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/audit", nil)
+	req.RemoteAddr = "192.168.0.1:4567"
+	suite.Equal("192.168.0.1", sourceIP(req, trusted))
+
+	// a client that isn't a trusted proxy can't forge its address
+	req.Header.Set("X-Forwarded-For", "172.16.0.1")
+	suite.Equal("192.168.0.1", sourceIP(req, trusted))
+	suite.Equal("192.168.0.1", sourceIP(req, nil))
+
+	// the right-most address that isn't a trusted proxy is the client
+	req.RemoteAddr = "10.0.0.1:4567"
+	req.Header.Set("X-Forwarded-For", "172.16.0.1, 192.168.0.1, 10.0.0.2")
+	suite.Equal("192.168.0.1", sourceIP(req, trusted))
+	req.Header.Add("X-Forwarded-For", "10.0.0.3")
+	suite.Equal("192.168.0.1", sourceIP(req, trusted))
+
+	// invalid forwarded addresses stop the search
+	req.Header.Set("X-Forwarded-For", "192.168.0.1, unknown, 10.0.0.2")
+	suite.Equal("10.0.0.2", sourceIP(req, trusted))
+
+	req.Header.Del("X-Forwarded-For")
+	suite.Equal("10.0.0.1", sourceIP(req, trusted))
+}
+
+func (suite *AuditHandlersTestSuite) TestAuditList() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	str.InsertAuditEntry(context.Background(), stores.QAuditEntry{TraceID: "trace1", ActorUUID: "uuid1", RouteName: "topics:create",
+		ProjectUUID: "argo_uuid", ResourceType: "topics", Resource: "topic4", StatusCode: 200, SourceIP: "10.0.0.1",
+		CreatedOn: created})
+	str.InsertAuditEntry(context.Background(), stores.QAuditEntry{TraceID: "trace2", ActorUUID: "uuid0", RouteName: "users:create",
+		ResourceType: "users", Resource: "UserZ", StatusCode: 200, SourceIP: "10.0.0.1",
+		CreatedOn: created.Add(time.Hour)})
+	str.InsertAuditEntry(context.Background(), stores.QAuditEntry{TraceID: "trace3", ActorUUID: "uuid1", RouteName: "topics:delete",
+		ProjectUUID: "argo_uuid2", ResourceType: "topics", Resource: "topic4", StatusCode: 200, SourceIP: "10.0.0.1",
+		CreatedOn: created.Add(2 * time.Hour)})
+	router := suite.router(str)
+
+	expJSON := `{
+   "audit_entries": [
+      {
+         "timestamp": "2024-01-01T00:00:00Z",
+         "trace_id": "trace1",
+         "actor": "UserA",
+         "actor_uuid": "uuid1",
+         "route_name": "topics:create",
+         "project": "ARGO",
+         "resource_type": "topics",
+         "resource": "topic4",
+         "status_code": 200,
+         "source_ip": "10.0.0.1"
+      }
+   ],
+   "nextPageToken": "",
+   "totalSize": 1
+}`
+
+	// project admins only see the entries of their project
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/audit", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expJSON, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/audit?actor=UserA&resource=topic4&to=2024-01-01T01:00:00Z", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expJSON, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/audit?pageSize=2", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"nextPageToken": "1"`)
+	suite.Contains(w.Body.String(), `"totalSize": 3`)
+
+	tests := []struct {
+		url     string
+		code    int
+		message string
+	}{
+		{"http://localhost:8080/v1/audit?project=unknown", 404, "ProjectUUID doesn't exist"},
+		{"http://localhost:8080/v1/audit?actor=unknown", 404, "User doesn't exist"},
+		{"http://localhost:8080/v1/audit?from=2024-01-01", 400, "From should be in the format of YYYY-MM-DDTHH:mm:ssZ"},
+		{"http://localhost:8080/v1/audit?pageSize=two", 400, "Invalid page size"},
+		{"http://localhost:8080/v1/audit?pageToken=invalid", 400, "Invalid page token"},
+	}
+
+	for _, t := range tests {
+		req, _ = http.NewRequest("GET", t.url, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.code, w.Code, t.url)
+		suite.Contains(w.Body.String(), t.message, t.url)
+	}
+}
+
+func TestAuditHandlersTestSuite(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(AuditHandlersTestSuite))
+}
@@ -143,6 +143,34 @@ var APIErrorTimeout = func(msg string) APIErrorRoot {
 	}
 }
 
+// APIErrorTooManyRequests to be used when a request goes over the rate limits of its project or user
+var APIErrorTooManyRequests = func() APIErrorRoot {
+
+	apiErrBody := APIErrorBody{
+		Code:    http.StatusTooManyRequests,
+		Message: "Rate limit exceeded, please retry later",
+		Status:  "RESOURCE_EXHAUSTED",
+	}
+
+	return APIErrorRoot{
+		Body: apiErrBody,
+	}
+}
+
+// APIErrorQuotaExceeded to be used when a request goes over one of the quotas of its project
+var APIErrorQuotaExceeded = func(quota string) APIErrorRoot {
+
+	apiErrBody := APIErrorBody{
+		Code:    http.StatusTooManyRequests,
+		Message: fmt.Sprintf("Project quota %v exceeded", quota),
+		Status:  "RESOURCE_EXHAUSTED",
+	}
+
+	return APIErrorRoot{
+		Body: apiErrBody,
+	}
+}
+
 // APIErrorConflict for dealing with already existing resources
 var APIErrorConflict = func(resource string) APIErrorRoot {
 
@@ -213,6 +241,34 @@ var APIErrTooLargeMessage = func(resource string) APIErrorRoot {
 	}
 }
 
+// APIErrorTooLargeRequest to be used when the body of a request is larger than its rate limits allow in a second
+var APIErrorTooLargeRequest = func() APIErrorRoot {
+
+	apiErrBody := APIErrorBody{
+		Code:    http.StatusRequestEntityTooLarge,
+		Message: "Request body is larger than the bytes per second of the rate limits",
+		Status:  "INVALID_ARGUMENT",
+	}
+
+	return APIErrorRoot{
+		Body: apiErrBody,
+	}
+}
+
+// APIErrorUnsupportedMediaType to be used when the body of a request has a media type that is not supported
+var APIErrorUnsupportedMediaType = func(msg string) APIErrorRoot {
+
+	apiErrBody := APIErrorBody{
+		Code:    http.StatusUnsupportedMediaType,
+		Message: msg,
+		Status:  "INVALID_ARGUMENT",
+	}
+
+	return APIErrorRoot{
+		Body: apiErrBody,
+	}
+}
+
 // APIErrGenericInternal for dealing with generic internal errors
 var APIErrGenericInternal = func(msg string) APIErrorRoot {
 
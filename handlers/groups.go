@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/stores"
+	gorillaContext "github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"github.com/twinj/uuid"
+)
+
+// GroupListAll (GET) lists all the groups of a project
+func GroupListAll(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refProjUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	res, err := auth.FindGroups(rCTX, refProjUUID, "", refStr)
+	if err != nil {
+		err := APIErrQueryDatastore()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// GroupListOne (GET) lists a specific group of a project along with its members
+func GroupListOne(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlGroup := urlVars["group"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refProjUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	res, err := auth.FindGroups(rCTX, refProjUUID, urlGroup, refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Group")
+			respondErr(rCTX, w, err)
+			return
+		}
+		err := APIErrQueryDatastore()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	group := res.List[0]
+	resJSON, err := group.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// GroupCreate (POST) creates a new group of users in a project
+func GroupCreate(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlGroup := urlVars["group"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refProjUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	postBody, err := auth.GetGroupFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Group")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// check if the members are valid users of the given project
+	_, err = auth.AreValidUsers(rCTX, refProjUUID, postBody.Members, refStr)
+	if err != nil {
+		err := APIErrorRoot{Body: APIErrorBody{Code: http.StatusNotFound, Message: err.Error(), Status: "NOT_FOUND"}}
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	groupUUID := uuid.NewV4().String() // generate a new uuid to attach to the new group
+	created := time.Now().UTC()
+
+	res, err := auth.CreateGroup(rCTX, refProjUUID, groupUUID, urlGroup, postBody.Members, created, refStr)
+	if err != nil {
+		if err.Error() == "exists" {
+			err := APIErrorConflict("Group")
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// GroupAddMembers (POST) adds users to the members of a group
+func GroupAddMembers(w http.ResponseWriter, r *http.Request) {
+	groupModMembers(w, r, auth.AddGroupMembers)
+}
+
+// GroupRemoveMembers (POST) removes users from the members of a group
+func GroupRemoveMembers(w http.ResponseWriter, r *http.Request) {
+	groupModMembers(w, r, auth.RemoveGroupMembers)
+}
+
+// groupModMembers applies a change to the members of a group, as described in the request body
+func groupModMembers(w http.ResponseWriter, r *http.Request,
+	modify func(ctx context.Context, projectUUID string, name string, members []string, store stores.Store) (auth.Group, error)) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlGroup := urlVars["group"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refProjUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	postBody, err := auth.GetGroupFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Group")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// check if the members are valid users of the given project
+	_, err = auth.AreValidUsers(rCTX, refProjUUID, postBody.Members, refStr)
+	if err != nil {
+		err := APIErrorRoot{Body: APIErrorBody{Code: http.StatusNotFound, Message: err.Error(), Status: "NOT_FOUND"}}
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	res, err := modify(rCTX, refProjUUID, urlGroup, postBody.Members, refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Group")
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// GroupDelete (DELETE) removes a group from a project
+func GroupDelete(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlGroup := urlVars["group"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refProjUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	err := auth.DeleteGroup(rCTX, refProjUUID, urlGroup, refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Group")
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write empty response if everything's ok
+	respondOK(w, output)
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/config"
+	oldPush "github.com/ARGOeu/argo-messaging/push"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+)
+
+type GroupsHandlersTestSuite struct {
+	suite.Suite
+	cfgStr string
+}
+
+func (suite *GroupsHandlersTestSuite) SetupTest() {
+	suite.cfgStr = `{
+	"bind_ip":"",
+	"port":8080,
+	"zookeeper_hosts":["localhost"],
+	"kafka_znode":"",
+	"store_host":"localhost",
+	"store_db":"argo_msg",
+	"certificate":"/etc/pki/tls/certs/localhost.crt",
+	"certificate_key":"/etc/pki/tls/private/localhost.key",
+	"per_resource_auth":"true"
+	}`
+}
+
+func (suite *GroupsHandlersTestSuite) router(str stores.Store) *mux.Router {
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/groups/{group}:addMembers", WrapMockAuthConfig(GroupAddMembers, cfgKafka, &brk, str, &mgr, nil)).Methods("POST")
+	router.HandleFunc("/v1/projects/{project}/groups/{group}:removeMembers", WrapMockAuthConfig(GroupRemoveMembers, cfgKafka, &brk, str, &mgr, nil)).Methods("POST")
+	router.HandleFunc("/v1/projects/{project}/groups/{group}", WrapMockAuthConfig(GroupListOne, cfgKafka, &brk, str, &mgr, nil)).Methods("GET")
+	router.HandleFunc("/v1/projects/{project}/groups/{group}", WrapMockAuthConfig(GroupCreate, cfgKafka, &brk, str, &mgr, nil)).Methods("POST")
+	router.HandleFunc("/v1/projects/{project}/groups/{group}", WrapMockAuthConfig(GroupDelete, cfgKafka, &brk, str, &mgr, nil)).Methods("DELETE")
+	router.HandleFunc("/v1/projects/{project}/groups", WrapMockAuthConfig(GroupListAll, cfgKafka, &brk, str, &mgr, nil)).Methods("GET")
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:modAcl", WrapMockAuthConfig(TopicModACL, cfgKafka, &brk, str, &mgr, nil)).Methods("POST")
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:acl", WrapMockAuthConfig(TopicACL, cfgKafka, &brk, str, &mgr, nil)).Methods("GET")
+	return router
+}
+
+func (suite *GroupsHandlersTestSuite) TestGroupCreate() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := suite.router(str)
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/groups/team",
+		bytes.NewBuffer([]byte(`{"members": ["UserA", "UserB"]}`)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	group, _ := auth.GetGroupFromJSON(w.Body.Bytes())
+	suite.Equal("team", group.Name)
+	suite.Equal([]string{"UserA", "UserB"}, group.Members)
+
+	qGroups, _ := str.QueryGroups(context.Background(), "argo_uuid", "", "team")
+	suite.Equal([]string{"uuid1", "uuid2"}, qGroups[0].Members)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/groups/team",
+		bytes.NewBuffer([]byte(`{"members": []}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(409, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 409,
+      "message": "Group already exists",
+      "status": "ALREADY_EXISTS"
+   }
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/groups/other",
+		bytes.NewBuffer([]byte(`{"members": ["UserA", "unknown"]}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 404,
+      "message": "User(s): unknown do not exist",
+      "status": "NOT_FOUND"
+   }
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/groups/other",
+		bytes.NewBuffer([]byte(`{}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+}
+
+func (suite *GroupsHandlersTestSuite) TestGroupList() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	auth.CreateGroup(context.Background(), "argo_uuid", "group_uuid", "team", []string{"UserA"}, created, str)
+	router := suite.router(str)
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/groups", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "groups": [
+      {
+         "name": "team",
+         "members": [
+            "UserA"
+         ],
+         "created_on": "2024-01-01T00:00:00Z"
+      }
+   ]
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/groups/team", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "name": "team",
+   "members": [
+      "UserA"
+   ],
+   "created_on": "2024-01-01T00:00:00Z"
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/groups/unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 404,
+      "message": "Group doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`, w.Body.String())
+}
+
+func (suite *GroupsHandlersTestSuite) TestGroupMembers() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	auth.CreateGroup(context.Background(), "argo_uuid", "group_uuid", "team", []string{"UserA"}, created, str)
+	router := suite.router(str)
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/groups/team:addMembers",
+		bytes.NewBuffer([]byte(`{"members": ["UserB", "UserA"]}`)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	group, _ := auth.GetGroupFromJSON(w.Body.Bytes())
+	suite.Equal([]string{"UserA", "UserB"}, group.Members)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/groups/team:removeMembers",
+		bytes.NewBuffer([]byte(`{"members": ["UserA"]}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	group, _ = auth.GetGroupFromJSON(w.Body.Bytes())
+	suite.Equal([]string{"UserB"}, group.Members)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/groups/unknown:addMembers",
+		bytes.NewBuffer([]byte(`{"members": ["UserA"]}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+
+	req, _ = http.NewRequest("DELETE", "http://localhost:8080/v1/projects/ARGO/groups/team", bytes.NewBuffer([]byte("")))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("", w.Body.String())
+
+	req, _ = http.NewRequest("DELETE", "http://localhost:8080/v1/projects/ARGO/groups/team", bytes.NewBuffer([]byte("")))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+}
+
+func (suite *GroupsHandlersTestSuite) TestTopicACLWithGroups() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	auth.CreateGroup(context.Background(), "argo_uuid", "group_uuid", "team", []string{"UserA", "UserB"}, created, str)
+	router := suite.router(str)
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic3:modAcl",
+		bytes.NewBuffer([]byte(`{"authorized_users": ["UserX"], "authorized_groups": ["team"]}`)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Nil(str.ExistsInACL(context.Background(), "argo_uuid", "topics", "topic3", "uuid2", ""))
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic3:acl", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "authorized_users": [
+      "UserX"
+   ],
+   "authorized_groups": [
+      "team"
+   ],
+   "group_members": {
+      "team": [
+         "UserA",
+         "UserB"
+      ]
+   }
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic3:modAcl",
+		bytes.NewBuffer([]byte(`{"authorized_groups": ["unknown"]}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 404,
+      "message": "Group(s): unknown do not exist",
+      "status": "NOT_FOUND"
+   }
+}`, w.Body.String())
+}
+
+func TestGroupsHandlersTestSuite(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(GroupsHandlersTestSuite))
+}
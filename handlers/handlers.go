@@ -7,6 +7,7 @@ import (
 	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/notifications"
 	"github.com/ARGOeu/argo-messaging/projects"
 	oldPush "github.com/ARGOeu/argo-messaging/push"
 	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
@@ -19,6 +20,7 @@ import (
 	"github.com/twinj/uuid"
 	"net/http"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -81,6 +83,11 @@ func WrapMockAuthConfig(hfn http.HandlerFunc, cfg *config.APICfg, brk brokers.Br
 		gorillaContext.Set(r, "auth_roles", userRoles)
 		gorillaContext.Set(r, "push_worker_token", cfg.PushWorkerToken)
 		gorillaContext.Set(r, "push_enabled", cfg.PushEnabled)
+		gorillaContext.Set(r, "idempotency_window", cfg.IdempotencyWindow)
+		gorillaContext.Set(r, "audit_retention", cfg.AuditRetention)
+		gorillaContext.Set(r, "trusted_proxies", cfg.TrustedProxies)
+		gorillaContext.Set(r, "rate_limiting", cfg.RateLimiting)
+		gorillaContext.Set(r, "notifier", notifierFromConfig(cfg))
 		hfn.ServeHTTP(w, r)
 
 	})
@@ -102,11 +109,24 @@ func WrapConfig(hfn http.HandlerFunc, cfg *config.APICfg, brk brokers.Broker, st
 		gorillaContext.Set(r, "auth_service_token", cfg.ServiceToken)
 		gorillaContext.Set(r, "push_worker_token", cfg.PushWorkerToken)
 		gorillaContext.Set(r, "push_enabled", cfg.PushEnabled)
+		gorillaContext.Set(r, "idempotency_window", cfg.IdempotencyWindow)
+		gorillaContext.Set(r, "audit_retention", cfg.AuditRetention)
+		gorillaContext.Set(r, "trusted_proxies", cfg.TrustedProxies)
+		gorillaContext.Set(r, "rate_limiting", cfg.RateLimiting)
+		gorillaContext.Set(r, "notifier", notifierFromConfig(cfg))
 		hfn.ServeHTTP(w, r)
 
 	})
 }
 
+// notifierFromConfig returns the sender of the notifications, nil when no SMTP server has been configured
+func notifierFromConfig(cfg *config.APICfg) notifications.Sender {
+	if cfg.SMTPHost == "" {
+		return nil
+	}
+	return notifications.NewSMTPSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+}
+
 // WrapLog handle wrapper to apply Logging
 func WrapLog(hfn http.Handler, name string) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -135,13 +155,16 @@ func WrapLog(hfn http.Handler, name string) http.HandlerFunc {
 				"requester":       gorillaContext.Get(r, "auth_user_uuid"),
 				"processing_time": time.Since(start).String(),
 				"trace_id":        gorillaContext.Get(r, "trace_id"),
+				"auth_method":     gorillaContext.Get(r, "auth_method"),
 			},
 		).Info("")
 	})
 }
 
-// WrapAuthenticate handle wrapper to apply authentication
-func WrapAuthenticate(hfn http.Handler, extractToken RequestTokenExtractStrategy) http.HandlerFunc {
+// WrapAuthenticate handle wrapper to apply authentication.
+// A verified client certificate is tried first and the request falls back to its api key,
+// or to its OIDC bearer token when oidcAuth is not nil
+func WrapAuthenticate(hfn http.Handler, extractToken RequestTokenExtractStrategy, oidcAuth *auth.OIDCAuthenticator) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		traceId := gorillaContext.Get(r, "trace_id").(string)
 		rCTX := context.WithValue(context.Background(), "trace_id", traceId)
@@ -150,8 +173,15 @@ func WrapAuthenticate(hfn http.Handler, extractToken RequestTokenExtractStrategy
 
 		apiKey := extractToken(r)
 
-		// if the url parameter 'key' is empty or absent, end the request with an unauthorized response
-		if apiKey == "" {
+		bearerToken := ""
+		if oidcAuth != nil {
+			bearerToken = BearerTokenExtract(r)
+		}
+
+		certDN, certIssuerDN := ClientCertificateDN(r)
+
+		// if there is no credential at all, end the request with an unauthorized response
+		if apiKey == "" && bearerToken == "" && certDN == "" {
 			err := APIErrorUnauthorized()
 			respondErr(rCTX, w, err)
 			return
@@ -179,11 +209,38 @@ func WrapAuthenticate(hfn http.Handler, extractToken RequestTokenExtractStrategy
 			gorillaContext.Set(r, "auth_user", "")
 			gorillaContext.Set(r, "auth_user_uuid", "")
 			gorillaContext.Set(r, "auth_project_uuid", projectUUID)
+			gorillaContext.Set(r, "auth_method", AuthMethodToken)
 			hfn.ServeHTTP(w, r)
 			return
 		}
 
-		roles, user := auth.Authenticate(rCTX, projectUUID, apiKey, refStr)
+		roles := []string{}
+		user := ""
+		method := ""
+
+		if certDN != "" {
+			roles, user = auth.AuthenticateDN(rCTX, projectUUID, certDN, certIssuerDN, refStr)
+			method = AuthMethodX509
+		}
+
+		// the api key takes precedence over the bearer token
+		if len(roles) == 0 && apiKey != "" {
+			roles, user = auth.Authenticate(rCTX, projectUUID, apiKey, refStr)
+			method = AuthMethodToken
+		} else if len(roles) == 0 && bearerToken != "" {
+			var err error
+			roles, user, err = oidcAuth.Authenticate(rCTX, projectUUID, bearerToken, refStr)
+			method = AuthMethodOIDC
+			if err != nil {
+				log.WithFields(
+					log.Fields{
+						"trace_id": traceId,
+						"type":     "service_log",
+						"error":    err.Error(),
+					},
+				).Info("Bearer token rejected")
+			}
+		}
 
 		if len(roles) > 0 {
 			userUUID := auth.GetUUIDByName(rCTX, user, refStr)
@@ -191,6 +248,7 @@ func WrapAuthenticate(hfn http.Handler, extractToken RequestTokenExtractStrategy
 			gorillaContext.Set(r, "auth_user", user)
 			gorillaContext.Set(r, "auth_user_uuid", userUUID)
 			gorillaContext.Set(r, "auth_project_uuid", projectUUID)
+			gorillaContext.Set(r, "auth_method", method)
 			hfn.ServeHTTP(w, r)
 		} else {
 			err := APIErrorUnauthorized()
@@ -226,6 +284,50 @@ func WrapAuthorize(hfn http.Handler, routeName string, extractToken RequestToken
 	})
 }
 
+// WrapResourceAuthorize handle wrapper to apply the acl of the topic or subscription that the route acts upon.
+// Users whose roles are restricted by acls need to be authorized for the route's action on the resource
+func WrapResourceAuthorize(hfn http.Handler, resourceType string, action string) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceId := gorillaContext.Get(r, "trace_id").(string)
+		rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+		urlVars := mux.Vars(r)
+
+		refStr := gorillaContext.Get(r, "str").(stores.Store)
+		refRoles := gorillaContext.Get(r, "auth_roles").([]string)
+		refAuthResource := gorillaContext.Get(r, "auth_resource").(bool)
+
+		if !refAuthResource || !auth.RequiresResourceACL(refRoles) {
+			hfn.ServeHTTP(w, r)
+			return
+		}
+
+		refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+		projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+		resourceName := urlVars["topic"]
+		if resourceType == "subscriptions" {
+			resourceName = urlVars["subscription"]
+		}
+
+		if !auth.PerResource(rCTX, projectUUID, resourceType, resourceName, refUserUUID, action, refStr) {
+			err := APIErrorForbidden()
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		hfn.ServeHTTP(w, r)
+	})
+}
+
+// WrapRoutes handle wrapper to provide the names of the routes that roles can grant access to
+func WrapRoutes(hfn http.HandlerFunc, routes []string) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gorillaContext.Set(r, "routes", routes)
+		hfn.ServeHTTP(w, r)
+	})
+}
+
 // HealthCheck returns an ok message to make sure the service is up and running
 func HealthCheck(w http.ResponseWriter, r *http.Request) {
 	traceId := gorillaContext.Get(r, "trace_id").(string)
@@ -377,6 +479,13 @@ func respondErr(ctx context.Context, w http.ResponseWriter, apiErr APIErrorRoot)
 	w.Write(output)
 }
 
+// The methods that a request can authenticate with, as recorded in the request log
+const (
+	AuthMethodToken = "token"
+	AuthMethodOIDC  = "oidc"
+	AuthMethodX509  = "x509"
+)
+
 // RequestTokenExtractStrategy is a function type that refers to all the functions
 // that can extract an api access token from the request
 type RequestTokenExtractStrategy func(r *http.Request) string
@@ -406,6 +515,44 @@ func HeaderUrlKeyExtract(r *http.Request) string {
 	return key
 }
 
+// ClientCertificateDN returns the subject and the issuer of the client certificate that the tls handshake verified,
+// or empty strings when the client did not present one
+func ClientCertificateDN(r *http.Request) (string, string) {
+
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", ""
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+	return auth.CertificateDN(cert), auth.CertificateIssuerDN(cert)
+}
+
+// BearerTokenExtract extracts an OIDC bearer token from the Authorization header
+func BearerTokenExtract(r *http.Request) string {
+
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+
+	return ""
+}
+
+// BasicAuthKeyExtract extends a token extraction strategy with the password of http basic authentication,
+// which is how schema registry clients provide their credentials
+func BasicAuthKeyExtract(extractToken RequestTokenExtractStrategy) RequestTokenExtractStrategy {
+	return func(r *http.Request) string {
+
+		// the username is ignored, the password holds the api access token
+		_, password, ok := r.BasicAuth()
+		if ok && password != "" {
+			return password
+		}
+
+		return extractToken(r)
+	}
+}
+
 // GetRequestTokenExtractStrategy determines which api token extraction strategy
 // should take place based on the provided argument
 func GetRequestTokenExtractStrategy(authOpt config.AuthOption) RequestTokenExtractStrategy {
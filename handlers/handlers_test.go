@@ -1,21 +1,34 @@
 package handlers
 
 import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/version"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
 	"time"
 
+	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
 	oldPush "github.com/ARGOeu/argo-messaging/push"
 	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
 	"github.com/ARGOeu/argo-messaging/stores"
+	gorillaContext "github.com/gorilla/context"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/suite"
 )
@@ -217,6 +230,210 @@ func (suite *HandlerTestSuite) TestGetRequestTokenExtractStrategy() {
 	suite.Equal("tok3n-url", bothStrategy2(r3))
 }
 
+func (suite *HandlerTestSuite) TestWrapAuthenticateBearerToken() {
+
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+		}},
+	})
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(jwks)
+	}))
+	defer jwksServer.Close()
+
+	sign := func(claims map[string]interface{}) string {
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "key-1"})
+		payload, _ := json.Marshal(claims)
+		input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+		digest := sha256.Sum256([]byte(input))
+		sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.OIDCIssuer = "https://aai.example.org"
+	cfgKafka.OIDCAudience = "ams"
+	cfgKafka.OIDCJWKS = jwksServer.URL
+	cfgKafka.OIDCGroupsClaim = "groups"
+	cfgKafka.OIDCGroupRoles = []config.OIDCGroupRole{{Group: "ams-admins", Project: "ARGO", Roles: []string{"project_admin"}}}
+
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.UpdateUserOIDCSubject(context.Background(), "uuid1", "aai-subject-1")
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+
+	// echoes the identity that the authentication resolved
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%v %v", gorillaContext.Get(r, "auth_user"), gorillaContext.Get(r, "auth_roles"))
+	})
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics",
+		WrapConfig(WrapAuthenticate(echo, HeaderUrlKeyExtract, auth.NewOIDCAuthenticator(cfgKafka)), cfgKafka, &brk, str, &mgr, pc)).
+		Name("topics:list")
+
+	claims := map[string]interface{}{
+		"iss":    "https://aai.example.org",
+		"aud":    "ams",
+		"sub":    "aai-subject-1",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"groups": []string{"ams-admins"},
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics", nil)
+	req.Header.Set("Authorization", "Bearer "+sign(claims))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("UserA [consumer publisher project_admin]", w.Body.String())
+
+	// api keys still work and take precedence over the bearer token
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics", nil)
+	req.Header.Set("Authorization", "Bearer "+sign(claims))
+	req.Header.Set("x-api-key", "S3CR3T")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("Test [consumer publisher]", w.Body.String())
+
+	// a token for another audience
+	claims["aud"] = "other"
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics", nil)
+	req.Header.Set("Authorization", "Bearer "+sign(claims))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(401, w.Code)
+
+	// bearer tokens are ignored when oidc is disabled
+	router = mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics",
+		WrapConfig(WrapAuthenticate(echo, HeaderUrlKeyExtract, nil), cfgKafka, &brk, str, &mgr, pc)).
+		Name("topics:list")
+	claims["aud"] = "ams"
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics", nil)
+	req.Header.Set("Authorization", "Bearer "+sign(claims))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(401, w.Code)
+}
+
+func (suite *HandlerTestSuite) TestWrapAuthenticateClientCertificate() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.UserList[1].DN = "CN=UserA,O=ARGO,C=GR"
+	str.UserList[1].IssuerDN = "CN=ARGO CA,O=ARGO,C=GR"
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+
+	// echoes the identity that the authentication resolved
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%v %v", gorillaContext.Get(r, "auth_user"), gorillaContext.Get(r, "auth_method"))
+	})
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics",
+		WrapConfig(WrapAuthenticate(echo, HeaderUrlKeyExtract, nil), cfgKafka, &brk, str, &mgr, pc)).
+		Name("topics:list")
+
+	withCert := func(req *http.Request, cn string, issuerCN string) {
+		cert := &x509.Certificate{
+			Subject: pkix.Name{Country: []string{"GR"}, Organization: []string{"ARGO"}, CommonName: cn},
+			Issuer:  pkix.Name{Country: []string{"GR"}, Organization: []string{"ARGO"}, CommonName: issuerCN},
+		}
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics", nil)
+	withCert(req, "UserA", "ARGO CA")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("UserA x509", w.Body.String())
+
+	// the same subject issued by another CA doesn't authenticate the user
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics", nil)
+	withCert(req, "UserA", "Other CA")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(401, w.Code)
+
+	// a certificate that belongs to no user falls back to the api key
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics", nil)
+	withCert(req, "Unknown", "ARGO CA")
+	req.Header.Set("x-api-key", "S3CR3T2")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("UserB token", w.Body.String())
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics", nil)
+	withCert(req, "Unknown", "ARGO CA")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(401, w.Code)
+}
+
+func (suite *HandlerTestSuite) TestWrapResourceAuthorize() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.TopicsACL["topic2"] = stores.QAcl{
+		ACL:     []string{"uuid1", "uuid2"},
+		Actions: map[string][]string{"uuid1": {"publish"}},
+	}
+	mgr := oldPush.Manager{}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondOK(w, []byte("ok"))
+	})
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:metrics",
+		WrapMockAuthConfig(WrapResourceAuthorize(ok, "topics", "metrics"), cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish",
+		WrapMockAuthConfig(WrapResourceAuthorize(ok, "topics", "publish"), cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull",
+		WrapMockAuthConfig(WrapResourceAuthorize(ok, "subscriptions", "pull"), cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/admin/projects/{project}/topics/{topic}:metrics",
+		WrapMockAuthConfig(WrapResourceAuthorize(ok, "topics", "metrics"), cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	tests := []struct {
+		url  string
+		code int
+	}{
+		// a flat acl entry allows every action
+		{"http://localhost:8080/v1/projects/ARGO/topics/topic1:metrics", 200},
+		// users that are not part of the acl are denied
+		{"http://localhost:8080/v1/projects/ARGO/topics/topic3:metrics", 403},
+		// restricted entries are only allowed their actions
+		{"http://localhost:8080/v1/projects/ARGO/topics/topic2:publish", 200},
+		{"http://localhost:8080/v1/projects/ARGO/topics/topic2:metrics", 403},
+		{"http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", 200},
+		{"http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:pull", 403},
+		// project admins are not restricted by acls
+		{"http://localhost:8080/v1/admin/projects/ARGO/topics/topic3:metrics", 200},
+	}
+
+	for _, t := range tests {
+		req, _ := http.NewRequest("GET", t.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.code, w.Code, t.url)
+	}
+}
+
 func (suite *HandlerTestSuite) TestListVersion() {
 
 	req, err := http.NewRequest("GET", "http://localhost:8080/v1/version", nil)
@@ -245,7 +462,7 @@ func (suite *HandlerTestSuite) TestListVersion() {
 	cfgKafka.PushWorkerToken = "missing"
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
-	str.UserList = append(str.UserList, stores.QUser{8, "uuid8", nil, "UserZ", "", "", "", "", "st", "foo-email", []string{"service_admin"}, time.Now(), time.Now(), ""})
+	str.UserList = append(str.UserList, stores.QUser{9, "uuid9", nil, "UserZ", "", "", "", "", "st", "foo-email", []string{"service_admin"}, time.Now(), time.Now(), "", "", "", ""})
 
 	router := mux.NewRouter().StrictSlash(true)
 	mgr := oldPush.Manager{}
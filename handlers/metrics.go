@@ -388,27 +388,11 @@ func TopicMetrics(w http.ResponseWriter, r *http.Request) {
 
 	// Grab context references
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
-	refRoles := gorillaContext.Get(r, "auth_roles").([]string)
-	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
-	refAuthResource := gorillaContext.Get(r, "auth_resource").(bool)
 
 	urlTopic := urlVars["topic"]
 
 	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
 
-	// Check Authorization per topic
-	// - if enabled in config
-	// - if user has only publisher role
-
-	if refAuthResource && auth.IsPublisher(refRoles) {
-
-		if auth.PerResource(rCTX, projectUUID, "topics", urlTopic, refUserUUID, refStr) == false {
-			err := APIErrorForbidden()
-			respondErr(rCTX, w, err)
-			return
-		}
-	}
-
 	// Number of bytes and number of messages
 	resultsMsg, err := topics.FindMetric(rCTX, projectUUID, urlTopic, refStr)
 
@@ -453,8 +437,9 @@ func TopicMetrics(w http.ResponseWriter, r *http.Request) {
 	m3 := metrics.NewTopicBytes(urlTopic, numBytes, metrics.GetTimeNowZulu())
 	m4 := metrics.NewDailyTopicMsgCount(urlTopic, timePoints)
 	m5 := metrics.NewTopicRate(urlTopic, resultsMsg.PublishRate, resultsMsg.LatestPublish.UTC().Format("2006-01-02T15:04:05Z"))
+	m6 := metrics.NewTopicStoredBytes(urlTopic, resultsMsg.StoredBytes, metrics.GetTimeNowZulu())
 
-	res.Metrics = append(res.Metrics, m2, m3, m4, m5)
+	res.Metrics = append(res.Metrics, m2, m3, m4, m5, m6)
 
 	// Output result to JSON
 	resJSON, err := res.ExportJSON()
@@ -487,27 +472,11 @@ func SubMetrics(w http.ResponseWriter, r *http.Request) {
 
 	// Grab context references
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
-	refRoles := gorillaContext.Get(r, "auth_roles").([]string)
-	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
-	refAuthResource := gorillaContext.Get(r, "auth_resource").(bool)
 
 	urlSub := urlVars["subscription"]
 
 	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
 
-	// Check Authorization per topic
-	// - if enabled in config
-	// - if user has only publisher role
-
-	if refAuthResource && auth.IsConsumer(refRoles) {
-
-		if auth.PerResource(rCTX, projectUUID, "subscriptions", urlSub, refUserUUID, refStr) == false {
-			err := APIErrorForbidden()
-			respondErr(rCTX, w, err)
-			return
-		}
-	}
-
 	resultMsg, err := subscriptions.FindMetric(rCTX, projectUUID, urlSub, refStr)
 
 	if err != nil {
@@ -528,7 +497,17 @@ func SubMetrics(w http.ResponseWriter, r *http.Request) {
 	m2 := metrics.NewSubBytes(urlSub, numBytes, metrics.GetTimeNowZulu())
 	m3 := metrics.NewSubRate(urlSub, resultMsg.ConsumeRate, resultMsg.LatestConsume.UTC().Format("2006-01-02T15:04:05Z"))
 
-	res.Metrics = append(res.Metrics, m2, m3)
+	scheduledMsgs, err := refStr.CountScheduledMessages(rCTX, projectUUID, urlSub, time.Now().UTC())
+	if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(rCTX, w, err)
+		return
+	}
+	m4 := metrics.NewSubScheduledMsgs(urlSub, scheduledMsgs, metrics.GetTimeNowZulu())
+	m5 := metrics.NewSubExpiredMsgs(urlSub, resultMsg.ExpiredMsgNum, metrics.GetTimeNowZulu())
+	m6 := metrics.NewSubStoredBytes(urlSub, resultMsg.StoredBytes, metrics.GetTimeNowZulu())
+
+	res.Metrics = append(res.Metrics, m2, m3, m4, m5, m6)
 
 	// Output result to JSON
 	resJSON, err := res.ExportJSON()
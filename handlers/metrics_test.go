@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -250,6 +251,48 @@ func (suite *MetricsHandlersTestSuite) TestSubMetrics() {
             }
          ],
          "description": "A rate that displays how many messages were consumed per second between the last two consume events"
+      },
+      {
+         "metric": "subscription.number_of_scheduled_messages",
+         "metric_type": "counter",
+         "value_type": "int64",
+         "resource_type": "subscription",
+         "resource_name": "sub1",
+         "timeseries": [
+            {
+               "timestamp": "{{TS4}}",
+               "value": 1
+            }
+         ],
+         "description": "Counter that displays the number of messages of the specific subscription that are scheduled for later delivery and are not yet due"
+      },
+      {
+         "metric": "subscription.number_of_expired_messages",
+         "metric_type": "counter",
+         "value_type": "int64",
+         "resource_type": "subscription",
+         "resource_name": "sub1",
+         "timeseries": [
+            {
+               "timestamp": "{{TS5}}",
+               "value": 2
+            }
+         ],
+         "description": "Counter that displays the number of messages of the specific subscription that were skipped because they exceeded the topic's message ttl"
+      },
+      {
+         "metric": "subscription.number_of_stored_bytes",
+         "metric_type": "counter",
+         "value_type": "int64",
+         "resource_type": "subscription",
+         "resource_name": "sub1",
+         "timeseries": [
+            {
+               "timestamp": "{{TS6}}",
+               "value": 3
+            }
+         ],
+         "description": "Counter that displays the total size of data (in bytes) consumed from the specific subscription, as it was stored before decompression"
       }
    ]
 }`
@@ -258,6 +301,11 @@ func (suite *MetricsHandlersTestSuite) TestSubMetrics() {
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
+	// one message that is not yet due and one that is due and shouldn't be counted
+	str.InsertScheduledMessage(context.Background(), "argo_uuid", "sub1", 0, time.Now().UTC().Add(time.Hour), "{}")
+	str.InsertScheduledMessage(context.Background(), "argo_uuid", "sub1", 1, time.Now().UTC().Add(-time.Hour), "{}")
+	str.IncrementSubExpiredMsgNum(context.Background(), "argo_uuid", "sub1", 2)
+	str.IncrementSubStoredBytes(context.Background(), "argo_uuid", "sub1", 3)
 	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
@@ -268,8 +316,14 @@ func (suite *MetricsHandlersTestSuite) TestSubMetrics() {
 	metricOut, _ := metrics.GetMetricsFromJSON([]byte(w.Body.String()))
 	ts1 := metricOut.Metrics[0].Timeseries[0].Timestamp
 	ts2 := metricOut.Metrics[1].Timeseries[0].Timestamp
+	ts4 := metricOut.Metrics[3].Timeseries[0].Timestamp
 	expResp = strings.Replace(expResp, "{{TS1}}", ts1, -1)
 	expResp = strings.Replace(expResp, "{{TS2}}", ts2, -1)
+	ts5 := metricOut.Metrics[4].Timeseries[0].Timestamp
+	expResp = strings.Replace(expResp, "{{TS4}}", ts4, -1)
+	expResp = strings.Replace(expResp, "{{TS5}}", ts5, -1)
+	ts6 := metricOut.Metrics[5].Timeseries[0].Timestamp
+	expResp = strings.Replace(expResp, "{{TS6}}", ts6, -1)
 	suite.Equal(expResp, w.Body.String())
 
 }
@@ -661,6 +715,20 @@ func (suite *MetricsHandlersTestSuite) TestTopicMetrics() {
             }
          ],
          "description": "A rate that displays how many messages were published per second between the last two publish events"
+      },
+      {
+         "metric": "topic.number_of_stored_bytes",
+         "metric_type": "counter",
+         "value_type": "int64",
+         "resource_type": "topic",
+         "resource_name": "topic1",
+         "timeseries": [
+            {
+               "timestamp": "{{TIMESTAMP6}}",
+               "value": 0
+            }
+         ],
+         "description": "Counter that displays the total size of data (in bytes) published to the specific topic, as it is stored after compression"
       }
    ]
 }`
@@ -686,6 +754,8 @@ func (suite *MetricsHandlersTestSuite) TestTopicMetrics() {
 	expResp = strings.Replace(expResp, "{{TIMESTAMP3}}", ts3, -1)
 	expResp = strings.Replace(expResp, "{{TIMESTAMP4}}", ts4, -1)
 	expResp = strings.Replace(expResp, "{{TIMESTAMP5}}", ts5, -1)
+	ts6 := metricOut.Metrics[5].Timeseries[0].Timestamp
+	expResp = strings.Replace(expResp, "{{TIMESTAMP6}}", ts6, -1)
 
 	suite.Equal(expResp, w.Body.String())
 
@@ -787,7 +857,7 @@ func (suite *MetricsHandlersTestSuite) TestUserUsageProfile() {
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
-	str.UserList = append(str.UserList, stores.QUser{1, "uuid1", []stores.QProjectRoles{
+	str.UserList = append(str.UserList, stores.QUser{9, "uuid9", []stores.QProjectRoles{
 		{
 			ProjectUUID: "argo_uuid",
 			Roles:       []string{"project_admin"},
@@ -795,7 +865,7 @@ func (suite *MetricsHandlersTestSuite) TestUserUsageProfile() {
 	},
 		"UserA", "FirstA", "LastA", "OrgA",
 		"DescA", "S3CR3T1T", "foo-email", []string{},
-		time.Now(), time.Now(), ""})
+		time.Now(), time.Now(), "", "", "", ""})
 	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/ARGOeu/argo-messaging/notifications"
+	"github.com/ARGOeu/argo-messaging/stores"
+	gorillaContext "github.com/gorilla/context"
+	"github.com/gorilla/mux"
+)
+
+// NotificationTemplatesListAll (GET) lists the templates of the notifications that are sent about user registrations
+func NotificationTemplatesListAll(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	res, err := notifications.FindTemplates(rCTX, "", refStr)
+	if err != nil {
+		err := APIErrQueryDatastore()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// NotificationTemplateListOne (GET) shows a notification template
+func NotificationTemplateListOne(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	name := urlVars["template"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	res, err := notifications.FindTemplates(rCTX, name, refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Notification template")
+			respondErr(rCTX, w, err)
+			return
+		}
+		err := APIErrQueryDatastore()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.List[0].ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// NotificationTemplateUpdate (PUT) replaces the subject and the body of a notification template
+func NotificationTemplateUpdate(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	name := urlVars["template"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	if !notifications.IsTemplate(name) {
+		err := APIErrorNotFound("Notification template")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Read PUT JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	postBody, err := notifications.GetTemplateFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Notification template")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	if err := postBody.Validate(); err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	if err := notifications.UpdateTemplate(rCTX, name, postBody, refStr); err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	res, err := notifications.FindTemplates(rCTX, name, refStr)
+	if err != nil {
+		err := APIErrQueryDatastore()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.List[0].ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// NotificationTemplateReset (DELETE) discards the edits of a notification template, restoring its default
+func NotificationTemplateReset(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	name := urlVars["template"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	if !notifications.IsTemplate(name) {
+		err := APIErrorNotFound("Notification template")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	if err := notifications.ResetTemplate(rCTX, name, refStr); err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write empty response if everything's ok
+	respondOK(w, []byte(""))
+}
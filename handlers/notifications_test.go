@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/config"
+	oldPush "github.com/ARGOeu/argo-messaging/push"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+)
+
+type NotificationsHandlersTestSuite struct {
+	suite.Suite
+	cfgStr string
+}
+
+func (suite *NotificationsHandlersTestSuite) SetupTest() {
+	suite.cfgStr = `{
+	"bind_ip":"",
+	"port":8080,
+	"zookeeper_hosts":["localhost"],
+	"kafka_znode":"",
+	"store_host":"localhost",
+	"store_db":"argo_msg",
+	"certificate":"/etc/pki/tls/certs/localhost.crt",
+	"certificate_key":"/etc/pki/tls/private/localhost.key",
+	"per_resource_auth":"true"
+	}`
+}
+
+func (suite *NotificationsHandlersTestSuite) router(str stores.Store) *mux.Router {
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	mgr := oldPush.Manager{}
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/notifications/templates",
+		WrapMockAuthConfig(NotificationTemplatesListAll, cfgKafka, &brk, str, &mgr, nil, "service_admin")).Methods("GET")
+	router.HandleFunc("/v1/notifications/templates/{template}",
+		WrapMockAuthConfig(NotificationTemplateListOne, cfgKafka, &brk, str, &mgr, nil, "service_admin")).Methods("GET")
+	router.HandleFunc("/v1/notifications/templates/{template}",
+		WrapMockAuthConfig(NotificationTemplateUpdate, cfgKafka, &brk, str, &mgr, nil, "service_admin")).Methods("PUT")
+	router.HandleFunc("/v1/notifications/templates/{template}",
+		WrapMockAuthConfig(NotificationTemplateReset, cfgKafka, &brk, str, &mgr, nil, "service_admin")).Methods("DELETE")
+	return router
+}
+
+func (suite *NotificationsHandlersTestSuite) TestListTemplates() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := suite.router(str)
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/notifications/templates", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"name": "registration_email_confirmation"`)
+	suite.Contains(w.Body.String(), `"name": "registration_pending"`)
+	suite.Contains(w.Body.String(), `"name": "registration_accepted"`)
+	suite.Contains(w.Body.String(), `"name": "registration_declined"`)
+	suite.NotContains(w.Body.String(), `"custom": true`)
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/notifications/templates/unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 404,
+      "message": "Notification template doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`, w.Body.String())
+}
+
+func (suite *NotificationsHandlersTestSuite) TestUpdateAndResetTemplate() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := suite.router(str)
+
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/notifications/templates/registration_declined",
+		bytes.NewBuffer([]byte(`{"subject": "Declined {{.Name}}", "body": "Reason: {{.DeclineComment}}"}`)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "name": "registration_declined",
+   "subject": "Declined {{.Name}}",
+   "body": "Reason: {{.DeclineComment}}",
+   "custom": true
+}`, w.Body.String())
+
+	qTmpls, _ := str.QueryNotificationTemplates(context.Background(), "registration_declined")
+	suite.Equal([]stores.QNotificationTemplate{{Name: "registration_declined", Subject: "Declined {{.Name}}", Body: "Reason: {{.DeclineComment}}"}}, qTmpls)
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/notifications/templates/registration_declined", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"custom": true`)
+
+	tests := []struct {
+		url     string
+		body    string
+		code    int
+		message string
+	}{
+		{"registration_declined", `{"subject": 1}`, 400, "Invalid Notification template Arguments"},
+		{"registration_declined", `{"subject": "s"}`, 400, "empty body"},
+		{"registration_declined", `{"subject": "s", "body": "{{.Name"}`, 400, "invalid body template"},
+		{"unknown", `{"subject": "s", "body": "b"}`, 404, "Notification template doesn't exist"},
+	}
+
+	for _, t := range tests {
+		req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/notifications/templates/"+t.url, bytes.NewBuffer([]byte(t.body)))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.code, w.Code, t.body)
+		suite.Contains(w.Body.String(), t.message, t.body)
+	}
+
+	// resetting the template restores its default
+	req, _ = http.NewRequest("DELETE", "http://localhost:8080/v1/notifications/templates/registration_declined", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(0, len(str.NotificationTmpls))
+
+	req, _ = http.NewRequest("DELETE", "http://localhost:8080/v1/notifications/templates/unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+}
+
+func TestNotificationsHandlersTestSuite(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(NotificationsHandlersTestSuite))
+}
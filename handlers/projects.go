@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/projects"
+	"github.com/ARGOeu/argo-messaging/quotas"
 	"github.com/ARGOeu/argo-messaging/stores"
 	gorillaContext "github.com/gorilla/context"
 	"github.com/gorilla/mux"
@@ -403,6 +404,11 @@ func ProjectUserCreate(w http.ResponseWriter, r *http.Request) {
 
 	postBody.Projects = []auth.ProjectRoles{projectRoles}
 
+	// check that the project has room for one more member
+	if !withinCreateQuota(rCTX, w, refProjUUID, quotas.MaxUsers, refStr) {
+		return
+	}
+
 	uuid := uuid.NewV4().String() // generate a new uuid to attach to the new project
 	token, err := auth.GenToken() // generate a new user token
 	created := time.Now().UTC()
@@ -757,6 +763,11 @@ func ProjectUserAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// check that the project has room for one more member
+	if !withinCreateQuota(rCTX, w, refProjUUID, quotas.MaxUsers, refStr) {
+		return
+	}
+
 	// Get Result Object
 	userUUID := u.One().UUID
 	modified := time.Now().UTC()
@@ -68,7 +68,6 @@ func (suite *ProjectsHandlersTestSuite) TestProjectUserListOne() {
       }
    ],
    "name": "UserZ",
-   "token": "S3CR3T4",
    "email": "foo-email",
    "service_roles": [],
    "created_on": "2009-11-10T23:00:00Z",
@@ -159,7 +158,6 @@ func (suite *ProjectsHandlersTestSuite) TestProjectUserListARGO() {
             }
          ],
          "name": "UserSame2",
-         "token": "S3CR3T42",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -180,7 +178,6 @@ func (suite *ProjectsHandlersTestSuite) TestProjectUserListARGO() {
             }
          ],
          "name": "UserSame1",
-         "token": "S3CR3T41",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -206,7 +203,6 @@ func (suite *ProjectsHandlersTestSuite) TestProjectUserListARGO() {
             }
          ],
          "name": "UserZ",
-         "token": "S3CR3T4",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -231,7 +227,6 @@ func (suite *ProjectsHandlersTestSuite) TestProjectUserListARGO() {
             }
          ],
          "name": "UserX",
-         "token": "S3CR3T3",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -259,7 +254,6 @@ func (suite *ProjectsHandlersTestSuite) TestProjectUserListARGO() {
             }
          ],
          "name": "UserB",
-         "token": "S3CR3T2",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -291,7 +285,6 @@ func (suite *ProjectsHandlersTestSuite) TestProjectUserListARGO() {
          "last_name": "LastA",
          "organization": "OrgA",
          "description": "DescA",
-         "token": "S3CR3T1",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -311,7 +304,6 @@ func (suite *ProjectsHandlersTestSuite) TestProjectUserListARGO() {
             }
          ],
          "name": "Test",
-         "token": "S3CR3T",
          "email": "Test@test.com",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -349,7 +341,6 @@ func (suite *ProjectsHandlersTestSuite) TestProjectUserListARGONoUserDetails() {
       {
          "uuid": "same_uuid",
          "name": "UserSame2",
-         "token": "S3CR3T42",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -720,7 +711,11 @@ func (suite *ProjectsHandlersTestSuite) TestProjectUserCreate() {
 		if t.expectedStatusCode == 200 {
 			u, _ := auth.FindUsers(context.Background(), "argo_uuid", "", t.user, true, str)
 			t.expectedResponse = strings.Replace(t.expectedResponse, "{{UUID}}", u.List[0].UUID, 1)
-			t.expectedResponse = strings.Replace(t.expectedResponse, "{{TOKEN}}", u.List[0].Token, 1)
+			// the token is only shown in the response, the store keeps its hash
+			res, _ := auth.GetUserFromJSON([]byte(w.Body.String()))
+			qUsers, _ := str.QueryUsers(context.Background(), "", u.List[0].UUID, "")
+			suite.Equal(auth.HashToken(res.Token), qUsers[0].Token, t.msg)
+			t.expectedResponse = strings.Replace(t.expectedResponse, "{{TOKEN}}", res.Token, 1)
 			t.expectedResponse = strings.Replace(t.expectedResponse, "{{CON}}", u.List[0].CreatedOn, 1)
 			t.expectedResponse = strings.Replace(t.expectedResponse, "{{MON}}", u.List[0].ModifiedOn, 1)
 		}
@@ -834,7 +829,6 @@ func (suite *ProjectsHandlersTestSuite) TestProjectUserUpdate() {
    "last_name": "LastA",
    "organization": "OrgA",
    "description": "DescA",
-   "token": "{{TOKEN}}",
    "email": "foo-email",
    "service_roles": [],
    "created_on": "{{CON}}",
@@ -939,7 +933,6 @@ func (suite *ProjectsHandlersTestSuite) TestProjectUserUpdate() {
 		if t.expectedStatusCode == 200 {
 			u, _ := auth.FindUsers(context.Background(), "argo_uuid", "", t.user, true, str)
 			t.expectedResponse = strings.Replace(t.expectedResponse, "{{UUID}}", u.List[0].UUID, 1)
-			t.expectedResponse = strings.Replace(t.expectedResponse, "{{TOKEN}}", u.List[0].Token, 1)
 			t.expectedResponse = strings.Replace(t.expectedResponse, "{{CON}}", u.List[0].CreatedOn, 1)
 			t.expectedResponse = strings.Replace(t.expectedResponse, "{{MON}}", u.List[0].ModifiedOn, 1)
 		}
@@ -1123,7 +1116,6 @@ func (suite *ProjectsHandlersTestSuite) TestProjectUserAdd() {
    "last_name": "LastA",
    "organization": "OrgA",
    "description": "DescA",
-   "token": "{{TOKEN}}",
    "email": "foo-email",
    "service_roles": [],
    "created_on": "{{CON}}",
@@ -1173,7 +1165,6 @@ func (suite *ProjectsHandlersTestSuite) TestProjectUserAdd() {
 		if t.expectedStatusCode == 200 {
 			u, _ := auth.FindUsers(context.Background(), "argo_uuid", "", t.user, true, str)
 			t.expectedResponse = strings.Replace(t.expectedResponse, "{{UUID}}", u.List[0].UUID, 1)
-			t.expectedResponse = strings.Replace(t.expectedResponse, "{{TOKEN}}", u.List[0].Token, 1)
 			t.expectedResponse = strings.Replace(t.expectedResponse, "{{CON}}", u.List[0].CreatedOn, 1)
 			t.expectedResponse = strings.Replace(t.expectedResponse, "{{MON}}", u.List[0].ModifiedOn, 1)
 		}
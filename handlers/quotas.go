@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/quotas"
+	"github.com/ARGOeu/argo-messaging/stores"
+	gorillaContext "github.com/gorilla/context"
+)
+
+// withinCreateQuota checks that one more resource fits in the given quota of a project.
+// When it doesn't, or the quota can't be checked, it responds with the respective error and returns false
+func withinCreateQuota(ctx context.Context, w http.ResponseWriter, projectUUID string, quota string, store stores.Store) bool {
+	exceeded, err := quotas.CheckCreate(ctx, projectUUID, quota, store)
+	if err != nil {
+		err := APIErrQueryDatastore()
+		respondErr(ctx, w, err)
+		return false
+	}
+
+	if exceeded != "" {
+		err := APIErrorQuotaExceeded(exceeded)
+		respondErr(ctx, w, err)
+		return false
+	}
+
+	return true
+}
+
+// ProjectQuota (GET) shows the quotas of a project along with its current usage
+func ProjectQuota(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	res, err := quotas.Find(rCTX, projectUUID, time.Now().UTC(), refStr)
+	if err != nil {
+		err := APIErrQueryDatastore()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// ProjectUpdateQuota (PUT) replaces the quotas of a project
+func ProjectUpdateQuota(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	// Read PUT JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	postBody, err := quotas.GetLimitsFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Quota")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	if err := postBody.Validate(); err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	if err := quotas.Update(rCTX, projectUUID, postBody, refStr); err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	res, err := quotas.Find(rCTX, projectUUID, time.Now().UTC(), refStr)
+	if err != nil {
+		err := APIErrQueryDatastore()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/config"
+	oldPush "github.com/ARGOeu/argo-messaging/push"
+	"github.com/ARGOeu/argo-messaging/quotas"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+)
+
+type QuotasHandlersTestSuite struct {
+	suite.Suite
+	cfgStr string
+}
+
+func (suite *QuotasHandlersTestSuite) SetupTest() {
+	suite.cfgStr = `{
+	"bind_ip":"",
+	"port":8080,
+	"zookeeper_hosts":["localhost"],
+	"kafka_znode":"",
+	"store_host":"localhost",
+	"store_db":"argo_msg",
+	"certificate":"/etc/pki/tls/certs/localhost.crt",
+	"certificate_key":"/etc/pki/tls/private/localhost.key",
+	"per_resource_auth":"true"
+	}`
+}
+
+func (suite *QuotasHandlersTestSuite) router(str stores.Store) *mux.Router {
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	mgr := oldPush.Manager{}
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}:quota",
+		WrapMockAuthConfig(ProjectQuota, cfgKafka, &brk, str, &mgr, nil, "service_admin")).Methods("GET")
+	router.HandleFunc("/v1/projects/{project}:quota",
+		WrapMockAuthConfig(ProjectUpdateQuota, cfgKafka, &brk, str, &mgr, nil, "service_admin")).Methods("PUT")
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish",
+		WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil)).Methods("POST")
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}",
+		WrapMockAuthConfig(TopicCreate, cfgKafka, &brk, str, &mgr, nil)).Methods("PUT")
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}",
+		WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil)).Methods("PUT")
+	router.HandleFunc("/v1/projects/{project}/members/{user}:add",
+		WrapMockAuthConfig(ProjectUserAdd, cfgKafka, &brk, str, &mgr, nil, "service_admin")).Methods("POST")
+	router.HandleFunc("/v1/projects/{project}/members/{user}",
+		WrapMockAuthConfig(ProjectUserCreate, cfgKafka, &brk, str, &mgr, nil, "project_admin")).Methods("POST")
+	return router
+}
+
+func (suite *QuotasHandlersTestSuite) TestManageQuota() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := suite.router(str)
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO:quota", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "limits": {
+      "max_topics": 0,
+      "max_subscriptions": 0,
+      "max_users": 0,
+      "daily_messages": 0,
+      "daily_bytes": 0
+   },
+   "usage": {
+      "topics": 4,
+      "subscriptions": 4,
+      "users": 7,
+      "daily_messages": 0,
+      "daily_bytes": 0
+   }
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO:quota",
+		bytes.NewBuffer([]byte(`{"max_topics": 10, "daily_messages": 1000}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "limits": {
+      "max_topics": 10,
+      "max_subscriptions": 0,
+      "max_users": 0,
+      "daily_messages": 1000,
+      "daily_bytes": 0
+   },
+   "usage": {
+      "topics": 4,
+      "subscriptions": 4,
+      "users": 7,
+      "daily_messages": 0,
+      "daily_bytes": 0
+   }
+}`, w.Body.String())
+
+	qQuota, _ := str.QueryProjectQuota(context.Background(), "argo_uuid")
+	suite.Equal(stores.QProjectQuota{ProjectUUID: "argo_uuid", MaxTopics: 10, DailyMessages: 1000}, qQuota)
+
+	tests := []struct {
+		body    string
+		message string
+	}{
+		{`{"max_topics": "10"}`, "Invalid Quota Arguments"},
+		{`{"daily_bytes": -1}`, "negative value for quota daily_bytes"},
+	}
+
+	for _, t := range tests {
+		req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO:quota", bytes.NewBuffer([]byte(t.body)))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(400, w.Code)
+		suite.Contains(w.Body.String(), t.message)
+	}
+}
+
+func (suite *QuotasHandlersTestSuite) TestCreateQuotas() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	quotas.Update(context.Background(), "argo_uuid", quotas.Limits{MaxTopics: 4, MaxSubscriptions: 4, MaxUsers: 7}, str)
+	router := suite.router(str)
+
+	tests := []struct {
+		url   string
+		body  string
+		quota string
+	}{
+		{"http://localhost:8080/v1/projects/ARGO/topics/topicNew", "", "max_topics"},
+		{"http://localhost:8080/v1/projects/ARGO/subscriptions/subNew", `{"topic": "projects/ARGO/topics/topic1"}`, "max_subscriptions"},
+		{"http://localhost:8080/v1/projects/ARGO/members/userNew", `{"email": "user@new.com"}`, "max_users"},
+		{"http://localhost:8080/v1/projects/ARGO/members/push_worker_0:add", `{"roles": ["consumer"]}`, "max_users"},
+	}
+
+	for _, t := range tests {
+		method := "PUT"
+		if t.quota == "max_users" {
+			method = "POST"
+		}
+		req, _ := http.NewRequest(method, t.url, bytes.NewBuffer([]byte(t.body)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(429, w.Code, t.url)
+		suite.Equal(`{
+   "error": {
+      "code": 429,
+      "message": "Project quota `+t.quota+` exceeded",
+      "status": "RESOURCE_EXHAUSTED"
+   }
+}`, w.Body.String(), t.url)
+	}
+
+	// raising the quota makes room for one more topic
+	quotas.Update(context.Background(), "argo_uuid", quotas.Limits{MaxTopics: 5}, str)
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topicNew", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+}
+
+func (suite *QuotasHandlersTestSuite) TestPublishQuotas() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	quotas.Update(context.Background(), "argo_uuid", quotas.Limits{DailyMessages: 2, DailyBytes: 100}, str)
+	router := suite.router(str)
+
+	body := `{"messages": [{"data": "ZGF0YQ=="}]}`
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", bytes.NewBuffer([]byte(body)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code)
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO:quota", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Contains(w.Body.String(), `"daily_messages": 2,
+      "daily_bytes": 16`)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", bytes.NewBuffer([]byte(body)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(429, w.Code)
+	suite.Contains(w.Body.String(), "Project quota daily_messages exceeded")
+
+	// rejected publishes don't count towards the quotas
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO:quota", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Contains(w.Body.String(), `"daily_messages": 2,
+      "daily_bytes": 16`)
+
+	// the bytes of a request that go over the daily bytes
+	quotas.Update(context.Background(), "argo_uuid", quotas.Limits{DailyBytes: 20}, str)
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", bytes.NewBuffer([]byte(body)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(429, w.Code)
+	suite.Contains(w.Body.String(), "Project quota daily_bytes exceeded")
+}
+
+func TestQuotasHandlersTestSuite(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(QuotasHandlersTestSuite))
+}
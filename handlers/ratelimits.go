@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/ratelimit"
+	"github.com/ARGOeu/argo-messaging/stores"
+	gorillaContext "github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// rateLimitResponseWriter keeps the number of bytes written in the response of a rate limited route
+type rateLimitResponseWriter struct {
+	http.ResponseWriter
+	bytes int64
+}
+
+func (rw *rateLimitResponseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// rateLimitBody keeps the number of bytes read from the body of a rate limited route
+type rateLimitBody struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (rb *rateLimitBody) Read(p []byte) (int, error) {
+	n, err := rb.ReadCloser.Read(p)
+	rb.bytes += int64(n)
+	return n, err
+}
+
+// WrapRateLimit handle wrapper to enforce the rate limits of the project and the user of a request.
+// Requests are charged with the bytes of both their body and their response. The declared length of the body
+// is charged up front, while the bytes actually read beyond it, e.g. of chunked bodies, are charged afterwards.
+// A declared length larger than the bytes per second of a limit is rejected, since it would never fit in a window.
+// The limits fail open: when they can't be loaded or charged because of a store error, the request is served
+func WrapRateLimit(hfn http.Handler, routeClass string) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceId := gorillaContext.Get(r, "trace_id").(string)
+		rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+		rateLimiting, _ := gorillaContext.Get(r, "rate_limiting").(bool)
+		if !rateLimiting {
+			hfn.ServeHTTP(w, r)
+			return
+		}
+
+		refStr := gorillaContext.Get(r, "str").(stores.Store)
+		projectUUID, _ := gorillaContext.Get(r, "auth_project_uuid").(string)
+		userUUID, _ := gorillaContext.Get(r, "auth_user_uuid").(string)
+
+		buckets, err := ratelimit.Applicable(rCTX, projectUUID, userUUID, routeClass, refStr)
+		if err != nil || len(buckets) == 0 {
+			hfn.ServeHTTP(w, r)
+			return
+		}
+
+		var requestBytes int64
+		if r.ContentLength > 0 {
+			requestBytes = r.ContentLength
+		}
+
+		// a body that doesn't fit in a window would only ever get told to retry
+		if !ratelimit.Fits(buckets, requestBytes) {
+			err := APIErrorTooLargeRequest()
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		body := &rateLimitBody{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+
+		allowed, wait, err := ratelimit.Take(rCTX, buckets, requestBytes, time.Now().UTC(), refStr)
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"trace_id": traceId,
+					"type":     "service_log",
+					"error":    err.Error(),
+				},
+			).Error("Could not charge the rate limits of the request")
+		}
+
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfter(wait)))
+			err := APIErrorTooManyRequests()
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		rw := &rateLimitResponseWriter{ResponseWriter: w}
+		hfn.ServeHTTP(rw, r)
+
+		charged := rw.bytes
+		if body.bytes > requestBytes {
+			charged += body.bytes - requestBytes
+		}
+
+		if charged > 0 {
+			ratelimit.Charge(rCTX, buckets, charged, time.Now().UTC(), refStr)
+		}
+	})
+}
+
+// ProjectRateLimits (GET) shows the rate limits of a project
+func ProjectRateLimits(w http.ResponseWriter, r *http.Request) {
+	rateLimitsShow(w, r, ratelimit.ScopeProject)
+}
+
+// ProjectUpdateRateLimits (PUT) replaces the rate limits of a project
+func ProjectUpdateRateLimits(w http.ResponseWriter, r *http.Request) {
+	rateLimitsUpdate(w, r, ratelimit.ScopeProject)
+}
+
+// UserRateLimits (GET) shows the rate limits of a user
+func UserRateLimits(w http.ResponseWriter, r *http.Request) {
+	rateLimitsShow(w, r, ratelimit.ScopeUser)
+}
+
+// UserUpdateRateLimits (PUT) replaces the rate limits of a user
+func UserUpdateRateLimits(w http.ResponseWriter, r *http.Request) {
+	rateLimitsUpdate(w, r, ratelimit.ScopeUser)
+}
+
+// rateLimitsScope returns the uuid of the project or user of the request that rate limits are managed for,
+// or an empty value when the user doesn't exist
+func rateLimitsScope(ctx context.Context, r *http.Request, scopeType string, store stores.Store) string {
+	if scopeType == ratelimit.ScopeProject {
+		return gorillaContext.Get(r, "auth_project_uuid").(string)
+	}
+	return auth.GetUUIDByName(ctx, mux.Vars(r)["user"], store)
+}
+
+// rateLimitsShow shows the rate limits of the project or user of the request
+func rateLimitsShow(w http.ResponseWriter, r *http.Request, scopeType string) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	scopeUUID := rateLimitsScope(rCTX, r, scopeType, refStr)
+	if scopeUUID == "" {
+		err := APIErrorNotFound("User")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	res, err := ratelimit.Find(rCTX, scopeType, scopeUUID, refStr)
+	if err != nil {
+		err := APIErrQueryDatastore()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// rateLimitsUpdate replaces the rate limits of the project or user of the request with the ones of the request body
+func rateLimitsUpdate(w http.ResponseWriter, r *http.Request, scopeType string) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	scopeUUID := rateLimitsScope(rCTX, r, scopeType, refStr)
+	if scopeUUID == "" {
+		err := APIErrorNotFound("User")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Read PUT JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	postBody, err := ratelimit.GetLimitsFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Rate Limits")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	if err := postBody.Validate(); err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	if err := ratelimit.Update(rCTX, scopeType, scopeUUID, postBody, refStr); err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := postBody.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/config"
+	oldPush "github.com/ARGOeu/argo-messaging/push"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+)
+
+type RateLimitsHandlersTestSuite struct {
+	suite.Suite
+	cfgStr string
+}
+
+func (suite *RateLimitsHandlersTestSuite) SetupTest() {
+	suite.cfgStr = `{
+	"bind_ip":"",
+	"port":8080,
+	"zookeeper_hosts":["localhost"],
+	"kafka_znode":"",
+	"store_host":"localhost",
+	"store_db":"argo_msg",
+	"certificate":"/etc/pki/tls/certs/localhost.crt",
+	"certificate_key":"/etc/pki/tls/private/localhost.key",
+	"per_resource_auth":"true",
+	"rate_limiting":true
+	}`
+}
+
+func (suite *RateLimitsHandlersTestSuite) router(cfgStr string, str stores.Store) *mux.Router {
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(cfgStr)
+	brk := brokers.MockBroker{}
+	mgr := oldPush.Manager{}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		respondOK(w, []byte("ok"))
+	})
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/rateLimits",
+		WrapMockAuthConfig(ProjectRateLimits, cfgKafka, &brk, str, &mgr, nil, "service_admin")).Methods("GET")
+	router.HandleFunc("/v1/projects/{project}/rateLimits",
+		WrapMockAuthConfig(ProjectUpdateRateLimits, cfgKafka, &brk, str, &mgr, nil, "service_admin")).Methods("PUT")
+	router.HandleFunc("/v1/users/{user}/rateLimits",
+		WrapMockAuthConfig(UserRateLimits, cfgKafka, &brk, str, &mgr, nil, "service_admin")).Methods("GET")
+	router.HandleFunc("/v1/users/{user}/rateLimits",
+		WrapMockAuthConfig(UserUpdateRateLimits, cfgKafka, &brk, str, &mgr, nil, "service_admin")).Methods("PUT")
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish",
+		WrapMockAuthConfig(WrapRateLimit(ok, "publish"), cfgKafka, &brk, str, &mgr, nil)).Methods("POST")
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull",
+		WrapMockAuthConfig(WrapRateLimit(ok, "pull"), cfgKafka, &brk, str, &mgr, nil)).Methods("POST")
+	return router
+}
+
+func (suite *RateLimitsHandlersTestSuite) TestManageRateLimits() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := suite.router(suite.cfgStr, str)
+
+	limitsJSON := `{
+   "rate_limits": [
+      {
+         "route_class": "publish",
+         "requests_per_second": 100,
+         "bytes_per_second": 1048576
+      }
+   ]
+}`
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/rateLimits", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "rate_limits": []
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/rateLimits", bytes.NewBuffer([]byte(limitsJSON)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(limitsJSON, w.Body.String())
+
+	qLimits, _ := str.QueryRateLimits(context.Background(), "projects", "argo_uuid")
+	suite.Equal([]stores.QRateLimit{{RouteClass: "publish", RequestsPerSecond: 100, BytesPerSecond: 1048576}}, qLimits)
+
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/users/UserB/rateLimits", bytes.NewBuffer([]byte(limitsJSON)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/users/UserB/rateLimits", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(limitsJSON, w.Body.String())
+
+	tests := []struct {
+		method  string
+		url     string
+		body    string
+		code    int
+		message string
+	}{
+		{"GET", "http://localhost:8080/v1/users/unknown/rateLimits", "", 404, "User doesn't exist"},
+		{"PUT", "http://localhost:8080/v1/users/unknown/rateLimits", limitsJSON, 404, "User doesn't exist"},
+		{"PUT", "http://localhost:8080/v1/projects/ARGO/rateLimits", `{}`, 400, "Invalid Rate Limits Arguments"},
+		{"PUT", "http://localhost:8080/v1/projects/ARGO/rateLimits", `{"rate_limits": [{"route_class": "push"}]}`, 400, "invalid route class push"},
+	}
+
+	for _, t := range tests {
+		req, _ = http.NewRequest(t.method, t.url, bytes.NewBuffer([]byte(t.body)))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.code, w.Code, t.url)
+		suite.Contains(w.Body.String(), t.message, t.url)
+	}
+
+	// an empty list removes the limits
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/rateLimits", bytes.NewBuffer([]byte(`{"rate_limits": []}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	qLimits, _ = str.QueryRateLimits(context.Background(), "projects", "argo_uuid")
+	suite.Equal([]stores.QRateLimit{}, qLimits)
+}
+
+func (suite *RateLimitsHandlersTestSuite) TestWrapRateLimit() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.UpdateRateLimits(context.Background(), "projects", "argo_uuid",
+		[]stores.QRateLimit{{RouteClass: "publish", RequestsPerSecond: 100, BytesPerSecond: 60}})
+	router := suite.router(suite.cfgStr, str)
+
+	body := `{"messages": [{"data": "ZGF0YQ=="}]}`
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", bytes.NewBuffer([]byte(body)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	// the second request goes over the bytes per second of the project
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", bytes.NewBuffer([]byte(body)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(429, w.Code)
+	suite.Equal("1", w.Header().Get("Retry-After"))
+	suite.Equal(`{
+   "error": {
+      "code": 429,
+      "message": "Rate limit exceeded, please retry later",
+      "status": "RESOURCE_EXHAUSTED"
+   }
+}`, w.Body.String())
+
+	// a body that is larger than the bytes per second of the project is rejected instead of retried forever
+	str.UpdateRateLimits(context.Background(), "projects", "argo_uuid",
+		[]stores.QRateLimit{{RouteClass: "publish", RequestsPerSecond: 100, BytesPerSecond: 10}})
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", bytes.NewBuffer([]byte(body)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(413, w.Code)
+	suite.Equal("", w.Header().Get("Retry-After"))
+	suite.Equal(`{
+   "error": {
+      "code": 413,
+      "message": "Request body is larger than the bytes per second of the rate limits",
+      "status": "INVALID_ARGUMENT"
+   }
+}`, w.Body.String())
+
+	// the limits of a route class don't apply to the others
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", bytes.NewBuffer([]byte(body)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	// the response of a request is charged as well
+	str.UpdateRateLimits(context.Background(), "users", "uuid1",
+		[]stores.QRateLimit{{RouteClass: "pull", RequestsPerSecond: 100, BytesPerSecond: 1000}})
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", bytes.NewBuffer([]byte(body)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	var charged int64
+	for _, counter := range str.RateCounters {
+		if counter.Key == "users:uuid1:pull" {
+			charged += counter.Bytes
+		}
+	}
+	suite.Equal(int64(len(body)+len("ok")), charged)
+
+	// the bytes of a chunked body, which has no declared length, are charged once they have been read
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull",
+		ioutil.NopCloser(strings.NewReader(body)))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	charged = 0
+	for _, counter := range str.RateCounters {
+		if counter.Key == "users:uuid1:pull" {
+			charged += counter.Bytes
+		}
+	}
+	suite.Equal(int64(2*(len(body)+len("ok"))), charged)
+
+	// limits are not enforced when rate limiting is disabled
+	router = suite.router(strings.Replace(suite.cfgStr, `"rate_limiting":true`, `"rate_limiting":false`, 1), str)
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", bytes.NewBuffer([]byte(body)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+}
+
+func TestRateLimitsHandlersTestSuite(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(RateLimitsHandlersTestSuite))
+}
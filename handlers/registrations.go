@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/notifications"
 	"github.com/ARGOeu/argo-messaging/stores"
 	gorillaContext "github.com/gorilla/context"
 	"github.com/gorilla/mux"
@@ -75,6 +76,15 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// ask the registrant to confirm their email and let the service admins know about the registration
+	data := registrationData(r, ur)
+	notifyRegistration(rCTX, r, notifications.EmailConfirmationTemplate, []string{ur.Email}, data, refStr)
+
+	admins, err := notifications.ServiceAdminEmails(rCTX, refStr)
+	if err == nil && len(admins) > 0 {
+		notifyRegistration(rCTX, r, notifications.PendingRegistrationTemplate, admins, data, refStr)
+	}
+
 	output, err = json.MarshalIndent(ur, "", "   ")
 	if err != nil {
 		err := APIErrGenericInternal(err.Error())
@@ -85,6 +95,60 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 	respondOK(w, output)
 }
 
+// ConfirmRegistrationEmail (GET) confirms the email of a pending registration through its activation token
+func ConfirmRegistrationEmail(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	activationToken := r.URL.Query().Get("activation_token")
+	if activationToken == "" {
+		err := APIErrorNotFound("User registration")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	urList, err := auth.FindUserRegistrations(rCTX, auth.PendingRegistrationStatus, activationToken, "", "", "", refStr)
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	if len(urList.UserRegistrations) == 0 {
+		err := APIErrorNotFound("User registration")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	ur := urList.UserRegistrations[0]
+	if ur.EmailConfirmedAt == "" {
+		confirmed := time.Now().UTC()
+		err = auth.ConfirmUserRegistrationEmail(rCTX, ur.UUID, confirmed, refStr)
+		if err != nil {
+			err := APIErrGenericInternal(err.Error())
+			respondErr(rCTX, w, err)
+			return
+		}
+		ur.EmailConfirmedAt = confirmed.Format("2006-01-02T15:04:05Z")
+	}
+
+	urb, err := json.MarshalIndent(ur, "", "   ")
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	respondOK(w, urb)
+}
+
 // AcceptUserRegister (POST) accepts a user registration and creates the respective user
 func AcceptRegisterUser(w http.ResponseWriter, r *http.Request) {
 	traceId := gorillaContext.Get(r, "trace_id").(string)
@@ -147,6 +211,9 @@ func AcceptRegisterUser(w http.ResponseWriter, r *http.Request) {
 		).Error("Could not update registration")
 	}
 
+	// let the registrant know how to start using the service
+	notifyRegistration(rCTX, r, notifications.AcceptedRegistrationTemplate, []string{ru.Email}, registrationData(r, ru), refStr)
+
 	// Output result to JSON
 	resJSON, err := res.ExportJSON()
 	if err != nil {
@@ -175,7 +242,7 @@ func DeclineRegisterUser(w http.ResponseWriter, r *http.Request) {
 	// Grab context references
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
 
-	_, err := auth.FindUserRegistration(rCTX, regUUID, auth.PendingRegistrationStatus, refStr)
+	ru, err := auth.FindUserRegistration(rCTX, regUUID, auth.PendingRegistrationStatus, refStr)
 	if err != nil {
 
 		if err.Error() == "not found" {
@@ -208,6 +275,10 @@ func DeclineRegisterUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	data := registrationData(r, ru)
+	data.DeclineComment = reqBody["comment"]
+	notifyRegistration(rCTX, r, notifications.DeclinedRegistrationTemplate, []string{ru.Email}, data, refStr)
+
 	respondOK(w, []byte("{}"))
 
 }
@@ -326,3 +397,50 @@ func DeleteRegistration(w http.ResponseWriter, r *http.Request) {
 	}
 	respondOK(w, nil)
 }
+
+// registrationData returns the values of a registration that its notifications can refer to
+func registrationData(r *http.Request, ur auth.UserRegistration) notifications.Data {
+
+	// links are only built from the configured host, since the host of the request is up to the client
+	serviceHost := gorillaContext.Get(r, "proxy_hostname").(string)
+
+	return notifications.Data{
+		Name:             ur.Name,
+		FirstName:        ur.FirstName,
+		LastName:         ur.LastName,
+		Email:            ur.Email,
+		Organization:     ur.Organization,
+		Description:      ur.Description,
+		RegistrationUUID: ur.UUID,
+		RegisteredAt:     ur.RegisteredAt,
+		ActivationToken:  ur.ActivationToken,
+		DeclineComment:   ur.DeclineComment,
+		ServiceHost:      serviceHost,
+	}
+}
+
+// notifyRegistration sends a notification about a registration when notifications are enabled.
+// Failing to send it doesn't fail the request, it only gets logged
+func notifyRegistration(ctx context.Context, r *http.Request, name string, to []string, data notifications.Data, store stores.Store) {
+
+	notifier, ok := gorillaContext.Get(r, "notifier").(notifications.Sender)
+	if !ok || notifier == nil {
+		return
+	}
+
+	if len(to) == 0 || to[0] == "" {
+		return
+	}
+
+	err := notifications.Notify(ctx, notifier, name, to, data, store)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"trace_id":     ctx.Value("trace_id"),
+				"type":         "service_log",
+				"notification": name,
+				"error":        err.Error(),
+			},
+		).Error("Could not send notification")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/notifications"
 	oldPush "github.com/ARGOeu/argo-messaging/push"
 	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
 	"github.com/ARGOeu/argo-messaging/stores"
@@ -194,7 +195,11 @@ func (suite *RegistrationsHandlersTestSuite) TestAcceptRegisterUser() {
 		if t.expectedStatusCode == 200 {
 			u, _ := auth.FindUsers(context.Background(), "", "", t.uname, true, str)
 			t.expectedResponse = strings.Replace(t.expectedResponse, "{{UUID}}", u.List[0].UUID, 1)
-			t.expectedResponse = strings.Replace(t.expectedResponse, "{{TOKEN}}", u.List[0].Token, 1)
+			// the token is only shown in the response, the store keeps its hash
+			res, _ := auth.GetUserFromJSON([]byte(w.Body.String()))
+			qUsers, _ := str.QueryUsers(context.Background(), "", u.List[0].UUID, "")
+			suite.Equal(auth.HashToken(res.Token), qUsers[0].Token, t.msg)
+			t.expectedResponse = strings.Replace(t.expectedResponse, "{{TOKEN}}", res.Token, 1)
 			t.expectedResponse = strings.Replace(t.expectedResponse, "{{CON}}", u.List[0].CreatedOn, 1)
 			t.expectedResponse = strings.Replace(t.expectedResponse, "{{MON}}", u.List[0].ModifiedOn, 1)
 		}
@@ -481,6 +486,188 @@ func (suite *RegistrationsHandlersTestSuite) TestListManyRegistrations() {
 
 }
 
+func (suite *RegistrationsHandlersTestSuite) TestConfirmRegistrationEmail() {
+
+	type td struct {
+		activationToken    string
+		expectedResponse   string
+		expectedStatusCode int
+		msg                string
+	}
+
+	testData := []td{
+		{
+			activationToken: "uratkn-1",
+			expectedResponse: `{
+   "uuid": "ur-uuid1",
+   "name": "urname",
+   "first_name": "urfname",
+   "last_name": "urlname",
+   "organization": "urorg",
+   "description": "urdesc",
+   "email": "uremail",
+   "status": "pending",
+   "activation_token": "uratkn-1",
+   "registered_at": "2019-05-12T22:26:58Z",
+   "modified_by": "UserA",
+   "modified_at": "2020-05-15T22:26:58Z",
+   "email_confirmed_at": "{{ECAT}}"
+}`,
+			expectedStatusCode: 200,
+			msg:                "Email of the user registration confirmed successfully",
+		},
+		{
+			activationToken: "unknown",
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "User registration doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			expectedStatusCode: 404,
+			msg:                "No user registration with the activation token",
+		},
+		{
+			activationToken: "",
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "User registration doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			expectedStatusCode: 404,
+			msg:                "Missing activation token",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+
+	for _, t := range testData {
+
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("http://localhost:8080/v1/registrations:confirmEmail?activation_token=%v", t.activationToken)
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		router.HandleFunc("/v1/registrations:confirmEmail", WrapMockAuthConfig(ConfirmRegistrationEmail, cfgKafka, &brk, str, &mgr, pc))
+		router.ServeHTTP(w, req)
+		if t.expectedStatusCode == 200 {
+			suite.NotEqual("", str.UserRegistrations[0].EmailConfirmedAt)
+			t.expectedResponse = strings.Replace(t.expectedResponse, "{{ECAT}}", str.UserRegistrations[0].EmailConfirmedAt, 1)
+		}
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+	}
+}
+
+func (suite *RegistrationsHandlersTestSuite) TestRegistrationNotifications() {
+
+	srv, err := notifications.NewMockSMTPServer()
+	suite.Nil(err)
+	defer srv.Close()
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.ProxyHostname = "ams.example.org"
+	cfgKafka.SMTPHost = srv.Host()
+	cfgKafka.SMTPPort = srv.Port()
+	cfgKafka.SMTPFrom = "ams@example.org"
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+
+	// service admins get notified about pending registrations
+	str.UserList[1].ServiceRoles = []string{"service_admin"}
+	str.UserList[1].Email = "admin@example.org"
+
+	router.HandleFunc("/v1/registrations", WrapMockAuthConfig(RegisterUser, cfgKafka, &brk, str, &mgr, pc))
+	router.HandleFunc("/v1/registrations/{uuid}:accept", WrapMockAuthConfig(AcceptRegisterUser, cfgKafka, &brk, str, &mgr, pc))
+	router.HandleFunc("/v1/registrations/{uuid}:decline", WrapMockAuthConfig(DeclineRegisterUser, cfgKafka, &brk, str, &mgr, pc))
+
+	postBody := `{
+	"name": "new-register-user",
+	"first_name": "first-name",
+	"last_name": "last-name",
+	"email": "test@example.com",
+	"organization": "org1",
+	"description": "desc1"
+}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/registrations", strings.NewReader(postBody))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	ur := str.UserRegistrations[1]
+	messages := srv.Messages()
+	suite.Equal(2, len(messages))
+	suite.Equal([]string{"test@example.com"}, messages[0].To)
+	suite.Equal("ams@example.org", messages[0].From)
+	suite.True(strings.Contains(messages[0].Data, "Subject: Confirm your email for the ARGO Messaging Service\r\n"))
+	suite.True(strings.Contains(messages[0].Data,
+		fmt.Sprintf("https://ams.example.org/v1/registrations:confirmEmail?activation_token=%v", ur.ActivationToken)))
+	suite.Equal([]string{"admin@example.org"}, messages[1].To)
+	suite.True(strings.Contains(messages[1].Data, "Subject: New user registration new-register-user is pending\r\n"))
+	suite.True(strings.Contains(messages[1].Data, fmt.Sprintf("https://ams.example.org/v1/registrations/%v", ur.UUID)))
+
+	// the accepted registrant gets the info they need to start using the service
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", fmt.Sprintf("http://localhost:8080/v1/registrations/%v:accept", ur.UUID), nil)
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	user, _ := auth.GetUserFromJSON(w.Body.Bytes())
+	suite.NotEqual("", user.Token)
+	messages = srv.Messages()
+	suite.Equal(3, len(messages))
+	suite.Equal([]string{"test@example.com"}, messages[2].To)
+	suite.True(strings.Contains(messages[2].Data, "Subject: Your registration to the ARGO Messaging Service has been accepted\r\n"))
+	suite.True(strings.Contains(messages[2].Data, "Username: new-register-user\r\n"))
+	// the token of the user is never emailed
+	suite.False(strings.Contains(messages[2].Data, user.Token))
+
+	// edited templates replace the defaults
+	notifications.UpdateTemplate(context.Background(), notifications.DeclinedRegistrationTemplate,
+		notifications.Template{Subject: "Declined {{.Name}}", Body: "Reason: {{.DeclineComment}}"}, str)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/registrations/ur-uuid1:decline", strings.NewReader(`{"comment": "unknown organization"}`))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	messages = srv.Messages()
+	suite.Equal(4, len(messages))
+	suite.Equal([]string{"uremail"}, messages[3].To)
+	suite.True(strings.Contains(messages[3].Data, "Subject: Declined urname\r\n"))
+	suite.True(strings.HasSuffix(messages[3].Data, "\r\n\r\nReason: unknown organization\r\n"))
+
+	// links are never built from the host of the request, so nothing is sent without a configured host
+	cfgKafka.ProxyHostname = ""
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/registrations", strings.NewReader(strings.Replace(postBody, "new-register-user", "forged-host-user", 1)))
+	req.Host = "attacker.example.org"
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(4, len(srv.Messages()))
+
+	// failing to send a notification doesn't fail the request
+	srv.Close()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/registrations", strings.NewReader(strings.Replace(postBody, "new-register-user", "other-user", 1)))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+}
+
 func TestRegistrationsHandlersTestSuite(t *testing.T) {
 	log.SetOutput(ioutil.Discard)
 	suite.Run(t, new(RegistrationsHandlersTestSuite))
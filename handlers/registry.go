@@ -0,0 +1,725 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ARGOeu/argo-messaging/schemas"
+	"github.com/ARGOeu/argo-messaging/stores"
+	gorillaContext "github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/twinj/uuid"
+	"net/http"
+	"strconv"
+)
+
+// registryContentType is the media type of the Confluent Schema Registry API
+const registryContentType = "application/vnd.schemaregistry.v1+json"
+
+// RegistryError is the error body of the Confluent Schema Registry API
+type RegistryError struct {
+	Status    int    `json:"-"`
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+// registrySchemaRequest is the body of the schema registry calls that submit a schema
+type registrySchemaRequest struct {
+	Schema     string        `json:"schema"`
+	SchemaType string        `json:"schemaType"`
+	References []interface{} `json:"references"`
+}
+
+func registryErrSubjectNotFound(subject string) RegistryError {
+	return RegistryError{Status: http.StatusNotFound, ErrorCode: 40401, Message: fmt.Sprintf("Subject '%s' not found.", subject)}
+}
+
+func registryErrVersionNotFound(version string) RegistryError {
+	return RegistryError{Status: http.StatusNotFound, ErrorCode: 40402, Message: fmt.Sprintf("Version %s not found.", version)}
+}
+
+func registryErrSchemaNotFound() RegistryError {
+	return RegistryError{Status: http.StatusNotFound, ErrorCode: 40403, Message: "Schema not found"}
+}
+
+func registryErrInvalidSchema(msg string) RegistryError {
+	return RegistryError{Status: http.StatusUnprocessableEntity, ErrorCode: 42201, Message: fmt.Sprintf("Invalid schema: %s", msg)}
+}
+
+func registryErrInvalidVersion(version string) RegistryError {
+	return RegistryError{Status: http.StatusUnprocessableEntity, ErrorCode: 42202,
+		Message: fmt.Sprintf("The specified version '%s' is not a valid version id. Allowed values are between [1, 2^31-1] and the string \"latest\"", version)}
+}
+
+func registryErrInvalidCompatibility() RegistryError {
+	return RegistryError{Status: http.StatusUnprocessableEntity, ErrorCode: 42203,
+		Message: "Invalid compatibility level. Valid values are none, backward, forward and full"}
+}
+
+func registryErrIncompatible(subject string, msg string) RegistryError {
+	return RegistryError{Status: http.StatusConflict, ErrorCode: http.StatusConflict,
+		Message: fmt.Sprintf("Schema being registered is incompatible with an earlier schema for subject \"%s\", details: %s", subject, msg)}
+}
+
+func registryErrReferenceExists() RegistryError {
+	return RegistryError{Status: http.StatusUnprocessableEntity, ErrorCode: 42206,
+		Message: "One or more references exist to the schema"}
+}
+
+func registryErrBackend(msg string) RegistryError {
+	return RegistryError{Status: http.StatusInternalServerError, ErrorCode: 50001, Message: fmt.Sprintf("Error in the backend data store: %s", msg)}
+}
+
+// respondRegistryErr finalizes the response writer with a schema registry error
+func respondRegistryErr(ctx context.Context, w http.ResponseWriter, regErr RegistryError) {
+	log.WithFields(
+		log.Fields{
+			"trace_id":    ctx.Value("trace_id"),
+			"type":        "service_log",
+			"status_code": regErr.Status,
+		},
+	).Info(regErr.Message)
+
+	w.WriteHeader(regErr.Status)
+	output, _ := json.Marshal(regErr)
+	w.Write(output)
+}
+
+// findSubject retrieves the schema that backs a schema registry subject
+func findSubject(ctx context.Context, projectUUID, subject string, str stores.Store) (schemas.Schema, *RegistryError) {
+
+	schemasList, err := schemas.Find(ctx, projectUUID, "", subject, str)
+	if err != nil {
+		regErr := registryErrBackend(err.Error())
+		return schemas.Schema{}, &regErr
+	}
+
+	if schemasList.Empty() {
+		regErr := registryErrSubjectNotFound(subject)
+		return schemas.Schema{}, &regErr
+	}
+
+	return schemasList.Schemas[0], nil
+}
+
+// findSubjectVersions retrieves the revisions of the schema that backs a subject
+func findSubjectVersions(ctx context.Context, schema schemas.Schema, str stores.Store) ([]schemas.Revision, *RegistryError) {
+
+	revisionList, err := schemas.FindRevisions(ctx, schema, 0, str)
+	if err != nil {
+		regErr := registryErrBackend(err.Error())
+		return nil, &regErr
+	}
+
+	return revisionList.Revisions, nil
+}
+
+// findSubjectVersion resolves a version of the schema registry api, either a number or latest, to a revision of the subject
+func findSubjectVersion(ctx context.Context, schema schemas.Schema, version string, str stores.Store) (schemas.Revision, *RegistryError) {
+
+	revision := int64(-1)
+
+	if version != "latest" {
+		v, err := strconv.ParseInt(version, 10, 32)
+		if err != nil || v == 0 || v < -1 {
+			regErr := registryErrInvalidVersion(version)
+			return schemas.Revision{}, &regErr
+		}
+		revision = v
+	}
+
+	revisions, regErr := findSubjectVersions(ctx, schema, str)
+	if regErr != nil {
+		return schemas.Revision{}, regErr
+	}
+
+	for _, r := range revisions {
+		if r.Revision == revision || (revision == -1 && r.Revision == schema.Revision) {
+			return r, nil
+		}
+	}
+
+	notFound := registryErrVersionNotFound(version)
+	return schemas.Revision{}, &notFound
+}
+
+// decodeRegistrySchema reads a submitted schema registry schema and converts it to a schema type and content
+func decodeRegistrySchema(r *http.Request) (string, map[string]interface{}, *RegistryError) {
+
+	body := registrySchemaRequest{}
+
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		regErr := registryErrInvalidSchema("the request body is not valid")
+		return "", nil, &regErr
+	}
+
+	if len(body.References) > 0 {
+		regErr := registryErrInvalidSchema("schema references are not supported")
+		return "", nil, &regErr
+	}
+
+	schemaType, err := schemas.FromRegistryType(body.SchemaType)
+	if err != nil {
+		regErr := registryErrInvalidSchema(err.Error())
+		return "", nil, &regErr
+	}
+
+	rawSchema, err := schemas.FromRegistrySchema(schemaType, body.Schema)
+	if err != nil {
+		regErr := registryErrInvalidSchema(err.Error())
+		return "", nil, &regErr
+	}
+
+	return schemaType, rawSchema, nil
+}
+
+// toRegistrySchema converts a revision of a subject to its schema registry representation
+func toRegistrySchema(subject string, revision schemas.Revision) (schemas.RegistrySchema, *RegistryError) {
+
+	definition, err := schemas.ToRegistrySchema(revision.Type, revision.RawSchema)
+	if err != nil {
+		regErr := registryErrInvalidSchema(err.Error())
+		return schemas.RegistrySchema{}, &regErr
+	}
+
+	return schemas.RegistrySchema{
+		Subject:    subject,
+		Version:    revision.Revision,
+		ID:         revision.ID,
+		SchemaType: schemas.ToRegistryType(revision.Type),
+		Schema:     definition,
+	}, nil
+}
+
+// RegistryListSubjects (GET) lists the subjects, the schemas of the project
+func RegistryListSubjects(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	w.Header().Add("Content-Type", registryContentType)
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	schemasList, err := schemas.Find(rCTX, projectUUID, "", "", refStr)
+	if err != nil {
+		respondRegistryErr(rCTX, w, registryErrBackend(err.Error()))
+		return
+	}
+
+	subjects := []string{}
+	for _, s := range schemasList.Schemas {
+		subjects = append(subjects, s.Name)
+	}
+
+	output, _ := json.Marshal(subjects)
+	respondOK(w, output)
+}
+
+// RegistryListVersions (GET) lists the versions of a subject
+func RegistryListVersions(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	w.Header().Add("Content-Type", registryContentType)
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+	subject := urlVars["subject"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	schema, regErr := findSubject(rCTX, projectUUID, subject, refStr)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	revisions, regErr := findSubjectVersions(rCTX, schema, refStr)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	versions := []int64{}
+	for _, rev := range revisions {
+		versions = append(versions, rev.Revision)
+	}
+
+	output, _ := json.Marshal(versions)
+	respondOK(w, output)
+}
+
+// RegistryShowVersion (GET) retrieves a version of a subject
+func RegistryShowVersion(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	w.Header().Add("Content-Type", registryContentType)
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+	subject := urlVars["subject"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	schema, regErr := findSubject(rCTX, projectUUID, subject, refStr)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	revision, regErr := findSubjectVersion(rCTX, schema, urlVars["version"], refStr)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	registrySchema, regErr := toRegistrySchema(subject, revision)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	output, _ := json.Marshal(registrySchema)
+	respondOK(w, output)
+}
+
+// RegistryShowVersionSchema (GET) retrieves only the definition of a version of a subject
+func RegistryShowVersionSchema(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	w.Header().Add("Content-Type", registryContentType)
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+	subject := urlVars["subject"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	schema, regErr := findSubject(rCTX, projectUUID, subject, refStr)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	revision, regErr := findSubjectVersion(rCTX, schema, urlVars["version"], refStr)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	registrySchema, regErr := toRegistrySchema(subject, revision)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	respondOK(w, []byte(registrySchema.Schema))
+}
+
+// RegistryRegister (POST) registers a schema under a subject and returns its id.
+// The subject's schema is created when it doesn't exist, a schema that is already a version of the subject is not registered again.
+func RegistryRegister(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	w.Header().Add("Content-Type", registryContentType)
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+	subject := urlVars["subject"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	schemaType, rawSchema, regErr := decodeRegistrySchema(r)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	schema, regErr := findSubject(rCTX, projectUUID, subject, refStr)
+	if regErr != nil && regErr.ErrorCode != 40401 {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	var err error
+
+	if regErr != nil {
+		schema, err = schemas.Create(rCTX, projectUUID, uuid.NewV4().String(), subject, schemaType, "", rawSchema, nil, refStr)
+		if err != nil {
+			respondRegistryErr(rCTX, w, registryErrBackend(err.Error()))
+			return
+		}
+		schema.ProjectUUID = projectUUID
+	} else {
+		revisions, regErr := findSubjectVersions(rCTX, schema, refStr)
+		if regErr != nil {
+			respondRegistryErr(rCTX, w, *regErr)
+			return
+		}
+
+		for _, rev := range revisions {
+			if rev.SameContent(schemaType, rawSchema) {
+				output, _ := json.Marshal(map[string]int64{"id": rev.ID})
+				respondOK(w, output)
+				return
+			}
+		}
+
+		next := schema
+		next.Type = schemaType
+		next.RawSchema = rawSchema
+
+		err = schemas.CheckCompatibility(schema.Compatibility, schema, next)
+		if err != nil {
+			respondRegistryErr(rCTX, w, registryErrIncompatible(subject, err.Error()))
+			return
+		}
+
+		schema, err = schemas.Update(rCTX, schema, "", schemaType, "", rawSchema, nil, refStr)
+		if err != nil {
+			respondRegistryErr(rCTX, w, registryErrBackend(err.Error()))
+			return
+		}
+	}
+
+	revisionList, err := schemas.FindRevisions(rCTX, schema, schema.Revision, refStr)
+	if err != nil || revisionList.Empty() {
+		respondRegistryErr(rCTX, w, registryErrBackend("could not load the registered schema"))
+		return
+	}
+
+	output, _ := json.Marshal(map[string]int64{"id": revisionList.Revisions[0].ID})
+	respondOK(w, output)
+}
+
+// RegistryLookup (POST) looks up a schema among the versions of a subject
+func RegistryLookup(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	w.Header().Add("Content-Type", registryContentType)
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+	subject := urlVars["subject"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	schema, regErr := findSubject(rCTX, projectUUID, subject, refStr)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	schemaType, rawSchema, regErr := decodeRegistrySchema(r)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	revisions, regErr := findSubjectVersions(rCTX, schema, refStr)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	for _, rev := range revisions {
+		if rev.SameContent(schemaType, rawSchema) {
+			registrySchema, regErr := toRegistrySchema(subject, rev)
+			if regErr != nil {
+				respondRegistryErr(rCTX, w, *regErr)
+				return
+			}
+
+			output, _ := json.Marshal(registrySchema)
+			respondOK(w, output)
+			return
+		}
+	}
+
+	respondRegistryErr(rCTX, w, registryErrSchemaNotFound())
+}
+
+// RegistryDeleteSubject (DELETE) deletes a subject along with all of its versions and returns the deleted versions
+func RegistryDeleteSubject(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	w.Header().Add("Content-Type", registryContentType)
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+	subject := urlVars["subject"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	schema, regErr := findSubject(rCTX, projectUUID, subject, refStr)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	revisionList, err := schemas.FindRevisions(rCTX, schema, 0, refStr)
+	if err != nil {
+		respondRegistryErr(rCTX, w, registryErrBackend(err.Error()))
+		return
+	}
+
+	err = schemas.Delete(rCTX, projectUUID, schema.UUID, refStr)
+	if err != nil {
+		if err.Error() == "referenced" {
+			respondRegistryErr(rCTX, w, registryErrReferenceExists())
+			return
+		}
+		respondRegistryErr(rCTX, w, registryErrBackend(err.Error()))
+		return
+	}
+
+	versions := []int64{}
+	for _, rev := range revisionList.Revisions {
+		versions = append(versions, rev.Revision)
+	}
+
+	output, _ := json.Marshal(versions)
+	respondOK(w, output)
+}
+
+// RegistryShowSchema (GET) retrieves a schema by its id
+func RegistryShowSchema(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	w.Header().Add("Content-Type", registryContentType)
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	id, err := strconv.ParseInt(urlVars["id"], 10, 64)
+	if err != nil {
+		respondRegistryErr(rCTX, w, registryErrSchemaNotFound())
+		return
+	}
+
+	revisionList, err := schemas.FindRevisionByID(rCTX, projectUUID, id, refStr)
+	if err != nil {
+		respondRegistryErr(rCTX, w, registryErrBackend(err.Error()))
+		return
+	}
+
+	if revisionList.Empty() {
+		respondRegistryErr(rCTX, w, registryErrSchemaNotFound())
+		return
+	}
+
+	registrySchema, regErr := toRegistrySchema("", revisionList.Revisions[0])
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	// lookups by id only return the definition of the schema
+	registrySchema.Version = 0
+	registrySchema.ID = 0
+
+	output, _ := json.Marshal(registrySchema)
+	respondOK(w, output)
+}
+
+// RegistryListTypes (GET) lists the schema types that the registry supports
+func RegistryListTypes(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	w.Header().Add("Content-Type", registryContentType)
+
+	output, _ := json.Marshal([]string{schemas.RegistryAvro, schemas.RegistryJSON, schemas.RegistryProtobuf})
+	respondOK(w, output)
+}
+
+// RegistryShowConfig (GET) retrieves the default compatibility level that new subjects get
+func RegistryShowConfig(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	w.Header().Add("Content-Type", registryContentType)
+
+	output, _ := json.Marshal(map[string]string{"compatibilityLevel": schemas.NONE})
+	respondOK(w, output)
+}
+
+// RegistryShowSubjectConfig (GET) retrieves the compatibility level of a subject
+func RegistryShowSubjectConfig(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	w.Header().Add("Content-Type", registryContentType)
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+	subject := urlVars["subject"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	schema, regErr := findSubject(rCTX, projectUUID, subject, refStr)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	output, _ := json.Marshal(map[string]string{"compatibilityLevel": schema.Compatibility})
+	respondOK(w, output)
+}
+
+// RegistryUpdateSubjectConfig (PUT) updates the compatibility level of a subject
+func RegistryUpdateSubjectConfig(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	w.Header().Add("Content-Type", registryContentType)
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+	subject := urlVars["subject"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	body := struct {
+		Compatibility string `json:"compatibility"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		respondRegistryErr(rCTX, w, registryErrInvalidCompatibility())
+		return
+	}
+
+	compatibility, err := schemas.ParseCompatibility(body.Compatibility)
+	if err != nil || compatibility == "" {
+		respondRegistryErr(rCTX, w, registryErrInvalidCompatibility())
+		return
+	}
+
+	schema, regErr := findSubject(rCTX, projectUUID, subject, refStr)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	_, err = schemas.Update(rCTX, schema, "", "", compatibility, nil, nil, refStr)
+	if err != nil {
+		respondRegistryErr(rCTX, w, registryErrBackend(err.Error()))
+		return
+	}
+
+	output, _ := json.Marshal(map[string]string{"compatibility": compatibility})
+	respondOK(w, output)
+}
+
+// RegistryCheckCompatibility (POST) checks a schema against a version of a subject under the subject's compatibility level
+func RegistryCheckCompatibility(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	w.Header().Add("Content-Type", registryContentType)
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+	subject := urlVars["subject"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	schema, regErr := findSubject(rCTX, projectUUID, subject, refStr)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	revision, regErr := findSubjectVersion(rCTX, schema, urlVars["version"], refStr)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	schemaType, rawSchema, regErr := decodeRegistrySchema(r)
+	if regErr != nil {
+		respondRegistryErr(rCTX, w, *regErr)
+		return
+	}
+
+	previous := schemas.Schema{Type: revision.Type, RawSchema: revision.RawSchema, Revision: revision.Revision}
+	next := schemas.Schema{Type: schemaType, RawSchema: rawSchema}
+
+	result := struct {
+		IsCompatible bool     `json:"is_compatible"`
+		Messages     []string `json:"messages,omitempty"`
+	}{IsCompatible: true}
+
+	err := schemas.CheckCompatibility(schema.Compatibility, previous, next)
+	if err != nil {
+		result.IsCompatible = false
+		result.Messages = []string{err.Error()}
+	}
+
+	output, _ := json.Marshal(result)
+	respondOK(w, output)
+}
@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/config"
+	oldPush "github.com/ARGOeu/argo-messaging/push"
+	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
+	"github.com/ARGOeu/argo-messaging/schemas"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type RegistryHandlersTestSuite struct {
+	suite.Suite
+	cfgStr string
+}
+
+func (suite *RegistryHandlersTestSuite) SetupTest() {
+	suite.cfgStr = `{
+	"bind_ip":"",
+	"port":8080,
+	"zookeeper_hosts":["localhost"],
+	"kafka_znode":"",
+	"store_host":"localhost",
+	"store_db":"argo_msg",
+	"certificate":"/etc/pki/tls/certs/localhost.crt",
+	"certificate_key":"/etc/pki/tls/private/localhost.key",
+	"per_resource_auth":"true",
+	"push_enabled": "true",
+	"push_worker_token": "push_token"
+	}`
+}
+
+func (suite *RegistryHandlersTestSuite) TestRegistry() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	// the schemas of the mock store predate revisions, like the ones the startup migration takes care of
+	_, err := schemas.MigrateRevisions(context.Background(), str)
+	suite.Nil(err)
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+
+	wrap := func(hfn http.HandlerFunc) http.HandlerFunc {
+		return WrapMockAuthConfig(hfn, cfgKafka, &brk, str, &mgr, pc)
+	}
+
+	prefix := "/v1/projects/{project}/registry"
+	router.HandleFunc(prefix+"/subjects", wrap(RegistryListSubjects)).Methods("GET")
+	router.HandleFunc(prefix+"/subjects/{subject}/versions/{version}/schema", wrap(RegistryShowVersionSchema)).Methods("GET")
+	router.HandleFunc(prefix+"/subjects/{subject}/versions/{version}", wrap(RegistryShowVersion)).Methods("GET")
+	router.HandleFunc(prefix+"/subjects/{subject}/versions", wrap(RegistryListVersions)).Methods("GET")
+	router.HandleFunc(prefix+"/subjects/{subject}/versions", wrap(RegistryRegister)).Methods("POST")
+	router.HandleFunc(prefix+"/subjects/{subject}", wrap(RegistryLookup)).Methods("POST")
+	router.HandleFunc(prefix+"/subjects/{subject}", wrap(RegistryDeleteSubject)).Methods("DELETE")
+	router.HandleFunc(prefix+"/schemas/ids/{id}", wrap(RegistryShowSchema)).Methods("GET")
+	router.HandleFunc(prefix+"/schemas/types", wrap(RegistryListTypes)).Methods("GET")
+	router.HandleFunc(prefix+"/config", wrap(RegistryShowConfig)).Methods("GET")
+	router.HandleFunc(prefix+"/config/{subject}", wrap(RegistryShowSubjectConfig)).Methods("GET")
+	router.HandleFunc(prefix+"/config/{subject}", wrap(RegistryUpdateSubjectConfig)).Methods("PUT")
+	router.HandleFunc(prefix+"/compatibility/subjects/{subject}/versions/{version}", wrap(RegistryCheckCompatibility)).Methods("POST")
+
+	// the content of schema-3 as the schema registry returns it, the migration stored it under the id 3
+	userV1 := `{"fields":[{"name":"username","type":"string"},{"name":"phone","type":"int"}],"name":"User","namespace":"user.avro","type":"record"}`
+	userV2 := `{"type":"record","name":"User","namespace":"user.avro","fields":[{"name":"username","type":"string"},{"name":"phone","type":"int"},{"name":"city","type":"string","default":"Athens"}]}`
+	userV3 := `{"type":"record","name":"User","namespace":"user.avro","fields":[{"name":"username","type":"string"},{"name":"phone","type":"int"},{"name":"city","type":"string","default":"Athens"},{"name":"email","type":"string"}]}`
+
+	toBody := func(schemaType, schema string) string {
+		b, _ := json.Marshal(map[string]string{"schemaType": schemaType, "schema": schema})
+		return string(b)
+	}
+
+	type td struct {
+		method             string
+		url                string
+		postBody           string
+		expectedStatusCode int
+		expectedResponse   string
+		msg                string
+	}
+
+	testData := []td{
+		{
+			method:             "GET",
+			url:                "subjects",
+			expectedStatusCode: 200,
+			expectedResponse:   `["schema-1","schema-2","schema-3"]`,
+			msg:                "Case where the subjects of the project are listed",
+		},
+		{
+			method:             "GET",
+			url:                "subjects/schema-3/versions",
+			expectedStatusCode: 200,
+			expectedResponse:   `[1]`,
+			msg:                "Case where the versions of a subject are listed",
+		},
+		{
+			method:             "GET",
+			url:                "subjects/schema-3/versions/latest",
+			expectedStatusCode: 200,
+			expectedResponse:   `{"subject":"schema-3","version":1,"id":3,"schema":` + fmt.Sprintf("%q", userV1) + `}`,
+			msg:                "Case where the latest version of a subject is retrieved",
+		},
+		{
+			method:             "GET",
+			url:                "subjects/schema-3/versions/1/schema",
+			expectedStatusCode: 200,
+			expectedResponse:   userV1,
+			msg:                "Case where only the definition of a version is retrieved",
+		},
+		{
+			method:             "GET",
+			url:                "subjects/schema-3/versions/2",
+			expectedStatusCode: 404,
+			expectedResponse:   `{"error_code":40402,"message":"Version 2 not found."}`,
+			msg:                "Case where the version doesn't exist",
+		},
+		{
+			method:             "GET",
+			url:                "subjects/schema-3/versions/first",
+			expectedStatusCode: 422,
+			expectedResponse:   `{"error_code":42202,"message":"The specified version 'first' is not a valid version id. Allowed values are between [1, 2^31-1] and the string \"latest\""}`,
+			msg:                "Case where the version is not valid",
+		},
+		{
+			method:             "GET",
+			url:                "subjects/unknown/versions",
+			expectedStatusCode: 404,
+			expectedResponse:   `{"error_code":40401,"message":"Subject 'unknown' not found."}`,
+			msg:                "Case where the subject doesn't exist",
+		},
+		{
+			method:             "POST",
+			url:                "subjects/schema-3/versions",
+			postBody:           toBody("", `{"type":"record","name":"User","namespace":"user.avro","fields":[{"name":"username","type":"string"},{"name":"phone","type":"int"}]}`),
+			expectedStatusCode: 200,
+			expectedResponse:   `{"id":3}`,
+			msg:                "Case where an existing version is registered again",
+		},
+		{
+			method:             "POST",
+			url:                "subjects/schema-3/versions",
+			postBody:           toBody("AVRO", userV2),
+			expectedStatusCode: 200,
+			expectedResponse:   `{"id":4}`,
+			msg:                "Case where a new version is registered",
+		},
+		{
+			method:             "PUT",
+			url:                "config/schema-3",
+			postBody:           `{"compatibility":"backward"}`,
+			expectedStatusCode: 200,
+			expectedResponse:   `{"compatibility":"BACKWARD"}`,
+			msg:                "Case where the compatibility level of a subject is updated",
+		},
+		{
+			method:             "PUT",
+			url:                "config/schema-3",
+			postBody:           `{"compatibility":"BACKWARD_TRANSITIVE"}`,
+			expectedStatusCode: 422,
+			expectedResponse:   `{"error_code":42203,"message":"Invalid compatibility level. Valid values are none, backward, forward and full"}`,
+			msg:                "Case where the compatibility level is not supported",
+		},
+		{
+			method:             "GET",
+			url:                "config/schema-3",
+			expectedStatusCode: 200,
+			expectedResponse:   `{"compatibilityLevel":"BACKWARD"}`,
+			msg:                "Case where the compatibility level of a subject is retrieved",
+		},
+		{
+			method:             "POST",
+			url:                "compatibility/subjects/schema-3/versions/latest",
+			postBody:           toBody("AVRO", userV3),
+			expectedStatusCode: 200,
+			expectedResponse:   `{"is_compatible":false,"messages":["Schema is not backward compatible with revision 2, $: field 'email' is missing and has no default value"]}`,
+			msg:                "Case where a schema is checked against the latest version",
+		},
+		{
+			method:             "POST",
+			url:                "compatibility/subjects/schema-3/versions/1",
+			postBody:           toBody("AVRO", userV2),
+			expectedStatusCode: 200,
+			expectedResponse:   `{"is_compatible":true}`,
+			msg:                "Case where a schema is checked against a specific version",
+		},
+		{
+			method:             "POST",
+			url:                "subjects/schema-3/versions",
+			postBody:           toBody("AVRO", userV3),
+			expectedStatusCode: 409,
+			expectedResponse:   `{"error_code":409,"message":"Schema being registered is incompatible with an earlier schema for subject \"schema-3\", details: Schema is not backward compatible with revision 2, $: field 'email' is missing and has no default value"}`,
+			msg:                "Case where an incompatible version is registered",
+		},
+		{
+			method:             "POST",
+			url:                "subjects/schema-3/versions",
+			postBody:           toBody("XML", "<schema/>"),
+			expectedStatusCode: 422,
+			expectedResponse:   `{"error_code":42201,"message":"Invalid schema: schema type XML is not supported"}`,
+			msg:                "Case where the schema type is not supported",
+		},
+		{
+			method:             "POST",
+			url:                "subjects/schema-3/versions",
+			postBody:           toBody("JSON", `"string"`),
+			expectedStatusCode: 422,
+			expectedResponse:   `{"error_code":42201,"message":"Invalid schema: schema should be a json object"}`,
+			msg:                "Case where the schema is not an object",
+		},
+		{
+			method:             "POST",
+			url:                "subjects/schema-3",
+			postBody:           toBody("", userV1),
+			expectedStatusCode: 200,
+			expectedResponse:   `{"subject":"schema-3","version":1,"id":3,"schema":` + fmt.Sprintf("%q", userV1) + `}`,
+			msg:                "Case where a schema is looked up under a subject",
+		},
+		{
+			method:             "POST",
+			url:                "subjects/schema-3",
+			postBody:           toBody("AVRO", userV3),
+			expectedStatusCode: 404,
+			expectedResponse:   `{"error_code":40403,"message":"Schema not found"}`,
+			msg:                "Case where a schema is not a version of the subject",
+		},
+		{
+			method:             "GET",
+			url:                "schemas/ids/3",
+			expectedStatusCode: 200,
+			expectedResponse:   `{"schema":` + fmt.Sprintf("%q", userV1) + `}`,
+			msg:                "Case where a schema is retrieved by its id",
+		},
+		{
+			method:             "GET",
+			url:                "schemas/ids/99",
+			expectedStatusCode: 404,
+			expectedResponse:   `{"error_code":40403,"message":"Schema not found"}`,
+			msg:                "Case where the schema id doesn't exist",
+		},
+		{
+			method:             "GET",
+			url:                "schemas/types",
+			expectedStatusCode: 200,
+			expectedResponse:   `["AVRO","JSON","PROTOBUF"]`,
+			msg:                "Case where the supported schema types are listed",
+		},
+		{
+			method:             "GET",
+			url:                "config",
+			expectedStatusCode: 200,
+			expectedResponse:   `{"compatibilityLevel":"NONE"}`,
+			msg:                "Case where the default compatibility level is retrieved",
+		},
+		{
+			method:             "DELETE",
+			url:                "subjects/schema-3",
+			expectedStatusCode: 200,
+			expectedResponse:   `[1,2]`,
+			msg:                "Case where a subject is deleted",
+		},
+		{
+			method:             "GET",
+			url:                "schemas/ids/3",
+			expectedStatusCode: 404,
+			expectedResponse:   `{"error_code":40403,"message":"Schema not found"}`,
+			msg:                "Case where the schema of a deleted subject is retrieved by its id",
+		},
+	}
+
+	for _, t := range testData {
+
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("http://localhost:8080/v1/projects/ARGO/registry/%v", t.url)
+		req, err := http.NewRequest(t.method, url, strings.NewReader(t.postBody))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router.ServeHTTP(w, req)
+
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+		suite.Equal("application/vnd.schemaregistry.v1+json", w.Header().Get("Content-Type"), t.msg)
+	}
+
+	// protobuf schemas use the first message type of the registered source
+	source := "syntax = \"proto3\";\npackage orders;\n\nmessage Order {\n  string id = 1;\n}\n\nmessage Item {\n  string sku = 1;\n}\n"
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/registry/subjects/orders-value/versions",
+		strings.NewReader(toBody("PROTOBUF", source)))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	registered := map[string]int64{}
+	suite.Nil(json.Unmarshal(w.Body.Bytes(), &registered))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("http://localhost:8080/v1/projects/ARGO/registry/schemas/ids/%d", registered["id"]), nil)
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{"schemaType":"PROTOBUF","schema":`+fmt.Sprintf("%q", source)+`}`, w.Body.String())
+
+	qSchemas, _ := str.QuerySchemas(context.Background(), "argo_uuid", "", "orders-value")
+	suite.Equal(1, len(qSchemas))
+	suite.Equal("protobuf", qSchemas[0].Type)
+}
+
+func (suite *RegistryHandlersTestSuite) TestBasicAuthKeyExtract() {
+
+	extract := BasicAuthKeyExtract(HeaderKeyExtract)
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/registry/subjects", nil)
+	req.SetBasicAuth("ams", "S3CR3T")
+	req.Header.Set("x-api-key", "other")
+	suite.Equal("S3CR3T", extract(req))
+
+	// without basic authentication the wrapped strategy is used
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/registry/subjects", nil)
+	req.Header.Set("x-api-key", "other")
+	suite.Equal("other", extract(req))
+}
+
+func TestRegistryHandlersTestSuite(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(RegistryHandlersTestSuite))
+}
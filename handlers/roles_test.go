@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/config"
+	oldPush "github.com/ARGOeu/argo-messaging/push"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+)
+
+type RolesHandlersTestSuite struct {
+	suite.Suite
+	cfgStr string
+	routes []string
+}
+
+func (suite *RolesHandlersTestSuite) SetupTest() {
+	suite.cfgStr = `{
+	"bind_ip":"",
+	"port":8080,
+	"zookeeper_hosts":["localhost"],
+	"kafka_znode":"",
+	"store_host":"localhost",
+	"store_db":"argo_msg",
+	"certificate":"/etc/pki/tls/certs/localhost.crt",
+	"certificate_key":"/etc/pki/tls/private/localhost.key",
+	"per_resource_auth":"true"
+	}`
+	suite.routes = []string{"topics:list_all", "topics:publish", "ams:metrics"}
+}
+
+func (suite *RolesHandlersTestSuite) router(str stores.Store) *mux.Router {
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/roles", WrapMockAuthConfig(WrapRoutes(RoleListAll, suite.routes), cfgKafka, &brk, str, &mgr, nil)).Methods("GET")
+	router.HandleFunc("/v1/roles/{role}", WrapMockAuthConfig(WrapRoutes(RoleListOne, suite.routes), cfgKafka, &brk, str, &mgr, nil)).Methods("GET")
+	router.HandleFunc("/v1/roles/{role}", WrapMockAuthConfig(WrapRoutes(RoleCreate, suite.routes), cfgKafka, &brk, str, &mgr, nil)).Methods("POST")
+	router.HandleFunc("/v1/roles/{role}", WrapMockAuthConfig(WrapRoutes(RoleUpdate, suite.routes), cfgKafka, &brk, str, &mgr, nil)).Methods("PUT")
+	router.HandleFunc("/v1/roles/{role}", WrapMockAuthConfig(WrapRoutes(RoleDelete, suite.routes), cfgKafka, &brk, str, &mgr, nil)).Methods("DELETE")
+	return router
+}
+
+func (suite *RolesHandlersTestSuite) TestRoleListAll() {
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/roles", nil)
+	w := httptest.NewRecorder()
+	suite.router(stores.NewMockStore("whatever", "argo_mgs")).ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "roles": [
+      {
+         "name": "admin",
+         "routes": [
+            "topics:list_all",
+            "topics:publish"
+         ]
+      },
+      {
+         "name": "publisher",
+         "routes": [
+            "topics:list_all",
+            "topics:publish"
+         ]
+      },
+      {
+         "name": "reader",
+         "routes": [
+            "topics:list_all"
+         ]
+      }
+   ]
+}`, w.Body.String())
+}
+
+func (suite *RolesHandlersTestSuite) TestRoleListOne() {
+
+	router := suite.router(stores.NewMockStore("whatever", "argo_mgs"))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/roles/reader", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "name": "reader",
+   "routes": [
+      "topics:list_all"
+   ]
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/roles/unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 404,
+      "message": "Role doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`, w.Body.String())
+}
+
+func (suite *RolesHandlersTestSuite) TestRoleCreate() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := suite.router(str)
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/roles/metrics_viewer",
+		bytes.NewBuffer([]byte(`{"routes": ["ams:metrics"]}`)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "name": "metrics_viewer",
+   "routes": [
+      "ams:metrics"
+   ]
+}`, w.Body.String())
+	suite.True(str.HasResourceRoles(context.Background(), "ams:metrics", []string{"metrics_viewer"}))
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/roles/metrics_viewer",
+		bytes.NewBuffer([]byte(`{"routes": ["ams:metrics"]}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(409, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 409,
+      "message": "Role already exists",
+      "status": "ALREADY_EXISTS"
+   }
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/roles/other",
+		bytes.NewBuffer([]byte(`{"routes": ["ams:unknown"]}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 400,
+      "message": "invalid route: ams:unknown",
+      "status": "INVALID_ARGUMENT"
+   }
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/roles/other",
+		bytes.NewBuffer([]byte(`{"routes": "ams:metrics"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+}
+
+func (suite *RolesHandlersTestSuite) TestRoleUpdate() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := suite.router(str)
+
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/roles/reader",
+		bytes.NewBuffer([]byte(`{"routes": ["ams:metrics", "topics:publish"]}`)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "name": "reader",
+   "routes": [
+      "ams:metrics",
+      "topics:publish"
+   ]
+}`, w.Body.String())
+	suite.False(str.HasResourceRoles(context.Background(), "topics:list_all", []string{"reader"}))
+
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/roles/unknown",
+		bytes.NewBuffer([]byte(`{"routes": ["ams:metrics"]}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/roles/service_admin",
+		bytes.NewBuffer([]byte(`{"routes": ["ams:metrics"]}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 400,
+      "message": "invalid role: service_admin cannot be modified",
+      "status": "INVALID_ARGUMENT"
+   }
+}`, w.Body.String())
+}
+
+func (suite *RolesHandlersTestSuite) TestRoleDelete() {
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := suite.router(str)
+
+	req, _ := http.NewRequest("DELETE", "http://localhost:8080/v1/roles/reader", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("", w.Body.String())
+	suite.False(str.HasResourceRoles(context.Background(), "topics:list_all", []string{"reader"}))
+
+	req, _ = http.NewRequest("DELETE", "http://localhost:8080/v1/roles/reader", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+}
+
+func TestRolesHandlersTestSuite(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(RolesHandlersTestSuite))
+}
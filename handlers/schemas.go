@@ -12,7 +12,9 @@ import (
 	gorillaContext "github.com/gorilla/context"
 	"github.com/gorilla/mux"
 	"github.com/twinj/uuid"
+	"io/ioutil"
 	"net/http"
+	"strconv"
 )
 
 // SchemaCreate (POST) handles the creation of a new schema
@@ -46,7 +48,7 @@ func SchemaCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	schema, err = schemas.Create(rCTX, projectUUID, schemaUUID, schemaName, schema.Type, schema.RawSchema, refStr)
+	schema, err = schemas.Create(rCTX, projectUUID, schemaUUID, schemaName, schema.Type, schema.Compatibility, schema.RawSchema, schema.References, refStr)
 	if err != nil {
 		if err.Error() == "exists" {
 			err := APIErrorConflict("Schema")
@@ -62,6 +64,18 @@ func SchemaCreate(w http.ResponseWriter, r *http.Request) {
 
 		}
 
+		if err.Error() == "unsupported compatibility" {
+			err := APIErrorInvalidData(schemas.UnsupportedCompatibilityError)
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		if err.Error() == "revision conflict" {
+			err := APIErrorGenericConflict(schemas.RevisionConflictError)
+			respondErr(rCTX, w, err)
+			return
+		}
+
 		err := APIErrorInvalidData(err.Error())
 		respondErr(rCTX, w, err)
 		return
@@ -184,7 +198,7 @@ func SchemaUpdate(w http.ResponseWriter, r *http.Request) {
 		updatedSchema.Name = schemaName
 	}
 
-	schema, err := schemas.Update(rCTX, schemasList.Schemas[0], updatedSchema.Name, updatedSchema.Type, updatedSchema.RawSchema, refStr)
+	schema, err := schemas.Update(rCTX, schemasList.Schemas[0], updatedSchema.Name, updatedSchema.Type, updatedSchema.Compatibility, updatedSchema.RawSchema, updatedSchema.References, refStr)
 	if err != nil {
 		if err.Error() == "exists" {
 			err := APIErrorConflict("Schema")
@@ -200,6 +214,185 @@ func SchemaUpdate(w http.ResponseWriter, r *http.Request) {
 
 		}
 
+		if err.Error() == "unsupported compatibility" {
+			err := APIErrorInvalidData(schemas.UnsupportedCompatibilityError)
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		if err.Error() == "revision conflict" {
+			err := APIErrorGenericConflict(schemas.RevisionConflictError)
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		err := APIErrorInvalidData(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	output, _ := json.MarshalIndent(schema, "", " ")
+	respondOK(w, output)
+}
+
+// SchemaListRevisions (GET) retrieves all the revisions of the given schema
+func SchemaListRevisions(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+	schemaName := urlVars["schema"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+	schemasList, err := schemas.Find(rCTX, projectUUID, "", schemaName, refStr)
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	if schemasList.Empty() {
+		err := APIErrorNotFound("Schema")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	revisionList, err := schemas.FindRevisions(rCTX, schemasList.Schemas[0], 0, refStr)
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	output, _ := json.MarshalIndent(revisionList, "", " ")
+	respondOK(w, output)
+}
+
+// SchemaListOneRevision (GET) retrieves a specific revision of the given schema
+func SchemaListOneRevision(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+	schemaName := urlVars["schema"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	revision, err := strconv.ParseInt(urlVars["revision"], 10, 64)
+	if err != nil || revision <= 0 {
+		err := APIErrorInvalidArgument("Revision")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	schemasList, err := schemas.Find(rCTX, projectUUID, "", schemaName, refStr)
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	if schemasList.Empty() {
+		err := APIErrorNotFound("Schema")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	revisionList, err := schemas.FindRevisions(rCTX, schemasList.Schemas[0], revision, refStr)
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	if revisionList.Empty() {
+		err := APIErrorNotFound("Schema revision")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	output, _ := json.MarshalIndent(revisionList.Revisions[0], "", " ")
+	respondOK(w, output)
+}
+
+// SchemaRollback (POST) makes a past revision of the given schema its current one, by creating a new revision
+func SchemaRollback(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+	schemaName := urlVars["schema"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	postBody := struct {
+		Revision int64 `json:"revision"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&postBody)
+	if err != nil || postBody.Revision <= 0 {
+		err := APIErrorInvalidArgument("Revision")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	schemasList, err := schemas.Find(rCTX, projectUUID, "", schemaName, refStr)
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	if schemasList.Empty() {
+		err := APIErrorNotFound("Schema")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	schema, err := schemas.Rollback(rCTX, schemasList.Schemas[0], postBody.Revision, refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Schema revision")
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		if err.Error() == "revision conflict" {
+			err := APIErrorGenericConflict(schemas.RevisionConflictError)
+			respondErr(rCTX, w, err)
+			return
+		}
+
 		err := APIErrorInvalidData(err.Error())
 		respondErr(rCTX, w, err)
 		return
@@ -241,8 +434,14 @@ func SchemaDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = schemas.Delete(rCTX, schemasList.Schemas[0].UUID, refStr)
+	err = schemas.Delete(rCTX, projectUUID, schemasList.Schemas[0].UUID, refStr)
 	if err != nil {
+		if err.Error() == "referenced" {
+			err := APIErrorGenericConflict("Schema is referenced by other schemas of the project")
+			respondErr(rCTX, w, err)
+			return
+		}
+
 		err := APIErrGenericInternal(err.Error())
 		respondErr(rCTX, w, err)
 		return
@@ -301,7 +500,7 @@ func SchemaValidateMessage(w http.ResponseWriter, r *http.Request) {
 
 		msgList.Msgs = append(msgList.Msgs, msg)
 
-	case schemas.AVRO:
+	case schemas.AVRO, schemas.PROTOBUF:
 
 		body := map[string]string{}
 		err := json.Unmarshal(buf.Bytes(), &body)
@@ -345,3 +544,66 @@ func SchemaValidateMessage(w http.ResponseWriter, r *http.Request) {
 
 	respondOK(w, res)
 }
+
+// SchemaValidateMessages (POST) validates a list of messages against the schema and reports the outcome of each message
+func SchemaValidateMessages(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+	schemaName := urlVars["schema"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+	schemasList, err := schemas.Find(rCTX, projectUUID, "", schemaName, refStr)
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	if schemasList.Empty() {
+		err := APIErrorNotFound("Schema")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	msgList, err := messages.LoadMsgListJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Message")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	report, err := schemas.ValidateMessagesReport(schemasList.Schemas[0], msgList)
+	if err != nil {
+		err := APIErrGenericInternal(schemas.GenericError)
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	output, err := json.MarshalIndent(report, "", " ")
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
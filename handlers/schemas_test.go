@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/brokers"
@@ -18,6 +20,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 type SchemasHandlersTestSuite struct {
@@ -67,7 +70,9 @@ func (suite *SchemasHandlersTestSuite) TestSchemaCreate() {
  "type": "json",
  "schema": {
   "type": "string"
- }
+ },
+ "revision": 1,
+ "compatibility": "NONE"
 }`,
 			msg: "Case where the schema is valid and successfully created(JSON)",
 		},
@@ -104,7 +109,9 @@ func (suite *SchemasHandlersTestSuite) TestSchemaCreate() {
   "name": "User",
   "namespace": "user.avro",
   "type": "record"
- }
+ },
+ "revision": 1,
+ "compatibility": "NONE"
 }`,
 			msg: "Case where the schema is valid and successfully created(AVRO)",
 		},
@@ -120,7 +127,7 @@ func (suite *SchemasHandlersTestSuite) TestSchemaCreate() {
 			expectedResponse: `{
    "error": {
       "code": 400,
-      "message": "Schema type can only be 'json' or 'avro'",
+      "message": "Schema type can only be 'json', 'avro' or 'protobuf'",
       "status": "INVALID_ARGUMENT"
    }
 }`,
@@ -252,7 +259,9 @@ func (suite *SchemasHandlersTestSuite) TestSchemaListOne() {
    "email"
   ],
   "type": "object"
- }
+ },
+ "revision": 1,
+ "compatibility": "NONE"
 }`,
 			msg: "Case where a specific schema is retrieved successfully",
 		},
@@ -335,7 +344,9 @@ func (suite *SchemasHandlersTestSuite) TestSchemaListAll() {
      "email"
     ],
     "type": "object"
-   }
+   },
+   "revision": 1,
+   "compatibility": "NONE"
   },
   {
    "uuid": "schema_uuid_2",
@@ -361,7 +372,9 @@ func (suite *SchemasHandlersTestSuite) TestSchemaListAll() {
      "email"
     ],
     "type": "object"
-   }
+   },
+   "revision": 1,
+   "compatibility": "NONE"
   },
   {
    "uuid": "schema_uuid_3",
@@ -381,7 +394,9 @@ func (suite *SchemasHandlersTestSuite) TestSchemaListAll() {
     "name": "User",
     "namespace": "user.avro",
     "type": "record"
-   }
+   },
+   "revision": 1,
+   "compatibility": "NONE"
   }
  ]
 }`,
@@ -454,7 +469,7 @@ func (suite *SchemasHandlersTestSuite) TestSchemaUpdate() {
 			expectedResponse: `{
    "error": {
       "code": 400,
-      "message": "Schema type can only be 'json' or 'avro'",
+      "message": "Schema type can only be 'json', 'avro' or 'protobuf'",
       "status": "INVALID_ARGUMENT"
    }
 }`,
@@ -501,7 +516,9 @@ func (suite *SchemasHandlersTestSuite) TestSchemaUpdate() {
    "address"
   ],
   "type": "object"
- }
+ },
+ "revision": 2,
+ "compatibility": "NONE"
 }`,
 			postBody: `{
  "name": "projects/ARGO/schemas/new-name",
@@ -573,6 +590,267 @@ func (suite *SchemasHandlersTestSuite) TestSchemaUpdate() {
 	}
 }
 
+func (suite *SchemasHandlersTestSuite) TestSchemaRevisions() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	// the schemas of the mock store were created before revisions existed
+	schemas.MigrateRevisions(context.Background(), str)
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+
+	router.HandleFunc("/v1/projects/{project}/schemas/{schema}:rollback", WrapMockAuthConfig(SchemaRollback, cfgKafka, &brk, str, &mgr, pc))
+	router.HandleFunc("/v1/projects/{project}/schemas/{schema}/revisions", WrapMockAuthConfig(SchemaListRevisions, cfgKafka, &brk, str, &mgr, pc))
+	router.HandleFunc("/v1/projects/{project}/schemas/{schema}/revisions/{revision}", WrapMockAuthConfig(SchemaListOneRevision, cfgKafka, &brk, str, &mgr, pc))
+	router.HandleFunc("/v1/projects/{project}/schemas/{schema}", WrapMockAuthConfig(SchemaUpdate, cfgKafka, &brk, str, &mgr, pc))
+
+	type td struct {
+		method             string
+		url                string
+		postBody           string
+		expectedStatusCode int
+		expectedResponse   string
+		msg                string
+	}
+
+	testData := []td{
+		{
+			method:             "PUT",
+			url:                "schemas/schema-3",
+			postBody:           `{"compatibility":"FULL","schema":{"type":"record","name":"User","namespace":"user.avro","fields":[{"name":"username","type":"string"},{"name":"phone","type":"int"},{"name":"city","type":"string","default":"Athens"}]}}`,
+			expectedStatusCode: 200,
+			expectedResponse: `{
+ "uuid": "schema_uuid_3",
+ "name": "projects/ARGO/schemas/schema-3",
+ "type": "avro",
+ "schema": {
+  "fields": [
+   {
+    "name": "username",
+    "type": "string"
+   },
+   {
+    "name": "phone",
+    "type": "int"
+   },
+   {
+    "default": "Athens",
+    "name": "city",
+    "type": "string"
+   }
+  ],
+  "name": "User",
+  "namespace": "user.avro",
+  "type": "record"
+ },
+ "revision": 2,
+ "compatibility": "FULL"
+}`,
+			msg: "Case where a compatible revision is created along with a compatibility mode",
+		},
+		{
+			method:             "PUT",
+			url:                "schemas/schema-3",
+			postBody:           `{"schema":{"type":"record","name":"User","namespace":"user.avro","fields":[{"name":"username","type":"string"},{"name":"phone","type":"long"}]}}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Schema is not forward compatible with revision 2, $.phone: type long cannot be read as int",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Case where an incompatible revision is rejected",
+		},
+		{
+			method:             "PUT",
+			url:                "schemas/schema-3",
+			postBody:           `{"compatibility":"sometimes"}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Compatibility mode can only be one of BACKWARD, FORWARD, FULL or NONE",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Case where an unknown compatibility mode is given",
+		},
+		{
+			method:             "GET",
+			url:                "schemas/schema-3/revisions/1",
+			expectedStatusCode: 200,
+			expectedResponse: `{
+ "id": 3,
+ "revision": 1,
+ "type": "avro",
+ "schema": {
+  "fields": [
+   {
+    "name": "username",
+    "type": "string"
+   },
+   {
+    "name": "phone",
+    "type": "int"
+   }
+  ],
+  "name": "User",
+  "namespace": "user.avro",
+  "type": "record"
+ },
+ "created_on": "{{CREATED_ON}}"
+}`,
+			msg: "Case where a specific revision is retrieved",
+		},
+		{
+			method:             "GET",
+			url:                "schemas/schema-3/revisions/5",
+			expectedStatusCode: 404,
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "Schema revision doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			msg: "Case where the requested revision doesn't exist",
+		},
+		{
+			method:             "GET",
+			url:                "schemas/schema-3/revisions/first",
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Invalid Revision Arguments",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Case where the requested revision is not a number",
+		},
+		{
+			method:             "POST",
+			url:                "schemas/schema-3:rollback",
+			postBody:           `{"revision": 1}`,
+			expectedStatusCode: 200,
+			expectedResponse: `{
+ "uuid": "schema_uuid_3",
+ "name": "projects/ARGO/schemas/schema-3",
+ "type": "avro",
+ "schema": {
+  "fields": [
+   {
+    "name": "username",
+    "type": "string"
+   },
+   {
+    "name": "phone",
+    "type": "int"
+   }
+  ],
+  "name": "User",
+  "namespace": "user.avro",
+  "type": "record"
+ },
+ "revision": 3,
+ "compatibility": "FULL"
+}`,
+			msg: "Case where the schema is rolled back to its first revision",
+		},
+		{
+			method:             "POST",
+			url:                "schemas/schema-3:rollback",
+			postBody:           `{"revision": 9}`,
+			expectedStatusCode: 404,
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "Schema revision doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			msg: "Case where the schema is rolled back to an unknown revision",
+		},
+		{
+			method:             "POST",
+			url:                "schemas/unknown:rollback",
+			postBody:           `{"revision": 1}`,
+			expectedStatusCode: 404,
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "Schema doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			msg: "Case where the schema doesn't exist",
+		},
+	}
+
+	for _, t := range testData {
+
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("http://localhost:8080/v1/projects/ARGO/%v", t.url)
+		req, err := http.NewRequest(t.method, url, strings.NewReader(t.postBody))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router.ServeHTTP(w, req)
+
+		if len(str.SchemaRevisions) > 0 {
+			t.expectedResponse = strings.Replace(t.expectedResponse, "{{CREATED_ON}}",
+				str.SchemaRevisions[0].CreatedOn.Format("2006-01-02T15:04:05Z"), 1)
+		}
+
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+	}
+
+	// list all the revisions
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/schemas/schema-3/revisions", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	rl := schemas.RevisionList{}
+	suite.Nil(json.Unmarshal(w.Body.Bytes(), &rl))
+	suite.Equal(3, len(rl.Revisions))
+	for idx, rev := range rl.Revisions {
+		suite.Equal(int64(idx+1), rev.Revision)
+		suite.Equal("avro", rev.Type)
+	}
+	suite.Equal(rl.Revisions[0].RawSchema, rl.Revisions[2].RawSchema)
+
+	// a revision that another update stored while this one was in progress
+	raw := base64.StdEncoding.EncodeToString([]byte(`{"type":"record","name":"User","namespace":"user.avro","fields":[{"name":"username","type":"string"},{"name":"phone","type":"long"}]}`))
+	suite.Nil(str.InsertSchemaRevision(context.Background(), "argo_uuid", "schema_uuid_3", 4, "avro", raw, nil, time.Now().UTC()))
+
+	for _, t := range []struct {
+		postBody           string
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{`{"revision": 4}`, 400, "Schema is not forward compatible with revision 3, $.phone: type long cannot be read as int"},
+		{`{"revision": 2}`, 409, "Schema has been changed by another request, please retry"},
+	} {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/schemas/schema-3:rollback", strings.NewReader(t.postBody))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code)
+		suite.Contains(w.Body.String(), t.expectedMessage)
+	}
+}
+
 func (suite *SchemasHandlersTestSuite) TestSchemaDelete() {
 
 	type td struct {
@@ -767,6 +1045,329 @@ func (suite *SchemasHandlersTestSuite) TestSchemaValidateMessage() {
 	}
 }
 
+func (suite *SchemasHandlersTestSuite) TestSchemaValidateMessages() {
+
+	type td struct {
+		postBody           string
+		schemaName         string
+		expectedStatusCode int
+		expectedResponse   string
+		msg                string
+	}
+
+	testData := []td{
+		{
+			// {"name":"name-1", "email": "test@example.com"} and {"name":"name-1","address":"Street 13","telephone":6948567889}
+			postBody:           `{"messages":[{"data":"eyJuYW1lIjoibmFtZS0xIiwgImVtYWlsIjogInRlc3RAZXhhbXBsZS5jb20ifQ=="},{"data":"eyJuYW1lIjoibmFtZS0xIiwiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6Njk0ODU2Nzg4OX0="}]}`,
+			schemaName:         "schema-1",
+			expectedStatusCode: 200,
+			expectedResponse: `{
+ "valid": false,
+ "messages": [
+  {
+   "index": 0,
+   "valid": true
+  },
+  {
+   "index": 1,
+   "valid": false,
+   "errors": [
+    {
+     "path": "$.email",
+     "message": "email is required"
+    },
+    {
+     "path": "$.telephone",
+     "message": "Invalid type. Expected: string, given: integer"
+    }
+   ]
+  }
+ ]
+}`,
+			msg: "Report the outcome of each message(JSON)",
+		},
+		{
+			// username: agelos, phone: 89890
+			postBody:           `{"messages":[{"data":"DGFnZWxvc8T8Cg=="}]}`,
+			schemaName:         "schema-3",
+			expectedStatusCode: 200,
+			expectedResponse: `{
+ "valid": true,
+ "messages": [
+  {
+   "index": 0,
+   "valid": true
+  }
+ ]
+}`,
+			msg: "Report the outcome of each message(AVRO)",
+		},
+		{
+			postBody:           `{"messages":"unknown"}`,
+			schemaName:         "schema-1",
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Invalid Message Arguments",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Case where the request body is not a message list",
+		},
+		{
+			postBody:           `{"messages":[]}`,
+			schemaName:         "unknown",
+			expectedStatusCode: 404,
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "Schema doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			msg: "Case where the schema doesn't exist",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+	router.HandleFunc("/v1/projects/{project}/schemas/{schema}:validateMessages", WrapMockAuthConfig(SchemaValidateMessages, cfgKafka, &brk, str, &mgr, pc))
+
+	for _, t := range testData {
+
+		w := httptest.NewRecorder()
+
+		url := fmt.Sprintf("http://localhost:8080/v1/projects/ARGO/schemas/%v:validateMessages", t.schemaName)
+
+		req, err := http.NewRequest("POST", url, strings.NewReader(t.postBody))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router.ServeHTTP(w, req)
+
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+	}
+}
+
+func (suite *SchemasHandlersTestSuite) TestSchemaProtobuf() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+
+	router.HandleFunc("/v1/projects/{project}/schemas/{schema}:validate", WrapMockAuthConfig(SchemaValidateMessage, cfgKafka, &brk, str, &mgr, pc))
+	router.HandleFunc("/v1/projects/{project}/schemas/{schema}", WrapMockAuthConfig(SchemaCreate, cfgKafka, &brk, str, &mgr, pc)).Methods("POST")
+
+	type td struct {
+		url                string
+		postBody           map[string]interface{}
+		expectedStatusCode int
+		expectedResponse   string
+		msg                string
+	}
+
+	testData := []td{
+		{
+			url: "http://localhost:8080/v1/projects/ARGO/schemas/schema-proto",
+			postBody: map[string]interface{}{
+				"type": "protobuf",
+				"schema": map[string]interface{}{
+					"message_type": "user.proto.User",
+					"proto":        "syntax = \"proto3\";\npackage user.proto;\nmessage User { string username = 1; int32 phone = 2; }",
+				},
+			},
+			expectedStatusCode: 200,
+			msg:                "Case where a protobuf schema is created from its .proto source",
+		},
+		{
+			url: "http://localhost:8080/v1/projects/ARGO/schemas/schema-proto-invalid",
+			postBody: map[string]interface{}{
+				"type": "protobuf",
+				"schema": map[string]interface{}{
+					"message_type": "user.proto.Unknown",
+					"proto":        "syntax = \"proto3\";\npackage user.proto;\nmessage User { string username = 1; }",
+				},
+			},
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "message type user.proto.Unknown is not defined",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Case where the protobuf message type is not defined",
+		},
+		{
+			// username: joe, phone: 5
+			url:                "http://localhost:8080/v1/projects/ARGO/schemas/schema-proto:validate",
+			postBody:           map[string]interface{}{"data": "CgNqb2UQBQ=="},
+			expectedStatusCode: 200,
+			expectedResponse: `{
+ "message": "Message validated successfully"
+}`,
+			msg: "Case where the message is successfully validated(PROTOBUF)",
+		},
+		{
+			// username: joe, phone: 5, 4: 1
+			url:                "http://localhost:8080/v1/projects/ARGO/schemas/schema-proto:validate",
+			postBody:           map[string]interface{}{"data": "CgNqb2UQBSAB"},
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Message 0 is not valid.user.proto.User contains unknown fields",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Case where the message contains unknown fields(PROTOBUF)",
+		},
+	}
+
+	for _, t := range testData {
+
+		w := httptest.NewRecorder()
+
+		body, _ := json.Marshal(t.postBody)
+
+		req, err := http.NewRequest("POST", t.url, bytes.NewReader(body))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router.ServeHTTP(w, req)
+
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		if t.expectedResponse != "" {
+			suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+		}
+	}
+}
+
+func (suite *SchemasHandlersTestSuite) TestSchemaReferences() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	// the schemas of the mock store were created before revisions existed
+	schemas.MigrateRevisions(context.Background(), str)
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+
+	router.HandleFunc("/v1/projects/{project}/schemas/{schema}:validate", WrapMockAuthConfig(SchemaValidateMessage, cfgKafka, &brk, str, &mgr, pc))
+	router.HandleFunc("/v1/projects/{project}/schemas/{schema}", WrapMockAuthConfig(SchemaCreate, cfgKafka, &brk, str, &mgr, pc)).Methods("POST")
+	router.HandleFunc("/v1/projects/{project}/schemas/{schema}", WrapMockAuthConfig(SchemaDelete, cfgKafka, &brk, str, &mgr, pc)).Methods("DELETE")
+
+	type td struct {
+		method             string
+		url                string
+		postBody           map[string]interface{}
+		expectedStatusCode int
+		expectedResponse   string
+		msg                string
+	}
+
+	testData := []td{
+		{
+			method: "POST",
+			url:    "http://localhost:8080/v1/projects/ARGO/schemas/schema-contact",
+			postBody: map[string]interface{}{
+				"type": "json",
+				"schema": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"contact": map[string]interface{}{"$ref": "contact.json"},
+					},
+				},
+				"references": []interface{}{
+					map[string]interface{}{"name": "contact.json", "schema": "schema-2"},
+				},
+			},
+			expectedStatusCode: 200,
+			msg:                "Case where a schema is created with a reference to another schema",
+		},
+		{
+			method: "POST",
+			url:    "http://localhost:8080/v1/projects/ARGO/schemas/schema-unknown-ref",
+			postBody: map[string]interface{}{
+				"type":   "json",
+				"schema": map[string]interface{}{"type": "object"},
+				"references": []interface{}{
+					map[string]interface{}{"name": "contact.json", "schema": "unknown"},
+				},
+			},
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Referenced schema unknown doesn't exist",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Case where the referenced schema doesn't exist",
+		},
+		{
+			method: "POST",
+			url:    "http://localhost:8080/v1/projects/ARGO/schemas/schema-contact:validate",
+			postBody: map[string]interface{}{
+				"contact": map[string]interface{}{"name": "joe"},
+			},
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Message 0 data is not valid,contact: email is required",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Case where the message is validated against the referenced schema",
+		},
+		{
+			method:             "DELETE",
+			url:                "http://localhost:8080/v1/projects/ARGO/schemas/schema-2",
+			expectedStatusCode: 409,
+			expectedResponse: `{
+   "error": {
+      "code": 409,
+      "message": "Schema is referenced by other schemas of the project",
+      "status": "CONFLICT"
+   }
+}`,
+			msg: "Case where the schema can't be deleted while other schemas reference it",
+		},
+	}
+
+	for _, t := range testData {
+
+		w := httptest.NewRecorder()
+
+		body, _ := json.Marshal(t.postBody)
+
+		req, err := http.NewRequest(t.method, t.url, bytes.NewReader(body))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router.ServeHTTP(w, req)
+
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		if t.expectedResponse != "" {
+			suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+		}
+	}
+}
+
 func TestSchemasHandlersTestSuite(t *testing.T) {
 	log.SetOutput(ioutil.Discard)
 	suite.Run(t, new(SchemasHandlersTestSuite))
@@ -3,12 +3,15 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/messages"
 	"github.com/ARGOeu/argo-messaging/projects"
 	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
+	"github.com/ARGOeu/argo-messaging/quotas"
+	"github.com/ARGOeu/argo-messaging/schemas"
 	"github.com/ARGOeu/argo-messaging/stores"
 	"github.com/ARGOeu/argo-messaging/subscriptions"
 	"github.com/ARGOeu/argo-messaging/topics"
@@ -91,8 +94,51 @@ func SubAck(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// ack ids that precede the subscription's offset can only refer to messages
+	// that were held back and delivered later on, so acknowledge them separately
+	scheduledAckIDs := map[int64]string{}
+	scheduledOffsets := []int64{}
+	streamAckIDs := []string{}
+	for _, ackID := range postBody.IDs {
+		off, err := subscriptions.GetOffsetFromAckID(ackID)
+		if err != nil {
+			err := APIErrorInvalidData("Invalid ack id")
+			respondErr(rCTX, w, err)
+			return
+		}
+		if off < cur_sub.Subscriptions[0].Offset {
+			scheduledAckIDs[off] = ackID
+			scheduledOffsets = append(scheduledOffsets, off)
+			continue
+		}
+		streamAckIDs = append(streamAckIDs, ackID)
+	}
+
+	if len(scheduledOffsets) > 0 {
+		acked, err := refStr.AckScheduledMessages(rCTX, projectUUID, subName, scheduledOffsets)
+		if err != nil {
+			err := APIErrHandlingAcknowledgement()
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		// ack ids that didn't match a delivered scheduled message follow the regular ack path
+		for _, off := range acked {
+			delete(scheduledAckIDs, off)
+		}
+		for _, ackID := range scheduledAckIDs {
+			streamAckIDs = append(streamAckIDs, ackID)
+		}
+
+		if len(streamAckIDs) == 0 {
+			output = []byte("{}")
+			respondOK(w, output)
+			return
+		}
+	}
+
 	// Get Max ackID
-	maxAckID, err := subscriptions.GetMaxAckID(postBody.IDs)
+	maxAckID, err := subscriptions.GetMaxAckID(streamAckIDs)
 	if err != nil {
 		err := APIErrHandlingAcknowledgement()
 		respondErr(rCTX, w, err)
@@ -496,6 +542,14 @@ func SubModACL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// check that the action restrictions refer to the acl and to actions of the resource
+	err = auth.ValidateACLActions("subscriptions", postBody)
+	if err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
 	// Grab context references
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
 	// Get project UUID First to use as reference
@@ -509,7 +563,15 @@ func SubModACL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = auth.ModACL(rCTX, projectUUID, "subscriptions", urlSub, postBody.AuthUsers, refStr)
+	// check if group list contain valid groups for the given project
+	_, err = auth.AreValidGroups(rCTX, projectUUID, postBody.AuthGroups, refStr)
+	if err != nil {
+		err := APIErrorRoot{Body: APIErrorBody{Code: http.StatusNotFound, Message: err.Error(), Status: "NOT_FOUND"}}
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	err = auth.ModACL(rCTX, projectUUID, "subscriptions", urlSub, postBody, refStr)
 
 	if err != nil {
 
@@ -597,6 +659,7 @@ func SubModPush(w http.ResponseWriter, r *http.Request) {
 	mattermostChannel := ""
 	pushType := ""
 	base64Decode := false
+	schemaDecode := false
 
 	if postBody.PushCfg != (subscriptions.PushConfig{}) {
 
@@ -617,6 +680,7 @@ func SubModPush(w http.ResponseWriter, r *http.Request) {
 		}
 
 		base64Decode = postBody.PushCfg.Base64Decode
+		schemaDecode = postBody.PushCfg.SchemaDecode
 
 		rPolicy = postBody.PushCfg.RetPol.PolicyType
 		rPeriod = postBody.PushCfg.RetPol.Period
@@ -778,6 +842,7 @@ func SubModPush(w http.ResponseWriter, r *http.Request) {
 		MattermostUsername: mattermostUsername,
 		MattermostChannel:  mattermostChannel,
 		Base64Decode:       base64Decode,
+		SchemaDecode:       schemaDecode,
 	}
 	err = subscriptions.ModSubPush(rCTX, projectUUID, subName, cfg, refStr)
 
@@ -1021,6 +1086,62 @@ func SubModAck(w http.ResponseWriter, r *http.Request) {
 	respondOK(w, output)
 }
 
+// SubModOutputFormat (POST) modifies the format that the subscription delivers its messages in
+func SubModOutputFormat(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlSub := urlVars["subscription"]
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	postBody, err := subscriptions.GetOutputFormatFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("OutputFormat")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	err = subscriptions.ModOutputFormat(rCTX, projectUUID, urlSub, postBody.OutputFormat, refStr)
+	if err != nil {
+		if err.Error() == "wrong value" {
+			err := APIErrorInvalidData(subscriptions.UnsupportedOutputFormat)
+			respondErr(rCTX, w, err)
+			return
+		}
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Subscription")
+			respondErr(rCTX, w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
 // SubCreate (PUT) creates a new subscription
 func SubCreate(w http.ResponseWriter, r *http.Request) {
 	traceId := gorillaContext.Get(r, "trace_id").(string)
@@ -1072,6 +1193,17 @@ func SubCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if postBody.OutputFormat != "" && !subscriptions.IsOutputFormatSupported(postBody.OutputFormat) {
+		err := APIErrorInvalidData(subscriptions.UnsupportedOutputFormat)
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// check that the project has room for one more subscription
+	if !withinCreateQuota(rCTX, w, projectUUID, quotas.MaxSubscriptions, refStr) {
+		return
+	}
+
 	// Get current topic offset
 	tProjectUUID := projects.GetUUIDByName(rCTX, tProject, refStr)
 	fullTopic := tProjectUUID + "." + tName
@@ -1181,6 +1313,7 @@ func SubCreate(w http.ResponseWriter, r *http.Request) {
 		}
 
 		pushConfig.Base64Decode = postBody.PushCfg.Base64Decode
+		pushConfig.SchemaDecode = postBody.PushCfg.SchemaDecode
 
 		pushConfig.RetPol.PolicyType = postBody.PushCfg.RetPol.PolicyType
 		pushConfig.RetPol.Period = postBody.PushCfg.RetPol.Period
@@ -1205,7 +1338,7 @@ func SubCreate(w http.ResponseWriter, r *http.Request) {
 
 	// Get Result Object
 	res, err := subscriptions.Create(rCTX, projectUUID, urlVars["subscription"], tName, curOff,
-		postBody.Ack, pushConfig, created, refStr)
+		postBody.Ack, pushConfig, postBody.OutputFormat, created, refStr)
 
 	if err != nil {
 		if err.Error() == "exists" {
@@ -1383,9 +1516,7 @@ func SubPull(w http.ResponseWriter, r *http.Request) {
 	// Grab context references
 	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
-	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
 	refRoles := gorillaContext.Get(r, "auth_roles").([]string)
-	refAuthResource := gorillaContext.Get(r, "auth_resource").(bool)
 	pushEnabled := gorillaContext.Get(r, "push_enabled").(bool)
 
 	// Get project UUID First to use as reference
@@ -1424,24 +1555,17 @@ func SubPull(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check Authorization per subscription
-	// - if enabled in config
-	// - if user has only consumer role
-	if refAuthResource && auth.IsConsumer(refRoles) {
-		if auth.PerResource(rCTX, projectUUID, "subscriptions", targetSub.Name, refUserUUID, refStr) == false {
-			err := APIErrorForbidden()
-			respondErr(rCTX, w, err)
-			return
-		}
-	}
-
 	// check if the subscription's topic exists
-	if !topics.HasTopic(rCTX, projectUUID, targetSub.Topic, refStr) {
+	topicResults, err := topics.Find(rCTX, projectUUID, "", targetSub.Topic, "", 0, refStr)
+	if err != nil || topicResults.Empty() {
 		err := APIErrorPullNoTopic()
 		respondErr(rCTX, w, err)
 		return
 	}
 
+	// messages older than the topic's ttl are skipped
+	messageTTL := time.Duration(topicResults.Topics[0].MessageTTL) * time.Second
+
 	// Read POST JSON body
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -1472,24 +1596,98 @@ func SubPull(w http.ResponseWriter, r *http.Request) {
 	// Init Received Message List
 	recList := messages.RecList{}
 
-	msgs, err := refBrk.Consume(rCTX, fullTopic, targetSub.Offset, retImm, int64(max))
+	ackPrefix := "projects/" + urlProject + "/subscriptions/" + urlSub + ":"
+
+	// reference time for the delayed delivery of messages
+	pullTime := time.Now().UTC()
+
+	// deliver first the held back messages that became due, along with the ones
+	// whose ack deadline has expired without being acknowledged
+	ackDeadline := time.Duration(targetSub.Ack) * time.Second
+	dueMsgs, err := refStr.QueryDueScheduledMessages(rCTX, projectUUID, targetSub.Name, targetSub.Offset,
+		pullTime, pullTime.Add(-ackDeadline), int64(max))
 	if err != nil {
-		// If tracked offset is off
-		if err == brokers.ErrOffsetOff {
-			log.WithFields(
-				log.Fields{
-					"trace_id":     rCTX.Value("trace_id"),
-					"type":         "sservice_log",
-					"subscription": targetSub.FullName,
-				},
-			).Debug("Will increment now . . .")
-			// Increment tracked offset to current min offset
-			targetSub.Offset = refBrk.GetMinOffset(rCTX, fullTopic)
-			refStr.UpdateSubOffset(rCTX, projectUUID, targetSub.Name, targetSub.Offset)
-			// Try again to consume
-			msgs, err = refBrk.Consume(rCTX, fullTopic, targetSub.Offset, retImm, int64(max))
-			// If still error respond and return
-			if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	dueOffsets := []int64{}
+	expiredOffsets := []int64{}
+	for _, dueMsg := range dueMsgs {
+		curMsg, err := messages.LoadMsgJSON([]byte(dueMsg.Message))
+		if err != nil {
+			err := APIErrGenericInternal("Message retrieved from broker network has invalid JSON Structure")
+			respondErr(rCTX, w, err)
+			return
+		}
+		if curMsg.IsExpired(messageTTL, pullTime) {
+			expiredOffsets = append(expiredOffsets, dueMsg.Offset)
+			continue
+		}
+		curMsg.ID = strconv.FormatInt(dueMsg.Offset, 10)
+		curRec := messages.RecMsg{AckID: ackPrefix + curMsg.ID, Msg: curMsg}
+		recList.RecMsgs = append(recList.RecMsgs, curRec)
+		dueOffsets = append(dueOffsets, dueMsg.Offset)
+	}
+
+	if len(dueOffsets) > 0 {
+		err = refStr.UpdateScheduledMessagesDelivery(rCTX, projectUUID, targetSub.Name, dueOffsets, pullTime)
+		if err != nil {
+			err := APIErrGenericBackend()
+			respondErr(rCTX, w, err)
+			return
+		}
+		// don't block waiting for new messages since there are already messages to return
+		retImm = true
+	}
+
+	// held back messages that expired in the meantime are dropped without being delivered
+	if len(expiredOffsets) > 0 {
+		err = refStr.DeleteScheduledMessages(rCTX, projectUUID, targetSub.Name, expiredOffsets)
+		if err != nil {
+			err := APIErrGenericBackend()
+			respondErr(rCTX, w, err)
+			return
+		}
+	}
+
+	msgs := []string{}
+	if max <= 0 || len(recList.RecMsgs) < max {
+		if max > 0 {
+			max = max - len(recList.RecMsgs)
+		}
+		msgs, err = refBrk.Consume(rCTX, fullTopic, targetSub.Offset, retImm, int64(max))
+		if err != nil {
+			// If tracked offset is off
+			if err == brokers.ErrOffsetOff {
+				log.WithFields(
+					log.Fields{
+						"trace_id":     rCTX.Value("trace_id"),
+						"type":         "sservice_log",
+						"subscription": targetSub.FullName,
+					},
+				).Debug("Will increment now . . .")
+				// Increment tracked offset to current min offset
+				targetSub.Offset = refBrk.GetMinOffset(rCTX, fullTopic)
+				refStr.UpdateSubOffset(rCTX, projectUUID, targetSub.Name, targetSub.Offset)
+				// Try again to consume
+				msgs, err = refBrk.Consume(rCTX, fullTopic, targetSub.Offset, retImm, int64(max))
+				// If still error respond and return
+				if err != nil {
+					log.WithFields(
+						log.Fields{
+							"trace_id":     rCTX.Value("trace_id"),
+							"type":         "service_log",
+							"error":        err.Error(),
+							"subscription": targetSub.FullName,
+						},
+					).Error("Couldn't consume messages for subscription")
+					err := APIErrGenericBackend()
+					respondErr(rCTX, w, err)
+					return
+				}
+			} else {
 				log.WithFields(
 					log.Fields{
 						"trace_id":     rCTX.Value("trace_id"),
@@ -1502,30 +1700,27 @@ func SubPull(w http.ResponseWriter, r *http.Request) {
 				respondErr(rCTX, w, err)
 				return
 			}
-		} else {
-			log.WithFields(
-				log.Fields{
-					"trace_id":     rCTX.Value("trace_id"),
-					"type":         "service_log",
-					"error":        err.Error(),
-					"subscription": targetSub.FullName,
-				},
-			).Error("Couldn't consume messages for subscription")
-			err := APIErrGenericBackend()
-			respondErr(rCTX, w, err)
-			return
 		}
 	}
+
 	var limit int
 	limit, err = strconv.Atoi(pullInfo.MaxMsg)
 	if err != nil {
 		limit = 0
 	}
 
-	ackPrefix := "projects/" + urlProject + "/subscriptions/" + urlSub + ":"
+	// classify the messages read from the subscription's topic
+	const (
+		msgDeliver = iota
+		msgExpired
+		msgScheduled
+	)
+	streamMsgs := []messages.Message{}
+	streamStates := []int{}
+	lastDeliverable := -1
 
 	for i, msg := range msgs {
-		if limit > 0 && i >= limit {
+		if limit > 0 && i+len(dueOffsets) >= limit {
 			break // max messages left
 		}
 		curMsg, err := messages.LoadMsgJSON([]byte(msg))
@@ -1534,15 +1729,167 @@ func SubPull(w http.ResponseWriter, r *http.Request) {
 			respondErr(rCTX, w, err)
 			return
 		}
+
+		state := msgDeliver
+		if curMsg.IsExpired(messageTTL, pullTime) {
+			state = msgExpired
+		} else if !curMsg.IsDue(pullTime) {
+			state = msgScheduled
+		} else {
+			lastDeliverable = i
+		}
+
+		streamMsgs = append(streamMsgs, curMsg)
+		streamStates = append(streamStates, state)
+	}
+
+	// number of messages read from the subscription's topic.
+	// Skipped messages that follow the last delivered one are left for the next pull,
+	// where they will be skipped without needing an ack
+	consumed := len(streamMsgs)
+	if lastDeliverable >= 0 {
+		consumed = lastDeliverable + 1
+	}
+
+	// delayed messages that are delivered directly from the topic since they are already due
+	deliveredDelayed := []int64{}
+	// number of expired messages that are seen for the first time
+	expiredCount := int64(len(expiredOffsets))
+
+	for i := 0; i < consumed; i++ {
+		curMsg := streamMsgs[i]
 		// calc the message id = message's kafka offset (read offst + msg position)
 		idOff := targetSub.Offset + int64(i)
+
+		switch streamStates[i] {
+		case msgExpired:
+			// messages of a batch that is pending an ack have already been counted
+			if idOff >= targetSub.NextOffset {
+				expiredCount++
+			}
+			continue
+		case msgScheduled:
+			// hold back the message until its delivery time without blocking the ones that follow
+			err := refStr.InsertScheduledMessage(rCTX, projectUUID, targetSub.Name, idOff, curMsg.DeliveryTime(), msgs[i])
+			if err != nil {
+				err := APIErrGenericBackend()
+				respondErr(rCTX, w, err)
+				return
+			}
+			continue
+		}
+
+		if curMsg.DeliverAfter != "" {
+			deliveredDelayed = append(deliveredDelayed, idOff)
+		}
+
 		curMsg.ID = strconv.FormatInt(idOff, 10)
 		curRec := messages.RecMsg{AckID: ackPrefix + curMsg.ID, Msg: curMsg}
 		recList.RecMsgs = append(recList.RecMsgs, curRec)
 	}
 
-	// amount of messages consumed
-	msgCount := int64(len(msgs))
+	if expiredCount > 0 {
+		refStr.IncrementSubExpiredMsgNum(rCTX, projectUUID, urlSub, expiredCount)
+	}
+
+	// a delayed message that got delivered from the topic might have been held back by an earlier pull
+	if len(deliveredDelayed) > 0 {
+		err = refStr.DeleteScheduledMessages(rCTX, projectUUID, targetSub.Name, deliveredDelayed)
+		if err != nil {
+			err := APIErrGenericBackend()
+			respondErr(rCTX, w, err)
+			return
+		}
+	}
+
+	// restore the payloads that the topic stored compressed, counting their size as they were stored
+	storedBytes := int64(0)
+	for i := range recList.RecMsgs {
+		curMsg := &recList.RecMsgs[i].Msg
+		storedBytes += curMsg.Size()
+		err := curMsg.Decompress()
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"trace_id":     rCTX.Value("trace_id"),
+					"type":         "service_log",
+					"subscription": targetSub.FullName,
+					"message_id":   curMsg.ID,
+					"error":        err.Error(),
+				},
+			).Error("Could not decompress message")
+			err := APIErrGenericInternal("Message retrieved from broker network has an invalid compressed payload")
+			respondErr(rCTX, w, err)
+			return
+		}
+	}
+
+	// decode the payloads using the topic's schema, when asked by the consumer
+	// or by the push configuration of the subscription when the push worker consumes
+	decode := pullInfo.Decode == "true" || (targetSub.PushCfg.SchemaDecode && auth.IsPushWorker(refRoles))
+	if decode && topicResults.Topics[0].Schema != "" && len(recList.RecMsgs) > 0 {
+
+		schema, err := topicSchema(rCTX, projectUUID, topicResults.Topics[0].Schema, refStr)
+		if err != nil {
+			err := APIErrGenericInternal(schemas.GenericError)
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		current, err := schemas.FindDecoder(rCTX, schema, 0, refStr)
+		if err != nil {
+			err := APIErrGenericInternal(schemas.GenericError)
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		// each payload is decoded with the revision it was published with,
+		// payloads published before the schema was attached use the current one
+		decoders := map[int64]*schemas.Decoder{0: current, current.Revision(): current}
+
+		for i := range recList.RecMsgs {
+			curMsg := &recList.RecMsgs[i].Msg
+
+			decoder, found := decoders[curMsg.SchemaRevision]
+			if !found {
+				decoder, err = schemas.FindDecoder(rCTX, schema, curMsg.SchemaRevision, refStr)
+				if err != nil {
+					log.WithFields(
+						log.Fields{
+							"trace_id":        rCTX.Value("trace_id"),
+							"type":            "service_log",
+							"subscription":    targetSub.FullName,
+							"message_id":      curMsg.ID,
+							"schema_revision": curMsg.SchemaRevision,
+							"error":           err.Error(),
+						},
+					).Error("Could not load schema decoder")
+					continue
+				}
+				decoders[curMsg.SchemaRevision] = decoder
+			}
+
+			// payloads published before the schema was attached can't always be decoded, they are returned as is
+			decoded, err := decoder.Decode([]byte(curMsg.GetDecoded()))
+			if err != nil {
+				log.WithFields(
+					log.Fields{
+						"trace_id":     rCTX.Value("trace_id"),
+						"type":         "service_log",
+						"subscription": targetSub.FullName,
+						"message_id":   curMsg.ID,
+						"error":        err.Error(),
+					},
+				).Debug("Could not decode message")
+				continue
+			}
+			curMsg.DecodedData = decoded
+			curMsg.SchemaRevision = decoder.Revision()
+		}
+	}
+
+	// amount of messages delivered
+	msgCount := int64(len(recList.RecMsgs))
 
 	// consumption time
 	consumeTime := time.Now().UTC()
@@ -1550,6 +1897,7 @@ func SubPull(w http.ResponseWriter, r *http.Request) {
 	// increment subscription number of message metric
 	refStr.IncrementSubMsgNum(rCTX, projectUUID, urlSub, msgCount)
 	refStr.IncrementSubBytes(rCTX, projectUUID, urlSub, recList.TotalSize())
+	refStr.IncrementSubStoredBytes(rCTX, projectUUID, urlSub, storedBytes)
 	refStr.UpdateSubLatestConsume(rCTX, projectUUID, targetSub.Name, consumeTime)
 
 	// count the rate of consumed messages per sec between the last two consume events
@@ -1562,7 +1910,14 @@ func SubPull(w http.ResponseWriter, r *http.Request) {
 
 	refStr.UpdateSubConsumeRate(rCTX, projectUUID, targetSub.Name, float64(msgCount)/dt)
 
-	resJSON, err := recList.ExportJSON()
+	var resJSON string
+	if targetSub.IsCloudEvents() {
+		// messages that weren't published as events get the subscription's topic as their source
+		events := recList.CloudEvents(targetSub.FullTopic)
+		resJSON, err = events.ExportJSON()
+	} else {
+		resJSON, err = recList.ExportJSON()
+	}
 
 	if err != nil {
 		err := APIErrExportJSON()
@@ -1574,7 +1929,13 @@ func SubPull(w http.ResponseWriter, r *http.Request) {
 	zSec := "2006-01-02T15:04:05Z"
 	t := time.Now().UTC()
 	ts := t.Format(zSec)
-	refStr.UpdateSubPull(rCTX, targetSub.ProjectUUID, targetSub.Name, int64(len(recList.RecMsgs))+targetSub.Offset, ts)
+	streamDelivered := len(recList.RecMsgs) - len(dueOffsets)
+	if consumed > 0 && streamDelivered == 0 {
+		// every message read from the topic is held back or expired, so there is nothing to acknowledge
+		refStr.UpdateSubOffset(rCTX, targetSub.ProjectUUID, targetSub.Name, targetSub.Offset+int64(consumed))
+	} else {
+		refStr.UpdateSubPull(rCTX, targetSub.ProjectUUID, targetSub.Name, int64(consumed)+targetSub.Offset, ts)
+	}
 
 	output = []byte(resJSON)
 	respondOK(w, output)
@@ -1600,3 +1961,23 @@ func activatePushSubscription(rCTX context.Context, sub subscriptions.Subscripti
 
 	return nil
 }
+
+// topicSchema retrieves the schema that is attached to a topic, along with its compiled definition when it is cached
+func topicSchema(ctx context.Context, projectUUID string, schemaRef string, str stores.Store) (schemas.Schema, error) {
+
+	_, schemaName, err := schemas.ExtractSchema(schemaRef)
+	if err != nil {
+		return schemas.Schema{}, err
+	}
+
+	sl, err := schemas.FindCached(ctx, projectUUID, schemaName, str)
+	if err != nil {
+		return schemas.Schema{}, err
+	}
+
+	if sl.Empty() {
+		return schemas.Schema{}, errors.New("not found")
+	}
+
+	return sl.Schemas[0], nil
+}
@@ -3,11 +3,15 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/messages"
 	oldPush "github.com/ARGOeu/argo-messaging/push"
 	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
+	"github.com/ARGOeu/argo-messaging/schemas"
 	"github.com/ARGOeu/argo-messaging/stores"
 	"github.com/ARGOeu/argo-messaging/subscriptions"
 	"github.com/gorilla/mux"
@@ -1366,6 +1370,66 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubCreate() {
 
 }
 
+func (suite *SubscriptionsHandlersTestSuite) TestSubCreateOutputFormat() {
+
+	type td struct {
+		postBody           string
+		expectedStatusCode int
+		expectedFormat     string
+		expectedResponse   string
+		msg                string
+	}
+
+	testData := []td{
+		{
+			postBody:           `{"topic":"projects/ARGO/topics/topic1","outputFormat":"cloudevents"}`,
+			expectedStatusCode: 200,
+			expectedFormat:     "cloudevents",
+			msg:                "Create a subscription that delivers cloud events",
+		},
+		{
+			postBody:           `{"topic":"projects/ARGO/topics/topic1","outputFormat":"xml"}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Output format can only be 'ams' or 'cloudevents'",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Create a subscription with an unsupported output format",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+
+	for _, t := range testData {
+
+		brk := brokers.MockBroker{}
+		str := stores.NewMockStore("whatever", "argo_mgs")
+		router := mux.NewRouter().StrictSlash(true)
+		mgr := oldPush.Manager{}
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
+
+		req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew", bytes.NewBuffer([]byte(t.postBody)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+
+		if t.expectedStatusCode == 200 {
+			sub, _ := str.QueryOneSub(suite.ctx, "argo_uuid", "subNew")
+			suite.Equal(t.expectedFormat, sub.OutputFormat, t.msg)
+			suite.Contains(w.Body.String(), `"outputFormat": "cloudevents"`, t.msg)
+		} else {
+			suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+		}
+	}
+}
+
 func (suite *SubscriptionsHandlersTestSuite) TestSubCreateExists() {
 
 	postJSON := `{
@@ -2263,6 +2327,61 @@ func (suite *SubscriptionsHandlersTestSuite) TestModSubACL01() {
 
 }
 
+func (suite *SubscriptionsHandlersTestSuite) TestModSubACLActions() {
+
+	postExp := `{"authorized_users":["UserX","UserZ"], "user_actions": {"UserX": ["pull", "acknowledge"]}}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:modAcl", WrapMockAuthConfig(SubModACL, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acl", WrapMockAuthConfig(SubACL, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modAcl", bytes.NewBuffer([]byte(postExp)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(map[string][]string{"uuid3": {"pull", "acknowledge"}}, str.SubsACL["sub1"].Actions)
+
+	expResp := `{
+   "authorized_users": [
+      "UserX",
+      "UserZ"
+   ],
+   "user_actions": {
+      "UserX": [
+         "pull",
+         "acknowledge"
+      ]
+   }
+}`
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acl", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+	// actions of topics can't be given on subscriptions
+	expErr := `{
+   "error": {
+      "code": 400,
+      "message": "invalid action publish for user UserX",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+
+	postExp = `{"authorized_users":["UserX"], "user_actions": {"UserX": ["publish"]}}`
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modAcl", bytes.NewBuffer([]byte(postExp)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(expErr, w.Body.String())
+}
+
 func (suite *SubscriptionsHandlersTestSuite) TestSubACL01() {
 
 	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscription/sub1:acl", nil)
@@ -2367,6 +2486,109 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubPullOne() {
 
 }
 
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullCloudEvents() {
+
+	postJSON := `{
+  "maxMessages":"2"
+}`
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the first message was published as a plain message, the second one as an event
+	expJSON := `{
+   "receivedMessages": [
+      {
+         "ackId": "projects/ARGO/subscriptions/sub1:0",
+         "message": {
+            "data_base64": "YmFzZTY0ZW5jb2RlZA==",
+            "foo": "bar",
+            "id": "0",
+            "source": "/projects/ARGO/topics/topic1",
+            "specversion": "1.0",
+            "time": "2016-02-24T11:55:09.786127994Z",
+            "type": "argo.messaging.message"
+         }
+      },
+      {
+         "ackId": "projects/ARGO/subscriptions/sub1:1",
+         "message": {
+            "data": {
+               "value": 21
+            },
+            "id": "e1",
+            "source": "/sensors/1",
+            "specversion": "1.0",
+            "type": "reading",
+            "unit": "celsius"
+         }
+      }
+   ]
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateOne()
+	event := messages.CloudEvent{}
+	_ = json.Unmarshal([]byte(`{"specversion":"1.0","id":"e1","source":"/sensors/1","type":"reading","unit":"celsius","data":{"value":21}}`), &event)
+	eventMsg, _ := event.ToMessage()
+	brk.Publish(suite.ctx, "argo_uuid.topic1", eventMsg)
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.ModSubOutputFormat(suite.ctx, "argo_uuid", "sub1", "cloudevents")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expJSON, w.Body.String())
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullCompressed() {
+
+	postJSON := `{
+  "maxMessages":"1"
+}`
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	data := base64.StdEncoding.EncodeToString([]byte(strings.Repeat(`{"status":"CRITICAL"}`, 20)))
+	msg := messages.Message{Attr: messages.Attributes{"foo": "bar"}, Data: data}
+	_ = msg.Compress(messages.CompressionGzip)
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.Publish(suite.ctx, "argo_uuid.topic1", msg)
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	// the payload is delivered decompressed
+	recList := messages.RecList{}
+	suite.Nil(json.Unmarshal(w.Body.Bytes(), &recList))
+	suite.Equal(1, len(recList.RecMsgs))
+	suite.Equal(data, recList.RecMsgs[0].Msg.Data)
+	suite.Equal("", recList.RecMsgs[0].Msg.Compression)
+
+	// both the logical and the stored size of the payload are counted
+	sub, _ := str.QueryOneSub(suite.ctx, "argo_uuid", "sub1")
+	suite.Equal(int64(len(data)), sub.TotalBytes)
+	suite.Equal(msg.Size(), sub.StoredBytes)
+}
+
 func (suite *SubscriptionsHandlersTestSuite) TestSubPullFromPushEnabledAsPushWorker() {
 
 	postJSON := `{
@@ -2409,6 +2631,161 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubPullFromPushEnabledAsPushWor
 	suite.Equal(expJSON, w.Body.String())
 }
 
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullDecode() {
+
+	// sub3 consumes from topic3, which has the avro schema-3 attached
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub3:pull"
+
+	avroMsg := `{"messageId": "0", "data": "DGFnZWxvc8T8Cg==", "publishTime": "2016-02-24T11:55:09.786127994Z"}`
+	plainMsg := `{"messageId": "1", "data": "YmFzZTY0ZW5jb2RlZA==", "publishTime": "2016-02-24T11:55:09.827678754Z"}`
+
+	decodedJSON := `{
+   "receivedMessages": [
+      {
+         "ackId": "projects/ARGO/subscriptions/sub3:0",
+         "message": {
+            "messageId": "0",
+            "data": "DGFnZWxvc8T8Cg==",
+            "publishTime": "2016-02-24T11:55:09.786127994Z",
+            "decodedData": {
+               "phone": 89890,
+               "username": "agelos"
+            },
+            "schemaRevision": 1
+         }
+      },
+      {
+         "ackId": "projects/ARGO/subscriptions/sub3:1",
+         "message": {
+            "messageId": "1",
+            "data": "YmFzZTY0ZW5jb2RlZA==",
+            "publishTime": "2016-02-24T11:55:09.827678754Z"
+         }
+      }
+   ]
+}`
+
+	plainJSON := `{
+   "receivedMessages": [
+      {
+         "ackId": "projects/ARGO/subscriptions/sub3:0",
+         "message": {
+            "messageId": "0",
+            "data": "DGFnZWxvc8T8Cg==",
+            "publishTime": "2016-02-24T11:55:09.786127994Z"
+         }
+      },
+      {
+         "ackId": "projects/ARGO/subscriptions/sub3:1",
+         "message": {
+            "messageId": "1",
+            "data": "YmFzZTY0ZW5jb2RlZA==",
+            "publishTime": "2016-02-24T11:55:09.827678754Z"
+         }
+      }
+   ]
+}`
+
+	type td struct {
+		postBody     string
+		roles        []string
+		schemaDecode bool
+		expectedJSON string
+		msg          string
+	}
+
+	testData := []td{
+		{
+			postBody:     `{"maxMessages":"2","decode":"true"}`,
+			expectedJSON: decodedJSON,
+			msg:          "Case where the consumer asks for the payloads to be decoded",
+		},
+		{
+			postBody:     `{"maxMessages":"2"}`,
+			expectedJSON: plainJSON,
+			msg:          "Case where the payloads are returned as published",
+		},
+		{
+			postBody:     `{"maxMessages":"2"}`,
+			roles:        []string{"push_worker"},
+			schemaDecode: true,
+			expectedJSON: decodedJSON,
+			msg:          "Case where the push worker consumes from a subscription with schema decoding enabled",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	mgr := oldPush.Manager{}
+
+	for _, t := range testData {
+
+		brk := brokers.MockBroker{}
+		brk.Initialize([]string{"localhost"})
+		brk.MsgList = []string{avroMsg, plainMsg}
+		str := stores.NewMockStore("whatever", "argo_mgs")
+		schemas.MigrateRevisions(context.Background(), str)
+
+		if t.schemaDecode {
+			for i, sub := range str.SubList {
+				if sub.Name == "sub3" {
+					str.SubList[i].PushType = "http_endpoint"
+					str.SubList[i].PushEndpoint = "https://www.example.com"
+					str.SubList[i].Verified = true
+					str.SubList[i].SchemaDecode = true
+				}
+			}
+		}
+
+		req, err := http.NewRequest("POST", url, strings.NewReader(t.postBody))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		router := mux.NewRouter().StrictSlash(true)
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil, t.roles...))
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code, t.msg)
+		suite.Equal(t.expectedJSON, w.Body.String(), t.msg)
+	}
+
+	// a payload is decoded with the revision it was published with, even after the schema has changed
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.MsgList = []string{`{"messageId": "0", "data": "DGFnZWxvc8T8Cg==", "publishTime": "2016-02-24T11:55:09.786127994Z", "schemaRevision": 1}`}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	schemas.MigrateRevisions(context.Background(), str)
+
+	sl, _ := schemas.Find(context.Background(), "argo_uuid", "", "schema-3", str)
+	_, err := schemas.Update(context.Background(), sl.Schemas[0], "", "", "", map[string]interface{}{
+		"namespace": "user.avro",
+		"type":      "record",
+		"name":      "User",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "phone", "type": "int"},
+			map[string]interface{}{"name": "username", "type": "string"},
+		},
+	}, nil, str)
+	suite.Nil(err)
+
+	req, err := http.NewRequest("POST", url, strings.NewReader(`{"maxMessages":"1","decode":"true"}`))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"decodedData": {
+               "phone": 89890,
+               "username": "agelos"
+            },
+            "schemaRevision": 1`)
+}
+
 func (suite *SubscriptionsHandlersTestSuite) TestSubPullFromPushEnabledAsPushWorkerDISABLED() {
 
 	postJSON := `{
@@ -2521,6 +2898,88 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubPullFromPushEnabledNoPushWor
 	suite.Equal(expJSON, w.Body.String())
 }
 
+func (suite *SubscriptionsHandlersTestSuite) TestSubModOutputFormat() {
+
+	type td struct {
+		sub                string
+		postBody           string
+		expectedStatusCode int
+		expectedFormat     string
+		expectedResponse   string
+		msg                string
+	}
+
+	testData := []td{
+		{
+			sub:                "sub1",
+			postBody:           `{"outputFormat":"cloudevents"}`,
+			expectedStatusCode: 200,
+			expectedFormat:     "cloudevents",
+			expectedResponse:   "",
+			msg:                "Switch a subscription to cloud events",
+		},
+		{
+			sub:                "sub1",
+			postBody:           `{"outputFormat":"ams"}`,
+			expectedStatusCode: 200,
+			expectedFormat:     "",
+			expectedResponse:   "",
+			msg:                "Switch a subscription back to the ams format",
+		},
+		{
+			sub:                "sub1",
+			postBody:           `{"outputFormat":"xml"}`,
+			expectedStatusCode: 400,
+			expectedFormat:     "",
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Output format can only be 'ams' or 'cloudevents'",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Switch a subscription to an unsupported format",
+		},
+		{
+			sub:                "unknown",
+			postBody:           `{"outputFormat":"cloudevents"}`,
+			expectedStatusCode: 404,
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "Subscription doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			msg: "Switch an unknown subscription",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:modifyOutputFormat", WrapMockAuthConfig(SubModOutputFormat, cfgKafka, &brk, str, &mgr, nil))
+
+	for _, t := range testData {
+
+		url := fmt.Sprintf("http://localhost:8080/v1/projects/ARGO/subscriptions/%v:modifyOutputFormat", t.sub)
+		req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(t.postBody)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+
+		sub, _ := str.QueryOneSub(suite.ctx, "argo_uuid", "sub1")
+		suite.Equal(t.expectedFormat, sub.OutputFormat, t.msg)
+	}
+}
+
 func (suite *SubscriptionsHandlersTestSuite) TestSubModAck() {
 
 	postJSON := `{
@@ -2671,6 +3130,240 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubAck() {
 
 }
 
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullDelayed() {
+
+	pullJSON := `{
+  "maxMessages":"3"
+}`
+
+	expJSON1 := `{
+   "receivedMessages": [
+      {
+         "ackId": "projects/ARGO/subscriptions/sub1:0",
+         "message": {
+            "messageId": "0",
+            "attributes": {
+               "foo": "bar"
+            },
+            "data": "YmFzZTY0ZW5jb2RlZA==",
+            "publishTime": "2016-02-24T11:55:09.786127994Z"
+         }
+      },
+      {
+         "ackId": "projects/ARGO/subscriptions/sub1:2",
+         "message": {
+            "messageId": "2",
+            "attributes": {
+               "foo2": "bar2"
+            },
+            "data": "YmFzZTY0ZW5jb2RlZA==",
+            "publishTime": "2016-02-24T11:55:09.830417467Z"
+         }
+      }
+   ]
+}`
+
+	expJSON2 := `{
+   "receivedMessages": [
+      {
+         "ackId": "projects/ARGO/subscriptions/sub1:1",
+         "message": {
+            "messageId": "1",
+            "attributes": {
+               "foo2": "bar2"
+            },
+            "data": "YmFzZTY0ZW5jb2RlZA==",
+            "publishTime": "2016-02-24T11:55:09.827678754Z",
+            "deliverAfter": "2100-01-01T00:00:00Z"
+         }
+      }
+   ]
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree() // Add three messages to the broker queue
+	// hold back the second message
+	delayedMsg, _ := messages.LoadMsgJSON([]byte(brk.MsgList[1]))
+	delayedMsg.DeliverAfter = "2100-01-01T00:00:00Z"
+	delayedJSON, _ := delayedMsg.ExportJSON()
+	brk.MsgList[1] = delayedJSON
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+
+	pullURL := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull"
+	ackURL := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge"
+
+	// the delayed message is held back without blocking the one that follows
+	req, err := http.NewRequest("POST", pullURL, bytes.NewBuffer([]byte(pullJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expJSON1, w.Body.String())
+	suite.Equal(1, len(str.ScheduledMessages))
+	suite.Equal(int64(1), str.ScheduledMessages[0].Offset)
+	suite.Equal(int64(3), str.SubList[0].NextOffset)
+
+	// acknowledge the delivered messages
+	req, err = http.NewRequest("POST", ackURL, bytes.NewBuffer([]byte(`{"ackIds":["projects/ARGO/subscriptions/sub1:2"]}`)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	router = mux.NewRouter().StrictSlash(true)
+	w = httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	// the mock store doesn't keep track of the acknowledged offset
+	str.SubList[0].Offset = 3
+	str.SubList[0].NextOffset = 0
+
+	// the topic has no new messages and the delayed message becomes due
+	brk.MsgList = []string{}
+	str.ScheduledMessages[0].DeliverAfter = time.Now().UTC().Add(-time.Second)
+
+	req, err = http.NewRequest("POST", pullURL, bytes.NewBuffer([]byte(pullJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	router = mux.NewRouter().StrictSlash(true)
+	w = httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expJSON2, w.Body.String())
+	suite.False(str.ScheduledMessages[0].DeliveredAt.IsZero())
+
+	// while pending an ack, the delayed message isn't delivered again
+	req, err = http.NewRequest("POST", pullURL, bytes.NewBuffer([]byte(pullJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	router = mux.NewRouter().StrictSlash(true)
+	w = httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("{\n   \"receivedMessages\": []\n}", w.Body.String())
+
+	// acknowledging the delayed message removes it
+	req, err = http.NewRequest("POST", ackURL, bytes.NewBuffer([]byte(`{"ackIds":["projects/ARGO/subscriptions/sub1:1"]}`)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	router = mux.NewRouter().StrictSlash(true)
+	w = httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("{}", w.Body.String())
+	suite.Equal(0, len(str.ScheduledMessages))
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullExpired() {
+
+	pullJSON := `{
+  "maxMessages":"3"
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	mgr := oldPush.Manager{}
+
+	// refresh returns the broker messages with the publish time of the given positions set to now
+	refresh := func(brk *brokers.MockBroker, positions ...int) {
+		for _, p := range positions {
+			msg, _ := messages.LoadMsgJSON([]byte(brk.MsgList[p]))
+			msg.PubTime = time.Now().UTC().Format(time.RFC3339Nano)
+			msgJSON, _ := msg.ExportJSON()
+			brk.MsgList[p] = msgJSON
+		}
+	}
+
+	pull := func(brk *brokers.MockBroker, str *stores.MockStore) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", bytes.NewBuffer([]byte(pullJSON)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router := mux.NewRouter().StrictSlash(true)
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, brk, str, &mgr, nil))
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// expired messages that precede a valid one are skipped and counted
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	refresh(&brk, 2)
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.ModTopicMessageTTL(suite.ctx, "argo_uuid", "topic1", 3600)
+	w := pull(&brk, str)
+	suite.Equal(200, w.Code)
+	recList := messages.RecList{}
+	_ = json.Unmarshal(w.Body.Bytes(), &recList)
+	suite.Equal(1, len(recList.RecMsgs))
+	suite.Equal("projects/ARGO/subscriptions/sub1:2", recList.RecMsgs[0].AckID)
+	suite.Equal(int64(2), str.SubList[0].ExpiredMsgNum)
+	suite.Equal(int64(1), str.SubList[0].MsgNum)
+	suite.Equal(int64(3), str.SubList[0].NextOffset)
+
+	// pulling again the same batch while it's pending an ack doesn't count the expired messages twice
+	w = pull(&brk, str)
+	suite.Equal(200, w.Code)
+	suite.Equal(int64(2), str.SubList[0].ExpiredMsgNum)
+
+	// expired messages that follow the last valid one are left for the next pull
+	brk = brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	refresh(&brk, 0)
+	str = stores.NewMockStore("whatever", "argo_mgs")
+	str.ModTopicMessageTTL(suite.ctx, "argo_uuid", "topic1", 3600)
+	w = pull(&brk, str)
+	suite.Equal(200, w.Code)
+	recList = messages.RecList{}
+	_ = json.Unmarshal(w.Body.Bytes(), &recList)
+	suite.Equal(1, len(recList.RecMsgs))
+	suite.Equal("projects/ARGO/subscriptions/sub1:0", recList.RecMsgs[0].AckID)
+	suite.Equal(int64(0), str.SubList[0].ExpiredMsgNum)
+	suite.Equal(int64(1), str.SubList[0].NextOffset)
+
+	// only expired messages, along with a held back message that expired before becoming due
+	brk = brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str = stores.NewMockStore("whatever", "argo_mgs")
+	str.ModTopicMessageTTL(suite.ctx, "argo_uuid", "topic1", 3600)
+	str.SubList[0].Offset = 5
+	str.InsertScheduledMessage(suite.ctx, "argo_uuid", "sub1", 1, time.Now().UTC().Add(-time.Minute), brk.MsgList[1])
+	w = pull(&brk, str)
+	suite.Equal(200, w.Code)
+	suite.Equal("{\n   \"receivedMessages\": []\n}", w.Body.String())
+	suite.Equal(int64(4), str.SubList[0].ExpiredMsgNum)
+	suite.Equal(0, len(str.ScheduledMessages))
+
+	// without a ttl nothing expires
+	brk = brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str = stores.NewMockStore("whatever", "argo_mgs")
+	w = pull(&brk, str)
+	suite.Equal(200, w.Code)
+	recList = messages.RecList{}
+	_ = json.Unmarshal(w.Body.Bytes(), &recList)
+	suite.Equal(3, len(recList.RecMsgs))
+	suite.Equal(int64(0), str.SubList[0].ExpiredMsgNum)
+}
+
 func (suite *SubscriptionsHandlersTestSuite) TestSubError() {
 
 	postJSON := `{
@@ -7,6 +7,7 @@ import (
 	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/messages"
+	"github.com/ARGOeu/argo-messaging/quotas"
 	"github.com/ARGOeu/argo-messaging/schemas"
 	"github.com/ARGOeu/argo-messaging/stores"
 	"github.com/ARGOeu/argo-messaging/subscriptions"
@@ -20,6 +21,13 @@ import (
 	"time"
 )
 
+const (
+	// quarantineSourceTopicAttr is the attribute of a quarantined message that holds the topic it was published to
+	quarantineSourceTopicAttr = "ams_source_topic"
+	// quarantineReasonAttr is the attribute of a quarantined message that holds the reason it got rejected
+	quarantineReasonAttr = "ams_rejection_reason"
+)
+
 // TopicDelete (DEL) deletes an existing topic
 func TopicDelete(w http.ResponseWriter, r *http.Request) {
 	traceId := gorillaContext.Get(r, "trace_id").(string)
@@ -72,6 +80,54 @@ func TopicDelete(w http.ResponseWriter, r *http.Request) {
 	respondOK(w, output)
 }
 
+// TopicsReconcile (POST) compares the topics of the store with the topics of the broker,
+// reports the orphans on either side and only removes the broker orphans when dry_run is explicitly set to false
+func TopicsReconcile(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+
+	// deleting broker topics has to be asked for explicitly
+	dryRun := true
+	if r.URL.Query().Get("dry_run") != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(r.URL.Query().Get("dry_run"))
+		if err != nil {
+			err := APIErrorInvalidData("dry_run is not a valid boolean value")
+			respondErr(rCTX, w, err)
+			return
+		}
+	}
+
+	report, err := topics.Reconcile(rCTX, dryRun, refStr, refBrk)
+	if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	resJSON, err := report.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
 // TopicModACL (PUT) modifies the ACL
 func TopicModACL(w http.ResponseWriter, r *http.Request) {
 	traceId := gorillaContext.Get(r, "trace_id").(string)
@@ -106,6 +162,14 @@ func TopicModACL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// check that the action restrictions refer to the acl and to actions of the resource
+	err = auth.ValidateACLActions("topics", postBody)
+	if err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
 	// Grab context references
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
 	// Get project UUID First to use as reference
@@ -119,7 +183,15 @@ func TopicModACL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = auth.ModACL(rCTX, projectUUID, "topics", urlTopic, postBody.AuthUsers, refStr)
+	// check if group list contain valid groups for the given project
+	_, err = auth.AreValidGroups(rCTX, projectUUID, postBody.AuthGroups, refStr)
+	if err != nil {
+		err := APIErrorRoot{Body: APIErrorBody{Code: http.StatusNotFound, Message: err.Error(), Status: "NOT_FOUND"}}
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	err = auth.ModACL(rCTX, projectUUID, "topics", urlTopic, postBody, refStr)
 
 	if err != nil {
 
@@ -157,7 +229,10 @@ func TopicCreate(w http.ResponseWriter, r *http.Request) {
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
 	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
 
-	postBody := map[string]string{}
+	postBody := struct {
+		Schema     string `json:"schema"`
+		MessageTTL int64  `json:"messageTTL"`
+	}{}
 	schemaUUID := ""
 
 	// check if there's a request body provided before trying to decode
@@ -180,7 +255,13 @@ func TopicCreate(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			schemaRef := postBody["schema"]
+			if postBody.MessageTTL < 0 {
+				err := APIErrorInvalidData("messageTTL should be a non negative number of seconds")
+				respondErr(rCTX, w, err)
+				return
+			}
+
+			schemaRef := postBody.Schema
 
 			// if there was a schema name provided, check its existence
 			if schemaRef != "" {
@@ -208,10 +289,15 @@ func TopicCreate(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// check that the project has room for one more topic
+	if !withinCreateQuota(rCTX, w, projectUUID, quotas.MaxTopics, refStr) {
+		return
+	}
+
 	created := time.Now().UTC()
 
 	// Get Result Object
-	res, err := topics.CreateTopic(rCTX, projectUUID, urlVars["topic"], schemaUUID, created, refStr)
+	res, err := topics.CreateTopic(rCTX, projectUUID, urlVars["topic"], schemaUUID, postBody.MessageTTL, created, refStr)
 	if err != nil {
 		if err.Error() == "exists" {
 			err := APIErrorConflict("Topic")
@@ -378,6 +464,369 @@ func TopicDetachSchema(w http.ResponseWriter, r *http.Request) {
 	respondOK(w, output)
 }
 
+// TopicModMessageTTL (POST) modifies the time after which the messages of a topic expire
+func TopicModMessageTTL(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	postBody, err := topics.GetMessageTTLFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("messageTTL(needs a non negative number of seconds)")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	err = topics.ModMessageTTL(rCTX, projectUUID, urlVars["topic"], postBody.MessageTTL, refStr)
+	if err != nil {
+		if err.Error() == "wrong value" {
+			err := APIErrorInvalidArgument("messageTTL(needs a non negative number of seconds)")
+			respondErr(rCTX, w, err)
+			return
+		}
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Topic")
+			respondErr(rCTX, w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
+// TopicModQuarantineTopic (POST) modifies the topic that receives the rejected messages of a topic
+func TopicModQuarantineTopic(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	postBody, err := topics.GetQuarantineTopicFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("quarantineTopic")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	quarantineTopic := ""
+
+	// an empty quarantine topic disables quarantine
+	if postBody.QuarantineTopic != "" {
+		tProject, tName, err := subscriptions.ExtractFullTopicRef(postBody.QuarantineTopic)
+		if err != nil || tProject != urlVars["project"] {
+			err := APIErrorInvalidData("quarantineTopic should be a topic of the same project, e.g. projects/{project}/topics/{topic}")
+			respondErr(rCTX, w, err)
+			return
+		}
+		quarantineTopic = tName
+	}
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	err = topics.ModQuarantineTopic(rCTX, projectUUID, urlVars["topic"], quarantineTopic, refStr)
+	if err != nil {
+		if err.Error() == "wrong value" {
+			err := APIErrorInvalidData("A topic can't be its own quarantine topic")
+			respondErr(rCTX, w, err)
+			return
+		}
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Topic")
+			respondErr(rCTX, w, err)
+			return
+		}
+		if err.Error() == "quarantine not found" {
+			err := APIErrorNotFound("Quarantine topic")
+			respondErr(rCTX, w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
+// TopicModCompression (POST) modifies the algorithm that compresses the payloads of a topic's messages
+func TopicModCompression(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	postBody, err := topics.GetCompressionFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("compression")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	err = topics.ModCompression(rCTX, projectUUID, urlVars["topic"], postBody.Compression, refStr)
+	if err != nil {
+		if err.Error() == "wrong value" {
+			err := APIErrorInvalidData("compression should be either gzip, zstd or empty")
+			respondErr(rCTX, w, err)
+			return
+		}
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Topic")
+			respondErr(rCTX, w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
+// updateTopicPublishMetrics updates the metrics of a topic after a successful publish of the given messages.
+// The stored bytes are the size of the messages as they reached the broker, after compression
+func updateTopicPublishMetrics(ctx context.Context, projectUUID string, name string, published messages.MsgList,
+	storedBytes int64, latestPublish time.Time, refStr stores.Store) {
+
+	// timestamp of the publish event
+	publishTime := time.Now().UTC()
+
+	// amount of messages published
+	msgCount := int64(len(published.Msgs))
+
+	// increment topic number of message metric
+	refStr.IncrementTopicMsgNum(ctx, projectUUID, name, msgCount)
+
+	// increment daily count of topic messages
+	year, month, day := publishTime.Date()
+	refStr.IncrementDailyTopicMsgCount(ctx, projectUUID, name, msgCount, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+
+	// increment topic total bytes published
+	refStr.IncrementTopicBytes(ctx, projectUUID, name, published.TotalSize())
+	refStr.IncrementTopicStoredBytes(ctx, projectUUID, name, storedBytes)
+
+	// update latest publish date for the given topic
+	refStr.UpdateTopicLatestPublish(ctx, projectUUID, name, publishTime)
+
+	// count the rate of published messages per sec between the last two publish events
+	var dt float64 = 1
+	// if its the first publish to the topic
+	// skip the subtraction that computes the DT between the last two publish events
+	if !latestPublish.IsZero() {
+		dt = publishTime.Sub(latestPublish).Seconds()
+	}
+	refStr.UpdateTopicPublishRate(ctx, projectUUID, name, float64(msgCount)/dt)
+}
+
+// rejectedMessage describes a message that didn't get published because it doesn't match the topic's schema
+type rejectedMessage struct {
+	Index               int                       `json:"index"`
+	Errors              []schemas.ValidationError `json:"errors"`
+	QuarantineMessageID string                    `json:"quarantineMessageId,omitempty"`
+	msg                 messages.Message
+}
+
+// publishResult holds the outcome of a publish request that accepts only the valid messages
+type publishResult struct {
+	messages.MsgIDs
+	Rejected []rejectedMessage `json:"rejected"`
+}
+
+// ExportJSON exports the publish result as a json string
+func (pr publishResult) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(pr, "", "   ")
+	return string(output[:]), err
+}
+
+// quarantineMessages publishes the rejected messages to the quarantine topic of the given topic, along with
+// the reason of their rejection. The valid messages are already published so a failure is only logged
+func quarantineMessages(ctx context.Context, projectUUID string, topic topics.Topic, rejected []rejectedMessage,
+	refBrk brokers.Broker, refStr stores.Store) {
+
+	_, quarantineName, _ := subscriptions.ExtractFullTopicRef(topic.QuarantineTopic)
+
+	results, err := topics.Find(ctx, projectUUID, "", quarantineName, "", 0, refStr)
+	if err != nil || results.Empty() {
+		log.WithFields(
+			log.Fields{
+				"trace_id":         ctx.Value("trace_id"),
+				"type":             "service_log",
+				"topic_name":       topic.Name,
+				"quarantine_topic": topic.QuarantineTopic,
+			},
+		).Error("Could not retrieve the quarantine topic")
+		return
+	}
+
+	fullTopic := projectUUID + "." + quarantineName
+	published := messages.MsgList{}
+	storedBytes := int64(0)
+
+	for i := range rejected {
+
+		msg := rejected[i].msg
+
+		// keep the original attributes along with the reason of the rejection
+		attr := messages.Attributes{}
+		for k, v := range msg.Attr {
+			attr[k] = v
+		}
+		attr[quarantineSourceTopicAttr] = topic.FullName
+		if len(rejected[i].Errors) > 0 {
+			reason := rejected[i].Errors[0].Message
+			if rejected[i].Errors[0].Path != "" {
+				reason = rejected[i].Errors[0].Path + ": " + reason
+			}
+			attr[quarantineReasonAttr] = reason
+		}
+		msg.Attr = attr
+
+		// the quarantine topic compresses the message with its own algorithm
+		stored := msg
+		err := stored.Compress(results.Topics[0].Compression)
+
+		msgID := ""
+		if err == nil {
+			msgID, _, _, _, err = refBrk.Publish(ctx, fullTopic, stored)
+		}
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"trace_id":         ctx.Value("trace_id"),
+					"type":             "service_log",
+					"topic_name":       topic.Name,
+					"quarantine_topic": topic.QuarantineTopic,
+					"error":            err.Error(),
+				},
+			).Error("Could not publish a rejected message to the quarantine topic")
+			continue
+		}
+
+		rejected[i].QuarantineMessageID = msgID
+		msg.ID = msgID
+		published.Msgs = append(published.Msgs, msg)
+		storedBytes += stored.Size()
+	}
+
+	if len(published.Msgs) > 0 {
+		updateTopicPublishMetrics(ctx, projectUUID, quarantineName, published, storedBytes, results.Topics[0].LatestPublish, refStr)
+
+		// quarantined messages count towards the daily quotas of the project, without being limited by them
+		quotas.RecordPublish(ctx, projectUUID, int64(len(published.Msgs)), published.TotalSize(), time.Now().UTC(), refStr)
+	}
+}
+
+// rememberIdempotencyKey stores the message ids of a reserved idempotency key. The messages are already published
+// so a failure is only logged, the key stays reserved and retries get a conflict until its window passes
+func rememberIdempotencyKey(ctx context.Context, projectUUID string, name string, key string, msgIDs []string,
+	window time.Duration, refStr stores.Store) {
+
+	err := topics.RememberIdempotencyKey(ctx, projectUUID, name, key, msgIDs, window, refStr)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"trace_id":        ctx.Value("trace_id"),
+				"type":            "service_log",
+				"topic_name":      name,
+				"idempotency_key": key,
+				"error":           err.Error(),
+			},
+		).Error("Could not store the idempotency key")
+	}
+}
+
+// releaseIdempotencyKeys gives up the reserved keys of a publish that did not complete.
+// A failure is only logged, the keys stay reserved until their window passes
+func releaseIdempotencyKeys(ctx context.Context, projectUUID string, name string, pendingKeys map[string]bool,
+	refStr stores.Store) {
+
+	keys := []string{}
+	for key := range pendingKeys {
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return
+	}
+
+	err := topics.ReleaseIdempotencyKeys(ctx, projectUUID, name, keys, refStr)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"trace_id":         ctx.Value("trace_id"),
+				"type":             "service_log",
+				"topic_name":       name,
+				"idempotency_keys": keys,
+				"error":            err.Error(),
+			},
+		).Error("Could not release the idempotency keys")
+	}
+}
+
 // TopicListOne (GET) one topic
 func TopicListOne(w http.ResponseWriter, r *http.Request) {
 	traceId := gorillaContext.Get(r, "trace_id").(string)
@@ -590,8 +1039,59 @@ func TopicListAll(w http.ResponseWriter, r *http.Request) {
 	respondOK(w, output)
 }
 
+// msgListLoader creates the list of messages to publish out of the body of a publish request.
+// It returns the error response of the request when the messages can't be loaded.
+type msgListLoader func(r *http.Request, body []byte) (messages.MsgList, *APIErrorRoot)
+
+// loadMsgList loads the messages of a publish request, either from the JSON list of messages or from the cloud events that the request carries
+func loadMsgList(r *http.Request, body []byte) (messages.MsgList, *APIErrorRoot) {
+
+	if messages.IsCloudEventsRequest(r.Header) {
+		msgList, err := messages.LoadCloudEventsHTTP(r.Header, body)
+		if err != nil {
+			err := APIErrorInvalidData(err.Error())
+			return messages.MsgList{}, &err
+		}
+		return msgList, nil
+	}
+
+	msgList, err := messages.LoadMsgListJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Message")
+		return messages.MsgList{}, &err
+	}
+
+	return msgList, nil
+}
+
 // TopicPublish (POST) publish messages to a topic
 func TopicPublish(w http.ResponseWriter, r *http.Request) {
+	publishToTopic(w, r, loadMsgList)
+}
+
+// loadRawMsgList loads the messages of a raw publish request, out of the payloads of its body
+func loadRawMsgList(r *http.Request, body []byte) (messages.MsgList, *APIErrorRoot) {
+
+	msgList, err := messages.LoadRawHTTP(r.Header, r.URL.Query(), body)
+	if err != nil {
+		if err == messages.ErrUnsupportedMediaType {
+			err := APIErrorUnsupportedMediaType(err.Error())
+			return messages.MsgList{}, &err
+		}
+		err := APIErrorInvalidData(err.Error())
+		return messages.MsgList{}, &err
+	}
+
+	return msgList, nil
+}
+
+// TopicPublishRaw (POST) publish raw payloads to a topic, without wrapping them in a JSON list of base64 encoded messages
+func TopicPublishRaw(w http.ResponseWriter, r *http.Request) {
+	publishToTopic(w, r, loadRawMsgList)
+}
+
+// publishToTopic publishes the messages that the given loader extracts from the request
+func publishToTopic(w http.ResponseWriter, r *http.Request, load msgListLoader) {
 	traceId := gorillaContext.Get(r, "trace_id").(string)
 	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
 	// Init output
@@ -610,9 +1110,6 @@ func TopicPublish(w http.ResponseWriter, r *http.Request) {
 
 	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
-	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
-	refRoles := gorillaContext.Get(r, "auth_roles").([]string)
-	refAuthResource := gorillaContext.Get(r, "auth_resource").(bool)
 	// Get project UUID First to use as reference
 	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
 
@@ -633,19 +1130,6 @@ func TopicPublish(w http.ResponseWriter, r *http.Request) {
 
 	res := results.Topics[0]
 
-	// Check Authorization per topic
-	// - if enabled in config
-	// - if user has only publisher role
-
-	if refAuthResource && auth.IsPublisher(refRoles) {
-
-		if auth.PerResource(rCTX, projectUUID, "topics", urlTopic, refUserUUID, refStr) == false {
-			err := APIErrorForbidden()
-			respondErr(rCTX, w, err)
-			return
-		}
-	}
-
 	// Read POST JSON body
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -654,14 +1138,32 @@ func TopicPublish(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create Message List from Post JSON
-	msgList, err := messages.LoadMsgListJSON(body)
-	if err != nil {
-		err := APIErrorInvalidArgument("Message")
-		respondErr(rCTX, w, err)
+	// Create Message List from the request
+	msgList, loadErr := load(r, body)
+	if loadErr != nil {
+		respondErr(rCTX, w, *loadErr)
 		return
 	}
 
+	// validate the delivery options of each message and resolve them to an absolute delivery time
+	scheduleTime := time.Now().UTC()
+	for i := range msgList.Msgs {
+		err := msgList.Msgs[i].ScheduleDelivery(scheduleTime)
+		if err != nil {
+			err := APIErrorInvalidData(err.Error())
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		// decoded payloads are only produced on consumption, while the schema revision and compression are decided by the topic
+		msgList.Msgs[i].DecodedData = nil
+		msgList.Msgs[i].SchemaRevision = 0
+		msgList.Msgs[i].Compression = ""
+	}
+
+	// messages that don't match the topic's schema, when the request accepts only the valid ones
+	rejected := []rejectedMessage{}
+
 	// check if the topic has a schema associated with it
 	if res.Schema != "" {
 
@@ -682,7 +1184,7 @@ func TopicPublish(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		sl, err := schemas.Find(rCTX, projectUUID, "", schemaName, refStr)
+		sl, err := schemas.FindCached(rCTX, projectUUID, schemaName, refStr)
 
 		if err != nil {
 			log.WithFields(
@@ -699,7 +1201,29 @@ func TopicPublish(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if !sl.Empty() {
+		if !sl.Empty() && msgList.AcceptValid {
+			report, err := schemas.ValidateMessagesReport(sl.Schemas[0], msgList)
+			if err != nil {
+				err := APIErrGenericInternal(schemas.GenericError)
+				respondErr(rCTX, w, err)
+				return
+			}
+
+			// only the valid messages get published
+			valid := []messages.Message{}
+			for _, result := range report.Messages {
+				if result.Valid {
+					valid = append(valid, msgList.Msgs[result.Index])
+					continue
+				}
+				rejected = append(rejected, rejectedMessage{
+					Index:  result.Index,
+					Errors: result.Errors,
+					msg:    msgList.Msgs[result.Index],
+				})
+			}
+			msgList.Msgs = valid
+		} else if !sl.Empty() {
 			err := schemas.ValidateMessages(sl.Schemas[0], msgList)
 			if err != nil {
 				if err.Error() == "500" {
@@ -725,72 +1249,167 @@ func TopicPublish(w http.ResponseWriter, r *http.Request) {
 			respondErr(rCTX, w, err)
 			return
 		}
+
+		// the messages keep the revision they were validated against, so that they get decoded with it
+		for i := range msgList.Msgs {
+			msgList.Msgs[i].SchemaRevision = sl.Schemas[0].Revision
+		}
 	}
 
+	// reserve the idempotency keys of the request before publishing, if deduplication is enabled
+	idempotencyWindow := time.Duration(gorillaContext.Get(r, "idempotency_window").(int)) * time.Second
+	knownMsgIDs := map[string][]string{}
+	// keys reserved by the request that have not been stored along with their message ids yet
+	pendingKeys := map[string]bool{}
+
 	// Init message ids list
 	msgIDs := messages.MsgIDs{IDs: []string{}}
 
-	// For each message in message list
-	for _, msg := range msgList.Msgs {
-		// Get offset and set it as msg
-		fullTopic := projectUUID + "." + urlTopic
-
-		msgID, rTop, _, _, err := refBrk.Publish(rCTX, fullTopic, msg)
-
+	if idempotencyWindow > 0 {
+		keys := msgList.IdempotencyKeys()
+		knownMsgIDs, err = topics.ReserveIdempotencyKeys(rCTX, projectUUID, urlTopic, keys, idempotencyWindow, refStr)
 		if err != nil {
-			if err.Error() == "kafka server: Message was too large, server rejected it to avoid allocation error." {
-				err := APIErrTooLargeMessage("Message size too large")
+			if err == topics.ErrIdempotencyKeyInFlight {
+				err := APIErrorGenericConflict("A publish with the same idempotency key is in progress")
 				respondErr(rCTX, w, err)
 				return
 			}
-
 			err := APIErrGenericBackend()
 			respondErr(rCTX, w, err)
 			return
 		}
 
-		msg.ID = msgID
-		// Assertions for Successful Publish
-		if rTop != fullTopic {
-			err := APIErrGenericInternal("Broker reports wrong topic")
+		for _, key := range keys {
+			if _, found := knownMsgIDs[key]; !found {
+				pendingKeys[key] = true
+			}
+		}
+
+		// a publish that fails gives its keys back, so that it can be retried. A request key whose messages
+		// partly reached the broker keeps their ids instead, so that a retry doesn't publish them again
+		defer func() {
+			if key := msgList.IdempotencyKey; pendingKeys[key] && len(msgIDs.IDs) > 0 {
+				rememberIdempotencyKey(rCTX, projectUUID, urlTopic, key, msgIDs.IDs, idempotencyWindow, refStr)
+				delete(pendingKeys, key)
+			}
+			releaseIdempotencyKeys(rCTX, projectUUID, urlTopic, pendingKeys, refStr)
+		}()
+	}
+
+	// a retried request returns the message ids of its original publish
+	if ids, found := knownMsgIDs[msgList.IdempotencyKey]; found && msgList.IdempotencyKey != "" {
+		msgIDs.IDs = ids
+	} else {
+
+		// count the messages towards the daily quotas of the project, if they fit in them
+		reservedOn := time.Now().UTC()
+		exceeded, err := quotas.ReservePublish(rCTX, projectUUID, int64(len(msgList.Msgs)), msgList.TotalSize(), reservedOn, refStr)
+		if err != nil {
+			err := APIErrGenericBackend()
+			respondErr(rCTX, w, err)
+			return
+		}
+		if exceeded != "" {
+			err := APIErrorQuotaExceeded(exceeded)
 			respondErr(rCTX, w, err)
 			return
 		}
 
-		// Append the MsgID of the successful published message to the msgIds list
-		msgIDs.IDs = append(msgIDs.IDs, msg.ID)
-	}
+		// messages that actually reach the broker
+		published := messages.MsgList{}
+		storedBytes := int64(0)
 
-	// timestamp of the publish event
-	publishTime := time.Now().UTC()
+		// the messages that don't get published, e.g. because of a broker error, are given back to the daily quotas
+		defer func() {
+			quotas.ReleasePublish(rCTX, projectUUID, int64(len(msgList.Msgs)-len(published.Msgs)),
+				msgList.TotalSize()-published.TotalSize(), reservedOn, refStr)
+		}()
 
-	// amount of messages published
-	msgCount := int64(len(msgList.Msgs))
+		// For each message in message list
+		for _, msg := range msgList.Msgs {
 
-	// increment topic number of message metric
-	refStr.IncrementTopicMsgNum(rCTX, projectUUID, urlTopic, msgCount)
+			// message level keys only apply when the request itself has no key
+			msgKey := ""
+			if msgList.IdempotencyKey == "" && idempotencyWindow > 0 {
+				msgKey = msg.IdempotencyKey
+			}
 
-	// increment daily count of topic messages
-	year, month, day := publishTime.Date()
-	refStr.IncrementDailyTopicMsgCount(rCTX, projectUUID, urlTopic, msgCount, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+			if ids, found := knownMsgIDs[msgKey]; found && msgKey != "" && len(ids) > 0 {
+				msgIDs.IDs = append(msgIDs.IDs, ids[0])
+				continue
+			}
 
-	// increment topic total bytes published
-	refStr.IncrementTopicBytes(rCTX, projectUUID, urlTopic, msgList.TotalSize())
+			// compress the payload, if the topic stores its messages compressed
+			stored := msg
+			err := stored.Compress(res.Compression)
+			if err != nil {
+				if err == messages.ErrPayloadTooLarge {
+					err := APIErrTooLargeMessage("Message size too large")
+					respondErr(rCTX, w, err)
+					return
+				}
+				err := APIErrorInvalidData(err.Error())
+				respondErr(rCTX, w, err)
+				return
+			}
 
-	// update latest publish date for the given topic
-	refStr.UpdateTopicLatestPublish(rCTX, projectUUID, urlTopic, publishTime)
+			// Get offset and set it as msg
+			fullTopic := projectUUID + "." + urlTopic
 
-	// count the rate of published messages per sec between the last two publish events
-	var dt float64 = 1
-	// if its the first publish to the topic
-	// skip the subtraction that computes the DT between the last two publish events
-	if !res.LatestPublish.IsZero() {
-		dt = publishTime.Sub(res.LatestPublish).Seconds()
+			msgID, rTop, _, _, err := refBrk.Publish(rCTX, fullTopic, stored)
+
+			if err != nil {
+				if err.Error() == "kafka server: Message was too large, server rejected it to avoid allocation error." {
+					err := APIErrTooLargeMessage("Message size too large")
+					respondErr(rCTX, w, err)
+					return
+				}
+
+				err := APIErrGenericBackend()
+				respondErr(rCTX, w, err)
+				return
+			}
+
+			msg.ID = msgID
+			// Assertions for Successful Publish
+			if rTop != fullTopic {
+				err := APIErrGenericInternal("Broker reports wrong topic")
+				respondErr(rCTX, w, err)
+				return
+			}
+
+			if msgKey != "" {
+				knownMsgIDs[msgKey] = []string{msg.ID}
+				rememberIdempotencyKey(rCTX, projectUUID, urlTopic, msgKey, []string{msg.ID}, idempotencyWindow, refStr)
+				delete(pendingKeys, msgKey)
+			}
+
+			// Append the MsgID of the successful published message to the msgIds list
+			msgIDs.IDs = append(msgIDs.IDs, msg.ID)
+			published.Msgs = append(published.Msgs, msg)
+			storedBytes += stored.Size()
+		}
+
+		if msgList.IdempotencyKey != "" && idempotencyWindow > 0 {
+			rememberIdempotencyKey(rCTX, projectUUID, urlTopic, msgList.IdempotencyKey, msgIDs.IDs, idempotencyWindow, refStr)
+			delete(pendingKeys, msgList.IdempotencyKey)
+		}
+
+		// update the topic metrics only if new messages got published
+		if len(published.Msgs) > 0 {
+			updateTopicPublishMetrics(rCTX, projectUUID, urlTopic, published, storedBytes, res.LatestPublish, refStr)
+		}
+
+		if len(rejected) > 0 && res.QuarantineTopic != "" {
+			quarantineMessages(rCTX, projectUUID, res, rejected, refBrk, refStr)
+		}
 	}
-	refStr.UpdateTopicPublishRate(rCTX, projectUUID, urlTopic, float64(msgCount)/dt)
 
 	// Export the msgIDs
 	resJSON, err := msgIDs.ExportJSON()
+	if msgList.AcceptValid {
+		resJSON, err = publishResult{MsgIDs: msgIDs, Rejected: rejected}.ExportJSON()
+	}
 	if err != nil {
 		err := APIErrExportJSON()
 		respondErr(rCTX , w, err)
@@ -3,13 +3,18 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/messages"
 	oldPush "github.com/ARGOeu/argo-messaging/push"
 	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
+	"github.com/ARGOeu/argo-messaging/schemas"
 	"github.com/ARGOeu/argo-messaging/stores"
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/suite"
 	"io/ioutil"
 	"log"
@@ -72,6 +77,115 @@ func (suite *TopicsHandlersTestSuite) TestTopicDeleteNotfound() {
 
 }
 
+func (suite *TopicsHandlersTestSuite) TestTopicsReconcile() {
+
+	type td struct {
+		url             string
+		expectedStatus  int
+		expectedResp    string
+		remainingTopics int
+		msg             string
+	}
+
+	testData := []td{
+		{
+			url:            "http://localhost:8080/v1/topics:reconcile?dry_run=maybe",
+			expectedStatus: 400,
+			expectedResp: `{
+   "error": {
+      "code": 400,
+      "message": "dry_run is not a valid boolean value",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			remainingTopics: 3,
+			msg:             "Case where the dry_run parameter is not a boolean",
+		},
+		{
+			url:            "http://localhost:8080/v1/topics:reconcile?dry_run=true",
+			expectedStatus: 200,
+			expectedResp: `{
+   "dry_run": true,
+   "broker_orphans": [
+      "argo_uuid.topicOrphan"
+   ],
+   "store_orphans": [
+      "/projects/ARGO/topics/topic3",
+      "/projects/ARGO/topics/topic4"
+   ],
+   "deleted": [],
+   "failed": []
+}`,
+			remainingTopics: 3,
+			msg:             "Case where the orphans are only reported",
+		},
+		{
+			url:            "http://localhost:8080/v1/topics:reconcile",
+			expectedStatus: 200,
+			expectedResp: `{
+   "dry_run": true,
+   "broker_orphans": [
+      "argo_uuid.topicOrphan"
+   ],
+   "store_orphans": [
+      "/projects/ARGO/topics/topic3",
+      "/projects/ARGO/topics/topic4"
+   ],
+   "deleted": [],
+   "failed": []
+}`,
+			remainingTopics: 3,
+			msg:             "Case where the orphans are only reported by default",
+		},
+		{
+			url:            "http://localhost:8080/v1/topics:reconcile?dry_run=false",
+			expectedStatus: 200,
+			expectedResp: `{
+   "dry_run": false,
+   "broker_orphans": [
+      "argo_uuid.topicOrphan"
+   ],
+   "store_orphans": [
+      "/projects/ARGO/topics/topic3",
+      "/projects/ARGO/topics/topic4"
+   ],
+   "deleted": [
+      "argo_uuid.topicOrphan"
+   ],
+   "failed": []
+}`,
+			remainingTopics: 2,
+			msg:             "Case where the broker orphans are removed",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{Topics: map[string]string{
+		"argo_uuid.topic1":      "",
+		"argo_uuid.topic2":      "",
+		"argo_uuid.topicOrphan": "",
+	}}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+
+	for _, t := range testData {
+
+		req, err := http.NewRequest("POST", t.url, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		router := mux.NewRouter().StrictSlash(true)
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/topics:reconcile", WrapMockAuthConfig(TopicsReconcile, cfgKafka, &brk, str, &mgr, nil))
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatus, w.Code, t.msg)
+		suite.Equal(t.expectedResp, w.Body.String(), t.msg)
+		suite.Equal(t.remainingTopics, len(brk.Topics), t.msg)
+	}
+}
+
 func (suite *TopicsHandlersTestSuite) TestTopicCreate() {
 
 	req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topicNew", nil)
@@ -100,6 +214,149 @@ func (suite *TopicsHandlersTestSuite) TestTopicCreate() {
 
 }
 
+func (suite *TopicsHandlersTestSuite) TestTopicCreateWithMessageTTL() {
+
+	type td struct {
+		body               string
+		expectedStatusCode int
+		expectedResponse   string
+		msg                string
+	}
+
+	testData := []td{
+		{
+			body:               `{"messageTTL": 3600}`,
+			expectedStatusCode: 200,
+			expectedResponse: `{
+   "name": "/projects/ARGO/topics/topicNew",
+   "created_on": "{{CON}}",
+   "messageTTL": 3600
+}`,
+			msg: "Create a topic with a message ttl",
+		},
+		{
+			body:               `{"messageTTL": -10}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "messageTTL should be a non negative number of seconds",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Create a topic with a negative message ttl",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	mgr := oldPush.Manager{}
+
+	for _, t := range testData {
+		brk := brokers.MockBroker{}
+		str := stores.NewMockStore("whatever", "argo_mgs")
+		req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topicNew", strings.NewReader(t.body))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router := mux.NewRouter().StrictSlash(true)
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicCreate, cfgKafka, &brk, str, &mgr, nil))
+		router.ServeHTTP(w, req)
+		if t.expectedStatusCode == 200 {
+			tp, _, _, _ := str.QueryTopics(suite.ctx, "argo_uuid", "", "topicNew", "", 1)
+			t.expectedResponse = strings.Replace(t.expectedResponse, "{{CON}}", tp[0].CreatedOn.Format("2006-01-02T15:04:05Z"), 1)
+			suite.Equal(int64(3600), tp[0].MessageTTL, t.msg)
+		}
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+	}
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicModMessageTTL() {
+
+	type td struct {
+		topic              string
+		body               string
+		expectedStatusCode int
+		expectedResponse   string
+		msg                string
+	}
+
+	testData := []td{
+		{
+			topic:              "topic1",
+			body:               `{"messageTTL": 120}`,
+			expectedStatusCode: 200,
+			expectedResponse:   "",
+			msg:                "Modify the message ttl of a topic",
+		},
+		{
+			topic:              "topic1",
+			body:               `{"messageTTL": -1}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Invalid messageTTL(needs a non negative number of seconds) Arguments",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Modify the message ttl of a topic with a negative value",
+		},
+		{
+			topic:              "topic1",
+			body:               `{"messageTTL": "a"}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Invalid messageTTL(needs a non negative number of seconds) Arguments",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Modify the message ttl of a topic with an invalid value",
+		},
+		{
+			topic:              "unknown",
+			body:               `{"messageTTL": 120}`,
+			expectedStatusCode: 404,
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "Topic doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			msg: "Modify the message ttl of a topic that doesn't exist",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	mgr := oldPush.Manager{}
+
+	for _, t := range testData {
+		brk := brokers.MockBroker{}
+		str := stores.NewMockStore("whatever", "argo_mgs")
+		url := fmt.Sprintf("http://localhost:8080/v1/projects/ARGO/topics/%v:modifyMessageTTL", t.topic)
+		req, err := http.NewRequest("POST", url, strings.NewReader(t.body))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router := mux.NewRouter().StrictSlash(true)
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/projects/{project}/topics/{topic}:modifyMessageTTL", WrapMockAuthConfig(TopicModMessageTTL, cfgKafka, &brk, str, &mgr, nil))
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+		if t.expectedStatusCode == 200 {
+			tp, _, _, _ := str.QueryTopics(suite.ctx, "argo_uuid", "", "topic1", "", 1)
+			suite.Equal(int64(120), tp[0].MessageTTL, t.msg)
+		}
+	}
+}
+
 func (suite *TopicsHandlersTestSuite) TestTopicAttachSchema() {
 
 	body := `{
@@ -686,6 +943,9 @@ func (suite *TopicsHandlersTestSuite) TestPublishWithSchema() {
 		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
 		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
 	}
+	// the published messages remember the schema revision they were validated against
+	published, _ := messages.LoadMsgJSON([]byte(brk.MsgList[0]))
+	suite.Equal(int64(1), published.SchemaRevision)
 }
 
 func (suite *TopicsHandlersTestSuite) TestTopicListAllFirstPage() {
@@ -949,88 +1209,1006 @@ func (suite *TopicsHandlersTestSuite) TestPublishMultiple() {
 
 }
 
-func (suite *TopicsHandlersTestSuite) TestPublishError() {
+func (suite *TopicsHandlersTestSuite) TestPublishCloudEvents() {
 
-	postJSON := `{
-  "messages": [
-    {
-      "attributes": [
-        {
-          "key": "foo",
-          "valu2RlZA=="
-    },
-    {
-      "attributes": [
-        {
-          "key": "foo2",
-          "value": "bar2"
-        }
-      ],
-      "data": "YmFzZTY0ZW5jb2RlZA=="
-    }
-  ]
-}`
-	url := "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish"
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
-	if err != nil {
-		log.Fatal(err)
+	type td struct {
+		body            string
+		header          map[string]string
+		expectedStatus  int
+		expectedMessage string
+		expectedResp    string
+		msg             string
 	}
 
-	expJSON := `{
+	testData := []td{
+		{
+			body:   `{"specversion":"1.0","id":"e1","source":"/sensors/1","type":"reading","data":{"value":21}}`,
+			header: map[string]string{"Content-Type": "application/cloudevents+json; charset=utf-8"},
+			expectedMessage: `{
+   "attributes": {"ce-id":"e1", "ce-source":"/sensors/1", "ce-specversion":"1.0", "ce-type":"reading"},
+   "data": "eyJ2YWx1ZSI6MjF9"
+}`,
+			expectedStatus: 200,
+			expectedResp: `{
+   "messageIds": [
+      "1"
+   ]
+}`,
+			msg: "Publish an event in structured mode",
+		},
+		{
+			body: "hello",
+			header: map[string]string{
+				"Content-Type":   "text/plain",
+				"ce-specversion": "1.0",
+				"ce-id":          "e2",
+				"ce-source":      "/sensors/1",
+				"ce-type":        "note",
+			},
+			expectedMessage: `{
+   "attributes": {"ce-datacontenttype":"text/plain", "ce-id":"e2", "ce-source":"/sensors/1", "ce-specversion":"1.0", "ce-type":"note"},
+   "data": "aGVsbG8="
+}`,
+			expectedStatus: 200,
+			expectedResp: `{
+   "messageIds": [
+      "1"
+   ]
+}`,
+			msg: "Publish an event in binary mode",
+		},
+		{
+			body:           `[{"specversion":"1.0","id":"e3","source":"s","type":"t"},{"specversion":"1.0","id":"e4","source":"s"}]`,
+			header:         map[string]string{"Content-Type": "application/cloudevents-batch+json"},
+			expectedStatus: 400,
+			expectedResp: `{
    "error": {
       "code": 400,
-      "message": "Invalid Message Arguments",
+      "message": "Cloud event should declare an id, a source and a type",
       "status": "INVALID_ARGUMENT"
    }
-}`
+}`,
+			msg: "Publish a batch of events with an invalid one",
+		},
+		{
+			body:           `{"specversion":"1.0","id":"e1","source":"s","type":"t","data":1,"data_base64":"AA=="}`,
+			header:         map[string]string{"Content-Type": "application/cloudevents+json"},
+			expectedStatus: 400,
+			expectedResp: `{
+   "error": {
+      "code": 400,
+      "message": "Cloud event data and data_base64 cannot be used together",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Publish an event with both data and data_base64",
+		},
+	}
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
-	brk := brokers.MockBroker{}
-	str := stores.NewMockStore("whatever", "argo_mgs")
-	router := mux.NewRouter().StrictSlash(true)
-	w := httptest.NewRecorder()
-	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
-	router.ServeHTTP(w, req)
-	suite.Equal(400, w.Code)
-	suite.Equal(expJSON, w.Body.String())
 
+	for _, t := range testData {
+
+		brk := brokers.MockBroker{}
+		brk.Initialize([]string{"localhost"})
+		str := stores.NewMockStore("whatever", "argo_mgs")
+		router := mux.NewRouter().StrictSlash(true)
+		mgr := oldPush.Manager{}
+		router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+
+		url := "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish"
+		req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(t.body)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		for k, v := range t.header {
+			req.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatus, w.Code, t.msg)
+		suite.Equal(t.expectedResp, w.Body.String(), t.msg)
+
+		if t.expectedStatus == 200 {
+			suite.JSONEq(t.expectedMessage, brk.MsgList[0], t.msg)
+		} else {
+			suite.Equal(0, len(brk.MsgList), t.msg)
+		}
+	}
 }
 
-func (suite *TopicsHandlersTestSuite) TestPublishNoTopic() {
+func (suite *TopicsHandlersTestSuite) TestPublishRaw() {
 
-	postJSON := `{
-  "messages": [
-		{
-			"attributes": [
-				{
-					"key": "foo",
-					"value": "bar"
-				}
-			],
-			"data": "YmFzZTY0ZW5jb2RlZA=="
-		},
-    {
-      "attributes": [
-        {
-          "key": "foo2",
-          "value": "bar2"
-        }
-      ],
-      "data": "YmFzZTY0ZW5jb2RlZA=="
-    }
-  ]
-}`
-	url := "http://localhost:8080/v1/projects/ARGO/topics/FOO:publish"
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
-	if err != nil {
-		log.Fatal(err)
+	type td struct {
+		body             string
+		query            string
+		header           map[string]string
+		expectedStatus   int
+		expectedMessages []string
+		expectedResp     string
+		msg              string
 	}
 
-	expJSON := `{
-   "error": {
-      "code": 404,
+	testData := []td{
+		{
+			body:   "hello",
+			query:  "?attributes.source=sensor",
+			header: map[string]string{"Content-Type": "application/octet-stream", "X-Attribute-Unit": "celsius"},
+			expectedMessages: []string{`{
+   "attributes": {"source":"sensor", "unit":"celsius"},
+   "data": "aGVsbG8="
+}`},
+			expectedStatus: 200,
+			expectedResp: `{
+   "messageIds": [
+      "1"
+   ]
+}`,
+			msg: "Publish a single raw payload",
+		},
+		{
+			body: "--b\r\nX-Attribute-Unit: celsius\r\n\r\nfirst\r\n--b\r\n\r\nsecond\r\n--b--\r\n",
+			header: map[string]string{
+				"Content-Type":       "multipart/mixed; boundary=b",
+				"X-Attribute-Source": "sensor",
+			},
+			expectedMessages: []string{
+				`{"attributes": {"source":"sensor", "unit":"celsius"}, "data": "Zmlyc3Q="}`,
+				`{"attributes": {"source":"sensor"}, "data": "c2Vjb25k"}`,
+			},
+			expectedStatus: 200,
+			expectedResp: `{
+   "messageIds": [
+      "1",
+      "2"
+   ]
+}`,
+			msg: "Publish several raw payloads through a multipart body",
+		},
+		{
+			body:           "--b--\r\n",
+			header:         map[string]string{"Content-Type": "multipart/mixed; boundary=b"},
+			expectedStatus: 400,
+			expectedResp: `{
+   "error": {
+      "code": 400,
+      "message": "Multipart body should carry at least one message",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Publish an empty multipart body",
+		},
+		{
+			body:           "hello",
+			query:          "?delaySeconds=soon",
+			header:         map[string]string{"Content-Type": "application/octet-stream"},
+			expectedStatus: 400,
+			expectedResp: `{
+   "error": {
+      "code": 400,
+      "message": "delaySeconds should be a non negative number",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Publish a raw payload with an invalid delay",
+		},
+		{
+			body:           "unit=celsius",
+			header:         map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			expectedStatus: 415,
+			expectedResp: `{
+   "error": {
+      "code": 415,
+      "message": "Unsupported media type, the body should be application/octet-stream, application/json, text/plain or multipart",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Publish a raw payload with an unsupported media type",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+
+	for _, t := range testData {
+
+		brk := brokers.MockBroker{}
+		brk.Initialize([]string{"localhost"})
+		str := stores.NewMockStore("whatever", "argo_mgs")
+		router := mux.NewRouter().StrictSlash(true)
+		mgr := oldPush.Manager{}
+		router.HandleFunc("/v1/projects/{project}/topics/{topic}:publishRaw", WrapMockAuthConfig(TopicPublishRaw, cfgKafka, &brk, str, &mgr, nil))
+
+		url := "http://localhost:8080/v1/projects/ARGO/topics/topic1:publishRaw" + t.query
+		req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(t.body)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		for k, v := range t.header {
+			req.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatus, w.Code, t.msg)
+		suite.Equal(t.expectedResp, w.Body.String(), t.msg)
+
+		suite.Equal(len(t.expectedMessages), len(brk.MsgList), t.msg)
+		for i, expectedMsg := range t.expectedMessages {
+			suite.JSONEq(expectedMsg, brk.MsgList[i], t.msg)
+		}
+	}
+}
+
+// failingBroker fails every publish after the first ones
+type failingBroker struct {
+	brokers.MockBroker
+	failAfter int
+	published int
+}
+
+func (b *failingBroker) Publish(ctx context.Context, topic string, msg messages.Message) (string, string, int, int64, error) {
+	if b.published >= b.failAfter {
+		return "", "", 0, 0, errors.New("broker unavailable")
+	}
+	b.published++
+	return b.MockBroker.Publish(ctx, topic, msg)
+}
+
+func (suite *TopicsHandlersTestSuite) TestPublishIdempotent() {
+
+	type td struct {
+		postJSON           string
+		expectedResponse   string
+		expectedBrokerMsgs int
+		msg                string
+	}
+
+	testData := []td{
+		{
+			postJSON:           `{"messages":[{"data":"YmFzZTY0ZW5jb2RlZA==","idempotencyKey":"k1"},{"data":"YmFzZTY0ZW5jb2RlZA=="}]}`,
+			expectedResponse:   "{\n   \"messageIds\": [\n      \"1\",\n      \"2\"\n   ]\n}",
+			expectedBrokerMsgs: 2,
+			msg:                "Publish a message with an idempotency key",
+		},
+		{
+			postJSON:           `{"messages":[{"data":"YmFzZTY0ZW5jb2RlZA==","idempotencyKey":"k1"},{"data":"YmFzZTY0ZW5jb2RlZA=="}]}`,
+			expectedResponse:   "{\n   \"messageIds\": [\n      \"1\",\n      \"3\"\n   ]\n}",
+			expectedBrokerMsgs: 3,
+			msg:                "Retry the publish, only the message without a key gets published again",
+		},
+		{
+			postJSON:           `{"idempotencyKey":"req1","messages":[{"data":"YmFzZTY0ZW5jb2RlZA=="},{"data":"YmFzZTY0ZW5jb2RlZA==","idempotencyKey":"k1"}]}`,
+			expectedResponse:   "{\n   \"messageIds\": [\n      \"4\",\n      \"5\"\n   ]\n}",
+			expectedBrokerMsgs: 5,
+			msg:                "Publish a request with an idempotency key, message keys are ignored",
+		},
+		{
+			postJSON:           `{"idempotencyKey":"req1","messages":[{"data":"YmFzZTY0ZW5jb2RlZA=="},{"data":"YmFzZTY0ZW5jb2RlZA=="}]}`,
+			expectedResponse:   "{\n   \"messageIds\": [\n      \"4\",\n      \"5\"\n   ]\n}",
+			expectedBrokerMsgs: 5,
+			msg:                "Retry the request, nothing gets published",
+		},
+		{
+			postJSON:           `{"messages":[{"data":"YmFzZTY0ZW5jb2RlZA==","idempotencyKey":"k2"},{"data":"YmFzZTY0ZW5jb2RlZA==","idempotencyKey":"k2"}]}`,
+			expectedResponse:   "{\n   \"messageIds\": [\n      \"6\",\n      \"6\"\n   ]\n}",
+			expectedBrokerMsgs: 6,
+			msg:                "Publish the same key twice in the same request",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.IdempotencyWindow = 600
+	mgr := oldPush.Manager{}
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	str := stores.NewMockStore("whatever", "argo_mgs")
+
+	for _, t := range testData {
+		url := "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish"
+		req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(t.postJSON)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router := mux.NewRouter().StrictSlash(true)
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+		suite.Equal(t.expectedBrokerMsgs, len(brk.MsgList), t.msg)
+	}
+
+	suite.Equal(3, len(str.IdempotencyKeys))
+	for _, k := range str.IdempotencyKeys {
+		suite.False(k.Pending)
+	}
+
+	// only the messages that reached the broker are counted
+	tp, _, _, _ := str.QueryTopics(context.Background(), "argo_uuid", "", "topic1", "", 0)
+	suite.Equal(int64(6), tp[0].MsgNum)
+
+	publish := func(s *stores.MockStore, postJSON string) *httptest.ResponseRecorder {
+		url := "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish"
+		req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router := mux.NewRouter().StrictSlash(true)
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, s, &mgr, nil))
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// a key that another publish has reserved and not completed yet is a conflict
+	str.IdempotencyKeys = append(str.IdempotencyKeys, stores.QIdempotencyKey{ProjectUUID: "argo_uuid", Topic: "topic1",
+		Key: "req2", MessageIDs: []string{}, Pending: true, ExpiresOn: time.Now().UTC().Add(time.Hour)})
+	w := publish(str, `{"idempotencyKey":"req2","messages":[{"data":"YmFzZTY0ZW5jb2RlZA=="}]}`)
+	suite.Equal(409, w.Code)
+	suite.Contains(w.Body.String(), "A publish with the same idempotency key is in progress")
+	suite.Equal(6, len(brk.MsgList))
+
+	// a publish that fails releases its keys, so that it can be retried
+	failing := &failingBroker{}
+	failing.Initialize([]string{"localhost"})
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, failing, str, &mgr, nil))
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish",
+		bytes.NewBuffer([]byte(`{"messages":[{"data":"YmFzZTY0ZW5jb2RlZA==","idempotencyKey":"k3"},{"data":"YmFzZTY0ZW5jb2RlZA==","idempotencyKey":"k4"}]}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(500, w.Code)
+	suite.Equal(4, len(str.IdempotencyKeys))
+
+	// a request key whose publish fails midway keeps the ids of the messages that reached the broker
+	failing = &failingBroker{failAfter: 1}
+	failing.Initialize([]string{"localhost"})
+	router = mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, failing, str, &mgr, nil))
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish",
+		bytes.NewBuffer([]byte(`{"idempotencyKey":"req3","messages":[{"data":"YmFzZTY0ZW5jb2RlZA=="},{"data":"YmFzZTY0ZW5jb2RlZA=="}]}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(500, w.Code)
+	suite.Equal(1, len(failing.MsgList))
+
+	// retrying it returns the ids of the published messages instead of publishing them again
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish",
+		bytes.NewBuffer([]byte(`{"idempotencyKey":"req3","messages":[{"data":"YmFzZTY0ZW5jb2RlZA=="},{"data":"YmFzZTY0ZW5jb2RlZA=="}]}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("{\n   \"messageIds\": [\n      \"1\"\n   ]\n}", w.Body.String())
+	suite.Equal(1, len(failing.MsgList))
+	// expired keys no longer deduplicate
+	str.IdempotencyKeys = str.IdempotencyKeys[:3]
+	for i := range str.IdempotencyKeys {
+		str.IdempotencyKeys[i].ExpiresOn = time.Now().UTC().Add(-time.Second)
+	}
+
+	// a zero window disables deduplication
+	cfgNoDedup := config.NewAPICfg()
+	cfgNoDedup.LoadStrJSON(suite.cfgStr)
+	str2 := stores.NewMockStore("whatever", "argo_mgs")
+	str2.IdempotencyKeys = []stores.QIdempotencyKey{{ProjectUUID: "argo_uuid", Topic: "topic1", Key: "k1",
+		MessageIDs: []string{"1"}, ExpiresOn: time.Now().UTC().Add(time.Hour)}}
+
+	for _, tc := range []struct {
+		cfg    *config.APICfg
+		str    *stores.MockStore
+		expMsg string
+	}{
+		{cfgKafka, str, "expired key"},
+		{cfgNoDedup, str2, "zero window"},
+	} {
+		before := len(brk.MsgList)
+		url := "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish"
+		req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(`{"messages":[{"data":"YmFzZTY0ZW5jb2RlZA==","idempotencyKey":"k1"}]}`)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router := mux.NewRouter().StrictSlash(true)
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, tc.cfg, &brk, tc.str, &mgr, nil))
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code, tc.expMsg)
+		suite.Equal(before+1, len(brk.MsgList), tc.expMsg)
+	}
+}
+
+func (suite *TopicsHandlersTestSuite) TestPublishAcceptValid() {
+
+	// {"name":"name-1", "email": "test@example.com"}
+	validMsg := `{"attributes":{"foo":"bar"},"data":"eyJuYW1lIjoibmFtZS0xIiwgImVtYWlsIjogInRlc3RAZXhhbXBsZS5jb20ifQ=="}`
+	// {"name":"name-1","address":"Street 13","telephone":6948567889}
+	invalidMsg := `{"attributes":{"foo":"bar"},"data":"eyJuYW1lIjoibmFtZS0xIiwiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6Njk0ODU2Nzg4OX0="}`
+
+	type td struct {
+		postJSON           string
+		quarantineTopic    string
+		expectedStatusCode int
+		expectedResponse   string
+		expectedBrokerMsgs int
+		msg                string
+	}
+
+	testData := []td{
+		{
+			postJSON:           fmt.Sprintf(`{"acceptValid":true,"messages":[%s,%s,{"data":"not-base64"}]}`, validMsg, invalidMsg),
+			expectedStatusCode: 200,
+			expectedResponse: `{
+   "messageIds": [
+      "1"
+   ],
+   "rejected": [
+      {
+         "index": 1,
+         "errors": [
+            {
+               "path": "$.email",
+               "message": "email is required"
+            },
+            {
+               "path": "$.telephone",
+               "message": "Invalid type. Expected: string, given: integer"
+            }
+         ]
+      },
+      {
+         "index": 2,
+         "errors": [
+            {
+               "message": "payload is not in valid base64 encoding, illegal base64 data at input byte 3"
+            }
+         ]
+      }
+   ]
+}`,
+			expectedBrokerMsgs: 1,
+			msg:                "Publish only the valid messages",
+		},
+		{
+			postJSON:           fmt.Sprintf(`{"acceptValid":true,"messages":[%s,%s]}`, validMsg, invalidMsg),
+			quarantineTopic:    "topic1",
+			expectedStatusCode: 200,
+			expectedResponse: `{
+   "messageIds": [
+      "1"
+   ],
+   "rejected": [
+      {
+         "index": 1,
+         "errors": [
+            {
+               "path": "$.email",
+               "message": "email is required"
+            },
+            {
+               "path": "$.telephone",
+               "message": "Invalid type. Expected: string, given: integer"
+            }
+         ],
+         "quarantineMessageId": "2"
+      }
+   ]
+}`,
+			expectedBrokerMsgs: 2,
+			msg:                "Publish the rejected messages to the quarantine topic",
+		},
+		{
+			postJSON:           fmt.Sprintf(`{"acceptValid":true,"messages":[%s]}`, validMsg),
+			expectedStatusCode: 200,
+			expectedResponse: `{
+   "messageIds": [
+      "1"
+   ],
+   "rejected": []
+}`,
+			expectedBrokerMsgs: 1,
+			msg:                "Publish only valid messages",
+		},
+		{
+			postJSON:           fmt.Sprintf(`{"messages":[%s,%s]}`, validMsg, invalidMsg),
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Message 1 data is not valid.1)(root): email is required.2)telephone: Invalid type. Expected: string, given: integer.",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			expectedBrokerMsgs: 0,
+			msg:                "The whole request is rejected without acceptValid",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	mgr := oldPush.Manager{}
+
+	for _, t := range testData {
+		brk := brokers.MockBroker{}
+		str := stores.NewMockStore("whatever", "argo_mgs")
+		if t.quarantineTopic != "" {
+			str.ModTopicQuarantineTopic(suite.ctx, "argo_uuid", "topic2", t.quarantineTopic)
+		}
+		url := "http://localhost:8080/v1/projects/ARGO/topics/topic2:publish"
+		req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(t.postJSON)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router := mux.NewRouter().StrictSlash(true)
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+		suite.Equal(t.expectedBrokerMsgs, len(brk.MsgList), t.msg)
+
+		if t.quarantineTopic != "" {
+			// the quarantined message keeps its attributes along with the reason of the rejection
+			quarantined, _ := messages.LoadMsgJSON([]byte(brk.MsgList[1]))
+			suite.Equal(messages.Attributes{
+				"foo":                  "bar",
+				"ams_source_topic":     "/projects/ARGO/topics/topic2",
+				"ams_rejection_reason": "$.email: email is required",
+			}, quarantined.Attr, t.msg)
+
+			tp, _, _, _ := str.QueryTopics(suite.ctx, "argo_uuid", "", t.quarantineTopic, "", 0)
+			suite.Equal(int64(1), tp[0].MsgNum, t.msg)
+		}
+	}
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicModQuarantineTopic() {
+
+	type td struct {
+		topic                   string
+		body                    string
+		expectedStatusCode      int
+		expectedResponse        string
+		expectedQuarantineTopic string
+		msg                     string
+	}
+
+	testData := []td{
+		{
+			topic:                   "topic1",
+			body:                    `{"quarantineTopic": "projects/ARGO/topics/topic2"}`,
+			expectedStatusCode:      200,
+			expectedResponse:        "",
+			expectedQuarantineTopic: "topic2",
+			msg:                     "Modify the quarantine topic of a topic",
+		},
+		{
+			topic:                   "topic1",
+			body:                    `{"quarantineTopic": ""}`,
+			expectedStatusCode:      200,
+			expectedResponse:        "",
+			expectedQuarantineTopic: "",
+			msg:                     "Disable the quarantine of a topic",
+		},
+		{
+			topic:              "topic1",
+			body:               `{"quarantineTopic": "projects/ARGO2/topics/topic2"}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "quarantineTopic should be a topic of the same project, e.g. projects/{project}/topics/{topic}",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Modify the quarantine topic with a topic of another project",
+		},
+		{
+			topic:              "topic1",
+			body:               `{"quarantineTopic": "projects/ARGO/topics/topic1"}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "A topic can't be its own quarantine topic",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Modify the quarantine topic with the topic itself",
+		},
+		{
+			topic:              "topic1",
+			body:               `{"quarantineTopic": "projects/ARGO/topics/unknown"}`,
+			expectedStatusCode: 404,
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "Quarantine topic doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			msg: "Modify the quarantine topic with a topic that doesn't exist",
+		},
+		{
+			topic:              "unknown",
+			body:               `{"quarantineTopic": "projects/ARGO/topics/topic2"}`,
+			expectedStatusCode: 404,
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "Topic doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			msg: "Modify the quarantine topic of a topic that doesn't exist",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	mgr := oldPush.Manager{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+
+	for _, t := range testData {
+		brk := brokers.MockBroker{}
+		url := fmt.Sprintf("http://localhost:8080/v1/projects/ARGO/topics/%v:modifyQuarantineTopic", t.topic)
+		req, err := http.NewRequest("POST", url, strings.NewReader(t.body))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router := mux.NewRouter().StrictSlash(true)
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/projects/{project}/topics/{topic}:modifyQuarantineTopic", WrapMockAuthConfig(TopicModQuarantineTopic, cfgKafka, &brk, str, &mgr, nil))
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+		if t.expectedStatusCode == 200 {
+			tp, _, _, _ := str.QueryTopics(suite.ctx, "argo_uuid", "", "topic1", "", 1)
+			suite.Equal(t.expectedQuarantineTopic, tp[0].QuarantineTopic, t.msg)
+		}
+	}
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicModCompression() {
+
+	type td struct {
+		topic               string
+		body                string
+		expectedStatusCode  int
+		expectedResponse    string
+		expectedCompression string
+		msg                 string
+	}
+
+	testData := []td{
+		{
+			topic:               "topic1",
+			body:                `{"compression": "zstd"}`,
+			expectedStatusCode:  200,
+			expectedResponse:    "",
+			expectedCompression: "zstd",
+			msg:                 "Modify the compression of a topic",
+		},
+		{
+			topic:               "topic1",
+			body:                `{"compression": ""}`,
+			expectedStatusCode:  200,
+			expectedResponse:    "",
+			expectedCompression: "",
+			msg:                 "Disable the compression of a topic",
+		},
+		{
+			topic:              "topic1",
+			body:               `{"compression": "lz4"}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "compression should be either gzip, zstd or empty",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Modify the compression of a topic with an unsupported algorithm",
+		},
+		{
+			topic:              "unknown",
+			body:               `{"compression": "gzip"}`,
+			expectedStatusCode: 404,
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "Topic doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			msg: "Modify the compression of a topic that doesn't exist",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	mgr := oldPush.Manager{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+
+	for _, t := range testData {
+		brk := brokers.MockBroker{}
+		url := fmt.Sprintf("http://localhost:8080/v1/projects/ARGO/topics/%v:modifyCompression", t.topic)
+		req, err := http.NewRequest("POST", url, strings.NewReader(t.body))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router := mux.NewRouter().StrictSlash(true)
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/projects/{project}/topics/{topic}:modifyCompression", WrapMockAuthConfig(TopicModCompression, cfgKafka, &brk, str, &mgr, nil))
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+		if t.expectedStatusCode == 200 {
+			tp, _, _, _ := str.QueryTopics(suite.ctx, "argo_uuid", "", "topic1", "", 1)
+			suite.Equal(t.expectedCompression, tp[0].Compression, t.msg)
+		}
+	}
+}
+
+func (suite *TopicsHandlersTestSuite) TestPublishCompressed() {
+
+	data := base64.StdEncoding.EncodeToString([]byte(strings.Repeat(`{"status":"CRITICAL"}`, 20)))
+	postJSON := fmt.Sprintf(`{"messages": [{"attributes": {"foo": "bar"}, "data": "%s"}]}`, data)
+
+	url := "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish"
+	req, err := http.NewRequest("POST", url, strings.NewReader(postJSON))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.ModTopicCompression(suite.ctx, "argo_uuid", "topic1", "gzip")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	// the broker holds the compressed payload
+	stored, _ := messages.LoadMsgJSON([]byte(brk.MsgList[0]))
+	suite.Equal("gzip", stored.Compression)
+	suite.NotEqual(data, stored.Data)
+
+	// the stored payload restores to the published one
+	restored := stored
+	suite.Nil(restored.Decompress())
+	suite.Equal(data, restored.Data)
+
+	// both the logical and the stored size of the payload are counted
+	tp, _, _, _ := str.QueryTopics(suite.ctx, "argo_uuid", "", "topic1", "", 1)
+	suite.Equal(int64(len(data)), tp[0].TotalBytes)
+	suite.Equal(stored.Size(), tp[0].StoredBytes)
+	suite.True(tp[0].StoredBytes < tp[0].TotalBytes)
+}
+
+func (suite *TopicsHandlersTestSuite) TestPublishDelayed() {
+
+	type td struct {
+		postJSON           string
+		expectedStatusCode int
+		expectedResponse   string
+		msg                string
+	}
+
+	testData := []td{
+		{
+			postJSON: `{
+  "messages": [
+    {
+      "data": "YmFzZTY0ZW5jb2RlZA==",
+      "delaySeconds": 60
+    }
+  ]
+}`,
+			expectedStatusCode: 200,
+			expectedResponse: `{
+   "messageIds": [
+      "1"
+   ]
+}`,
+			msg: "Publish a message with a relative delay",
+		},
+		{
+			postJSON: `{
+  "messages": [
+    {
+      "data": "YmFzZTY0ZW5jb2RlZA==",
+      "deliverAfter": "2100-01-01T10:00:00+02:00"
+    }
+  ]
+}`,
+			expectedStatusCode: 200,
+			expectedResponse: `{
+   "messageIds": [
+      "1"
+   ]
+}`,
+			msg: "Publish a message with an absolute delivery time",
+		},
+		{
+			postJSON: `{
+  "messages": [
+    {
+      "data": "YmFzZTY0ZW5jb2RlZA==",
+      "deliverAfter": "tomorrow"
+    }
+  ]
+}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "deliverAfter should be a valid RFC3339 timestamp",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Publish a message with an invalid delivery time",
+		},
+		{
+			postJSON: `{
+  "messages": [
+    {
+      "data": "YmFzZTY0ZW5jb2RlZA==",
+      "delaySeconds": -5
+    }
+  ]
+}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "delaySeconds should be a non negative number",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Publish a message with a negative delay",
+		},
+		{
+			postJSON: `{
+  "messages": [
+    {
+      "data": "YmFzZTY0ZW5jb2RlZA==",
+      "delaySeconds": 5,
+      "deliverAfter": "2100-01-01T10:00:00Z"
+    }
+  ]
+}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "deliverAfter and delaySeconds cannot be used together",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Publish a message with both delivery options",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	mgr := oldPush.Manager{}
+
+	for _, t := range testData {
+		brk := brokers.MockBroker{}
+		brk.Initialize([]string{"localhost"})
+		str := stores.NewMockStore("whatever", "argo_mgs")
+		url := "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish"
+		req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(t.postJSON)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router := mux.NewRouter().StrictSlash(true)
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+
+		// the delivery time is stored along with the message as an absolute UTC timestamp
+		if t.expectedStatusCode == 200 {
+			msg, _ := messages.LoadMsgJSON([]byte(brk.MsgList[0]))
+			suite.Equal(int64(0), msg.DelaySeconds, t.msg)
+			suite.False(msg.IsDue(time.Now().UTC()), t.msg)
+			suite.True(strings.HasSuffix(msg.DeliverAfter, "Z"), t.msg)
+		}
+	}
+}
+
+func (suite *TopicsHandlersTestSuite) TestPublishError() {
+
+	postJSON := `{
+  "messages": [
+    {
+      "attributes": [
+        {
+          "key": "foo",
+          "valu2RlZA=="
+    },
+    {
+      "attributes": [
+        {
+          "key": "foo2",
+          "value": "bar2"
+        }
+      ],
+      "data": "YmFzZTY0ZW5jb2RlZA=="
+    }
+  ]
+}`
+	url := "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expJSON := `{
+   "error": {
+      "code": 400,
+      "message": "Invalid Message Arguments",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(expJSON, w.Body.String())
+
+}
+
+func (suite *TopicsHandlersTestSuite) TestPublishNoTopic() {
+
+	postJSON := `{
+  "messages": [
+		{
+			"attributes": [
+				{
+					"key": "foo",
+					"value": "bar"
+				}
+			],
+			"data": "YmFzZTY0ZW5jb2RlZA=="
+		},
+    {
+      "attributes": [
+        {
+          "key": "foo2",
+          "value": "bar2"
+        }
+      ],
+      "data": "YmFzZTY0ZW5jb2RlZA=="
+    }
+  ]
+}`
+	url := "http://localhost:8080/v1/projects/ARGO/topics/FOO:publish"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expJSON := `{
+   "error": {
+      "code": 404,
       "message": "Topic doesn't exist",
       "status": "NOT_FOUND"
    }
@@ -1127,3 +2305,82 @@ func TestTopicsHandlersTestSuite(t *testing.T) {
 	log.SetOutput(ioutil.Discard)
 	suite.Run(t, new(TopicsHandlersTestSuite))
 }
+
+// BenchmarkTopicPublishWithSchema measures publishing to topics with a schema, with and without the schema cache
+func BenchmarkTopicPublishWithSchema(b *testing.B) {
+
+	logrus.SetOutput(ioutil.Discard)
+
+	cfgStr := `{
+	"bind_ip":"",
+	"port":8080,
+	"zookeeper_hosts":["localhost"],
+	"kafka_znode":"",
+	"store_host":"localhost",
+	"store_db":"argo_msg",
+	"per_resource_auth":"true",
+	"push_enabled": "true",
+	"push_worker_token": "push_token"
+	}`
+
+	type bd struct {
+		name     string
+		topic    string
+		postBody string
+	}
+
+	benchData := []bd{
+		{
+			name:     "json",
+			topic:    "topic2",
+			postBody: `{"messages":[{"data":"eyJuYW1lIjoibmFtZS0xIiwgImVtYWlsIjogInRlc3RAZXhhbXBsZS5jb20ifQ=="}]}`,
+		},
+		{
+			name:     "avro",
+			topic:    "topic3",
+			postBody: `{"messages":[{"data":"DGFnZWxvc8T8Cg=="}]}`,
+		},
+	}
+
+	for _, t := range benchData {
+		for _, cached := range []bool{false, true} {
+
+			mode := "uncached"
+			if cached {
+				mode = "cached"
+			}
+
+			b.Run(fmt.Sprintf("%s/%s", t.name, mode), func(b *testing.B) {
+
+				if cached {
+					schemas.EnableCache()
+					defer schemas.DisableCache()
+				}
+
+				cfgKafka := config.NewAPICfg()
+				cfgKafka.LoadStrJSON(cfgStr)
+				brk := brokers.MockBroker{}
+				str := stores.NewMockStore("whatever", "argo_mgs")
+				// allow the mock user to publish to every topic
+				str.TopicsACL[t.topic] = stores.QAcl{ACL: []string{"uuid1"}}
+				router := mux.NewRouter().StrictSlash(true)
+				mgr := oldPush.Manager{}
+				router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+
+				url := fmt.Sprintf("http://localhost:8080/v1/projects/ARGO/topics/%s:publish", t.topic)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					w := httptest.NewRecorder()
+					req, _ := http.NewRequest("POST", url, strings.NewReader(t.postBody))
+					router.ServeHTTP(w, req)
+					if w.Code != http.StatusOK {
+						b.Fatalf("unexpected status %d, %s", w.Code, w.Body.String())
+					}
+				}
+			})
+		}
+	}
+}
@@ -118,6 +118,169 @@ func RefreshToken(w http.ResponseWriter, r *http.Request) {
 	respondOK(w, output)
 }
 
+// UserModDN (POST) sets the subject of the client certificate that authenticates the user
+func UserModDN(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlUser := urlVars["user"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	postBody, err := auth.GetUserDNFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("DN")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Get Result Object
+	userUUID := auth.GetUUIDByName(rCTX, urlUser, refStr)
+	if userUUID == "" {
+		err := APIErrorNotFound("User")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	res, err := auth.UpdateUserDN(rCTX, userUUID, postBody.DN, postBody.IssuerDN, refStr)
+
+	if err != nil {
+		if err.Error() == "invalid dn" {
+			err := APIErrorInvalidData("Invalid DN")
+			respondErr(rCTX, w, err)
+			return
+		}
+		if err.Error() == "invalid issuer dn" {
+			err := APIErrorInvalidData("Invalid issuer DN")
+			respondErr(rCTX, w, err)
+			return
+		}
+		if err.Error() == "empty issuer dn" {
+			err := APIErrorInvalidData("The issuer DN of the certificate is required")
+			respondErr(rCTX, w, err)
+			return
+		}
+		if err.Error() == "exists" {
+			err := APIErrorGenericConflict("DN is already assigned to another user")
+			respondErr(rCTX, w, err)
+			return
+		}
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("User")
+			respondErr(rCTX, w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// UserModOIDCSubject (POST) sets the subject claim of the OIDC tokens that authenticate the user
+func UserModOIDCSubject(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlUser := urlVars["user"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	postBody, err := auth.GetUserOIDCSubjectFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("OIDC subject")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Get Result Object
+	userUUID := auth.GetUUIDByName(rCTX, urlUser, refStr)
+	if userUUID == "" {
+		err := APIErrorNotFound("User")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	res, err := auth.UpdateUserOIDCSubject(rCTX, userUUID, postBody.OIDCSubject, refStr)
+
+	if err != nil {
+		if err.Error() == "exists" {
+			err := APIErrorGenericConflict("OIDC subject is already assigned to another user")
+			respondErr(rCTX, w, err)
+			return
+		}
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("User")
+			respondErr(rCTX, w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
 // UserUpdate (PUT) updates the user information
 func UserUpdate(w http.ResponseWriter, r *http.Request) {
 	traceId := gorillaContext.Get(r, "trace_id").(string)
@@ -556,3 +719,238 @@ func UserDelete(w http.ResponseWriter, r *http.Request) {
 	// Write empty response if anything ok
 	respondOK(w, output)
 }
+
+// UserListTokens (GET) lists the api keys of a user, without their secrets
+func UserListTokens(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlUser := urlVars["user"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	userUUID := auth.GetUUIDByName(rCTX, urlUser, refStr)
+	if userUUID == "" {
+		err := APIErrorNotFound("User")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	res, err := auth.FindAPIKeys(rCTX, userUUID, refStr)
+	if err != nil {
+		err := APIErrQueryDatastore()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// UserCreateToken (POST) creates a new named api key for a user, the response is the only time that its secret is shown
+func UserCreateToken(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlUser := urlVars["user"]
+	urlToken := urlVars["token"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Read POST JSON body, an empty one creates an unrestricted key
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	postBody := auth.APIKey{}
+	if len(body) > 0 {
+		postBody, err = auth.GetAPIKeyFromJSON(body)
+		if err != nil {
+			err := APIErrorInvalidArgument("Token")
+			respondErr(rCTX, w, err)
+			return
+		}
+	}
+
+	userUUID := auth.GetUUIDByName(rCTX, urlUser, refStr)
+	if userUUID == "" {
+		err := APIErrorNotFound("User")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	created := time.Now().UTC()
+	res, err := auth.CreateAPIKey(rCTX, userUUID, urlToken, postBody.Project, postBody.Roles, postBody.ExpiresOn, created, refStr)
+
+	if err != nil {
+		if err.Error() == "exists" {
+			err := APIErrorConflict("Token")
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("User")
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		if strings.HasPrefix(err.Error(), "invalid") {
+			err := APIErrorInvalidData(err.Error())
+			respondErr(rCTX, w, err)
+			return
+		}
+
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// UserDeleteToken (DELETE) revokes an api key of a user
+func UserDeleteToken(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlUser := urlVars["user"]
+	urlToken := urlVars["token"]
+
+	userUUID := auth.GetUUIDByName(rCTX, urlUser, refStr)
+	if userUUID == "" {
+		err := APIErrorNotFound("User")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	err := auth.RevokeAPIKey(rCTX, userUUID, urlToken, refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Token")
+			respondErr(rCTX, w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write empty response if anything ok
+	respondOK(w, output)
+}
+
+// UserPermissions (GET) lists the routes that a user is authorized for, optionally within a project
+func UserPermissions(w http.ResponseWriter, r *http.Request) {
+	traceId := gorillaContext.Get(r, "trace_id").(string)
+	rCTX := context.WithValue(context.Background(), "trace_id", traceId)
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlUser := urlVars["user"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refRoutes := gorillaContext.Get(r, "routes").([]string)
+
+	userUUID := auth.GetUUIDByName(rCTX, urlUser, refStr)
+	if userUUID == "" {
+		err := APIErrorNotFound("User")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	project := r.URL.Query().Get("project")
+	projectUUID := ""
+	if project != "" {
+		projectUUID = projects.GetUUIDByName(rCTX, project, refStr)
+		if projectUUID == "" {
+			err := APIErrorNotFound("ProjectUUID")
+			respondErr(rCTX, w, err)
+			return
+		}
+	}
+
+	res, err := auth.FindPermissions(rCTX, userUUID, project, projectUUID, refRoutes, refStr)
+	if err != nil {
+		err := APIErrorNotFound("User")
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(rCTX, w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
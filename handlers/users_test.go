@@ -69,7 +69,6 @@ func (suite *UsersHandlersTestSuite) TestUserProfileUrlKey() {
    "last_name": "LastA",
    "organization": "OrgA",
    "description": "DescA",
-   "token": "S3CR3T1",
    "email": "foo-email",
    "service_roles": [],
    "created_on": "2009-11-10T23:00:00Z",
@@ -124,7 +123,6 @@ func (suite *UsersHandlersTestSuite) TestUserProfileHeaderKey() {
    "last_name": "LastA",
    "organization": "OrgA",
    "description": "DescA",
-   "token": "S3CR3T1",
    "email": "foo-email",
    "service_roles": [],
    "created_on": "2009-11-10T23:00:00Z",
@@ -383,6 +381,255 @@ func (suite *UsersHandlersTestSuite) TestRefreshToken() {
 	suite.NotEqual("S3CR3T", userOut.Token)
 }
 
+func (suite *UsersHandlersTestSuite) TestUserModDN() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/users/{user}:modifyDN", WrapMockAuthConfig(UserModDN, cfgKafka, &brk, str, &mgr, nil))
+
+	// the openssl form gets stored in the canonical RFC 4514 form, along with the issuer
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/users/UserA:modifyDN", bytes.NewBuffer([]byte(`{"dn": "/C=GR/O=ARGO/CN=UserA", "issuer_dn": "/C=GR/O=ARGO/CN=ARGO CA"}`)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	userOut, _ := auth.GetUserFromJSON([]byte(w.Body.String()))
+	suite.Equal("CN=UserA,O=ARGO,C=GR", userOut.DN)
+	suite.Equal("CN=ARGO CA,O=ARGO,C=GR", userOut.IssuerDN)
+	suite.Equal("CN=UserA,O=ARGO,C=GR", str.UserList[1].DN)
+	suite.Equal("CN=ARGO CA,O=ARGO,C=GR", str.UserList[1].IssuerDN)
+
+	// the dn already belongs to UserA
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/users/UserB:modifyDN", bytes.NewBuffer([]byte(`{"dn": "CN=UserA,O=ARGO,C=GR", "issuer_dn": "CN=ARGO CA,O=ARGO,C=GR"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(409, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 409,
+      "message": "DN is already assigned to another user",
+      "status": "CONFLICT"
+   }
+}`, w.Body.String())
+
+	// the issuer is required along with the dn
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/users/UserB:modifyDN", bytes.NewBuffer([]byte(`{"dn": "CN=UserB,O=ARGO,C=GR"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 400,
+      "message": "The issuer DN of the certificate is required",
+      "status": "INVALID_ARGUMENT"
+   }
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/users/UserB:modifyDN", bytes.NewBuffer([]byte(`{"dn": "UserB", "issuer_dn": "CN=ARGO CA,O=ARGO,C=GR"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 400,
+      "message": "Invalid DN",
+      "status": "INVALID_ARGUMENT"
+   }
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/users/unknown:modifyDN", bytes.NewBuffer([]byte(`{"dn": "CN=unknown", "issuer_dn": "CN=ARGO CA"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/users/UserA:modifyDN", bytes.NewBuffer([]byte(`{"dn": 1}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+}
+
+func (suite *UsersHandlersTestSuite) TestUserModOIDCSubject() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/users/{user}:modifyOIDCSubject", WrapMockAuthConfig(UserModOIDCSubject, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/users/UserA:modifyOIDCSubject", bytes.NewBuffer([]byte(`{"oidc_subject": "aai-subject-1"}`)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	userOut, _ := auth.GetUserFromJSON([]byte(w.Body.String()))
+	suite.Equal("aai-subject-1", userOut.OIDCSubject)
+	suite.Equal("aai-subject-1", str.UserList[1].OIDCSubject)
+
+	// the subject already belongs to UserA
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/users/UserB:modifyOIDCSubject", bytes.NewBuffer([]byte(`{"oidc_subject": "aai-subject-1"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(409, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 409,
+      "message": "OIDC subject is already assigned to another user",
+      "status": "CONFLICT"
+   }
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/users/unknown:modifyOIDCSubject", bytes.NewBuffer([]byte(`{"oidc_subject": "aai-subject-2"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/users/UserA:modifyOIDCSubject", bytes.NewBuffer([]byte(`{"oidc_subject": 1}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+}
+
+func (suite *UsersHandlersTestSuite) TestUserTokens() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/users/{user}/tokens", WrapMockAuthConfig(UserListTokens, cfgKafka, &brk, str, &mgr, nil)).Methods("GET")
+	router.HandleFunc("/v1/users/{user}/tokens/{token}", WrapMockAuthConfig(UserCreateToken, cfgKafka, &brk, str, &mgr, nil)).Methods("POST")
+	router.HandleFunc("/v1/users/{user}/tokens/{token}", WrapMockAuthConfig(UserDeleteToken, cfgKafka, &brk, str, &mgr, nil)).Methods("DELETE")
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/users/UserA/tokens/ci",
+		bytes.NewBuffer([]byte(`{"project": "ARGO", "roles": ["publisher"], "expires_on": "2100-01-01T00:00:00Z"}`)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	keyOut, _ := auth.GetAPIKeyFromJSON([]byte(w.Body.String()))
+	suite.Equal("ci", keyOut.Name)
+	suite.Equal("ARGO", keyOut.Project)
+	suite.Equal([]string{"publisher"}, keyOut.Roles)
+	suite.Equal("2100-01-01T00:00:00Z", keyOut.ExpiresOn)
+	suite.NotEqual("", keyOut.Token)
+
+	// an empty body creates a key with all the roles of the user
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/users/UserA/tokens/full", bytes.NewBuffer([]byte("")))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/users/UserA/tokens/ci", bytes.NewBuffer([]byte("")))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(409, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 409,
+      "message": "Token already exists",
+      "status": "ALREADY_EXISTS"
+   }
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/users/UserA/tokens/other",
+		bytes.NewBuffer([]byte(`{"project": "ARGO", "roles": ["service_admin"]}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 400,
+      "message": "invalid role: service_admin",
+      "status": "INVALID_ARGUMENT"
+   }
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/users/unknown/tokens/ci", bytes.NewBuffer([]byte("")))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+
+	// the secrets are not listed
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/users/UserA/tokens", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.NotContains(w.Body.String(), keyOut.Token)
+	suite.Contains(w.Body.String(), `"name": "ci"`)
+	suite.Contains(w.Body.String(), `"name": "full"`)
+
+	req, _ = http.NewRequest("DELETE", "http://localhost:8080/v1/users/UserA/tokens/ci", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	req, _ = http.NewRequest("DELETE", "http://localhost:8080/v1/users/UserA/tokens/ci", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 404,
+      "message": "Token doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`, w.Body.String())
+}
+
+func (suite *UsersHandlersTestSuite) TestUserPermissions() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	routes := []string{"topics:list_all", "topics:publish", "ams:metrics"}
+	router.HandleFunc("/v1/users/{user}:permissions", WrapMockAuthConfig(WrapRoutes(UserPermissions, routes), cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/users/UserA:permissions?project=ARGO", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "user": "UserA",
+   "project": "ARGO",
+   "roles": [
+      "consumer",
+      "publisher"
+   ],
+   "routes": [
+      "topics:list_all",
+      "topics:publish"
+   ]
+}`, w.Body.String())
+
+	// without a project only the service roles count
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/users/UserA:permissions", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "user": "UserA",
+   "roles": [],
+   "routes": []
+}`, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/users/UserA:permissions?project=unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/users/unknown:permissions", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+}
+
 func (suite *UsersHandlersTestSuite) TestUserUpdate() {
 
 	postJSON := `{
@@ -579,7 +826,6 @@ func (suite *UsersHandlersTestSuite) TestUserListByToken() {
    "last_name": "LastA",
    "organization": "OrgA",
    "description": "DescA",
-   "token": "S3CR3T1",
    "email": "foo-email",
    "service_roles": [],
    "created_on": "2009-11-10T23:00:00Z",
@@ -627,7 +873,6 @@ func (suite *UsersHandlersTestSuite) TestUserListByUUID() {
       }
    ],
    "name": "UserZ",
-   "token": "S3CR3T4",
    "email": "foo-email",
    "service_roles": [],
    "created_on": "2009-11-10T23:00:00Z",
@@ -742,7 +987,6 @@ func (suite *UsersHandlersTestSuite) TestUserListOne() {
    "last_name": "LastA",
    "organization": "OrgA",
    "description": "DescA",
-   "token": "S3CR3T1",
    "email": "foo-email",
    "service_roles": [],
    "created_on": "2009-11-10T23:00:00Z",
@@ -787,7 +1031,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAll() {
             }
          ],
          "name": "UserZ",
-         "token": "S3CR3T1",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -796,7 +1039,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAll() {
       {
          "uuid": "uuid7",
          "name": "push_worker_0",
-         "token": "push_token",
          "email": "foo-email",
          "service_roles": [
             "push_worker"
@@ -818,7 +1060,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAll() {
             }
          ],
          "name": "UserSame2",
-         "token": "S3CR3T42",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -839,7 +1080,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAll() {
             }
          ],
          "name": "UserSame1",
-         "token": "S3CR3T41",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -865,7 +1105,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAll() {
             }
          ],
          "name": "UserZ",
-         "token": "S3CR3T4",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -890,7 +1129,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAll() {
             }
          ],
          "name": "UserX",
-         "token": "S3CR3T3",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -918,7 +1156,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAll() {
             }
          ],
          "name": "UserB",
-         "token": "S3CR3T2",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -950,7 +1187,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAll() {
          "last_name": "LastA",
          "organization": "OrgA",
          "description": "DescA",
-         "token": "S3CR3T1",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -970,7 +1206,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAll() {
             }
          ],
          "name": "Test",
-         "token": "S3CR3T",
          "email": "Test@test.com",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -1019,7 +1254,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllStartingPage() {
             }
          ],
          "name": "UserZ",
-         "token": "S3CR3T1",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -1028,7 +1262,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllStartingPage() {
       {
          "uuid": "uuid7",
          "name": "push_worker_0",
-         "token": "push_token",
          "email": "foo-email",
          "service_roles": [
             "push_worker"
@@ -1079,7 +1312,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllProjectARGO() {
             }
          ],
          "name": "UserSame2",
-         "token": "S3CR3T42",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -1100,7 +1332,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllProjectARGO() {
             }
          ],
          "name": "UserSame1",
-         "token": "S3CR3T41",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -1126,7 +1357,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllProjectARGO() {
             }
          ],
          "name": "UserZ",
-         "token": "S3CR3T4",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -1151,7 +1381,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllProjectARGO() {
             }
          ],
          "name": "UserX",
-         "token": "S3CR3T3",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -1179,7 +1408,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllProjectARGO() {
             }
          ],
          "name": "UserB",
-         "token": "S3CR3T2",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -1211,7 +1439,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllProjectARGO() {
          "last_name": "LastA",
          "organization": "OrgA",
          "description": "DescA",
-         "token": "S3CR3T1",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -1231,7 +1458,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllProjectARGO() {
             }
          ],
          "name": "Test",
-         "token": "S3CR3T",
          "email": "Test@test.com",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -1280,7 +1506,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllProjectARGO2() {
             }
          ],
          "name": "UserZ",
-         "token": "S3CR3T1",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -1348,7 +1573,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllStartingAtSecond() {
       {
          "uuid": "uuid7",
          "name": "push_worker_0",
-         "token": "push_token",
          "email": "foo-email",
          "service_roles": [
             "push_worker"
@@ -1370,7 +1594,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllStartingAtSecond() {
             }
          ],
          "name": "UserSame2",
-         "token": "S3CR3T42",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -1408,7 +1631,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllStartingAtSecondNoUserDetail
       {
          "uuid": "uuid7",
          "name": "push_worker_0",
-         "token": "push_token",
          "email": "foo-email",
          "service_roles": [
             "push_worker"
@@ -1419,7 +1641,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllStartingAtSecondNoUserDetail
       {
          "uuid": "same_uuid",
          "name": "UserSame2",
-         "token": "S3CR3T42",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -1503,7 +1724,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllIntermediatePage() {
             }
          ],
          "name": "UserZ",
-         "token": "S3CR3T4",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
@@ -1528,7 +1748,6 @@ func (suite *UsersHandlersTestSuite) TestUserListAllIntermediatePage() {
             }
          ],
          "name": "UserX",
-         "token": "S3CR3T3",
          "email": "foo-email",
          "service_roles": [],
          "created_on": "2009-11-10T23:00:00Z",
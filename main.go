@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
 	oldPush "github.com/ARGOeu/argo-messaging/push"
 	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
+	"github.com/ARGOeu/argo-messaging/ratelimit"
+	"github.com/ARGOeu/argo-messaging/schemas"
 	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/topics"
 	"github.com/ARGOeu/argo-messaging/version"
 	"github.com/gorilla/handlers"
 	log "github.com/sirupsen/logrus"
@@ -37,6 +43,48 @@ func main() {
 	store := stores.NewMongoStoreWithOfficialDriver(cfg.StoreHost, cfg.StoreDB)
 	store.Initialize()
 
+	// hashing the tokens with an empty pepper would irreversibly store them under a key that everyone knows
+	if cfg.TokenPepper == "" {
+		log.WithFields(
+			log.Fields{
+				"type": "service_log",
+			},
+		).Fatal("token_pepper is required, generate one with: openssl rand -hex 32")
+	}
+
+	// hash the user tokens with the configured pepper and migrate the ones that are still stored in plaintext
+	auth.SetTokenPepper(cfg.TokenPepper)
+	if migrated, err := auth.MigrateTokens(context.Background(), store); err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "service_log",
+				"error": err.Error(),
+			},
+		).Error("Could not migrate the user tokens")
+	} else if migrated > 0 {
+		log.WithFields(
+			log.Fields{
+				"type": "service_log",
+			},
+		).Infof("Hashed the plaintext tokens of %v users", migrated)
+	}
+
+	// store the first revision of the schemas that were created before revisions existed
+	if migrated, err := schemas.MigrateRevisions(context.Background(), store); err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":  "service_log",
+				"error": err.Error(),
+			},
+		).Error("Could not migrate the schema revisions")
+	} else if migrated > 0 {
+		log.WithFields(
+			log.Fields{
+				"type": "service_log",
+			},
+		).Infof("Stored the first revision of %v schemas", migrated)
+	}
+
 	// create and initialize broker based on configuration
 	broker := brokers.NewKafkaBroker(cfg.GetBrokerInfo())
 
@@ -61,6 +109,24 @@ func main() {
 		pushClient.Close()
 	}()
 
+	// periodically reconcile the topics of the store with the topics of the broker
+	if cfg.TopicReconciliationInterval > 0 {
+		go topics.ScheduleReconciliation(context.Background(),
+			time.Duration(cfg.TopicReconciliationInterval)*time.Second, cfg.TopicReconciliationDryRun, store, broker)
+	}
+
+	// cache the schemas that published messages get validated against and keep up with the changes of other instances
+	if cfg.SchemaCacheSyncInterval > 0 {
+		schemas.EnableCache()
+		go schemas.ScheduleCacheSync(context.Background(),
+			time.Duration(cfg.SchemaCacheSyncInterval)*time.Second, store)
+	}
+
+	// cache the rate limits of projects and users instead of loading them on every request
+	if cfg.RateLimiting {
+		ratelimit.EnableCache()
+	}
+
 	// create and initialize API routing object
 	API := NewRouting(cfg, broker, store, mgr, pushClient, defaultRoutes)
 
@@ -70,6 +136,13 @@ func main() {
 		PreferServerCipherSuites: true,
 	}
 
+	// verify the client certificates that are presented against the loaded CAs,
+	// clients without one can still authenticate with a token
+	if cfg.ClientCertAuth {
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		config.ClientCAs = cfg.LoadCAs()
+	}
+
 	// Initialize CORS specifics
 	xReqWithConType := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "x-api-key"})
 	allowVerbs := handlers.AllowedMethods([]string{"OPTIONS", "POST", "GET", "PUT", "DELETE", "HEAD"})
@@ -0,0 +1,571 @@
+package messages
+
+import (
+	"bytes"
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// CloudEventsSpecVersion is the version of the CloudEvents specification that is supported
+	CloudEventsSpecVersion = "1.0"
+	// CloudEventsContentType is the media type of a single event in structured mode
+	CloudEventsContentType = "application/cloudevents+json"
+	// CloudEventsBatchContentType is the media type of a batch of events in structured mode
+	CloudEventsBatchContentType = "application/cloudevents-batch+json"
+	// CloudEventAttrPrefix prefixes the message attributes that hold the attributes of an event
+	CloudEventAttrPrefix = "ce-"
+	// CloudEventDefaultType is the type of the events produced from messages that weren't published as events
+	CloudEventDefaultType = "argo.messaging.message"
+)
+
+// cloudEventNames holds the names of the attributes that the specification defines, along with data
+var cloudEventNames = map[string]bool{
+	"specversion":     true,
+	"id":              true,
+	"source":          true,
+	"type":            true,
+	"datacontenttype": true,
+	"dataschema":      true,
+	"subject":         true,
+	"time":            true,
+	"data":            true,
+	"data_base64":     true,
+}
+
+// extensionNameRegex matches the names that the specification allows for extension attributes
+var extensionNameRegex = regexp.MustCompile(`^[a-z0-9]{1,20}$`)
+
+// CloudEvent holds an event in the JSON format of the CloudEvents specification
+type CloudEvent struct {
+	SpecVersion     string
+	ID              string
+	Source          string
+	Type            string
+	DataContentType string
+	DataSchema      string
+	Subject         string
+	Time            string
+	// Extensions holds the extension attributes of the event
+	Extensions map[string]string
+	// Data holds the payload as a JSON value
+	Data json.RawMessage
+	// DataBase64 holds the payload as base64 encoded binary data
+	DataBase64 string
+}
+
+// RecCloudEvent holds a received message in the form of an event
+type RecCloudEvent struct {
+	AckID string     `json:"ackId,omitempty"`
+	Event CloudEvent `json:"message"`
+}
+
+// RecCloudEventList holds the received messages of a subscription in the form of events
+type RecCloudEventList struct {
+	RecEvents []RecCloudEvent `json:"receivedMessages"`
+}
+
+// MarshalJSON generates the JSON format of the event, with the extensions next to the rest of the attributes
+func (e CloudEvent) MarshalJSON() ([]byte, error) {
+
+	fields := map[string]interface{}{}
+	for name, value := range e.Extensions {
+		fields[name] = value
+	}
+
+	optional := map[string]string{
+		"specversion":     e.SpecVersion,
+		"id":              e.ID,
+		"source":          e.Source,
+		"type":            e.Type,
+		"datacontenttype": e.DataContentType,
+		"dataschema":      e.DataSchema,
+		"subject":         e.Subject,
+		"time":            e.Time,
+		"data_base64":     e.DataBase64,
+	}
+
+	for name, value := range optional {
+		if value != "" {
+			fields[name] = value
+		}
+	}
+
+	if len(e.Data) > 0 {
+		fields["data"] = e.Data
+	}
+
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON loads an event from its JSON format, the unknown attributes are treated as extensions
+func (e *CloudEvent) UnmarshalJSON(input []byte) error {
+
+	fields := map[string]json.RawMessage{}
+	err := json.Unmarshal(input, &fields)
+	if err != nil {
+		return err
+	}
+
+	*e = CloudEvent{}
+
+	for name, raw := range fields {
+
+		if name == "data" {
+			if string(raw) != "null" {
+				e.Data = raw
+			}
+			continue
+		}
+
+		var value string
+
+		// extensions might hold numbers or booleans, which are kept in their canonical string form
+		if err := json.Unmarshal(raw, &value); err != nil {
+			if cloudEventNames[name] {
+				return fmt.Errorf("Cloud event attribute %s should be a string", name)
+			}
+			var v interface{}
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return err
+			}
+			switch v.(type) {
+			case float64, bool:
+				value = string(raw)
+			default:
+				return fmt.Errorf("Cloud event attribute %s should be a string, a number or a boolean", name)
+			}
+		}
+
+		switch name {
+		case "specversion":
+			e.SpecVersion = value
+		case "id":
+			e.ID = value
+		case "source":
+			e.Source = value
+		case "type":
+			e.Type = value
+		case "datacontenttype":
+			e.DataContentType = value
+		case "dataschema":
+			e.DataSchema = value
+		case "subject":
+			e.Subject = value
+		case "time":
+			e.Time = value
+		case "data_base64":
+			e.DataBase64 = value
+		default:
+			if e.Extensions == nil {
+				e.Extensions = map[string]string{}
+			}
+			e.Extensions[name] = value
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the event carries the required attributes and that they are well formed
+func (e CloudEvent) Validate() error {
+
+	if e.SpecVersion != CloudEventsSpecVersion {
+		return fmt.Errorf("Cloud event specversion should be %s", CloudEventsSpecVersion)
+	}
+
+	if e.ID == "" || e.Source == "" || e.Type == "" {
+		return errors.New("Cloud event should declare an id, a source and a type")
+	}
+
+	if e.Time != "" {
+		if _, err := time.Parse(time.RFC3339Nano, e.Time); err != nil {
+			return errors.New("Cloud event time should be a valid RFC3339 timestamp")
+		}
+	}
+
+	for name := range e.Extensions {
+		if cloudEventNames[name] || !extensionNameRegex.MatchString(name) {
+			return fmt.Errorf("Cloud event extension %s should consist of up to 20 lowercase letters or digits", name)
+		}
+	}
+
+	if len(e.Data) > 0 && e.DataBase64 != "" {
+		return errors.New("Cloud event data and data_base64 cannot be used together")
+	}
+
+	if e.DataBase64 != "" {
+		if _, err := b64.StdEncoding.DecodeString(e.DataBase64); err != nil {
+			return errors.New("Cloud event data_base64 should be valid base64 encoded data")
+		}
+	}
+
+	return nil
+}
+
+// payload returns the bytes of the event's data
+func (e CloudEvent) payload() ([]byte, error) {
+
+	if e.DataBase64 != "" {
+		return b64.StdEncoding.DecodeString(e.DataBase64)
+	}
+
+	if len(e.Data) == 0 {
+		return []byte{}, nil
+	}
+
+	// string values of non json content types hold the data as is
+	if !isJSONContentType(e.DataContentType) {
+		var value string
+		if json.Unmarshal(e.Data, &value) == nil {
+			return []byte(value), nil
+		}
+	}
+
+	compacted := bytes.Buffer{}
+	err := json.Compact(&compacted, e.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return compacted.Bytes(), nil
+}
+
+// ToMessage maps the event to a message. The event's attributes are kept as message attributes
+// prefixed with ce-, while its data becomes the payload of the message.
+func (e CloudEvent) ToMessage() (Message, error) {
+
+	err := e.Validate()
+	if err != nil {
+		return Message{}, err
+	}
+
+	data, err := e.payload()
+	if err != nil {
+		return Message{}, err
+	}
+
+	msg := Message{Attr: Attributes{}, Data: b64.StdEncoding.EncodeToString(data)}
+
+	attributes := map[string]string{
+		"specversion":     e.SpecVersion,
+		"id":              e.ID,
+		"source":          e.Source,
+		"type":            e.Type,
+		"datacontenttype": e.DataContentType,
+		"dataschema":      e.DataSchema,
+		"subject":         e.Subject,
+		"time":            e.Time,
+	}
+
+	for name, value := range e.Extensions {
+		attributes[name] = value
+	}
+
+	for name, value := range attributes {
+		if value != "" {
+			msg.Attr[CloudEventAttrPrefix+name] = value
+		}
+	}
+
+	return msg, nil
+}
+
+// CloudEvent maps the message to an event. Messages that were published as events get back their original form.
+// The rest get their id and publish time from the message, the given source and a default type,
+// while the attributes that qualify as extension names become extensions.
+func (msg Message) CloudEvent(source string) CloudEvent {
+
+	e := CloudEvent{Extensions: map[string]string{}}
+
+	_, published := msg.Attr[CloudEventAttrPrefix+"specversion"]
+
+	for key, value := range msg.Attr {
+
+		if !published {
+			if !cloudEventNames[key] && extensionNameRegex.MatchString(key) {
+				e.Extensions[key] = value
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(key, CloudEventAttrPrefix) {
+			continue
+		}
+
+		switch name := strings.TrimPrefix(key, CloudEventAttrPrefix); name {
+		case "specversion":
+			e.SpecVersion = value
+		case "id":
+			e.ID = value
+		case "source":
+			e.Source = value
+		case "type":
+			e.Type = value
+		case "datacontenttype":
+			e.DataContentType = value
+		case "dataschema":
+			e.DataSchema = value
+		case "subject":
+			e.Subject = value
+		case "time":
+			e.Time = value
+		default:
+			e.Extensions[name] = value
+		}
+	}
+
+	if !published {
+		e.SpecVersion = CloudEventsSpecVersion
+		e.ID = msg.ID
+		e.Source = source
+		e.Type = CloudEventDefaultType
+		if pubTime, err := time.Parse(time.RFC3339Nano, msg.PubTime); err == nil {
+			e.Time = pubTime.UTC().Format(time.RFC3339Nano)
+		}
+	}
+
+	if len(e.Extensions) == 0 {
+		e.Extensions = nil
+	}
+
+	data, err := b64.StdEncoding.DecodeString(msg.Data)
+	if err != nil || len(data) == 0 {
+		return e
+	}
+
+	switch {
+	case published && isJSONContentType(e.DataContentType) && json.Valid(data):
+		e.Data = json.RawMessage(data)
+	case published && isTextContentType(e.DataContentType) && utf8.Valid(data):
+		e.Data, _ = json.Marshal(string(data))
+	default:
+		e.DataBase64 = b64.StdEncoding.EncodeToString(data)
+	}
+
+	return e
+}
+
+// isJSONContentType checks whether the data of an event with the given content type is a JSON value.
+// Events without a content type hold JSON data in the JSON format
+func isJSONContentType(contentType string) bool {
+
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// isTextContentType checks whether the data of an event with the given content type is text
+func isTextContentType(contentType string) bool {
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(mediaType, "text/")
+}
+
+// IsCloudEventsRequest checks whether a publish request carries events, either in structured or in binary mode
+func IsCloudEventsRequest(header http.Header) bool {
+
+	if header.Get(CloudEventAttrPrefix+"specversion") != "" {
+		return true
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+
+	return mediaType == CloudEventsContentType || mediaType == CloudEventsBatchContentType
+}
+
+// LoadCloudEventsHTTP creates a MsgList from the events of a publish request, following the HTTP binding
+// of the specification. Structured mode requests carry one event or a batch of events in their body,
+// while binary mode requests carry the attributes of a single event in ce- headers and its data in their body.
+func LoadCloudEventsHTTP(header http.Header, body []byte) (MsgList, error) {
+
+	events := []CloudEvent{}
+
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+
+	switch mediaType {
+	case CloudEventsContentType:
+		e := CloudEvent{}
+		if err := json.Unmarshal(body, &e); err != nil {
+			return MsgList{}, errors.New("Cloud event should be a valid JSON object")
+		}
+		events = append(events, e)
+	case CloudEventsBatchContentType:
+		if err := json.Unmarshal(body, &events); err != nil {
+			return MsgList{}, errors.New("Cloud events batch should be a valid JSON array of events")
+		}
+	default:
+		e, err := cloudEventFromHeaders(header, body)
+		if err != nil {
+			return MsgList{}, err
+		}
+		events = append(events, e)
+	}
+
+	msgList := MsgList{Msgs: []Message{}}
+
+	for _, e := range events {
+		msg, err := e.ToMessage()
+		if err != nil {
+			return MsgList{}, err
+		}
+		msgList.Msgs = append(msgList.Msgs, msg)
+	}
+
+	return msgList, nil
+}
+
+// cloudEventFromHeaders loads an event in binary mode
+func cloudEventFromHeaders(header http.Header, body []byte) (CloudEvent, error) {
+
+	e := CloudEvent{
+		DataContentType: header.Get("Content-Type"),
+		Extensions:      map[string]string{},
+	}
+
+	for key, values := range header {
+
+		name := strings.ToLower(key)
+		if !strings.HasPrefix(name, CloudEventAttrPrefix) || len(values) == 0 {
+			continue
+		}
+		name = strings.TrimPrefix(name, CloudEventAttrPrefix)
+
+		value, err := url.PathUnescape(values[0])
+		if err != nil {
+			return CloudEvent{}, fmt.Errorf("Cloud event header %s should be percent encoded", key)
+		}
+
+		switch name {
+		case "specversion":
+			e.SpecVersion = value
+		case "id":
+			e.ID = value
+		case "source":
+			e.Source = value
+		case "type":
+			e.Type = value
+		case "dataschema":
+			e.DataSchema = value
+		case "subject":
+			e.Subject = value
+		case "time":
+			e.Time = value
+		case "datacontenttype", "data", "data_base64":
+			return CloudEvent{}, fmt.Errorf("Cloud event attribute %s can't be sent as a header", name)
+		default:
+			e.Extensions[name] = value
+		}
+	}
+
+	if len(e.Extensions) == 0 {
+		e.Extensions = nil
+	}
+
+	if len(body) > 0 {
+		e.DataBase64 = b64.StdEncoding.EncodeToString(body)
+	}
+
+	return e, nil
+}
+
+// HTTPBinding returns the headers and the body that deliver the event in binary mode
+func (e CloudEvent) HTTPBinding() (http.Header, []byte, error) {
+
+	header := http.Header{}
+
+	attributes := map[string]string{
+		"specversion": e.SpecVersion,
+		"id":          e.ID,
+		"source":      e.Source,
+		"type":        e.Type,
+		"dataschema":  e.DataSchema,
+		"subject":     e.Subject,
+		"time":        e.Time,
+	}
+
+	for name, value := range e.Extensions {
+		attributes[name] = value
+	}
+
+	names := []string{}
+	for name, value := range attributes {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		header.Set(CloudEventAttrPrefix+name, percentEncode(attributes[name]))
+	}
+
+	if e.DataContentType != "" {
+		header.Set("Content-Type", e.DataContentType)
+	} else if len(e.Data) > 0 {
+		header.Set("Content-Type", "application/json")
+	}
+
+	body, err := e.payload()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return header, body, nil
+}
+
+// percentEncode encodes the characters that the HTTP binding doesn't allow in header values as is
+func percentEncode(value string) string {
+
+	encoded := strings.Builder{}
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c <= ' ' || c >= 0x7f || c == '"' || c == '%' {
+			encoded.WriteString(fmt.Sprintf("%%%02X", c))
+			continue
+		}
+		encoded.WriteByte(c)
+	}
+
+	return encoded.String()
+}
+
+// CloudEvents maps the received messages to events, using the given source for the messages that weren't published as events
+func (recList RecList) CloudEvents(source string) RecCloudEventList {
+
+	events := RecCloudEventList{RecEvents: []RecCloudEvent{}}
+
+	for _, rec := range recList.RecMsgs {
+		events.RecEvents = append(events.RecEvents, RecCloudEvent{AckID: rec.AckID, Event: rec.Msg.CloudEvent(source)})
+	}
+
+	return events
+}
+
+// ExportJSON exports the received events as a json string
+func (events *RecCloudEventList) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(events, "", "   ")
+	return string(output[:]), err
+}
@@ -0,0 +1,187 @@
+package messages
+
+import (
+	b64 "encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+func (suite *MsgTestSuite) TestCloudEventRoundTrip() {
+
+	type td struct {
+		event       string
+		expectedMsg Message
+		msg         string
+	}
+
+	testData := []td{
+		{
+			event: `{"specversion":"1.0","id":"e1","source":"/sensors/1","type":"reading","time":"2026-10-19T10:00:00Z","unit":"celsius","data":{"value":21}}`,
+			expectedMsg: Message{
+				Attr: Attributes{
+					"ce-specversion": "1.0",
+					"ce-id":          "e1",
+					"ce-source":      "/sensors/1",
+					"ce-type":        "reading",
+					"ce-time":        "2026-10-19T10:00:00Z",
+					"ce-unit":        "celsius",
+				},
+				Data: b64.StdEncoding.EncodeToString([]byte(`{"value":21}`)),
+			},
+			msg: "json data",
+		},
+		{
+			event: `{"specversion":"1.0","id":"e2","source":"/sensors/1","type":"note","datacontenttype":"text/plain","data":"hello"}`,
+			expectedMsg: Message{
+				Attr: Attributes{
+					"ce-specversion":     "1.0",
+					"ce-id":              "e2",
+					"ce-source":          "/sensors/1",
+					"ce-type":            "note",
+					"ce-datacontenttype": "text/plain",
+				},
+				Data: b64.StdEncoding.EncodeToString([]byte("hello")),
+			},
+			msg: "text data",
+		},
+		{
+			event: `{"specversion":"1.0","id":"e3","source":"/sensors/1","type":"raw","datacontenttype":"application/octet-stream","data_base64":"AAEC"}`,
+			expectedMsg: Message{
+				Attr: Attributes{
+					"ce-specversion":     "1.0",
+					"ce-id":              "e3",
+					"ce-source":          "/sensors/1",
+					"ce-type":            "raw",
+					"ce-datacontenttype": "application/octet-stream",
+				},
+				Data: "AAEC",
+			},
+			msg: "binary data",
+		},
+	}
+
+	for _, t := range testData {
+		e := CloudEvent{}
+		suite.Nil(json.Unmarshal([]byte(t.event), &e), t.msg)
+
+		msg, err := e.ToMessage()
+		suite.Nil(err, t.msg)
+		suite.Equal(t.expectedMsg, msg, t.msg)
+
+		// the message maps back to the original event
+		output, err := json.Marshal(msg.CloudEvent("/projects/ARGO/topics/topic1"))
+		suite.Nil(err, t.msg)
+		suite.JSONEq(t.event, string(output), t.msg)
+	}
+}
+
+func (suite *MsgTestSuite) TestCloudEventFromMessage() {
+
+	msg := Message{
+		ID:      "5",
+		Attr:    Attributes{"station": "athens", "Not-An-Extension": "x"},
+		Data:    b64.StdEncoding.EncodeToString([]byte("raw payload")),
+		PubTime: "2026-10-19T10:00:00.000000001Z",
+	}
+
+	e := msg.CloudEvent("/projects/ARGO/topics/topic1")
+	suite.Equal(CloudEvent{
+		SpecVersion: "1.0",
+		ID:          "5",
+		Source:      "/projects/ARGO/topics/topic1",
+		Type:        CloudEventDefaultType,
+		Time:        "2026-10-19T10:00:00.000000001Z",
+		Extensions:  map[string]string{"station": "athens"},
+		DataBase64:  msg.Data,
+	}, e)
+}
+
+func (suite *MsgTestSuite) TestCloudEventValidation() {
+
+	type td struct {
+		event       string
+		expectedErr string
+	}
+
+	testData := []td{
+		{
+			event:       `{"specversion":"0.3","id":"e1","source":"s","type":"t"}`,
+			expectedErr: "Cloud event specversion should be 1.0",
+		},
+		{
+			event:       `{"specversion":"1.0","id":"e1","type":"t"}`,
+			expectedErr: "Cloud event should declare an id, a source and a type",
+		},
+		{
+			event:       `{"specversion":"1.0","id":"e1","source":"s","type":"t","time":"yesterday"}`,
+			expectedErr: "Cloud event time should be a valid RFC3339 timestamp",
+		},
+		{
+			event:       `{"specversion":"1.0","id":"e1","source":"s","type":"t","Bad-Name":"v"}`,
+			expectedErr: "Cloud event extension Bad-Name should consist of up to 20 lowercase letters or digits",
+		},
+		{
+			event:       `{"specversion":"1.0","id":"e1","source":"s","type":"t","data":1,"data_base64":"AA=="}`,
+			expectedErr: "Cloud event data and data_base64 cannot be used together",
+		},
+	}
+
+	for _, t := range testData {
+		e := CloudEvent{}
+		suite.Nil(json.Unmarshal([]byte(t.event), &e))
+		_, err := e.ToMessage()
+		suite.EqualError(err, t.expectedErr)
+	}
+}
+
+func (suite *MsgTestSuite) TestLoadCloudEventsHTTP() {
+
+	// structured mode
+	header := http.Header{}
+	header.Set("Content-Type", CloudEventsBatchContentType)
+	suite.True(IsCloudEventsRequest(header))
+	msgList, err := LoadCloudEventsHTTP(header, []byte(`[
+		{"specversion":"1.0","id":"e1","source":"s","type":"t","data":{"a":1}},
+		{"specversion":"1.0","id":"e2","source":"s","type":"t"}
+	]`))
+	suite.Nil(err)
+	suite.Equal(2, len(msgList.Msgs))
+	suite.Equal(`{"a":1}`, msgList.Msgs[0].GetDecoded())
+	suite.Equal("e2", msgList.Msgs[1].Attr["ce-id"])
+
+	_, err = LoadCloudEventsHTTP(header, []byte(`{"specversion":"1.0"}`))
+	suite.EqualError(err, "Cloud events batch should be a valid JSON array of events")
+
+	// binary mode
+	header = http.Header{}
+	header.Set("Content-Type", "text/plain")
+	header.Set("ce-specversion", "1.0")
+	header.Set("ce-id", "e3")
+	header.Set("ce-source", "/sensors/1")
+	header.Set("ce-type", "note")
+	header.Set("ce-comment", "hello%20world")
+	suite.True(IsCloudEventsRequest(header))
+	msgList, err = LoadCloudEventsHTTP(header, []byte("some text"))
+	suite.Nil(err)
+	suite.Equal(Attributes{
+		"ce-specversion":     "1.0",
+		"ce-id":              "e3",
+		"ce-source":          "/sensors/1",
+		"ce-type":            "note",
+		"ce-comment":         "hello world",
+		"ce-datacontenttype": "text/plain",
+	}, msgList.Msgs[0].Attr)
+	suite.Equal("some text", msgList.Msgs[0].GetDecoded())
+
+	// the event is delivered back in binary mode
+	outHeader, body, err := msgList.Msgs[0].CloudEvent("").HTTPBinding()
+	suite.Nil(err)
+	suite.Equal("some text", string(body))
+	suite.Equal("text/plain", outHeader.Get("Content-Type"))
+	suite.Equal("/sensors/1", outHeader.Get("ce-source"))
+	suite.Equal("hello%20world", outHeader.Get("ce-comment"))
+
+	header = http.Header{}
+	header.Set("Content-Type", "application/json")
+	suite.False(IsCloudEventsRequest(header))
+}
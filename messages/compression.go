@@ -0,0 +1,214 @@
+package messages
+
+import (
+	"bytes"
+	"compress/gzip"
+	b64 "encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// CompressionGzip compresses message payloads using gzip
+	CompressionGzip = "gzip"
+	// CompressionZstd compresses message payloads using zstandard
+	CompressionZstd = "zstd"
+)
+
+// MaxMessageSize is the size in bytes of the largest message that the broker accepts.
+// No payload gets compressed or decompressed beyond it
+const MaxMessageSize = 1500000
+
+// zstdMaxWindow bounds the memory that decoding a zstandard payload may allocate for its window
+const zstdMaxWindow = 8 << 20
+
+// ErrPayloadTooLarge is returned when a payload to compress, or the payload that a message decompresses to, exceeds MaxMessageSize
+var ErrPayloadTooLarge = errors.New("Message size is too large")
+
+// the encoders and decoders are pooled, since creating them for every message is expensive
+var (
+	gzipWriters  = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+	gzipReaders  = sync.Pool{}
+	zstdEncoders = sync.Pool{New: func() interface{} {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return w
+	}}
+	zstdDecoders = sync.Pool{New: func() interface{} {
+		// the window of the default encoder is the largest one that the stored payloads use
+		r, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow))
+		return r
+	}}
+)
+
+// compressGzip compresses the payload with a pooled gzip writer
+func compressGzip(payload []byte) ([]byte, error) {
+
+	var buf bytes.Buffer
+
+	w := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(w)
+	w.Reset(&buf)
+
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// compressZstd compresses the payload with a pooled zstandard encoder
+func compressZstd(payload []byte) ([]byte, error) {
+
+	var buf bytes.Buffer
+
+	w := zstdEncoders.Get().(*zstd.Encoder)
+	defer zstdEncoders.Put(w)
+	w.Reset(&buf)
+
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decompressGzip decompresses the payload with a pooled gzip reader
+func decompressGzip(compressed []byte) ([]byte, error) {
+
+	var r *gzip.Reader
+	if pooled, ok := gzipReaders.Get().(*gzip.Reader); ok {
+		if err := pooled.Reset(bytes.NewReader(compressed)); err != nil {
+			return nil, err
+		}
+		r = pooled
+	} else {
+		created, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, err
+		}
+		r = created
+	}
+	defer gzipReaders.Put(r)
+
+	return readLimited(r)
+}
+
+// decompressZstd decompresses the payload with a pooled zstandard decoder
+func decompressZstd(compressed []byte) ([]byte, error) {
+
+	r := zstdDecoders.Get().(*zstd.Decoder)
+	defer zstdDecoders.Put(r)
+
+	if err := r.Reset(bytes.NewReader(compressed)); err != nil {
+		return nil, err
+	}
+	// let go of the compressed payload while the decoder sits in the pool
+	defer r.Reset(nil)
+
+	return readLimited(r)
+}
+
+// readLimited reads a decompressed payload, failing as soon as it exceeds MaxMessageSize
+func readLimited(r io.Reader) ([]byte, error) {
+
+	payload, err := ioutil.ReadAll(io.LimitReader(r, MaxMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) > MaxMessageSize {
+		return nil, ErrPayloadTooLarge
+	}
+
+	return payload, nil
+}
+
+// IsValidCompression checks whether the given algorithm can compress message payloads.
+// An empty algorithm stands for uncompressed payloads
+func IsValidCompression(algorithm string) bool {
+	return algorithm == "" || algorithm == CompressionGzip || algorithm == CompressionZstd
+}
+
+// Compress compresses the payload of the message using the given algorithm and records the algorithm in the message.
+// An empty algorithm leaves the message untouched
+func (msg *Message) Compress(algorithm string) error {
+
+	if algorithm == "" {
+		return nil
+	}
+
+	if msg.Compression != "" {
+		return errors.New("Message payload is already compressed")
+	}
+
+	payload, err := b64.StdEncoding.DecodeString(msg.Data)
+	if err != nil {
+		return errors.New("Message payload is not base64 encoded")
+	}
+
+	if len(payload) > MaxMessageSize {
+		return ErrPayloadTooLarge
+	}
+
+	var compressed []byte
+
+	switch algorithm {
+	case CompressionGzip:
+		compressed, err = compressGzip(payload)
+	case CompressionZstd:
+		compressed, err = compressZstd(payload)
+	default:
+		return errors.New("Unsupported compression algorithm " + algorithm)
+	}
+	if err != nil {
+		return err
+	}
+
+	msg.Data = b64.StdEncoding.EncodeToString(compressed)
+	msg.Compression = algorithm
+
+	return nil
+}
+
+// Decompress restores the payload of a message that got compressed before it got stored in the broker.
+// Uncompressed messages are left untouched, while payloads that decompress beyond MaxMessageSize fail with ErrPayloadTooLarge
+func (msg *Message) Decompress() error {
+
+	if msg.Compression == "" {
+		return nil
+	}
+
+	compressed, err := b64.StdEncoding.DecodeString(msg.Data)
+	if err != nil {
+		return errors.New("Message payload is not base64 encoded")
+	}
+
+	var payload []byte
+
+	switch msg.Compression {
+	case CompressionGzip:
+		payload, err = decompressGzip(compressed)
+	case CompressionZstd:
+		payload, err = decompressZstd(compressed)
+	default:
+		return errors.New("Unsupported compression algorithm " + msg.Compression)
+	}
+	if err != nil {
+		return err
+	}
+
+	msg.Data = b64.StdEncoding.EncodeToString(payload)
+	msg.Compression = ""
+
+	return nil
+}
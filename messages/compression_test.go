@@ -0,0 +1,68 @@
+package messages
+
+import (
+	b64 "encoding/base64"
+	"strings"
+)
+
+func (suite *MsgTestSuite) TestCompression() {
+
+	payload := strings.Repeat(`{"status":"CRITICAL","host":"node1.example.com"}`, 50)
+	data := b64.StdEncoding.EncodeToString([]byte(payload))
+
+	for _, algorithm := range []string{CompressionGzip, CompressionZstd} {
+		msg := Message{Data: data}
+		suite.Nil(msg.Compress(algorithm), algorithm)
+		suite.Equal(algorithm, msg.Compression, algorithm)
+		suite.True(msg.Size() < int64(len(data)), algorithm)
+
+		// a message can't get compressed twice
+		suite.Equal("Message payload is already compressed", msg.Compress(algorithm).Error())
+
+		suite.Nil(msg.Decompress(), algorithm)
+		suite.Equal("", msg.Compression, algorithm)
+		suite.Equal(data, msg.Data, algorithm)
+	}
+
+	// no algorithm leaves the message untouched
+	msg := Message{Data: data}
+	suite.Nil(msg.Compress(""))
+	suite.Equal(Message{Data: data}, msg)
+	suite.Nil(msg.Decompress())
+	suite.Equal(Message{Data: data}, msg)
+
+	// errors
+	msg = Message{Data: data}
+	suite.Equal("Unsupported compression algorithm lz4", msg.Compress("lz4").Error())
+	msg = Message{Data: "not base64"}
+	suite.Equal("Message payload is not base64 encoded", msg.Compress(CompressionGzip).Error())
+	msg = Message{Data: data, Compression: CompressionGzip}
+	suite.NotNil(msg.Decompress())
+
+	// payloads are never compressed or decompressed beyond the max message size
+	large := b64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", MaxMessageSize+1)))
+	for _, algorithm := range []string{CompressionGzip, CompressionZstd} {
+		msg = Message{Data: large}
+		suite.Equal(ErrPayloadTooLarge, msg.Compress(algorithm), algorithm)
+
+		// a payload that got compressed elsewhere and expands beyond the limit
+		var compressed []byte
+		if algorithm == CompressionGzip {
+			compressed, _ = compressGzip([]byte(strings.Repeat("a", MaxMessageSize+1)))
+		} else {
+			compressed, _ = compressZstd([]byte(strings.Repeat("a", MaxMessageSize+1)))
+		}
+		msg = Message{Data: b64.StdEncoding.EncodeToString(compressed), Compression: algorithm}
+		suite.Equal(ErrPayloadTooLarge, msg.Decompress(), algorithm)
+
+		// a payload right at the limit still goes through
+		msg = Message{Data: b64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", MaxMessageSize)))}
+		suite.Nil(msg.Compress(algorithm), algorithm)
+		suite.Nil(msg.Decompress(), algorithm)
+	}
+
+	suite.True(IsValidCompression(""))
+	suite.True(IsValidCompression(CompressionGzip))
+	suite.True(IsValidCompression(CompressionZstd))
+	suite.False(IsValidCompression("lz4"))
+}
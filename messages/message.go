@@ -7,6 +7,7 @@ import (
 	"errors"
 	"sort"
 	"strings"
+	"time"
 )
 
 // RecMsg holds info for a received message
@@ -23,6 +24,10 @@ type RecList struct {
 // MsgList is used to hold a list of messages
 type MsgList struct {
 	Msgs []Message `json:"messages"`
+	// IdempotencyKey deduplicates the whole publish request
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	// AcceptValid publishes the messages that match the topic's schema and rejects the rest, instead of the whole request
+	AcceptValid bool `json:"acceptValid,omitempty"`
 }
 
 // Message struct used to hold message information
@@ -31,6 +36,18 @@ type Message struct {
 	Attr    Attributes `json:"attributes,omitempty"`  // used to hold attribute key/value store
 	Data    string     `json:"data"`                  // base64 encoded data payload
 	PubTime string     `json:"publishTime,omitempty"` // publish timedate of message
+	// DeliverAfter holds the RFC3339 timestamp before which the message should not be delivered
+	DeliverAfter string `json:"deliverAfter,omitempty"`
+	// DelaySeconds is an alternative to DeliverAfter, relative to the publish time
+	DelaySeconds int64 `json:"delaySeconds,omitempty"`
+	// IdempotencyKey deduplicates retried publishes of the message
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	// DecodedData holds the JSON representation of the payload, decoded using the topic's schema on consumption
+	DecodedData json.RawMessage `json:"decodedData,omitempty"`
+	// SchemaRevision is the revision of the topic's schema that the payload was published with and gets decoded with
+	SchemaRevision int64 `json:"schemaRevision,omitempty"`
+	// Compression is the algorithm that compressed the payload before it got stored in the broker
+	Compression string `json:"compression,omitempty"`
 }
 
 // PushMsg contains structure for push messages
@@ -71,6 +88,26 @@ func (msgL MsgList) TotalSize() int64 {
 	return sum
 }
 
+// IdempotencyKeys returns the distinct idempotency keys that apply to the message list.
+// A request key replaces the keys of the messages
+func (msgL MsgList) IdempotencyKeys() []string {
+	keys := []string{}
+	seen := map[string]bool{}
+
+	if msgL.IdempotencyKey != "" {
+		return []string{msgL.IdempotencyKey}
+	}
+
+	for _, msg := range msgL.Msgs {
+		if msg.IdempotencyKey != "" && !seen[msg.IdempotencyKey] {
+			keys = append(keys, msg.IdempotencyKey)
+			seen[msg.IdempotencyKey] = true
+		}
+	}
+
+	return keys
+}
+
 //Size returns the messages size in bytes
 func (msg Message) Size() int64 {
 	// Convert data string to byte array
@@ -202,6 +239,66 @@ func (msg *Message) GetAttribute(key string) (string, error) {
 
 }
 
+// ScheduleDelivery validates the delivery options of the message and normalizes them
+// to an absolute UTC deliverAfter timestamp, using t as the reference time for delaySeconds
+func (msg *Message) ScheduleDelivery(t time.Time) error {
+
+	if msg.DelaySeconds < 0 {
+		return errors.New("delaySeconds should be a non negative number")
+	}
+
+	if msg.DelaySeconds > 0 && msg.DeliverAfter != "" {
+		return errors.New("deliverAfter and delaySeconds cannot be used together")
+	}
+
+	if msg.DelaySeconds > 0 {
+		msg.DeliverAfter = t.Add(time.Duration(msg.DelaySeconds) * time.Second).UTC().Format(time.RFC3339)
+		msg.DelaySeconds = 0
+		return nil
+	}
+
+	if msg.DeliverAfter != "" {
+		deliverAfter, err := time.Parse(time.RFC3339, msg.DeliverAfter)
+		if err != nil {
+			return errors.New("deliverAfter should be a valid RFC3339 timestamp")
+		}
+		msg.DeliverAfter = deliverAfter.UTC().Format(time.RFC3339)
+	}
+
+	return nil
+}
+
+// DeliveryTime returns the time before which the message should not be delivered.
+// Messages without a scheduled delivery return the zero time
+func (msg *Message) DeliveryTime() time.Time {
+	if msg.DeliverAfter == "" {
+		return time.Time{}
+	}
+	deliverAfter, err := time.Parse(time.RFC3339, msg.DeliverAfter)
+	if err != nil {
+		return time.Time{}
+	}
+	return deliverAfter
+}
+
+// IsDue checks whether the message can be delivered at the given time
+func (msg *Message) IsDue(t time.Time) bool {
+	return !msg.DeliveryTime().After(t)
+}
+
+// IsExpired checks whether the message has outlived the given ttl at the given time.
+// Messages without a valid publish time or a positive ttl never expire
+func (msg *Message) IsExpired(ttl time.Duration, t time.Time) bool {
+	if ttl <= 0 {
+		return false
+	}
+	pubTime, err := time.Parse(time.RFC3339Nano, msg.PubTime)
+	if err != nil {
+		return false
+	}
+	return pubTime.Add(ttl).Before(t)
+}
+
 // ExportJSON exports whole Message Structure as a json string
 func (pMsg *PushMsg) ExportJSON() (string, error) {
 	output, err := json.MarshalIndent(pMsg, "", "   ")
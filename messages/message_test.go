@@ -4,6 +4,7 @@ import (
 	b64 "encoding/base64"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -113,6 +114,91 @@ func (suite *MsgTestSuite) TestGetDecodedData() {
 
 }
 
+func (suite *MsgTestSuite) TestScheduleDelivery() {
+
+	ref := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	// no delivery options
+	m1 := New("test")
+	suite.Nil(m1.ScheduleDelivery(ref))
+	suite.Equal("", m1.DeliverAfter)
+	suite.True(m1.DeliveryTime().IsZero())
+	suite.True(m1.IsDue(ref))
+
+	// delay relative to the reference time
+	m2 := New("test")
+	m2.DelaySeconds = 60
+	suite.Nil(m2.ScheduleDelivery(ref))
+	suite.Equal("2021-01-01T10:01:00Z", m2.DeliverAfter)
+	suite.Equal(int64(0), m2.DelaySeconds)
+	suite.False(m2.IsDue(ref))
+	suite.True(m2.IsDue(ref.Add(time.Minute)))
+
+	// absolute timestamp gets normalized to UTC
+	m3 := New("test")
+	m3.DeliverAfter = "2021-01-01T12:30:00+02:00"
+	suite.Nil(m3.ScheduleDelivery(ref))
+	suite.Equal("2021-01-01T10:30:00Z", m3.DeliverAfter)
+	suite.Equal(time.Date(2021, 1, 1, 10, 30, 0, 0, time.UTC), m3.DeliveryTime())
+
+	// invalid options
+	m4 := New("test")
+	m4.DelaySeconds = -1
+	suite.Equal(errors.New("delaySeconds should be a non negative number"), m4.ScheduleDelivery(ref))
+
+	m5 := New("test")
+	m5.DelaySeconds = 10
+	m5.DeliverAfter = "2021-01-01T12:30:00Z"
+	suite.Equal(errors.New("deliverAfter and delaySeconds cannot be used together"), m5.ScheduleDelivery(ref))
+
+	m6 := New("test")
+	m6.DeliverAfter = "tomorrow"
+	suite.Equal(errors.New("deliverAfter should be a valid RFC3339 timestamp"), m6.ScheduleDelivery(ref))
+}
+
+func (suite *MsgTestSuite) TestIsExpired() {
+
+	ref := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	m := New("test")
+	m.PubTime = "2021-01-01T09:00:00.123456789Z"
+
+	suite.False(m.IsExpired(0, ref))
+	suite.False(m.IsExpired(2*time.Hour, ref))
+	suite.True(m.IsExpired(30*time.Minute, ref))
+
+	// messages without a publish time never expire
+	m2 := New("test")
+	suite.False(m2.IsExpired(time.Second, ref))
+}
+
+func (suite *MsgTestSuite) TestIdempotencyKeys() {
+
+	msgList := MsgList{}
+	suite.Equal([]string{}, msgList.IdempotencyKeys())
+
+	m1 := New("test")
+	m1.IdempotencyKey = "k1"
+	m2 := New("test")
+	m3 := New("test")
+	m3.IdempotencyKey = "k1"
+	m4 := New("test")
+	m4.IdempotencyKey = "k2"
+
+	msgList = MsgList{Msgs: []Message{m1, m2, m3, m4}}
+	suite.Equal([]string{"k1", "k2"}, msgList.IdempotencyKeys())
+
+	// the request key replaces the keys of the messages
+	msgList.IdempotencyKey = "req"
+	suite.Equal([]string{"req"}, msgList.IdempotencyKeys())
+
+	// the key is part of the json representation
+	msgList2, err := LoadMsgListJSON([]byte(`{"idempotencyKey":"req","messages":[{"data":"dGVzdA==","idempotencyKey":"k1"}]}`))
+	suite.Nil(err)
+	suite.Equal("req", msgList2.IdempotencyKey)
+	suite.Equal("k1", msgList2.Msgs[0].IdempotencyKey)
+}
+
 func TestMsgTestSuite(t *testing.T) {
 	suite.Run(t, new(MsgTestSuite))
 }
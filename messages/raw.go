@@ -0,0 +1,152 @@
+package messages
+
+import (
+	"bytes"
+	b64 "encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// RawAttributeHeaderPrefix prefixes the headers that hold the attributes of raw messages
+	RawAttributeHeaderPrefix = "X-Attribute-"
+	// RawAttributeQueryPrefix prefixes the query parameters that hold the attributes of raw messages
+	RawAttributeQueryPrefix = "attributes."
+)
+
+// ErrUnsupportedMediaType is returned when the body of a raw publish request has a media type that can't be published as is
+var ErrUnsupportedMediaType = errors.New("Unsupported media type, the body should be application/octet-stream, application/json, text/plain or multipart")
+
+// rawMediaTypes lists the media types, besides multipart ones, whose bodies are published as a single payload
+var rawMediaTypes = map[string]bool{
+	"application/octet-stream": true,
+	"application/json":         true,
+	"text/plain":               true,
+}
+
+// rawAttributes collects the attributes that the headers of a raw message declare
+func rawAttributes(header map[string][]string, attr Attributes) {
+	for key, values := range header {
+		canonical := http.CanonicalHeaderKey(key)
+		if !strings.HasPrefix(canonical, RawAttributeHeaderPrefix) || len(values) == 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimPrefix(canonical, RawAttributeHeaderPrefix))
+		if name != "" {
+			attr[name] = values[0]
+		}
+	}
+}
+
+// LoadRawHTTP creates a MsgList from a raw publish request. The body of the request is the payload of a single message,
+// unless it is a multipart body, where every part is the payload of a message.
+// The attributes of the messages are declared with X-Attribute- headers and attributes. query parameters,
+// while the headers of a part declare the attributes of its own message only.
+// The deliverAfter, delaySeconds, idempotencyKey and acceptValid query parameters apply to the whole request.
+// A body without a Content-Type is treated as application/octet-stream, any other media type fails with ErrUnsupportedMediaType.
+func LoadRawHTTP(header http.Header, query url.Values, body []byte) (MsgList, error) {
+
+	mediaType, params := "application/octet-stream", map[string]string{}
+	if contentType := header.Get("Content-Type"); contentType != "" {
+		var err error
+		mediaType, params, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return MsgList{}, ErrUnsupportedMediaType
+		}
+	}
+
+	if !rawMediaTypes[mediaType] && !strings.HasPrefix(mediaType, "multipart/") {
+		return MsgList{}, ErrUnsupportedMediaType
+	}
+
+	attr := Attributes{}
+	for key, values := range query {
+		if strings.HasPrefix(key, RawAttributeQueryPrefix) && len(values) > 0 {
+			name := strings.TrimPrefix(key, RawAttributeQueryPrefix)
+			if name != "" {
+				attr[name] = values[0]
+			}
+		}
+	}
+	rawAttributes(header, attr)
+
+	msgList := MsgList{
+		Msgs:           []Message{},
+		IdempotencyKey: query.Get("idempotencyKey"),
+	}
+
+	if acceptValid := query.Get("acceptValid"); acceptValid != "" {
+		v, err := strconv.ParseBool(acceptValid)
+		if err != nil {
+			return MsgList{}, errors.New("acceptValid should be either true or false")
+		}
+		msgList.AcceptValid = v
+	}
+
+	var delaySeconds int64
+	if delay := query.Get("delaySeconds"); delay != "" {
+		v, err := strconv.ParseInt(delay, 10, 64)
+		if err != nil {
+			return MsgList{}, errors.New("delaySeconds should be a non negative number")
+		}
+		delaySeconds = v
+	}
+
+	newMessage := func(payload []byte, partAttr Attributes) Message {
+		msg := Message{
+			Attr:         Attributes{},
+			Data:         b64.StdEncoding.EncodeToString(payload),
+			DeliverAfter: query.Get("deliverAfter"),
+			DelaySeconds: delaySeconds,
+		}
+		for k, v := range attr {
+			msg.Attr[k] = v
+		}
+		for k, v := range partAttr {
+			msg.Attr[k] = v
+		}
+		return msg
+	}
+
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		msgList.Msgs = append(msgList.Msgs, newMessage(body, nil))
+		return msgList, nil
+	}
+
+	if params["boundary"] == "" {
+		return MsgList{}, errors.New("Multipart body should declare a boundary")
+	}
+
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return MsgList{}, errors.New("Multipart body is malformed")
+		}
+
+		payload, err := ioutil.ReadAll(part)
+		if err != nil {
+			return MsgList{}, errors.New("Multipart body is malformed")
+		}
+
+		partAttr := Attributes{}
+		rawAttributes(part.Header, partAttr)
+		msgList.Msgs = append(msgList.Msgs, newMessage(payload, partAttr))
+	}
+
+	if len(msgList.Msgs) == 0 {
+		return MsgList{}, errors.New("Multipart body should carry at least one message")
+	}
+
+	return msgList, nil
+}
@@ -0,0 +1,71 @@
+package messages
+
+import (
+	b64 "encoding/base64"
+	"net/http"
+	"net/url"
+)
+
+func (suite *MsgTestSuite) TestLoadRawHTTP() {
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set("X-Attribute-Unit", "celsius")
+	query := url.Values{}
+	query.Set("attributes.unit", "kelvin")
+	query.Set("attributes.source", "sensor")
+	query.Set("delaySeconds", "30")
+	query.Set("idempotencyKey", "k1")
+	query.Set("acceptValid", "true")
+
+	msgList, err := LoadRawHTTP(header, query, []byte{0, 1, 2})
+	suite.Nil(err)
+	suite.Equal("k1", msgList.IdempotencyKey)
+	suite.True(msgList.AcceptValid)
+	suite.Equal([]Message{
+		{
+			Attr:         Attributes{"unit": "celsius", "source": "sensor"},
+			Data:         b64.StdEncoding.EncodeToString([]byte{0, 1, 2}),
+			DelaySeconds: 30,
+		},
+	}, msgList.Msgs)
+
+	// multipart bodies publish a message per part
+	header = http.Header{}
+	header.Set("Content-Type", "multipart/mixed; boundary=b")
+	body := "--b\r\nX-Attribute-Unit: celsius\r\n\r\nfirst\r\n--b\r\n\r\nsecond\r\n--b--\r\n"
+	msgList, err = LoadRawHTTP(header, url.Values{}, []byte(body))
+	suite.Nil(err)
+	suite.Equal([]Message{
+		{Attr: Attributes{"unit": "celsius"}, Data: b64.StdEncoding.EncodeToString([]byte("first"))},
+		{Attr: Attributes{}, Data: b64.StdEncoding.EncodeToString([]byte("second"))},
+	}, msgList.Msgs)
+
+	// errors
+	header.Set("Content-Type", "multipart/mixed")
+	_, err = LoadRawHTTP(header, url.Values{}, []byte(body))
+	suite.Equal("Multipart body should declare a boundary", err.Error())
+
+	query = url.Values{}
+	query.Set("acceptValid", "maybe")
+	_, err = LoadRawHTTP(http.Header{}, query, []byte("data"))
+	suite.Equal("acceptValid should be either true or false", err.Error())
+
+	// a body without a content type is published as is
+	msgList, err = LoadRawHTTP(http.Header{}, url.Values{}, []byte("data"))
+	suite.Nil(err)
+	suite.Equal(b64.StdEncoding.EncodeToString([]byte("data")), msgList.Msgs[0].Data)
+
+	header = http.Header{}
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	_, err = LoadRawHTTP(header, url.Values{}, []byte("data"))
+	suite.Nil(err)
+
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = LoadRawHTTP(header, url.Values{}, []byte("a=b"))
+	suite.Equal(ErrUnsupportedMediaType, err)
+
+	header.Set("Content-Type", "text/plain; charset")
+	_, err = LoadRawHTTP(header, url.Values{}, []byte("data"))
+	suite.Equal(ErrUnsupportedMediaType, err)
+}
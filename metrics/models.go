@@ -25,6 +25,8 @@ const (
 	NameDailyTopicMsgs    = "topic.number_of_daily_messages"
 	DescTopicBytes        = "Counter that displays the total size of data (in bytes) published to the specific topic"
 	NameTopicBytes        = "topic.number_of_bytes"
+	DescTopicStoredBytes  = "Counter that displays the total size of data (in bytes) published to the specific topic, as it is stored after compression"
+	NameTopicStoredBytes  = "topic.number_of_stored_bytes"
 	DescProjectUserSubs   = "Counter that displays the number of subscriptions that a user has access to the specific project"
 	NameProjectUserSubs   = "project.user.number_of_subscriptions"
 	DescProjectUserTopics = "Counter that displays the number of topics that a user has access to the specific project"
@@ -33,6 +35,12 @@ const (
 	NameSubMsgs           = "subscription.number_of_messages"
 	DescSubBytes          = "Counter that displays the total size of data (in bytes) consumed from the specific subscription"
 	NameSubBytes          = "subscription.number_of_bytes"
+	DescSubStoredBytes    = "Counter that displays the total size of data (in bytes) consumed from the specific subscription, as it was stored before decompression"
+	NameSubStoredBytes    = "subscription.number_of_stored_bytes"
+	DescSubScheduledMsgs  = "Counter that displays the number of messages of the specific subscription that are scheduled for later delivery and are not yet due"
+	NameSubScheduledMsgs  = "subscription.number_of_scheduled_messages"
+	DescSubExpiredMsgs    = "Counter that displays the number of messages of the specific subscription that were skipped because they exceeded the topic's message ttl"
+	NameSubExpiredMsgs    = "subscription.number_of_expired_messages"
 	DescOpNodeCPU         = "Percentage value that displays the CPU usage of ams service in the specific node"
 	NameOpNodeCPU         = "ams_node.cpu_usage"
 	DescOpNodeMEM         = "Percentage value that displays the Memory usage of ams service in the specific node"
@@ -163,6 +171,30 @@ func NewSubBytes(topic string, value int64, tstamp string) Metric {
 	return m
 }
 
+func NewSubStoredBytes(sub string, value int64, tstamp string) Metric {
+	// Initialize single point timeseries with the latest timestamp and value
+	ts := []Timepoint{Timepoint{Timestamp: tstamp, Value: value}}
+	m := Metric{Metric: NameSubStoredBytes, MetricType: "counter", ValueType: "int64", ResourceType: "subscription", Resource: sub, Timeseries: ts, Description: DescSubStoredBytes}
+
+	return m
+}
+
+func NewSubScheduledMsgs(sub string, value int64, tstamp string) Metric {
+	// Initialize single point timeseries with the latest timestamp and value
+	ts := []Timepoint{Timepoint{Timestamp: tstamp, Value: value}}
+	m := Metric{Metric: NameSubScheduledMsgs, MetricType: "counter", ValueType: "int64", ResourceType: "subscription", Resource: sub, Timeseries: ts, Description: DescSubScheduledMsgs}
+
+	return m
+}
+
+func NewSubExpiredMsgs(sub string, value int64, tstamp string) Metric {
+	// Initialize single point timeseries with the latest timestamp and value
+	ts := []Timepoint{Timepoint{Timestamp: tstamp, Value: value}}
+	m := Metric{Metric: NameSubExpiredMsgs, MetricType: "counter", ValueType: "int64", ResourceType: "subscription", Resource: sub, Timeseries: ts, Description: DescSubExpiredMsgs}
+
+	return m
+}
+
 func NewTopicMsgs(topic string, value int64, tstamp string) Metric {
 	// Initialize single point timeseries with the latest timestamp and value
 	ts := []Timepoint{Timepoint{Timestamp: tstamp, Value: value}}
@@ -183,6 +215,13 @@ func NewTopicBytes(topic string, value int64, tstamp string) Metric {
 	return m
 }
 
+func NewTopicStoredBytes(topic string, value int64, tstamp string) Metric {
+	// Initialize single point timeseries with the latest timestamp and value
+	ts := []Timepoint{Timepoint{Timestamp: tstamp, Value: value}}
+	m := Metric{Metric: NameTopicStoredBytes, MetricType: "counter", ValueType: "int64", ResourceType: "topic", Resource: topic, Timeseries: ts, Description: DescTopicStoredBytes}
+	return m
+}
+
 func NewProjectUserSubs(project string, user string, value int64, tstamp string) Metric {
 	// Initialize single point timeseries with the latest timestamp and value
 	ts := []Timepoint{Timepoint{Timestamp: tstamp, Value: value}}
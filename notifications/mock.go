@@ -0,0 +1,136 @@
+package notifications
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+)
+
+// MockMessage holds an email that the mock SMTP server has received
+type MockMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// MockSMTPServer is a local stand-in of an SMTP server that records the emails it receives
+type MockSMTPServer struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	messages []MockMessage
+	wg       sync.WaitGroup
+}
+
+// NewMockSMTPServer starts a mock SMTP server on a random local port
+func NewMockSMTPServer() (*MockSMTPServer, error) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &MockSMTPServer{listener: listener}
+
+	srv.wg.Add(1)
+	go func() {
+		defer srv.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			srv.wg.Add(1)
+			go func() {
+				defer srv.wg.Done()
+				srv.serve(conn)
+			}()
+		}
+	}()
+
+	return srv, nil
+}
+
+// Host returns the host that the server listens on
+func (srv *MockSMTPServer) Host() string {
+	return srv.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the port that the server listens on
+func (srv *MockSMTPServer) Port() int {
+	return srv.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Messages returns the emails that the server has received so far
+func (srv *MockSMTPServer) Messages() []MockMessage {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	return append([]MockMessage{}, srv.messages...)
+}
+
+// Close stops the server
+func (srv *MockSMTPServer) Close() {
+	srv.listener.Close()
+	srv.wg.Wait()
+}
+
+// serve speaks just enough SMTP to receive the emails of a connection
+func (srv *MockSMTPServer) serve(conn net.Conn) {
+
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost mock SMTP")
+
+	msg := MockMessage{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH"):
+			reply("235 authenticated")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = MockMessage{From: strings.Trim(line[len("MAIL FROM:"):], "<> ")}
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.To = append(msg.To, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 ok")
+		case cmd == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			data := strings.Builder{}
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				// undo the dot stuffing of the client
+				data.WriteString(strings.TrimPrefix(dataLine, "."))
+			}
+			msg.Data = data.String()
+			srv.mutex.Lock()
+			srv.messages = append(srv.messages, msg)
+			srv.mutex.Unlock()
+			reply("250 ok")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
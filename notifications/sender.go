@@ -0,0 +1,120 @@
+package notifications
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dialTimeout is the time that connecting to the SMTP server is allowed to take
+const dialTimeout = 10 * time.Second
+
+// Sender delivers notifications to their recipients
+type Sender interface {
+	Send(to []string, subject string, body string) error
+}
+
+// SMTPSender delivers notifications as plain text emails through an SMTP server
+type SMTPSender struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// NewSMTPSender returns a sender for the given SMTP server, an empty username skips authentication
+func NewSMTPSender(host string, port int, username string, password string, from string) *SMTPSender {
+	return &SMTPSender{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		From:     from,
+	}
+}
+
+// Send emails the given subject and body to the recipients.
+// The connection is upgraded to TLS whenever the server supports it
+func (s *SMTPSender) Send(to []string, subject string, body string) error {
+
+	if len(to) == 0 {
+		return errors.New("no recipients")
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(s.Host, strconv.Itoa(s.Port)), dialTimeout)
+	if err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return err
+		}
+	}
+
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(message(s.From, to, subject, body, time.Now())); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// message builds the headers and the body of a plain text email
+func message(from string, to []string, subject string, body string, date time.Time) []byte {
+
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("From: %s\r\n", headerValue(from)))
+	b.WriteString(fmt.Sprintf("To: %s\r\n", headerValue(strings.Join(to, ", "))))
+	b.WriteString(fmt.Sprintf("Subject: %s\r\n", headerValue(subject)))
+	b.WriteString(fmt.Sprintf("Date: %s\r\n", date.Format(time.RFC1123Z)))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	// the body has its lines terminated by CRLF as SMTP expects
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return []byte(b.String())
+}
+
+// headerValue strips the line breaks that would let a value inject additional headers
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
@@ -0,0 +1,59 @@
+package notifications
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SenderTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SenderTestSuite) TestSend() {
+
+	srv, err := NewMockSMTPServer()
+	suite.Nil(err)
+	defer srv.Close()
+
+	sender := NewSMTPSender(srv.Host(), srv.Port(), "ams", "s3cr3t", "ams@example.org")
+	err = sender.Send([]string{"a@example.org", "b@example.org"}, "Hello", "line 1\n.line 2\n")
+	suite.Nil(err)
+
+	messages := srv.Messages()
+	suite.Equal(1, len(messages))
+	suite.Equal("ams@example.org", messages[0].From)
+	suite.Equal([]string{"a@example.org", "b@example.org"}, messages[0].To)
+	suite.True(strings.Contains(messages[0].Data, "From: ams@example.org\r\n"))
+	suite.True(strings.Contains(messages[0].Data, "To: a@example.org, b@example.org\r\n"))
+	suite.True(strings.Contains(messages[0].Data, "Subject: Hello\r\n"))
+	suite.True(strings.HasSuffix(messages[0].Data, "\r\n\r\nline 1\r\n.line 2\r\n"))
+
+	suite.Equal("no recipients", sender.Send([]string{}, "Hello", "body").Error())
+
+	// an unreachable server fails the notification
+	srv.Close()
+	suite.NotNil(sender.Send([]string{"a@example.org"}, "Hello", "body"))
+}
+
+func (suite *SenderTestSuite) TestMessage() {
+
+	date := time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC)
+	msg := string(message("ams@example.org", []string{"a@example.org"}, "Hi\r\nBcc: c@example.org", "a\nb", date))
+
+	expMsg := "From: ams@example.org\r\n" +
+		"To: a@example.org\r\n" +
+		"Subject: Hi  Bcc: c@example.org\r\n" +
+		"Date: Mon, 01 Jan 2024 15:00:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"a\r\nb"
+	suite.Equal(expMsg, msg)
+}
+
+func TestSenderTestSuite(t *testing.T) {
+	suite.Run(t, new(SenderTestSuite))
+}
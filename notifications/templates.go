@@ -0,0 +1,280 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"text/template"
+
+	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+// The notifications that are sent about user registrations, named after their templates
+const (
+	EmailConfirmationTemplate    = "registration_email_confirmation"
+	PendingRegistrationTemplate  = "registration_pending"
+	AcceptedRegistrationTemplate = "registration_accepted"
+	DeclinedRegistrationTemplate = "registration_declined"
+)
+
+// templateNames lists the templates in the order they are shown
+var templateNames = []string{
+	EmailConfirmationTemplate,
+	PendingRegistrationTemplate,
+	AcceptedRegistrationTemplate,
+	DeclinedRegistrationTemplate,
+}
+
+// defaultTemplates holds the templates that are used until they get edited
+var defaultTemplates = map[string]Template{
+	EmailConfirmationTemplate: {
+		Subject: "Confirm your email for the ARGO Messaging Service",
+		Body: `Dear {{.FirstName}} {{.LastName}},
+
+thank you for registering to the ARGO Messaging Service as {{.Name}}.
+Please confirm your email by visiting the following link:
+
+https://{{.ServiceHost}}/v1/registrations:confirmEmail?activation_token={{.ActivationToken}}
+
+Your registration will be reviewed by the administrators of the service.
+`,
+	},
+	PendingRegistrationTemplate: {
+		Subject: "New user registration {{.Name}} is pending",
+		Body: `A new user registration is pending review.
+
+Name: {{.Name}}
+First name: {{.FirstName}}
+Last name: {{.LastName}}
+Email: {{.Email}}
+Organization: {{.Organization}}
+Description: {{.Description}}
+Registered at: {{.RegisteredAt}}
+
+Review it at https://{{.ServiceHost}}/v1/registrations/{{.RegistrationUUID}}
+`,
+	},
+	AcceptedRegistrationTemplate: {
+		Subject: "Your registration to the ARGO Messaging Service has been accepted",
+		Body: `Dear {{.FirstName}} {{.LastName}},
+
+your registration to the ARGO Messaging Service has been accepted.
+
+Username: {{.Name}}
+Endpoint: https://{{.ServiceHost}}/v1
+
+Your token is not sent by email. Sign in to the web UI of the service to generate it, or ask the service
+administrators to reset it for you through https://{{.ServiceHost}}/v1/users/{{.Name}}:refreshToken.
+Use the token as the value of the x-api-key header of your requests, and keep it secret.
+Please ask the administrators of a project to add you as one of its members, in order to access its topics and subscriptions.
+`,
+	},
+	DeclinedRegistrationTemplate: {
+		Subject: "Your registration to the ARGO Messaging Service has been declined",
+		Body: `Dear {{.FirstName}} {{.LastName}},
+
+your registration to the ARGO Messaging Service as {{.Name}} has been declined.
+{{if .DeclineComment}}
+Comment: {{.DeclineComment}}
+{{end}}`,
+	},
+}
+
+// Template holds the subject and the body of a notification, both of them text/template templates
+type Template struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	Custom  bool   `json:"custom"`
+}
+
+// Templates holds a list of notification templates
+type Templates struct {
+	List []Template `json:"templates"`
+}
+
+// Data holds the values that notification templates can refer to
+type Data struct {
+	Name             string
+	FirstName        string
+	LastName         string
+	Email            string
+	Organization     string
+	Description      string
+	RegistrationUUID string
+	RegisteredAt     string
+	ActivationToken  string
+	DeclineComment   string
+	ServiceHost      string
+}
+
+// ExportJSON exports the template to json format
+func (t *Template) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(t, "", "   ")
+	return string(output[:]), err
+}
+
+// ExportJSON exports the templates list to json format
+func (ts *Templates) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(ts, "", "   ")
+	return string(output[:]), err
+}
+
+// GetTemplateFromJSON retrieves the subject and the body of a template from a json input
+func GetTemplateFromJSON(input []byte) (Template, error) {
+	t := Template{}
+	err := json.Unmarshal(input, &t)
+	return t, err
+}
+
+// Validate checks that the subject and the body of the template are not empty and can be parsed
+func (t Template) Validate() error {
+
+	if t.Subject == "" {
+		return errors.New("empty subject")
+	}
+
+	if t.Body == "" {
+		return errors.New("empty body")
+	}
+
+	if _, err := template.New("subject").Parse(t.Subject); err != nil {
+		return errors.New("invalid subject template")
+	}
+
+	if _, err := template.New("body").Parse(t.Body); err != nil {
+		return errors.New("invalid body template")
+	}
+
+	return nil
+}
+
+// RefersToServiceHost checks whether the subject or the body of the template links to the service
+func (t Template) RefersToServiceHost() bool {
+	return strings.Contains(t.Subject, ".ServiceHost") || strings.Contains(t.Body, ".ServiceHost")
+}
+
+// IsTemplate checks whether a notification has a template with the given name
+func IsTemplate(name string) bool {
+	_, ok := defaultTemplates[name]
+	return ok
+}
+
+// FindTemplates returns the template with the given name, or all of them when no name is given.
+// Templates that have been edited replace their defaults
+func FindTemplates(ctx context.Context, name string, store stores.Store) (Templates, error) {
+
+	names := templateNames
+	if name != "" {
+		if !IsTemplate(name) {
+			return Templates{}, errors.New("not found")
+		}
+		names = []string{name}
+	}
+
+	qTemplates, err := store.QueryNotificationTemplates(ctx, name)
+	if err != nil {
+		return Templates{}, err
+	}
+
+	custom := map[string]stores.QNotificationTemplate{}
+	for _, qt := range qTemplates {
+		custom[qt.Name] = qt
+	}
+
+	result := Templates{List: []Template{}}
+	for _, n := range names {
+		t := defaultTemplates[n]
+		t.Name = n
+		if qt, ok := custom[n]; ok {
+			t.Subject = qt.Subject
+			t.Body = qt.Body
+			t.Custom = true
+		}
+		result.List = append(result.List, t)
+	}
+
+	return result, nil
+}
+
+// UpdateTemplate replaces the subject and the body of a template
+func UpdateTemplate(ctx context.Context, name string, t Template, store stores.Store) error {
+	return store.UpdateNotificationTemplate(ctx, stores.QNotificationTemplate{
+		Name:    name,
+		Subject: t.Subject,
+		Body:    t.Body,
+	})
+}
+
+// ResetTemplate discards the edits of a template, restoring its default
+func ResetTemplate(ctx context.Context, name string, store stores.Store) error {
+	return store.DeleteNotificationTemplate(ctx, name)
+}
+
+// Render fills in the subject and the body of a template with the given data
+func Render(t Template, data Data) (string, string, error) {
+
+	subjectTmpl, err := template.New("subject").Parse(t.Subject)
+	if err != nil {
+		return "", "", err
+	}
+
+	bodyTmpl, err := template.New("body").Parse(t.Body)
+	if err != nil {
+		return "", "", err
+	}
+
+	subject := bytes.Buffer{}
+	if err := subjectTmpl.Execute(&subject, data); err != nil {
+		return "", "", err
+	}
+
+	body := bytes.Buffer{}
+	if err := bodyTmpl.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+
+	return subject.String(), body.String(), nil
+}
+
+// Notify renders the template with the given name and sends it to the recipients.
+// Templates that link to the service are refused when there is no service host to link to
+func Notify(ctx context.Context, sender Sender, name string, to []string, data Data, store stores.Store) error {
+
+	templates, err := FindTemplates(ctx, name, store)
+	if err != nil {
+		return err
+	}
+
+	if data.ServiceHost == "" && templates.List[0].RefersToServiceHost() {
+		return errors.New("no service host")
+	}
+
+	subject, body, err := Render(templates.List[0], data)
+	if err != nil {
+		return err
+	}
+
+	return sender.Send(to, subject, body)
+}
+
+// ServiceAdminEmails returns the emails of the service admins, who get notified about pending registrations
+func ServiceAdminEmails(ctx context.Context, store stores.Store) ([]string, error) {
+
+	users, err := store.QueryUsers(ctx, "", "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	emails := []string{}
+	for _, u := range users {
+		if u.Email != "" && auth.IsServiceAdmin(u.ServiceRoles) {
+			emails = append(emails, u.Email)
+		}
+	}
+
+	return emails, nil
+}
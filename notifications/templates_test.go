@@ -0,0 +1,156 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/stretchr/testify/suite"
+)
+
+type TemplatesTestSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+// mockSender records the notifications instead of sending them
+type mockSender struct {
+	to      []string
+	subject string
+	body    string
+	err     error
+}
+
+func (m *mockSender) Send(to []string, subject string, body string) error {
+	m.to, m.subject, m.body = to, subject, body
+	return m.err
+}
+
+func (suite *TemplatesTestSuite) TestTemplateFromJSON() {
+
+	t, err := GetTemplateFromJSON([]byte(`{"subject": "Hi {{.Name}}", "body": "Welcome"}`))
+	suite.Nil(err)
+	suite.Equal(Template{Subject: "Hi {{.Name}}", Body: "Welcome"}, t)
+	suite.Nil(t.Validate())
+
+	_, err = GetTemplateFromJSON([]byte(`{"subject": 1}`))
+	suite.NotNil(err)
+
+	suite.Equal("empty subject", Template{Body: "b"}.Validate().Error())
+	suite.Equal("empty body", Template{Subject: "s"}.Validate().Error())
+	suite.Equal("invalid subject template", Template{Subject: "{{.Name", Body: "b"}.Validate().Error())
+	suite.Equal("invalid body template", Template{Subject: "s", Body: "{{if}}"}.Validate().Error())
+}
+
+func (suite *TemplatesTestSuite) TestFindUpdateReset() {
+
+	store := stores.NewMockStore("", "")
+
+	res, err := FindTemplates(suite.ctx, "", store)
+	suite.Nil(err)
+	suite.Equal(4, len(res.List))
+	suite.Equal(EmailConfirmationTemplate, res.List[0].Name)
+	suite.Equal(PendingRegistrationTemplate, res.List[1].Name)
+	suite.Equal(AcceptedRegistrationTemplate, res.List[2].Name)
+	suite.Equal(DeclinedRegistrationTemplate, res.List[3].Name)
+	for _, t := range res.List {
+		suite.False(t.Custom)
+		suite.Nil(t.Validate())
+	}
+
+	_, err = FindTemplates(suite.ctx, "unknown", store)
+	suite.Equal("not found", err.Error())
+
+	suite.Nil(UpdateTemplate(suite.ctx, DeclinedRegistrationTemplate, Template{Subject: "Declined", Body: "Sorry"}, store))
+	res, _ = FindTemplates(suite.ctx, DeclinedRegistrationTemplate, store)
+	suite.Equal([]Template{{Name: DeclinedRegistrationTemplate, Subject: "Declined", Body: "Sorry", Custom: true}}, res.List)
+
+	expJSON := `{
+   "name": "registration_declined",
+   "subject": "Declined",
+   "body": "Sorry",
+   "custom": true
+}`
+	outJSON, _ := res.List[0].ExportJSON()
+	suite.Equal(expJSON, outJSON)
+
+	suite.Nil(ResetTemplate(suite.ctx, DeclinedRegistrationTemplate, store))
+	res, _ = FindTemplates(suite.ctx, DeclinedRegistrationTemplate, store)
+	suite.False(res.List[0].Custom)
+	suite.Equal(defaultTemplates[DeclinedRegistrationTemplate].Body, res.List[0].Body)
+}
+
+func (suite *TemplatesTestSuite) TestRender() {
+
+	data := Data{
+		Name:            "new-user",
+		FirstName:       "New",
+		LastName:        "User",
+		ActivationToken: "atkn",
+		ServiceHost:     "ams.example.org",
+	}
+
+	subject, body, err := Render(defaultTemplates[EmailConfirmationTemplate], data)
+	suite.Nil(err)
+	suite.Equal("Confirm your email for the ARGO Messaging Service", subject)
+	suite.True(strings.Contains(body, "Dear New User,"))
+	suite.True(strings.Contains(body, "https://ams.example.org/v1/registrations:confirmEmail?activation_token=atkn"))
+
+	// the decline comment is only mentioned when there is one
+	_, body, _ = Render(defaultTemplates[DeclinedRegistrationTemplate], data)
+	suite.False(strings.Contains(body, "Comment:"))
+	data.DeclineComment = "unknown organization"
+	_, body, _ = Render(defaultTemplates[DeclinedRegistrationTemplate], data)
+	suite.True(strings.Contains(body, "Comment: unknown organization"))
+
+	_, _, err = Render(Template{Subject: "{{.Unknown}}", Body: "b"}, data)
+	suite.NotNil(err)
+}
+
+func (suite *TemplatesTestSuite) TestNotify() {
+
+	store := stores.NewMockStore("", "")
+	sender := &mockSender{}
+
+	suite.Nil(UpdateTemplate(suite.ctx, AcceptedRegistrationTemplate, Template{Subject: "Welcome {{.Name}}", Body: "Endpoint {{.ServiceHost}}"}, store))
+	err := Notify(suite.ctx, sender, AcceptedRegistrationTemplate, []string{"new@example.org"}, Data{Name: "new-user", ServiceHost: "ams.example.org"}, store)
+	suite.Nil(err)
+	suite.Equal([]string{"new@example.org"}, sender.to)
+	suite.Equal("Welcome new-user", sender.subject)
+	suite.Equal("Endpoint ams.example.org", sender.body)
+
+	// a template that links to the service isn't sent without a service host
+	sender.to = nil
+	suite.Equal("no service host", Notify(suite.ctx, sender, AcceptedRegistrationTemplate, []string{"new@example.org"}, Data{Name: "new-user"}, store).Error())
+	suite.Nil(sender.to)
+	suite.Nil(Notify(suite.ctx, sender, DeclinedRegistrationTemplate, []string{"new@example.org"}, Data{Name: "new-user"}, store))
+	suite.Equal([]string{"new@example.org"}, sender.to)
+
+	sender.err = errors.New("unreachable")
+	suite.Equal("unreachable", Notify(suite.ctx, sender, AcceptedRegistrationTemplate, []string{"new@example.org"}, Data{ServiceHost: "ams.example.org"}, store).Error())
+}
+
+func (suite *TemplatesTestSuite) TestServiceAdminEmails() {
+
+	store := stores.NewMockStore("", "")
+
+	emails, err := ServiceAdminEmails(suite.ctx, store)
+	suite.Nil(err)
+	suite.Equal([]string{}, emails)
+
+	store.UserList[1].ServiceRoles = []string{"service_admin"}
+	store.UserList[1].Email = "admin@example.org"
+	store.UserList[2].ServiceRoles = []string{"service_admin"}
+	store.UserList[2].Email = ""
+
+	emails, _ = ServiceAdminEmails(suite.ctx, store)
+	suite.Equal([]string{"admin@example.org"}, emails)
+}
+
+func TestTemplatesTestSuite(t *testing.T) {
+	suite.Run(t, &TemplatesTestSuite{
+		ctx: context.Background(),
+	})
+}
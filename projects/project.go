@@ -7,6 +7,7 @@ import (
 
 	"time"
 
+	"github.com/ARGOeu/argo-messaging/ratelimit"
 	"github.com/ARGOeu/argo-messaging/stores"
 )
 
@@ -240,6 +241,31 @@ func RemoveProject(ctx context.Context, uuid string, store stores.Store) error {
 		return errors.New("backend error")
 	}
 
+	// Remove the held back messages of the project's subscriptions
+	if err := store.RemoveProjectScheduledMessages(ctx, uuid); err != nil {
+		return errors.New("backend error")
+	}
+
+	// Remove the idempotency keys of the project's topics
+	if err := store.RemoveProjectIdempotencyKeys(ctx, uuid); err != nil {
+		return errors.New("backend error")
+	}
+
+	// Remove the groups of the project's users
+	if err := store.RemoveGroups(ctx, uuid, ""); err != nil {
+		return errors.New("backend error")
+	}
+
+	// Remove the rate limits of the project
+	if err := store.UpdateRateLimits(ctx, ratelimit.ScopeProject, uuid, nil); err != nil {
+		return errors.New("backend error")
+	}
+
+	// Remove the quotas of the project along with its daily usage
+	if err := store.RemoveProjectQuota(ctx, uuid); err != nil {
+		return errors.New("backend error")
+	}
+
 	return nil
 
 }
@@ -171,6 +171,9 @@ func (suite *ProjectsTestSuite) TestProjects() {
 	suite.Equal(expUpdJSON, outAllUpdJSON)
 
 	// Test removing project
+	store.UpdateRateLimits(suite.ctx, "projects", "argo_uuid", []stores.QRateLimit{{RouteClass: "publish", RequestsPerSecond: 10}})
+	store.UpdateProjectQuota(suite.ctx, stores.QProjectQuota{ProjectUUID: "argo_uuid", MaxTopics: 10})
+	store.IncrementDailyProjectUsage(suite.ctx, "argo_uuid", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 1, 10)
 	RemoveProject(suite.ctx, "argo_uuid", store)
 	pRemoved, err := Find(suite.ctx, "argo_uuid", "", store)
 	suite.Equal(Projects{}, pRemoved)
@@ -181,6 +184,9 @@ func (suite *ProjectsTestSuite) TestProjects() {
 	suite.Equal(0, len(resTop))
 	resSub, _, _, _ := store.QuerySubs(suite.ctx, "argo_uuid", "", "", "", 0)
 	suite.Equal(0, len(resSub))
+	suite.Equal(0, len(store.RateLimits))
+	suite.Equal(0, len(store.ProjectQuotas))
+	suite.Equal(0, len(store.DailyProjectUsage))
 }
 
 func TestProjectsTestSuite(t *testing.T) {
@@ -3,6 +3,7 @@ package push
 import (
 	"context"
 	"errors"
+	"strconv"
 	"strings"
 	"time"
 
@@ -127,8 +128,22 @@ func (p *Pusher) push(brk brokers.Broker, store stores.Store) {
 
 		pMsg.Msg, _ = messages.LoadMsgJSON([]byte(msgs[0]))
 		pMsg.Sub = p.sub.FullName
-		pMsgJSON, _ := pMsg.ExportJSON()
-		err := p.sndr.Send(pMsgJSON, p.endpoint)
+
+		// restore the payload that the topic stored compressed
+		storedBytes := pMsg.Msg.Size()
+		if err := pMsg.Msg.Decompress(); err != nil {
+			log.Error("Unable to decompress message: ", err.Error())
+			return
+		}
+
+		var err error
+		if p.sub.IsCloudEvents() {
+			pMsg.Msg.ID = strconv.FormatInt(p.sub.Offset, 10)
+			err = p.sndr.SendEvent(pMsg.Msg.CloudEvent(p.sub.FullTopic), p.endpoint)
+		} else {
+			pMsgJSON, _ := pMsg.ExportJSON()
+			err = p.sndr.Send(pMsgJSON, p.endpoint)
+		}
 
 		if err == nil {
 			// Advance the offset
@@ -136,6 +151,7 @@ func (p *Pusher) push(brk brokers.Broker, store stores.Store) {
 			// Update subscription's metrics
 			store.IncrementSubMsgNum(context.Background(), p.sub.ProjectUUID, p.sub.Name, int64(1))
 			store.IncrementSubBytes(context.Background(), p.sub.ProjectUUID, p.sub.Name, pMsg.Msg.Size())
+			store.IncrementSubStoredBytes(context.Background(), p.sub.ProjectUUID, p.sub.Name, storedBytes)
 			log.Debug("offset updated")
 		}
 	} else {
@@ -1,6 +1,7 @@
 package push
 
 import (
+	"context"
 	"errors"
 	"io/ioutil"
 	"testing"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/messages"
 	"github.com/ARGOeu/argo-messaging/stores"
 	"github.com/stretchr/testify/suite"
 )
@@ -60,6 +62,31 @@ func (suite *PushTestSuite) TestPusher() {
 	suite.Equal("endpoint.foo", p.sub.PushCfg.Pend)
 }
 
+func (suite *PushTestSuite) TestPushCloudEvents() {
+	sndr := NewMockSender(false)
+	brk := brokers.MockBroker{}
+	brk.PopulateOne()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.ModSubOutputFormat(context.Background(), "argo_uuid", "sub4", "cloudevents")
+	pushMgr := NewManager(&brk, str, sndr)
+	suite.Nil(pushMgr.Add("argo_uuid", "sub4"))
+	p, _ := pushMgr.Get("argo_uuid/sub4")
+
+	p.push(&brk, str)
+
+	suite.Equal("", sndr.LastMsg)
+	suite.Equal("endpoint.foo", sndr.LastEndpoint)
+	suite.Equal(messages.CloudEvent{
+		SpecVersion: "1.0",
+		ID:          "0",
+		Source:      "/projects/ARGO/topics/topic4",
+		Type:        messages.CloudEventDefaultType,
+		Time:        "2016-02-24T11:55:09.786127994Z",
+		Extensions:  map[string]string{"foo": "bar"},
+		DataBase64:  "YmFzZTY0ZW5jb2RlZA==",
+	}, sndr.LastEvent)
+}
+
 func TestPushTestSuite(t *testing.T) {
 	suite.Run(t, new(PushTestSuite))
 }
@@ -6,12 +6,15 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/ARGOeu/argo-messaging/messages"
 	log "github.com/sirupsen/logrus"
 )
 
 // Sender is inteface for sending messages to remote endpoints
 type Sender interface {
 	Send(msg string, endpoint string) error
+	// SendEvent sends an event using the binary mode of the CloudEvents HTTP binding
+	SendEvent(event messages.CloudEvent, endpoint string) error
 }
 
 // HTTPSender sends msgs through http
@@ -23,6 +26,7 @@ type HTTPSender struct {
 type MockSender struct {
 	ClientFail   bool
 	LastMsg      string
+	LastEvent    messages.CloudEvent
 	LastEndpoint string
 }
 
@@ -38,10 +42,36 @@ func NewHTTPSender(timeoutSec int) *HTTPSender {
 func (hs *HTTPSender) Send(msg string, endpoint string) error {
 	var jsonStr = []byte(msg)
 	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(jsonStr))
+	if err != nil {
+		return err
+	}
 	req.Header.Set("Content-Type", "application/json")
 	log.Debug("Sending to endpoint:", endpoint)
 	log.Debug("message contents:", msg)
 
+	return hs.do(req)
+}
+
+// SendEvent sends an event through HTTP, with its attributes as ce- headers and its data as the request body
+func (hs *HTTPSender) SendEvent(event messages.CloudEvent, endpoint string) error {
+	header, body, err := event.HTTPBinding()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	log.Debug("Sending event to endpoint:", endpoint)
+	log.Debug("event id:", event.ID)
+
+	return hs.do(req)
+}
+
+// do delivers a request and checks that the endpoint accepted it
+func (hs *HTTPSender) do(req *http.Request) error {
 	resp, err := hs.Client.Do(req)
 
 	if err == nil {
@@ -75,3 +105,15 @@ func (ms *MockSender) Send(msg string, endpoint string) error {
 
 	return nil
 }
+
+// SendEvent keeps the event that would be sent
+func (ms *MockSender) SendEvent(event messages.CloudEvent, endpoint string) error {
+	if ms.ClientFail == true {
+		return errors.New("endpoint not reachable")
+	}
+
+	ms.LastEvent = event
+	ms.LastEndpoint = endpoint
+
+	return nil
+}
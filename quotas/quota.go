@@ -0,0 +1,232 @@
+package quotas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+// The quotas of a project, named after their json fields
+const (
+	MaxTopics        = "max_topics"
+	MaxSubscriptions = "max_subscriptions"
+	MaxUsers         = "max_users"
+	DailyMessages    = "daily_messages"
+	DailyBytes       = "daily_bytes"
+)
+
+// Limits holds the quotas of a project, 0 means no quota
+type Limits struct {
+	MaxTopics        int64 `json:"max_topics"`
+	MaxSubscriptions int64 `json:"max_subscriptions"`
+	MaxUsers         int64 `json:"max_users"`
+	DailyMessages    int64 `json:"daily_messages"`
+	DailyBytes       int64 `json:"daily_bytes"`
+}
+
+// Usage holds the resources of a project and the messages and bytes it has published today
+type Usage struct {
+	Topics        int64 `json:"topics"`
+	Subscriptions int64 `json:"subscriptions"`
+	Users         int64 `json:"users"`
+	DailyMessages int64 `json:"daily_messages"`
+	DailyBytes    int64 `json:"daily_bytes"`
+}
+
+// Quota holds the quotas of a project along with its current usage
+type Quota struct {
+	Limits Limits `json:"limits"`
+	Usage  Usage  `json:"usage"`
+}
+
+// ExportJSON exports the quota to json format
+func (q *Quota) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(q, "", "   ")
+	return string(output[:]), err
+}
+
+// GetLimitsFromJSON retrieves the quotas of a project from a json input, quotas that are missing are set to 0
+func GetLimitsFromJSON(input []byte) (Limits, error) {
+	limits := Limits{}
+	err := json.Unmarshal(input, &limits)
+	return limits, err
+}
+
+// Validate checks that none of the quotas is negative
+func (l Limits) Validate() error {
+	values := []struct {
+		name  string
+		value int64
+	}{
+		{MaxTopics, l.MaxTopics},
+		{MaxSubscriptions, l.MaxSubscriptions},
+		{MaxUsers, l.MaxUsers},
+		{DailyMessages, l.DailyMessages},
+		{DailyBytes, l.DailyBytes},
+	}
+
+	for _, v := range values {
+		if v.value < 0 {
+			return fmt.Errorf("negative value for quota %v", v.name)
+		}
+	}
+	return nil
+}
+
+// Day returns the day that the usage of a point in time is counted in
+func Day(now time.Time) time.Time {
+	year, month, day := now.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// FindLimits returns the quotas of a project
+func FindLimits(ctx context.Context, projectUUID string, store stores.Store) (Limits, error) {
+	qQuota, err := store.QueryProjectQuota(ctx, projectUUID)
+	if err != nil {
+		return Limits{}, err
+	}
+
+	return Limits{
+		MaxTopics:        qQuota.MaxTopics,
+		MaxSubscriptions: qQuota.MaxSubscriptions,
+		MaxUsers:         qQuota.MaxUsers,
+		DailyMessages:    qQuota.DailyMessages,
+		DailyBytes:       qQuota.DailyBytes,
+	}, nil
+}
+
+// Find returns the quotas of a project along with its usage at the given point in time
+func Find(ctx context.Context, projectUUID string, now time.Time, store stores.Store) (Quota, error) {
+	limits, err := FindLimits(ctx, projectUUID, store)
+	if err != nil {
+		return Quota{}, err
+	}
+
+	usage := Usage{}
+	if usage.Topics, err = countResources(ctx, projectUUID, MaxTopics, store); err != nil {
+		return Quota{}, err
+	}
+	if usage.Subscriptions, err = countResources(ctx, projectUUID, MaxSubscriptions, store); err != nil {
+		return Quota{}, err
+	}
+	if usage.Users, err = countResources(ctx, projectUUID, MaxUsers, store); err != nil {
+		return Quota{}, err
+	}
+
+	qUsage, err := store.QueryDailyProjectUsage(ctx, projectUUID, Day(now))
+	if err != nil {
+		return Quota{}, err
+	}
+	usage.DailyMessages = qUsage.Messages
+	usage.DailyBytes = qUsage.Bytes
+
+	return Quota{Limits: limits, Usage: usage}, nil
+}
+
+// Update replaces the quotas of a project
+func Update(ctx context.Context, projectUUID string, limits Limits, store stores.Store) error {
+	return store.UpdateProjectQuota(ctx, stores.QProjectQuota{
+		ProjectUUID:      projectUUID,
+		MaxTopics:        limits.MaxTopics,
+		MaxSubscriptions: limits.MaxSubscriptions,
+		MaxUsers:         limits.MaxUsers,
+		DailyMessages:    limits.DailyMessages,
+		DailyBytes:       limits.DailyBytes,
+	})
+}
+
+// CheckCreate checks whether one more resource fits in the given quota of a project,
+// one of max_topics, max_subscriptions and max_users. It returns the name of the quota when it doesn't
+func CheckCreate(ctx context.Context, projectUUID string, quota string, store stores.Store) (string, error) {
+	limits, err := FindLimits(ctx, projectUUID, store)
+	if err != nil {
+		return "", err
+	}
+
+	max := int64(0)
+	switch quota {
+	case MaxTopics:
+		max = limits.MaxTopics
+	case MaxSubscriptions:
+		max = limits.MaxSubscriptions
+	case MaxUsers:
+		max = limits.MaxUsers
+	default:
+		return "", errors.New("invalid quota")
+	}
+
+	if max == 0 {
+		return "", nil
+	}
+
+	count, err := countResources(ctx, projectUUID, quota, store)
+	if err != nil {
+		return "", err
+	}
+
+	if count >= max {
+		return quota, nil
+	}
+	return "", nil
+}
+
+// ReservePublish counts the given messages and bytes towards the daily usage of a project, as long as they fit in
+// its daily quotas. The check and the count happen atomically, so concurrent publishes can't go over the quotas.
+// It returns the name of the first quota that they don't fit in, in which case nothing gets counted
+func ReservePublish(ctx context.Context, projectUUID string, messages int64, bytes int64, now time.Time, store stores.Store) (string, error) {
+	limits, err := FindLimits(ctx, projectUUID, store)
+	if err != nil {
+		return "", err
+	}
+
+	reserved, err := store.ReserveDailyProjectUsage(ctx, projectUUID, Day(now), messages, bytes, limits.DailyMessages, limits.DailyBytes)
+	if err != nil {
+		return "", err
+	}
+	if reserved {
+		return "", nil
+	}
+
+	qUsage, err := store.QueryDailyProjectUsage(ctx, projectUUID, Day(now))
+	if err != nil {
+		return "", err
+	}
+
+	if limits.DailyMessages > 0 && qUsage.Messages+messages > limits.DailyMessages {
+		return DailyMessages, nil
+	}
+	return DailyBytes, nil
+}
+
+// ReleasePublish gives back the messages and bytes of a reservation that did not get published
+func ReleasePublish(ctx context.Context, projectUUID string, messages int64, bytes int64, now time.Time, store stores.Store) error {
+	if messages == 0 && bytes == 0 {
+		return nil
+	}
+	return store.IncrementDailyProjectUsage(ctx, projectUUID, Day(now), -messages, -bytes)
+}
+
+// RecordPublish adds published messages and bytes to the daily usage of a project, without checking its daily quotas
+func RecordPublish(ctx context.Context, projectUUID string, messages int64, bytes int64, now time.Time, store stores.Store) error {
+	return store.IncrementDailyProjectUsage(ctx, projectUUID, Day(now), messages, bytes)
+}
+
+// countResources returns the number of topics, subscriptions or users of a project
+func countResources(ctx context.Context, projectUUID string, quota string, store stores.Store) (int64, error) {
+	switch quota {
+	case MaxTopics:
+		topics, _, _, err := store.QueryTopics(ctx, projectUUID, "", "", "", 0)
+		return int64(len(topics)), err
+	case MaxSubscriptions:
+		subs, _, _, err := store.QuerySubs(ctx, projectUUID, "", "", "", 0)
+		return int64(len(subs)), err
+	case MaxUsers:
+		users, _, _, err := store.PaginatedQueryUsers(ctx, "", 0, projectUUID)
+		return int64(len(users)), err
+	}
+	return 0, errors.New("invalid quota")
+}
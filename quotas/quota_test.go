@@ -0,0 +1,131 @@
+package quotas
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/stretchr/testify/suite"
+)
+
+type QuotaTestSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+func (suite *QuotaTestSuite) TestLimitsFromJSON() {
+
+	limits, err := GetLimitsFromJSON([]byte(`{"max_topics": 10, "daily_bytes": 1024}`))
+	suite.Nil(err)
+	suite.Equal(Limits{MaxTopics: 10, DailyBytes: 1024}, limits)
+	suite.Nil(limits.Validate())
+
+	_, err = GetLimitsFromJSON([]byte(`{"max_topics": "10"}`))
+	suite.NotNil(err)
+
+	suite.Equal("negative value for quota max_users", Limits{MaxUsers: -1}.Validate().Error())
+}
+
+func (suite *QuotaTestSuite) TestFind() {
+
+	store := stores.NewMockStore("", "")
+	now := time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC)
+
+	quota, err := Find(suite.ctx, "argo_uuid", now, store)
+	suite.Nil(err)
+	suite.Equal(Limits{}, quota.Limits)
+	suite.Equal(Usage{Topics: 4, Subscriptions: 4, Users: 7}, quota.Usage)
+
+	suite.Nil(Update(suite.ctx, "argo_uuid", Limits{MaxTopics: 5, DailyMessages: 100}, store))
+	suite.Nil(RecordPublish(suite.ctx, "argo_uuid", 3, 300, now, store))
+	// usage of a previous day doesn't count
+	suite.Nil(RecordPublish(suite.ctx, "argo_uuid", 1, 100, now.Add(-24*time.Hour), store))
+
+	expJSON := `{
+   "limits": {
+      "max_topics": 5,
+      "max_subscriptions": 0,
+      "max_users": 0,
+      "daily_messages": 100,
+      "daily_bytes": 0
+   },
+   "usage": {
+      "topics": 4,
+      "subscriptions": 4,
+      "users": 7,
+      "daily_messages": 3,
+      "daily_bytes": 300
+   }
+}`
+
+	quota, _ = Find(suite.ctx, "argo_uuid", now, store)
+	outJSON, _ := quota.ExportJSON()
+	suite.Equal(expJSON, outJSON)
+}
+
+func (suite *QuotaTestSuite) TestCheckCreate() {
+
+	store := stores.NewMockStore("", "")
+
+	// no quotas
+	exceeded, err := CheckCreate(suite.ctx, "argo_uuid", MaxTopics, store)
+	suite.Nil(err)
+	suite.Equal("", exceeded)
+
+	Update(suite.ctx, "argo_uuid", Limits{MaxTopics: 4, MaxSubscriptions: 5, MaxUsers: 7}, store)
+
+	exceeded, _ = CheckCreate(suite.ctx, "argo_uuid", MaxTopics, store)
+	suite.Equal(MaxTopics, exceeded)
+	exceeded, _ = CheckCreate(suite.ctx, "argo_uuid", MaxSubscriptions, store)
+	suite.Equal("", exceeded)
+	exceeded, _ = CheckCreate(suite.ctx, "argo_uuid", MaxUsers, store)
+	suite.Equal(MaxUsers, exceeded)
+
+	_, err = CheckCreate(suite.ctx, "argo_uuid", DailyBytes, store)
+	suite.Equal("invalid quota", err.Error())
+}
+
+func (suite *QuotaTestSuite) TestReservePublish() {
+
+	store := stores.NewMockStore("", "")
+	now := time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC)
+
+	Update(suite.ctx, "argo_uuid", Limits{DailyMessages: 10, DailyBytes: 1000}, store)
+	RecordPublish(suite.ctx, "argo_uuid", 6, 400, now, store)
+
+	exceeded, err := ReservePublish(suite.ctx, "argo_uuid", 2, 100, now, store)
+	suite.Nil(err)
+	suite.Equal("", exceeded)
+
+	// reservations that go over a quota are not counted
+	exceeded, _ = ReservePublish(suite.ctx, "argo_uuid", 3, 100, now, store)
+	suite.Equal(DailyMessages, exceeded)
+
+	exceeded, _ = ReservePublish(suite.ctx, "argo_uuid", 1, 501, now, store)
+	suite.Equal(DailyBytes, exceeded)
+
+	quota, _ := Find(suite.ctx, "argo_uuid", now, store)
+	suite.Equal(int64(8), quota.Usage.DailyMessages)
+	suite.Equal(int64(500), quota.Usage.DailyBytes)
+
+	// the part of a reservation that didn't get published is given back
+	suite.Nil(ReleasePublish(suite.ctx, "argo_uuid", 1, 50, now, store))
+	quota, _ = Find(suite.ctx, "argo_uuid", now, store)
+	suite.Equal(int64(7), quota.Usage.DailyMessages)
+	suite.Equal(int64(450), quota.Usage.DailyBytes)
+
+	// the next day starts over
+	exceeded, _ = ReservePublish(suite.ctx, "argo_uuid", 10, 1000, now.Add(9*time.Hour), store)
+	suite.Equal("", exceeded)
+}
+
+func (suite *QuotaTestSuite) TestDay() {
+	suite.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Day(time.Date(2024, 1, 1, 23, 59, 59, 0, time.UTC)))
+}
+
+func TestQuotaTestSuite(t *testing.T) {
+	suite.Run(t, &QuotaTestSuite{
+		ctx: context.Background(),
+	})
+}
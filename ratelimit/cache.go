@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+// CacheTTL is for how long the rate limits of a project or a user are cached, so changes made through
+// other instances take up to that long to be enforced
+const CacheTTL = 10 * time.Second
+
+// cachedLimits is the cached rate limits of a project or a user along with the time they got cached
+type cachedLimits struct {
+	limits   Limits
+	cachedOn time.Time
+}
+
+// limitsCache keeps the rate limits of projects and users, so that enforcing them doesn't need
+// to load them from the store on every request
+type limitsCache struct {
+	mu sync.RWMutex
+	// limits holds the rate limits by scope type and uuid
+	limits map[string]cachedLimits
+}
+
+// cache is nil while caching is disabled
+var cache *limitsCache
+
+// EnableCache starts caching the rate limits of projects and users for CacheTTL
+func EnableCache() {
+	cache = &limitsCache{
+		limits: map[string]cachedLimits{},
+	}
+}
+
+// DisableCache stops caching rate limits and drops the cached ones
+func DisableCache() {
+	cache = nil
+}
+
+func limitsKey(scopeType string, scopeUUID string) string {
+	return fmt.Sprintf("%s/%s", scopeType, scopeUUID)
+}
+
+// findCached returns the rate limits of a project or a user from the cache when caching is enabled,
+// limits that are not cached or have expired are loaded from the store
+func findCached(ctx context.Context, scopeType string, scopeUUID string, store stores.Store) (Limits, error) {
+
+	c := cache
+	if c == nil {
+		return Find(ctx, scopeType, scopeUUID, store)
+	}
+
+	key := limitsKey(scopeType, scopeUUID)
+	now := time.Now().UTC()
+
+	c.mu.RLock()
+	entry, ok := c.limits[key]
+	c.mu.RUnlock()
+
+	if ok && now.Sub(entry.cachedOn) < CacheTTL {
+		return entry.limits, nil
+	}
+
+	limits, err := Find(ctx, scopeType, scopeUUID, store)
+	if err != nil {
+		return limits, err
+	}
+
+	c.mu.Lock()
+	c.limits[key] = cachedLimits{limits: limits, cachedOn: now}
+	c.mu.Unlock()
+
+	return limits, nil
+}
+
+// invalidate drops the cached rate limits of a project or a user
+func invalidate(scopeType string, scopeUUID string) {
+
+	c := cache
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	delete(c.limits, limitsKey(scopeType, scopeUUID))
+	c.mu.Unlock()
+}
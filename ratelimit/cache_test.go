@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/stretchr/testify/suite"
+)
+
+type CacheTestSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+func (suite *CacheTestSuite) TestFindCached() {
+
+	EnableCache()
+	defer DisableCache()
+
+	store := stores.NewMockStore("", "")
+	suite.Nil(Update(suite.ctx, ScopeProject, "argo_uuid", Limits{List: []Limit{{ClassPublish, 10, 0}}}, store))
+
+	buckets, err := Applicable(suite.ctx, "argo_uuid", "", ClassPublish, store)
+	suite.Nil(err)
+	suite.Equal(1, len(buckets))
+
+	// changes made directly in the store are not seen until the cached limits expire
+	store.UpdateRateLimits(suite.ctx, ScopeProject, "argo_uuid", []stores.QRateLimit{})
+	buckets, _ = Applicable(suite.ctx, "argo_uuid", "", ClassPublish, store)
+	suite.Equal(1, len(buckets))
+
+	entry := cache.limits[limitsKey(ScopeProject, "argo_uuid")]
+	entry.cachedOn = entry.cachedOn.Add(-CacheTTL)
+	cache.limits[limitsKey(ScopeProject, "argo_uuid")] = entry
+	buckets, _ = Applicable(suite.ctx, "argo_uuid", "", ClassPublish, store)
+	suite.Equal(0, len(buckets))
+
+	// updating the limits drops the cached ones
+	suite.Nil(Update(suite.ctx, ScopeProject, "argo_uuid", Limits{List: []Limit{{ClassPublish, 0, 1024}}}, store))
+	buckets, _ = Applicable(suite.ctx, "argo_uuid", "", ClassPublish, store)
+	suite.Equal([]Bucket{{Key: "projects:argo_uuid:publish", Limit: Limit{ClassPublish, 0, 1024}}}, buckets)
+}
+
+func TestCacheTestSuite(t *testing.T) {
+	suite.Run(t, &CacheTestSuite{
+		ctx: context.Background(),
+	})
+}
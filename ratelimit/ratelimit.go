@@ -0,0 +1,236 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+// The classes of routes that rate limits can be set for
+const (
+	ClassPublish = "publish"
+	ClassPull    = "pull"
+	ClassAdmin   = "admin"
+)
+
+// The kinds of owners that rate limits can be set for
+const (
+	ScopeProject = "projects"
+	ScopeUser    = "users"
+)
+
+// Window is the span of time that the requests and bytes of a rate limit are counted in
+const Window = time.Second
+
+// counterRetention is for how long a counter is kept after its window has passed
+const counterRetention = time.Minute
+
+// RouteClasses holds the classes of routes that rate limits can be set for
+var RouteClasses = []string{ClassPublish, ClassPull, ClassAdmin}
+
+// Limit is the limit of the requests and bytes per second of a class of routes, 0 means no limit
+type Limit struct {
+	RouteClass        string `json:"route_class"`
+	RequestsPerSecond int64  `json:"requests_per_second"`
+	BytesPerSecond    int64  `json:"bytes_per_second"`
+}
+
+// Limits holds the rate limits of a project or a user
+type Limits struct {
+	List []Limit `json:"rate_limits"`
+}
+
+// Bucket is a rate limit that a request is subject to, along with the key of the counter it is charged to
+type Bucket struct {
+	Key   string
+	Limit Limit
+}
+
+// ExportJSON exports the rate limits to json format
+func (l *Limits) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(l, "", "   ")
+	return string(output[:]), err
+}
+
+// GetLimitsFromJSON retrieves the rate limits from a json input
+func GetLimitsFromJSON(input []byte) (Limits, error) {
+	limits := Limits{}
+	err := json.Unmarshal(input, &limits)
+	if err != nil {
+		return limits, err
+	}
+	if limits.List == nil {
+		return limits, errors.New("wrong argument")
+	}
+	return limits, nil
+}
+
+// Validate checks that every limit refers to a distinct route class and has no negative values
+func (l Limits) Validate() error {
+	seen := map[string]bool{}
+	for _, limit := range l.List {
+		if !isRouteClass(limit.RouteClass) {
+			return fmt.Errorf("invalid route class %v", limit.RouteClass)
+		}
+		if seen[limit.RouteClass] {
+			return fmt.Errorf("route class %v is limited more than once", limit.RouteClass)
+		}
+		if limit.RequestsPerSecond < 0 || limit.BytesPerSecond < 0 {
+			return fmt.Errorf("negative limit for route class %v", limit.RouteClass)
+		}
+		seen[limit.RouteClass] = true
+	}
+	return nil
+}
+
+// isRouteClass checks whether rate limits can be set for a class of routes
+func isRouteClass(routeClass string) bool {
+	for _, class := range RouteClasses {
+		if class == routeClass {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the rate limits of a project or a user
+func Find(ctx context.Context, scopeType string, scopeUUID string, store stores.Store) (Limits, error) {
+	result := Limits{List: []Limit{}}
+
+	qLimits, err := store.QueryRateLimits(ctx, scopeType, scopeUUID)
+	if err != nil {
+		return result, err
+	}
+
+	for _, qLimit := range qLimits {
+		result.List = append(result.List, Limit{
+			RouteClass:        qLimit.RouteClass,
+			RequestsPerSecond: qLimit.RequestsPerSecond,
+			BytesPerSecond:    qLimit.BytesPerSecond,
+		})
+	}
+
+	return result, nil
+}
+
+// Update replaces the rate limits of a project or a user, no limits remove them altogether
+func Update(ctx context.Context, scopeType string, scopeUUID string, limits Limits, store stores.Store) error {
+	qLimits := []stores.QRateLimit{}
+	for _, limit := range limits.List {
+		qLimits = append(qLimits, stores.QRateLimit{
+			RouteClass:        limit.RouteClass,
+			RequestsPerSecond: limit.RequestsPerSecond,
+			BytesPerSecond:    limit.BytesPerSecond,
+		})
+	}
+	err := store.UpdateRateLimits(ctx, scopeType, scopeUUID, qLimits)
+	invalidate(scopeType, scopeUUID)
+	return err
+}
+
+// Applicable returns the rate limits that a request of a user to a route of the given class is subject to,
+// the ones of the project of the request and the ones of the user. Empty uuids have no limits.
+// The limits are served from the cache when caching is enabled
+func Applicable(ctx context.Context, projectUUID string, userUUID string, routeClass string, store stores.Store) ([]Bucket, error) {
+	buckets := []Bucket{}
+
+	scopes := []struct {
+		scopeType string
+		scopeUUID string
+	}{
+		{ScopeProject, projectUUID},
+		{ScopeUser, userUUID},
+	}
+
+	for _, scope := range scopes {
+		if scope.scopeUUID == "" {
+			continue
+		}
+
+		limits, err := findCached(ctx, scope.scopeType, scope.scopeUUID, store)
+		if err != nil {
+			return buckets, err
+		}
+
+		for _, limit := range limits.List {
+			if limit.RouteClass != routeClass || (limit.RequestsPerSecond == 0 && limit.BytesPerSecond == 0) {
+				continue
+			}
+			buckets = append(buckets, Bucket{
+				Key:   fmt.Sprintf("%v:%v:%v", scope.scopeType, scope.scopeUUID, routeClass),
+				Limit: limit,
+			})
+		}
+	}
+
+	return buckets, nil
+}
+
+// Take charges the buckets of a request with one request and the given bytes, and reports whether the request
+// is within all of its limits. When it isn't, it also returns how long it should wait before it gets retried
+func Take(ctx context.Context, buckets []Bucket, bytes int64, now time.Time, store stores.Store) (bool, time.Duration, error) {
+	window := now.Truncate(Window)
+	allowed := true
+
+	for _, bucket := range buckets {
+		counter, err := store.IncrementRateCounter(ctx, bucket.Key, window, 1, bytes, window.Add(counterRetention))
+		if err != nil {
+			return true, 0, err
+		}
+
+		if exceeds(bucket.Limit.RequestsPerSecond, counter.Requests) || exceeds(bucket.Limit.BytesPerSecond, counter.Bytes) {
+			allowed = false
+		}
+	}
+
+	if allowed {
+		return true, 0, nil
+	}
+
+	return false, window.Add(Window).Sub(now), nil
+}
+
+// Fits reports whether a request of the given bytes fits in the bytes per second of all of its buckets,
+// a request that doesn't would go over its limits in every window and can never be served
+func Fits(buckets []Bucket, bytes int64) bool {
+	for _, bucket := range buckets {
+		if exceeds(bucket.Limit.BytesPerSecond, bytes) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Charge adds bytes to the buckets that limit them, e.g. the size of the response of a request that has been served
+func Charge(ctx context.Context, buckets []Bucket, bytes int64, now time.Time, store stores.Store) error {
+	window := now.Truncate(Window)
+
+	for _, bucket := range buckets {
+		if bucket.Limit.BytesPerSecond == 0 {
+			continue
+		}
+
+		_, err := store.IncrementRateCounter(ctx, bucket.Key, window, 0, bytes, window.Add(counterRetention))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RetryAfter returns the value of the Retry-After header for a wait, in whole seconds
+func RetryAfter(wait time.Duration) int {
+	return int(math.Max(1, math.Ceil(wait.Seconds())))
+}
+
+// exceeds checks whether a count has gone over a limit, 0 means no limit
+func exceeds(limit int64, count int64) bool {
+	return limit > 0 && count > limit
+}
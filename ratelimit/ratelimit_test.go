@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/stretchr/testify/suite"
+)
+
+type RateLimitTestSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+func (suite *RateLimitTestSuite) TestLimitsFromJSON() {
+
+	limits, err := GetLimitsFromJSON([]byte(`{"rate_limits": [{"route_class": "publish", "requests_per_second": 10}]}`))
+	suite.Nil(err)
+	suite.Equal(Limits{List: []Limit{{RouteClass: "publish", RequestsPerSecond: 10}}}, limits)
+	suite.Nil(limits.Validate())
+
+	_, err = GetLimitsFromJSON([]byte(`{}`))
+	suite.Equal("wrong argument", err.Error())
+
+	_, err = GetLimitsFromJSON([]byte(`{"rate_limits": "publish"}`))
+	suite.NotNil(err)
+
+	tests := []struct {
+		limits Limits
+		err    string
+	}{
+		{Limits{List: []Limit{{RouteClass: "push"}}}, "invalid route class push"},
+		{Limits{List: []Limit{{RouteClass: "pull"}, {RouteClass: "pull"}}}, "route class pull is limited more than once"},
+		{Limits{List: []Limit{{RouteClass: "admin", BytesPerSecond: -1}}}, "negative limit for route class admin"},
+	}
+
+	for _, t := range tests {
+		suite.Equal(t.err, t.limits.Validate().Error())
+	}
+}
+
+func (suite *RateLimitTestSuite) TestManageLimits() {
+
+	store := stores.NewMockStore("", "")
+
+	limits, err := Find(suite.ctx, ScopeProject, "argo_uuid", store)
+	suite.Nil(err)
+	suite.Equal(Limits{List: []Limit{}}, limits)
+
+	expJSON := `{
+   "rate_limits": [
+      {
+         "route_class": "publish",
+         "requests_per_second": 10,
+         "bytes_per_second": 1024
+      }
+   ]
+}`
+
+	suite.Nil(Update(suite.ctx, ScopeProject, "argo_uuid", Limits{List: []Limit{{ClassPublish, 10, 1024}}}, store))
+	limits, _ = Find(suite.ctx, ScopeProject, "argo_uuid", store)
+	outJSON, _ := limits.ExportJSON()
+	suite.Equal(expJSON, outJSON)
+
+	suite.Nil(Update(suite.ctx, ScopeProject, "argo_uuid", Limits{List: []Limit{}}, store))
+	suite.Equal(0, len(store.RateLimits))
+}
+
+func (suite *RateLimitTestSuite) TestApplicable() {
+
+	store := stores.NewMockStore("", "")
+	Update(suite.ctx, ScopeProject, "argo_uuid", Limits{List: []Limit{{ClassPublish, 10, 0}, {ClassPull, 5, 0}}}, store)
+	Update(suite.ctx, ScopeUser, "uuid1", Limits{List: []Limit{{ClassPublish, 0, 1024}, {ClassAdmin, 0, 0}}}, store)
+
+	buckets, err := Applicable(suite.ctx, "argo_uuid", "uuid1", ClassPublish, store)
+	suite.Nil(err)
+	suite.Equal([]Bucket{
+		{Key: "projects:argo_uuid:publish", Limit: Limit{ClassPublish, 10, 0}},
+		{Key: "users:uuid1:publish", Limit: Limit{ClassPublish, 0, 1024}},
+	}, buckets)
+
+	// limits without any values don't apply
+	buckets, _ = Applicable(suite.ctx, "argo_uuid", "uuid1", ClassAdmin, store)
+	suite.Equal([]Bucket{}, buckets)
+
+	// requests without a project or a user are only subject to the limits they have
+	buckets, _ = Applicable(suite.ctx, "", "uuid1", ClassPull, store)
+	suite.Equal([]Bucket{}, buckets)
+	buckets, _ = Applicable(suite.ctx, "argo_uuid", "", ClassPull, store)
+	suite.Equal(1, len(buckets))
+}
+
+func (suite *RateLimitTestSuite) TestTake() {
+
+	store := stores.NewMockStore("", "")
+	buckets := []Bucket{
+		{Key: "projects:argo_uuid:publish", Limit: Limit{ClassPublish, 2, 0}},
+		{Key: "users:uuid1:publish", Limit: Limit{ClassPublish, 0, 1000}},
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 250000000, time.UTC)
+
+	allowed, _, err := Take(suite.ctx, buckets, 400, now, store)
+	suite.True(allowed)
+	suite.Nil(err)
+
+	// the response of the request counts towards the bytes of the limits that limit bytes
+	suite.Nil(Charge(suite.ctx, buckets, 500, now, store))
+	suite.Equal(int64(400), store.RateCounters[0].Bytes)
+	suite.Equal(int64(900), store.RateCounters[1].Bytes)
+
+	// going over the bytes per second
+	allowed, wait, _ := Take(suite.ctx, buckets, 200, now, store)
+	suite.False(allowed)
+	suite.Equal(750*time.Millisecond, wait)
+
+	// going over the requests per second
+	allowed, _, _ = Take(suite.ctx, buckets[:1], 0, now, store)
+	suite.False(allowed)
+
+	// the next window starts over
+	allowed, _, _ = Take(suite.ctx, buckets, 200, now.Add(time.Second), store)
+	suite.True(allowed)
+}
+
+func (suite *RateLimitTestSuite) TestFits() {
+
+	buckets := []Bucket{
+		{Key: "projects:argo_uuid:publish", Limit: Limit{ClassPublish, 2, 0}},
+		{Key: "users:uuid1:publish", Limit: Limit{ClassPublish, 0, 1000}},
+	}
+
+	suite.True(Fits(buckets, 1000))
+	suite.False(Fits(buckets, 1001))
+	// limits without bytes per second fit any request
+	suite.True(Fits(buckets[:1], 1001))
+}
+
+func (suite *RateLimitTestSuite) TestRetryAfter() {
+	suite.Equal(1, RetryAfter(0))
+	suite.Equal(1, RetryAfter(750*time.Millisecond))
+	suite.Equal(2, RetryAfter(1500*time.Millisecond))
+}
+
+func TestRateLimitTestSuite(t *testing.T) {
+	suite.Run(t, &RateLimitTestSuite{
+		ctx: context.Background(),
+	})
+}
@@ -2,14 +2,17 @@ package main
 
 import (
 	"net/http"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
 	"github.com/ARGOeu/argo-messaging/handlers"
 	oldPush "github.com/ARGOeu/argo-messaging/push"
 	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
+	"github.com/ARGOeu/argo-messaging/ratelimit"
 	"github.com/ARGOeu/argo-messaging/stores"
 	gorillaContext "github.com/gorilla/context"
 	"github.com/gorilla/mux"
@@ -40,6 +43,17 @@ func NewRouting(cfg *config.APICfg, brk brokers.Broker, str stores.Store, mgr *o
 
 	tokenExtractStrategy := handlers.GetRequestTokenExtractStrategy(cfg.AuthOption())
 
+	// nil when OIDC bearer token authentication is disabled
+	oidcAuth := auth.NewOIDCAuthenticator(cfg)
+
+	// the routes that roles can grant access to
+	authorizedRoutes := []string{}
+	for _, route := range ar.Routes {
+		if requiresAuthorization(route.Name) && authorizedAs(route.Name) == route.Name {
+			authorizedRoutes = append(authorizedRoutes, route.Name)
+		}
+	}
+
 	// For each route
 	for _, route := range ar.Routes {
 
@@ -49,15 +63,31 @@ func NewRouting(cfg *config.APICfg, brk brokers.Broker, str stores.Store, mgr *o
 
 		handler = handlers.WrapLog(handler, route.Name)
 
+		// administrative actions are recorded once the request is authorized
+		if isAudited(route) {
+			handler = handlers.WrapAudit(handler, route.Name)
+		}
+
+		// topic and subscription actions are also authorized by the acl of the resource
+		if resourceType, action := resourceAction(route.Name); resourceType != "" && requiresAuthorization(route.Name) {
+			handler = handlers.WrapResourceAuthorize(handler, resourceType, action)
+		}
+
+		// schema registry clients can only provide their token through basic authentication
+		routeTokenExtractStrategy := tokenExtractStrategy
+		if strings.HasPrefix(route.Name, "registry:") {
+			routeTokenExtractStrategy = handlers.BasicAuthKeyExtract(tokenExtractStrategy)
+		}
+
 		// skip authentication/authorization for the health status and profile api calls
-		if route.Name != "ams:healthStatus" &&
-			"users:profile" != route.Name &&
-			route.Name != "version:list" &&
-			route.Name != "users:usageReport" {
-			handler = handlers.WrapAuthorize(handler, route.Name, tokenExtractStrategy)
-			handler = handlers.WrapAuthenticate(handler, tokenExtractStrategy)
+		if requiresAuthorization(route.Name) {
+			handler = handlers.WrapAuthorize(handler, authorizedAs(route.Name), routeTokenExtractStrategy)
+			handler = handlers.WrapRateLimit(handler, rateLimitClass(route.Name))
+			handler = handlers.WrapAuthenticate(handler, routeTokenExtractStrategy, oidcAuth)
 		}
 
+		handler = handlers.WrapRoutes(handler, authorizedRoutes)
+
 		handler = handlers.WrapValidate(handler)
 		handler = handlers.WrapConfig(handler, cfg, brk, str, mgr, c)
 
@@ -92,10 +122,24 @@ var defaultRoutes = []APIRoute{
 	{"users:usageReport", "GET", "/users/usageReport", handlers.UserUsageReport},
 	{"users:show", "GET", "/users/{user}", handlers.UserListOne},
 	{"users:refreshToken", "POST", "/users/{user}:refreshToken", handlers.RefreshToken},
+	{"users:modifyDN", "POST", "/users/{user}:modifyDN", handlers.UserModDN},
+	{"users:modifyOIDCSubject", "POST", "/users/{user}:modifyOIDCSubject", handlers.UserModOIDCSubject},
+	{"users:permissions", "GET", "/users/{user}:permissions", handlers.UserPermissions},
+	{"users:showRateLimits", "GET", "/users/{user}/rateLimits", handlers.UserRateLimits},
+	{"users:updateRateLimits", "PUT", "/users/{user}/rateLimits", handlers.UserUpdateRateLimits},
+	{"users:listTokens", "GET", "/users/{user}/tokens", handlers.UserListTokens},
+	{"users:createToken", "POST", "/users/{user}/tokens/{token}", handlers.UserCreateToken},
+	{"users:deleteToken", "DELETE", "/users/{user}/tokens/{token}", handlers.UserDeleteToken},
 	{"users:create", "POST", "/users/{user}", handlers.UserCreate},
 	{"users:update", "PUT", "/users/{user}", handlers.UserUpdate},
 	{"users:delete", "DELETE", "/users/{user}", handlers.UserDelete},
+	{"roles:list", "GET", "/roles", handlers.RoleListAll},
+	{"roles:show", "GET", "/roles/{role}", handlers.RoleListOne},
+	{"roles:create", "POST", "/roles/{role}", handlers.RoleCreate},
+	{"roles:update", "PUT", "/roles/{role}", handlers.RoleUpdate},
+	{"roles:delete", "DELETE", "/roles/{role}", handlers.RoleDelete},
 	{"registrations:newUser", "POST", "/registrations", handlers.RegisterUser},
+	{"registrations:confirmEmail", "GET", "/registrations:confirmEmail", handlers.ConfirmRegistrationEmail},
 	{"registrations:acceptNewUser", "POST", "/registrations/{uuid}:accept", handlers.AcceptRegisterUser},
 	{"registrations:declineNewUser", "POST", "/registrations/{uuid}:decline", handlers.DeclineRegisterUser},
 	{"registrations:delete", "DELETE", "/registrations/{uuid}", handlers.DeleteRegistration},
@@ -103,12 +147,23 @@ var defaultRoutes = []APIRoute{
 	{"registrations:list", "GET", "/registrations", handlers.ListAllRegistrations},
 	{"projects:list", "GET", "/projects", handlers.ProjectListAll},
 	{"projects:metrics", "GET", "/projects/{project}:metrics", handlers.ProjectMetrics},
+	{"projects:quota", "GET", "/projects/{project}:quota", handlers.ProjectQuota},
+	{"projects:updateQuota", "PUT", "/projects/{project}:quota", handlers.ProjectUpdateQuota},
 	{"projects:addUser", "POST", "/projects/{project}/members/{user}:add", handlers.ProjectUserAdd},
 	{"projects:removeUser", "POST", "/projects/{project}/members/{user}:remove", handlers.ProjectUserRemove},
 	{"projects:showUser", "GET", "/projects/{project}/members/{user}", handlers.ProjectUserListOne},
 	{"projects:createUser", "POST", "/projects/{project}/members/{user}", handlers.ProjectUserCreate},
 	{"projects:updateUser", "PUT", "/projects/{project}/members/{user}", handlers.ProjectUserUpdate},
 	{"projects:listUsers", "GET", "/projects/{project}/members", handlers.ProjectListUsers},
+	{"groups:addMembers", "POST", "/projects/{project}/groups/{group}:addMembers", handlers.GroupAddMembers},
+	{"groups:removeMembers", "POST", "/projects/{project}/groups/{group}:removeMembers", handlers.GroupRemoveMembers},
+	{"groups:show", "GET", "/projects/{project}/groups/{group}", handlers.GroupListOne},
+	{"groups:create", "POST", "/projects/{project}/groups/{group}", handlers.GroupCreate},
+	{"groups:delete", "DELETE", "/projects/{project}/groups/{group}", handlers.GroupDelete},
+	{"groups:list", "GET", "/projects/{project}/groups", handlers.GroupListAll},
+	{"projects:showRateLimits", "GET", "/projects/{project}/rateLimits", handlers.ProjectRateLimits},
+	{"projects:updateRateLimits", "PUT", "/projects/{project}/rateLimits", handlers.ProjectUpdateRateLimits},
+	{"projects:audit", "GET", "/projects/{project}/audit", handlers.AuditListByProject},
 	{"projects:show", "GET", "/projects/{project}", handlers.ProjectListOne},
 	{"projects:create", "POST", "/projects/{project}", handlers.ProjectCreate},
 	{"projects:update", "PUT", "/projects/{project}", handlers.ProjectUpdate},
@@ -126,9 +181,11 @@ var defaultRoutes = []APIRoute{
 	{"subscriptions:acknowledge", "POST", "/projects/{project}/subscriptions/{subscription}:acknowledge", handlers.SubAck},
 	{"subscriptions:verifyPushEndpoint", "POST", "/projects/{project}/subscriptions/{subscription}:verifyPushEndpoint", handlers.SubVerifyPushEndpoint},
 	{"subscriptions:modifyAckDeadline", "POST", "/projects/{project}/subscriptions/{subscription}:modifyAckDeadline", handlers.SubModAck},
+	{"subscriptions:modifyOutputFormat", "POST", "/projects/{project}/subscriptions/{subscription}:modifyOutputFormat", handlers.SubModOutputFormat},
 	{"subscriptions:modifyPushConfig", "POST", "/projects/{project}/subscriptions/{subscription}:modifyPushConfig", handlers.SubModPush},
 	{"subscriptions:modifyOffset", "POST", "/projects/{project}/subscriptions/{subscription}:modifyOffset", handlers.SubSetOffset},
 	{"subscriptions:modifyAcl", "POST", "/projects/{project}/subscriptions/{subscription}:modifyAcl", handlers.SubModACL},
+	{"topics:reconcile", "POST", "/topics:reconcile", handlers.TopicsReconcile},
 	{"topics:list", "GET", "/projects/{project}/topics", handlers.TopicListAll},
 	{"topics:acl", "GET", "/projects/{project}/topics/{topic}:acl", handlers.TopicACL},
 	{"topics:metrics", "GET", "/projects/{project}/topics/{topic}:metrics", handlers.TopicMetrics},
@@ -136,14 +193,107 @@ var defaultRoutes = []APIRoute{
 	{"topics:create", "PUT", "/projects/{project}/topics/{topic}", handlers.TopicCreate},
 	{"topics:delete", "DELETE", "/projects/{project}/topics/{topic}", handlers.TopicDelete},
 	{"topics:publish", "POST", "/projects/{project}/topics/{topic}:publish", handlers.TopicPublish},
+	{"topics:publishRaw", "POST", "/projects/{project}/topics/{topic}:publishRaw", handlers.TopicPublishRaw},
 	{"topics:modifyAcl", "POST", "/projects/{project}/topics/{topic}:modifyAcl", handlers.TopicModACL},
 	{"topics:attachSchema", "POST", "/projects/{project}/topics/{topic}:attachSchema", handlers.TopicAttachSchema},
 	{"topics:detachSchema", "POST", "/projects/{project}/topics/{topic}:detachSchema", handlers.TopicDetachSchema},
+	{"topics:modifyMessageTTL", "POST", "/projects/{project}/topics/{topic}:modifyMessageTTL", handlers.TopicModMessageTTL},
+	{"topics:modifyQuarantineTopic", "POST", "/projects/{project}/topics/{topic}:modifyQuarantineTopic", handlers.TopicModQuarantineTopic},
+	{"topics:modifyCompression", "POST", "/projects/{project}/topics/{topic}:modifyCompression", handlers.TopicModCompression},
 	{"schemas:validateMessage", "POST", "/projects/{project}/schemas/{schema}:validate", handlers.SchemaValidateMessage},
+	{"schemas:validateMessages", "POST", "/projects/{project}/schemas/{schema}:validateMessages", handlers.SchemaValidateMessages},
+	{"schemas:rollback", "POST", "/projects/{project}/schemas/{schema}:rollback", handlers.SchemaRollback},
+	{"schemas:listRevisions", "GET", "/projects/{project}/schemas/{schema}/revisions", handlers.SchemaListRevisions},
+	{"schemas:showRevision", "GET", "/projects/{project}/schemas/{schema}/revisions/{revision}", handlers.SchemaListOneRevision},
 	{"schemas:create", "POST", "/projects/{project}/schemas/{schema}", handlers.SchemaCreate},
 	{"schemas:show", "GET", "/projects/{project}/schemas/{schema}", handlers.SchemaListOne},
 	{"schemas:list", "GET", "/projects/{project}/schemas", handlers.SchemaListAll},
 	{"schemas:update", "PUT", "/projects/{project}/schemas/{schema}", handlers.SchemaUpdate},
 	{"schemas:delete", "DELETE", "/projects/{project}/schemas/{schema}", handlers.SchemaDelete},
+	{"registry:listSubjects", "GET", "/projects/{project}/registry/subjects", handlers.RegistryListSubjects},
+	{"registry:listVersions", "GET", "/projects/{project}/registry/subjects/{subject}/versions", handlers.RegistryListVersions},
+	{"registry:showVersion", "GET", "/projects/{project}/registry/subjects/{subject}/versions/{version}", handlers.RegistryShowVersion},
+	{"registry:showVersionSchema", "GET", "/projects/{project}/registry/subjects/{subject}/versions/{version}/schema", handlers.RegistryShowVersionSchema},
+	{"registry:register", "POST", "/projects/{project}/registry/subjects/{subject}/versions", handlers.RegistryRegister},
+	{"registry:lookup", "POST", "/projects/{project}/registry/subjects/{subject}", handlers.RegistryLookup},
+	{"registry:deleteSubject", "DELETE", "/projects/{project}/registry/subjects/{subject}", handlers.RegistryDeleteSubject},
+	{"registry:showSchema", "GET", "/projects/{project}/registry/schemas/ids/{id}", handlers.RegistryShowSchema},
+	{"registry:listTypes", "GET", "/projects/{project}/registry/schemas/types", handlers.RegistryListTypes},
+	{"registry:showConfig", "GET", "/projects/{project}/registry/config", handlers.RegistryShowConfig},
+	{"registry:showSubjectConfig", "GET", "/projects/{project}/registry/config/{subject}", handlers.RegistryShowSubjectConfig},
+	{"registry:updateSubjectConfig", "PUT", "/projects/{project}/registry/config/{subject}", handlers.RegistryUpdateSubjectConfig},
+	{"registry:checkCompatibility", "POST", "/projects/{project}/registry/compatibility/subjects/{subject}/versions/{version}", handlers.RegistryCheckCompatibility},
+	{"audit:list", "GET", "/audit", handlers.AuditListAll},
+	{"notifications:listTemplates", "GET", "/notifications/templates", handlers.NotificationTemplatesListAll},
+	{"notifications:showTemplate", "GET", "/notifications/templates/{template}", handlers.NotificationTemplateListOne},
+	{"notifications:updateTemplate", "PUT", "/notifications/templates/{template}", handlers.NotificationTemplateUpdate},
+	{"notifications:resetTemplate", "DELETE", "/notifications/templates/{template}", handlers.NotificationTemplateReset},
 	{"version:list", "GET", "/version", handlers.ListVersion},
 }
+
+// routeAuthorizations maps the routes that share the roles of another route to the route whose roles they use
+var routeAuthorizations = map[string]string{
+	"topics:publishRaw": "topics:publish",
+}
+
+// requiresAuthorization checks whether a route is only served to users with a role that grants access to it
+func requiresAuthorization(routeName string) bool {
+	return routeName != "ams:healthStatus" &&
+		routeName != "users:profile" &&
+		routeName != "version:list" &&
+		routeName != "users:usageReport" &&
+		routeName != "registrations:confirmEmail"
+}
+
+// resourceAction returns the resource type and the action of a route that acts upon a topic or subscription
+// that acls control access to, or empty values when the route doesn't
+func resourceAction(routeName string) (string, string) {
+	parts := strings.SplitN(authorizedAs(routeName), ":", 2)
+	if len(parts) != 2 || !auth.IsResourceAction(parts[0], parts[1]) {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+// unauditedRoutes holds the routes that change the state of the service but are part of the flow of messages,
+// rather than its administration
+var unauditedRoutes = map[string]bool{
+	"topics:publish":              true,
+	"topics:publishRaw":           true,
+	"subscriptions:pull":          true,
+	"subscriptions:acknowledge":   true,
+	"schemas:validateMessage":     true,
+	"schemas:validateMessages":    true,
+	"registry:lookup":             true,
+	"registry:checkCompatibility": true,
+}
+
+// rateLimitClasses maps the routes of the flow of messages to the class of rate limits that they are subject to
+var rateLimitClasses = map[string]string{
+	"topics:publish":            ratelimit.ClassPublish,
+	"topics:publishRaw":         ratelimit.ClassPublish,
+	"subscriptions:pull":        ratelimit.ClassPull,
+	"subscriptions:acknowledge": ratelimit.ClassPull,
+}
+
+// rateLimitClass returns the class of rate limits that a route is subject to, any route outside the flow of messages
+// is an administrative one
+func rateLimitClass(routeName string) string {
+	if class, found := rateLimitClasses[routeName]; found {
+		return class
+	}
+	return ratelimit.ClassAdmin
+}
+
+// isAudited checks whether a route is recorded in the audit log
+func isAudited(route APIRoute) bool {
+	return route.Method != "GET" && requiresAuthorization(route.Name) && !unauditedRoutes[route.Name]
+}
+
+// authorizedAs returns the name of the route whose roles authorize the given route
+func authorizedAs(routeName string) string {
+	if name, found := routeAuthorizations[routeName]; found {
+		return name
+	}
+	return routeName
+}
@@ -0,0 +1,301 @@
+package schemas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ARGOeu/argo-messaging/stores"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// cacheSyncMargin covers small clock differences between the instances, so that no invalidation gets missed
+const cacheSyncMargin = 5 * time.Second
+
+// cachedSchema is a cached schema along with the time it got cached
+type cachedSchema struct {
+	schema   Schema
+	cachedOn time.Time
+}
+
+// schemaCache keeps the schemas that published messages get validated against, along with their compiled definitions,
+// so that publishing doesn't need to load and compile them on every request
+type schemaCache struct {
+	mu sync.RWMutex
+	// schemas holds the schemas by their project uuid and name
+	schemas map[string]cachedSchema
+	// validators holds the compiled definitions by schema uuid and revision
+	validators map[string]*validator
+	// generation changes on every invalidation, so that lookups that raced with one don't get cached
+	generation int64
+	// syncedOn is the time of the last sync with the invalidations of other instances
+	syncedOn time.Time
+}
+
+// cache is nil while caching is disabled
+var cache *schemaCache
+
+// EnableCache starts caching the schemas that published messages get validated against.
+// The cache should be kept in sync with the changes of other instances through ScheduleCacheSync.
+func EnableCache() {
+	cache = &schemaCache{
+		schemas:    map[string]cachedSchema{},
+		validators: map[string]*validator{},
+		syncedOn:   time.Now().UTC(),
+	}
+}
+
+// DisableCache stops caching schemas and drops the cached ones
+func DisableCache() {
+	cache = nil
+}
+
+func schemaKey(projectUUID, schemaName string) string {
+	return fmt.Sprintf("%s/%s", projectUUID, schemaName)
+}
+
+func validatorKey(schemaUUID string, revision int64) string {
+	return fmt.Sprintf("%s/%d", schemaUUID, revision)
+}
+
+// FindCached retrieves a schema by its name, along with its compiled definition, from the cache when caching is enabled.
+// Schemas that are not cached yet are loaded from the store and compiled once.
+func FindCached(ctx context.Context, projectUUID, schemaName string, str stores.Store) (SchemaList, error) {
+
+	c := cache
+	if c == nil {
+		return Find(ctx, projectUUID, "", schemaName, str)
+	}
+
+	key := schemaKey(projectUUID, schemaName)
+
+	c.mu.RLock()
+	entry, ok := c.schemas[key]
+	generation := c.generation
+	c.mu.RUnlock()
+
+	if ok {
+		return SchemaList{Schemas: []Schema{entry.schema}}, nil
+	}
+
+	cachedOn := time.Now().UTC()
+
+	sl, err := Find(ctx, projectUUID, "", schemaName, str)
+	if err != nil || sl.Empty() {
+		return sl, err
+	}
+
+	schema := sl.Schemas[0]
+
+	c.mu.RLock()
+	v, ok := c.validators[validatorKey(schema.UUID, schema.Revision)]
+	c.mu.RUnlock()
+
+	if !ok {
+		v, err = newValidator(schema)
+		if err != nil {
+			// the error surfaces once the schema is used for validation
+			return sl, nil
+		}
+	}
+
+	schema.compiled = v
+
+	c.mu.Lock()
+	if c.generation == generation {
+		c.schemas[key] = cachedSchema{schema: schema, cachedOn: cachedOn}
+		c.validators[validatorKey(schema.UUID, schema.Revision)] = v
+	}
+	c.mu.Unlock()
+
+	return SchemaList{Schemas: []Schema{schema}}, nil
+}
+
+// FindDecoder returns a decoder for the given revision of a schema, or for its current revision when the given one is 0.
+// Past revisions are loaded from the store and compiled once when caching is enabled, they never change
+// so their compiled definitions are cached by schema uuid and revision
+func FindDecoder(ctx context.Context, schema Schema, revision int64, str stores.Store) (*Decoder, error) {
+
+	if revision == 0 || revision == schema.Revision {
+		v, err := schema.validator()
+		if err != nil {
+			return nil, err
+		}
+		return v.decoder(), nil
+	}
+
+	c := cache
+	key := validatorKey(schema.UUID, revision)
+	generation := int64(0)
+
+	if c != nil {
+		c.mu.RLock()
+		v, ok := c.validators[key]
+		generation = c.generation
+		c.mu.RUnlock()
+
+		if ok {
+			return v.decoder(), nil
+		}
+	}
+
+	revisionList, err := FindRevisions(ctx, schema, revision, str)
+	if err != nil {
+		return nil, err
+	}
+
+	if revisionList.Empty() {
+		return nil, errors.New("not found")
+	}
+
+	rev := revisionList.Revisions[0]
+
+	deps, err := loadDependencies(ctx, schema.ProjectUUID, rev.Type, toQReferences(rev.References), str, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	past := schema
+	past.Type = rev.Type
+	past.RawSchema = rev.RawSchema
+	past.References = rev.References
+	past.Revision = rev.Revision
+	past.dependencies = deps
+	past.compiled = nil
+
+	v, err := newValidator(past)
+	if err != nil {
+		return nil, err
+	}
+
+	if c != nil {
+		c.mu.Lock()
+		if c.generation == generation {
+			c.validators[key] = v
+		}
+		c.mu.Unlock()
+	}
+
+	return v.decoder(), nil
+}
+
+// invalidate drops what got cached about the given schema before the given time.
+// Compiled definitions are kept only for the revisions that remain cached.
+func (c *schemaCache) invalidate(schemaUUID string, before time.Time) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	revisions := map[int64]bool{}
+
+	for key, entry := range c.schemas {
+		if entry.schema.UUID != schemaUUID {
+			continue
+		}
+		if entry.cachedOn.Before(before) {
+			delete(c.schemas, key)
+			continue
+		}
+		revisions[entry.schema.Revision] = true
+	}
+
+	for key, v := range c.validators {
+		if v.schema.UUID == schemaUUID && !revisions[v.schema.Revision] {
+			delete(c.validators, key)
+		}
+	}
+
+	c.generation++
+}
+
+// flush drops everything cached
+func (c *schemaCache) flush() {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.schemas = map[string]cachedSchema{}
+	c.validators = map[string]*validator{}
+	c.generation++
+}
+
+// invalidateSchema drops the cached copies of a schema that has changed, on this instance and,
+// through the invalidation that gets recorded in the store, on every other instance
+func invalidateSchema(ctx context.Context, projectUUID, schemaUUID string, str stores.Store) {
+
+	now := time.Now().UTC()
+
+	if c := cache; c != nil {
+		c.invalidate(schemaUUID, now)
+	}
+
+	err := str.InsertSchemaInvalidation(ctx, projectUUID, schemaUUID, now)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"trace_id":    ctx.Value("trace_id"),
+				"type":        "service_log",
+				"schema_uuid": schemaUUID,
+				"error":       err.Error(),
+			},
+		).Error("Could not record the schema invalidation")
+	}
+}
+
+// SyncCache drops the cached schemas that other instances have changed since the last sync.
+// When the changes can't be retrieved the whole cache is dropped.
+func SyncCache(ctx context.Context, str stores.Store) error {
+
+	c := cache
+	if c == nil {
+		return nil
+	}
+
+	c.mu.RLock()
+	since := c.syncedOn.Add(-cacheSyncMargin)
+	c.mu.RUnlock()
+
+	now := time.Now().UTC()
+
+	invalidations, err := str.QuerySchemaInvalidations(ctx, since)
+	if err != nil {
+		c.flush()
+		return err
+	}
+
+	// schemas that got cached after an invalidation already reflect the change
+	for _, inv := range invalidations {
+		c.invalidate(inv.SchemaUUID, inv.InvalidatedOn.Add(cacheSyncMargin))
+	}
+
+	c.mu.Lock()
+	c.syncedOn = now
+	c.mu.Unlock()
+
+	return nil
+}
+
+// ScheduleCacheSync keeps the schema cache in sync with the changes of other instances until the context is cancelled
+func ScheduleCacheSync(ctx context.Context, interval time.Duration, str stores.Store) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := SyncCache(ctx, str)
+			if err != nil {
+				log.WithFields(
+					log.Fields{
+						"type":  "service_log",
+						"error": err.Error(),
+					},
+				).Error("Could not sync the schema cache, the cached schemas have been dropped")
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package schemas
+
+import (
+	"encoding/base64"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"time"
+)
+
+func (suite *SchemasTestSuite) TestCache() {
+
+	store := stores.NewMockStore("", "")
+
+	// without the cache every lookup goes to the store
+	sl, err := FindCached(suite.ctx, "argo_uuid", "schema-1", store)
+	suite.Nil(err)
+	suite.Nil(sl.Schemas[0].compiled)
+
+	EnableCache()
+	defer DisableCache()
+
+	sl, err = FindCached(suite.ctx, "argo_uuid", "schema-1", store)
+	suite.Nil(err)
+	suite.NotNil(sl.Schemas[0].compiled)
+	suite.Equal("projects/ARGO/schemas/schema-1", sl.Schemas[0].FullName)
+	suite.Equal(1, len(cache.schemas))
+	suite.Equal(1, len(cache.validators))
+
+	// cached schemas are served without the store
+	store.SchemaList[0].Name = "schema-1-changed"
+	cached, err := FindCached(suite.ctx, "argo_uuid", "schema-1", store)
+	suite.Nil(err)
+	suite.Equal(sl.Schemas[0].compiled, cached.Schemas[0].compiled)
+	store.SchemaList[0].Name = "schema-1"
+
+	// unknown schemas are not cached
+	sl, err = FindCached(suite.ctx, "argo_uuid", "unknown", store)
+	suite.Nil(err)
+	suite.True(sl.Empty())
+	suite.Equal(1, len(cache.schemas))
+
+	// updating a schema drops it from the cache and records the change for the other instances
+	_, err = Update(suite.ctx, cached.Schemas[0], "", "", "", map[string]interface{}{"type": "string"}, nil, store)
+	suite.Nil(err)
+	suite.Equal(0, len(cache.schemas))
+	suite.Equal(0, len(cache.validators))
+	suite.Equal("schema_uuid_1", store.SchemaInvalidations[0].SchemaUUID)
+
+	sl, _ = FindCached(suite.ctx, "argo_uuid", "schema-1", store)
+	suite.Equal(int64(2), sl.Schemas[0].Revision)
+	suite.Equal(map[string]interface{}{"type": "string"}, sl.Schemas[0].compiled.schema.RawSchema)
+
+	// changes of other instances are picked up on the next sync
+	// the update of schema-1 happened well before, outside of the margin that covers clock differences
+	store.SchemaInvalidations[0].InvalidatedOn = time.Now().UTC().Add(-time.Minute)
+	_, _ = FindCached(suite.ctx, "argo_uuid", "schema-3", store)
+	suite.Equal(2, len(cache.schemas))
+	suite.Nil(store.InsertSchemaInvalidation(suite.ctx, "argo_uuid", "schema_uuid_3", time.Now().UTC()))
+	suite.Nil(SyncCache(suite.ctx, store))
+	suite.Equal(1, len(cache.schemas))
+	_, ok := cache.schemas[schemaKey("argo_uuid", "schema-1")]
+	suite.True(ok)
+
+	// deleting a schema drops it from the cache
+	suite.Nil(Delete(suite.ctx, "argo_uuid", "schema_uuid_1", store))
+	suite.Equal(0, len(cache.schemas))
+	sl, _ = FindCached(suite.ctx, "argo_uuid", "schema-1", store)
+	suite.True(sl.Empty())
+}
+
+func (suite *SchemasTestSuite) TestFindDecoder() {
+
+	store := stores.NewMockStore("", "")
+	// the schemas of the mock store were created before revisions existed
+	MigrateRevisions(suite.ctx, store)
+
+	EnableCache()
+	defer DisableCache()
+
+	sl, _ := FindCached(suite.ctx, "argo_uuid", "schema-3", store)
+
+	// the second revision swaps the fields, payloads of the first one can't be read with it
+	v2 := map[string]interface{}{
+		"namespace": "user.avro",
+		"type":      "record",
+		"name":      "User",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "phone", "type": "int"},
+			map[string]interface{}{"name": "username", "type": "string"},
+		},
+	}
+	updated, err := Update(suite.ctx, sl.Schemas[0], "", "", "", v2, nil, store)
+	suite.Nil(err)
+	suite.Equal(int64(2), updated.Revision)
+
+	sl, _ = FindCached(suite.ctx, "argo_uuid", "schema-3", store)
+
+	// username: agelos, phone: 89890 written with the first revision
+	payload, _ := base64.StdEncoding.DecodeString("DGFnZWxvc8T8Cg==")
+
+	d, err := FindDecoder(suite.ctx, sl.Schemas[0], 1, store)
+	suite.Nil(err)
+	suite.Equal(int64(1), d.Revision())
+	decoded, err := d.Decode(payload)
+	suite.Nil(err)
+	suite.Equal(`{"phone":89890,"username":"agelos"}`, string(decoded))
+
+	// past revisions are compiled once
+	suite.NotNil(cache.validators[validatorKey("schema_uuid_3", 1)])
+
+	d, err = FindDecoder(suite.ctx, sl.Schemas[0], 0, store)
+	suite.Nil(err)
+	suite.Equal(int64(2), d.Revision())
+
+	_, err = FindDecoder(suite.ctx, sl.Schemas[0], 7, store)
+	suite.Equal("not found", err.Error())
+}
@@ -0,0 +1,678 @@
+package schemas
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"reflect"
+	"strings"
+)
+
+const (
+	// BACKWARD means that consumers using the new revision can read data produced with the previous one
+	BACKWARD = "BACKWARD"
+	// FORWARD means that consumers using the previous revision can read data produced with the new one
+	FORWARD = "FORWARD"
+	// FULL means that both BACKWARD and FORWARD compatibility are required
+	FULL = "FULL"
+	// NONE disables compatibility checking
+	NONE = "NONE"
+
+	UnsupportedCompatibilityError = "Compatibility mode can only be one of BACKWARD, FORWARD, FULL or NONE"
+)
+
+// ParseCompatibility normalizes and validates a compatibility mode, an empty mode is returned as is
+func ParseCompatibility(mode string) (string, error) {
+
+	mode = strings.ToUpper(mode)
+
+	switch mode {
+	case "", BACKWARD, FORWARD, FULL, NONE:
+		return mode, nil
+	}
+
+	return "", errors.New("unsupported compatibility")
+}
+
+// CheckCompatibility checks that the next revision of a schema is compatible with the previous one
+// under the given compatibility mode
+func CheckCompatibility(mode string, previous Schema, next Schema) error {
+
+	if mode == NONE || mode == "" {
+		return nil
+	}
+
+	if previous.Type != next.Type {
+		return fmt.Errorf("Schema type cannot change under the %s compatibility mode", mode)
+	}
+
+	if mode == BACKWARD || mode == FULL {
+		err := checkReadable(next, previous)
+		if err != nil {
+			return fmt.Errorf("Schema is not backward compatible with revision %v, %s", previous.Revision, err.Error())
+		}
+	}
+
+	if mode == FORWARD || mode == FULL {
+		err := checkReadable(previous, next)
+		if err != nil {
+			return fmt.Errorf("Schema is not forward compatible with revision %v, %s", previous.Revision, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// checkReadable checks that data produced with the writer schema can be read using the reader schema.
+// Avro and protobuf schemas are compared along with the types they use from their references,
+// json schemas are compared without following their references.
+func checkReadable(reader, writer Schema) error {
+
+	switch reader.Type {
+	case JSON:
+		// every document that is valid against the writer schema should also be valid against the reader schema
+		return jsonAccepts(reader.RawSchema, writer.RawSchema, "$")
+	case AVRO:
+		c := avroChecker{
+			readerNames: map[string]map[string]interface{}{},
+			writerNames: map[string]map[string]interface{}{},
+			visited:     map[string]bool{},
+		}
+		readerSchema := expandAvroSchema(reader.RawSchema, reader.dependencies)
+		writerSchema := expandAvroSchema(writer.RawSchema, writer.dependencies)
+		collectAvroNames(readerSchema, "", c.readerNames)
+		collectAvroNames(writerSchema, "", c.writerNames)
+		return c.canRead(readerSchema, writerSchema, "$")
+	case PROTOBUF:
+		readerMd, err := compileProtobuf(reader.RawSchema, reader.dependencies)
+		if err != nil {
+			return err
+		}
+		writerMd, err := compileProtobuf(writer.RawSchema, writer.dependencies)
+		if err != nil {
+			return err
+		}
+		c := protoChecker{visited: map[string]bool{}}
+		return c.canRead(readerMd, writerMd, "$")
+	}
+
+	return errors.New("unsupported")
+}
+
+// ##### AVRO #####
+
+var avroPrimitives = map[string]bool{
+	"null":    true,
+	"boolean": true,
+	"int":     true,
+	"long":    true,
+	"float":   true,
+	"double":  true,
+	"bytes":   true,
+	"string":  true,
+}
+
+var avroComplex = map[string]bool{
+	"record": true,
+	"enum":   true,
+	"array":  true,
+	"map":    true,
+	"fixed":  true,
+}
+
+// avroPromotions holds, for each reader type, the writer types it can be promoted from
+var avroPromotions = map[string][]string{
+	"long":   {"int"},
+	"float":  {"int", "long"},
+	"double": {"int", "long", "float"},
+	"string": {"bytes"},
+	"bytes":  {"string"},
+}
+
+// avroChecker applies the avro schema resolution rules between a reader and a writer schema
+type avroChecker struct {
+	readerNames map[string]map[string]interface{}
+	writerNames map[string]map[string]interface{}
+	// visited keeps track of the named types already being compared, so that recursive types terminate
+	visited map[string]bool
+}
+
+// collectAvroNames indexes the named types of a schema both by their name and their full name
+func collectAvroNames(schema interface{}, namespace string, names map[string]map[string]interface{}) {
+
+	switch s := schema.(type) {
+	case []interface{}:
+		for _, branch := range s {
+			collectAvroNames(branch, namespace, names)
+		}
+	case map[string]interface{}:
+		t, _ := s["type"].(string)
+		if !avroComplex[t] {
+			if _, ok := s["type"].(string); !ok {
+				collectAvroNames(s["type"], namespace, names)
+			}
+			return
+		}
+
+		if name, ok := s["name"].(string); ok {
+			if ns, ok := s["namespace"].(string); ok {
+				namespace = ns
+			}
+			fullName := name
+			if !strings.Contains(name, ".") && namespace != "" {
+				fullName = namespace + "." + name
+			}
+			names[name] = s
+			names[fullName] = s
+		}
+
+		switch t {
+		case "record":
+			fields, _ := s["fields"].([]interface{})
+			for _, f := range fields {
+				if field, ok := f.(map[string]interface{}); ok {
+					collectAvroNames(field["type"], namespace, names)
+				}
+			}
+		case "array":
+			collectAvroNames(s["items"], namespace, names)
+		case "map":
+			collectAvroNames(s["values"], namespace, names)
+		}
+	}
+}
+
+// resolve returns the kind of the given schema along with its definition, named references are replaced
+// by the type they refer to
+func (c *avroChecker) resolve(schema interface{}, names map[string]map[string]interface{}) (string, interface{}) {
+
+	switch s := schema.(type) {
+	case []interface{}:
+		return "union", s
+	case string:
+		if avroPrimitives[s] {
+			return s, s
+		}
+		if def, ok := names[s]; ok {
+			t, _ := def["type"].(string)
+			return t, def
+		}
+		return s, s
+	case map[string]interface{}:
+		if t, ok := s["type"].(string); ok {
+			if avroComplex[t] || avroPrimitives[t] {
+				return t, s
+			}
+		}
+		return c.resolve(s["type"], names)
+	}
+
+	return "", schema
+}
+
+func (c *avroChecker) canRead(reader, writer interface{}, path string) error {
+
+	rt, r := c.resolve(reader, c.readerNames)
+	wt, w := c.resolve(writer, c.writerNames)
+
+	// every branch of a writer union should be readable
+	if wt == "union" {
+		for _, branch := range w.([]interface{}) {
+			err := c.canRead(reader, branch, path)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// at least one branch of a reader union should be able to read the writer's type
+	if rt == "union" {
+		for _, branch := range r.([]interface{}) {
+			if c.canRead(branch, writer, path) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: type %s is not part of the union", path, wt)
+	}
+
+	if rt != wt {
+		for _, promoted := range avroPromotions[rt] {
+			if promoted == wt {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: type %s cannot be read as %s", path, wt, rt)
+	}
+
+	if avroPrimitives[rt] {
+		return nil
+	}
+
+	rm, _ := r.(map[string]interface{})
+	wm, _ := w.(map[string]interface{})
+
+	switch rt {
+	case "record":
+		rName, _ := rm["name"].(string)
+		wName, _ := wm["name"].(string)
+
+		if rName != wName && !hasAlias(rm, wName) {
+			return fmt.Errorf("%s: record %s cannot be read as %s", path, wName, rName)
+		}
+
+		key := rName + "/" + wName
+		if c.visited[key] {
+			return nil
+		}
+		c.visited[key] = true
+
+		writerFields := map[string]map[string]interface{}{}
+		wFields, _ := wm["fields"].([]interface{})
+		for _, f := range wFields {
+			if field, ok := f.(map[string]interface{}); ok {
+				name, _ := field["name"].(string)
+				writerFields[name] = field
+			}
+		}
+
+		rFields, _ := rm["fields"].([]interface{})
+		for _, f := range rFields {
+			field, ok := f.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := field["name"].(string)
+
+			writerField, found := writerFields[name]
+			if !found {
+				aliases, _ := field["aliases"].([]interface{})
+				for _, alias := range aliases {
+					if a, ok := alias.(string); ok {
+						if writerField, found = writerFields[a]; found {
+							break
+						}
+					}
+				}
+			}
+
+			if !found {
+				if _, hasDefault := field["default"]; !hasDefault {
+					return fmt.Errorf("%s: field '%s' is missing and has no default value", path, name)
+				}
+				continue
+			}
+
+			err := c.canRead(field["type"], writerField["type"], path+"."+name)
+			if err != nil {
+				return err
+			}
+		}
+
+	case "enum":
+		if _, hasDefault := rm["default"]; hasDefault {
+			return nil
+		}
+		rSymbols, _ := rm["symbols"].([]interface{})
+		wSymbols, _ := wm["symbols"].([]interface{})
+		for _, symbol := range wSymbols {
+			if !containsValue(rSymbols, symbol) {
+				return fmt.Errorf("%s: enum symbol '%v' is missing", path, symbol)
+			}
+		}
+
+	case "array":
+		return c.canRead(rm["items"], wm["items"], path+"[]")
+
+	case "map":
+		return c.canRead(rm["values"], wm["values"], path+"{}")
+
+	case "fixed":
+		if !reflect.DeepEqual(rm["size"], wm["size"]) {
+			return fmt.Errorf("%s: fixed size %v cannot be read as %v", path, wm["size"], rm["size"])
+		}
+	}
+
+	return nil
+}
+
+func hasAlias(schema map[string]interface{}, name string) bool {
+	aliases, _ := schema["aliases"].([]interface{})
+	return containsValue(aliases, name)
+}
+
+// ##### JSON SCHEMA #####
+
+// jsonAnnotations are keywords that have no effect on validation
+var jsonAnnotations = map[string]bool{
+	"$schema":     true,
+	"$id":         true,
+	"id":          true,
+	"$comment":    true,
+	"title":       true,
+	"description": true,
+	"default":     true,
+	"examples":    true,
+	"readOnly":    true,
+	"writeOnly":   true,
+}
+
+// jsonUpperBounds are keywords whose value can only be lowered by a more restrictive schema
+var jsonUpperBounds = []string{"maximum", "exclusiveMaximum", "maxLength", "maxItems", "maxProperties"}
+
+// jsonLowerBounds are keywords whose value can only be raised by a more restrictive schema
+var jsonLowerBounds = []string{"minimum", "exclusiveMinimum", "minLength", "minItems", "minProperties"}
+
+// jsonHandled are the keywords that jsonAccepts compares structurally, the rest need to stay the same
+var jsonHandled = map[string]bool{
+	"type":                 true,
+	"enum":                 true,
+	"const":                true,
+	"required":             true,
+	"properties":           true,
+	"additionalProperties": true,
+	"items":                true,
+}
+
+// jsonAccepts checks, conservatively, that every document valid against the narrow schema
+// is also valid against the wide one
+func jsonAccepts(wide, narrow interface{}, path string) error {
+
+	if b, ok := wide.(bool); ok {
+		if b {
+			return nil
+		}
+		if nb, ok := narrow.(bool); ok && !nb {
+			return nil
+		}
+		return fmt.Errorf("%s: no value is allowed", path)
+	}
+
+	w, _ := wide.(map[string]interface{})
+	n, _ := narrow.(map[string]interface{})
+	if n == nil {
+		// a narrow schema of false doesn't allow anything
+		if nb, ok := narrow.(bool); ok && !nb {
+			return nil
+		}
+		n = map[string]interface{}{}
+	}
+
+	// type
+	if wTypes := jsonTypes(w); wTypes != nil {
+		nTypes := jsonTypes(n)
+		if nTypes == nil {
+			return fmt.Errorf("%s: type should be one of %v", path, wTypes)
+		}
+		for _, t := range nTypes {
+			if !containsString(wTypes, t) && !(t == "integer" && containsString(wTypes, "number")) {
+				return fmt.Errorf("%s: type '%s' is not allowed", path, t)
+			}
+		}
+	}
+
+	// enum and const
+	if wEnum, ok := jsonEnum(w); ok {
+		nEnum, ok := jsonEnum(n)
+		if !ok {
+			return fmt.Errorf("%s: value should be one of %v", path, wEnum)
+		}
+		for _, v := range nEnum {
+			if !containsValue(wEnum, v) {
+				return fmt.Errorf("%s: value '%v' is not allowed", path, v)
+			}
+		}
+	}
+
+	// required properties
+	wRequired, _ := w["required"].([]interface{})
+	nRequired, _ := n["required"].([]interface{})
+	for _, r := range wRequired {
+		if !containsValue(nRequired, r) {
+			return fmt.Errorf("%s: property '%v' is required", path, r)
+		}
+	}
+
+	// properties
+	wProps, _ := w["properties"].(map[string]interface{})
+	nProps, _ := n["properties"].(map[string]interface{})
+	nAdditional, hasNAdditional := n["additionalProperties"]
+	if !hasNAdditional {
+		nAdditional = true
+	}
+
+	for name, wp := range wProps {
+		np, found := nProps[name]
+		if !found {
+			np = nAdditional
+		}
+		err := jsonAccepts(wp, np, path+"."+name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if wAdditional, ok := w["additionalProperties"]; ok {
+		for name, np := range nProps {
+			if _, found := wProps[name]; found {
+				continue
+			}
+			err := jsonAccepts(wAdditional, np, path+"."+name)
+			if err != nil {
+				return err
+			}
+		}
+		err := jsonAccepts(wAdditional, nAdditional, path+".*")
+		if err != nil {
+			return err
+		}
+	}
+
+	// items
+	if wItems, ok := w["items"]; ok {
+		nItems, ok := n["items"]
+		if !ok {
+			nItems = true
+		}
+		err := jsonAccepts(wItems, nItems, path+"[]")
+		if err != nil {
+			return err
+		}
+	}
+
+	// bounds
+	for _, k := range jsonUpperBounds {
+		if wv, ok := w[k].(float64); ok {
+			nv, ok := n[k].(float64)
+			if !ok || nv > wv {
+				return fmt.Errorf("%s: %s should be at most %v", path, k, wv)
+			}
+		}
+	}
+
+	for _, k := range jsonLowerBounds {
+		if wv, ok := w[k].(float64); ok {
+			nv, ok := n[k].(float64)
+			if !ok || nv < wv {
+				return fmt.Errorf("%s: %s should be at least %v", path, k, wv)
+			}
+		}
+	}
+
+	// every other validation keyword should stay the same
+	for k, wv := range w {
+		if jsonHandled[k] || jsonAnnotations[k] || containsString(jsonUpperBounds, k) || containsString(jsonLowerBounds, k) {
+			continue
+		}
+		if !reflect.DeepEqual(wv, n[k]) {
+			return fmt.Errorf("%s: %s has changed", path, k)
+		}
+	}
+
+	return nil
+}
+
+// jsonTypes returns the types a json schema allows, or nil if it doesn't restrict them
+func jsonTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := []string{}
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// jsonEnum returns the values a json schema allows through enum or const
+func jsonEnum(schema map[string]interface{}) ([]interface{}, bool) {
+	if c, ok := schema["const"]; ok {
+		return []interface{}{c}, true
+	}
+	e, ok := schema["enum"].([]interface{})
+	return e, ok
+}
+
+// ##### PROTOBUF #####
+
+// protoWireGroups maps each field kind to a group of kinds that share the same wire representation
+// and can be decoded in place of each other
+var protoWireGroups = map[protoreflect.Kind]string{
+	protoreflect.BoolKind:     "varint",
+	protoreflect.EnumKind:     "varint",
+	protoreflect.Int32Kind:    "varint",
+	protoreflect.Int64Kind:    "varint",
+	protoreflect.Uint32Kind:   "varint",
+	protoreflect.Uint64Kind:   "varint",
+	protoreflect.Sint32Kind:   "zigzag",
+	protoreflect.Sint64Kind:   "zigzag",
+	protoreflect.Fixed32Kind:  "fixed32",
+	protoreflect.Sfixed32Kind: "fixed32",
+	protoreflect.FloatKind:    "float",
+	protoreflect.Fixed64Kind:  "fixed64",
+	protoreflect.Sfixed64Kind: "fixed64",
+	protoreflect.DoubleKind:   "double",
+	protoreflect.StringKind:   "bytes",
+	protoreflect.BytesKind:    "bytes",
+	protoreflect.MessageKind:  "message",
+	protoreflect.GroupKind:    "group",
+}
+
+// protoChecker checks that payloads of a writer message type are accepted by the strict validation of a reader one
+type protoChecker struct {
+	// visited keeps track of the message types already being compared, so that recursive types terminate
+	visited map[string]bool
+}
+
+func (c *protoChecker) canRead(reader, writer protoreflect.MessageDescriptor, path string) error {
+
+	key := fmt.Sprintf("%s|%s", reader.FullName(), writer.FullName())
+	if c.visited[key] {
+		return nil
+	}
+	c.visited[key] = true
+
+	writerFields := writer.Fields()
+	readerFields := reader.Fields()
+
+	for i := 0; i < writerFields.Len(); i++ {
+		wf := writerFields.Get(i)
+		fieldPath := fmt.Sprintf("%s.%s", path, wf.Name())
+
+		// unknown fields are rejected during validation
+		rf := readerFields.ByNumber(wf.Number())
+		if rf == nil {
+			return fmt.Errorf("%s: field number %d is unknown to the reader", fieldPath, wf.Number())
+		}
+
+		if err := c.canReadField(rf, wf, fieldPath); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < readerFields.Len(); i++ {
+		rf := readerFields.Get(i)
+		if rf.Cardinality() != protoreflect.Required {
+			continue
+		}
+
+		wf := writerFields.ByNumber(rf.Number())
+		if wf == nil || wf.Cardinality() != protoreflect.Required {
+			return fmt.Errorf("%s: field '%s' is required", path, rf.Name())
+		}
+	}
+
+	return nil
+}
+
+func (c *protoChecker) canReadField(reader, writer protoreflect.FieldDescriptor, path string) error {
+
+	if reader.IsList() != writer.IsList() || reader.IsMap() != writer.IsMap() {
+		return fmt.Errorf("%s: %s field cannot be read as %s", path, protoCardinality(writer), protoCardinality(reader))
+	}
+
+	if protoWireGroups[reader.Kind()] != protoWireGroups[writer.Kind()] {
+		return fmt.Errorf("%s: type %s cannot be read as %s", path, writer.Kind(), reader.Kind())
+	}
+
+	if reader.IsMap() {
+		err := c.canReadField(reader.MapKey(), writer.MapKey(), path+"{key}")
+		if err != nil {
+			return err
+		}
+		return c.canReadField(reader.MapValue(), writer.MapValue(), path+"{value}")
+	}
+
+	switch {
+	case reader.Message() != nil:
+		return c.canRead(reader.Message(), writer.Message(), path)
+	case reader.Enum() != nil && reader.Enum().IsClosed():
+		// values of closed enums that the reader doesn't know end up as unknown fields
+		if writer.Enum() == nil {
+			return fmt.Errorf("%s: type %s cannot be read as a closed enum", path, writer.Kind())
+		}
+		writerValues := writer.Enum().Values()
+		readerValues := reader.Enum().Values()
+		for i := 0; i < writerValues.Len(); i++ {
+			n := writerValues.Get(i).Number()
+			if readerValues.ByNumber(n) == nil {
+				return fmt.Errorf("%s: enum value %d is missing", path, n)
+			}
+		}
+	}
+
+	return nil
+}
+
+func protoCardinality(fd protoreflect.FieldDescriptor) string {
+	switch {
+	case fd.IsMap():
+		return "map"
+	case fd.IsList():
+		return "repeated"
+	}
+	return "singular"
+}
+
+func containsString(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+func containsValue(items []interface{}, item interface{}) bool {
+	for _, i := range items {
+		if reflect.DeepEqual(i, item) {
+			return true
+		}
+	}
+	return false
+}
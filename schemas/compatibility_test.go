@@ -0,0 +1,192 @@
+package schemas
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+func (suite *SchemasTestSuite) TestParseCompatibility() {
+
+	expected := map[string]string{
+		"":         "",
+		"backward": BACKWARD,
+		"Forward":  FORWARD,
+		"FULL":     FULL,
+		"none":     NONE,
+	}
+
+	for mode, exp := range expected {
+		m, err := ParseCompatibility(mode)
+		suite.Nil(err)
+		suite.Equal(exp, m)
+	}
+
+	_, err := ParseCompatibility("transitive")
+	suite.Equal(errors.New("unsupported compatibility"), err)
+}
+
+func (suite *SchemasTestSuite) TestCheckCompatibilityAvro() {
+
+	toMap := func(s string) map[string]interface{} {
+		m := map[string]interface{}{}
+		_ = json.Unmarshal([]byte(s), &m)
+		return m
+	}
+
+	v1 := Schema{Type: AVRO, Revision: 1, RawSchema: toMap(`{"type":"record","name":"User","fields":[
+		{"name":"username","type":"string"},
+		{"name":"phone","type":"int"}]}`)}
+
+	type td struct {
+		mode string
+		next string
+		err  error
+		msg  string
+	}
+
+	testData := []td{
+		{
+			mode: BACKWARD,
+			next: `{"type":"record","name":"User","fields":[
+				{"name":"username","type":"string"},
+				{"name":"phone","type":"long"},
+				{"name":"email","type":["null","string"],"default":null}]}`,
+			err: nil,
+			msg: "Case where a field with a default is added and a type is promoted",
+		},
+		{
+			mode: BACKWARD,
+			next: `{"type":"record","name":"User","fields":[
+				{"name":"username","type":"string"},
+				{"name":"phone","type":"int"},
+				{"name":"email","type":"string"}]}`,
+			err: errors.New("Schema is not backward compatible with revision 1, $: field 'email' is missing and has no default value"),
+			msg: "Case where a field without a default is added under BACKWARD",
+		},
+		{
+			mode: FORWARD,
+			next: `{"type":"record","name":"User","fields":[
+				{"name":"username","type":"string"},
+				{"name":"phone","type":"int"},
+				{"name":"email","type":"string"}]}`,
+			err: nil,
+			msg: "Case where a field without a default is added under FORWARD",
+		},
+		{
+			mode: FORWARD,
+			next: `{"type":"record","name":"User","fields":[{"name":"username","type":"string"}]}`,
+			err:  errors.New("Schema is not forward compatible with revision 1, $: field 'phone' is missing and has no default value"),
+			msg:  "Case where a field without a default is removed under FORWARD",
+		},
+		{
+			mode: FULL,
+			next: `{"type":"record","name":"User","fields":[
+				{"name":"username","type":"string"},
+				{"name":"phone","type":"long"}]}`,
+			err: errors.New("Schema is not forward compatible with revision 1, $.phone: type long cannot be read as int"),
+			msg: "Case where a promotion is only backward compatible under FULL",
+		},
+		{
+			mode: BACKWARD,
+			next: `{"type":"record","name":"User","fields":[
+				{"name":"username","type":"string"},
+				{"name":"phone","type":"string"}]}`,
+			err: errors.New("Schema is not backward compatible with revision 1, $.phone: type int cannot be read as string"),
+			msg: "Case where a field changes to an incompatible type",
+		},
+		{
+			mode: NONE,
+			next: `{"type":"record","name":"Other","fields":[{"name":"id","type":"string"}]}`,
+			err:  nil,
+			msg:  "Case where anything is accepted under NONE",
+		},
+	}
+
+	for _, t := range testData {
+		next := Schema{Type: AVRO, Revision: 2, RawSchema: toMap(t.next)}
+		suite.Equal(t.err, CheckCompatibility(t.mode, v1, next), t.msg)
+	}
+
+	// enums without a default symbol
+	e1 := Schema{Type: AVRO, Revision: 1, RawSchema: toMap(`{"type":"enum","name":"Color","symbols":["RED","GREEN"]}`)}
+	e2 := Schema{Type: AVRO, Revision: 2, RawSchema: toMap(`{"type":"enum","name":"Color","symbols":["RED"]}`)}
+	suite.Nil(CheckCompatibility(FORWARD, e1, e2))
+	suite.Equal(errors.New("Schema is not backward compatible with revision 1, $: enum symbol 'GREEN' is missing"),
+		CheckCompatibility(BACKWARD, e1, e2))
+
+	// the schema type can't change unless compatibility is disabled
+	j := Schema{Type: JSON, Revision: 2, RawSchema: toMap(`{"type":"string"}`)}
+	suite.Equal(errors.New("Schema type cannot change under the BACKWARD compatibility mode"), CheckCompatibility(BACKWARD, v1, j))
+	suite.Nil(CheckCompatibility(NONE, v1, j))
+}
+
+func (suite *SchemasTestSuite) TestCheckCompatibilityJSON() {
+
+	toMap := func(s string) map[string]interface{} {
+		m := map[string]interface{}{}
+		_ = json.Unmarshal([]byte(s), &m)
+		return m
+	}
+
+	v1 := Schema{Type: JSON, Revision: 3, RawSchema: toMap(`{"type":"object","properties":{
+		"name":{"type":"string"},
+		"age":{"type":"integer"}},
+		"required":["name"]}`)}
+
+	type td struct {
+		mode string
+		next string
+		err  error
+		msg  string
+	}
+
+	testData := []td{
+		{
+			mode: BACKWARD,
+			next: `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"number"}},"required":["name"]}`,
+			err:  nil,
+			msg:  "Case where a property type is widened",
+		},
+		{
+			mode: FORWARD,
+			next: `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"number"}},"required":["name"]}`,
+			err:  errors.New("Schema is not forward compatible with revision 3, $.age: type 'number' is not allowed"),
+			msg:  "Case where a property type is widened under FORWARD",
+		},
+		{
+			mode: BACKWARD,
+			next: `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer"}},"required":["name","age"]}`,
+			err:  errors.New("Schema is not backward compatible with revision 3, $: property 'age' is required"),
+			msg:  "Case where a property becomes required",
+		},
+		{
+			mode: FORWARD,
+			next: `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer"}},"required":["name","age"]}`,
+			err:  nil,
+			msg:  "Case where a property becomes required under FORWARD",
+		},
+		{
+			mode: BACKWARD,
+			next: `{"type":"object","properties":{"name":{"type":"string","maxLength":10},"age":{"type":"integer"}},"required":["name"]}`,
+			err:  errors.New("Schema is not backward compatible with revision 3, $.name: maxLength should be at most 10"),
+			msg:  "Case where a constraint is added",
+		},
+		{
+			mode: FULL,
+			next: `{"title":"person","type":"object","properties":{"name":{"type":"string","description":"full name"},"age":{"type":"integer"}},"required":["name"]}`,
+			err:  nil,
+			msg:  "Case where only annotations change under FULL",
+		},
+		{
+			mode: BACKWARD,
+			next: `{"type":"object","properties":{"name":{"type":"string"}},"required":["name"],"additionalProperties":false}`,
+			err:  errors.New("Schema is not backward compatible with revision 3, $.age: no value is allowed"),
+			msg:  "Case where a property is removed and additional properties are forbidden",
+		},
+	}
+
+	for _, t := range testData {
+		next := Schema{Type: JSON, Revision: 4, RawSchema: toMap(t.next)}
+		suite.Equal(t.err, CheckCompatibility(t.mode, v1, next), t.msg)
+	}
+}
@@ -0,0 +1,93 @@
+package schemas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/linkedin/goavro"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Decoder converts the payloads of messages that follow a schema to their JSON representation
+type Decoder struct {
+	schema       Schema
+	avroCodec    *goavro.Codec
+	protoMessage protoreflect.MessageDescriptor
+}
+
+// NewDecoder prepares a decoder for the given schema, compiling its definition once
+func NewDecoder(schema Schema) (*Decoder, error) {
+
+	d := &Decoder{schema: schema}
+
+	switch schema.Type {
+	case JSON:
+	case AVRO:
+		c, err := compileAvroSchema(schema.RawSchema, schema.dependencies)
+		if err != nil {
+			return nil, err
+		}
+		d.avroCodec = c
+	case PROTOBUF:
+		md, err := compileProtobuf(schema.RawSchema, schema.dependencies)
+		if err != nil {
+			return nil, err
+		}
+		d.protoMessage = md
+	default:
+		return nil, errors.New("unsupported")
+	}
+
+	return d, nil
+}
+
+// decoder returns a decoder that shares the compiled definition of the validator
+func (v *validator) decoder() *Decoder {
+	return &Decoder{schema: v.schema, avroCodec: v.avroCodec, protoMessage: v.protoMessage}
+}
+
+// Revision returns the revision of the schema that the decoder uses
+func (d *Decoder) Revision() int64 {
+	return d.schema.Revision
+}
+
+// Decode returns the JSON representation of a payload.
+// Avro payloads use the JSON encoding of the avro specification and protobuf payloads the canonical protobuf JSON mapping.
+func (d *Decoder) Decode(payload []byte) (json.RawMessage, error) {
+
+	switch d.schema.Type {
+	case JSON:
+		if !json.Valid(payload) {
+			return nil, errors.New("payload is not valid JSON")
+		}
+		return payload, nil
+	case AVRO:
+		native, _, err := d.avroCodec.NativeFromBinary(payload)
+		if err != nil {
+			return nil, err
+		}
+		textual, err := d.avroCodec.TextualFromNative(nil, native)
+		if err != nil {
+			return nil, err
+		}
+		// the order of the record fields in the textual encoding varies, sort them to keep the output stable
+		var v interface{}
+		err = json.Unmarshal(textual, &v)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	case PROTOBUF:
+		msg := dynamicpb.NewMessage(d.protoMessage)
+		err := proto.Unmarshal(payload, msg)
+		if err != nil {
+			return nil, err
+		}
+		return protojson.Marshal(msg)
+	}
+
+	return nil, fmt.Errorf("schema type %s cannot be decoded", d.schema.Type)
+}
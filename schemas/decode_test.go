@@ -0,0 +1,74 @@
+package schemas
+
+import (
+	"encoding/base64"
+	"errors"
+)
+
+func (suite *SchemasTestSuite) TestDecoder() {
+
+	avroSchema := Schema{
+		Type:     AVRO,
+		Revision: 2,
+		RawSchema: map[string]interface{}{
+			"namespace": "user.avro",
+			"type":      "record",
+			"name":      "User",
+			"fields": []interface{}{
+				map[string]interface{}{"name": "username", "type": "string"},
+				map[string]interface{}{"name": "phone", "type": "int"},
+			},
+		},
+	}
+
+	protoSchema := Schema{
+		Type:     PROTOBUF,
+		Revision: 1,
+		RawSchema: map[string]interface{}{
+			"message_type": "user.proto.User",
+			"proto":        testProtoSource,
+		},
+	}
+
+	jsonSchema := Schema{
+		Type:      JSON,
+		Revision:  3,
+		RawSchema: map[string]interface{}{"type": "object"},
+	}
+
+	decode := func(schema Schema, data string) (string, error) {
+		d, err := NewDecoder(schema)
+		suite.Nil(err)
+		suite.Equal(schema.Revision, d.Revision())
+		payload, _ := base64.StdEncoding.DecodeString(data)
+		decoded, err := d.Decode(payload)
+		return string(decoded), err
+	}
+
+	// username: agelos, phone: 89890
+	decoded, err := decode(avroSchema, "DGFnZWxvc8T8Cg==")
+	suite.Nil(err)
+	suite.Equal(`{"phone":89890,"username":"agelos"}`, decoded)
+
+	_, err = decode(avroSchema, "YmFzZTY0ZW5jb2RlZA==")
+	suite.NotNil(err)
+
+	// username: joe, phone: 5
+	decoded, err = decode(protoSchema, "CgNqb2UQBQ==")
+	suite.Nil(err)
+	suite.JSONEq(`{"username":"joe","phone":5}`, decoded)
+
+	_, err = decode(protoSchema, "CgVq")
+	suite.NotNil(err)
+
+	// {"name":"name-1"}
+	decoded, err = decode(jsonSchema, "eyJuYW1lIjoibmFtZS0xIn0=")
+	suite.Nil(err)
+	suite.Equal(`{"name":"name-1"}`, decoded)
+
+	_, err = decode(jsonSchema, "YmFzZTY0ZW5jb2RlZA==")
+	suite.Equal(errors.New("payload is not valid JSON"), err)
+
+	_, err = NewDecoder(Schema{Type: "unknown"})
+	suite.Equal(errors.New("unsupported"), err)
+}
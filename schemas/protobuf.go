@@ -0,0 +1,234 @@
+package schemas
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/bufbuild/protocompile"
+	"github.com/bufbuild/protocompile/linker"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"strings"
+)
+
+const (
+	// ProtoMessageTypeField holds the fully qualified name of the message type that payloads should be unmarshalled to
+	ProtoMessageTypeField = "message_type"
+	// ProtoSourceField holds the contents of a .proto file
+	ProtoSourceField = "proto"
+	// ProtoDescriptorSetField holds a base64 encoded serialized FileDescriptorSet
+	ProtoDescriptorSetField = "descriptor_set"
+
+	// protoSourceFilename is the name under which the submitted .proto source gets compiled
+	protoSourceFilename = "schema.proto"
+)
+
+// compileProtobuf compiles the definition of a protobuf schema and returns the descriptor of its message type.
+// The definition should provide the message type and either a .proto source or a serialized FileDescriptorSet.
+// The .proto sources of the dependencies can be imported by the source of the schema under their reference name.
+func compileProtobuf(rawSchema map[string]interface{}, deps []dependency) (protoreflect.MessageDescriptor, error) {
+
+	messageType, ok := rawSchema[ProtoMessageTypeField].(string)
+	if !ok || messageType == "" {
+		return nil, fmt.Errorf("protobuf schema should declare its %s", ProtoMessageTypeField)
+	}
+
+	source, hasSource := rawSchema[ProtoSourceField]
+	descriptorSet, hasDescriptorSet := rawSchema[ProtoDescriptorSetField]
+
+	if hasSource == hasDescriptorSet {
+		return nil, fmt.Errorf("protobuf schema should provide either a %s or a %s", ProtoSourceField, ProtoDescriptorSetField)
+	}
+
+	var d protoreflect.Descriptor
+	var err error
+
+	if hasSource {
+		src, ok := source.(string)
+		if !ok {
+			return nil, fmt.Errorf("protobuf schema %s should be a string", ProtoSourceField)
+		}
+		d, err = findInProtoSource(src, messageType, deps)
+	} else {
+		if len(deps) > 0 {
+			return nil, fmt.Errorf("protobuf schema with a %s can't have references", ProtoDescriptorSetField)
+		}
+		fds, ok := descriptorSet.(string)
+		if !ok {
+			return nil, fmt.Errorf("protobuf schema %s should be a base64 encoded string", ProtoDescriptorSetField)
+		}
+		d, err = findInDescriptorSet(fds, messageType)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message type", messageType)
+	}
+
+	return md, nil
+}
+
+// findInProtoSource compiles the provided .proto source and looks up the given fully qualified name
+func findInProtoSource(source string, name string, deps []dependency) (protoreflect.Descriptor, error) {
+
+	fd, err := compileProtoSource(source, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	d := fd.FindDescriptorByName(protoreflect.FullName(name))
+	if d == nil {
+		return nil, fmt.Errorf("message type %s is not defined", name)
+	}
+
+	return d, nil
+}
+
+// compileProtoSource compiles the provided .proto source.
+// Imports of the well known google/protobuf types and of the dependencies are resolved, any other import is not.
+func compileProtoSource(source string, deps []dependency) (linker.File, error) {
+
+	sources := map[string]string{
+		protoSourceFilename: source,
+	}
+
+	for _, d := range deps {
+		depSource, ok := d.rawSchema[ProtoSourceField].(string)
+		if !ok {
+			return nil, fmt.Errorf("Schema reference %s should point to a protobuf schema with a %s", d.name, ProtoSourceField)
+		}
+		sources[d.name] = depSource
+	}
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(sources),
+		}),
+	}
+
+	files, err := compiler.Compile(context.Background(), protoSourceFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	return files[0], nil
+}
+
+// firstProtoMessage returns the fully qualified name of the first message type declared in the .proto source
+func firstProtoMessage(source string) (string, error) {
+
+	fd, err := compileProtoSource(source, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if fd.Messages().Len() == 0 {
+		return "", errors.New("protobuf schema should declare at least one message type")
+	}
+
+	return string(fd.Messages().Get(0).FullName()), nil
+}
+
+// findInDescriptorSet builds the files of a base64 encoded FileDescriptorSet and looks up the given fully qualified name
+func findInDescriptorSet(descriptorSet string, name string) (protoreflect.Descriptor, error) {
+
+	b, err := base64.StdEncoding.DecodeString(descriptorSet)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf schema %s is not in valid base64 encoding", ProtoDescriptorSetField)
+	}
+
+	fds := &descriptorpb.FileDescriptorSet{}
+	err = proto.Unmarshal(b, fds)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf schema %s is not a valid FileDescriptorSet", ProtoDescriptorSetField)
+	}
+
+	files, err := protodesc.NewFiles(fds)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("message type %s is not defined", name)
+	}
+
+	return d, nil
+}
+
+// unknownFieldsError reports a message, at the given path of the payload, that carries fields which are not part of its definition
+type unknownFieldsError struct {
+	messageType string
+	path        string
+}
+
+func (e unknownFieldsError) Error() string {
+	return fmt.Sprintf("%s%s contains unknown fields", e.messageType, strings.TrimPrefix(e.path, "$"))
+}
+
+// unmarshalProtobufStrict unmarshals the payload to the given message type.
+// Required fields need to be present and unknown fields are rejected at any depth.
+func unmarshalProtobufStrict(md protoreflect.MessageDescriptor, payload []byte) error {
+
+	msg := dynamicpb.NewMessage(md)
+
+	err := proto.Unmarshal(payload, msg)
+	if err != nil {
+		return err
+	}
+
+	path := checkNoUnknownFields(msg, "$")
+	if path != "" {
+		return unknownFieldsError{messageType: string(md.FullName()), path: path}
+	}
+
+	return nil
+}
+
+// checkNoUnknownFields walks the populated fields of a message and returns the path of the first one carrying unknown fields
+func checkNoUnknownFields(msg protoreflect.Message, path string) string {
+
+	if len(msg.GetUnknown()) > 0 {
+		return path
+	}
+
+	found := ""
+
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+
+		fieldPath := fmt.Sprintf("%s.%s", path, fd.Name())
+
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				return true
+			}
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				found = checkNoUnknownFields(mv.Message(), fmt.Sprintf("%s[%v]", fieldPath, k.Interface()))
+				return found == ""
+			})
+		case fd.IsList():
+			if fd.Message() == nil {
+				return true
+			}
+			list := v.List()
+			for i := 0; i < list.Len() && found == ""; i++ {
+				found = checkNoUnknownFields(list.Get(i).Message(), fmt.Sprintf("%s[%d]", fieldPath, i))
+			}
+		case fd.Message() != nil:
+			found = checkNoUnknownFields(v.Message(), fieldPath)
+		}
+
+		return found == ""
+	})
+
+	return found
+}
@@ -0,0 +1,235 @@
+package schemas
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/ARGOeu/argo-messaging/messages"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const testProtoSource = `syntax = "proto3";
+package user.proto;
+
+message Address {
+  string city = 1;
+}
+
+message User {
+  string username = 1;
+  int32 phone = 2;
+  Address address = 3;
+}
+`
+
+func (suite *SchemasTestSuite) TestCompileProtobuf() {
+
+	md, err := compileProtobuf(map[string]interface{}{
+		"message_type": "user.proto.User",
+		"proto":        testProtoSource,
+	}, nil)
+	suite.Nil(err)
+	suite.Equal("user.proto.User", string(md.FullName()))
+	suite.Equal(3, md.Fields().Len())
+
+	// the same definition submitted as a serialized FileDescriptorSet
+	fds := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(md.ParentFile())},
+	}
+	b, _ := proto.Marshal(fds)
+
+	md2, err := compileProtobuf(map[string]interface{}{
+		"message_type":   "user.proto.User",
+		"descriptor_set": base64.StdEncoding.EncodeToString(b),
+	}, nil)
+	suite.Nil(err)
+	suite.Equal(md.FullName(), md2.FullName())
+
+	type td struct {
+		schema map[string]interface{}
+		err    error
+		msg    string
+	}
+
+	testData := []td{
+		{
+			schema: map[string]interface{}{"proto": testProtoSource},
+			err:    errors.New("protobuf schema should declare its message_type"),
+			msg:    "Case where the message type is missing",
+		},
+		{
+			schema: map[string]interface{}{"message_type": "user.proto.User"},
+			err:    errors.New("protobuf schema should provide either a proto or a descriptor_set"),
+			msg:    "Case where neither a source nor a descriptor set is provided",
+		},
+		{
+			schema: map[string]interface{}{"message_type": "user.proto.User", "proto": testProtoSource, "descriptor_set": ""},
+			err:    errors.New("protobuf schema should provide either a proto or a descriptor_set"),
+			msg:    "Case where both a source and a descriptor set are provided",
+		},
+		{
+			schema: map[string]interface{}{"message_type": "user.proto.Unknown", "proto": testProtoSource},
+			err:    errors.New("message type user.proto.Unknown is not defined"),
+			msg:    "Case where the message type is not defined in the source",
+		},
+		{
+			schema: map[string]interface{}{"message_type": "user.proto", "proto": testProtoSource},
+			err:    errors.New("message type user.proto is not defined"),
+			msg:    "Case where the message type is a package",
+		},
+		{
+			schema: map[string]interface{}{"message_type": "user.proto.User", "descriptor_set": "not-base64"},
+			err:    errors.New("protobuf schema descriptor_set is not in valid base64 encoding"),
+			msg:    "Case where the descriptor set is not in base64",
+		},
+		{
+			schema: map[string]interface{}{"message_type": "user.proto.User", "descriptor_set": "CgVq"},
+			err:    errors.New("protobuf schema descriptor_set is not a valid FileDescriptorSet"),
+			msg:    "Case where the descriptor set can't be unmarshalled",
+		},
+	}
+
+	for _, t := range testData {
+		_, err := compileProtobuf(t.schema, nil)
+		suite.Equal(t.err, err, t.msg)
+	}
+
+	// syntax errors in the source are reported
+	_, err = compileProtobuf(map[string]interface{}{
+		"message_type": "user.proto.User",
+		"proto":        "syntax = \"proto3\"; message User { string username = }",
+	}, nil)
+	suite.NotNil(err)
+}
+
+func (suite *SchemasTestSuite) TestValidateMessagesProtobuf() {
+
+	schema := Schema{
+		ProjectUUID: "argo_uuid",
+		UUID:        "schema_uuid_proto",
+		Name:        "schema-proto",
+		Type:        PROTOBUF,
+		RawSchema: map[string]interface{}{
+			"message_type": "user.proto.User",
+			"proto":        testProtoSource,
+		},
+	}
+
+	type td struct {
+		data string
+		err  error
+		msg  string
+	}
+
+	testData := []td{
+		{
+			// username: joe, phone: 5
+			data: "CgNqb2UQBQ==",
+			err:  nil,
+			msg:  "Case where the message is successfully validated",
+		},
+		{
+			// username: joe, address: {city: ath}
+			data: "CgNqb2UaBQoDYXRo",
+			err:  nil,
+			msg:  "Case where the message with a nested message is successfully validated",
+		},
+		{
+			// username: joe, phone: 5, 4: 1
+			data: "CgNqb2UQBSAB",
+			err:  errors.New("Message 0 is not valid.user.proto.User contains unknown fields"),
+			msg:  "Case where the message contains an unknown field",
+		},
+		{
+			// username: joe, address: {2: 1}
+			data: "CgNqb2UaAhAB",
+			err:  errors.New("Message 0 is not valid.user.proto.User.address contains unknown fields"),
+			msg:  "Case where a nested message contains an unknown field",
+		},
+		{
+			data: "not-base64",
+			err:  errors.New("Message 0 is not in valid base64 enocding,illegal base64 data at input byte 3"),
+			msg:  "Case where the message is not in base64",
+		},
+	}
+
+	for _, t := range testData {
+		msgList := messages.MsgList{Msgs: []messages.Message{{Data: t.data}}}
+		suite.Equal(t.err, ValidateMessages(schema, msgList), t.msg)
+	}
+
+	// the wording of the protobuf unmarshalling errors is deliberately unstable
+	err := ValidateMessages(schema, messages.MsgList{Msgs: []messages.Message{{Data: "CgVq"}}})
+	suite.Contains(err.Error(), "Message 0 is not valid.proto:")
+
+	// a schema that can't be compiled results in an internal error
+	schema.RawSchema = map[string]interface{}{"message_type": "user.proto.User"}
+	suite.Equal(errors.New("500"), ValidateMessages(schema, messages.MsgList{Msgs: []messages.Message{{Data: "CgNqb2UQBQ=="}}}))
+}
+
+func (suite *SchemasTestSuite) TestCheckCompatibilityProtobuf() {
+
+	toSchema := func(revision int64, body string) Schema {
+		return Schema{
+			Type:     PROTOBUF,
+			Revision: revision,
+			RawSchema: map[string]interface{}{
+				"message_type": "user.proto.User",
+				"proto":        "syntax = \"proto3\";\npackage user.proto;\n" + body,
+			},
+		}
+	}
+
+	v1 := toSchema(1, `message User { string username = 1; int32 phone = 2; }`)
+
+	type td struct {
+		mode string
+		next string
+		err  error
+		msg  string
+	}
+
+	testData := []td{
+		{
+			mode: BACKWARD,
+			next: `message User { string username = 1; int64 phone = 2; string email = 3; }`,
+			err:  nil,
+			msg:  "Case where a field is added and an integer is widened",
+		},
+		{
+			mode: FORWARD,
+			next: `message User { string username = 1; int32 phone = 2; string email = 3; }`,
+			err:  errors.New("Schema is not forward compatible with revision 1, $.email: field number 3 is unknown to the reader"),
+			msg:  "Case where a field is added under FORWARD",
+		},
+		{
+			mode: BACKWARD,
+			next: `message User { string username = 1; }`,
+			err:  errors.New("Schema is not backward compatible with revision 1, $.phone: field number 2 is unknown to the reader"),
+			msg:  "Case where a field is removed under BACKWARD",
+		},
+		{
+			mode: FULL,
+			next: `message User { bytes login = 1; uint32 phone = 2; }`,
+			err:  nil,
+			msg:  "Case where fields are renamed and types keep their wire format",
+		},
+		{
+			mode: BACKWARD,
+			next: `message User { string username = 1; sint32 phone = 2; }`,
+			err:  errors.New("Schema is not backward compatible with revision 1, $.phone: type int32 cannot be read as sint32"),
+			msg:  "Case where a field changes its wire format",
+		},
+		{
+			mode: BACKWARD,
+			next: `message User { string username = 1; repeated int32 phone = 2; }`,
+			err:  errors.New("Schema is not backward compatible with revision 1, $.phone: singular field cannot be read as repeated"),
+			msg:  "Case where a field becomes repeated",
+		},
+	}
+
+	for _, t := range testData {
+		suite.Equal(t.err, CheckCompatibility(t.mode, v1, toSchema(2, t.next)), t.msg)
+	}
+}
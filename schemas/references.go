@@ -0,0 +1,492 @@
+package schemas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/linkedin/goavro"
+	log "github.com/sirupsen/logrus"
+	"github.com/xeipuuv/gojsonschema"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+const (
+	// maxReferenceDepth limits how deep the references of a schema are followed
+	maxReferenceDepth = 10
+
+	// jsonReferenceBase is the base uri that relative references of json schemas without an $id resolve against
+	jsonReferenceBase = "https://schemas.argo-messaging.local/"
+)
+
+// Reference points to a revision of another schema of the same project that a schema depends on.
+// Name is the way the schema refers to it, the $ref uri for json schemas and the import path for protobuf schemas.
+// Avro schemas use the named types that the referenced schema defines by their full name.
+type Reference struct {
+	Name     string `json:"name"`
+	Schema   string `json:"schema"`
+	Revision int64  `json:"revision"`
+	// schemaUUID is the uuid of the referenced schema
+	schemaUUID string
+}
+
+// dependency holds the content of a schema revision that a schema depends on, either directly or through its references
+type dependency struct {
+	name      string
+	rawSchema map[string]interface{}
+}
+
+// resolveReferences looks up the schemas that the given references point to under the given project and loads their content.
+// References without a revision point to the current revision of their schema.
+func resolveReferences(ctx context.Context, projectUUID, schemaType string, references []Reference, str stores.Store) ([]Reference, []dependency, error) {
+
+	if len(references) == 0 {
+		return nil, nil, nil
+	}
+
+	resolved := []Reference{}
+	names := map[string]bool{}
+
+	for _, ref := range references {
+
+		if ref.Name == "" || ref.Schema == "" {
+			return nil, nil, errors.New("Schema references should declare both a name and a schema")
+		}
+
+		if names[ref.Name] {
+			return nil, nil, fmt.Errorf("Schema reference %s is declared more than once", ref.Name)
+		}
+		names[ref.Name] = true
+
+		if ref.Revision < 0 {
+			return nil, nil, fmt.Errorf("Schema reference %s should point to a positive revision", ref.Name)
+		}
+
+		sl, err := findReferenced(ctx, projectUUID, "", ref.Schema, str)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if sl.Empty() {
+			return nil, nil, fmt.Errorf("Referenced schema %s doesn't exist", ref.Schema)
+		}
+
+		referenced := sl.Schemas[0]
+
+		if ref.Revision == 0 {
+			ref.Revision = referenced.Revision
+		}
+
+		ref.schemaUUID = referenced.UUID
+		resolved = append(resolved, ref)
+	}
+
+	deps, err := loadDependencies(ctx, projectUUID, schemaType, toQReferences(resolved), str, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resolved, deps, nil
+}
+
+// findReferenced retrieves a schema by its uuid or name without following its own references
+func findReferenced(ctx context.Context, projectUUID, schemaUUID, schemaName string, str stores.Store) (SchemaList, error) {
+
+	schemaList := SchemaList{
+		Schemas: []Schema{},
+	}
+
+	qSchemas, err := str.QuerySchemas(ctx, projectUUID, schemaUUID, schemaName)
+	if err != nil {
+		return schemaList, err
+	}
+
+	for _, s := range qSchemas {
+
+		rawSchema, err := decodeRawSchema(s.RawSchema)
+		if err != nil {
+			return SchemaList{}, errors.New("Could not load the schema")
+		}
+
+		revision := s.Revision
+		if revision == 0 {
+			revision = 1
+		}
+
+		schemaList.Schemas = append(schemaList.Schemas, Schema{
+			ProjectUUID: s.ProjectUUID,
+			UUID:        s.UUID,
+			Name:        s.Name,
+			Type:        s.Type,
+			RawSchema:   rawSchema,
+			Revision:    revision,
+		})
+	}
+
+	return schemaList, nil
+}
+
+// loadDependencies loads the content of the referenced schema revisions, along with the revisions they reference in turn.
+// The dependencies of a revision precede it in the returned list.
+func loadDependencies(ctx context.Context, projectUUID, schemaType string, references []stores.QSchemaReference, str stores.Store, depth int) ([]dependency, error) {
+
+	if depth >= maxReferenceDepth {
+		return nil, fmt.Errorf("Schema references can't be nested more than %d levels deep", maxReferenceDepth)
+	}
+
+	deps := []dependency{}
+
+	for _, ref := range references {
+
+		qRevisions, err := str.QuerySchemaRevisions(ctx, projectUUID, ref.SchemaUUID, ref.Revision)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(qRevisions) == 0 {
+			return nil, fmt.Errorf("Schema reference %s points to revision %d which doesn't exist", ref.Name, ref.Revision)
+		}
+
+		revision := qRevisions[0]
+
+		if revision.Type != schemaType {
+			return nil, fmt.Errorf("Schema reference %s should point to a %s schema", ref.Name, schemaType)
+		}
+
+		rawSchema, err := decodeRawSchema(revision.RawSchema)
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":        "service_log",
+					"schema_uuid": ref.SchemaUUID,
+					"revision":    ref.Revision,
+					"error":       err.Error(),
+				},
+			).Error("Could not decode the referenced schema revision")
+			return nil, errors.New("Could not load the schema")
+		}
+
+		nested, err := loadDependencies(ctx, projectUUID, schemaType, revision.References, str, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		deps = append(deps, nested...)
+		deps = append(deps, dependency{name: ref.Name, rawSchema: rawSchema})
+	}
+
+	// the same dependency can be reached through more than one path
+	unique := []dependency{}
+	seen := map[string]map[string]interface{}{}
+
+	for _, d := range deps {
+		if rawSchema, ok := seen[d.name]; ok {
+			if !reflect.DeepEqual(rawSchema, d.rawSchema) {
+				return nil, fmt.Errorf("Schema reference %s points to different schemas", d.name)
+			}
+			continue
+		}
+		seen[d.name] = d.rawSchema
+		unique = append(unique, d)
+	}
+
+	return unique, nil
+}
+
+// loadReferences converts stored references to their api representation, using the current name of the referenced schemas
+func loadReferences(ctx context.Context, projectUUID string, references []stores.QSchemaReference, str stores.Store) ([]Reference, error) {
+
+	if len(references) == 0 {
+		return nil, nil
+	}
+
+	refs := []Reference{}
+
+	for _, ref := range references {
+
+		qSchemas, err := str.QuerySchemas(ctx, projectUUID, ref.SchemaUUID, "")
+		if err != nil {
+			return nil, err
+		}
+
+		schemaName := ""
+		if len(qSchemas) > 0 {
+			schemaName = qSchemas[0].Name
+		}
+
+		refs = append(refs, Reference{
+			Name:       ref.Name,
+			Schema:     schemaName,
+			Revision:   ref.Revision,
+			schemaUUID: ref.SchemaUUID,
+		})
+	}
+
+	return refs, nil
+}
+
+// toQReferences converts references to their stored representation
+func toQReferences(references []Reference) []stores.QSchemaReference {
+
+	if len(references) == 0 {
+		return nil
+	}
+
+	qRefs := []stores.QSchemaReference{}
+
+	for _, ref := range references {
+		qRefs = append(qRefs, stores.QSchemaReference{
+			Name:       ref.Name,
+			SchemaUUID: ref.schemaUUID,
+			Revision:   ref.Revision,
+		})
+	}
+
+	return qRefs
+}
+
+// sameReferences returns weather or not both lists point to the same schema revisions under the same names
+func sameReferences(a, b []Reference) bool {
+	return reflect.DeepEqual(toQReferences(a), toQReferences(b))
+}
+
+// ##### JSON #####
+
+// compileJSONSchema compiles a json schema along with the schemas it references.
+// Relative references resolve against the $id of the schema, or against a common base when it has none.
+func compileJSONSchema(rawSchema map[string]interface{}, deps []dependency) (*gojsonschema.Schema, error) {
+
+	sl := gojsonschema.NewSchemaLoader()
+
+	if len(deps) == 0 {
+		return sl.Compile(gojsonschema.NewGoLoader(rawSchema))
+	}
+
+	idKey := "$id"
+	if s, ok := rawSchema["$schema"].(string); ok && strings.Contains(s, "draft-04") {
+		idKey = "id"
+	}
+
+	base := jsonReferenceBase + "schema.json"
+	if id, ok := rawSchema[idKey].(string); ok && id != "" {
+		base = id
+	} else {
+		// use a copy with an $id, so that relative references can be resolved
+		withID := map[string]interface{}{}
+		for k, v := range rawSchema {
+			withID[k] = v
+		}
+		withID[idKey] = base
+		rawSchema = withID
+	}
+
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range deps {
+		ref, err := url.Parse(d.name)
+		if err != nil {
+			return nil, fmt.Errorf("Schema reference %s is not a valid uri", d.name)
+		}
+
+		err = sl.AddSchema(baseURL.ResolveReference(ref).String(), gojsonschema.NewGoLoader(d.rawSchema))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sl.Compile(gojsonschema.NewGoLoader(rawSchema))
+}
+
+// ##### AVRO #####
+
+// expandAvroSchema returns the avro schema with the named types that it uses from its references defined in place.
+// Avro schemas can only use named types that are defined earlier in the same document, so each referenced type
+// gets defined where it is first used.
+func expandAvroSchema(rawSchema map[string]interface{}, deps []dependency) interface{} {
+
+	if len(deps) == 0 {
+		return rawSchema
+	}
+
+	e := avroExpander{
+		available: map[string]map[string]interface{}{},
+		defined:   map[string]bool{},
+	}
+
+	for _, d := range deps {
+		collectAvroDefinitions(d.rawSchema, "", e.available)
+	}
+
+	return e.expand(rawSchema, "", false)
+}
+
+// avroFullName returns the full name of a named type, given the namespace it is declared in
+func avroFullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// avroNamespace returns the namespace that a full name belongs to
+func avroNamespace(fullName string) string {
+	if idx := strings.LastIndex(fullName, "."); idx >= 0 {
+		return fullName[:idx]
+	}
+	return ""
+}
+
+// collectAvroDefinitions indexes the named types of a schema by their full name.
+// The indexed definitions carry their full name, so that they keep their meaning wherever they get defined.
+func collectAvroDefinitions(schema interface{}, namespace string, definitions map[string]map[string]interface{}) {
+
+	switch s := schema.(type) {
+	case []interface{}:
+		for _, branch := range s {
+			collectAvroDefinitions(branch, namespace, definitions)
+		}
+	case map[string]interface{}:
+		t, _ := s["type"].(string)
+		if !avroComplex[t] {
+			if _, ok := s["type"].(string); !ok {
+				collectAvroDefinitions(s["type"], namespace, definitions)
+			}
+			return
+		}
+
+		if name, ok := s["name"].(string); ok {
+			if ns, ok := s["namespace"].(string); ok {
+				namespace = ns
+			}
+			fullName := avroFullName(name, namespace)
+			namespace = avroNamespace(fullName)
+
+			def := map[string]interface{}{}
+			for k, v := range s {
+				def[k] = v
+			}
+			def["name"] = fullName
+			delete(def, "namespace")
+			definitions[fullName] = def
+		}
+
+		switch t {
+		case "record":
+			fields, _ := s["fields"].([]interface{})
+			for _, f := range fields {
+				if field, ok := f.(map[string]interface{}); ok {
+					collectAvroDefinitions(field["type"], namespace, definitions)
+				}
+			}
+		case "array":
+			collectAvroDefinitions(s["items"], namespace, definitions)
+		case "map":
+			collectAvroDefinitions(s["values"], namespace, definitions)
+		}
+	}
+}
+
+// avroExpander defines the referenced named types of an avro schema at their first use
+type avroExpander struct {
+	// available holds the named types of the references by their full name
+	available map[string]map[string]interface{}
+	// defined keeps track of the named types that are already defined in the expanded schema
+	defined map[string]bool
+}
+
+// expand returns a copy of the schema with the referenced named types defined in place.
+// Definitions that come from references are replaced by their name when the type is already defined.
+func (e *avroExpander) expand(schema interface{}, namespace string, referenced bool) interface{} {
+
+	switch s := schema.(type) {
+	case string:
+		if avroPrimitives[s] {
+			return s
+		}
+		fullName := avroFullName(s, namespace)
+		if e.defined[fullName] {
+			return s
+		}
+		if def, ok := e.available[fullName]; ok {
+			return e.expand(def, namespace, true)
+		}
+		return s
+
+	case []interface{}:
+		branches := make([]interface{}, len(s))
+		for i, branch := range s {
+			branches[i] = e.expand(branch, namespace, referenced)
+		}
+		return branches
+
+	case map[string]interface{}:
+		expanded := map[string]interface{}{}
+		for k, v := range s {
+			expanded[k] = v
+		}
+
+		t, _ := s["type"].(string)
+
+		if name, ok := s["name"].(string); ok && avroComplex[t] {
+			ns := namespace
+			if declared, ok := s["namespace"].(string); ok {
+				ns = declared
+			}
+			fullName := avroFullName(name, ns)
+			if referenced && e.defined[fullName] {
+				return fullName
+			}
+			e.defined[fullName] = true
+			namespace = avroNamespace(fullName)
+		}
+
+		switch t {
+		case "record":
+			fields, _ := s["fields"].([]interface{})
+			expandedFields := make([]interface{}, len(fields))
+			for i, f := range fields {
+				field, ok := f.(map[string]interface{})
+				if !ok {
+					expandedFields[i] = f
+					continue
+				}
+				expandedField := map[string]interface{}{}
+				for k, v := range field {
+					expandedField[k] = v
+				}
+				expandedField["type"] = e.expand(field["type"], namespace, referenced)
+				expandedFields[i] = expandedField
+			}
+			expanded["fields"] = expandedFields
+		case "array":
+			expanded["items"] = e.expand(s["items"], namespace, referenced)
+		case "map":
+			expanded["values"] = e.expand(s["values"], namespace, referenced)
+		case "enum", "fixed":
+		default:
+			expanded["type"] = e.expand(s["type"], namespace, referenced)
+		}
+
+		return expanded
+	}
+
+	return schema
+}
+
+// compileAvroSchema builds the codec of an avro schema, defining the named types that it uses from its references in place
+func compileAvroSchema(rawSchema map[string]interface{}, deps []dependency) (*goavro.Codec, error) {
+
+	// convert the schema to a json string representation
+	b, err := json.Marshal(expandAvroSchema(rawSchema, deps))
+	if err != nil {
+		return nil, err
+	}
+
+	return goavro.NewCodec(string(b))
+}
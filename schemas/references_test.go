@@ -0,0 +1,238 @@
+package schemas
+
+import (
+	"errors"
+	"github.com/ARGOeu/argo-messaging/messages"
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+func (suite *SchemasTestSuite) TestJSONReferences() {
+
+	store := stores.NewMockStore("", "")
+
+	address := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"street": map[string]interface{}{"type": "string"},
+		},
+		"required": []interface{}{"street"},
+	}
+
+	_, err := Create(suite.ctx, "argo_uuid", "address_uuid", "address", JSON, "", address, nil, store)
+	suite.Nil(err)
+
+	person := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name":    map[string]interface{}{"type": "string"},
+			"address": map[string]interface{}{"$ref": "address.json"},
+		},
+	}
+
+	// the reference points to the current revision of the schema
+	s, err := Create(suite.ctx, "argo_uuid", "person_uuid", "person", JSON, "", person,
+		[]Reference{{Name: "address.json", Schema: "address"}}, store)
+	suite.Nil(err)
+	suite.Equal([]Reference{{Name: "address.json", Schema: "address", Revision: 1, schemaUUID: "address_uuid"}}, s.References)
+
+	sl, err := Find(suite.ctx, "argo_uuid", "", "person", store)
+	suite.Nil(err)
+	suite.Equal(s.References, sl.Schemas[0].References)
+
+	report, err := ValidateMessagesReport(sl.Schemas[0], messages.MsgList{Msgs: []messages.Message{
+		// {"name":"joe","address":{"street":"Main"}}
+		{Data: "eyJuYW1lIjoiam9lIiwiYWRkcmVzcyI6eyJzdHJlZXQiOiJNYWluIn19"},
+		// {"name":"joe","address":{}}
+		{Data: "eyJuYW1lIjoiam9lIiwiYWRkcmVzcyI6e319"},
+	}})
+	suite.Nil(err)
+	suite.True(report.Messages[0].Valid)
+	suite.Equal([]ValidationError{{Path: "$.address.street", Message: "street is required"}}, report.Messages[1].Errors)
+
+	// a new revision of the referenced schema doesn't affect the schemas that reference an older one
+	asl, _ := Find(suite.ctx, "argo_uuid", "", "address", store)
+	_, err = Update(suite.ctx, asl.Schemas[0], "", "", "", map[string]interface{}{"type": "object"}, nil, store)
+	suite.Nil(err)
+
+	sl, _ = Find(suite.ctx, "argo_uuid", "", "person", store)
+	report, _ = ValidateMessagesReport(sl.Schemas[0], messages.MsgList{Msgs: []messages.Message{
+		{Data: "eyJuYW1lIjoiam9lIiwiYWRkcmVzcyI6e319"},
+	}})
+	suite.False(report.Valid)
+
+	// moving the reference to the new revision creates a new revision of the schema
+	updated, err := Update(suite.ctx, sl.Schemas[0], "", "", "", nil,
+		[]Reference{{Name: "address.json", Schema: "address", Revision: 2}}, store)
+	suite.Nil(err)
+	suite.Equal(int64(2), updated.Revision)
+
+	report, _ = ValidateMessagesReport(updated, messages.MsgList{Msgs: []messages.Message{
+		{Data: "eyJuYW1lIjoiam9lIiwiYWRkcmVzcyI6e319"},
+	}})
+	suite.True(report.Valid)
+
+	// the revisions keep their references
+	rl, err := FindRevisions(suite.ctx, updated, 0, store)
+	suite.Nil(err)
+	suite.Equal(int64(1), rl.Revisions[0].References[0].Revision)
+	suite.Equal(int64(2), rl.Revisions[1].References[0].Revision)
+
+	// rolling back restores the references of the revision
+	rolled, err := Rollback(suite.ctx, updated, 1, store)
+	suite.Nil(err)
+	suite.Equal(int64(1), rolled.References[0].Revision)
+
+	// referenced schemas can't be deleted
+	suite.Equal(errors.New("referenced"), Delete(suite.ctx, "argo_uuid", "address_uuid", store))
+
+	// dropping the references isn't enough while older revisions still reference the schema
+	_, err = Update(suite.ctx, rolled, "", "", "", map[string]interface{}{"type": "object"}, []Reference{}, store)
+	suite.Nil(err)
+	suite.Equal(errors.New("referenced"), Delete(suite.ctx, "argo_uuid", "address_uuid", store))
+
+	// deleting the schema along with its revisions allows the deletion
+	suite.Nil(Delete(suite.ctx, "argo_uuid", "person_uuid", store))
+	suite.Nil(Delete(suite.ctx, "argo_uuid", "address_uuid", store))
+}
+
+func (suite *SchemasTestSuite) TestInvalidReferences() {
+
+	store := stores.NewMockStore("", "")
+	// the schemas of the mock store were created before revisions existed
+	MigrateRevisions(suite.ctx, store)
+
+	jsonSchema := map[string]interface{}{"type": "object"}
+
+	type td struct {
+		references []Reference
+		err        error
+		msg        string
+	}
+
+	testData := []td{
+		{
+			references: []Reference{{Name: "a.json"}},
+			err:        errors.New("Schema references should declare both a name and a schema"),
+			msg:        "Case where the reference doesn't declare a schema",
+		},
+		{
+			references: []Reference{{Name: "a.json", Schema: "schema-1"}, {Name: "a.json", Schema: "schema-2"}},
+			err:        errors.New("Schema reference a.json is declared more than once"),
+			msg:        "Case where the same name is used twice",
+		},
+		{
+			references: []Reference{{Name: "a.json", Schema: "unknown"}},
+			err:        errors.New("Referenced schema unknown doesn't exist"),
+			msg:        "Case where the referenced schema doesn't exist",
+		},
+		{
+			references: []Reference{{Name: "a.json", Schema: "schema-1", Revision: 5}},
+			err:        errors.New("Schema reference a.json points to revision 5 which doesn't exist"),
+			msg:        "Case where the referenced revision doesn't exist",
+		},
+		{
+			references: []Reference{{Name: "a.json", Schema: "schema-3"}},
+			err:        errors.New("Schema reference a.json should point to a json schema"),
+			msg:        "Case where the referenced schema is of another type",
+		},
+	}
+
+	for _, t := range testData {
+		_, err := Create(suite.ctx, "argo_uuid", "new_uuid", "new-schema", JSON, "", jsonSchema, t.references, store)
+		suite.Equal(t.err, err, t.msg)
+	}
+
+	// a schema can't reference itself
+	sl, _ := Find(suite.ctx, "argo_uuid", "schema_uuid_1", "", store)
+	_, err := Update(suite.ctx, sl.Schemas[0], "", "", "", nil, []Reference{{Name: "a.json", Schema: "schema-1"}}, store)
+	suite.Equal(errors.New("Schema can't reference itself"), err)
+}
+
+func (suite *SchemasTestSuite) TestAvroReferences() {
+
+	store := stores.NewMockStore("", "")
+	// the schemas of the mock store were created before revisions existed
+	MigrateRevisions(suite.ctx, store)
+
+	// schema-3 defines the user.avro.User record
+	order := map[string]interface{}{
+		"namespace": "shop.avro",
+		"type":      "record",
+		"name":      "Order",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "buyer", "type": "user.avro.User"},
+			map[string]interface{}{"name": "seller", "type": "user.avro.User"},
+		},
+	}
+
+	// the named type is unknown without the reference
+	_, err := Create(suite.ctx, "argo_uuid", "order_uuid", "order", AVRO, "", order, nil, store)
+	suite.NotNil(err)
+
+	s, err := Create(suite.ctx, "argo_uuid", "order_uuid", "order", AVRO, "", order,
+		[]Reference{{Name: "user.avro.User", Schema: "schema-3"}}, store)
+	suite.Nil(err)
+
+	// the referenced record is defined at its first use and referred to by name afterwards
+	suite.Equal(map[string]interface{}{
+		"namespace": "shop.avro",
+		"type":      "record",
+		"name":      "Order",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "buyer", "type": map[string]interface{}{
+				"type": "record",
+				"name": "user.avro.User",
+				"fields": []interface{}{
+					map[string]interface{}{"name": "username", "type": "string"},
+					map[string]interface{}{"name": "phone", "type": "int"},
+				},
+			}},
+			map[string]interface{}{"name": "seller", "type": "user.avro.User"},
+		},
+	}, expandAvroSchema(s.RawSchema, s.dependencies))
+
+	report, err := ValidateMessagesReport(s, messages.MsgList{Msgs: []messages.Message{
+		// buyer: {username: agelos, phone: 89890}, seller: {username: agelos, phone: 89890}
+		{Data: "DGFnZWxvc8T8CgxhZ2Vsb3PE/Ao="},
+	}})
+	suite.Nil(err)
+	suite.True(report.Valid)
+}
+
+func (suite *SchemasTestSuite) TestProtobufReferences() {
+
+	store := stores.NewMockStore("", "")
+
+	_, err := Create(suite.ctx, "argo_uuid", "address_uuid", "address", PROTOBUF, "", map[string]interface{}{
+		"message_type": "common.Address",
+		"proto":        "syntax = \"proto3\"; package common; message Address { string street = 1; }",
+	}, nil, store)
+	suite.Nil(err)
+
+	user := map[string]interface{}{
+		"message_type": "user.User",
+		"proto": "syntax = \"proto3\"; package user; import \"common/address.proto\";" +
+			" message User { string username = 1; common.Address address = 2; }",
+	}
+
+	// the import can't be resolved without the reference
+	_, err = Create(suite.ctx, "argo_uuid", "user_uuid", "user", PROTOBUF, "", user, nil, store)
+	suite.NotNil(err)
+
+	s, err := Create(suite.ctx, "argo_uuid", "user_uuid", "user", PROTOBUF, "", user,
+		[]Reference{{Name: "common/address.proto", Schema: "address"}}, store)
+	suite.Nil(err)
+
+	report, err := ValidateMessagesReport(s, messages.MsgList{Msgs: []messages.Message{
+		// username: joe, address: {street: Main}
+		{Data: "CgNqb2USBgoETWFpbg=="},
+	}})
+	suite.Nil(err)
+	suite.True(report.Valid)
+
+	d, err := NewDecoder(s)
+	suite.Nil(err)
+	decoded, err := d.Decode([]byte{0x0a, 0x03, 'j', 'o', 'e', 0x12, 0x06, 0x0a, 0x04, 'M', 'a', 'i', 'n'})
+	suite.Nil(err)
+	suite.JSONEq(`{"username":"joe","address":{"street":"Main"}}`, string(decoded))
+}
@@ -0,0 +1,111 @@
+package schemas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	// RegistryAvro is the schema registry type of avro schemas, it is also the type assumed when none is given
+	RegistryAvro = "AVRO"
+	// RegistryJSON is the schema registry type of json schemas
+	RegistryJSON = "JSON"
+	// RegistryProtobuf is the schema registry type of protobuf schemas
+	RegistryProtobuf = "PROTOBUF"
+)
+
+// RegistrySchema is the representation of a schema revision in the Confluent Schema Registry API
+type RegistrySchema struct {
+	Subject    string `json:"subject,omitempty"`
+	Version    int64  `json:"version,omitempty"`
+	ID         int64  `json:"id,omitempty"`
+	SchemaType string `json:"schemaType,omitempty"`
+	Schema     string `json:"schema"`
+}
+
+// FromRegistryType maps a schema registry type to the respective schema type
+func FromRegistryType(registryType string) (string, error) {
+
+	switch strings.ToUpper(registryType) {
+	case "", RegistryAvro:
+		return AVRO, nil
+	case RegistryJSON:
+		return JSON, nil
+	case RegistryProtobuf:
+		return PROTOBUF, nil
+	}
+
+	return "", fmt.Errorf("schema type %s is not supported", registryType)
+}
+
+// ToRegistryType maps a schema type to the respective schema registry type.
+// Avro schemas are returned without a type, as the schema registry does.
+func ToRegistryType(schemaType string) string {
+
+	switch schemaType {
+	case JSON:
+		return RegistryJSON
+	case PROTOBUF:
+		return RegistryProtobuf
+	}
+
+	return ""
+}
+
+// FromRegistrySchema converts the textual definition of a schema registry schema to the content of a schema and checks its validity.
+// Avro and json schemas are json documents, protobuf schemas are .proto sources whose first message type is the one used.
+func FromRegistrySchema(schemaType string, definition string) (map[string]interface{}, error) {
+
+	rawSchema := map[string]interface{}{}
+
+	switch schemaType {
+	case JSON, AVRO:
+		err := json.Unmarshal([]byte(definition), &rawSchema)
+		if err != nil {
+			return nil, errors.New("schema should be a json object")
+		}
+	case PROTOBUF:
+		messageType, err := firstProtoMessage(definition)
+		if err != nil {
+			return nil, err
+		}
+		rawSchema[ProtoMessageTypeField] = messageType
+		rawSchema[ProtoSourceField] = definition
+	default:
+		return nil, errors.New("unsupported")
+	}
+
+	err := checkSchema(schemaType, rawSchema, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return rawSchema, nil
+}
+
+// ToRegistrySchema converts the content of a schema to its textual schema registry definition
+func ToRegistrySchema(schemaType string, rawSchema map[string]interface{}) (string, error) {
+
+	if schemaType == PROTOBUF {
+		source, ok := rawSchema[ProtoSourceField].(string)
+		if !ok {
+			return "", fmt.Errorf("protobuf schemas without a %s cannot be represented in the schema registry", ProtoSourceField)
+		}
+		return source, nil
+	}
+
+	b, err := json.Marshal(rawSchema)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// SameContent returns weather or not the revision holds the given schema type and content
+func (r Revision) SameContent(schemaType string, rawSchema map[string]interface{}) bool {
+	return r.Type == schemaType && reflect.DeepEqual(r.RawSchema, rawSchema)
+}
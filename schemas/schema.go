@@ -9,27 +9,35 @@ import (
 	"github.com/ARGOeu/argo-messaging/messages"
 	"github.com/ARGOeu/argo-messaging/projects"
 	"github.com/ARGOeu/argo-messaging/stores"
-	"github.com/linkedin/goavro"
 	log "github.com/sirupsen/logrus"
-	"github.com/xeipuuv/gojsonschema"
 	"strings"
+	"time"
 )
 
 const (
 	JSON                   = "json"
 	AVRO                   = "avro"
-	UnsupportedSchemaError = `Schema type can only be 'json' or 'avro'`
+	PROTOBUF               = "protobuf"
+	UnsupportedSchemaError = `Schema type can only be 'json', 'avro' or 'protobuf'`
 	GenericError           = "Could not load schema for topic"
+	RevisionConflictError  = "Schema has been changed by another request, please retry"
 )
 
 // Schema holds information regarding a schema that will be used to validate a topic's published messages
 type Schema struct {
-	ProjectUUID string                 `json:"-"`
-	UUID        string                 `json:"uuid"`
-	Name        string                 `json:"-"`
-	FullName    string                 `json:"name"`
-	Type        string                 `json:"type"`
-	RawSchema   map[string]interface{} `json:"schema"`
+	ProjectUUID   string                 `json:"-"`
+	UUID          string                 `json:"uuid"`
+	Name          string                 `json:"-"`
+	FullName      string                 `json:"name"`
+	Type          string                 `json:"type"`
+	RawSchema     map[string]interface{} `json:"schema"`
+	Revision      int64                  `json:"revision"`
+	Compatibility string                 `json:"compatibility"`
+	References    []Reference            `json:"references,omitempty"`
+	// dependencies holds the content of the referenced schema revisions
+	dependencies []dependency
+	// compiled holds the compiled definition of cached schemas
+	compiled *validator
 }
 
 // SchemaList is a wrapper for a slice of schemas
@@ -37,109 +45,44 @@ type SchemaList struct {
 	Schemas []Schema `json:"schemas"`
 }
 
+// Revision holds an immutable version of a schema's type and content
+type Revision struct {
+	ID         int64                  `json:"id,omitempty"`
+	Revision   int64                  `json:"revision"`
+	Type       string                 `json:"type"`
+	RawSchema  map[string]interface{} `json:"schema"`
+	References []Reference            `json:"references,omitempty"`
+	CreatedOn  string                 `json:"created_on,omitempty"`
+}
+
+// RevisionList is a wrapper for a slice of schema revisions
+type RevisionList struct {
+	Revisions []Revision `json:"revisions"`
+}
+
+// Empty returns weather or not there are any revisions inside the revision list
+func (rl *RevisionList) Empty() bool {
+	return len(rl.Revisions) <= 0
+}
+
 // Empty returns weather or not there are any schemas inside the schema list
 func (sl *SchemaList) Empty() bool {
 	return len(sl.Schemas) <= 0
 }
 
-// ValidateMessages validates a list of messages against the provided schema
+// ValidateMessages validates a list of messages against the provided schema and stops at the first invalid one
 func ValidateMessages(schema Schema, msgList messages.MsgList) error {
 
-	switch schema.Type {
-	case JSON:
-		// load the schema
-		s, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema.RawSchema))
-
-		if err != nil {
-			log.WithFields(
-				log.Fields{
-					"type":        "service_log",
-					"schema_name": schema.FullName,
-					"error":       err.Error(),
-				},
-			).Error("Could not load json schema")
-			return errors.New("500")
-		}
-
-		for idx, msg := range msgList.Msgs {
-
-			// decode the message payload from base64
-			messageBytes, err := base64.StdEncoding.DecodeString(msg.Data)
-
-			if err != nil {
-				return fmt.Errorf("Message %v is not in valid base64 enocding,%s", idx, err.Error())
-			}
-
-			documentLoader := gojsonschema.NewBytesLoader(messageBytes)
-
-			result, err := s.Validate(documentLoader)
-			if err != nil {
-				return fmt.Errorf("Message %v data is not valid JSON format,%s", idx, err.Error())
-			}
-
-			if !result.Valid() {
-				if len(result.Errors()) > 1 {
-					sb := strings.Builder{}
-
-					for idx, e := range result.Errors() {
-						sb.WriteString(fmt.Sprintf("%v)%s.", idx+1, e.String()))
-					}
-
-					return fmt.Errorf("Message %v data is not valid.%s", idx, sb.String())
-				} else {
-					return fmt.Errorf("Message %v data is not valid,%v", idx, result.Errors()[0].String())
-				}
-			}
-		}
-	case AVRO:
-		// convert the schema to a json string representation
-		b, err := json.Marshal(schema.RawSchema)
-		if err != nil {
-			log.WithFields(
-				log.Fields{
-					"type":        "service_log",
-					"schema_name": schema.FullName,
-					"error":       err.Error(),
-				},
-			).Error("Could not convert to json bytes representation")
-			return errors.New("500")
-		}
+	v, err := schema.validator()
+	if err != nil {
+		return err
+	}
 
-		c, err := goavro.NewCodec(string(b))
+	for idx, msg := range msgList.Msgs {
+		_, err := v.validate(idx, msg.Data)
 		if err != nil {
-			log.WithFields(
-				log.Fields{
-					"type":        "service_log",
-					"schema_name": schema.FullName,
-					"error":       err.Error(),
-				},
-			).Error("Could not load avro schema")
-			return errors.New("500")
-		}
-
-		for idx, msg := range msgList.Msgs {
-
-			// decode the message payload from base64
-			messageBytes, err := base64.StdEncoding.DecodeString(msg.Data)
-			if err != nil {
-				return fmt.Errorf("Message %v is not in valid base64 enocding,%s", idx, err.Error())
-			}
-
-			_, _, err = c.NativeFromBinary(messageBytes)
-			if err != nil {
-				return fmt.Errorf("Message %v is not valid.%s", idx, err.Error())
-			}
+			return err
 		}
-
-	default:
-		log.WithFields(
-			log.Fields{
-				"type":        "service_log",
-				"schema_name": schema.FullName,
-				"schema_type": schema.Type,
-			},
-		).Error("Schema with unsupported type")
-		return errors.New("500")
 	}
 
 	return nil
@@ -185,8 +128,19 @@ func Find(ctx context.Context, projectUUID, schemaUUID, schemaName string, str s
 		_schema.Name = s.Name
 		_schema.Type = s.Type
 		_schema.ProjectUUID = s.ProjectUUID
+		_schema.Revision = s.Revision
+		_schema.Compatibility = s.Compatibility
+
+		// schemas that were created before revisions existed are on their first revision
+		if _schema.Revision == 0 {
+			_schema.Revision = 1
+		}
 
-		decodedSchemaBytes, err := base64.StdEncoding.DecodeString(s.RawSchema)
+		if _schema.Compatibility == "" {
+			_schema.Compatibility = NONE
+		}
+
+		_schema.RawSchema, err = decodeRawSchema(s.RawSchema)
 		if err != nil {
 			log.WithFields(
 				log.Fields{
@@ -195,43 +149,353 @@ func Find(ctx context.Context, projectUUID, schemaUUID, schemaName string, str s
 					"project_uuid": projectUUID,
 					"error":        err.Error(),
 				},
-			).Error("Could not decode the base64 encoded schema")
+			).Error("Could not decode the schema")
 			return SchemaList{}, errors.New("Could not load the schema")
 		}
 
-		err = json.Unmarshal(decodedSchemaBytes, &_schema.RawSchema)
+		if len(s.References) > 0 {
+			_schema.References, err = loadReferences(ctx, s.ProjectUUID, s.References, str)
+			if err == nil {
+				_schema.dependencies, err = loadDependencies(ctx, s.ProjectUUID, s.Type, s.References, str, 0)
+			}
+			if err != nil {
+				log.WithFields(
+					log.Fields{
+						"type":         "service_log",
+						"schema_name":  s.Name,
+						"project_uuid": projectUUID,
+						"error":        err.Error(),
+					},
+				).Error("Could not load the schema references")
+				return SchemaList{}, errors.New("Could not load the schema")
+			}
+		}
+
+		projectName := projects.GetNameByUUID(ctx, projectUUID, str)
+
+		_schema.FullName = FormatSchemaRef(projectName, s.Name)
+
+		schemaList.Schemas = append(schemaList.Schemas, _schema)
+	}
+
+	return schemaList, nil
+}
+
+// decodeRawSchema decodes the base64 encoded json representation of a schema
+func decodeRawSchema(rawSchemaString string) (map[string]interface{}, error) {
+
+	rawSchema := map[string]interface{}{}
+
+	decodedSchemaBytes, err := base64.StdEncoding.DecodeString(rawSchemaString)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(decodedSchemaBytes, &rawSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	return rawSchema, nil
+}
+
+// encodeRawSchema produces the base64 encoded json representation of a schema
+func encodeRawSchema(rawSchema map[string]interface{}) (string, error) {
+
+	schemaBytes, err := json.Marshal(rawSchema)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(schemaBytes), nil
+}
+
+// FindRevisions retrieves all the revisions of a schema or a specific one
+func FindRevisions(ctx context.Context, schema Schema, revision int64, str stores.Store) (RevisionList, error) {
+
+	revisionList := RevisionList{
+		Revisions: []Revision{},
+	}
+
+	qRevisions, err := str.QuerySchemaRevisions(ctx, schema.ProjectUUID, schema.UUID, revision)
+	if err != nil {
+		return revisionList, err
+	}
+
+	for _, r := range qRevisions {
+		rawSchema, err := decodeRawSchema(r.RawSchema)
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":        "service_log",
+					"schema_name": schema.FullName,
+					"revision":    r.Revision,
+					"error":       err.Error(),
+				},
+			).Error("Could not decode the schema revision")
+			return RevisionList{}, errors.New("Could not load the schema")
+		}
+
+		references, err := loadReferences(ctx, r.ProjectUUID, r.References, str)
+		if err != nil {
+			return RevisionList{}, err
+		}
+
+		revisionList.Revisions = append(revisionList.Revisions, Revision{
+			ID:         r.ID,
+			Revision:   r.Revision,
+			Type:       r.Type,
+			RawSchema:  rawSchema,
+			References: references,
+			CreatedOn:  r.CreatedOn.Format("2006-01-02T15:04:05Z"),
+		})
+	}
+
+	return revisionList, nil
+}
+
+// FindRevisionByID retrieves the schema revision with the given id under the given project
+func FindRevisionByID(ctx context.Context, projectUUID string, id int64, str stores.Store) (RevisionList, error) {
+
+	revisionList := RevisionList{
+		Revisions: []Revision{},
+	}
+
+	qRevisions, err := str.QuerySchemaRevisionByID(ctx, projectUUID, id)
+	if err != nil {
+		return revisionList, err
+	}
+
+	for _, r := range qRevisions {
+		rawSchema, err := decodeRawSchema(r.RawSchema)
 		if err != nil {
 			log.WithFields(
 				log.Fields{
 					"type":         "service_log",
-					"schema_name":  schemaName,
 					"project_uuid": projectUUID,
+					"revision_id":  id,
 					"error":        err.Error(),
 				},
-			).Error("Could not marshal the schema bytes")
-			return SchemaList{}, errors.New("Could not load the schema")
+			).Error("Could not decode the schema revision")
+			return RevisionList{}, errors.New("Could not load the schema")
 		}
 
-		projectName := projects.GetNameByUUID(ctx, projectUUID, str)
+		references, err := loadReferences(ctx, r.ProjectUUID, r.References, str)
+		if err != nil {
+			return RevisionList{}, err
+		}
 
-		_schema.FullName = FormatSchemaRef(projectName, s.Name)
+		revisionList.Revisions = append(revisionList.Revisions, Revision{
+			ID:         r.ID,
+			Revision:   r.Revision,
+			Type:       r.Type,
+			RawSchema:  rawSchema,
+			References: references,
+			CreatedOn:  r.CreatedOn.Format("2006-01-02T15:04:05Z"),
+		})
+	}
 
-		schemaList.Schemas = append(schemaList.Schemas, _schema)
+	return revisionList, nil
+}
+
+// MigrateRevisions stores the current content of the schemas that were created before revisions existed
+// as their first revision and returns how many schemas it migrated. It runs on startup, so that reads
+// of the revisions never have to write to the store
+func MigrateRevisions(ctx context.Context, str stores.Store) (int, error) {
+
+	projects, err := str.QueryProjects(ctx, "", "")
+	if err != nil {
+		return 0, err
 	}
 
-	return schemaList, nil
+	migrated := 0
+	for _, p := range projects {
+		sl, err := Find(ctx, p.UUID, "", "", str)
+		if err != nil {
+			return migrated, err
+		}
+		for _, schema := range sl.Schemas {
+			stored, err := ensureRevisions(ctx, schema, str)
+			if err != nil {
+				return migrated, err
+			}
+			if stored {
+				migrated++
+			}
+		}
+	}
+
+	return migrated, nil
+}
+
+// ensureRevisions stores the current content of a schema that was created before revisions existed as its first revision,
+// reporting whether it had to store it
+func ensureRevisions(ctx context.Context, schema Schema, str stores.Store) (bool, error) {
+
+	qRevisions, err := str.QuerySchemaRevisions(ctx, schema.ProjectUUID, schema.UUID, 0)
+	if err != nil {
+		return false, err
+	}
+
+	if len(qRevisions) > 0 {
+		return false, nil
+	}
+
+	rawSchemaString, err := encodeRawSchema(schema.RawSchema)
+	if err != nil {
+		return false, err
+	}
+
+	err = str.InsertSchemaRevision(ctx, schema.ProjectUUID, schema.UUID, schema.Revision, schema.Type,
+		rawSchemaString, toQReferences(schema.References), time.Now().UTC())
+	if err != nil {
+		// a concurrent update has already stored it
+		if err.Error() == "exists" {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// insertRevision stores the content of the schema as the revision that follows the previous one.
+// The revision is stored before the schema changes, so that out of two updates that start from the same
+// revision only the first one goes through and the other one fails with a revision conflict
+func insertRevision(ctx context.Context, previous Schema, schema *Schema, rawSchemaString string, str stores.Store) error {
+
+	schema.Revision = previous.Revision + 1
+
+	err := str.InsertSchemaRevision(ctx, previous.ProjectUUID, previous.UUID, schema.Revision, schema.Type,
+		rawSchemaString, toQReferences(schema.References), time.Now().UTC())
+	if err != nil {
+		if err.Error() == "exists" {
+			return errors.New("revision conflict")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Rollback creates a new revision of the schema with the type and content of the given past revision
+func Rollback(ctx context.Context, existingSchema Schema, revision int64, str stores.Store) (Schema, error) {
+
+	revisionList, err := FindRevisions(ctx, existingSchema, revision, str)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	if revisionList.Empty() {
+		return Schema{}, errors.New("not found")
+	}
+
+	target := revisionList.Revisions[0]
+
+	// the compiled definition of a cached schema doesn't follow its changes
+	existingSchema.compiled = nil
+
+	// the revision keeps pointing to the same revisions of the schemas it references
+	deps, err := loadDependencies(ctx, existingSchema.ProjectUUID, target.Type, toQReferences(target.References), str, 0)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	rawSchemaString, err := encodeRawSchema(target.RawSchema)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	schema := existingSchema
+	schema.Type = target.Type
+	schema.RawSchema = target.RawSchema
+	schema.References = target.References
+	schema.dependencies = deps
+
+	// rolling back creates a new revision, which has to respect the compatibility mode like any other
+	err = CheckCompatibility(existingSchema.Compatibility, existingSchema, schema)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	err = insertRevision(ctx, existingSchema, &schema, rawSchemaString, str)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	err = str.UpdateSchema(ctx, existingSchema.UUID, "", target.Type, rawSchemaString, "", schema.Revision,
+		toQReferences(target.References))
+	if err != nil {
+		return Schema{}, err
+	}
+
+	invalidateSchema(ctx, schema.ProjectUUID, schema.UUID, str)
+
+	return schema, nil
 }
 
-// Delete wraps the store's method for removing a schema
-func Delete(ctx context.Context, schemaUUID string, str stores.Store) error {
-	return str.DeleteSchema(ctx, schemaUUID)
+// Delete removes a schema along with its revisions, as long as no other schema of the project references it,
+// neither in its current content nor in any of its revisions
+func Delete(ctx context.Context, projectUUID, schemaUUID string, str stores.Store) error {
+
+	referencing, err := str.QuerySchemasByReference(ctx, projectUUID, schemaUUID)
+	if err != nil {
+		return err
+	}
+
+	if len(referencing) > 0 {
+		return errors.New("referenced")
+	}
+
+	// older revisions of other schemas can still be resolved, so they keep the schema in use
+	revisions, err := str.QuerySchemaRevisionsByReference(ctx, projectUUID, schemaUUID)
+	if err != nil {
+		return err
+	}
+
+	for _, rev := range revisions {
+		if rev.SchemaUUID != schemaUUID {
+			return errors.New("referenced")
+		}
+	}
+
+	err = str.DeleteSchema(ctx, schemaUUID)
+	if err != nil {
+		return err
+	}
+
+	invalidateSchema(ctx, projectUUID, schemaUUID, str)
+
+	return nil
 }
 
-// Update updates the provided schema , validates its content and saves it to the store
-func Update(ctx context.Context, existingSchema Schema, newSchemaName, newSchemaType string, newRawSchema map[string]interface{}, str stores.Store) (Schema, error) {
+// Update updates the provided schema , validates its content and saves it to the store.
+// Nil references keep the existing ones, any other value replaces them.
+func Update(ctx context.Context, existingSchema Schema, newSchemaName, newSchemaType, newCompatibility string, newRawSchema map[string]interface{}, newReferences []Reference, str stores.Store) (Schema, error) {
 
 	newSchema := Schema{}
 
+	if existingSchema.Revision == 0 {
+		existingSchema.Revision = 1
+	}
+
+	if existingSchema.Compatibility == "" {
+		existingSchema.Compatibility = NONE
+	}
+
+	// the compiled definition of a cached schema doesn't follow its changes
+	existingSchema.compiled = nil
+
+	previous := existingSchema
+
+	newCompatibility, err := ParseCompatibility(newCompatibility)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	if newCompatibility != "" {
+		existingSchema.Compatibility = newCompatibility
+	}
+
 	if newSchemaName != "" {
 		// if the name has changed check that is not already taken by another schema under the given project
 		if existingSchema.Name != newSchemaName {
@@ -258,11 +522,37 @@ func Update(ctx context.Context, existingSchema Schema, newSchemaName, newSchema
 		newSchema.Type = existingSchema.Type
 	}
 
+	referencesChanged := false
+
+	if newReferences != nil {
+		references, deps, err := resolveReferences(ctx, existingSchema.ProjectUUID, newSchema.Type, newReferences, str)
+		if err != nil {
+			return Schema{}, err
+		}
+
+		for _, ref := range references {
+			if ref.schemaUUID == existingSchema.UUID {
+				return Schema{}, errors.New("Schema can't reference itself")
+			}
+		}
+
+		referencesChanged = !sameReferences(existingSchema.References, references)
+		existingSchema.References = references
+		existingSchema.dependencies = deps
+	} else if newSchemaType != "" && len(existingSchema.References) > 0 {
+		// the existing references should point to schemas of the new type
+		deps, err := loadDependencies(ctx, existingSchema.ProjectUUID, newSchema.Type, toQReferences(existingSchema.References), str, 0)
+		if err != nil {
+			return Schema{}, err
+		}
+		existingSchema.dependencies = deps
+	}
+
 	rawSchemaString := ""
 
 	// if there is a new schema check the validity
 	if len(newRawSchema) > 0 {
-		err := checkSchema(newSchema.Type, newRawSchema)
+		err := checkSchema(newSchema.Type, newRawSchema, existingSchema.dependencies)
 		if err != nil {
 			return Schema{}, err
 		}
@@ -280,20 +570,46 @@ func Update(ctx context.Context, existingSchema Schema, newSchemaName, newSchema
 
 	}
 
-	// if there is a new type for the already existing schema
-	if len(newRawSchema) == 0 && newSchemaType != "" {
-		err := checkSchema(newSchema.Type, existingSchema.RawSchema)
+	// if there is a new type or new references for the already existing schema
+	if len(newRawSchema) == 0 && (newSchemaType != "" || referencesChanged) {
+		err := checkSchema(newSchema.Type, existingSchema.RawSchema, existingSchema.dependencies)
 		if err != nil {
 			return Schema{}, err
 		}
 
+		rawSchemaString, err = encodeRawSchema(existingSchema.RawSchema)
+		if err != nil {
+			return Schema{}, err
+		}
+	}
+
+	// a change of type, content or references results in a new revision that has to respect the compatibility mode
+	newRevision := rawSchemaString != ""
+	if newRevision {
+		err := CheckCompatibility(existingSchema.Compatibility, previous, existingSchema)
+		if err != nil {
+			return Schema{}, err
+		}
+	}
+
+	// the schema keeps its revision when only its name or compatibility mode change
+	var revision int64
+	if newRevision {
+		err = insertRevision(ctx, previous, &existingSchema, rawSchemaString, str)
+		if err != nil {
+			return Schema{}, err
+		}
+		revision = existingSchema.Revision
 	}
 
-	err := str.UpdateSchema(ctx, existingSchema.UUID, newSchema.Name, newSchema.Type, rawSchemaString)
+	err = str.UpdateSchema(ctx, existingSchema.UUID, newSchema.Name, newSchema.Type, rawSchemaString, newCompatibility,
+		revision, toQReferences(existingSchema.References))
 	if err != nil {
 		return Schema{}, err
 	}
 
+	invalidateSchema(ctx, existingSchema.ProjectUUID, existingSchema.UUID, str)
+
 	projectName := projects.GetNameByUUID(ctx, existingSchema.ProjectUUID, str)
 
 	existingSchema.FullName = FormatSchemaRef(projectName, existingSchema.Name)
@@ -301,8 +617,17 @@ func Update(ctx context.Context, existingSchema Schema, newSchemaName, newSchema
 	return existingSchema, nil
 }
 
-// Create checks the validity of the schema to be created and then saves it to the store
-func Create(ctx context.Context, projectUUID, schemaUUID, name, schemaType string, rawSchema map[string]interface{}, str stores.Store) (Schema, error) {
+// Create checks the validity of the schema to be created, along with the schemas it references, and then saves it to the store
+func Create(ctx context.Context, projectUUID, schemaUUID, name, schemaType, compatibility string, rawSchema map[string]interface{}, references []Reference, str stores.Store) (Schema, error) {
+
+	compatibility, err := ParseCompatibility(compatibility)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	if compatibility == "" {
+		compatibility = NONE
+	}
 
 	exists, err := ExistsWithName(ctx, projectUUID, name, str)
 	if err != nil {
@@ -322,12 +647,22 @@ func Create(ctx context.Context, projectUUID, schemaUUID, name, schemaType strin
 
 	schemaType = strings.ToLower(schemaType)
 
-	err = checkSchema(schemaType, rawSchema)
+	references, deps, err := resolveReferences(ctx, projectUUID, schemaType, references, str)
 	if err != nil {
 		return Schema{}, err
 	}
 
-	err = str.InsertSchema(ctx, projectUUID, schemaUUID, name, schemaType, b64SchemaString)
+	err = checkSchema(schemaType, rawSchema, deps)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	err = str.InsertSchema(ctx, projectUUID, schemaUUID, name, schemaType, b64SchemaString, compatibility, 1, toQReferences(references))
+	if err != nil {
+		return Schema{}, err
+	}
+
+	err = str.InsertSchemaRevision(ctx, projectUUID, schemaUUID, 1, schemaType, b64SchemaString, toQReferences(references), time.Now().UTC())
 	if err != nil {
 		return Schema{}, err
 	}
@@ -335,37 +670,42 @@ func Create(ctx context.Context, projectUUID, schemaUUID, name, schemaType strin
 	projectName := projects.GetNameByUUID(ctx, projectUUID, str)
 
 	schema := Schema{
-		UUID:      schemaUUID,
-		Name:      name,
-		Type:      schemaType,
-		RawSchema: rawSchema,
-		FullName:  FormatSchemaRef(projectName, name),
+		UUID:          schemaUUID,
+		Name:          name,
+		Type:          schemaType,
+		RawSchema:     rawSchema,
+		FullName:      FormatSchemaRef(projectName, name),
+		Revision:      1,
+		Compatibility: compatibility,
+		References:    references,
+		dependencies:  deps,
 	}
 
 	return schema, nil
 }
 
-// checkSchema checks that the schema content is indeed of its provided schema type
-func checkSchema(schemaType string, schemaContent map[string]interface{}) error {
+// checkSchema checks that the schema content, along with the content of the schemas it references,
+// is indeed of its provided schema type
+func checkSchema(schemaType string, schemaContent map[string]interface{}, deps []dependency) error {
 
 	switch strings.ToLower(schemaType) {
 	case JSON:
 
-		jsonLoader := gojsonschema.NewGoLoader(schemaContent)
-		_, err := gojsonschema.NewSchemaLoader().Compile(jsonLoader)
+		_, err := compileJSONSchema(schemaContent, deps)
 		if err != nil {
 			return err
 		}
 
 	case AVRO:
 
-		// convert the schema to a json string representation
-		b, err := json.Marshal(schemaContent)
+		_, err := compileAvroSchema(schemaContent, deps)
 		if err != nil {
 			return err
 		}
 
-		_, err = goavro.NewCodec(string(b))
+	case PROTOBUF:
+
+		_, err := compileProtobuf(schemaContent, deps)
 		if err != nil {
 			return err
 		}
@@ -94,10 +94,12 @@ func (suite *SchemasTestSuite) TestFind() {
 			schemaList: SchemaList{
 				Schemas: []Schema{
 					{UUID: "schema_uuid_1",
-						ProjectUUID: "argo_uuid",
-						Name:        "schema-1",
-						FullName:    "projects/ARGO/schemas/schema-1",
-						Type:        JSON,
+						ProjectUUID:   "argo_uuid",
+						Name:          "schema-1",
+						FullName:      "projects/ARGO/schemas/schema-1",
+						Revision:      1,
+						Compatibility: NONE,
+						Type:          JSON,
 						RawSchema: map[string]interface{}{
 							"properties": map[string]interface{}{
 								"address":   map[string]interface{}{"type": "string"},
@@ -121,11 +123,13 @@ func (suite *SchemasTestSuite) TestFind() {
 			schemaList: SchemaList{
 				Schemas: []Schema{
 					{
-						ProjectUUID: "argo_uuid",
-						UUID:        "schema_uuid_1",
-						Name:        "schema-1",
-						FullName:    "projects/ARGO/schemas/schema-1",
-						Type:        JSON,
+						ProjectUUID:   "argo_uuid",
+						UUID:          "schema_uuid_1",
+						Name:          "schema-1",
+						FullName:      "projects/ARGO/schemas/schema-1",
+						Revision:      1,
+						Compatibility: NONE,
+						Type:          JSON,
 						RawSchema: map[string]interface{}{
 							"properties": map[string]interface{}{
 								"address":   map[string]interface{}{"type": "string"},
@@ -138,11 +142,13 @@ func (suite *SchemasTestSuite) TestFind() {
 						},
 					},
 					{
-						ProjectUUID: "argo_uuid",
-						UUID:        "schema_uuid_2",
-						Name:        "schema-2",
-						FullName:    "projects/ARGO/schemas/schema-2",
-						Type:        JSON,
+						ProjectUUID:   "argo_uuid",
+						UUID:          "schema_uuid_2",
+						Name:          "schema-2",
+						FullName:      "projects/ARGO/schemas/schema-2",
+						Revision:      1,
+						Compatibility: NONE,
+						Type:          JSON,
 						RawSchema: map[string]interface{}{
 							"properties": map[string]interface{}{
 								"address":   map[string]interface{}{"type": "string"},
@@ -155,11 +161,13 @@ func (suite *SchemasTestSuite) TestFind() {
 						},
 					},
 					{
-						ProjectUUID: "argo_uuid",
-						UUID:        "schema_uuid_3",
-						Name:        "schema-3",
-						FullName:    "projects/ARGO/schemas/schema-3",
-						Type:        AVRO,
+						ProjectUUID:   "argo_uuid",
+						UUID:          "schema_uuid_3",
+						Name:          "schema-3",
+						FullName:      "projects/ARGO/schemas/schema-3",
+						Revision:      1,
+						Compatibility: NONE,
+						Type:          AVRO,
 						RawSchema: map[string]interface{}{
 							"namespace": "user.avro",
 							"type":      "record",
@@ -222,12 +230,14 @@ func (suite *SchemasTestSuite) TestUpdate() {
 			newType:   JSON,
 			newSchema: map[string]interface{}{"type": "string"},
 			expectedSchema: Schema{
-				ProjectUUID: "argo_uuid",
-				UUID:        "schema_uuid_1",
-				Name:        "new-schema-name",
-				FullName:    "projects/ARGO/schemas/new-schema-name",
-				Type:        JSON,
-				RawSchema:   map[string]interface{}{"type": "string"},
+				ProjectUUID:   "argo_uuid",
+				UUID:          "schema_uuid_1",
+				Name:          "new-schema-name",
+				FullName:      "projects/ARGO/schemas/new-schema-name",
+				Revision:      2,
+				Compatibility: NONE,
+				Type:          JSON,
+				RawSchema:     map[string]interface{}{"type": "string"},
 			},
 			err: nil,
 			queryFunc: func() interface{} {
@@ -240,6 +250,7 @@ func (suite *SchemasTestSuite) TestUpdate() {
 				Name:        "new-schema-name",
 				Type:        JSON,
 				RawSchema:   "eyJ0eXBlIjoic3RyaW5nIn0=",
+				Revision:    2,
 			},
 			msg: "Case where a schema has all its fields successfully updated",
 		},
@@ -330,13 +341,138 @@ func (suite *SchemasTestSuite) TestUpdate() {
 	}
 
 	for _, t := range testData {
-		s, e := Update(suite.ctx, t.existingSchema, t.newName, t.newType, t.newSchema, store)
+		s, e := Update(suite.ctx, t.existingSchema, t.newName, t.newType, "", t.newSchema, nil, store)
 		suite.Equal(t.expectedSchema, s, t.msg)
 		suite.Equal(t.err, e, t.msg)
 		suite.Equal(t.returnQuery, t.queryFunc(), t.msg)
 	}
 }
 
+func (suite *SchemasTestSuite) TestRevisions() {
+
+	store := stores.NewMockStore("", "")
+
+	sl, _ := Find(suite.ctx, "argo_uuid", "", "schema-1", store)
+	existing := sl.Schemas[0]
+
+	// schemas created before revisions existed have none until the startup migration stores their first one
+	rl, err := FindRevisions(suite.ctx, existing, 0, store)
+	suite.Nil(err)
+	suite.True(rl.Empty())
+
+	_, err = MigrateRevisions(suite.ctx, store)
+	suite.Nil(err)
+	rl, err = FindRevisions(suite.ctx, existing, 0, store)
+	suite.Nil(err)
+	suite.Equal(1, len(rl.Revisions))
+	suite.Equal(int64(1), rl.Revisions[0].Revision)
+	suite.Equal(existing.RawSchema, rl.Revisions[0].RawSchema)
+
+	// switch to BACKWARD and make a compatible change by dropping a required property
+	v2 := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"address":   map[string]interface{}{"type": "string"},
+			"email":     map[string]interface{}{"type": "string"},
+			"name":      map[string]interface{}{"type": "string"},
+			"telephone": map[string]interface{}{"type": "string"},
+		},
+		"required": []interface{}{"name"},
+		"type":     "object",
+	}
+	updated, err := Update(suite.ctx, existing, "", "", "backward", v2, nil, store)
+	suite.Nil(err)
+	suite.Equal(int64(2), updated.Revision)
+	suite.Equal(BACKWARD, updated.Compatibility)
+
+	rl, _ = FindRevisions(suite.ctx, updated, 0, store)
+	suite.Equal(2, len(rl.Revisions))
+	suite.Equal(existing.RawSchema, rl.Revisions[0].RawSchema)
+	suite.Equal(v2, rl.Revisions[1].RawSchema)
+
+	// an incompatible change is rejected and leaves the schema intact
+	v3 := map[string]interface{}{"type": "string"}
+	_, err = Update(suite.ctx, updated, "", "", "", v3, nil, store)
+	suite.Equal(errors.New("Schema is not backward compatible with revision 2, $: type 'object' is not allowed"), err)
+
+	sl, _ = Find(suite.ctx, "argo_uuid", "", "schema-1", store)
+	suite.Equal(int64(2), sl.Schemas[0].Revision)
+	suite.Equal(v2, sl.Schemas[0].RawSchema)
+
+	// a rename doesn't create a revision
+	renamed, err := Update(suite.ctx, sl.Schemas[0], "schema-1-renamed", "", "", nil, nil, store)
+	suite.Nil(err)
+	suite.Equal(int64(2), renamed.Revision)
+
+	// rolling back has to respect the compatibility mode like any other change
+	_, err = Rollback(suite.ctx, renamed, 1, store)
+	suite.Equal(errors.New("Schema is not backward compatible with revision 2, $: property 'email' is required"), err)
+
+	sl, _ = Find(suite.ctx, "argo_uuid", "", "schema-1-renamed", store)
+	suite.Equal(int64(2), sl.Schemas[0].Revision)
+	rl, _ = FindRevisions(suite.ctx, sl.Schemas[0], 0, store)
+	suite.Equal(2, len(rl.Revisions))
+
+	renamed, err = Update(suite.ctx, sl.Schemas[0], "", "", "none", nil, nil, store)
+	suite.Nil(err)
+
+	// rolling back creates a new revision with the old content
+	rolledBack, err := Rollback(suite.ctx, renamed, 1, store)
+	suite.Nil(err)
+	suite.Equal(int64(3), rolledBack.Revision)
+	suite.Equal(existing.RawSchema, rolledBack.RawSchema)
+
+	sl, _ = Find(suite.ctx, "argo_uuid", "", "schema-1-renamed", store)
+	suite.Equal(int64(3), sl.Schemas[0].Revision)
+	suite.Equal(existing.RawSchema, sl.Schemas[0].RawSchema)
+
+	rl, _ = FindRevisions(suite.ctx, sl.Schemas[0], 3, store)
+	suite.Equal(1, len(rl.Revisions))
+	suite.Equal(existing.RawSchema, rl.Revisions[0].RawSchema)
+
+	_, err = Rollback(suite.ctx, sl.Schemas[0], 7, store)
+	suite.Equal(errors.New("not found"), err)
+
+	// out of two changes that start from the same revision, the second one is a conflict and leaves the schema intact
+	_, err = Update(suite.ctx, sl.Schemas[0], "", "", "", v2, nil, store)
+	suite.Nil(err)
+	_, err = Rollback(suite.ctx, sl.Schemas[0], 2, store)
+	suite.Equal(errors.New("revision conflict"), err)
+
+	sl, _ = Find(suite.ctx, "argo_uuid", "", "schema-1-renamed", store)
+	suite.Equal(int64(4), sl.Schemas[0].Revision)
+	suite.Equal(v2, sl.Schemas[0].RawSchema)
+
+	_, err = Update(suite.ctx, sl.Schemas[0], "", "", "sometimes", nil, nil, store)
+	suite.Equal(errors.New("unsupported compatibility"), err)
+
+	// deleting the schema removes its revisions
+	suite.Nil(Delete(suite.ctx, sl.Schemas[0].ProjectUUID, sl.Schemas[0].UUID, store))
+	qRevisions, _ := store.QuerySchemaRevisions(suite.ctx, sl.Schemas[0].ProjectUUID, sl.Schemas[0].UUID, 0)
+	suite.Empty(qRevisions)
+}
+
+func (suite *SchemasTestSuite) TestMigrateRevisions() {
+
+	store := stores.NewMockStore("", "")
+
+	// all the schemas of the mock store were created before revisions existed
+	migrated, err := MigrateRevisions(suite.ctx, store)
+	suite.Nil(err)
+	suite.Equal(len(store.SchemaList), migrated)
+
+	sl, _ := Find(suite.ctx, "argo_uuid", "", "schema-1", store)
+	rl, err := FindRevisions(suite.ctx, sl.Schemas[0], 0, store)
+	suite.Nil(err)
+	suite.Equal(1, len(rl.Revisions))
+	suite.NotEqual(int64(0), rl.Revisions[0].ID)
+	suite.Equal(sl.Schemas[0].RawSchema, rl.Revisions[0].RawSchema)
+
+	// running it again finds nothing to migrate
+	migrated, err = MigrateRevisions(suite.ctx, store)
+	suite.Nil(err)
+	suite.Equal(0, migrated)
+}
+
 func (suite *SchemasTestSuite) TestValidateMessages() {
 
 	type td struct {
@@ -476,7 +612,7 @@ func (suite *SchemasTestSuite) TestDelete() {
 
 	store := stores.NewMockStore("", "")
 
-	e1 := Delete(suite.ctx, "schema_uuid_1", store)
+	e1 := Delete(suite.ctx, "argo_uuid", "schema_uuid_1", store)
 	sl, _ := Find(suite.ctx, "argo_uuid", "schema_uuid_1", "", store)
 	qtd, _, _, _ := store.QueryTopics(suite.ctx, "argo_uuid", "", "topic2", "", 1)
 	suite.Equal([]Schema{}, sl.Schemas)
@@ -510,22 +646,26 @@ func (suite *SchemasTestSuite) TestCreate() {
 			rawSchema:   map[string]interface{}{"type": "string"},
 			err:         nil,
 			returnedSchema: Schema{
-				UUID:      "suuid",
-				Name:      "s1",
-				FullName:  "projects/ARGO/schemas/s1",
-				Type:      JSON,
-				RawSchema: map[string]interface{}{"type": "string"},
+				UUID:          "suuid",
+				Name:          "s1",
+				FullName:      "projects/ARGO/schemas/s1",
+				Revision:      1,
+				Compatibility: NONE,
+				Type:          JSON,
+				RawSchema:     map[string]interface{}{"type": "string"},
 			},
 			queryFunc: func() interface{} {
 				qs, _ := store.QuerySchemas(suite.ctx, "argo_uuid", "suuid", "s1")
 				return qs[0]
 			},
 			returnQuery: stores.QSchema{
-				ProjectUUID: "argo_uuid",
-				UUID:        "suuid",
-				Name:        "s1",
-				Type:        JSON,
-				RawSchema:   "eyJ0eXBlIjoic3RyaW5nIn0=",
+				ProjectUUID:   "argo_uuid",
+				UUID:          "suuid",
+				Name:          "s1",
+				Type:          JSON,
+				RawSchema:     "eyJ0eXBlIjoic3RyaW5nIn0=",
+				Revision:      1,
+				Compatibility: NONE,
 			},
 			msg: "Case where the given schema has been validated and saved to the store successfully",
 		},
@@ -546,7 +686,7 @@ func (suite *SchemasTestSuite) TestCreate() {
 	}
 
 	for _, t := range testData {
-		s, e := Create(suite.ctx, t.projectUUID, t.uuid, t.name, t.schemaType, t.rawSchema, store)
+		s, e := Create(suite.ctx, t.projectUUID, t.uuid, t.name, t.schemaType, "", t.rawSchema, nil, store)
 		suite.Equal(t.err, e, t.msg)
 		suite.Equal(t.returnedSchema, s, t.msg)
 		suite.Equal(t.returnQuery, t.queryFunc())
@@ -633,6 +773,23 @@ func (suite *SchemasTestSuite) TestCheckSchema() {
 			err: errors.New("has a primitive type that is NOT VALID -- given: /unknown/ Expected valid values are:[array boolean integer number null object string]"),
 			msg: "Case where the provided schema type is supported but the format of the schema is incorrect(JSON)",
 		},
+		{
+			schemaType: PROTOBUF,
+			schema: map[string]interface{}{
+				"message_type": "user.proto.User",
+				"proto":        "syntax = \"proto3\";\npackage user.proto;\nmessage User { string username = 1; int32 phone = 2; }",
+			},
+			err: nil,
+			msg: "Case where the provided schema type is supported and the format of the schema is correct(PROTOBUF)",
+		},
+		{
+			schemaType: PROTOBUF,
+			schema: map[string]interface{}{
+				"message_type": "user.proto.User",
+			},
+			err: errors.New("protobuf schema should provide either a proto or a descriptor_set"),
+			msg: "Case where the provided schema type is supported but the format of the schema is incorrect(PROTOBUF)",
+		},
 		{
 			schemaType: "unknown",
 			schema: map[string]interface{}{
@@ -644,7 +801,7 @@ func (suite *SchemasTestSuite) TestCheckSchema() {
 	}
 
 	for _, t := range testData {
-		e := checkSchema(t.schemaType, t.schema)
+		e := checkSchema(t.schemaType, t.schema, nil)
 		suite.Equal(t.err, e, t.msg)
 	}
 }
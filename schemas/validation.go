@@ -0,0 +1,255 @@
+package schemas
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/ARGOeu/argo-messaging/messages"
+	"github.com/linkedin/goavro"
+	log "github.com/sirupsen/logrus"
+	"github.com/xeipuuv/gojsonschema"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"regexp"
+	"strings"
+)
+
+// avroFieldPattern matches the record fields that goavro reports while decoding
+var avroFieldPattern = regexp.MustCompile(`field "([^"]+)"`)
+
+// ValidationError describes why a message is not valid, along with the location in the payload when it is known
+type ValidationError struct {
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+// MessageValidation holds the validation outcome of a single message
+type MessageValidation struct {
+	Index  int               `json:"index"`
+	Valid  bool              `json:"valid"`
+	Errors []ValidationError `json:"errors,omitempty"`
+}
+
+// ValidationReport holds the validation outcome of every message of a message list
+type ValidationReport struct {
+	Valid    bool                `json:"valid"`
+	Messages []MessageValidation `json:"messages"`
+}
+
+// Invalid returns the outcomes of the messages that are not valid
+func (vr *ValidationReport) Invalid() []MessageValidation {
+
+	invalid := []MessageValidation{}
+	for _, m := range vr.Messages {
+		if !m.Valid {
+			invalid = append(invalid, m)
+		}
+	}
+
+	return invalid
+}
+
+// validator holds the compiled definition of a schema so that it can validate messages
+type validator struct {
+	schema       Schema
+	jsonSchema   *gojsonschema.Schema
+	avroCodec    *goavro.Codec
+	protoMessage protoreflect.MessageDescriptor
+}
+
+// newValidator compiles the definition of the schema.
+// A schema that can't be compiled results in a "500" error, since it should have been checked when it was stored.
+func newValidator(schema Schema) (*validator, error) {
+
+	v := &validator{schema: schema}
+
+	switch schema.Type {
+	case JSON:
+		s, err := compileJSONSchema(schema.RawSchema, schema.dependencies)
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":        "service_log",
+					"schema_name": schema.FullName,
+					"error":       err.Error(),
+				},
+			).Error("Could not load json schema")
+			return nil, errors.New("500")
+		}
+		v.jsonSchema = s
+
+	case AVRO:
+		c, err := compileAvroSchema(schema.RawSchema, schema.dependencies)
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":        "service_log",
+					"schema_name": schema.FullName,
+					"error":       err.Error(),
+				},
+			).Error("Could not load avro schema")
+			return nil, errors.New("500")
+		}
+		v.avroCodec = c
+
+	case PROTOBUF:
+		md, err := compileProtobuf(schema.RawSchema, schema.dependencies)
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":        "service_log",
+					"schema_name": schema.FullName,
+					"error":       err.Error(),
+				},
+			).Error("Could not load protobuf schema")
+			return nil, errors.New("500")
+		}
+		v.protoMessage = md
+
+	default:
+		log.WithFields(
+			log.Fields{
+				"type":        "service_log",
+				"schema_name": schema.FullName,
+				"schema_type": schema.Type,
+			},
+		).Error("Schema with unsupported type")
+		return nil, errors.New("500")
+	}
+
+	return v, nil
+}
+
+// validator returns the compiled definition of the schema, compiling it unless the schema comes from the cache
+func (s Schema) validator() (*validator, error) {
+
+	if s.compiled != nil {
+		return s.compiled, nil
+	}
+
+	return newValidator(s)
+}
+
+// validate validates the base64 encoded payload of the message with the given index.
+// Along with the outcome it returns an error that summarizes why the message is not valid.
+func (v *validator) validate(idx int, data string) (MessageValidation, error) {
+
+	result := MessageValidation{Index: idx, Valid: true}
+
+	invalid := func(summary error, errs ...ValidationError) (MessageValidation, error) {
+		result.Valid = false
+		result.Errors = errs
+		return result, summary
+	}
+
+	// decode the message payload from base64
+	payload, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return invalid(fmt.Errorf("Message %v is not in valid base64 enocding,%s", idx, err.Error()),
+			ValidationError{Message: fmt.Sprintf("payload is not in valid base64 encoding, %s", err.Error())})
+	}
+
+	switch v.schema.Type {
+	case JSON:
+		res, err := v.jsonSchema.Validate(gojsonschema.NewBytesLoader(payload))
+		if err != nil {
+			return invalid(fmt.Errorf("Message %v data is not valid JSON format,%s", idx, err.Error()),
+				ValidationError{Message: fmt.Sprintf("payload is not valid JSON, %s", err.Error())})
+		}
+
+		if res.Valid() {
+			return result, nil
+		}
+
+		errs := []ValidationError{}
+		for _, e := range res.Errors() {
+			errs = append(errs, ValidationError{Path: jsonErrorPath(e), Message: e.Description()})
+		}
+
+		if len(res.Errors()) > 1 {
+			sb := strings.Builder{}
+
+			for i, e := range res.Errors() {
+				sb.WriteString(fmt.Sprintf("%v)%s.", i+1, e.String()))
+			}
+
+			return invalid(fmt.Errorf("Message %v data is not valid.%s", idx, sb.String()), errs...)
+		}
+
+		return invalid(fmt.Errorf("Message %v data is not valid,%v", idx, res.Errors()[0].String()), errs...)
+
+	case AVRO:
+		_, _, err := v.avroCodec.NativeFromBinary(payload)
+		if err != nil {
+			return invalid(fmt.Errorf("Message %v is not valid.%s", idx, err.Error()),
+				ValidationError{Path: avroErrorPath(err), Message: err.Error()})
+		}
+
+	case PROTOBUF:
+		err := unmarshalProtobufStrict(v.protoMessage, payload)
+		if err != nil {
+			vErr := ValidationError{Message: err.Error()}
+			if ufErr, ok := err.(unknownFieldsError); ok {
+				vErr = ValidationError{Path: ufErr.path, Message: "contains unknown fields"}
+			}
+			return invalid(fmt.Errorf("Message %v is not valid.%s", idx, err.Error()), vErr)
+		}
+	}
+
+	return result, nil
+}
+
+// jsonErrorPath returns the location of a json schema validation error, using $ for the root of the document.
+// Missing required properties are reported at the location of the property itself.
+func jsonErrorPath(e gojsonschema.ResultError) string {
+
+	path := "$" + strings.TrimPrefix(e.Context().String(), gojsonschema.STRING_ROOT_SCHEMA_PROPERTY)
+
+	if e.Type() == "required" {
+		if property, ok := e.Details()["property"].(string); ok {
+			path = fmt.Sprintf("%s.%s", path, property)
+		}
+	}
+
+	return path
+}
+
+// avroErrorPath returns the location of an avro decoding error, from the record fields that the error mentions
+func avroErrorPath(err error) string {
+
+	matches := avroFieldPattern.FindAllStringSubmatch(err.Error(), -1)
+	if len(matches) == 0 {
+		return ""
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("$")
+	for _, m := range matches {
+		sb.WriteString(".")
+		sb.WriteString(m[1])
+	}
+
+	return sb.String()
+}
+
+// ValidateMessagesReport validates every message of the list against the provided schema and reports the outcome of each one.
+// The returned error is only set when the schema itself can't be used.
+func ValidateMessagesReport(schema Schema, msgList messages.MsgList) (ValidationReport, error) {
+
+	report := ValidationReport{
+		Valid:    true,
+		Messages: []MessageValidation{},
+	}
+
+	v, err := schema.validator()
+	if err != nil {
+		return ValidationReport{}, err
+	}
+
+	for idx, msg := range msgList.Msgs {
+		result, _ := v.validate(idx, msg.Data)
+		report.Valid = report.Valid && result.Valid
+		report.Messages = append(report.Messages, result)
+	}
+
+	return report, nil
+}
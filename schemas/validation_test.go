@@ -0,0 +1,110 @@
+package schemas
+
+import (
+	"errors"
+	"github.com/ARGOeu/argo-messaging/messages"
+)
+
+func (suite *SchemasTestSuite) TestValidateMessagesReport() {
+
+	jsonSchema := Schema{
+		Type: JSON,
+		RawSchema: map[string]interface{}{
+			"properties": map[string]interface{}{
+				"email":     map[string]interface{}{"type": "string"},
+				"name":      map[string]interface{}{"type": "string"},
+				"telephone": map[string]interface{}{"type": "string"},
+			},
+			"required": []interface{}{"name", "email"},
+			"type":     "object",
+		},
+	}
+
+	msgList := messages.MsgList{
+		Msgs: []messages.Message{
+			// {"name":"name-1", "email": "test@example.com"}
+			{Data: "eyJuYW1lIjoibmFtZS0xIiwgImVtYWlsIjogInRlc3RAZXhhbXBsZS5jb20ifQ=="},
+			// {"name":"name-1","address":"Street 13","telephone":6948567889}
+			{Data: "eyJuYW1lIjoibmFtZS0xIiwiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6Njk0ODU2Nzg4OX0="},
+			{Data: "not-base64"},
+			// {"name":"name-1","address":"Street 13","telephone":"6948567889"
+			{Data: "eyJuYW1lIjoibmFtZS0xIiwiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6IjY5NDg1Njc4ODkiCg=="},
+		},
+	}
+
+	report, err := ValidateMessagesReport(jsonSchema, msgList)
+	suite.Nil(err)
+	suite.Equal(ValidationReport{
+		Valid: false,
+		Messages: []MessageValidation{
+			{Index: 0, Valid: true},
+			{Index: 1, Valid: false, Errors: []ValidationError{
+				{Path: "$.email", Message: "email is required"},
+				{Path: "$.telephone", Message: "Invalid type. Expected: string, given: integer"},
+			}},
+			{Index: 2, Valid: false, Errors: []ValidationError{
+				{Message: "payload is not in valid base64 encoding, illegal base64 data at input byte 3"},
+			}},
+			{Index: 3, Valid: false, Errors: []ValidationError{
+				{Message: "payload is not valid JSON, unexpected EOF"},
+			}},
+		},
+	}, report)
+	suite.Equal([]int{1, 2, 3}, indices(report.Invalid()))
+
+	avroSchema := Schema{
+		Type: AVRO,
+		RawSchema: map[string]interface{}{
+			"namespace": "user.avro",
+			"type":      "record",
+			"name":      "User",
+			"fields": []interface{}{
+				map[string]interface{}{"name": "username", "type": "string"},
+				map[string]interface{}{"name": "phone", "type": "int"},
+			},
+		},
+	}
+
+	report, err = ValidateMessagesReport(avroSchema, messages.MsgList{Msgs: []messages.Message{
+		// username: agelos, phone: 89890
+		{Data: "DGFnZWxvc8T8Cg=="},
+		{Data: "YmFzZTY0ZW5jb2RlZA=="},
+	}})
+	suite.Nil(err)
+	suite.False(report.Valid)
+	suite.True(report.Messages[0].Valid)
+	suite.Equal("$.username", report.Messages[1].Errors[0].Path)
+
+	protoSchema := Schema{
+		Type: PROTOBUF,
+		RawSchema: map[string]interface{}{
+			"message_type": "user.proto.User",
+			"proto":        testProtoSource,
+		},
+	}
+
+	report, err = ValidateMessagesReport(protoSchema, messages.MsgList{Msgs: []messages.Message{
+		// username: joe, address: {2: 1}
+		{Data: "CgNqb2UaAhAB"},
+	}})
+	suite.Nil(err)
+	suite.Equal([]ValidationError{{Path: "$.address", Message: "contains unknown fields"}}, report.Messages[0].Errors)
+
+	// every message is valid
+	report, err = ValidateMessagesReport(protoSchema, messages.MsgList{Msgs: []messages.Message{{Data: "CgNqb2UQBQ=="}}})
+	suite.Nil(err)
+	suite.True(report.Valid)
+	suite.Empty(report.Invalid())
+
+	// a schema that can't be compiled results in an internal error
+	_, err = ValidateMessagesReport(Schema{Type: PROTOBUF, RawSchema: map[string]interface{}{}}, msgList)
+	suite.Equal(errors.New("500"), err)
+}
+
+func indices(results []MessageValidation) []int {
+	idx := []int{}
+	for _, r := range results {
+		idx = append(idx, r.Index)
+	}
+	return idx
+}
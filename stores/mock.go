@@ -5,25 +5,39 @@ import (
 	"errors"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // MockStore holds configuration
 type MockStore struct {
-	Server             string
-	Database           string
-	UserRegistrations  []QUserRegistration
-	SubList            []QSub
-	TopicList          []QTopic
-	DailyTopicMsgCount []QDailyTopicMsgCount
-	ProjectList        []QProject
-	UserList           []QUser
-	RoleList           []QRole
-	SchemaList         []QSchema
-	Session            bool
-	TopicsACL          map[string]QAcl
-	SubsACL            map[string]QAcl
-	OpMetrics          map[string]QopMetric
+	Server              string
+	Database            string
+	UserRegistrations   []QUserRegistration
+	SubList             []QSub
+	TopicList           []QTopic
+	DailyTopicMsgCount  []QDailyTopicMsgCount
+	ProjectList         []QProject
+	UserList            []QUser
+	RoleList            []QRole
+	SchemaList          []QSchema
+	SchemaRevisions     []QSchemaRevision
+	SchemaRevisionSeq   int64
+	Session             bool
+	TopicsACL           map[string]QAcl
+	SubsACL             map[string]QAcl
+	OpMetrics           map[string]QopMetric
+	ScheduledMessages   []QScheduledMessage
+	IdempotencyKeys     []QIdempotencyKey
+	APIKeys             []QAPIKey
+	GroupList           []QGroup
+	AuditEntries        []QAuditEntry
+	RateLimits          []QRateLimits
+	RateCounters        []QRateCounter
+	ProjectQuotas       []QProjectQuota
+	DailyProjectUsage   []QDailyProjectUsage
+	NotificationTmpls   []QNotificationTemplate
+	SchemaInvalidations []QSchemaInvalidation
 }
 
 func (mk *MockStore) TopicsCount(ctx context.Context, startDate, endDate time.Time, projectUUIDs []string) (map[string]int64, error) {
@@ -272,6 +286,19 @@ func (mk *MockStore) UpdateRegistration(ctx context.Context, regUUID, status, de
 	return nil
 }
 
+// ConfirmRegistrationEmail marks the email of a registration as confirmed
+func (mk *MockStore) ConfirmRegistrationEmail(ctx context.Context, regUUID, confirmedAt string) error {
+
+	for idx, ur := range mk.UserRegistrations {
+		if ur.UUID == regUUID {
+			mk.UserRegistrations[idx].EmailConfirmedAt = confirmedAt
+			return nil
+		}
+	}
+
+	return errors.New("not found")
+}
+
 // GetAllRoles returns a list of all available roles
 func (mk *MockStore) GetAllRoles(ctx context.Context) []string {
 	return []string{"service_admin", "admin", "project_admin", "viewer", "consumer", "producer", "publisher", "push_worker"}
@@ -290,6 +317,33 @@ func (mk *MockStore) UpdateUserToken(ctx context.Context, uuid string, token str
 
 }
 
+// UpdateUserDN updates the certificate subject and issuer of the user
+func (mk *MockStore) UpdateUserDN(ctx context.Context, uuid string, dn string, issuerDN string) error {
+	for i, item := range mk.UserList {
+		if item.UUID == uuid {
+			mk.UserList[i].DN = dn
+			mk.UserList[i].IssuerDN = issuerDN
+			return nil
+		}
+	}
+
+	return errors.New("not found")
+
+}
+
+// UpdateUserOIDCSubject updates the OIDC subject of the user
+func (mk *MockStore) UpdateUserOIDCSubject(ctx context.Context, uuid string, subject string) error {
+	for i, item := range mk.UserList {
+		if item.UUID == uuid {
+			mk.UserList[i].OIDCSubject = subject
+			return nil
+		}
+	}
+
+	return errors.New("not found")
+
+}
+
 // GetOpMetrics returns operation metrics
 func (mk *MockStore) GetOpMetrics(ctx context.Context) []QopMetric {
 	results := []QopMetric{}
@@ -398,9 +452,9 @@ func (mk *MockStore) HasUsers(ctx context.Context, projectUUID string, users []s
 	return len(notFound) == 0, notFound
 }
 
-// ModACL changes the acl in a function
-func (mk *MockStore) ModACL(ctx context.Context, projectUUID string, resource string, name string, acl []string) error {
-	newACL := QAcl{ACL: acl}
+// ModACL replaces the acl and the actions that its entries are restricted to
+func (mk *MockStore) ModACL(ctx context.Context, projectUUID string, resource string, name string, acl []string, actions map[string][]string) error {
+	newACL := QAcl{ACL: acl, Actions: actions}
 	if resource == "topics" {
 		if _, exists := mk.TopicsACL[name]; exists {
 			mk.TopicsACL[name] = newACL
@@ -458,12 +512,14 @@ func (mk *MockStore) RemoveFromACL(ctx context.Context, projectUUID string, reso
 	if resource == "topics" {
 		if qACL, exists := mk.TopicsACL[name]; exists {
 			qACL.ACL = removeValues(qACL.ACL, acl...)
+			qACL.Actions = removeACLActions(qACL.Actions, acl...)
 			mk.TopicsACL[name] = qACL
 			return nil
 		}
 	} else if resource == "subscriptions" {
 		if qACL, exists := mk.SubsACL[name]; exists {
 			qACL.ACL = removeValues(qACL.ACL, acl...)
+			qACL.Actions = removeACLActions(qACL.Actions, acl...)
 			mk.SubsACL[name] = qACL
 			return nil
 		}
@@ -494,6 +550,312 @@ func removeSingleValue(existingValues []string, valueToRemove string) []string {
 	return existingValues
 }
 
+// removeACLActions drops the action restrictions of the given acl entries
+func removeACLActions(actions map[string][]string, entries ...string) map[string][]string {
+	if len(actions) == 0 {
+		return actions
+	}
+
+	remaining := make(map[string][]string)
+	for entry, entryActions := range actions {
+		remaining[entry] = entryActions
+	}
+	for _, entry := range entries {
+		delete(remaining, entry)
+	}
+
+	return remaining
+}
+
+// InsertGroup stores a new group of users of a project
+func (mk *MockStore) InsertGroup(ctx context.Context, group QGroup) error {
+	mk.GroupList = append(mk.GroupList, group)
+	return nil
+}
+
+// QueryGroups returns the groups of a project, or a specific one when a uuid or a name is given
+func (mk *MockStore) QueryGroups(ctx context.Context, projectUUID string, uuid string, name string) ([]QGroup, error) {
+	result := []QGroup{}
+	for _, item := range mk.GroupList {
+		if item.ProjectUUID != projectUUID {
+			continue
+		}
+		if (uuid == "" || item.UUID == uuid) && (name == "" || item.Name == name) {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// AppendToGroup adds the given users to the members of an existing group
+func (mk *MockStore) AppendToGroup(ctx context.Context, projectUUID string, name string, members []string) error {
+	for i, item := range mk.GroupList {
+		if item.ProjectUUID == projectUUID && item.Name == name {
+			mk.GroupList[i].Members = appendUniqueValues(append([]string{}, item.Members...), members...)
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+// RemoveFromGroup removes the given users from the members of an existing group
+func (mk *MockStore) RemoveFromGroup(ctx context.Context, projectUUID string, name string, members []string) error {
+	for i, item := range mk.GroupList {
+		if item.ProjectUUID == projectUUID && item.Name == name {
+			mk.GroupList[i].Members = removeValues(append([]string{}, item.Members...), members...)
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+// RemoveGroups removes a group of a project, or all of them when no name is given
+func (mk *MockStore) RemoveGroups(ctx context.Context, projectUUID string, name string) error {
+	remaining := []QGroup{}
+	for _, item := range mk.GroupList {
+		if item.ProjectUUID == projectUUID && (name == "" || item.Name == name) {
+			continue
+		}
+		remaining = append(remaining, item)
+	}
+
+	if name != "" && len(remaining) == len(mk.GroupList) {
+		return errors.New("not found")
+	}
+
+	mk.GroupList = remaining
+	return nil
+}
+
+// isGroupMember checks if an acl entry references a group that the user is a member of
+func (mk *MockStore) isGroupMember(entry string, userUUID string) bool {
+	if !strings.HasPrefix(entry, GroupACLPrefix) {
+		return false
+	}
+	for _, item := range mk.GroupList {
+		if GroupACLEntry(item.UUID) != entry {
+			continue
+		}
+		for _, member := range item.Members {
+			if member == userUUID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// InsertAuditEntry stores a new entry of the audit log
+func (mk *MockStore) InsertAuditEntry(ctx context.Context, entry QAuditEntry) error {
+	entry.ID = len(mk.AuditEntries) + 1
+	mk.AuditEntries = append(mk.AuditEntries, entry)
+	return nil
+}
+
+// QueryAuditEntries returns the entries of the audit log that pass the filter, the most recent first
+func (mk *MockStore) QueryAuditEntries(ctx context.Context, filter QAuditFilter, pageToken string, pageSize int64) ([]QAuditEntry, int64, string, error) {
+
+	qEntries := []QAuditEntry{}
+	var nextPageToken string
+	var totalSize int64
+	var pg int
+	var err error
+
+	if pageToken != "" {
+		if pg, err = strconv.Atoi(pageToken); err != nil {
+			return qEntries, totalSize, nextPageToken, err
+		}
+	}
+
+	for i := len(mk.AuditEntries) - 1; i >= 0; i-- {
+		entry := mk.AuditEntries[i]
+		if !filter.matches(entry) {
+			continue
+		}
+
+		totalSize++
+
+		if pageToken != "" && entry.ID.(int) > pg {
+			continue
+		}
+
+		if pageSize > 0 && int64(len(qEntries)) == pageSize {
+			if nextPageToken == "" {
+				nextPageToken = strconv.Itoa(entry.ID.(int))
+			}
+			continue
+		}
+
+		qEntries = append(qEntries, entry)
+	}
+
+	return qEntries, totalSize, nextPageToken, nil
+}
+
+// QueryRateLimits returns the rate limits of a project or a user
+func (mk *MockStore) QueryRateLimits(ctx context.Context, scopeType string, scopeUUID string) ([]QRateLimit, error) {
+	for _, item := range mk.RateLimits {
+		if item.ScopeType == scopeType && item.ScopeUUID == scopeUUID {
+			return item.Limits, nil
+		}
+	}
+	return []QRateLimit{}, nil
+}
+
+// UpdateRateLimits replaces the rate limits of a project or a user, no limits remove them altogether
+func (mk *MockStore) UpdateRateLimits(ctx context.Context, scopeType string, scopeUUID string, limits []QRateLimit) error {
+	remaining := []QRateLimits{}
+	for _, item := range mk.RateLimits {
+		if item.ScopeType != scopeType || item.ScopeUUID != scopeUUID {
+			remaining = append(remaining, item)
+		}
+	}
+
+	if len(limits) > 0 {
+		remaining = append(remaining, QRateLimits{ScopeType: scopeType, ScopeUUID: scopeUUID, Limits: limits})
+	}
+
+	mk.RateLimits = remaining
+	return nil
+}
+
+// IncrementRateCounter charges the counter of a rate limit for a window of time and returns its new state
+func (mk *MockStore) IncrementRateCounter(ctx context.Context, key string, window time.Time, requests int64, bytes int64, expiresOn time.Time) (QRateCounter, error) {
+	for i, item := range mk.RateCounters {
+		if item.Key == key && item.Window.Equal(window) {
+			mk.RateCounters[i].Requests += requests
+			mk.RateCounters[i].Bytes += bytes
+			return mk.RateCounters[i], nil
+		}
+	}
+
+	counter := QRateCounter{Key: key, Window: window, Requests: requests, Bytes: bytes, ExpiresOn: expiresOn}
+	mk.RateCounters = append(mk.RateCounters, counter)
+	return counter, nil
+}
+
+// QueryProjectQuota returns the quotas of a project, a project without quotas has none of its values set
+func (mk *MockStore) QueryProjectQuota(ctx context.Context, projectUUID string) (QProjectQuota, error) {
+	for _, item := range mk.ProjectQuotas {
+		if item.ProjectUUID == projectUUID {
+			return item, nil
+		}
+	}
+	return QProjectQuota{ProjectUUID: projectUUID}, nil
+}
+
+// UpdateProjectQuota replaces the quotas of a project
+func (mk *MockStore) UpdateProjectQuota(ctx context.Context, quota QProjectQuota) error {
+	for i, item := range mk.ProjectQuotas {
+		if item.ProjectUUID == quota.ProjectUUID {
+			mk.ProjectQuotas[i] = quota
+			return nil
+		}
+	}
+	mk.ProjectQuotas = append(mk.ProjectQuotas, quota)
+	return nil
+}
+
+// QueryDailyProjectUsage returns the messages and bytes published to all of a project's topics during a day
+func (mk *MockStore) QueryDailyProjectUsage(ctx context.Context, projectUUID string, date time.Time) (QDailyProjectUsage, error) {
+	for _, item := range mk.DailyProjectUsage {
+		if item.ProjectUUID == projectUUID && item.Date.Equal(date) {
+			return item, nil
+		}
+	}
+	return QDailyProjectUsage{ProjectUUID: projectUUID, Date: date}, nil
+}
+
+// IncrementDailyProjectUsage increases the messages and bytes published to all of a project's topics during a day
+func (mk *MockStore) IncrementDailyProjectUsage(ctx context.Context, projectUUID string, date time.Time, messages int64, bytes int64) error {
+	for i, item := range mk.DailyProjectUsage {
+		if item.ProjectUUID == projectUUID && item.Date.Equal(date) {
+			mk.DailyProjectUsage[i].Messages += messages
+			mk.DailyProjectUsage[i].Bytes += bytes
+			return nil
+		}
+	}
+	mk.DailyProjectUsage = append(mk.DailyProjectUsage, QDailyProjectUsage{ProjectUUID: projectUUID, Date: date, Messages: messages, Bytes: bytes})
+	return nil
+}
+
+// ReserveDailyProjectUsage increases the messages and bytes published to all of a project's topics during a day,
+// only if they stay within the given maximums, 0 meaning no maximum. It reports whether they have been increased
+func (mk *MockStore) ReserveDailyProjectUsage(ctx context.Context, projectUUID string, date time.Time, messages int64, bytes int64,
+	maxMessages int64, maxBytes int64) (bool, error) {
+	qUsage, _ := mk.QueryDailyProjectUsage(ctx, projectUUID, date)
+	if (maxMessages > 0 && qUsage.Messages+messages > maxMessages) || (maxBytes > 0 && qUsage.Bytes+bytes > maxBytes) {
+		return false, nil
+	}
+	return true, mk.IncrementDailyProjectUsage(ctx, projectUUID, date, messages, bytes)
+}
+
+// RemoveProjectQuota removes the quotas of a project along with its daily usage
+func (mk *MockStore) RemoveProjectQuota(ctx context.Context, projectUUID string) error {
+	quotas := []QProjectQuota{}
+	for _, item := range mk.ProjectQuotas {
+		if item.ProjectUUID != projectUUID {
+			quotas = append(quotas, item)
+		}
+	}
+	mk.ProjectQuotas = quotas
+
+	usage := []QDailyProjectUsage{}
+	for _, item := range mk.DailyProjectUsage {
+		if item.ProjectUUID != projectUUID {
+			usage = append(usage, item)
+		}
+	}
+	mk.DailyProjectUsage = usage
+	return nil
+}
+
+// QueryNotificationTemplates returns the edited notification templates, all of them when no name is given
+func (mk *MockStore) QueryNotificationTemplates(ctx context.Context, name string) ([]QNotificationTemplate, error) {
+	results := []QNotificationTemplate{}
+	for _, item := range mk.NotificationTmpls {
+		if name == "" || item.Name == name {
+			results = append(results, item)
+		}
+	}
+	return results, nil
+}
+
+// UpdateNotificationTemplate creates or replaces an edited notification template
+func (mk *MockStore) UpdateNotificationTemplate(ctx context.Context, template QNotificationTemplate) error {
+	for i, item := range mk.NotificationTmpls {
+		if item.Name == template.Name {
+			mk.NotificationTmpls[i] = template
+			return nil
+		}
+	}
+	mk.NotificationTmpls = append(mk.NotificationTmpls, template)
+	return nil
+}
+
+// DeleteNotificationTemplate removes an edited notification template, restoring its default
+func (mk *MockStore) DeleteNotificationTemplate(ctx context.Context, name string) error {
+	templates := []QNotificationTemplate{}
+	for _, item := range mk.NotificationTmpls {
+		if item.Name != name {
+			templates = append(templates, item)
+		}
+	}
+	mk.NotificationTmpls = templates
+	return nil
+}
+
+// aclEntries returns the acl entries that refer to a user, the user itself and the groups they are a member of
+func (mk *MockStore) aclEntries(userUUID string) []string {
+	entries := []string{userUUID}
+	for _, item := range mk.GroupList {
+		if mk.isGroupMember(GroupACLEntry(item.UUID), userUUID) {
+			entries = append(entries, GroupACLEntry(item.UUID))
+		}
+	}
+	return entries
+}
+
 // UpdateProject updates project information
 func (mk *MockStore) UpdateProject(ctx context.Context, projectUUID string, name string, description string, modifiedOn time.Time) error {
 
@@ -589,6 +951,18 @@ func (mk *MockStore) IncrementTopicBytes(ctx context.Context, projectUUID string
 	return errors.New("not found")
 }
 
+// IncrementTopicStoredBytes increases the total number of bytes stored in the broker for a topic
+func (mk *MockStore) IncrementTopicStoredBytes(ctx context.Context, projectUUID string, name string, storedBytes int64) error {
+	for i, item := range mk.TopicList {
+		if item.ProjectUUID == projectUUID && item.Name == name {
+			mk.TopicList[i].StoredBytes += storedBytes
+			return nil
+		}
+	}
+
+	return errors.New("not found")
+}
+
 // IncrementSubBytes increases the total number of bytes published in a subscription
 func (mk *MockStore) IncrementSubBytes(ctx context.Context, projectUUID string, name string, totalBytes int64) error {
 	for i, item := range mk.SubList {
@@ -601,6 +975,18 @@ func (mk *MockStore) IncrementSubBytes(ctx context.Context, projectUUID string,
 	return errors.New("not found")
 }
 
+// IncrementSubStoredBytes increases the total number of bytes consumed from a subscription, as stored in the broker
+func (mk *MockStore) IncrementSubStoredBytes(ctx context.Context, projectUUID string, name string, storedBytes int64) error {
+	for i, item := range mk.SubList {
+		if item.ProjectUUID == projectUUID && item.Name == name {
+			mk.SubList[i].StoredBytes += storedBytes
+			return nil
+		}
+	}
+
+	return errors.New("not found")
+}
+
 // IncrementSubMsgNum increase number of messages pulled in a subscription
 func (mk *MockStore) IncrementSubMsgNum(ctx context.Context, projectUUID string, name string, num int64) error {
 
@@ -614,6 +1000,19 @@ func (mk *MockStore) IncrementSubMsgNum(ctx context.Context, projectUUID string,
 	return errors.New("not found")
 }
 
+// IncrementSubExpiredMsgNum increase number of expired messages skipped in a subscription
+func (mk *MockStore) IncrementSubExpiredMsgNum(ctx context.Context, projectUUID string, name string, num int64) error {
+
+	for i, item := range mk.SubList {
+		if item.ProjectUUID == projectUUID && item.Name == name {
+			mk.SubList[i].ExpiredMsgNum += num
+			return nil
+		}
+	}
+
+	return errors.New("not found")
+}
+
 // UpdateSubOffset updates the offset of the current subscription
 func (mk *MockStore) UpdateSubOffset(ctx context.Context, projectUUID string, name string, offset int64) {
 
@@ -632,6 +1031,18 @@ func (mk *MockStore) ModAck(ctx context.Context, projectUUID string, name string
 	return errors.New("not found")
 }
 
+// ModSubOutputFormat modifies the format that the subscription delivers its messages in
+func (mk *MockStore) ModSubOutputFormat(ctx context.Context, projectUUID string, name string, outputFormat string) error {
+	for i, item := range mk.SubList {
+		if item.ProjectUUID == projectUUID && item.Name == name {
+			mk.SubList[i].OutputFormat = outputFormat
+			return nil
+		}
+	}
+
+	return errors.New("not found")
+}
+
 // ModSubPush modifies the subscription push configuration
 func (mk *MockStore) ModSubPush(ctx context.Context, projectUUID string, name string, config QPushConfig) error {
 	for i, item := range mk.SubList {
@@ -648,6 +1059,7 @@ func (mk *MockStore) ModSubPush(ctx context.Context, projectUUID string, name st
 			mk.SubList[i].MattermostUrl = config.MattermostUrl
 			mk.SubList[i].MattermostUsername = config.MattermostUsername
 			mk.SubList[i].MattermostChannel = config.MattermostChannel
+			mk.SubList[i].SchemaDecode = config.SchemaDecode
 			return nil
 		}
 	}
@@ -857,15 +1269,256 @@ func (mk *MockStore) UpdateSubPull(ctx context.Context, projectUUID string, name
 
 }
 
+// InsertScheduledMessage holds back a message of a subscription until its delivery time
+func (mk *MockStore) InsertScheduledMessage(ctx context.Context, projectUUID string, subscription string, offset int64, deliverAfter time.Time, message string) error {
+	for _, item := range mk.ScheduledMessages {
+		if item.ProjectUUID == projectUUID && item.Subscription == subscription && item.Offset == offset {
+			return nil
+		}
+	}
+
+	mk.ScheduledMessages = append(mk.ScheduledMessages, QScheduledMessage{
+		ProjectUUID:  projectUUID,
+		Subscription: subscription,
+		Offset:       offset,
+		DeliverAfter: deliverAfter,
+		Message:      message,
+	})
+	return nil
+}
+
+// QueryDueScheduledMessages returns the scheduled messages of a subscription that are due and not pending an ack
+func (mk *MockStore) QueryDueScheduledMessages(ctx context.Context, projectUUID string, subscription string, maxOffset int64, dueBefore time.Time, deliveredBefore time.Time, limit int64) ([]QScheduledMessage, error) {
+
+	result := []QScheduledMessage{}
+	for _, item := range mk.ScheduledMessages {
+		if item.ProjectUUID == projectUUID && item.Subscription == subscription && item.Offset < maxOffset &&
+			!item.DeliverAfter.After(dueBefore) && !item.DeliveredAt.After(deliveredBefore) {
+			result = append(result, item)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].DeliverAfter.Equal(result[j].DeliverAfter) {
+			return result[i].Offset < result[j].Offset
+		}
+		return result[i].DeliverAfter.Before(result[j].DeliverAfter)
+	})
+
+	if limit > 0 && int64(len(result)) > limit {
+		result = result[:limit]
+	}
+
+	return result, nil
+}
+
+// UpdateScheduledMessagesDelivery marks the given scheduled messages as delivered at the given time
+func (mk *MockStore) UpdateScheduledMessagesDelivery(ctx context.Context, projectUUID string, subscription string, offsets []int64, deliveredAt time.Time) error {
+	for i, item := range mk.ScheduledMessages {
+		if item.ProjectUUID == projectUUID && item.Subscription == subscription && containsOffset(offsets, item.Offset) {
+			mk.ScheduledMessages[i].DeliveredAt = deliveredAt
+		}
+	}
+	return nil
+}
+
+// AckScheduledMessages removes the delivered scheduled messages that match the given offsets
+// and returns the offsets that got acknowledged
+func (mk *MockStore) AckScheduledMessages(ctx context.Context, projectUUID string, subscription string, offsets []int64) ([]int64, error) {
+
+	acked := []int64{}
+	remaining := []QScheduledMessage{}
+	for _, item := range mk.ScheduledMessages {
+		if item.ProjectUUID == projectUUID && item.Subscription == subscription &&
+			!item.DeliveredAt.IsZero() && containsOffset(offsets, item.Offset) {
+			acked = append(acked, item.Offset)
+			continue
+		}
+		remaining = append(remaining, item)
+	}
+
+	mk.ScheduledMessages = remaining
+	return acked, nil
+}
+
+// DeleteScheduledMessages removes the scheduled messages of a subscription that match the given offsets
+func (mk *MockStore) DeleteScheduledMessages(ctx context.Context, projectUUID string, subscription string, offsets []int64) error {
+	remaining := []QScheduledMessage{}
+	for _, item := range mk.ScheduledMessages {
+		if item.ProjectUUID == projectUUID && item.Subscription == subscription && containsOffset(offsets, item.Offset) {
+			continue
+		}
+		remaining = append(remaining, item)
+	}
+
+	mk.ScheduledMessages = remaining
+	return nil
+}
+
+// CountScheduledMessages returns the number of scheduled messages of a subscription that are due after the given time
+func (mk *MockStore) CountScheduledMessages(ctx context.Context, projectUUID string, subscription string, dueAfter time.Time) (int64, error) {
+	var count int64
+	for _, item := range mk.ScheduledMessages {
+		if item.ProjectUUID == projectUUID && item.Subscription == subscription && item.DeliverAfter.After(dueAfter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// RemoveSubScheduledMessages removes all the scheduled messages of a subscription
+func (mk *MockStore) RemoveSubScheduledMessages(ctx context.Context, projectUUID string, subscription string) error {
+	remaining := []QScheduledMessage{}
+	for _, item := range mk.ScheduledMessages {
+		if item.ProjectUUID == projectUUID && item.Subscription == subscription {
+			continue
+		}
+		remaining = append(remaining, item)
+	}
+
+	mk.ScheduledMessages = remaining
+	return nil
+}
+
+// RemoveProjectScheduledMessages removes all the scheduled messages of a project's subscriptions
+func (mk *MockStore) RemoveProjectScheduledMessages(ctx context.Context, projectUUID string) error {
+	remaining := []QScheduledMessage{}
+	for _, item := range mk.ScheduledMessages {
+		if item.ProjectUUID == projectUUID {
+			continue
+		}
+		remaining = append(remaining, item)
+	}
+
+	mk.ScheduledMessages = remaining
+	return nil
+}
+
+// ReserveIdempotencyKey stores the given key of a topic as pending, unless it is already stored and has not expired
+func (mk *MockStore) ReserveIdempotencyKey(ctx context.Context, projectUUID string, topic string, key string, createdOn time.Time, expiresOn time.Time) (bool, error) {
+	qKey := QIdempotencyKey{
+		ProjectUUID: projectUUID,
+		Topic:       topic,
+		Key:         key,
+		MessageIDs:  []string{},
+		Pending:     true,
+		CreatedOn:   createdOn,
+		ExpiresOn:   expiresOn,
+	}
+
+	for i, item := range mk.IdempotencyKeys {
+		if item.ProjectUUID == projectUUID && item.Topic == topic && item.Key == key {
+			if item.ExpiresOn.After(createdOn) {
+				return false, nil
+			}
+			mk.IdempotencyKeys[i] = qKey
+			return true, nil
+		}
+	}
+
+	mk.IdempotencyKeys = append(mk.IdempotencyKeys, qKey)
+	return true, nil
+}
+
+// InsertIdempotencyKey remembers the message ids that were published to a topic under the given key
+func (mk *MockStore) InsertIdempotencyKey(ctx context.Context, projectUUID string, topic string, key string, msgIDs []string, createdOn time.Time, expiresOn time.Time) error {
+	qKey := QIdempotencyKey{
+		ProjectUUID: projectUUID,
+		Topic:       topic,
+		Key:         key,
+		MessageIDs:  msgIDs,
+		CreatedOn:   createdOn,
+		ExpiresOn:   expiresOn,
+	}
+
+	for i, item := range mk.IdempotencyKeys {
+		if item.ProjectUUID == projectUUID && item.Topic == topic && item.Key == key {
+			mk.IdempotencyKeys[i] = qKey
+			return nil
+		}
+	}
+
+	mk.IdempotencyKeys = append(mk.IdempotencyKeys, qKey)
+	return nil
+}
+
+// ReleaseIdempotencyKey removes the given key of a topic, as long as it is still pending
+func (mk *MockStore) ReleaseIdempotencyKey(ctx context.Context, projectUUID string, topic string, key string) error {
+	remaining := []QIdempotencyKey{}
+	for _, item := range mk.IdempotencyKeys {
+		if item.ProjectUUID == projectUUID && item.Topic == topic && item.Key == key && item.Pending {
+			continue
+		}
+		remaining = append(remaining, item)
+	}
+
+	mk.IdempotencyKeys = remaining
+	return nil
+}
+
+// QueryIdempotencyKeys returns the keys of a topic, out of the given ones, that expire after the given time
+func (mk *MockStore) QueryIdempotencyKeys(ctx context.Context, projectUUID string, topic string, keys []string, expiresAfter time.Time) ([]QIdempotencyKey, error) {
+	result := []QIdempotencyKey{}
+	for _, item := range mk.IdempotencyKeys {
+		if item.ProjectUUID != projectUUID || item.Topic != topic || !item.ExpiresOn.After(expiresAfter) {
+			continue
+		}
+		for _, key := range keys {
+			if item.Key == key {
+				result = append(result, item)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// RemoveTopicIdempotencyKeys removes all the idempotency keys of a topic
+func (mk *MockStore) RemoveTopicIdempotencyKeys(ctx context.Context, projectUUID string, topic string) error {
+	remaining := []QIdempotencyKey{}
+	for _, item := range mk.IdempotencyKeys {
+		if item.ProjectUUID == projectUUID && item.Topic == topic {
+			continue
+		}
+		remaining = append(remaining, item)
+	}
+
+	mk.IdempotencyKeys = remaining
+	return nil
+}
+
+// RemoveProjectIdempotencyKeys removes all the idempotency keys of a project's topics
+func (mk *MockStore) RemoveProjectIdempotencyKeys(ctx context.Context, projectUUID string) error {
+	remaining := []QIdempotencyKey{}
+	for _, item := range mk.IdempotencyKeys {
+		if item.ProjectUUID == projectUUID {
+			continue
+		}
+		remaining = append(remaining, item)
+	}
+
+	mk.IdempotencyKeys = remaining
+	return nil
+}
+
+func containsOffset(offsets []int64, offset int64) bool {
+	for _, off := range offsets {
+		if off == offset {
+			return true
+		}
+	}
+	return false
+}
+
 // Initialize is used to initialize the mock
 func (mk *MockStore) Initialize() {
 	mk.OpMetrics = make(map[string]QopMetric)
 
 	// populate topics
-	qtop4 := QTopic{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}}
-	qtop3 := QTopic{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}}
-	qtop2 := QTopic{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}}
-	qtop1 := QTopic{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}}
+	qtop4 := QTopic{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0}
+	qtop3 := QTopic{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0}
+	qtop2 := QTopic{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0}
+	qtop1 := QTopic{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0}
 	mk.TopicList = append(mk.TopicList, qtop1)
 	mk.TopicList = append(mk.TopicList, qtop2)
 	mk.TopicList = append(mk.TopicList, qtop3)
@@ -876,26 +1529,26 @@ func (mk *MockStore) Initialize() {
 		"", "", 0, "", "", 10, "",
 		0, 0, 0, "", false, "", "", "", false,
 		time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC),
-		10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}}
+		10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0}
 
 	qsub2 := QSub{1, "argo_uuid", "sub2", "topic2", 0, 0, "",
 		"", "", 0, "", "", 10, "",
 		0, 0, 0, "", false, "", "", "", false,
 		time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC),
-		8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}}
+		8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0}
 
 	qsub3 := QSub{2, "argo_uuid", "sub3", "topic3", 0, 0, "",
 		"", "", 0, "", "", 10, "",
 		0, 0, 0, "", false, "", "", "", false,
 		time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC),
-		5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}}
+		5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0}
 
 	qsub4 := QSub{3, "argo_uuid", "sub4", "topic4", 0, 0, "",
 		"http_endpoint", "endpoint.foo", 1, "autogen",
 		"auth-header-1", 10, "linear", 300, 0, 0,
 		"push-id-1", true, "", "", "", true,
 		time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC),
-		0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}}
+		0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0}
 	mk.SubList = append(mk.SubList, qsub1)
 	mk.SubList = append(mk.SubList, qsub2)
 	mk.SubList = append(mk.SubList, qsub3)
@@ -948,34 +1601,34 @@ func (mk *MockStore) Initialize() {
 	// populate Users
 	qRole := []QProjectRoles{QProjectRoles{"argo_uuid", []string{"consumer", "publisher"}}}
 	qRoleB := []QProjectRoles{QProjectRoles{"argo_uuid2", []string{"consumer", "publisher"}}}
-	qUsr := QUser{0, "uuid0", qRole, "Test", "", "", "", "", "S3CR3T", "Test@test.com", []string{}, created, modified, ""}
+	qUsr := QUser{0, "uuid0", qRole, "Test", "", "", "", "", "S3CR3T", "Test@test.com", []string{}, created, modified, "", "", "", ""}
 
 	mk.UserList = append(mk.UserList, qUsr)
 
 	qRoleConsumerPub := []QProjectRoles{QProjectRoles{"argo_uuid", []string{"publisher", "consumer"}}}
 
-	mk.UserList = append(mk.UserList, QUser{1, "uuid1", qRole, "UserA", "FirstA", "LastA", "OrgA", "DescA", "S3CR3T1", "foo-email", []string{}, created, modified, ""})
-	mk.UserList = append(mk.UserList, QUser{2, "uuid2", qRole, "UserB", "", "", "", "", "S3CR3T2", "foo-email", []string{}, created, modified, "uuid1"})
-	mk.UserList = append(mk.UserList, QUser{3, "uuid3", qRoleConsumerPub, "UserX", "", "", "", "", "S3CR3T3", "foo-email", []string{}, created, modified, "uuid1"})
-	mk.UserList = append(mk.UserList, QUser{4, "uuid4", qRoleConsumerPub, "UserZ", "", "", "", "", "S3CR3T4", "foo-email", []string{}, created, modified, "uuid1"})
-	mk.UserList = append(mk.UserList, QUser{5, "same_uuid", qRoleConsumerPub, "UserSame1", "", "", "", "", "S3CR3T41", "foo-email", []string{}, created, modified, "uuid1"})
-	mk.UserList = append(mk.UserList, QUser{6, "same_uuid", qRoleConsumerPub, "UserSame2", "", "", "", "", "S3CR3T42", "foo-email", []string{}, created, modified, "uuid1"})
-	mk.UserList = append(mk.UserList, QUser{7, "uuid7", []QProjectRoles{}, "push_worker_0", "", "", "", "", "push_token", "foo-email", []string{"push_worker"}, created, modified, ""})
-	mk.UserList = append(mk.UserList, QUser{8, "uuid8", qRoleB, "UserZ", "", "", "", "", "S3CR3T1", "foo-email", []string{}, created, modified, ""})
+	mk.UserList = append(mk.UserList, QUser{1, "uuid1", qRole, "UserA", "FirstA", "LastA", "OrgA", "DescA", "S3CR3T1", "foo-email", []string{}, created, modified, "", "", "", ""})
+	mk.UserList = append(mk.UserList, QUser{2, "uuid2", qRole, "UserB", "", "", "", "", "S3CR3T2", "foo-email", []string{}, created, modified, "uuid1", "", "", ""})
+	mk.UserList = append(mk.UserList, QUser{3, "uuid3", qRoleConsumerPub, "UserX", "", "", "", "", "S3CR3T3", "foo-email", []string{}, created, modified, "uuid1", "", "", ""})
+	mk.UserList = append(mk.UserList, QUser{4, "uuid4", qRoleConsumerPub, "UserZ", "", "", "", "", "S3CR3T4", "foo-email", []string{}, created, modified, "uuid1", "", "", ""})
+	mk.UserList = append(mk.UserList, QUser{5, "same_uuid", qRoleConsumerPub, "UserSame1", "", "", "", "", "S3CR3T41", "foo-email", []string{}, created, modified, "uuid1", "", "", ""})
+	mk.UserList = append(mk.UserList, QUser{6, "same_uuid", qRoleConsumerPub, "UserSame2", "", "", "", "", "S3CR3T42", "foo-email", []string{}, created, modified, "uuid1", "", "", ""})
+	mk.UserList = append(mk.UserList, QUser{7, "uuid7", []QProjectRoles{}, "push_worker_0", "", "", "", "", "push_token", "foo-email", []string{"push_worker"}, created, modified, "", "", "", ""})
+	mk.UserList = append(mk.UserList, QUser{8, "uuid8", qRoleB, "UserZ", "", "", "", "", "S3CR3T1", "foo-email", []string{}, created, modified, "", "", "", ""})
 
 	qRole1 := QRole{"topics:list_all", []string{"admin", "reader", "publisher"}}
 	qRole2 := QRole{"topics:publish", []string{"admin", "publisher"}}
 	mk.RoleList = append(mk.RoleList, qRole1)
 	mk.RoleList = append(mk.RoleList, qRole2)
 
-	qTopicACL01 := QAcl{[]string{"uuid1", "uuid2"}}
-	qTopicACL02 := QAcl{[]string{"uuid1", "uuid2", "uuid4"}}
-	qTopicACL03 := QAcl{[]string{"uuid3"}}
+	qTopicACL01 := QAcl{ACL: []string{"uuid1", "uuid2"}}
+	qTopicACL02 := QAcl{ACL: []string{"uuid1", "uuid2", "uuid4"}}
+	qTopicACL03 := QAcl{ACL: []string{"uuid3"}}
 
-	qSubACL01 := QAcl{[]string{"uuid1", "uuid2"}}
-	qSubACL02 := QAcl{[]string{"uuid1", "uuid3"}}
-	qSubACL03 := QAcl{[]string{"uuid4", "uuid2", "uuid1"}}
-	qSubACL04 := QAcl{[]string{"uuid2", "uuid4", "uuid7"}}
+	qSubACL01 := QAcl{ACL: []string{"uuid1", "uuid2"}}
+	qSubACL02 := QAcl{ACL: []string{"uuid1", "uuid3"}}
+	qSubACL03 := QAcl{ACL: []string{"uuid4", "uuid2", "uuid1"}}
+	qSubACL04 := QAcl{ACL: []string{"uuid2", "uuid4", "uuid7"}}
 
 	mk.TopicsACL = make(map[string]QAcl)
 	mk.SubsACL = make(map[string]QAcl)
@@ -1013,6 +1666,49 @@ func (mk *MockStore) InsertResourceRoles(ctx context.Context, resource string, r
 	return nil
 }
 
+// QueryResourceRoles returns the roles that each resource has been bound to
+func (mk *MockStore) QueryResourceRoles(ctx context.Context) ([]QRole, error) {
+	result := []QRole{}
+	for _, item := range mk.RoleList {
+		result = append(result, QRole{Name: item.Name, Roles: append([]string{}, item.Roles...)})
+	}
+	return result, nil
+}
+
+// AddResourceRole binds a role to a resource
+func (mk *MockStore) AddResourceRole(ctx context.Context, resource string, role string) error {
+	for i, item := range mk.RoleList {
+		if item.Name == resource {
+			for _, r := range item.Roles {
+				if r == role {
+					return nil
+				}
+			}
+			mk.RoleList[i].Roles = append(mk.RoleList[i].Roles, role)
+			return nil
+		}
+	}
+	mk.RoleList = append(mk.RoleList, QRole{Name: resource, Roles: []string{role}})
+	return nil
+}
+
+// RemoveResourceRole unbinds a role from a resource, or from all of them when no resource is given
+func (mk *MockStore) RemoveResourceRole(ctx context.Context, resource string, role string) error {
+	for i, item := range mk.RoleList {
+		if resource != "" && item.Name != resource {
+			continue
+		}
+		roles := []string{}
+		for _, r := range item.Roles {
+			if r != role {
+				roles = append(roles, r)
+			}
+		}
+		mk.RoleList[i].Roles = roles
+	}
+	return nil
+}
+
 func (mk *MockStore) QueryTotalMessagesPerProject(ctx context.Context, projectUUIDs []string, startDate time.Time, endDate time.Time) ([]QProjectMessageCount, error) {
 
 	projectCount := make(map[string]int64)
@@ -1098,12 +1794,110 @@ func (mk *MockStore) GetUserFromToken(ctx context.Context, token string) (QUser,
 
 }
 
+// GetUserFromEmail retrieves specific user info from a given email
+func (mk *MockStore) GetUserFromEmail(ctx context.Context, email string) (QUser, error) {
+	var results []QUser
+	for _, item := range mk.UserList {
+		if email != "" && item.Email == email {
+			results = append(results, item)
+		}
+	}
+
+	if len(results) == 0 {
+		return QUser{}, errors.New("not found")
+	}
+
+	if len(results) > 1 {
+		return QUser{}, errors.New("multiple users")
+	}
+
+	return results[0], nil
+}
+
+// GetUserFromDN retrieves specific user info from a given certificate subject and issuer
+func (mk *MockStore) GetUserFromDN(ctx context.Context, dn string, issuerDN string) (QUser, error) {
+	for _, item := range mk.UserList {
+		if dn != "" && item.DN == dn && item.IssuerDN == issuerDN {
+			return item, nil
+		}
+	}
+
+	return QUser{}, errors.New("not found")
+}
+
+// GetUserFromOIDCSubject retrieves specific user info from a given OIDC subject
+func (mk *MockStore) GetUserFromOIDCSubject(ctx context.Context, subject string) (QUser, error) {
+	for _, item := range mk.UserList {
+		if subject != "" && item.OIDCSubject == subject {
+			return item, nil
+		}
+	}
+
+	return QUser{}, errors.New("not found")
+}
+
+// InsertAPIKey stores a new api key of a user
+func (mk *MockStore) InsertAPIKey(ctx context.Context, key QAPIKey) error {
+	mk.APIKeys = append(mk.APIKeys, key)
+	return nil
+}
+
+// QueryAPIKeys returns the api keys of a user, or a specific one when a name is given
+func (mk *MockStore) QueryAPIKeys(ctx context.Context, userUUID string, name string) ([]QAPIKey, error) {
+	result := []QAPIKey{}
+	for _, item := range mk.APIKeys {
+		if item.UserUUID == userUUID && (name == "" || item.Name == name) {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// GetAPIKeyByHash returns the api key with the given hash
+func (mk *MockStore) GetAPIKeyByHash(ctx context.Context, tokenHash string) (QAPIKey, error) {
+	for _, item := range mk.APIKeys {
+		if item.TokenHash == tokenHash {
+			return item, nil
+		}
+	}
+	return QAPIKey{}, errors.New("not found")
+}
+
+// UpdateAPIKeyLastUsed records when an api key was last used
+func (mk *MockStore) UpdateAPIKeyLastUsed(ctx context.Context, userUUID string, name string, lastUsedOn time.Time) error {
+	for i, item := range mk.APIKeys {
+		if item.UserUUID == userUUID && item.Name == name {
+			mk.APIKeys[i].LastUsedOn = lastUsedOn
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+// RemoveAPIKeys removes an api key of a user, or all of them when no name is given
+func (mk *MockStore) RemoveAPIKeys(ctx context.Context, userUUID string, name string) error {
+	remaining := []QAPIKey{}
+	for _, item := range mk.APIKeys {
+		if item.UserUUID == userUUID && (name == "" || item.Name == name) {
+			continue
+		}
+		remaining = append(remaining, item)
+	}
+
+	if name != "" && len(remaining) == len(mk.APIKeys) {
+		return errors.New("not found")
+	}
+
+	mk.APIKeys = remaining
+	return nil
+}
+
 // GetUserRoles returns the roles of a user in a project
 func (mk *MockStore) GetUserRoles(ctx context.Context, projectUUID string, token string) ([]string, string) {
 	for _, item := range mk.UserList {
 
 		if item.Token == token {
-			return item.getProjectRoles(projectUUID), item.Name
+			return item.ProjectRoles(projectUUID), item.Name
 
 		}
 	}
@@ -1170,6 +1964,39 @@ func (mk *MockStore) LinkTopicSchema(ctx context.Context, projectUUID, name, sch
 	return nil
 }
 
+// ModTopicMessageTTL modifies the topic's message ttl
+func (mk *MockStore) ModTopicMessageTTL(ctx context.Context, projectUUID string, name string, messageTTL int64) error {
+	for idx, topic := range mk.TopicList {
+		if topic.Name == name && topic.ProjectUUID == projectUUID {
+			mk.TopicList[idx].MessageTTL = messageTTL
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+// ModTopicQuarantineTopic modifies the topic that receives the rejected messages of the topic
+func (mk *MockStore) ModTopicQuarantineTopic(ctx context.Context, projectUUID string, name string, quarantineTopic string) error {
+	for idx, topic := range mk.TopicList {
+		if topic.Name == name && topic.ProjectUUID == projectUUID {
+			mk.TopicList[idx].QuarantineTopic = quarantineTopic
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+// ModTopicCompression modifies the algorithm that compresses the payloads of the topic
+func (mk *MockStore) ModTopicCompression(ctx context.Context, projectUUID string, name string, compression string) error {
+	for idx, topic := range mk.TopicList {
+		if topic.Name == name && topic.ProjectUUID == projectUUID {
+			mk.TopicList[idx].Compression = compression
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
 // InsertSub inserts a new sub object to the store
 func (mk *MockStore) InsertSub(ctx context.Context, projectUUID string, name string, topic string,
 	offset int64, ack int, pushCfg QPushConfig, createdOn time.Time) error {
@@ -1195,6 +2022,7 @@ func (mk *MockStore) InsertSub(ctx context.Context, projectUUID string, name str
 		MattermostChannel:   pushCfg.MattermostChannel,
 		MattermostUsername:  pushCfg.MattermostUsername,
 		Base64Decode:        pushCfg.Base64Decode,
+		SchemaDecode:        pushCfg.SchemaDecode,
 		MsgNum:              0,
 		TotalBytes:          0,
 		CreatedOn:           createdOn,
@@ -1478,6 +2306,11 @@ func (mk *MockStore) QueryTopicsByACL(ctx context.Context, projectUUID, user str
 	return result, nil
 }
 
+// QueryAllTopics returns the topics of all projects
+func (mk *MockStore) QueryAllTopics(ctx context.Context) ([]QTopic, error) {
+	return mk.TopicList, nil
+}
+
 // QueryTopics Query Subscription info from store
 func (mk *MockStore) QueryTopics(ctx context.Context, projectUUID, userUUID, name, pageToken string, pageSize int64) ([]QTopic, int64, string, error) {
 
@@ -1601,7 +2434,7 @@ func (mk *MockStore) existsInACL(ctx context.Context, resource, resourceName, us
 	}
 
 	for _, u := range acl.ACL {
-		if u == userUUID {
+		if u == userUUID || mk.isGroupMember(u, userUUID) {
 			return true
 		}
 
@@ -1612,7 +2445,7 @@ func (mk *MockStore) existsInACL(ctx context.Context, resource, resourceName, us
 }
 
 // Checks if a users exists in an ACL resource (topic or subscription)
-func (mk *MockStore) ExistsInACL(ctx context.Context, projectUUID string, resource string, resourceName string, userUUID string) error {
+func (mk *MockStore) ExistsInACL(ctx context.Context, projectUUID string, resource string, resourceName string, userUUID string, action string) error {
 
 	var acl QAcl
 
@@ -1622,11 +2455,8 @@ func (mk *MockStore) ExistsInACL(ctx context.Context, projectUUID string, resour
 		acl = mk.TopicsACL[resourceName]
 	}
 
-	for _, u := range acl.ACL {
-		if u == userUUID {
-			return nil
-		}
-
+	if acl.Allows(mk.aclEntries(userUUID), action) {
+		return nil
 	}
 
 	return errors.New("not found")
@@ -1709,13 +2539,16 @@ func (mk *MockStore) QueryDailyTopicMsgCount(ctx context.Context, projectUUID st
 	return qds, nil
 }
 
-func (mk *MockStore) InsertSchema(ctx context.Context, projectUUID, schemaUUID, name, schemaType, rawSchemaString string) error {
+func (mk *MockStore) InsertSchema(ctx context.Context, projectUUID, schemaUUID, name, schemaType, rawSchemaString, compatibility string, revision int64, references []QSchemaReference) error {
 	mk.SchemaList = append(mk.SchemaList, QSchema{
-		ProjectUUID: projectUUID,
-		UUID:        schemaUUID,
-		Name:        name,
-		Type:        schemaType,
-		RawSchema:   rawSchemaString,
+		ProjectUUID:   projectUUID,
+		UUID:          schemaUUID,
+		Name:          name,
+		Type:          schemaType,
+		RawSchema:     rawSchemaString,
+		Revision:      revision,
+		Compatibility: compatibility,
+		References:    references,
 	})
 
 	return nil
@@ -1760,7 +2593,7 @@ func (mk *MockStore) QuerySchemas(ctx context.Context, projectUUID, schemaUUID,
 	return qSchemas, nil
 }
 
-func (mk *MockStore) UpdateSchema(ctx context.Context, schemaUUID, name, schemaType, rawSchemaString string) error {
+func (mk *MockStore) UpdateSchema(ctx context.Context, schemaUUID, name, schemaType, rawSchemaString, compatibility string, revision int64, references []QSchemaReference) error {
 
 	for idx, s := range mk.SchemaList {
 		if s.UUID == schemaUUID {
@@ -1777,6 +2610,15 @@ func (mk *MockStore) UpdateSchema(ctx context.Context, schemaUUID, name, schemaT
 				mk.SchemaList[idx].RawSchema = rawSchemaString
 			}
 
+			if compatibility != "" {
+				mk.SchemaList[idx].Compatibility = compatibility
+			}
+
+			if revision > 0 {
+				mk.SchemaList[idx].Revision = revision
+				mk.SchemaList[idx].References = references
+			}
+
 			return nil
 		}
 	}
@@ -1789,6 +2631,14 @@ func (mk *MockStore) DeleteSchema(ctx context.Context, schemaUUID string) error
 		if s.UUID == schemaUUID {
 			mk.SchemaList = append(mk.SchemaList[:idx], mk.SchemaList[idx+1:]...)
 
+			revisions := []QSchemaRevision{}
+			for _, rev := range mk.SchemaRevisions {
+				if rev.SchemaUUID != schemaUUID {
+					revisions = append(revisions, rev)
+				}
+			}
+			mk.SchemaRevisions = revisions
+
 			for idx, t := range mk.TopicList {
 				if t.SchemaUUID == schemaUUID {
 					mk.TopicList[idx].SchemaUUID = ""
@@ -1802,6 +2652,115 @@ func (mk *MockStore) DeleteSchema(ctx context.Context, schemaUUID string) error
 	return errors.New("not found")
 }
 
+// QuerySchemasByReference returns the schemas of a project whose current content depends on the given schema
+func (mk *MockStore) QuerySchemasByReference(ctx context.Context, projectUUID, schemaUUID string) ([]QSchema, error) {
+	qSchemas := []QSchema{}
+	for _, s := range mk.SchemaList {
+		if s.ProjectUUID != projectUUID {
+			continue
+		}
+		for _, ref := range s.References {
+			if ref.SchemaUUID == schemaUUID {
+				qSchemas = append(qSchemas, s)
+				break
+			}
+		}
+	}
+
+	return qSchemas, nil
+}
+
+// InsertSchemaRevision stores a new revision of a schema
+func (mk *MockStore) InsertSchemaRevision(ctx context.Context, projectUUID, schemaUUID string, revision int64, schemaType, rawSchemaString string, references []QSchemaReference, createdOn time.Time) error {
+	for _, rev := range mk.SchemaRevisions {
+		if rev.SchemaUUID == schemaUUID && rev.Revision == revision {
+			return errors.New("exists")
+		}
+	}
+
+	mk.SchemaRevisionSeq++
+
+	mk.SchemaRevisions = append(mk.SchemaRevisions, QSchemaRevision{
+		ID:          mk.SchemaRevisionSeq,
+		ProjectUUID: projectUUID,
+		SchemaUUID:  schemaUUID,
+		Revision:    revision,
+		Type:        schemaType,
+		RawSchema:   rawSchemaString,
+		CreatedOn:   createdOn,
+		References:  references,
+	})
+	return nil
+}
+
+// QuerySchemaRevisions returns the revisions of a schema in ascending order, or only the given revision
+func (mk *MockStore) QuerySchemaRevisions(ctx context.Context, projectUUID, schemaUUID string, revision int64) ([]QSchemaRevision, error) {
+	result := []QSchemaRevision{}
+	for _, rev := range mk.SchemaRevisions {
+		if rev.ProjectUUID == projectUUID && rev.SchemaUUID == schemaUUID && (revision == 0 || rev.Revision == revision) {
+			result = append(result, rev)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Revision < result[j].Revision
+	})
+
+	return result, nil
+}
+
+// QuerySchemaRevisionByID returns the schema revision with the given id
+func (mk *MockStore) QuerySchemaRevisionByID(ctx context.Context, projectUUID string, id int64) ([]QSchemaRevision, error) {
+	result := []QSchemaRevision{}
+	for _, rev := range mk.SchemaRevisions {
+		if rev.ProjectUUID == projectUUID && rev.ID == id {
+			result = append(result, rev)
+		}
+	}
+
+	return result, nil
+}
+
+// QuerySchemaRevisionsByReference returns the schema revisions of a project whose content depends on the given schema
+func (mk *MockStore) QuerySchemaRevisionsByReference(ctx context.Context, projectUUID, schemaUUID string) ([]QSchemaRevision, error) {
+	result := []QSchemaRevision{}
+	for _, rev := range mk.SchemaRevisions {
+		if rev.ProjectUUID != projectUUID {
+			continue
+		}
+		for _, ref := range rev.References {
+			if ref.SchemaUUID == schemaUUID {
+				result = append(result, rev)
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// InsertSchemaInvalidation records a change to a schema
+func (mk *MockStore) InsertSchemaInvalidation(ctx context.Context, projectUUID, schemaUUID string, invalidatedOn time.Time) error {
+	mk.SchemaInvalidations = append(mk.SchemaInvalidations, QSchemaInvalidation{
+		ProjectUUID:   projectUUID,
+		SchemaUUID:    schemaUUID,
+		InvalidatedOn: invalidatedOn,
+	})
+	return nil
+}
+
+// QuerySchemaInvalidations returns the schema changes that were recorded from the given time onwards
+func (mk *MockStore) QuerySchemaInvalidations(ctx context.Context, since time.Time) ([]QSchemaInvalidation, error) {
+	result := []QSchemaInvalidation{}
+	for _, inv := range mk.SchemaInvalidations {
+		if !inv.InvalidatedOn.Before(since) {
+			result = append(result, inv)
+		}
+	}
+
+	return result, nil
+}
+
 func (mk *MockStore) DeleteRegistration(ctx context.Context, regUUID string) error {
 
 	for idx, s := range mk.UserRegistrations {
@@ -384,6 +384,15 @@ func (mong *MongoStore) UpdateRegistration(ctx context.Context, regUUID, status,
 	return c.Update(ur, change)
 }
 
+// ConfirmRegistrationEmail marks the email of a registration as confirmed
+func (mong *MongoStore) ConfirmRegistrationEmail(ctx context.Context, regUUID, confirmedAt string) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("user_registrations")
+
+	return c.Update(bson.M{"uuid": regUUID}, bson.M{"$set": bson.M{"email_confirmed_at": confirmedAt}})
+}
+
 // UpdateUserToken updates user's token
 func (mong *MongoStore) UpdateUserToken(ctx context.Context, uuid string, token string) error {
 
@@ -399,6 +408,36 @@ func (mong *MongoStore) UpdateUserToken(ctx context.Context, uuid string, token
 
 }
 
+// UpdateUserDN updates the certificate subject and issuer of the user
+func (mong *MongoStore) UpdateUserDN(ctx context.Context, uuid string, dn string, issuerDN string) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("users")
+
+	doc := bson.M{"uuid": uuid}
+	change := bson.M{"$set": bson.M{"dn": dn, "issuer_dn": issuerDN}}
+
+	err := c.Update(doc, change)
+
+	return err
+
+}
+
+// UpdateUserOIDCSubject updates the OIDC subject of the user
+func (mong *MongoStore) UpdateUserOIDCSubject(ctx context.Context, uuid string, subject string) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("users")
+
+	doc := bson.M{"uuid": uuid}
+	change := bson.M{"$set": bson.M{"oidc_subject": subject}}
+
+	err := c.Update(doc, change)
+
+	return err
+
+}
+
 // AppendToUserProjects appends a new unique project to the user's projects
 func (mong *MongoStore) AppendToUserProjects(ctx context.Context, userUUID string, projectUUID string, pRoles ...string) error {
 
@@ -810,6 +849,20 @@ func (mong *MongoStore) QueryTopicsByACL(ctx context.Context, projectUUID, user
 	return results, err
 }
 
+// QueryAllTopics returns the topics of all projects
+func (mong *MongoStore) QueryAllTopics(ctx context.Context) ([]QTopic, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("topics")
+	var results []QTopic
+	err := c.Find(bson.M{}).All(&results)
+
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "QueryAllTopics", err)
+	}
+
+	return results, err
+}
+
 // QueryTopics Query Subscription info from store
 func (mong *MongoStore) QueryTopics(ctx context.Context, projectUUID, userUUID, name, pageToken string, pageSize int64) ([]QTopic, int64, string, error) {
 
@@ -826,7 +879,7 @@ func (mong *MongoStore) QueryTopics(ctx context.Context, projectUUID, userUUID,
 
 	// find all the topics for a specific user
 	if userUUID != "" {
-		query["acl"] = bson.M{"$in": []string{userUUID}}
+		query["acl"] = bson.M{"$in": mong.aclEntries(ctx, projectUUID, userUUID)}
 	}
 
 	// if the page size is other than zero(where zero means, no limit), try to grab one more document to check if there
@@ -872,7 +925,7 @@ func (mong *MongoStore) QueryTopics(ctx context.Context, projectUUID, userUUID,
 
 		countQuery := bson.M{"project_uuid": projectUUID}
 		if userUUID != "" {
-			countQuery["acl"] = bson.M{"$in": []string{userUUID}}
+			countQuery["acl"] = bson.M{"$in": mong.aclEntries(ctx, projectUUID, userUUID)}
 		}
 
 		if size, err = c.Find(countQuery).Count(); err != nil {
@@ -1052,6 +1105,19 @@ func (mong *MongoStore) IncrementTopicBytes(ctx context.Context, projectUUID str
 	return err
 }
 
+// IncrementTopicStoredBytes increases the total number of bytes stored in the broker for a topic
+func (mong *MongoStore) IncrementTopicStoredBytes(ctx context.Context, projectUUID string, name string, storedBytes int64) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("topics")
+
+	doc := bson.M{"project_uuid": projectUUID, "name": name}
+	change := bson.M{"$inc": bson.M{"stored_bytes": storedBytes}}
+
+	err := c.Update(doc, change)
+
+	return err
+}
+
 // IncrementSubMsgNum increments the number of messages pulled in a subscription
 func (mong *MongoStore) IncrementSubMsgNum(ctx context.Context, projectUUID string, name string, num int64) error {
 
@@ -1067,6 +1133,21 @@ func (mong *MongoStore) IncrementSubMsgNum(ctx context.Context, projectUUID stri
 
 }
 
+// IncrementSubExpiredMsgNum increments the number of expired messages skipped in a subscription
+func (mong *MongoStore) IncrementSubExpiredMsgNum(ctx context.Context, projectUUID string, name string, num int64) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("subscriptions")
+
+	doc := bson.M{"project_uuid": projectUUID, "name": name}
+	change := bson.M{"$inc": bson.M{"expired_msg_num": num}}
+
+	err := c.Update(doc, change)
+
+	return err
+
+}
+
 // IncrementSubBytes increases the total number of bytes consumed from a subscription
 func (mong *MongoStore) IncrementSubBytes(ctx context.Context, projectUUID string, name string, totalBytes int64) error {
 	db := mong.Session.DB(mong.Database)
@@ -1080,6 +1161,19 @@ func (mong *MongoStore) IncrementSubBytes(ctx context.Context, projectUUID strin
 	return err
 }
 
+// IncrementSubStoredBytes increases the total number of bytes consumed from a subscription, as stored in the broker
+func (mong *MongoStore) IncrementSubStoredBytes(ctx context.Context, projectUUID string, name string, storedBytes int64) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("subscriptions")
+
+	doc := bson.M{"project_uuid": projectUUID, "name": name}
+	change := bson.M{"$inc": bson.M{"stored_bytes": storedBytes}}
+
+	err := c.Update(doc, change)
+
+	return err
+}
+
 // HasResourceRoles returns the roles of a user in a project
 func (mong *MongoStore) HasResourceRoles(ctx context.Context, resource string, roles []string) bool {
 
@@ -1113,6 +1207,50 @@ func (mong *MongoStore) InsertResourceRoles(ctx context.Context, resource string
 	return nil
 }
 
+// QueryResourceRoles returns the roles that each resource has been bound to
+func (mong *MongoStore) QueryResourceRoles(ctx context.Context) ([]QRole, error) {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("roles")
+	results := []QRole{}
+	err := c.Find(nil).Sort("resource").All(&results)
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "QueryResourceRoles", err)
+	}
+	return results, err
+}
+
+// AddResourceRole binds a role to a resource
+func (mong *MongoStore) AddResourceRole(ctx context.Context, resource string, role string) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("roles")
+	doc := bson.M{"resource": resource}
+	change := bson.M{"$addToSet": bson.M{"roles": role}}
+	_, err := c.Upsert(doc, change)
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "AddResourceRole", err)
+	}
+	return err
+}
+
+// RemoveResourceRole unbinds a role from a resource, or from all of them when no resource is given
+func (mong *MongoStore) RemoveResourceRole(ctx context.Context, resource string, role string) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("roles")
+	doc := bson.M{}
+	if resource != "" {
+		doc["resource"] = resource
+	}
+	change := bson.M{"$pull": bson.M{"roles": role}}
+	_, err := c.UpdateAll(doc, change)
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "RemoveResourceRole", err)
+	}
+	return err
+}
+
 // GetAllRoles returns a list of all available roles
 func (mong *MongoStore) GetAllRoles(ctx context.Context) []string {
 
@@ -1173,7 +1311,7 @@ func (mong *MongoStore) GetUserRoles(ctx context.Context, projectUUID string, to
 	}
 
 	// Search the found user for project roles
-	return results[0].getProjectRoles(projectUUID), results[0].Name
+	return results[0].ProjectRoles(projectUUID), results[0].Name
 
 }
 
@@ -1212,6 +1350,145 @@ func (mong *MongoStore) GetUserFromToken(ctx context.Context, token string) (QUs
 
 }
 
+// GetUserFromEmail returns user information from a specific email
+func (mong *MongoStore) GetUserFromEmail(ctx context.Context, email string) (QUser, error) {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("users")
+	var results []QUser
+
+	err := c.Find(bson.M{"email": email}).All(&results)
+
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "GetUserFromEmail", err)
+
+	}
+
+	if email == "" || len(results) == 0 {
+		return QUser{}, errors.New("not found")
+	}
+
+	// an email shared by several users doesn't identify any of them
+	if len(results) > 1 {
+		return QUser{}, errors.New("multiple users")
+	}
+
+	return results[0], err
+
+}
+
+// GetUserFromDN returns user information from a specific certificate subject and issuer
+func (mong *MongoStore) GetUserFromDN(ctx context.Context, dn string, issuerDN string) (QUser, error) {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("users")
+	var results []QUser
+
+	err := c.Find(bson.M{"dn": dn, "issuer_dn": issuerDN}).All(&results)
+
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "GetUserFromDN", err)
+
+	}
+
+	if dn == "" || len(results) == 0 {
+		return QUser{}, errors.New("not found")
+	}
+
+	return results[0], err
+
+}
+
+// GetUserFromOIDCSubject returns user information from a specific OIDC subject
+func (mong *MongoStore) GetUserFromOIDCSubject(ctx context.Context, subject string) (QUser, error) {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("users")
+	var results []QUser
+
+	err := c.Find(bson.M{"oidc_subject": subject}).All(&results)
+
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "GetUserFromOIDCSubject", err)
+
+	}
+
+	if subject == "" || len(results) == 0 {
+		return QUser{}, errors.New("not found")
+	}
+
+	return results[0], err
+
+}
+
+// InsertAPIKey stores a new api key of a user
+func (mong *MongoStore) InsertAPIKey(ctx context.Context, key QAPIKey) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("api_keys")
+
+	return c.Insert(key)
+}
+
+// QueryAPIKeys returns the api keys of a user, or a specific one when a name is given
+func (mong *MongoStore) QueryAPIKeys(ctx context.Context, userUUID string, name string) ([]QAPIKey, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("api_keys")
+
+	query := bson.M{"user_uuid": userUUID}
+	if name != "" {
+		query["name"] = name
+	}
+
+	results := []QAPIKey{}
+	err := c.Find(query).Sort("created_on").All(&results)
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "QueryAPIKeys", err)
+	}
+	return results, err
+}
+
+// GetAPIKeyByHash returns the api key with the given hash
+func (mong *MongoStore) GetAPIKeyByHash(ctx context.Context, tokenHash string) (QAPIKey, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("api_keys")
+
+	results := []QAPIKey{}
+	err := c.Find(bson.M{"token_hash": tokenHash}).All(&results)
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "GetAPIKeyByHash", err)
+	}
+
+	if len(results) == 0 {
+		return QAPIKey{}, errors.New("not found")
+	}
+
+	return results[0], err
+}
+
+// UpdateAPIKeyLastUsed records when an api key was last used
+func (mong *MongoStore) UpdateAPIKeyLastUsed(ctx context.Context, userUUID string, name string, lastUsedOn time.Time) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("api_keys")
+
+	doc := bson.M{"user_uuid": userUUID, "name": name}
+	change := bson.M{"$set": bson.M{"last_used_on": lastUsedOn}}
+
+	return c.Update(doc, change)
+}
+
+// RemoveAPIKeys removes an api key of a user, or all of them when no name is given
+func (mong *MongoStore) RemoveAPIKeys(ctx context.Context, userUUID string, name string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("api_keys")
+
+	if name == "" {
+		_, err := c.RemoveAll(bson.M{"user_uuid": userUUID})
+		return err
+	}
+
+	return c.Remove(bson.M{"user_uuid": userUUID, "name": name})
+}
+
 // QueryOneSub queries and returns specific sub of project
 func (mong *MongoStore) QueryOneSub(ctx context.Context, projectUUID string, name string) (QSub, error) {
 
@@ -1281,6 +1558,30 @@ func (mong *MongoStore) LinkTopicSchema(ctx context.Context, projectUUID, name,
 	return nil
 }
 
+// ModTopicMessageTTL modifies the topic's message ttl field in mongodb
+func (mong *MongoStore) ModTopicMessageTTL(ctx context.Context, projectUUID string, name string, messageTTL int64) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("topics")
+	err := c.Update(bson.M{"project_uuid": projectUUID, "name": name}, bson.M{"$set": bson.M{"message_ttl": messageTTL}})
+	return err
+}
+
+// ModTopicQuarantineTopic modifies the topic's quarantine topic field in mongodb
+func (mong *MongoStore) ModTopicQuarantineTopic(ctx context.Context, projectUUID string, name string, quarantineTopic string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("topics")
+	err := c.Update(bson.M{"project_uuid": projectUUID, "name": name}, bson.M{"$set": bson.M{"quarantine_topic": quarantineTopic}})
+	return err
+}
+
+// ModTopicCompression modifies the topic's compression field in mongodb
+func (mong *MongoStore) ModTopicCompression(ctx context.Context, projectUUID string, name string, compression string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("topics")
+	err := c.Update(bson.M{"project_uuid": projectUUID, "name": name}, bson.M{"$set": bson.M{"compression": compression}})
+	return err
+}
+
 // InsertOpMetric inserts an operational metric
 func (mong *MongoStore) InsertOpMetric(ctx context.Context, hostname string, cpu float64, mem float64) error {
 	opMetric := QopMetric{Hostname: hostname, CPU: cpu, MEM: mem}
@@ -1348,6 +1649,7 @@ func (mong *MongoStore) InsertSub(ctx context.Context, projectUUID string, name
 		MattermostChannel:   pushCfg.MattermostChannel,
 		MattermostUsername:  pushCfg.MattermostUsername,
 		Base64Decode:        pushCfg.Base64Decode,
+		SchemaDecode:        pushCfg.SchemaDecode,
 		MsgNum:              0,
 		TotalBytes:          0,
 		CreatedOn:           createdOn,
@@ -1523,8 +1825,8 @@ func (mong *MongoStore) RemoveSub(ctx context.Context, projectUUID string, name
 	return mong.RemoveResource(ctx, "subscriptions", sub)
 }
 
-// ExistsInACL checks if a user is part of a topic's or sub's acl
-func (mong *MongoStore) ExistsInACL(ctx context.Context, projectUUID string, resource string, resourceName string, userUUID string) error {
+// ExistsInACL checks if a user is part of a topic's or sub's acl and is allowed the given action, an empty action matches any
+func (mong *MongoStore) ExistsInACL(ctx context.Context, projectUUID string, resource string, resourceName string, userUUID string, action string) error {
 
 	db := mong.Session.DB(mong.Database)
 
@@ -1534,20 +1836,29 @@ func (mong *MongoStore) ExistsInACL(ctx context.Context, projectUUID string, res
 
 	c := db.C(resource)
 
+	entries := mong.aclEntries(ctx, projectUUID, userUUID)
 	query := bson.M{
 		"project_uuid": projectUUID,
 		"name":         resourceName,
 		"acl": bson.M{
-			"$in": []string{userUUID},
+			"$in": entries,
 		},
 	}
 
-	res := map[string]interface{}{}
-	return c.Find(query).One(&res)
+	var qAcl QAcl
+	err := c.Find(query).One(&qAcl)
+	if err != nil {
+		return err
+	}
+
+	if !qAcl.Allows(entries, action) {
+		return mgo.ErrNotFound
+	}
+	return nil
 }
 
-// ModACL modifies the push configuration
-func (mong *MongoStore) ModACL(ctx context.Context, projectUUID string, resource string, name string, acl []string) error {
+// ModACL replaces the acl and the actions that its entries are restricted to, in a single update
+func (mong *MongoStore) ModACL(ctx context.Context, projectUUID string, resource string, name string, acl []string, actions map[string][]string) error {
 	db := mong.Session.DB(mong.Database)
 
 	if resource != "topics" && resource != "subscriptions" {
@@ -1556,7 +1867,7 @@ func (mong *MongoStore) ModACL(ctx context.Context, projectUUID string, resource
 
 	c := db.C(resource)
 
-	err := c.Update(bson.M{"project_uuid": projectUUID, "name": name}, bson.M{"$set": bson.M{"acl": acl}})
+	err := c.Update(bson.M{"project_uuid": projectUUID, "name": name}, bson.M{"$set": bson.M{"acl": acl, "acl_actions": actions}})
 	return err
 }
 
@@ -1596,54 +1907,643 @@ func (mong *MongoStore) RemoveFromACL(ctx context.Context, projectUUID string, r
 
 	c := db.C(resource)
 
+	change := bson.M{
+		"$pullAll": bson.M{
+			"acl": acl,
+		},
+	}
+	if len(acl) > 0 {
+		change["$unset"] = aclActionsFields(acl)
+	}
+
 	err := c.Update(
 		bson.M{
 			"project_uuid": projectUUID,
 			"name":         name,
 		},
-		bson.M{
-			"$pullAll": bson.M{
-				"acl": acl,
-			},
-		})
+		change)
 
 	return err
 }
 
-// ModAck modifies the subscription's ack timeout field in mongodb
-func (mong *MongoStore) ModAck(ctx context.Context, projectUUID string, name string, ack int) error {
+// aclEntries returns the acl entries that grant access to a user, the user itself and the groups of the project it is a member of
+func (mong *MongoStore) aclEntries(ctx context.Context, projectUUID string, userUUID string) []string {
 	db := mong.Session.DB(mong.Database)
-	c := db.C("subscriptions")
-	err := c.Update(bson.M{"project_uuid": projectUUID, "name": name}, bson.M{"$set": bson.M{"ack": ack}})
-	return err
+	c := db.C("groups")
+
+	var groups []QGroup
+	err := c.Find(bson.M{"project_uuid": projectUUID, "members": userUUID}).All(&groups)
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "aclEntries", err)
+	}
+
+	entries := []string{userUUID}
+	for _, group := range groups {
+		entries = append(entries, GroupACLEntry(group.UUID))
+	}
+	return entries
 }
 
-// ModSubPush modifies the push configuration
-func (mong *MongoStore) ModSubPush(ctx context.Context, projectUUID string, name string, pushCfg QPushConfig) error {
+// InsertGroup stores a new group of users of a project
+func (mong *MongoStore) InsertGroup(ctx context.Context, group QGroup) error {
 	db := mong.Session.DB(mong.Database)
-	c := db.C("subscriptions")
+	c := db.C("groups")
 
-	err := c.Update(bson.M{
-		"project_uuid": projectUUID,
-		"name":         name,
-	},
-		bson.M{"$set": bson.M{
-			"push_type":            pushCfg.Type,
-			"push_endpoint":        pushCfg.PushEndpoint,
-			"authorization_type":   pushCfg.AuthorizationType,
-			"authorization_header": pushCfg.AuthorizationHeader,
-			"max_messages":         pushCfg.MaxMessages,
-			"retry_policy":         pushCfg.RetPolicy,
-			"retry_period":         pushCfg.RetPeriod,
-			"verification_hash":    pushCfg.VerificationHash,
-			"verified":             pushCfg.Verified,
-			"mattermost_url":       pushCfg.MattermostUrl,
-			"mattermost_username":  pushCfg.MattermostUsername,
-			"mattermost_channel":   pushCfg.MattermostChannel,
-			"base_64_decode":       pushCfg.Base64Decode,
-		},
-		})
-	return err
+	return c.Insert(group)
+}
+
+// QueryGroups returns the groups of a project, or a specific one when a uuid or a name is given
+func (mong *MongoStore) QueryGroups(ctx context.Context, projectUUID string, uuid string, name string) ([]QGroup, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("groups")
+
+	query := bson.M{"project_uuid": projectUUID}
+	if uuid != "" {
+		query["uuid"] = uuid
+	}
+	if name != "" {
+		query["name"] = name
+	}
+
+	results := []QGroup{}
+	err := c.Find(query).Sort("name").All(&results)
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "QueryGroups", err)
+	}
+	return results, err
+}
+
+// AppendToGroup adds the given users to the members of an existing group
+func (mong *MongoStore) AppendToGroup(ctx context.Context, projectUUID string, name string, members []string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("groups")
+
+	return c.Update(
+		bson.M{"project_uuid": projectUUID, "name": name},
+		bson.M{"$addToSet": bson.M{"members": bson.M{"$each": members}}},
+	)
+}
+
+// RemoveFromGroup removes the given users from the members of an existing group
+func (mong *MongoStore) RemoveFromGroup(ctx context.Context, projectUUID string, name string, members []string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("groups")
+
+	return c.Update(
+		bson.M{"project_uuid": projectUUID, "name": name},
+		bson.M{"$pullAll": bson.M{"members": members}},
+	)
+}
+
+// RemoveGroups removes a group of a project, or all of them when no name is given
+func (mong *MongoStore) RemoveGroups(ctx context.Context, projectUUID string, name string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("groups")
+
+	if name == "" {
+		_, err := c.RemoveAll(bson.M{"project_uuid": projectUUID})
+		return err
+	}
+
+	return c.Remove(bson.M{"project_uuid": projectUUID, "name": name})
+}
+
+// InsertAuditEntry stores a new entry of the audit log
+func (mong *MongoStore) InsertAuditEntry(ctx context.Context, entry QAuditEntry) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("audit_log")
+
+	return c.Insert(entry)
+}
+
+// QueryAuditEntries returns the entries of the audit log that pass the filter, the most recent first
+func (mong *MongoStore) QueryAuditEntries(ctx context.Context, filter QAuditFilter, pageToken string, pageSize int64) ([]QAuditEntry, int64, string, error) {
+
+	var qEntries []QAuditEntry
+	var limit int64
+	var nextPageToken string
+
+	// if the page size is other than zero(where zero means, no limit), try to grab one more document to check if there
+	// will be a next page after the current one
+	if pageSize > 0 {
+		limit = pageSize + 1
+	}
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("audit_log")
+
+	query := bson.M(filter.query())
+
+	// check the total of the entries selected by the query not taking into account pagination
+	size, err := c.Find(query).Count()
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "QueryAuditEntries", err)
+	}
+	totalSize := int64(size)
+
+	if pageToken != "" {
+		if !bson.IsObjectIdHex(pageToken) {
+			err = fmt.Errorf("Page token %v is not a valid bson ObjectId", pageToken)
+			log.WithFields(
+				log.Fields{
+					"type":            "backend_log",
+					"trace_id":        ctx.Value("trace_id"),
+					"backend_service": "mongo",
+					"page_token":      pageToken,
+				},
+			).Error("Page token is not a valid bson ObjectId")
+			return qEntries, totalSize, nextPageToken, err
+		}
+		query["_id"] = bson.M{"$lte": bson.ObjectIdHex(pageToken)}
+	}
+
+	if err = c.Find(query).Sort("-_id").Limit(int(limit)).All(&qEntries); err != nil {
+		mong.logErrorAndCrash(ctx, "QueryAuditEntries-2", err)
+	}
+
+	// pick the extra entry as the starting point of the next page
+	if pageSize > 0 && len(qEntries) == int(limit) {
+		nextPageToken = qEntries[limit-1].ID.(bson.ObjectId).Hex()
+		qEntries = qEntries[:len(qEntries)-1]
+	}
+
+	return qEntries, totalSize, nextPageToken, err
+}
+
+// QueryRateLimits returns the rate limits of a project or a user
+func (mong *MongoStore) QueryRateLimits(ctx context.Context, scopeType string, scopeUUID string) ([]QRateLimit, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("rate_limits")
+
+	var results []QRateLimits
+	err := c.Find(bson.M{"scope_type": scopeType, "scope_uuid": scopeUUID}).All(&results)
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "QueryRateLimits", err)
+	}
+
+	if len(results) == 0 {
+		return []QRateLimit{}, err
+	}
+
+	return results[0].Limits, err
+}
+
+// UpdateRateLimits replaces the rate limits of a project or a user, no limits remove them altogether
+func (mong *MongoStore) UpdateRateLimits(ctx context.Context, scopeType string, scopeUUID string, limits []QRateLimit) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("rate_limits")
+
+	doc := bson.M{"scope_type": scopeType, "scope_uuid": scopeUUID}
+
+	if len(limits) == 0 {
+		_, err := c.RemoveAll(doc)
+		return err
+	}
+
+	_, err := c.Upsert(doc, bson.M{"$set": bson.M{"limits": limits}})
+	return err
+}
+
+// IncrementRateCounter charges the counter of a rate limit for a window of time and returns its new state
+func (mong *MongoStore) IncrementRateCounter(ctx context.Context, key string, window time.Time, requests int64, bytes int64, expiresOn time.Time) (QRateCounter, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("rate_counters")
+
+	counter := QRateCounter{}
+	change := mgo.Change{
+		Update: bson.M{
+			"$inc":         bson.M{"requests": requests, "bytes": bytes},
+			"$setOnInsert": bson.M{"expires_on": expiresOn},
+		},
+		Upsert:    true,
+		ReturnNew: true,
+	}
+	_, err := c.Find(bson.M{"key": key, "window": window}).Apply(change, &counter)
+	return counter, err
+}
+
+// QueryProjectQuota returns the quotas of a project, a project without quotas has none of its values set
+func (mong *MongoStore) QueryProjectQuota(ctx context.Context, projectUUID string) (QProjectQuota, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("project_quotas")
+
+	var results []QProjectQuota
+	err := c.Find(bson.M{"project_uuid": projectUUID}).All(&results)
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "QueryProjectQuota", err)
+	}
+
+	if len(results) == 0 {
+		return QProjectQuota{ProjectUUID: projectUUID}, err
+	}
+
+	return results[0], err
+}
+
+// UpdateProjectQuota replaces the quotas of a project
+func (mong *MongoStore) UpdateProjectQuota(ctx context.Context, quota QProjectQuota) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("project_quotas")
+
+	_, err := c.Upsert(bson.M{"project_uuid": quota.ProjectUUID}, bson.M{"$set": bson.M{
+		"max_topics":        quota.MaxTopics,
+		"max_subscriptions": quota.MaxSubscriptions,
+		"max_users":         quota.MaxUsers,
+		"daily_messages":    quota.DailyMessages,
+		"daily_bytes":       quota.DailyBytes,
+	}})
+	return err
+}
+
+// QueryDailyProjectUsage returns the messages and bytes published to all of a project's topics during a day
+func (mong *MongoStore) QueryDailyProjectUsage(ctx context.Context, projectUUID string, date time.Time) (QDailyProjectUsage, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("daily_project_usage")
+
+	var results []QDailyProjectUsage
+	err := c.Find(bson.M{"project_uuid": projectUUID, "date": date}).All(&results)
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "QueryDailyProjectUsage", err)
+	}
+
+	if len(results) == 0 {
+		return QDailyProjectUsage{ProjectUUID: projectUUID, Date: date}, err
+	}
+
+	return results[0], err
+}
+
+// IncrementDailyProjectUsage increases the messages and bytes published to all of a project's topics during a day
+func (mong *MongoStore) IncrementDailyProjectUsage(ctx context.Context, projectUUID string, date time.Time, messages int64, bytes int64) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("daily_project_usage")
+
+	_, err := c.Upsert(
+		bson.M{"project_uuid": projectUUID, "date": date},
+		bson.M{"$inc": bson.M{"msg_count": messages, "bytes": bytes}},
+	)
+	return err
+}
+
+// ReserveDailyProjectUsage increases the messages and bytes published to all of a project's topics during a day,
+// only if they stay within the given maximums, 0 meaning no maximum. It reports whether they have been increased.
+// The check and the increase happen in a single conditional update, so concurrent publishes can't go over the maximums
+func (mong *MongoStore) ReserveDailyProjectUsage(ctx context.Context, projectUUID string, date time.Time, messages int64, bytes int64,
+	maxMessages int64, maxBytes int64) (bool, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("daily_project_usage")
+
+	// make sure that the usage of the day exists, so that the conditional update has a document to match
+	_, err := c.Upsert(
+		bson.M{"project_uuid": projectUUID, "date": date},
+		bson.M{"$inc": bson.M{"msg_count": 0, "bytes": 0}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	query := bson.M{"project_uuid": projectUUID, "date": date}
+	if maxMessages > 0 {
+		query["msg_count"] = bson.M{"$lte": maxMessages - messages}
+	}
+	if maxBytes > 0 {
+		query["bytes"] = bson.M{"$lte": maxBytes - bytes}
+	}
+
+	err = c.Update(query, bson.M{"$inc": bson.M{"msg_count": messages, "bytes": bytes}})
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RemoveProjectQuota removes the quotas of a project along with its daily usage
+func (mong *MongoStore) RemoveProjectQuota(ctx context.Context, projectUUID string) error {
+	db := mong.Session.DB(mong.Database)
+
+	_, err := db.C("project_quotas").RemoveAll(bson.M{"project_uuid": projectUUID})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.C("daily_project_usage").RemoveAll(bson.M{"project_uuid": projectUUID})
+	return err
+}
+
+// QueryNotificationTemplates returns the edited notification templates, all of them when no name is given
+func (mong *MongoStore) QueryNotificationTemplates(ctx context.Context, name string) ([]QNotificationTemplate, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("notification_templates")
+
+	query := bson.M{}
+	if name != "" {
+		query["name"] = name
+	}
+
+	results := []QNotificationTemplate{}
+	err := c.Find(query).All(&results)
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "QueryNotificationTemplates", err)
+	}
+	return results, err
+}
+
+// UpdateNotificationTemplate creates or replaces an edited notification template
+func (mong *MongoStore) UpdateNotificationTemplate(ctx context.Context, template QNotificationTemplate) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("notification_templates")
+
+	_, err := c.Upsert(bson.M{"name": template.Name}, bson.M{"$set": bson.M{
+		"subject": template.Subject,
+		"body":    template.Body,
+	}})
+	return err
+}
+
+// DeleteNotificationTemplate removes an edited notification template, restoring its default
+func (mong *MongoStore) DeleteNotificationTemplate(ctx context.Context, name string) error {
+	db := mong.Session.DB(mong.Database)
+
+	_, err := db.C("notification_templates").RemoveAll(bson.M{"name": name})
+	return err
+}
+
+// ModAck modifies the subscription's ack timeout field in mongodb
+func (mong *MongoStore) ModAck(ctx context.Context, projectUUID string, name string, ack int) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("subscriptions")
+	err := c.Update(bson.M{"project_uuid": projectUUID, "name": name}, bson.M{"$set": bson.M{"ack": ack}})
+	return err
+}
+
+// ModSubOutputFormat modifies the subscription's output format field in mongodb
+func (mong *MongoStore) ModSubOutputFormat(ctx context.Context, projectUUID string, name string, outputFormat string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("subscriptions")
+	err := c.Update(bson.M{"project_uuid": projectUUID, "name": name}, bson.M{"$set": bson.M{"output_format": outputFormat}})
+	return err
+}
+
+// ModSubPush modifies the push configuration
+func (mong *MongoStore) ModSubPush(ctx context.Context, projectUUID string, name string, pushCfg QPushConfig) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("subscriptions")
+
+	err := c.Update(bson.M{
+		"project_uuid": projectUUID,
+		"name":         name,
+	},
+		bson.M{"$set": bson.M{
+			"push_type":            pushCfg.Type,
+			"push_endpoint":        pushCfg.PushEndpoint,
+			"authorization_type":   pushCfg.AuthorizationType,
+			"authorization_header": pushCfg.AuthorizationHeader,
+			"max_messages":         pushCfg.MaxMessages,
+			"retry_policy":         pushCfg.RetPolicy,
+			"retry_period":         pushCfg.RetPeriod,
+			"verification_hash":    pushCfg.VerificationHash,
+			"verified":             pushCfg.Verified,
+			"mattermost_url":       pushCfg.MattermostUrl,
+			"mattermost_username":  pushCfg.MattermostUsername,
+			"mattermost_channel":   pushCfg.MattermostChannel,
+			"base_64_decode":       pushCfg.Base64Decode,
+			"schema_decode":        pushCfg.SchemaDecode,
+		},
+		})
+	return err
+}
+
+// InsertScheduledMessage holds back a message of a subscription until its delivery time
+func (mong *MongoStore) InsertScheduledMessage(ctx context.Context, projectUUID string, subscription string, offset int64, deliverAfter time.Time, message string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("scheduled_messages")
+
+	doc := bson.M{
+		"project_uuid": projectUUID,
+		"subscription": subscription,
+		"offset":       offset,
+	}
+
+	change := bson.M{
+		"$setOnInsert": bson.M{
+			"deliver_after": deliverAfter,
+			"delivered_at":  time.Time{},
+			"message":       message,
+		},
+	}
+
+	_, err := c.Upsert(doc, change)
+	return err
+}
+
+// QueryDueScheduledMessages returns the scheduled messages of a subscription that are due and not pending an ack
+func (mong *MongoStore) QueryDueScheduledMessages(ctx context.Context, projectUUID string, subscription string, maxOffset int64, dueBefore time.Time, deliveredBefore time.Time, limit int64) ([]QScheduledMessage, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("scheduled_messages")
+
+	query := bson.M{
+		"project_uuid":  projectUUID,
+		"subscription":  subscription,
+		"offset":        bson.M{"$lt": maxOffset},
+		"deliver_after": bson.M{"$lte": dueBefore},
+		"delivered_at":  bson.M{"$lte": deliveredBefore},
+	}
+
+	results := []QScheduledMessage{}
+	err := c.Find(query).Sort("deliver_after", "offset").Limit(int(limit)).All(&results)
+	return results, err
+}
+
+// UpdateScheduledMessagesDelivery marks the given scheduled messages as delivered at the given time
+func (mong *MongoStore) UpdateScheduledMessagesDelivery(ctx context.Context, projectUUID string, subscription string, offsets []int64, deliveredAt time.Time) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("scheduled_messages")
+
+	query := bson.M{
+		"project_uuid": projectUUID,
+		"subscription": subscription,
+		"offset":       bson.M{"$in": offsets},
+	}
+
+	_, err := c.UpdateAll(query, bson.M{"$set": bson.M{"delivered_at": deliveredAt}})
+	return err
+}
+
+// AckScheduledMessages removes the delivered scheduled messages that match the given offsets
+// and returns the offsets that got acknowledged
+func (mong *MongoStore) AckScheduledMessages(ctx context.Context, projectUUID string, subscription string, offsets []int64) ([]int64, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("scheduled_messages")
+
+	query := bson.M{
+		"project_uuid": projectUUID,
+		"subscription": subscription,
+		"offset":       bson.M{"$in": offsets},
+		"delivered_at": bson.M{"$gt": time.Time{}},
+	}
+
+	results := []QScheduledMessage{}
+	err := c.Find(query).All(&results)
+	if err != nil {
+		return nil, err
+	}
+
+	acked := []int64{}
+	for _, item := range results {
+		acked = append(acked, item.Offset)
+	}
+
+	if len(acked) == 0 {
+		return acked, nil
+	}
+
+	query["offset"] = bson.M{"$in": acked}
+	_, err = c.RemoveAll(query)
+	return acked, err
+}
+
+// DeleteScheduledMessages removes the scheduled messages of a subscription that match the given offsets
+func (mong *MongoStore) DeleteScheduledMessages(ctx context.Context, projectUUID string, subscription string, offsets []int64) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("scheduled_messages")
+
+	_, err := c.RemoveAll(bson.M{
+		"project_uuid": projectUUID,
+		"subscription": subscription,
+		"offset":       bson.M{"$in": offsets},
+	})
+	return err
+}
+
+// CountScheduledMessages returns the number of scheduled messages of a subscription that are due after the given time
+func (mong *MongoStore) CountScheduledMessages(ctx context.Context, projectUUID string, subscription string, dueAfter time.Time) (int64, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("scheduled_messages")
+
+	count, err := c.Find(bson.M{
+		"project_uuid":  projectUUID,
+		"subscription":  subscription,
+		"deliver_after": bson.M{"$gt": dueAfter},
+	}).Count()
+	return int64(count), err
+}
+
+// RemoveSubScheduledMessages removes all the scheduled messages of a subscription
+func (mong *MongoStore) RemoveSubScheduledMessages(ctx context.Context, projectUUID string, subscription string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("scheduled_messages")
+
+	_, err := c.RemoveAll(bson.M{"project_uuid": projectUUID, "subscription": subscription})
+	return err
+}
+
+// RemoveProjectScheduledMessages removes all the scheduled messages of a project's subscriptions
+func (mong *MongoStore) RemoveProjectScheduledMessages(ctx context.Context, projectUUID string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("scheduled_messages")
+
+	_, err := c.RemoveAll(bson.M{"project_uuid": projectUUID})
+	return err
+}
+
+// ReserveIdempotencyKey stores the given key of a topic as pending, unless it is already stored and has not expired
+func (mong *MongoStore) ReserveIdempotencyKey(ctx context.Context, projectUUID string, topic string, key string, createdOn time.Time, expiresOn time.Time) (bool, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("idempotency_keys")
+
+	// only an expired key matches, a live one makes the upsert collide with it on the unique index
+	doc := bson.M{
+		"project_uuid": projectUUID,
+		"topic":        topic,
+		"key":          key,
+		"expires_on":   bson.M{"$lte": createdOn},
+	}
+
+	change := bson.M{
+		"$set": bson.M{
+			"message_ids": []string{},
+			"pending":     true,
+			"created_on":  createdOn,
+			"expires_on":  expiresOn,
+		},
+	}
+
+	_, err := c.Upsert(doc, change)
+	if mgo.IsDup(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// InsertIdempotencyKey remembers the message ids that were published to a topic under the given key
+func (mong *MongoStore) InsertIdempotencyKey(ctx context.Context, projectUUID string, topic string, key string, msgIDs []string, createdOn time.Time, expiresOn time.Time) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("idempotency_keys")
+
+	doc := bson.M{
+		"project_uuid": projectUUID,
+		"topic":        topic,
+		"key":          key,
+	}
+
+	change := bson.M{
+		"$set": bson.M{
+			"message_ids": msgIDs,
+			"pending":     false,
+			"created_on":  createdOn,
+			"expires_on":  expiresOn,
+		},
+	}
+
+	_, err := c.Upsert(doc, change)
+	return err
+}
+
+// ReleaseIdempotencyKey removes the given key of a topic, as long as it is still pending
+func (mong *MongoStore) ReleaseIdempotencyKey(ctx context.Context, projectUUID string, topic string, key string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("idempotency_keys")
+
+	_, err := c.RemoveAll(bson.M{"project_uuid": projectUUID, "topic": topic, "key": key, "pending": true})
+	return err
+}
+
+// QueryIdempotencyKeys returns the keys of a topic, out of the given ones, that expire after the given time
+func (mong *MongoStore) QueryIdempotencyKeys(ctx context.Context, projectUUID string, topic string, keys []string, expiresAfter time.Time) ([]QIdempotencyKey, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("idempotency_keys")
+
+	query := bson.M{
+		"project_uuid": projectUUID,
+		"topic":        topic,
+		"key":          bson.M{"$in": keys},
+		"expires_on":   bson.M{"$gt": expiresAfter},
+	}
+
+	results := []QIdempotencyKey{}
+	err := c.Find(query).All(&results)
+	return results, err
+}
+
+// RemoveTopicIdempotencyKeys removes all the idempotency keys of a topic
+func (mong *MongoStore) RemoveTopicIdempotencyKeys(ctx context.Context, projectUUID string, topic string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("idempotency_keys")
+
+	_, err := c.RemoveAll(bson.M{"project_uuid": projectUUID, "topic": topic})
+	return err
+}
+
+// RemoveProjectIdempotencyKeys removes all the idempotency keys of a project's topics
+func (mong *MongoStore) RemoveProjectIdempotencyKeys(ctx context.Context, projectUUID string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("idempotency_keys")
+
+	_, err := c.RemoveAll(bson.M{"project_uuid": projectUUID})
+	return err
 }
 
 // InsertResource inserts a new topic object to the datastore
@@ -1697,7 +2597,7 @@ func (mong *MongoStore) QuerySubs(ctx context.Context, projectUUID, userUUID, na
 
 	// find all the subscriptions for a specific user
 	if userUUID != "" {
-		query["acl"] = bson.M{"$in": []string{userUUID}}
+		query["acl"] = bson.M{"$in": mong.aclEntries(ctx, projectUUID, userUUID)}
 	}
 
 	// if the page size is other than zero(where zero means, no limit), try to grab one more document to check if there
@@ -1743,7 +2643,7 @@ func (mong *MongoStore) QuerySubs(ctx context.Context, projectUUID, userUUID, na
 
 		countQuery := bson.M{"project_uuid": projectUUID}
 		if userUUID != "" {
-			countQuery["acl"] = bson.M{"$in": []string{userUUID}}
+			countQuery["acl"] = bson.M{"$in": mong.aclEntries(ctx, projectUUID, userUUID)}
 		}
 
 		if size, err = c.Find(countQuery).Count(); err != nil {
@@ -1782,13 +2682,16 @@ func (mong *MongoStore) QueryPushSubs(ctx context.Context) []QSub {
 
 }
 
-func (mong *MongoStore) InsertSchema(ctx context.Context, projectUUID, schemaUUID, name, schemaType, rawSchemaString string) error {
+func (mong *MongoStore) InsertSchema(ctx context.Context, projectUUID, schemaUUID, name, schemaType, rawSchemaString, compatibility string, revision int64, references []QSchemaReference) error {
 	sub := QSchema{
-		ProjectUUID: projectUUID,
-		UUID:        schemaUUID,
-		Name:        name,
-		Type:        schemaType,
-		RawSchema:   rawSchemaString,
+		ProjectUUID:   projectUUID,
+		UUID:          schemaUUID,
+		Name:          name,
+		Type:          schemaType,
+		RawSchema:     rawSchemaString,
+		Revision:      revision,
+		Compatibility: compatibility,
+		References:    references,
 	}
 	return mong.InsertResource(ctx, "schemas", sub)
 }
@@ -1819,8 +2722,9 @@ func (mong *MongoStore) QuerySchemas(ctx context.Context, projectUUID, schemaUUI
 	return results, nil
 }
 
-// UpdateSchema updates the fields of a schema
-func (mong *MongoStore) UpdateSchema(ctx context.Context, schemaUUID, name, schemaType, rawSchemaString string) error {
+// UpdateSchema updates the fields of a schema with a single write. Empty values keep the current ones,
+// while a new revision also replaces the references, since they are part of the content of the revision
+func (mong *MongoStore) UpdateSchema(ctx context.Context, schemaUUID, name, schemaType, rawSchemaString, compatibility string, revision int64, references []QSchemaReference) error {
 
 	db := mong.Session.DB(mong.Database)
 	c := db.C("schemas")
@@ -1841,11 +2745,120 @@ func (mong *MongoStore) UpdateSchema(ctx context.Context, schemaUUID, name, sche
 		updates["raw_schema"] = rawSchemaString
 	}
 
+	if compatibility != "" {
+		updates["compatibility"] = compatibility
+	}
+
+	if revision > 0 {
+		updates["revision"] = revision
+		updates["references"] = references
+	}
+
 	change := bson.M{"$set": updates}
 
 	return c.Update(selector, change)
 }
 
+// QuerySchemasByReference returns the schemas of a project whose current content depends on the given schema
+func (mong *MongoStore) QuerySchemasByReference(ctx context.Context, projectUUID, schemaUUID string) ([]QSchema, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("schemas")
+
+	results := []QSchema{}
+	err := c.Find(bson.M{"project_uuid": projectUUID, "references.schema_uuid": schemaUUID}).All(&results)
+	return results, err
+}
+
+// InsertSchemaRevision stores a new revision of a schema
+func (mong *MongoStore) InsertSchemaRevision(ctx context.Context, projectUUID, schemaUUID string, revision int64, schemaType, rawSchemaString string, references []QSchemaReference, createdOn time.Time) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("counters")
+
+	// schema revision ids are unique across projects and never reused
+	counter := QCounter{}
+	change := mgo.Change{
+		Update:    bson.M{"$inc": bson.M{"value": 1}},
+		Upsert:    true,
+		ReturnNew: true,
+	}
+	_, err := c.Find(bson.M{"name": "schema_revision_id"}).Apply(change, &counter)
+	if err != nil {
+		return err
+	}
+
+	rev := QSchemaRevision{
+		ID:          counter.Value,
+		ProjectUUID: projectUUID,
+		SchemaUUID:  schemaUUID,
+		Revision:    revision,
+		Type:        schemaType,
+		RawSchema:   rawSchemaString,
+		CreatedOn:   createdOn,
+		References:  references,
+	}
+
+	err = mong.InsertResource(ctx, "schema_revisions", rev)
+	if mgo.IsDup(err) {
+		return errors.New("exists")
+	}
+	return err
+}
+
+// QuerySchemaRevisions returns the revisions of a schema in ascending order, or only the given revision
+func (mong *MongoStore) QuerySchemaRevisions(ctx context.Context, projectUUID, schemaUUID string, revision int64) ([]QSchemaRevision, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("schema_revisions")
+
+	query := bson.M{"project_uuid": projectUUID, "schema_uuid": schemaUUID}
+	if revision > 0 {
+		query["revision"] = revision
+	}
+
+	results := []QSchemaRevision{}
+	err := c.Find(query).Sort("revision").All(&results)
+	return results, err
+}
+
+// QuerySchemaRevisionByID returns the schema revision with the given id
+func (mong *MongoStore) QuerySchemaRevisionByID(ctx context.Context, projectUUID string, id int64) ([]QSchemaRevision, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("schema_revisions")
+
+	results := []QSchemaRevision{}
+	err := c.Find(bson.M{"project_uuid": projectUUID, "id": id}).All(&results)
+	return results, err
+}
+
+// QuerySchemaRevisionsByReference returns the schema revisions of a project whose content depends on the given schema
+func (mong *MongoStore) QuerySchemaRevisionsByReference(ctx context.Context, projectUUID, schemaUUID string) ([]QSchemaRevision, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("schema_revisions")
+
+	results := []QSchemaRevision{}
+	err := c.Find(bson.M{"project_uuid": projectUUID, "references.schema_uuid": schemaUUID}).All(&results)
+	return results, err
+}
+
+// InsertSchemaInvalidation records a change to a schema
+func (mong *MongoStore) InsertSchemaInvalidation(ctx context.Context, projectUUID, schemaUUID string, invalidatedOn time.Time) error {
+	inv := QSchemaInvalidation{
+		ProjectUUID:   projectUUID,
+		SchemaUUID:    schemaUUID,
+		InvalidatedOn: invalidatedOn,
+	}
+	return mong.InsertResource(ctx, "schema_invalidations", inv)
+}
+
+// QuerySchemaInvalidations returns the schema changes that were recorded from the given time onwards
+func (mong *MongoStore) QuerySchemaInvalidations(ctx context.Context, since time.Time) ([]QSchemaInvalidation, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("schema_invalidations")
+
+	results := []QSchemaInvalidation{}
+	err := c.Find(bson.M{"invalidated_on": bson.M{"$gte": since}}).All(&results)
+	return results, err
+}
+
 // DeleteSchema removes the schema from the store
 // It also clears all the respective topics from the schema_uuid of the deleted schema
 func (mong *MongoStore) DeleteSchema(ctx context.Context, schemaUUID string) error {
@@ -1861,6 +2874,13 @@ func (mong *MongoStore) DeleteSchema(ctx context.Context, schemaUUID string) err
 		mong.logErrorAndCrash(ctx, "DeleteSchema", err)
 	}
 
+	revisions := db.C("schema_revisions")
+
+	_, err = revisions.RemoveAll(bson.M{"schema_uuid": schemaUUID})
+	if err != nil {
+		mong.logErrorAndCrash(ctx, "DeleteSchema-3", err)
+	}
+
 	topics := db.C("topics")
 
 	topicSelector := bson.M{"schema_uuid": schemaUUID}
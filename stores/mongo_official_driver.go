@@ -22,6 +22,22 @@ const UserRegistrationsCollection string = "user_registrations"
 const SchemasCollection string = "schemas"
 const OpMetricsCollection string = "op_metrics"
 const RolesCollection string = "roles"
+const ScheduledMessagesCollection string = "scheduled_messages"
+const IdempotencyKeysCollection string = "idempotency_keys"
+const APIKeysCollection string = "api_keys"
+const GroupsCollection string = "groups"
+const SchemaRevisionsCollection string = "schema_revisions"
+const SchemaInvalidationsCollection string = "schema_invalidations"
+const AuditLogCollection string = "audit_log"
+const RateLimitsCollection string = "rate_limits"
+const RateCountersCollection string = "rate_counters"
+const ProjectQuotasCollection string = "project_quotas"
+const DailyProjectUsageCollection string = "daily_project_usage"
+const NotificationTemplatesCollection string = "notification_templates"
+
+// schemaInvalidationsRetention is the number of seconds that schema invalidations are kept for
+const schemaInvalidationsRetention int32 = 24 * 60 * 60
+const CountersCollection string = "counters"
 
 type DocNotFound struct{}
 
@@ -73,13 +89,37 @@ type MongoStoreWithOfficialDriver struct {
 	schemasCollection             *mongo.Collection
 	rolesCollection               *mongo.Collection
 	opMetricsCollection           *mongo.Collection
-
-	topicsFindQueryProcessor            findQueryProcessor[QTopic]
-	subsFindQueryProcessor              findQueryProcessor[QSub]
-	usersFindQueryProcessor             findQueryProcessor[QUser]
-	projectsFindQueryProcessor          findQueryProcessor[QProject]
-	userRegistrationsFindQueryProcessor findQueryProcessor[QUserRegistration]
-	schemasFindQueryProcessor           findQueryProcessor[QSchema]
+	scheduledMessagesCollection   *mongo.Collection
+	idempotencyKeysCollection     *mongo.Collection
+	apiKeysCollection             *mongo.Collection
+	groupsCollection              *mongo.Collection
+	countersCollection            *mongo.Collection
+	schemaRevisionsCollection     *mongo.Collection
+	schemaInvalidationsCollection *mongo.Collection
+	auditLogCollection            *mongo.Collection
+	rateLimitsCollection          *mongo.Collection
+	rateCountersCollection        *mongo.Collection
+	projectQuotasCollection       *mongo.Collection
+	dailyProjectUsageCollection   *mongo.Collection
+	notificationTmplsCollection   *mongo.Collection
+
+	topicsFindQueryProcessor              findQueryProcessor[QTopic]
+	subsFindQueryProcessor                findQueryProcessor[QSub]
+	usersFindQueryProcessor               findQueryProcessor[QUser]
+	projectsFindQueryProcessor            findQueryProcessor[QProject]
+	userRegistrationsFindQueryProcessor   findQueryProcessor[QUserRegistration]
+	schemasFindQueryProcessor             findQueryProcessor[QSchema]
+	scheduledMessagesFindQueryProcessor   findQueryProcessor[QScheduledMessage]
+	idempotencyKeysFindQueryProcessor     findQueryProcessor[QIdempotencyKey]
+	apiKeysFindQueryProcessor             findQueryProcessor[QAPIKey]
+	groupsFindQueryProcessor              findQueryProcessor[QGroup]
+	schemaRevisionsFindQueryProcessor     findQueryProcessor[QSchemaRevision]
+	schemaInvalidationsFindQueryProcessor findQueryProcessor[QSchemaInvalidation]
+	auditLogFindQueryProcessor            findQueryProcessor[QAuditEntry]
+	rateLimitsFindQueryProcessor          findQueryProcessor[QRateLimits]
+	projectQuotasFindQueryProcessor       findQueryProcessor[QProjectQuota]
+	dailyProjectUsageFindQueryProcessor   findQueryProcessor[QDailyProjectUsage]
+	notificationTmplsFindQueryProcessor   findQueryProcessor[QNotificationTemplate]
 }
 
 func NewMongoStoreWithOfficialDriver(server, database string) *MongoStoreWithOfficialDriver {
@@ -189,9 +229,194 @@ func (store *MongoStoreWithOfficialDriver) Initialize() {
 		collection: store.schemasCollection,
 	}
 
+	store.schemaRevisionsCollection = store.database.Collection(SchemaRevisionsCollection)
+	store.schemaRevisionsFindQueryProcessor = findQueryProcessor[QSchemaRevision]{
+		collection: store.schemaRevisionsCollection,
+	}
+
+	store.countersCollection = store.database.Collection(CountersCollection)
+
 	store.topicsDailyMsgCountCollection = store.database.Collection(DailyTopicMsgCountCollection)
 	store.rolesCollection = store.database.Collection(RolesCollection)
 	store.opMetricsCollection = store.database.Collection(OpMetricsCollection)
+
+	store.scheduledMessagesCollection = store.database.Collection(ScheduledMessagesCollection)
+	store.scheduledMessagesFindQueryProcessor = findQueryProcessor[QScheduledMessage]{
+		collection: store.scheduledMessagesCollection,
+	}
+
+	store.idempotencyKeysCollection = store.database.Collection(IdempotencyKeysCollection)
+	store.idempotencyKeysFindQueryProcessor = findQueryProcessor[QIdempotencyKey]{
+		collection: store.idempotencyKeysCollection,
+	}
+
+	// let mongo discard the idempotency keys once their window has passed
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := store.idempotencyKeysCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_on", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys:    bson.D{{Key: "project_uuid", Value: 1}, {Key: "topic", Value: 1}, {Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "Initialize", err)
+	}
+
+	// api keys are looked up by their hash on every request they authenticate
+	store.apiKeysCollection = store.database.Collection(APIKeysCollection)
+	store.apiKeysFindQueryProcessor = findQueryProcessor[QAPIKey]{
+		collection: store.apiKeysCollection,
+	}
+
+	_, err = store.apiKeysCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "token_hash", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "user_uuid", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "Initialize", err)
+	}
+
+	// groups are looked up by their members on every acl check
+	store.groupsCollection = store.database.Collection(GroupsCollection)
+	store.groupsFindQueryProcessor = findQueryProcessor[QGroup]{
+		collection: store.groupsCollection,
+	}
+
+	_, err = store.groupsCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "project_uuid", Value: 1}, {Key: "members", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "project_uuid", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "Initialize", err)
+	}
+
+	// schema invalidations are only needed until every instance has caught up with them
+	store.schemaInvalidationsCollection = store.database.Collection(SchemaInvalidationsCollection)
+	store.schemaInvalidationsFindQueryProcessor = findQueryProcessor[QSchemaInvalidation]{
+		collection: store.schemaInvalidationsCollection,
+	}
+
+	_, err = store.schemaInvalidationsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "invalidated_on", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(schemaInvalidationsRetention),
+	})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "Initialize", err)
+	}
+
+	// concurrent updates of a schema can't store the same revision twice
+	_, err = store.schemaRevisionsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "schema_uuid", Value: 1}, {Key: "revision", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "Initialize", err)
+	}
+
+	// let mongo discard the audit log entries once their retention period has passed
+	store.auditLogCollection = store.database.Collection(AuditLogCollection)
+	store.auditLogFindQueryProcessor = findQueryProcessor[QAuditEntry]{
+		collection: store.auditLogCollection,
+	}
+
+	_, err = store.auditLogCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_on", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys: bson.D{{Key: "project_uuid", Value: 1}, {Key: "_id", Value: -1}},
+		},
+	})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "Initialize", err)
+	}
+
+	// rate limits are looked up on every request they apply to
+	store.rateLimitsCollection = store.database.Collection(RateLimitsCollection)
+	store.rateLimitsFindQueryProcessor = findQueryProcessor[QRateLimits]{
+		collection: store.rateLimitsCollection,
+	}
+
+	_, err = store.rateLimitsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "scope_type", Value: 1}, {Key: "scope_uuid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "Initialize", err)
+	}
+
+	// rate counters are only needed for as long as their window lasts
+	store.rateCountersCollection = store.database.Collection(RateCountersCollection)
+
+	_, err = store.rateCountersCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_on", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}, {Key: "window", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "Initialize", err)
+	}
+
+	// quotas and daily usage are looked up on every request that creates resources or publishes
+	store.projectQuotasCollection = store.database.Collection(ProjectQuotasCollection)
+	store.projectQuotasFindQueryProcessor = findQueryProcessor[QProjectQuota]{
+		collection: store.projectQuotasCollection,
+	}
+
+	_, err = store.projectQuotasCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "project_uuid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "Initialize", err)
+	}
+
+	store.dailyProjectUsageCollection = store.database.Collection(DailyProjectUsageCollection)
+	store.dailyProjectUsageFindQueryProcessor = findQueryProcessor[QDailyProjectUsage]{
+		collection: store.dailyProjectUsageCollection,
+	}
+
+	_, err = store.dailyProjectUsageCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "project_uuid", Value: 1}, {Key: "date", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "Initialize", err)
+	}
+
+	store.notificationTmplsCollection = store.database.Collection(NotificationTemplatesCollection)
+	store.notificationTmplsFindQueryProcessor = findQueryProcessor[QNotificationTemplate]{
+		collection: store.notificationTmplsCollection,
+	}
+
+	_, err = store.notificationTmplsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "Initialize", err)
+	}
 }
 
 func (store *MongoStoreWithOfficialDriver) Close() {
@@ -378,6 +603,47 @@ func (store *MongoStoreWithOfficialDriver) InsertResourceRoles(ctx context.Conte
 	return nil
 }
 
+// QueryResourceRoles returns the roles that each resource has been bound to
+func (store *MongoStoreWithOfficialDriver) QueryResourceRoles(ctx context.Context) ([]QRole, error) {
+	results := []QRole{}
+	findOptions := options.Find().SetSort(bson.M{"resource": 1})
+	cursor, err := store.rolesCollection.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QueryResourceRoles", err)
+		return results, err
+	}
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QueryResourceRoles", err)
+	}
+	return results, err
+}
+
+// AddResourceRole binds a role to a resource
+func (store *MongoStoreWithOfficialDriver) AddResourceRole(ctx context.Context, resource string, role string) error {
+	doc := bson.M{"resource": resource}
+	change := bson.M{"$addToSet": bson.M{"roles": role}}
+	_, err := store.rolesCollection.UpdateOne(ctx, doc, change, options.Update().SetUpsert(true))
+	if err != nil {
+		store.logErrorAndCrash(ctx, "AddResourceRole", err)
+	}
+	return err
+}
+
+// RemoveResourceRole unbinds a role from a resource, or from all of them when no resource is given
+func (store *MongoStoreWithOfficialDriver) RemoveResourceRole(ctx context.Context, resource string, role string) error {
+	doc := bson.M{}
+	if resource != "" {
+		doc["resource"] = resource
+	}
+	change := bson.M{"$pull": bson.M{"roles": role}}
+	_, err := store.rolesCollection.UpdateMany(ctx, doc, change)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "RemoveResourceRole", err)
+	}
+	return err
+}
+
 // GetAllRoles returns a list of all available roles
 func (store *MongoStoreWithOfficialDriver) GetAllRoles(ctx context.Context) []string {
 	cursor, err := store.rolesCollection.Distinct(ctx, "roles", bson.M{})
@@ -428,8 +694,8 @@ func (store *MongoStoreWithOfficialDriver) QueryACL(ctx context.Context, project
 	return QAcl{}, DocNotFound{}
 }
 
-// ExistsInACL checks if a user is part of a topic's or sub's acl
-func (store *MongoStoreWithOfficialDriver) ExistsInACL(ctx context.Context, projectUUID string, resource string, resourceName string, userUUID string) error {
+// ExistsInACL checks if a user is part of a topic's or sub's acl and is allowed the given action, an empty action matches any
+func (store *MongoStoreWithOfficialDriver) ExistsInACL(ctx context.Context, projectUUID string, resource string, resourceName string, userUUID string, action string) error {
 
 	var c *mongo.Collection
 	if resource == "topics" {
@@ -440,15 +706,17 @@ func (store *MongoStoreWithOfficialDriver) ExistsInACL(ctx context.Context, proj
 		return errors.New("wrong resource type")
 	}
 
+	entries := store.aclEntries(ctx, projectUUID, userUUID)
 	query := bson.M{
 		"project_uuid": projectUUID,
 		"name":         resourceName,
 		"acl": bson.M{
-			"$in": []string{userUUID},
+			"$in": entries,
 		},
 	}
 
-	err := c.FindOne(ctx, query).Err()
+	var qAcl QAcl
+	err := c.FindOne(ctx, query).Decode(&qAcl)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return DocNotFound{}
@@ -457,12 +725,16 @@ func (store *MongoStoreWithOfficialDriver) ExistsInACL(ctx context.Context, proj
 			return err
 		}
 	}
+
+	if !qAcl.Allows(entries, action) {
+		return DocNotFound{}
+	}
 	return nil
 }
 
-// ModACL modifies the push configuration
+// ModACL replaces the acl and the actions that its entries are restricted to, in a single update
 func (store *MongoStoreWithOfficialDriver) ModACL(ctx context.Context, projectUUID string,
-	resource string, name string, acl []string) error {
+	resource string, name string, acl []string, actions map[string][]string) error {
 	var c *mongo.Collection
 	if resource == "topics" {
 		c = store.topicsCollection
@@ -472,7 +744,7 @@ func (store *MongoStoreWithOfficialDriver) ModACL(ctx context.Context, projectUU
 		return errors.New("wrong resource type")
 	}
 	doc := bson.M{"project_uuid": projectUUID, "name": name}
-	change := bson.M{"$set": bson.M{"acl": acl}}
+	change := bson.M{"$set": bson.M{"acl": acl, "acl_actions": actions}}
 	_, err := c.UpdateOne(ctx, doc, change)
 	if err != nil {
 		store.logErrorAndCrash(ctx, "ModACL", err)
@@ -505,51 +777,474 @@ func (store *MongoStoreWithOfficialDriver) AppendToACL(ctx context.Context, proj
 		}}
 	_, err := c.UpdateOne(ctx, doc, change)
 	if err != nil {
-		store.logErrorAndCrash(ctx, "AppendToACL", err)
+		store.logErrorAndCrash(ctx, "AppendToACL", err)
+		return err
+	}
+
+	return nil
+}
+
+// RemoveFromACL removes users for a given ACL
+func (store *MongoStoreWithOfficialDriver) RemoveFromACL(ctx context.Context, projectUUID string, resource string, name string, acl []string) error {
+	var c *mongo.Collection
+	if resource == "topics" {
+		c = store.topicsCollection
+	} else if resource == "subscriptions" {
+		c = store.subscriptionsCollection
+	} else {
+		return errors.New("wrong resource type")
+	}
+
+	doc := bson.M{
+		"project_uuid": projectUUID,
+		"name":         name,
+	}
+	change := bson.M{
+		"$pullAll": bson.M{
+			"acl": acl,
+		},
+	}
+	if len(acl) > 0 {
+		change["$unset"] = aclActionsFields(acl)
+	}
+	_, err := c.UpdateOne(ctx, doc, change)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "RemoveFromACL", err)
+		return err
+	}
+	return nil
+}
+
+// aclEntries returns the acl entries that grant access to a user, the user itself and the groups of the project it is a member of
+func (store *MongoStoreWithOfficialDriver) aclEntries(ctx context.Context, projectUUID string, userUUID string) []string {
+
+	groups, err := store.groupsFindQueryProcessor.execute(ctx, bson.M{"project_uuid": projectUUID, "members": userUUID})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "aclEntries", err)
+	}
+
+	entries := []string{userUUID}
+	for _, group := range groups {
+		entries = append(entries, GroupACLEntry(group.UUID))
+	}
+	return entries
+}
+
+// ##### GROUP QUERIES #####
+
+// InsertGroup stores a new group of users of a project
+func (store *MongoStoreWithOfficialDriver) InsertGroup(ctx context.Context, group QGroup) error {
+	_, err := store.groupsCollection.InsertOne(ctx, group)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "InsertGroup", err)
+		return err
+	}
+	return nil
+}
+
+// QueryGroups returns the groups of a project, or a specific one when a uuid or a name is given
+func (store *MongoStoreWithOfficialDriver) QueryGroups(ctx context.Context, projectUUID string, uuid string, name string) ([]QGroup, error) {
+
+	query := bson.M{"project_uuid": projectUUID}
+	if uuid != "" {
+		query["uuid"] = uuid
+	}
+	if name != "" {
+		query["name"] = name
+	}
+
+	results, err := store.groupsFindQueryProcessor.execute(ctx, query, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QueryGroups", err)
+		return []QGroup{}, err
+	}
+
+	if results == nil {
+		results = []QGroup{}
+	}
+
+	return results, nil
+}
+
+// AppendToGroup adds the given users to the members of an existing group
+func (store *MongoStoreWithOfficialDriver) AppendToGroup(ctx context.Context, projectUUID string, name string, members []string) error {
+
+	doc := bson.M{"project_uuid": projectUUID, "name": name}
+	change := bson.M{"$addToSet": bson.M{"members": bson.M{"$each": members}}}
+
+	res, err := store.groupsCollection.UpdateOne(ctx, doc, change)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "AppendToGroup", err)
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return DocNotFound{}
+	}
+
+	return nil
+}
+
+// RemoveFromGroup removes the given users from the members of an existing group
+func (store *MongoStoreWithOfficialDriver) RemoveFromGroup(ctx context.Context, projectUUID string, name string, members []string) error {
+
+	doc := bson.M{"project_uuid": projectUUID, "name": name}
+	change := bson.M{"$pullAll": bson.M{"members": members}}
+
+	res, err := store.groupsCollection.UpdateOne(ctx, doc, change)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "RemoveFromGroup", err)
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return DocNotFound{}
+	}
+
+	return nil
+}
+
+// RemoveGroups removes a group of a project, or all of them when no name is given
+func (store *MongoStoreWithOfficialDriver) RemoveGroups(ctx context.Context, projectUUID string, name string) error {
+
+	query := bson.M{"project_uuid": projectUUID}
+	if name != "" {
+		query["name"] = name
+	}
+
+	res, err := store.groupsCollection.DeleteMany(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "RemoveGroups", err)
+		return err
+	}
+
+	if name != "" && res.DeletedCount == 0 {
+		return DocNotFound{}
+	}
+
+	return nil
+}
+
+// ##### AUDIT QUERIES #####
+
+// InsertAuditEntry stores a new entry of the audit log
+func (store *MongoStoreWithOfficialDriver) InsertAuditEntry(ctx context.Context, entry QAuditEntry) error {
+	_, err := store.auditLogCollection.InsertOne(ctx, entry)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "InsertAuditEntry", err)
+		return err
+	}
+	return nil
+}
+
+// QueryAuditEntries returns the entries of the audit log that pass the filter, the most recent first
+func (store *MongoStoreWithOfficialDriver) QueryAuditEntries(ctx context.Context, filter QAuditFilter,
+	pageToken string, pageSize int64) ([]QAuditEntry, int64, string, error) {
+
+	var qEntries []QAuditEntry
+	var limit int64
+	var nextPageToken string
+
+	// if the page size is other than zero(where zero means, no limit), try to grab one more document to check if there
+	// will be a next page after the current one
+	if pageSize > 0 {
+		limit = pageSize + 1
+	}
+
+	query := bson.M(filter.query())
+
+	// check the total of the entries selected by the query not taking into account pagination
+	totalSize, err := store.auditLogCollection.CountDocuments(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QueryAuditEntries", err)
+		return qEntries, totalSize, nextPageToken, err
+	}
+
+	if pageToken != "" {
+		bsonID, err := primitive.ObjectIDFromHex(pageToken)
+		if err != nil {
+			err = fmt.Errorf("page token %s is not a valid bson ObjectId. %s", pageToken, err.Error())
+			log.WithFields(
+				log.Fields{
+					"type":            "backend_log",
+					"trace_id":        ctx.Value("trace_id"),
+					"backend_service": "mongo",
+					"page_token":      pageToken,
+					"err":             err.Error(),
+				},
+			).Error("Page token is not a valid bson ObjectId")
+			return qEntries, totalSize, nextPageToken, err
+		}
+		query["_id"] = bson.M{"$lte": bsonID}
+	}
+
+	findOptions := options.Find().SetLimit(limit).SetSort(bson.M{"_id": -1})
+	qEntries, err = store.auditLogFindQueryProcessor.execute(ctx, query, findOptions)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QueryAuditEntries-2", err)
+		return qEntries, totalSize, nextPageToken, err
+	}
+
+	// pick the extra entry as the starting point of the next page
+	if pageSize > 0 && len(qEntries) == int(limit) {
+		nextPageToken = qEntries[limit-1].ID.(primitive.ObjectID).Hex()
+		qEntries = qEntries[:len(qEntries)-1]
+	}
+
+	if qEntries == nil {
+		qEntries = []QAuditEntry{}
+	}
+
+	return qEntries, totalSize, nextPageToken, nil
+}
+
+// ##### RATE LIMIT QUERIES #####
+
+// QueryRateLimits returns the rate limits of a project or a user
+func (store *MongoStoreWithOfficialDriver) QueryRateLimits(ctx context.Context, scopeType string,
+	scopeUUID string) ([]QRateLimit, error) {
+
+	query := bson.M{"scope_type": scopeType, "scope_uuid": scopeUUID}
+
+	results, err := store.rateLimitsFindQueryProcessor.execute(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QueryRateLimits", err)
+		return []QRateLimit{}, err
+	}
+
+	if len(results) == 0 {
+		return []QRateLimit{}, nil
+	}
+
+	return results[0].Limits, nil
+}
+
+// UpdateRateLimits replaces the rate limits of a project or a user, no limits remove them altogether
+func (store *MongoStoreWithOfficialDriver) UpdateRateLimits(ctx context.Context, scopeType string,
+	scopeUUID string, limits []QRateLimit) error {
+
+	doc := bson.M{"scope_type": scopeType, "scope_uuid": scopeUUID}
+
+	if len(limits) == 0 {
+		_, err := store.rateLimitsCollection.DeleteMany(ctx, doc)
+		if err != nil {
+			store.logErrorAndCrash(ctx, "UpdateRateLimits", err)
+		}
+		return err
+	}
+
+	err := store.upsert(ctx, doc, bson.M{"$set": bson.M{"limits": limits}}, store.rateLimitsCollection)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "UpdateRateLimits-2", err)
+	}
+	return err
+}
+
+// IncrementRateCounter charges the counter of a rate limit for a window of time and returns its new state
+func (store *MongoStoreWithOfficialDriver) IncrementRateCounter(ctx context.Context, key string,
+	window time.Time, requests int64, bytes int64, expiresOn time.Time) (QRateCounter, error) {
+
+	counter := QRateCounter{}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := store.rateCountersCollection.FindOneAndUpdate(ctx,
+		bson.M{"key": key, "window": window},
+		bson.M{
+			"$inc":         bson.M{"requests": requests, "bytes": bytes},
+			"$setOnInsert": bson.M{"expires_on": expiresOn},
+		}, opts).Decode(&counter)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "IncrementRateCounter", err)
+		return counter, err
+	}
+
+	return counter, nil
+}
+
+// ##### QUOTA QUERIES #####
+
+// QueryProjectQuota returns the quotas of a project, a project without quotas has none of its values set
+func (store *MongoStoreWithOfficialDriver) QueryProjectQuota(ctx context.Context,
+	projectUUID string) (QProjectQuota, error) {
+
+	results, err := store.projectQuotasFindQueryProcessor.execute(ctx, bson.M{"project_uuid": projectUUID})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QueryProjectQuota", err)
+		return QProjectQuota{}, err
+	}
+
+	if len(results) == 0 {
+		return QProjectQuota{ProjectUUID: projectUUID}, nil
+	}
+
+	return results[0], nil
+}
+
+// UpdateProjectQuota replaces the quotas of a project
+func (store *MongoStoreWithOfficialDriver) UpdateProjectQuota(ctx context.Context, quota QProjectQuota) error {
+
+	change := bson.M{
+		"$set": bson.M{
+			"max_topics":        quota.MaxTopics,
+			"max_subscriptions": quota.MaxSubscriptions,
+			"max_users":         quota.MaxUsers,
+			"daily_messages":    quota.DailyMessages,
+			"daily_bytes":       quota.DailyBytes,
+		},
+	}
+
+	err := store.upsert(ctx, bson.M{"project_uuid": quota.ProjectUUID}, change, store.projectQuotasCollection)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "UpdateProjectQuota", err)
+	}
+	return err
+}
+
+// QueryDailyProjectUsage returns the messages and bytes published to all of a project's topics during a day
+func (store *MongoStoreWithOfficialDriver) QueryDailyProjectUsage(ctx context.Context, projectUUID string,
+	date time.Time) (QDailyProjectUsage, error) {
+
+	query := bson.M{"project_uuid": projectUUID, "date": date}
+
+	results, err := store.dailyProjectUsageFindQueryProcessor.execute(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QueryDailyProjectUsage", err)
+		return QDailyProjectUsage{}, err
+	}
+
+	if len(results) == 0 {
+		return QDailyProjectUsage{ProjectUUID: projectUUID, Date: date}, nil
+	}
+
+	return results[0], nil
+}
+
+// IncrementDailyProjectUsage increases the messages and bytes published to all of a project's topics during a day
+func (store *MongoStoreWithOfficialDriver) IncrementDailyProjectUsage(ctx context.Context, projectUUID string,
+	date time.Time, messages int64, bytes int64) error {
+
+	err := store.upsert(ctx,
+		bson.M{"project_uuid": projectUUID, "date": date},
+		bson.M{"$inc": bson.M{"msg_count": messages, "bytes": bytes}},
+		store.dailyProjectUsageCollection)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "IncrementDailyProjectUsage", err)
+	}
+	return err
+}
+
+// ReserveDailyProjectUsage increases the messages and bytes published to all of a project's topics during a day,
+// only if they stay within the given maximums, 0 meaning no maximum. It reports whether they have been increased.
+// The check and the increase happen in a single conditional update, so concurrent publishes can't go over the maximums
+func (store *MongoStoreWithOfficialDriver) ReserveDailyProjectUsage(ctx context.Context, projectUUID string,
+	date time.Time, messages int64, bytes int64, maxMessages int64, maxBytes int64) (bool, error) {
+
+	// make sure that the usage of the day exists, so that the conditional update has a document to match
+	err := store.upsert(ctx,
+		bson.M{"project_uuid": projectUUID, "date": date},
+		bson.M{"$inc": bson.M{"msg_count": 0, "bytes": 0}},
+		store.dailyProjectUsageCollection)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "ReserveDailyProjectUsage", err)
+		return false, err
+	}
+
+	query := bson.M{"project_uuid": projectUUID, "date": date}
+	if maxMessages > 0 {
+		query["msg_count"] = bson.M{"$lte": maxMessages - messages}
+	}
+	if maxBytes > 0 {
+		query["bytes"] = bson.M{"$lte": maxBytes - bytes}
+	}
+
+	res, err := store.dailyProjectUsageCollection.UpdateOne(ctx, query,
+		bson.M{"$inc": bson.M{"msg_count": messages, "bytes": bytes}})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "ReserveDailyProjectUsage", err)
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+// RemoveProjectQuota removes the quotas of a project along with its daily usage
+func (store *MongoStoreWithOfficialDriver) RemoveProjectQuota(ctx context.Context, projectUUID string) error {
+
+	query := bson.M{"project_uuid": projectUUID}
+
+	_, err := store.projectQuotasCollection.DeleteMany(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "RemoveProjectQuota", err)
 		return err
 	}
 
-	return nil
+	_, err = store.dailyProjectUsageCollection.DeleteMany(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "RemoveProjectQuota-2", err)
+	}
+	return err
 }
 
-// RemoveFromACL removes users for a given ACL
-func (store *MongoStoreWithOfficialDriver) RemoveFromACL(ctx context.Context, projectUUID string, resource string, name string, acl []string) error {
-	var c *mongo.Collection
-	if resource == "topics" {
-		c = store.topicsCollection
-	} else if resource == "subscriptions" {
-		c = store.subscriptionsCollection
-	} else {
-		return errors.New("wrong resource type")
+// ##### NOTIFICATION QUERIES #####
+
+// QueryNotificationTemplates returns the edited notification templates, all of them when no name is given
+func (store *MongoStoreWithOfficialDriver) QueryNotificationTemplates(ctx context.Context,
+	name string) ([]QNotificationTemplate, error) {
+
+	query := bson.M{}
+	if name != "" {
+		query["name"] = name
 	}
 
-	doc := bson.M{
-		"project_uuid": projectUUID,
-		"name":         name,
+	results, err := store.notificationTmplsFindQueryProcessor.execute(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QueryNotificationTemplates", err)
+		return []QNotificationTemplate{}, err
 	}
+
+	return results, nil
+}
+
+// UpdateNotificationTemplate creates or replaces an edited notification template
+func (store *MongoStoreWithOfficialDriver) UpdateNotificationTemplate(ctx context.Context,
+	template QNotificationTemplate) error {
+
 	change := bson.M{
-		"$pullAll": bson.M{
-			"acl": acl,
+		"$set": bson.M{
+			"subject": template.Subject,
+			"body":    template.Body,
 		},
 	}
-	_, err := c.UpdateOne(ctx, doc, change)
+
+	err := store.upsert(ctx, bson.M{"name": template.Name}, change, store.notificationTmplsCollection)
 	if err != nil {
-		store.logErrorAndCrash(ctx, "RemoveFromACL", err)
-		return err
+		store.logErrorAndCrash(ctx, "UpdateNotificationTemplate", err)
 	}
-	return nil
+	return err
+}
+
+// DeleteNotificationTemplate removes an edited notification template, restoring its default
+func (store *MongoStoreWithOfficialDriver) DeleteNotificationTemplate(ctx context.Context, name string) error {
+
+	_, err := store.notificationTmplsCollection.DeleteMany(ctx, bson.M{"name": name})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "DeleteNotificationTemplate", err)
+	}
+	return err
 }
 
 // ##### SCHEMA QUERIES #####
 
 func (store *MongoStoreWithOfficialDriver) InsertSchema(ctx context.Context, projectUUID, schemaUUID, name,
-	schemaType, rawSchemaString string) error {
+	schemaType, rawSchemaString, compatibility string, revision int64, references []QSchemaReference) error {
 	schema := QSchema{
-		ProjectUUID: projectUUID,
-		UUID:        schemaUUID,
-		Name:        name,
-		Type:        schemaType,
-		RawSchema:   rawSchemaString,
+		ProjectUUID:   projectUUID,
+		UUID:          schemaUUID,
+		Name:          name,
+		Type:          schemaType,
+		RawSchema:     rawSchemaString,
+		Revision:      revision,
+		Compatibility: compatibility,
+		References:    references,
 	}
 	_, err := store.schemasCollection.InsertOne(ctx, schema)
 	if err != nil {
@@ -581,7 +1276,10 @@ func (store *MongoStoreWithOfficialDriver) QuerySchemas(ctx context.Context, pro
 
 }
 
-func (store *MongoStoreWithOfficialDriver) UpdateSchema(ctx context.Context, schemaUUID, name, schemaType, rawSchemaString string) error {
+// UpdateSchema updates the fields of a schema with a single write. Empty values keep the current ones,
+// while a new revision also replaces the references, since they are part of the content of the revision
+func (store *MongoStoreWithOfficialDriver) UpdateSchema(ctx context.Context, schemaUUID, name, schemaType,
+	rawSchemaString, compatibility string, revision int64, references []QSchemaReference) error {
 
 	doc := bson.M{"uuid": schemaUUID}
 
@@ -599,6 +1297,15 @@ func (store *MongoStoreWithOfficialDriver) UpdateSchema(ctx context.Context, sch
 		updates["raw_schema"] = rawSchemaString
 	}
 
+	if compatibility != "" {
+		updates["compatibility"] = compatibility
+	}
+
+	if revision > 0 {
+		updates["revision"] = revision
+		updates["references"] = references
+	}
+
 	change := bson.M{"$set": updates}
 	_, err := store.schemasCollection.UpdateOne(ctx, doc, change)
 	if err != nil {
@@ -629,9 +1336,164 @@ func (store *MongoStoreWithOfficialDriver) DeleteSchema(ctx context.Context, sch
 		return err
 	}
 
+	_, err = store.schemaRevisionsCollection.DeleteMany(ctx, bson.M{"schema_uuid": schemaUUID})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "DeleteSchema-3", err)
+		return err
+	}
+
+	return nil
+}
+
+// QuerySchemasByReference returns the schemas of a project whose current content depends on the given schema
+func (store *MongoStoreWithOfficialDriver) QuerySchemasByReference(ctx context.Context, projectUUID,
+	schemaUUID string) ([]QSchema, error) {
+
+	query := bson.M{"project_uuid": projectUUID, "references.schema_uuid": schemaUUID}
+	results, err := store.schemasFindQueryProcessor.execute(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QuerySchemasByReference", err)
+		return []QSchema{}, err
+	}
+
+	if results == nil {
+		results = []QSchema{}
+	}
+
+	return results, nil
+}
+
+// InsertSchemaRevision stores a new revision of a schema
+func (store *MongoStoreWithOfficialDriver) InsertSchemaRevision(ctx context.Context, projectUUID, schemaUUID string,
+	revision int64, schemaType, rawSchemaString string, references []QSchemaReference, createdOn time.Time) error {
+
+	// schema revision ids are unique across projects and never reused
+	counter := QCounter{}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := store.countersCollection.FindOneAndUpdate(ctx, bson.M{"name": "schema_revision_id"},
+		bson.M{"$inc": bson.M{"value": 1}}, opts).Decode(&counter)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "InsertSchemaRevision", err)
+		return err
+	}
+
+	rev := QSchemaRevision{
+		ID:          counter.Value,
+		ProjectUUID: projectUUID,
+		SchemaUUID:  schemaUUID,
+		Revision:    revision,
+		Type:        schemaType,
+		RawSchema:   rawSchemaString,
+		CreatedOn:   createdOn,
+		References:  references,
+	}
+	// the revision is already taken when another update of the schema got there first
+	_, err = store.schemaRevisionsCollection.InsertOne(ctx, rev)
+	if mongo.IsDuplicateKeyError(err) {
+		return errors.New("exists")
+	}
+	if err != nil {
+		store.logErrorAndCrash(ctx, "InsertSchemaRevision", err)
+		return err
+	}
+	return nil
+}
+
+// QuerySchemaRevisions returns the revisions of a schema in ascending order, or only the given revision
+func (store *MongoStoreWithOfficialDriver) QuerySchemaRevisions(ctx context.Context, projectUUID, schemaUUID string,
+	revision int64) ([]QSchemaRevision, error) {
+
+	query := bson.M{"project_uuid": projectUUID, "schema_uuid": schemaUUID}
+	if revision > 0 {
+		query["revision"] = revision
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "revision", Value: 1}})
+	results, err := store.schemaRevisionsFindQueryProcessor.execute(ctx, query, findOptions)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QuerySchemaRevisions", err)
+		return []QSchemaRevision{}, err
+	}
+
+	if results == nil {
+		results = []QSchemaRevision{}
+	}
+
+	return results, nil
+}
+
+// QuerySchemaRevisionByID returns the schema revision with the given id
+func (store *MongoStoreWithOfficialDriver) QuerySchemaRevisionByID(ctx context.Context, projectUUID string,
+	id int64) ([]QSchemaRevision, error) {
+
+	query := bson.M{"project_uuid": projectUUID, "id": id}
+	results, err := store.schemaRevisionsFindQueryProcessor.execute(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QuerySchemaRevisionByID", err)
+		return []QSchemaRevision{}, err
+	}
+
+	if results == nil {
+		results = []QSchemaRevision{}
+	}
+
+	return results, nil
+}
+
+// QuerySchemaRevisionsByReference returns the schema revisions of a project whose content depends on the given schema
+func (store *MongoStoreWithOfficialDriver) QuerySchemaRevisionsByReference(ctx context.Context, projectUUID,
+	schemaUUID string) ([]QSchemaRevision, error) {
+
+	query := bson.M{"project_uuid": projectUUID, "references.schema_uuid": schemaUUID}
+	results, err := store.schemaRevisionsFindQueryProcessor.execute(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QuerySchemaRevisionsByReference", err)
+		return []QSchemaRevision{}, err
+	}
+
+	if results == nil {
+		results = []QSchemaRevision{}
+	}
+
+	return results, nil
+}
+
+// InsertSchemaInvalidation records a change to a schema
+func (store *MongoStoreWithOfficialDriver) InsertSchemaInvalidation(ctx context.Context, projectUUID, schemaUUID string,
+	invalidatedOn time.Time) error {
+
+	inv := QSchemaInvalidation{
+		ProjectUUID:   projectUUID,
+		SchemaUUID:    schemaUUID,
+		InvalidatedOn: invalidatedOn,
+	}
+
+	_, err := store.schemaInvalidationsCollection.InsertOne(ctx, inv)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "InsertSchemaInvalidation", err)
+		return err
+	}
 	return nil
 }
 
+// QuerySchemaInvalidations returns the schema changes that were recorded from the given time onwards
+func (store *MongoStoreWithOfficialDriver) QuerySchemaInvalidations(ctx context.Context,
+	since time.Time) ([]QSchemaInvalidation, error) {
+
+	query := bson.M{"invalidated_on": bson.M{"$gte": since}}
+	results, err := store.schemaInvalidationsFindQueryProcessor.execute(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QuerySchemaInvalidations", err)
+		return []QSchemaInvalidation{}, err
+	}
+
+	if results == nil {
+		results = []QSchemaInvalidation{}
+	}
+
+	return results, nil
+}
+
 // ##### PROJECT QUERIES #####
 
 // QueryProjects queries the database for a specific project or a list of all projects
@@ -998,6 +1860,24 @@ func (store *MongoStoreWithOfficialDriver) UpdateRegistration(ctx context.Contex
 	return nil
 }
 
+// ConfirmRegistrationEmail marks the email of a registration as confirmed
+func (store *MongoStoreWithOfficialDriver) ConfirmRegistrationEmail(ctx context.Context, regUUID, confirmedAt string) error {
+
+	doc := bson.M{"uuid": regUUID}
+	change := bson.M{
+		"$set": bson.M{
+			"email_confirmed_at": confirmedAt,
+		},
+	}
+	_, err := store.userRegistrationsCollection.UpdateOne(ctx, doc, change)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "ConfirmRegistrationEmail", err)
+		return err
+	}
+
+	return nil
+}
+
 // ###### USER QUERIES ######
 
 // HasUsers accepts a user array of usernames and returns the not found
@@ -1257,6 +2137,28 @@ func (store *MongoStoreWithOfficialDriver) UpdateUserToken(ctx context.Context,
 	return err
 }
 
+// UpdateUserDN updates the certificate subject and issuer of the user
+func (store *MongoStoreWithOfficialDriver) UpdateUserDN(ctx context.Context, uuid string, dn string, issuerDN string) error {
+	doc := bson.M{"uuid": uuid}
+	change := bson.M{"$set": bson.M{"dn": dn, "issuer_dn": issuerDN}}
+	_, err := store.usersCollection.UpdateOne(ctx, doc, change)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "UpdateUserDN", err)
+	}
+	return err
+}
+
+// UpdateUserOIDCSubject updates the OIDC subject of the user
+func (store *MongoStoreWithOfficialDriver) UpdateUserOIDCSubject(ctx context.Context, uuid string, subject string) error {
+	doc := bson.M{"uuid": uuid}
+	change := bson.M{"$set": bson.M{"oidc_subject": subject}}
+	_, err := store.usersCollection.UpdateOne(ctx, doc, change)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "UpdateUserOIDCSubject", err)
+	}
+	return err
+}
+
 func (store *MongoStoreWithOfficialDriver) RemoveUser(ctx context.Context, uuid string) error {
 	user := bson.M{"uuid": uuid}
 	_, err := store.usersCollection.DeleteOne(ctx, user)
@@ -1284,42 +2186,179 @@ func (store *MongoStoreWithOfficialDriver) InsertUser(ctx context.Context, uuid
 		ModifiedOn:   modifiedOn,
 		CreatedBy:    createdBy,
 	}
-	_, err := store.usersCollection.InsertOne(ctx, user)
+	_, err := store.usersCollection.InsertOne(ctx, user)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "InsertUser", err)
+	}
+	return err
+}
+
+// GetUserFromToken returns user information from a specific token
+func (store *MongoStoreWithOfficialDriver) GetUserFromToken(ctx context.Context, token string) (QUser, error) {
+
+	query := bson.M{"token": token}
+	results, err := store.usersFindQueryProcessor.execute(ctx, query)
+
+	if err != nil {
+		store.logErrorAndCrash(ctx, "GetUserFromToken", err)
+		return QUser{}, err
+	}
+
+	if len(results) == 0 {
+		return QUser{}, DocNotFound{}
+	}
+
+	if len(results) > 1 {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"trace_id":        ctx.Value("trace_id"),
+				"token":           token,
+				"backend_service": "mongo",
+				"backend_hosts":   store.Server,
+			},
+		).Warning("Multiple users with the same token")
+	}
+
+	// Search the found user for project roles
+	return results[0], err
+}
+
+// GetUserFromEmail returns user information from a specific email
+func (store *MongoStoreWithOfficialDriver) GetUserFromEmail(ctx context.Context, email string) (QUser, error) {
+
+	query := bson.M{"email": email}
+	results, err := store.usersFindQueryProcessor.execute(ctx, query)
+
+	if err != nil {
+		store.logErrorAndCrash(ctx, "GetUserFromEmail", err)
+		return QUser{}, err
+	}
+
+	if email == "" || len(results) == 0 {
+		return QUser{}, DocNotFound{}
+	}
+
+	// an email shared by several users doesn't identify any of them
+	if len(results) > 1 {
+		return QUser{}, errors.New("multiple users")
+	}
+
+	return results[0], err
+}
+
+// GetUserFromDN returns user information from a specific certificate subject and issuer
+func (store *MongoStoreWithOfficialDriver) GetUserFromDN(ctx context.Context, dn string, issuerDN string) (QUser, error) {
+
+	query := bson.M{"dn": dn, "issuer_dn": issuerDN}
+	results, err := store.usersFindQueryProcessor.execute(ctx, query)
+
+	if err != nil {
+		store.logErrorAndCrash(ctx, "GetUserFromDN", err)
+		return QUser{}, err
+	}
+
+	if dn == "" || len(results) == 0 {
+		return QUser{}, DocNotFound{}
+	}
+
+	return results[0], err
+}
+
+// GetUserFromOIDCSubject returns user information from a specific OIDC subject
+func (store *MongoStoreWithOfficialDriver) GetUserFromOIDCSubject(ctx context.Context, subject string) (QUser, error) {
+
+	query := bson.M{"oidc_subject": subject}
+	results, err := store.usersFindQueryProcessor.execute(ctx, query)
+
+	if err != nil {
+		store.logErrorAndCrash(ctx, "GetUserFromOIDCSubject", err)
+		return QUser{}, err
+	}
+
+	if subject == "" || len(results) == 0 {
+		return QUser{}, DocNotFound{}
+	}
+
+	return results[0], err
+}
+
+// InsertAPIKey stores a new api key of a user
+func (store *MongoStoreWithOfficialDriver) InsertAPIKey(ctx context.Context, key QAPIKey) error {
+	_, err := store.apiKeysCollection.InsertOne(ctx, key)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "InsertAPIKey", err)
+	}
+	return err
+}
+
+// QueryAPIKeys returns the api keys of a user, or a specific one when a name is given
+func (store *MongoStoreWithOfficialDriver) QueryAPIKeys(ctx context.Context, userUUID string, name string) ([]QAPIKey, error) {
+
+	query := bson.M{"user_uuid": userUUID}
+	if name != "" {
+		query["name"] = name
+	}
+
+	results, err := store.apiKeysFindQueryProcessor.execute(ctx, query, options.Find().SetSort(bson.D{{Key: "created_on", Value: 1}}))
 	if err != nil {
-		store.logErrorAndCrash(ctx, "InsertUser", err)
+		store.logErrorAndCrash(ctx, "QueryAPIKeys", err)
+		return []QAPIKey{}, err
 	}
-	return err
-}
 
-// GetUserFromToken returns user information from a specific token
-func (store *MongoStoreWithOfficialDriver) GetUserFromToken(ctx context.Context, token string) (QUser, error) {
+	if results == nil {
+		results = []QAPIKey{}
+	}
 
-	query := bson.M{"token": token}
-	results, err := store.usersFindQueryProcessor.execute(ctx, query)
+	return results, nil
+}
 
+// GetAPIKeyByHash returns the api key with the given hash
+func (store *MongoStoreWithOfficialDriver) GetAPIKeyByHash(ctx context.Context, tokenHash string) (QAPIKey, error) {
+
+	results, err := store.apiKeysFindQueryProcessor.execute(ctx, bson.M{"token_hash": tokenHash})
 	if err != nil {
-		store.logErrorAndCrash(ctx, "GetUserFromToken", err)
-		return QUser{}, err
+		store.logErrorAndCrash(ctx, "GetAPIKeyByHash", err)
+		return QAPIKey{}, err
 	}
 
 	if len(results) == 0 {
-		return QUser{}, DocNotFound{}
+		return QAPIKey{}, DocNotFound{}
 	}
 
-	if len(results) > 1 {
-		log.WithFields(
-			log.Fields{
-				"type":            "backend_log",
-				"trace_id":        ctx.Value("trace_id"),
-				"token":           token,
-				"backend_service": "mongo",
-				"backend_hosts":   store.Server,
-			},
-		).Warning("Multiple users with the same token")
+	return results[0], nil
+}
+
+// UpdateAPIKeyLastUsed records when an api key was last used
+func (store *MongoStoreWithOfficialDriver) UpdateAPIKeyLastUsed(ctx context.Context, userUUID string, name string, lastUsedOn time.Time) error {
+	doc := bson.M{"user_uuid": userUUID, "name": name}
+	change := bson.M{"$set": bson.M{"last_used_on": lastUsedOn}}
+	_, err := store.apiKeysCollection.UpdateOne(ctx, doc, change)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "UpdateAPIKeyLastUsed", err)
 	}
+	return err
+}
 
-	// Search the found user for project roles
-	return results[0], err
+// RemoveAPIKeys removes an api key of a user, or all of them when no name is given
+func (store *MongoStoreWithOfficialDriver) RemoveAPIKeys(ctx context.Context, userUUID string, name string) error {
+
+	query := bson.M{"user_uuid": userUUID}
+	if name != "" {
+		query["name"] = name
+	}
+
+	res, err := store.apiKeysCollection.DeleteMany(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "RemoveAPIKeys", err)
+		return err
+	}
+
+	if name != "" && res.DeletedCount == 0 {
+		return DocNotFound{}
+	}
+
+	return nil
 }
 
 // UsersCount returns the amount of users created in the given time period per project
@@ -1424,7 +2463,7 @@ func (store *MongoStoreWithOfficialDriver) GetUserRoles(ctx context.Context, pro
 	}
 
 	// Search the found user for project roles
-	return results[0].getProjectRoles(projectUUID), results[0].Name
+	return results[0].ProjectRoles(projectUUID), results[0].Name
 
 }
 
@@ -1478,7 +2517,7 @@ func (store *MongoStoreWithOfficialDriver) QuerySubs(ctx context.Context, projec
 
 	// find all the topics for a specific user
 	if userUUID != "" {
-		query["acl"] = bson.M{"$in": []string{userUUID}}
+		query["acl"] = bson.M{"$in": store.aclEntries(ctx, projectUUID, userUUID)}
 	}
 
 	// if the page size is other than zero(where zero means, no limit), try to grab one more document to check if there
@@ -1520,7 +2559,7 @@ func (store *MongoStoreWithOfficialDriver) QuerySubs(ctx context.Context, projec
 
 		countQuery := bson.M{"project_uuid": projectUUID}
 		if userUUID != "" {
-			countQuery["acl"] = bson.M{"$in": []string{userUUID}}
+			countQuery["acl"] = bson.M{"$in": store.aclEntries(ctx, projectUUID, userUUID)}
 		}
 
 		totalSize, err = store.subscriptionsCollection.CountDocuments(ctx, countQuery)
@@ -1608,6 +2647,17 @@ func (store *MongoStoreWithOfficialDriver) IncrementSubBytes(ctx context.Context
 	return err
 }
 
+// IncrementSubStoredBytes increases the total number of bytes consumed from a subscription, as stored in the broker
+func (store *MongoStoreWithOfficialDriver) IncrementSubStoredBytes(ctx context.Context, projectUUID string, name string, storedBytes int64) error {
+	doc := bson.M{"project_uuid": projectUUID, "name": name}
+	change := bson.M{"$inc": bson.M{"stored_bytes": storedBytes}}
+	_, err := store.subscriptionsCollection.UpdateOne(ctx, doc, change)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "IncrementSubStoredBytes", err)
+	}
+	return err
+}
+
 // IncrementSubMsgNum increments the number of messages pulled in a subscription
 func (store *MongoStoreWithOfficialDriver) IncrementSubMsgNum(ctx context.Context, projectUUID string, name string, num int64) error {
 	doc := bson.M{"project_uuid": projectUUID, "name": name}
@@ -1619,6 +2669,17 @@ func (store *MongoStoreWithOfficialDriver) IncrementSubMsgNum(ctx context.Contex
 	return err
 }
 
+// IncrementSubExpiredMsgNum increments the number of expired messages skipped in a subscription
+func (store *MongoStoreWithOfficialDriver) IncrementSubExpiredMsgNum(ctx context.Context, projectUUID string, name string, num int64) error {
+	doc := bson.M{"project_uuid": projectUUID, "name": name}
+	change := bson.M{"$inc": bson.M{"expired_msg_num": num}}
+	_, err := store.subscriptionsCollection.UpdateOne(ctx, doc, change)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "IncrementSubExpiredMsgNum", err)
+	}
+	return err
+}
+
 func (store *MongoStoreWithOfficialDriver) InsertSub(ctx context.Context, projectUUID string, name string, topic string,
 	offset int64, ack int, pushCfg QPushConfig, createdOn time.Time) error {
 
@@ -1643,6 +2704,7 @@ func (store *MongoStoreWithOfficialDriver) InsertSub(ctx context.Context, projec
 		MattermostChannel:   pushCfg.MattermostChannel,
 		MattermostUsername:  pushCfg.MattermostUsername,
 		Base64Decode:        pushCfg.Base64Decode,
+		SchemaDecode:        pushCfg.SchemaDecode,
 		MsgNum:              0,
 		TotalBytes:          0,
 		CreatedOn:           createdOn,
@@ -1704,6 +2766,17 @@ func (store *MongoStoreWithOfficialDriver) ModAck(ctx context.Context, projectUU
 	return err
 }
 
+// ModSubOutputFormat modifies the subscription's output format field in mongodb
+func (store *MongoStoreWithOfficialDriver) ModSubOutputFormat(ctx context.Context, projectUUID string, name string, outputFormat string) error {
+	doc := bson.M{"project_uuid": projectUUID, "name": name}
+	change := bson.M{"$set": bson.M{"output_format": outputFormat}}
+	_, err := store.subscriptionsCollection.UpdateOne(ctx, doc, change)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "ModSubOutputFormat", err)
+	}
+	return err
+}
+
 // UpdateSubOffset updates a subscription offset
 func (store *MongoStoreWithOfficialDriver) UpdateSubOffset(ctx context.Context, projectUUID string, name string, offset int64) {
 	doc := bson.M{"project_uuid": projectUUID, "name": name}
@@ -1801,6 +2874,7 @@ func (store *MongoStoreWithOfficialDriver) ModSubPush(ctx context.Context, proje
 		"mattermost_username":  pushCfg.MattermostUsername,
 		"mattermost_channel":   pushCfg.MattermostChannel,
 		"base_64_decode":       pushCfg.Base64Decode,
+		"schema_decode":        pushCfg.SchemaDecode,
 	},
 	}
 	ur, err := store.subscriptionsCollection.UpdateOne(ctx, doc, change)
@@ -1815,6 +2889,297 @@ func (store *MongoStoreWithOfficialDriver) ModSubPush(ctx context.Context, proje
 	return nil
 }
 
+// ##### SCHEDULED MESSAGES QUERIES #####
+
+// InsertScheduledMessage holds back a message of a subscription until its delivery time
+func (store *MongoStoreWithOfficialDriver) InsertScheduledMessage(ctx context.Context, projectUUID string,
+	subscription string, offset int64, deliverAfter time.Time, message string) error {
+
+	doc := bson.M{
+		"project_uuid": projectUUID,
+		"subscription": subscription,
+		"offset":       offset,
+	}
+
+	// keep the delivery state of an already scheduled message intact
+	change := bson.M{
+		"$setOnInsert": bson.M{
+			"deliver_after": deliverAfter,
+			"delivered_at":  time.Time{},
+			"message":       message,
+		},
+	}
+
+	err := store.upsert(ctx, doc, change, store.scheduledMessagesCollection)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "InsertScheduledMessage", err)
+		return err
+	}
+	return nil
+}
+
+// QueryDueScheduledMessages returns the scheduled messages of a subscription that are due and not pending an ack
+func (store *MongoStoreWithOfficialDriver) QueryDueScheduledMessages(ctx context.Context, projectUUID string,
+	subscription string, maxOffset int64, dueBefore time.Time, deliveredBefore time.Time, limit int64) ([]QScheduledMessage, error) {
+
+	query := bson.M{
+		"project_uuid":  projectUUID,
+		"subscription":  subscription,
+		"offset":        bson.M{"$lt": maxOffset},
+		"deliver_after": bson.M{"$lte": dueBefore},
+		"delivered_at":  bson.M{"$lte": deliveredBefore},
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "deliver_after", Value: 1}, {Key: "offset", Value: 1}})
+	if limit > 0 {
+		findOptions.SetLimit(limit)
+	}
+
+	results, err := store.scheduledMessagesFindQueryProcessor.execute(ctx, query, findOptions)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QueryDueScheduledMessages", err)
+		return []QScheduledMessage{}, err
+	}
+
+	if results == nil {
+		results = []QScheduledMessage{}
+	}
+
+	return results, nil
+}
+
+// UpdateScheduledMessagesDelivery marks the given scheduled messages as delivered at the given time
+func (store *MongoStoreWithOfficialDriver) UpdateScheduledMessagesDelivery(ctx context.Context, projectUUID string,
+	subscription string, offsets []int64, deliveredAt time.Time) error {
+
+	query := bson.M{
+		"project_uuid": projectUUID,
+		"subscription": subscription,
+		"offset":       bson.M{"$in": offsets},
+	}
+
+	_, err := store.scheduledMessagesCollection.UpdateMany(ctx, query, bson.M{"$set": bson.M{"delivered_at": deliveredAt}})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "UpdateScheduledMessagesDelivery", err)
+		return err
+	}
+	return nil
+}
+
+// AckScheduledMessages removes the delivered scheduled messages that match the given offsets
+// and returns the offsets that got acknowledged
+func (store *MongoStoreWithOfficialDriver) AckScheduledMessages(ctx context.Context, projectUUID string,
+	subscription string, offsets []int64) ([]int64, error) {
+
+	query := bson.M{
+		"project_uuid": projectUUID,
+		"subscription": subscription,
+		"offset":       bson.M{"$in": offsets},
+		"delivered_at": bson.M{"$gt": time.Time{}},
+	}
+
+	results, err := store.scheduledMessagesFindQueryProcessor.execute(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "AckScheduledMessages", err)
+		return []int64{}, err
+	}
+
+	acked := []int64{}
+	for _, item := range results {
+		acked = append(acked, item.Offset)
+	}
+
+	if len(acked) == 0 {
+		return acked, nil
+	}
+
+	query["offset"] = bson.M{"$in": acked}
+	_, err = store.scheduledMessagesCollection.DeleteMany(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "AckScheduledMessages", err)
+		return []int64{}, err
+	}
+
+	return acked, nil
+}
+
+// DeleteScheduledMessages removes the scheduled messages of a subscription that match the given offsets
+func (store *MongoStoreWithOfficialDriver) DeleteScheduledMessages(ctx context.Context, projectUUID string,
+	subscription string, offsets []int64) error {
+
+	query := bson.M{
+		"project_uuid": projectUUID,
+		"subscription": subscription,
+		"offset":       bson.M{"$in": offsets},
+	}
+
+	_, err := store.scheduledMessagesCollection.DeleteMany(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "DeleteScheduledMessages", err)
+		return err
+	}
+	return nil
+}
+
+// CountScheduledMessages returns the number of scheduled messages of a subscription that are due after the given time
+func (store *MongoStoreWithOfficialDriver) CountScheduledMessages(ctx context.Context, projectUUID string,
+	subscription string, dueAfter time.Time) (int64, error) {
+
+	query := bson.M{
+		"project_uuid":  projectUUID,
+		"subscription":  subscription,
+		"deliver_after": bson.M{"$gt": dueAfter},
+	}
+
+	count, err := store.scheduledMessagesCollection.CountDocuments(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "CountScheduledMessages", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// RemoveSubScheduledMessages removes all the scheduled messages of a subscription
+func (store *MongoStoreWithOfficialDriver) RemoveSubScheduledMessages(ctx context.Context, projectUUID string, subscription string) error {
+	query := bson.M{"project_uuid": projectUUID, "subscription": subscription}
+	_, err := store.scheduledMessagesCollection.DeleteMany(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "RemoveSubScheduledMessages", err)
+		return err
+	}
+	return nil
+}
+
+// RemoveProjectScheduledMessages removes all the scheduled messages of a project's subscriptions
+func (store *MongoStoreWithOfficialDriver) RemoveProjectScheduledMessages(ctx context.Context, projectUUID string) error {
+	query := bson.M{"project_uuid": projectUUID}
+	_, err := store.scheduledMessagesCollection.DeleteMany(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "RemoveProjectScheduledMessages", err)
+		return err
+	}
+	return nil
+}
+
+// ##### IDEMPOTENCY KEY QUERIES #####
+
+// ReserveIdempotencyKey stores the given key of a topic as pending, unless it is already stored and has not expired
+func (store *MongoStoreWithOfficialDriver) ReserveIdempotencyKey(ctx context.Context, projectUUID string,
+	topic string, key string, createdOn time.Time, expiresOn time.Time) (bool, error) {
+
+	// only an expired key matches, a live one makes the upsert collide with it on the unique index
+	doc := bson.M{
+		"project_uuid": projectUUID,
+		"topic":        topic,
+		"key":          key,
+		"expires_on":   bson.M{"$lte": createdOn},
+	}
+
+	change := bson.M{
+		"$set": bson.M{
+			"message_ids": []string{},
+			"pending":     true,
+			"created_on":  createdOn,
+			"expires_on":  expiresOn,
+		},
+	}
+
+	err := store.upsert(ctx, doc, change, store.idempotencyKeysCollection)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		store.logErrorAndCrash(ctx, "ReserveIdempotencyKey", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// InsertIdempotencyKey remembers the message ids that were published to a topic under the given key
+func (store *MongoStoreWithOfficialDriver) InsertIdempotencyKey(ctx context.Context, projectUUID string,
+	topic string, key string, msgIDs []string, createdOn time.Time, expiresOn time.Time) error {
+
+	doc := bson.M{
+		"project_uuid": projectUUID,
+		"topic":        topic,
+		"key":          key,
+	}
+
+	// an expired key that mongo has not discarded yet gets overwritten
+	change := bson.M{
+		"$set": bson.M{
+			"message_ids": msgIDs,
+			"pending":     false,
+			"created_on":  createdOn,
+			"expires_on":  expiresOn,
+		},
+	}
+
+	err := store.upsert(ctx, doc, change, store.idempotencyKeysCollection)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "InsertIdempotencyKey", err)
+		return err
+	}
+	return nil
+}
+
+// QueryIdempotencyKeys returns the keys of a topic, out of the given ones, that expire after the given time
+func (store *MongoStoreWithOfficialDriver) QueryIdempotencyKeys(ctx context.Context, projectUUID string,
+	topic string, keys []string, expiresAfter time.Time) ([]QIdempotencyKey, error) {
+
+	query := bson.M{
+		"project_uuid": projectUUID,
+		"topic":        topic,
+		"key":          bson.M{"$in": keys},
+		"expires_on":   bson.M{"$gt": expiresAfter},
+	}
+
+	results, err := store.idempotencyKeysFindQueryProcessor.execute(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QueryIdempotencyKeys", err)
+		return []QIdempotencyKey{}, err
+	}
+
+	if results == nil {
+		results = []QIdempotencyKey{}
+	}
+
+	return results, nil
+}
+
+// ReleaseIdempotencyKey removes the given key of a topic, as long as it is still pending
+func (store *MongoStoreWithOfficialDriver) ReleaseIdempotencyKey(ctx context.Context, projectUUID string, topic string, key string) error {
+	query := bson.M{"project_uuid": projectUUID, "topic": topic, "key": key, "pending": true}
+	_, err := store.idempotencyKeysCollection.DeleteOne(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "ReleaseIdempotencyKey", err)
+		return err
+	}
+	return nil
+}
+
+// RemoveTopicIdempotencyKeys removes all the idempotency keys of a topic
+func (store *MongoStoreWithOfficialDriver) RemoveTopicIdempotencyKeys(ctx context.Context, projectUUID string, topic string) error {
+	query := bson.M{"project_uuid": projectUUID, "topic": topic}
+	_, err := store.idempotencyKeysCollection.DeleteMany(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "RemoveTopicIdempotencyKeys", err)
+		return err
+	}
+	return nil
+}
+
+// RemoveProjectIdempotencyKeys removes all the idempotency keys of a project's topics
+func (store *MongoStoreWithOfficialDriver) RemoveProjectIdempotencyKeys(ctx context.Context, projectUUID string) error {
+	query := bson.M{"project_uuid": projectUUID}
+	_, err := store.idempotencyKeysCollection.DeleteMany(ctx, query)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "RemoveProjectIdempotencyKeys", err)
+		return err
+	}
+	return nil
+}
+
 // ###### TOPIC QUERIES ######
 
 func (store *MongoStoreWithOfficialDriver) LinkTopicSchema(ctx context.Context, projectUUID, name, schemaUUID string) error {
@@ -1829,6 +3194,39 @@ func (store *MongoStoreWithOfficialDriver) LinkTopicSchema(ctx context.Context,
 	return nil
 }
 
+// ModTopicMessageTTL modifies the topic's message ttl field in mongodb
+func (store *MongoStoreWithOfficialDriver) ModTopicMessageTTL(ctx context.Context, projectUUID string, name string, messageTTL int64) error {
+	doc := bson.M{"project_uuid": projectUUID, "name": name}
+	change := bson.M{"$set": bson.M{"message_ttl": messageTTL}}
+	_, err := store.topicsCollection.UpdateOne(ctx, doc, change)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "ModTopicMessageTTL", err)
+	}
+	return err
+}
+
+// ModTopicQuarantineTopic modifies the topic's quarantine topic field in mongodb
+func (store *MongoStoreWithOfficialDriver) ModTopicQuarantineTopic(ctx context.Context, projectUUID string, name string, quarantineTopic string) error {
+	doc := bson.M{"project_uuid": projectUUID, "name": name}
+	change := bson.M{"$set": bson.M{"quarantine_topic": quarantineTopic}}
+	_, err := store.topicsCollection.UpdateOne(ctx, doc, change)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "ModTopicQuarantineTopic", err)
+	}
+	return err
+}
+
+// ModTopicCompression modifies the topic's compression field in mongodb
+func (store *MongoStoreWithOfficialDriver) ModTopicCompression(ctx context.Context, projectUUID string, name string, compression string) error {
+	doc := bson.M{"project_uuid": projectUUID, "name": name}
+	change := bson.M{"$set": bson.M{"compression": compression}}
+	_, err := store.topicsCollection.UpdateOne(ctx, doc, change)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "ModTopicCompression", err)
+	}
+	return err
+}
+
 // QueryTopicsByACL returns topics that a specific username has access to
 func (store *MongoStoreWithOfficialDriver) QueryTopicsByACL(ctx context.Context, projectUUID, user string) ([]QTopic, error) {
 
@@ -1850,6 +3248,15 @@ func (store *MongoStoreWithOfficialDriver) QueryTopicsByACL(ctx context.Context,
 
 }
 
+// QueryAllTopics returns the topics of all projects
+func (store *MongoStoreWithOfficialDriver) QueryAllTopics(ctx context.Context) ([]QTopic, error) {
+	results, err := store.topicsFindQueryProcessor.execute(ctx, bson.M{})
+	if err != nil {
+		store.logErrorAndCrash(ctx, "QueryAllTopics", err)
+	}
+	return results, err
+}
+
 func (store *MongoStoreWithOfficialDriver) QueryTopics(ctx context.Context, projectUUID string, userUUID string, name string, pageToken string, pageSize int64) ([]QTopic, int64, string, error) {
 
 	var err error
@@ -1863,7 +3270,7 @@ func (store *MongoStoreWithOfficialDriver) QueryTopics(ctx context.Context, proj
 
 	// find all the topics for a specific user
 	if userUUID != "" {
-		query["acl"] = bson.M{"$in": []string{userUUID}}
+		query["acl"] = bson.M{"$in": store.aclEntries(ctx, projectUUID, userUUID)}
 	}
 
 	// if the page size is other than zero(where zero means, no limit), try to grab one more document to check if there
@@ -1905,7 +3312,7 @@ func (store *MongoStoreWithOfficialDriver) QueryTopics(ctx context.Context, proj
 
 		countQuery := bson.M{"project_uuid": projectUUID}
 		if userUUID != "" {
-			countQuery["acl"] = bson.M{"$in": []string{userUUID}}
+			countQuery["acl"] = bson.M{"$in": store.aclEntries(ctx, projectUUID, userUUID)}
 		}
 
 		totalSize, err = store.topicsCollection.CountDocuments(ctx, countQuery)
@@ -2087,3 +3494,17 @@ func (store *MongoStoreWithOfficialDriver) IncrementTopicBytes(ctx context.Conte
 	}
 	return err
 }
+
+// IncrementTopicStoredBytes increases the total number of bytes stored in the broker for a topic
+func (store *MongoStoreWithOfficialDriver) IncrementTopicStoredBytes(ctx context.Context, projectUUID string,
+	name string, storedBytes int64) error {
+
+	doc := bson.M{"project_uuid": projectUUID, "name": name}
+	change := bson.M{"$inc": bson.M{"stored_bytes": storedBytes}}
+
+	_, err := store.topicsCollection.UpdateOne(ctx, doc, change)
+	if err != nil {
+		store.logErrorAndCrash(ctx, "IncrementTopicStoredBytes", err)
+	}
+	return err
+}
@@ -179,14 +179,14 @@ func (suite *MongoStoreIntegrationTestSuite) initDB() {
 		}
 	}
 
-	qTopicACL01 := QAcl{[]string{"uuid1", "uuid2"}}
-	qTopicACL02 := QAcl{[]string{"uuid1", "uuid2", "uuid4"}}
-	qTopicACL03 := QAcl{[]string{"uuid3"}}
+	qTopicACL01 := QAcl{ACL: []string{"uuid1", "uuid2"}}
+	qTopicACL02 := QAcl{ACL: []string{"uuid1", "uuid2", "uuid4"}}
+	qTopicACL03 := QAcl{ACL: []string{"uuid3"}}
 
-	qSubACL01 := QAcl{[]string{"uuid1", "uuid2"}}
-	qSubACL02 := QAcl{[]string{"uuid1", "uuid3"}}
-	qSubACL03 := QAcl{[]string{"uuid4", "uuid2", "uuid1"}}
-	qSubACL04 := QAcl{[]string{"uuid2", "uuid4", "uuid7"}}
+	qSubACL01 := QAcl{ACL: []string{"uuid1", "uuid2"}}
+	qSubACL02 := QAcl{ACL: []string{"uuid1", "uuid3"}}
+	qSubACL03 := QAcl{ACL: []string{"uuid4", "uuid2", "uuid1"}}
+	qSubACL04 := QAcl{ACL: []string{"uuid2", "uuid4", "uuid7"}}
 
 	suite.TopicsACL = make(map[string]QAcl)
 	suite.SubsACL = make(map[string]QAcl)
@@ -252,7 +252,7 @@ func (suite *MongoStoreIntegrationTestSuite) initDB() {
 		if err != nil {
 			panic("could not insert topics")
 		}
-		err = suite.store.ModACL(suite.ctx, qTopic.ProjectUUID, "topics", qTopic.Name, qTopic.ACL)
+		err = suite.store.ModACL(suite.ctx, qTopic.ProjectUUID, "topics", qTopic.Name, qTopic.ACL, nil)
 		if err != nil {
 			panic("could not mod topics acl")
 		}
@@ -360,7 +360,7 @@ func (suite *MongoStoreIntegrationTestSuite) initDB() {
 		if err != nil {
 			panic("could not insert subs")
 		}
-		err = suite.store.ModACL(suite.ctx, qSub.ProjectUUID, "subscriptions", qSub.Name, qSub.ACL)
+		err = suite.store.ModACL(suite.ctx, qSub.ProjectUUID, "subscriptions", qSub.Name, qSub.ACL, nil)
 		if err != nil {
 			panic("could not mod subs acl")
 		}
@@ -428,7 +428,8 @@ func (suite *MongoStoreIntegrationTestSuite) initDB() {
 	}
 	suite.SchemaList = append(suite.SchemaList, qSchema1, qSchema2, qSchema3)
 	for _, qSchema := range suite.SchemaList {
-		err := suite.store.InsertSchema(suite.ctx, qSchema.ProjectUUID, qSchema.UUID, qSchema.Name, qSchema.Type, qSchema.RawSchema)
+		err := suite.store.InsertSchema(suite.ctx, qSchema.ProjectUUID, qSchema.UUID, qSchema.Name, qSchema.Type, qSchema.RawSchema,
+			qSchema.Compatibility, qSchema.Revision, qSchema.References)
 		if err != nil {
 			panic("could not insert schema")
 		}
@@ -649,6 +650,13 @@ func (suite *MongoStoreIntegrationTestSuite) TestIncrementSubBytes() {
 	_ = suite.store.IncrementSubBytes(suite.ctx, "argo_uuid", "sub1", -50)
 }
 
+func (suite *MongoStoreIntegrationTestSuite) TestIncrementSubStoredBytes() {
+	_ = suite.store.IncrementSubStoredBytes(suite.ctx, "argo_uuid", "sub1", 30)
+	sub, _ := suite.store.QueryOneSub(suite.ctx, "argo_uuid", "sub1")
+	suite.Equal(int64(30), sub.StoredBytes)
+	_ = suite.store.IncrementSubStoredBytes(suite.ctx, "argo_uuid", "sub1", -30)
+}
+
 func (suite *MongoStoreIntegrationTestSuite) TestIncrementSubMsgNum() {
 	_ = suite.store.IncrementSubMsgNum(suite.ctx, "argo_uuid", "sub1", 50)
 	sub, _ := suite.store.QueryOneSub(suite.ctx, "argo_uuid", "sub1")
@@ -656,12 +664,46 @@ func (suite *MongoStoreIntegrationTestSuite) TestIncrementSubMsgNum() {
 	_ = suite.store.IncrementSubMsgNum(suite.ctx, "argo_uuid", "sub1", -50)
 }
 
+func (suite *MongoStoreIntegrationTestSuite) TestIncrementSubExpiredMsgNum() {
+	_ = suite.store.IncrementSubExpiredMsgNum(suite.ctx, "argo_uuid", "sub1", 5)
+	sub, _ := suite.store.QueryOneSub(suite.ctx, "argo_uuid", "sub1")
+	suite.Equal(int64(5), sub.ExpiredMsgNum)
+	_ = suite.store.IncrementSubExpiredMsgNum(suite.ctx, "argo_uuid", "sub1", -5)
+}
+
+func (suite *MongoStoreIntegrationTestSuite) TestModTopicMessageTTL() {
+	suite.Nil(suite.store.ModTopicMessageTTL(suite.ctx, "argo_uuid", "topic1", 3600))
+	tpList, _, _, _ := suite.store.QueryTopics(suite.ctx, "argo_uuid", "", "topic1", "", 0)
+	suite.Equal(int64(3600), tpList[0].MessageTTL)
+	suite.Nil(suite.store.ModTopicMessageTTL(suite.ctx, "argo_uuid", "topic1", 0))
+}
+
+func (suite *MongoStoreIntegrationTestSuite) TestModTopicQuarantineTopic() {
+	suite.Nil(suite.store.ModTopicQuarantineTopic(suite.ctx, "argo_uuid", "topic1", "topic2"))
+	tpList, _, _, _ := suite.store.QueryTopics(suite.ctx, "argo_uuid", "", "topic1", "", 0)
+	suite.Equal("topic2", tpList[0].QuarantineTopic)
+	suite.Nil(suite.store.ModTopicQuarantineTopic(suite.ctx, "argo_uuid", "topic1", ""))
+}
+
+func (suite *MongoStoreIntegrationTestSuite) TestModTopicCompression() {
+	suite.Nil(suite.store.ModTopicCompression(suite.ctx, "argo_uuid", "topic1", "gzip"))
+	tpList, _, _, _ := suite.store.QueryTopics(suite.ctx, "argo_uuid", "", "topic1", "", 0)
+	suite.Equal("gzip", tpList[0].Compression)
+	suite.Nil(suite.store.ModTopicCompression(suite.ctx, "argo_uuid", "topic1", ""))
+}
+
 func (suite *MongoStoreIntegrationTestSuite) TestQueryTopicsByACL() {
 	eTopList1st1 := []QTopic{suite.TopicList[0], suite.TopicList[1]}
 	tpList, _ := suite.store.QueryTopicsByACL(suite.ctx, "argo_uuid", "uuid1")
 	suite.assertTopicsEqual(eTopList1st1, tpList)
 }
 
+func (suite *MongoStoreIntegrationTestSuite) TestQueryAllTopics() {
+	tpList, err := suite.store.QueryAllTopics(suite.ctx)
+	suite.Nil(err)
+	suite.assertTopicsEqual(suite.TopicList, tpList)
+}
+
 func (suite *MongoStoreIntegrationTestSuite) TestIncrementTopicBytes() {
 	_ = suite.store.IncrementTopicBytes(suite.ctx, "argo_uuid", "topic1", 50)
 	tpList4, _, _, _ := suite.store.QueryTopics(suite.ctx, "argo_uuid", "", "topic1", "", 0)
@@ -669,6 +711,13 @@ func (suite *MongoStoreIntegrationTestSuite) TestIncrementTopicBytes() {
 	_ = suite.store.IncrementTopicBytes(suite.ctx, "argo_uuid", "topic1", -50)
 }
 
+func (suite *MongoStoreIntegrationTestSuite) TestIncrementTopicStoredBytes() {
+	_ = suite.store.IncrementTopicStoredBytes(suite.ctx, "argo_uuid", "topic1", 30)
+	tpList, _, _, _ := suite.store.QueryTopics(suite.ctx, "argo_uuid", "", "topic1", "", 0)
+	suite.Equal(int64(30), tpList[0].StoredBytes)
+	_ = suite.store.IncrementTopicStoredBytes(suite.ctx, "argo_uuid", "topic1", -30)
+}
+
 func (suite *MongoStoreIntegrationTestSuite) TestIncrementTopicMsgNum() {
 	_ = suite.store.IncrementTopicMsgNum(suite.ctx, "argo_uuid", "topic1", 50)
 	tpList4, _, _, _ := suite.store.QueryTopics(suite.ctx, "argo_uuid", "", "topic1", "", 0)
@@ -830,6 +879,29 @@ func (suite *MongoStoreIntegrationTestSuite) TestGetAllRoles() {
 	suite.Equal([]string{"admin", "publisher", "reader"}, roles)
 }
 
+func (suite *MongoStoreIntegrationTestSuite) TestResourceRoles() {
+
+	suite.Nil(suite.store.AddResourceRole(suite.ctx, "topics:publish", "viewer"))
+	suite.Nil(suite.store.AddResourceRole(suite.ctx, "topics:publish", "viewer"))
+	suite.Nil(suite.store.AddResourceRole(suite.ctx, "metrics:opMetrics", "viewer"))
+
+	resRoles, err := suite.store.QueryResourceRoles(suite.ctx)
+	suite.Nil(err)
+	suite.Equal([]QRole{
+		{"metrics:opMetrics", []string{"viewer"}},
+		{"topics:list_all", []string{"admin", "reader", "publisher"}},
+		{"topics:publish", []string{"admin", "publisher", "viewer"}},
+	}, resRoles)
+
+	suite.Nil(suite.store.RemoveResourceRole(suite.ctx, "topics:publish", "viewer"))
+	suite.False(suite.store.HasResourceRoles(suite.ctx, "topics:publish", []string{"viewer"}))
+	suite.True(suite.store.HasResourceRoles(suite.ctx, "metrics:opMetrics", []string{"viewer"}))
+
+	suite.Nil(suite.store.RemoveResourceRole(suite.ctx, "", "viewer"))
+	suite.False(suite.store.HasResourceRoles(suite.ctx, "metrics:opMetrics", []string{"viewer"}))
+	suite.Equal([]string{"admin", "publisher", "reader"}, suite.store.GetAllRoles(suite.ctx))
+}
+
 func (suite *MongoStoreIntegrationTestSuite) TestHasProject() {
 	suite.True(suite.store.HasProject(suite.ctx, "ARGO"))
 	suite.False(suite.store.HasProject(suite.ctx, "FOO"))
@@ -869,6 +941,13 @@ func (suite *MongoStoreIntegrationTestSuite) TestModAck() {
 	suite.Equal(66, subAck.Ack)
 }
 
+func (suite *MongoStoreIntegrationTestSuite) TestModSubOutputFormat() {
+	_ = suite.store.ModSubOutputFormat(suite.ctx, "argo_uuid", "sub1", "cloudevents")
+	sub, _ := suite.store.QueryOneSub(suite.ctx, "argo_uuid", "sub1")
+	suite.Equal("cloudevents", sub.OutputFormat)
+	_ = suite.store.ModSubOutputFormat(suite.ctx, "argo_uuid", "sub1", "")
+}
+
 func (suite *MongoStoreIntegrationTestSuite) TestUpdateSubOffset() {
 	_ = suite.store.InsertSub(suite.ctx, "argo_uuid", "subFresh", "topicFresh", 0, 1000,
 		QPushConfig{}, time.Date(2020, 12, 19, 0, 0, 0, 0, time.UTC))
@@ -971,39 +1050,60 @@ func (suite *MongoStoreIntegrationTestSuite) TestModPushSub() {
 }
 
 func (suite *MongoStoreIntegrationTestSuite) TestExistsInACL() {
-	existsE1 := suite.store.ExistsInACL(suite.ctx, "argo_uuid", "topics", "topic1", "uuid1")
+	existsE1 := suite.store.ExistsInACL(suite.ctx, "argo_uuid", "topics", "topic1", "uuid1", "")
 	suite.Nil(existsE1)
 
-	existsE2 := suite.store.ExistsInACL(suite.ctx, "argo_uuid", "topics", "topic1", "unknown")
+	existsE2 := suite.store.ExistsInACL(suite.ctx, "argo_uuid", "topics", "topic1", "unknown", "")
 	suite.Equal("not found", existsE2.Error())
 }
 
+func (suite *MongoStoreIntegrationTestSuite) TestACLActions() {
+	actions := map[string][]string{"uuid1": {"publish"}, "uuid2": {"viewMetrics"}}
+	suite.Nil(suite.store.ModACL(suite.ctx, "argo_uuid", "topics", "topic1", []string{"uuid1", "uuid2"}, actions))
+
+	qAcl, _ := suite.store.QueryACL(suite.ctx, "argo_uuid", "topics", "topic1")
+	suite.Equal(actions, qAcl.Actions)
+
+	suite.Nil(suite.store.ExistsInACL(suite.ctx, "argo_uuid", "topics", "topic1", "uuid1", "publish"))
+	suite.Nil(suite.store.ExistsInACL(suite.ctx, "argo_uuid", "topics", "topic1", "uuid1", ""))
+	suite.Equal("not found", suite.store.ExistsInACL(suite.ctx, "argo_uuid", "topics", "topic1", "uuid1", "viewMetrics").Error())
+
+	// removing an entry from the acl drops its actions as well
+	suite.Nil(suite.store.RemoveFromACL(suite.ctx, "argo_uuid", "topics", "topic1", []string{"uuid2"}))
+	qAcl, _ = suite.store.QueryACL(suite.ctx, "argo_uuid", "topics", "topic1")
+	suite.Equal(map[string][]string{"uuid1": {"publish"}}, qAcl.Actions)
+
+	suite.Nil(suite.store.ModACL(suite.ctx, "argo_uuid", "topics", "topic1", []string{"uuid1", "uuid2"}, nil))
+	qAcl, _ = suite.store.QueryACL(suite.ctx, "argo_uuid", "topics", "topic1")
+	suite.Equal(QAcl{ACL: []string{"uuid1", "uuid2"}}, qAcl)
+}
+
 func (suite *MongoStoreIntegrationTestSuite) TestQueryACL() {
-	ExpectedACL01 := QAcl{[]string{"uuid1", "uuid2"}}
+	ExpectedACL01 := QAcl{ACL: []string{"uuid1", "uuid2"}}
 	QAcl01, _ := suite.store.QueryACL(suite.ctx, "argo_uuid", "topics", "topic1")
 	suite.Equal(ExpectedACL01, QAcl01)
 
-	ExpectedACL02 := QAcl{[]string{"uuid1", "uuid2", "uuid4"}}
+	ExpectedACL02 := QAcl{ACL: []string{"uuid1", "uuid2", "uuid4"}}
 	QAcl02, _ := suite.store.QueryACL(suite.ctx, "argo_uuid", "topics", "topic2")
 	suite.Equal(ExpectedACL02, QAcl02)
 
-	ExpectedACL03 := QAcl{[]string{"uuid3"}}
+	ExpectedACL03 := QAcl{ACL: []string{"uuid3"}}
 	QAcl03, _ := suite.store.QueryACL(suite.ctx, "argo_uuid", "topics", "topic3")
 	suite.Equal(ExpectedACL03, QAcl03)
 
-	ExpectedACL04 := QAcl{[]string{"uuid1", "uuid2"}}
+	ExpectedACL04 := QAcl{ACL: []string{"uuid1", "uuid2"}}
 	QAcl04, _ := suite.store.QueryACL(suite.ctx, "argo_uuid", "subscriptions", "sub1")
 	suite.Equal(ExpectedACL04, QAcl04)
 
-	ExpectedACL05 := QAcl{[]string{"uuid1", "uuid3"}}
+	ExpectedACL05 := QAcl{ACL: []string{"uuid1", "uuid3"}}
 	QAcl05, _ := suite.store.QueryACL(suite.ctx, "argo_uuid", "subscriptions", "sub2")
 	suite.Equal(ExpectedACL05, QAcl05)
 
-	ExpectedACL06 := QAcl{[]string{"uuid4", "uuid2", "uuid1"}}
+	ExpectedACL06 := QAcl{ACL: []string{"uuid4", "uuid2", "uuid1"}}
 	QAcl06, _ := suite.store.QueryACL(suite.ctx, "argo_uuid", "subscriptions", "sub3")
 	suite.Equal(ExpectedACL06, QAcl06)
 
-	ExpectedACL07 := QAcl{[]string{"uuid2", "uuid4", "uuid7"}}
+	ExpectedACL07 := QAcl{ACL: []string{"uuid2", "uuid4", "uuid7"}}
 	QAcl07, _ := suite.store.QueryACL(suite.ctx, "argo_uuid", "subscriptions", "sub4")
 	suite.Equal(ExpectedACL07, QAcl07)
 
@@ -1055,6 +1155,15 @@ func (suite *MongoStoreIntegrationTestSuite) TestGetUserFromToken() {
 	suite.assertUsersEqual([]QUser{suite.UserList[0]}, []QUser{usrGet})
 }
 
+func (suite *MongoStoreIntegrationTestSuite) TestGetUserFromEmail() {
+	usrGet, _ := suite.store.GetUserFromEmail(suite.ctx, "Test@test.com")
+	suite.assertUsersEqual([]QUser{suite.UserList[0]}, []QUser{usrGet})
+	_, err := suite.store.GetUserFromEmail(suite.ctx, "unknown@test.com")
+	suite.Equal("not found", err.Error())
+	_, err = suite.store.GetUserFromEmail(suite.ctx, "foo-email")
+	suite.Equal("multiple users", err.Error())
+}
+
 func (suite *MongoStoreIntegrationTestSuite) TestUpdateUserFromToken() {
 	_ = suite.store.UpdateUserToken(suite.ctx, suite.UserList[0].UUID, "S3CR3T-v2")
 	_, e1 := suite.store.GetUserFromToken(suite.ctx, "S3CR3T")
@@ -1064,6 +1173,32 @@ func (suite *MongoStoreIntegrationTestSuite) TestUpdateUserFromToken() {
 	_ = suite.store.UpdateUserToken(suite.ctx, suite.UserList[0].UUID, "S3CR3T")
 }
 
+func (suite *MongoStoreIntegrationTestSuite) TestUpdateUserDN() {
+	dn := "CN=Test,O=ARGO,C=GR"
+	issuerDN := "CN=ARGO CA,O=ARGO,C=GR"
+	_ = suite.store.UpdateUserDN(suite.ctx, suite.UserList[0].UUID, dn, issuerDN)
+	usrGet, _ := suite.store.GetUserFromDN(suite.ctx, dn, issuerDN)
+	suite.Equal(suite.UserList[0].UUID, usrGet.UUID)
+	suite.Equal(dn, usrGet.DN)
+	suite.Equal(issuerDN, usrGet.IssuerDN)
+	// the same subject under another CA doesn't match
+	_, err := suite.store.GetUserFromDN(suite.ctx, dn, "CN=Other CA,O=ARGO,C=GR")
+	suite.Equal("not found", err.Error())
+	_ = suite.store.UpdateUserDN(suite.ctx, suite.UserList[0].UUID, "", "")
+	_, err = suite.store.GetUserFromDN(suite.ctx, dn, issuerDN)
+	suite.Equal("not found", err.Error())
+}
+
+func (suite *MongoStoreIntegrationTestSuite) TestUpdateUserOIDCSubject() {
+	_ = suite.store.UpdateUserOIDCSubject(suite.ctx, suite.UserList[0].UUID, "aai-subject-0")
+	usrGet, _ := suite.store.GetUserFromOIDCSubject(suite.ctx, "aai-subject-0")
+	suite.Equal(suite.UserList[0].UUID, usrGet.UUID)
+	suite.Equal("aai-subject-0", usrGet.OIDCSubject)
+	_ = suite.store.UpdateUserOIDCSubject(suite.ctx, suite.UserList[0].UUID, "")
+	_, err := suite.store.GetUserFromOIDCSubject(suite.ctx, "aai-subject-0")
+	suite.Equal("not found", err.Error())
+}
+
 func (suite *MongoStoreIntegrationTestSuite) TestPaginatedQueryUsers() {
 
 	reverser := QListReverser[QUser]{}
@@ -1103,18 +1238,18 @@ func (suite *MongoStoreIntegrationTestSuite) TestACLModificationActions() {
 	_ = suite.store.InsertTopic(suite.ctx, "argo_uuid", "topicFresh", "", time.Date(2020, 9, 11, 0, 0, 0, 0, time.UTC))
 
 	// test mod acl
-	ExpectedACL01 := QAcl{[]string{"uuid1", "uuid2"}}
-	eModACL1 := suite.store.ModACL(suite.ctx, "argo_uuid", "topics", "topicFresh", ExpectedACL01.ACL)
+	ExpectedACL01 := QAcl{ACL: []string{"uuid1", "uuid2"}}
+	eModACL1 := suite.store.ModACL(suite.ctx, "argo_uuid", "topics", "topicFresh", ExpectedACL01.ACL, nil)
 	suite.Nil(eModACL1)
 	QAcl01, _ := suite.store.QueryACL(suite.ctx, "argo_uuid", "topics", "topicFresh")
 	suite.Equal(ExpectedACL01, QAcl01)
 
-	eModACL2 := suite.store.ModACL(suite.ctx, "argo_uuid", "subscriptions", "subFresh", ExpectedACL01.ACL)
+	eModACL2 := suite.store.ModACL(suite.ctx, "argo_uuid", "subscriptions", "subFresh", ExpectedACL01.ACL, nil)
 	suite.Nil(eModACL2)
 	QAcl01sub, _ := suite.store.QueryACL(suite.ctx, "argo_uuid", "topics", "topicFresh")
 	suite.Equal(ExpectedACL01, QAcl01sub)
 
-	eModACL3 := suite.store.ModACL(suite.ctx, "argo_uuid", "mistype", "sub1", []string{"u1", "u2"})
+	eModACL3 := suite.store.ModACL(suite.ctx, "argo_uuid", "mistype", "sub1", []string{"u1", "u2"}, nil)
 	suite.Equal("wrong resource type", eModACL3.Error())
 
 	// test append acl
@@ -1257,21 +1392,24 @@ func (suite *MongoStoreIntegrationTestSuite) TestCRUDSchemas() {
 	suite.assertSchemasEqual([]QSchema{expectedSchemas[0]}, []QSchema{qqs3[0]})
 
 	// test InsertSchema
-	eis := suite.store.InsertSchema(suite.ctx, "argo_uuid", "uuid1", "s1-insert", "json", "raw")
+	eis := suite.store.InsertSchema(suite.ctx, "argo_uuid", "uuid1", "s1-insert", "json", "raw", "NONE", 1, nil)
 	qs1, _ := suite.store.QuerySchemas(suite.ctx, "argo_uuid", "uuid1", "s1-insert")
 	suite.Equal(QSchema{
-		ProjectUUID: "argo_uuid",
-		UUID:        "uuid1",
-		Name:        "s1-insert",
-		Type:        "json",
-		RawSchema:   "raw",
+		ProjectUUID:   "argo_uuid",
+		UUID:          "uuid1",
+		Name:          "s1-insert",
+		Type:          "json",
+		RawSchema:     "raw",
+		Revision:      1,
+		Compatibility: "NONE",
 	}, qs1[0])
 	suite.Nil(eis)
 
 	// test update schema
 	_ = suite.store.InsertTopic(suite.ctx, "argo_uuid", "topicFresh", "", time.Date(2020, 9, 11, 0, 0, 0, 0, time.UTC))
-	_ = suite.store.UpdateSchema(suite.ctx, "uuid1", "new-name", "new-type", "new-raw-schema")
-	eus := QSchema{UUID: "uuid1", ProjectUUID: "argo_uuid", Type: "new-type", Name: "new-name", RawSchema: "new-raw-schema"}
+	_ = suite.store.UpdateSchema(suite.ctx, "uuid1", "new-name", "new-type", "new-raw-schema", "", 0, nil)
+	eus := QSchema{UUID: "uuid1", ProjectUUID: "argo_uuid", Type: "new-type", Name: "new-name", RawSchema: "new-raw-schema",
+		Revision: 1, Compatibility: "NONE"}
 	qus, _ := suite.store.QuerySchemas(suite.ctx, "argo_uuid", "uuid1", "")
 	suite.Equal(eus, qus[0])
 	_ = suite.store.LinkTopicSchema(suite.ctx, "argo_uuid", "topicFresh", "uuid1")
@@ -1287,6 +1425,72 @@ func (suite *MongoStoreIntegrationTestSuite) TestCRUDSchemas() {
 	_ = suite.store.RemoveTopic(suite.ctx, "argo_uuid", "topicFresh")
 }
 
+func (suite *MongoStoreIntegrationTestSuite) TestSchemaRevisions() {
+
+	createdOn := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	_ = suite.store.InsertSchema(suite.ctx, "argo_uuid", "uuid-rev", "s-rev", "json", "raw1", "NONE", 1, nil)
+	suite.Nil(suite.store.InsertSchemaRevision(suite.ctx, "argo_uuid", "uuid-rev", 2, "json", "raw2", nil, createdOn))
+	suite.Nil(suite.store.InsertSchemaRevision(suite.ctx, "argo_uuid", "uuid-rev", 1, "json", "raw1", nil, createdOn))
+
+	// a revision can only be stored once
+	suite.Equal("exists", suite.store.InsertSchemaRevision(suite.ctx, "argo_uuid", "uuid-rev", 2, "json", "raw2b", nil, createdOn).Error())
+	// the compatibility mode and the revision get updated along with the rest of the schema
+	suite.Nil(suite.store.UpdateSchema(suite.ctx, "uuid-rev", "", "", "raw2", "BACKWARD", 2, nil))
+
+	qs, _ := suite.store.QuerySchemas(suite.ctx, "argo_uuid", "uuid-rev", "")
+	suite.Equal(int64(2), qs[0].Revision)
+	suite.Equal("BACKWARD", qs[0].Compatibility)
+
+	// revisions are returned in ascending order
+	revs, err := suite.store.QuerySchemaRevisions(suite.ctx, "argo_uuid", "uuid-rev", 0)
+	suite.Nil(err)
+
+	// ids are handed out in insertion order
+	suite.Equal(revs[1].ID+1, revs[0].ID)
+	id1, id2 := revs[0].ID, revs[1].ID
+
+	suite.Equal([]QSchemaRevision{
+		{ID: id1, ProjectUUID: "argo_uuid", SchemaUUID: "uuid-rev", Revision: 1, Type: "json", RawSchema: "raw1", CreatedOn: createdOn},
+		{ID: id2, ProjectUUID: "argo_uuid", SchemaUUID: "uuid-rev", Revision: 2, Type: "json", RawSchema: "raw2", CreatedOn: createdOn},
+	}, revs)
+
+	revs, _ = suite.store.QuerySchemaRevisionByID(suite.ctx, "argo_uuid", id2)
+	suite.Equal(1, len(revs))
+	suite.Equal(int64(2), revs[0].Revision)
+
+	// ids are scoped to the project of the schema
+	revs, _ = suite.store.QuerySchemaRevisionByID(suite.ctx, "argo_uuid2", id2)
+	suite.Empty(revs)
+
+	revs, _ = suite.store.QuerySchemaRevisions(suite.ctx, "argo_uuid", "uuid-rev", 2)
+	suite.Equal(1, len(revs))
+	suite.Equal("raw2", revs[0].RawSchema)
+
+	// references are kept along with the revision and the schema
+	refs := []QSchemaReference{{Name: "address.json", SchemaUUID: "uuid1", Revision: 1}}
+	suite.Nil(suite.store.InsertSchemaRevision(suite.ctx, "argo_uuid", "uuid-rev", 3, "json", "raw3", refs, createdOn))
+	revs, _ = suite.store.QuerySchemaRevisions(suite.ctx, "argo_uuid", "uuid-rev", 3)
+	suite.Equal(refs, revs[0].References)
+	suite.Nil(suite.store.UpdateSchema(suite.ctx, "uuid-rev", "", "", "raw3", "", 3, refs))
+	qs, _ = suite.store.QuerySchemasByReference(suite.ctx, "argo_uuid", "uuid1")
+	suite.Equal(1, len(qs))
+	suite.Equal("uuid-rev", qs[0].UUID)
+	suite.Equal(refs, qs[0].References)
+	qs, _ = suite.store.QuerySchemasByReference(suite.ctx, "argo_uuid2", "uuid1")
+	suite.Empty(qs)
+	revs, _ = suite.store.QuerySchemaRevisionsByReference(suite.ctx, "argo_uuid", "uuid1")
+	suite.Equal(1, len(revs))
+	suite.Equal(int64(3), revs[0].Revision)
+	revs, _ = suite.store.QuerySchemaRevisionsByReference(suite.ctx, "argo_uuid2", "uuid1")
+	suite.Empty(revs)
+
+	// deleting the schema removes its revisions
+	suite.Nil(suite.store.DeleteSchema(suite.ctx, "uuid-rev"))
+	revs, _ = suite.store.QuerySchemaRevisions(suite.ctx, "argo_uuid", "uuid-rev", 0)
+	suite.Empty(revs)
+}
+
 func (suite *MongoStoreIntegrationTestSuite) TestCRUDRegistrations() {
 
 	_ = suite.store.RegisterUser(suite.ctx, "ruuid1", "n1", "f1", "l1", "e1", "o1", "d1", "time", "atkn", "pending")
@@ -1327,6 +1531,11 @@ func (suite *MongoStoreIntegrationTestSuite) TestCRUDRegistrations() {
 	ur2, _ := suite.store.QueryRegistrations(suite.ctx, "ur-uuid1", "accepted", "", "", "", "")
 	suite.Equal(expur2, ur2)
 
+	suite.Nil(suite.store.ConfirmRegistrationEmail(suite.ctx, "ruuid1", "2020-05-18T22:26:58Z"))
+	ur13, _ := suite.store.QueryRegistrations(suite.ctx, "ruuid1", "", "", "", "", "")
+	suite.Equal("2020-05-18T22:26:58Z", ur13[0].EmailConfirmedAt)
+	expur1[0].EmailConfirmedAt = "2020-05-18T22:26:58Z"
+
 	ur3, _ := suite.store.QueryRegistrations(suite.ctx, "", "", "", "", "", "")
 
 	suite.Equal(2, len(ur3))
@@ -1370,6 +1579,356 @@ func (suite *MongoStoreIntegrationTestSuite) TestOpMetrics() {
 	suite.Equal(expectedOpMetrics, suite.store.GetOpMetrics(suite.ctx))
 }
 
+func (suite *MongoStoreIntegrationTestSuite) TestScheduledMessages() {
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	_ = suite.store.InsertScheduledMessage(suite.ctx, "argo_uuid", "sub_sched", 1, now.Add(-time.Minute), "msg1")
+	_ = suite.store.InsertScheduledMessage(suite.ctx, "argo_uuid", "sub_sched", 2, now.Add(time.Hour), "msg2")
+	_ = suite.store.InsertScheduledMessage(suite.ctx, "argo_uuid", "sub_sched", 7, now.Add(-time.Minute), "msg7")
+	// inserting again the same offset doesn't alter the scheduled message
+	_ = suite.store.InsertScheduledMessage(suite.ctx, "argo_uuid", "sub_sched", 1, now.Add(time.Hour), "other")
+
+	count, err := suite.store.CountScheduledMessages(suite.ctx, "argo_uuid", "sub_sched", now)
+	suite.Nil(err)
+	suite.Equal(int64(1), count)
+
+	// only offsets that precede the subscription's offset are returned
+	due, err := suite.store.QueryDueScheduledMessages(suite.ctx, "argo_uuid", "sub_sched", 5, now, now, 10)
+	suite.Nil(err)
+	suite.Equal(1, len(due))
+	suite.Equal(int64(1), due[0].Offset)
+	suite.Equal("msg1", due[0].Message)
+	suite.True(due[0].DeliveredAt.IsZero())
+
+	// a message that hasn't been delivered can't be acknowledged
+	acked, err := suite.store.AckScheduledMessages(suite.ctx, "argo_uuid", "sub_sched", []int64{1})
+	suite.Nil(err)
+	suite.Equal([]int64{}, acked)
+
+	// delivered messages are not returned until their ack deadline expires
+	suite.Nil(suite.store.UpdateScheduledMessagesDelivery(suite.ctx, "argo_uuid", "sub_sched", []int64{1}, now))
+	due, _ = suite.store.QueryDueScheduledMessages(suite.ctx, "argo_uuid", "sub_sched", 5, now, now.Add(-10*time.Second), 10)
+	suite.Equal(0, len(due))
+	due, _ = suite.store.QueryDueScheduledMessages(suite.ctx, "argo_uuid", "sub_sched", 5, now.Add(time.Minute), now.Add(time.Minute), 10)
+	suite.Equal(1, len(due))
+
+	acked, err = suite.store.AckScheduledMessages(suite.ctx, "argo_uuid", "sub_sched", []int64{1, 2})
+	suite.Nil(err)
+	suite.Equal([]int64{1}, acked)
+
+	suite.Nil(suite.store.DeleteScheduledMessages(suite.ctx, "argo_uuid", "sub_sched", []int64{7}))
+	due, _ = suite.store.QueryDueScheduledMessages(suite.ctx, "argo_uuid", "sub_sched", 10, now, now, 10)
+	suite.Equal(0, len(due))
+
+	suite.Nil(suite.store.RemoveSubScheduledMessages(suite.ctx, "argo_uuid", "sub_sched"))
+	count, _ = suite.store.CountScheduledMessages(suite.ctx, "argo_uuid", "sub_sched", now)
+	suite.Equal(int64(0), count)
+
+	_ = suite.store.InsertScheduledMessage(suite.ctx, "argo_uuid", "sub_sched", 3, now.Add(time.Hour), "msg3")
+	suite.Nil(suite.store.RemoveProjectScheduledMessages(suite.ctx, "argo_uuid"))
+	count, _ = suite.store.CountScheduledMessages(suite.ctx, "argo_uuid", "sub_sched", now)
+	suite.Equal(int64(0), count)
+}
+
+func (suite *MongoStoreIntegrationTestSuite) TestAPIKeys() {
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	k1 := QAPIKey{UserUUID: "uuid1", Name: "ci", TokenHash: "hash1", ProjectUUID: "argo_uuid",
+		Roles: []string{"publisher"}, CreatedOn: now, ExpiresOn: now.Add(time.Hour)}
+	k2 := QAPIKey{UserUUID: "uuid1", Name: "backup", TokenHash: "hash2", Roles: []string{}, CreatedOn: now.Add(time.Minute)}
+	suite.Nil(suite.store.InsertAPIKey(suite.ctx, k1))
+	suite.Nil(suite.store.InsertAPIKey(suite.ctx, k2))
+
+	keys, err := suite.store.QueryAPIKeys(suite.ctx, "uuid1", "")
+	suite.Nil(err)
+	suite.Equal(2, len(keys))
+	suite.Equal("ci", keys[0].Name)
+	suite.Equal("backup", keys[1].Name)
+
+	keys, _ = suite.store.QueryAPIKeys(suite.ctx, "uuid1", "ci")
+	suite.Equal(1, len(keys))
+	suite.Equal([]string{"publisher"}, keys[0].Roles)
+
+	key, err := suite.store.GetAPIKeyByHash(suite.ctx, "hash2")
+	suite.Nil(err)
+	suite.Equal("backup", key.Name)
+	_, err = suite.store.GetAPIKeyByHash(suite.ctx, "unknown")
+	suite.Equal("not found", err.Error())
+
+	suite.Nil(suite.store.UpdateAPIKeyLastUsed(suite.ctx, "uuid1", "ci", now.Add(time.Hour)))
+	key, _ = suite.store.GetAPIKeyByHash(suite.ctx, "hash1")
+	suite.Equal(now.Add(time.Hour), key.LastUsedOn.UTC())
+
+	suite.Nil(suite.store.RemoveAPIKeys(suite.ctx, "uuid1", "ci"))
+	suite.Equal("not found", suite.store.RemoveAPIKeys(suite.ctx, "uuid1", "ci").Error())
+	keys, _ = suite.store.QueryAPIKeys(suite.ctx, "uuid1", "")
+	suite.Equal(1, len(keys))
+
+	suite.Nil(suite.store.RemoveAPIKeys(suite.ctx, "uuid1", ""))
+	keys, _ = suite.store.QueryAPIKeys(suite.ctx, "uuid1", "")
+	suite.Equal(0, len(keys))
+}
+
+func (suite *MongoStoreIntegrationTestSuite) TestGroups() {
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	g1 := QGroup{UUID: "group_uuid1", ProjectUUID: "argo_uuid", Name: "team", Members: []string{"uuid7"}, CreatedOn: now}
+	g2 := QGroup{UUID: "group_uuid2", ProjectUUID: "argo_uuid", Name: "alpha", Members: []string{}, CreatedOn: now}
+	suite.Nil(suite.store.InsertGroup(suite.ctx, g1))
+	suite.Nil(suite.store.InsertGroup(suite.ctx, g2))
+
+	groups, err := suite.store.QueryGroups(suite.ctx, "argo_uuid", "", "")
+	suite.Nil(err)
+	suite.Equal(2, len(groups))
+	suite.Equal("alpha", groups[0].Name)
+	suite.Equal("team", groups[1].Name)
+
+	groups, _ = suite.store.QueryGroups(suite.ctx, "argo_uuid", "group_uuid1", "")
+	suite.Equal(1, len(groups))
+	suite.Equal("team", groups[0].Name)
+
+	suite.Nil(suite.store.AppendToGroup(suite.ctx, "argo_uuid", "team", []string{"uuid8", "uuid7"}))
+	groups, _ = suite.store.QueryGroups(suite.ctx, "argo_uuid", "", "team")
+	suite.Equal([]string{"uuid7", "uuid8"}, groups[0].Members)
+	suite.Nil(suite.store.RemoveFromGroup(suite.ctx, "argo_uuid", "team", []string{"uuid7"}))
+	groups, _ = suite.store.QueryGroups(suite.ctx, "argo_uuid", "", "team")
+	suite.Equal([]string{"uuid8"}, groups[0].Members)
+	suite.Equal("not found", suite.store.AppendToGroup(suite.ctx, "argo_uuid", "unknown", []string{"uuid8"}).Error())
+
+	// the members of a group in an acl get access through it
+	suite.NotNil(suite.store.ExistsInACL(suite.ctx, "argo_uuid", "topics", "topic3", "uuid8", ""))
+	suite.Nil(suite.store.AppendToACL(suite.ctx, "argo_uuid", "topics", "topic3", []string{GroupACLEntry("group_uuid1")}))
+	suite.Nil(suite.store.ExistsInACL(suite.ctx, "argo_uuid", "topics", "topic3", "uuid8", ""))
+	topics, _, _, _ := suite.store.QueryTopics(suite.ctx, "argo_uuid", "uuid8", "", "", 0)
+	suite.Equal(1, len(topics))
+	suite.Equal("topic3", topics[0].Name)
+	suite.Nil(suite.store.RemoveFromACL(suite.ctx, "argo_uuid", "topics", "topic3", []string{GroupACLEntry("group_uuid1")}))
+
+	suite.Nil(suite.store.RemoveGroups(suite.ctx, "argo_uuid", "team"))
+	suite.Equal("not found", suite.store.RemoveGroups(suite.ctx, "argo_uuid", "team").Error())
+	suite.Nil(suite.store.RemoveGroups(suite.ctx, "argo_uuid", ""))
+	groups, _ = suite.store.QueryGroups(suite.ctx, "argo_uuid", "", "")
+	suite.Equal(0, len(groups))
+}
+
+func (suite *MongoStoreIntegrationTestSuite) TestAuditLog() {
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e1 := QAuditEntry{TraceID: "trace1", ActorUUID: "uuid1", RouteName: "topics:create", ProjectUUID: "argo_uuid",
+		ResourceType: "topics", Resource: "topic1", StatusCode: 200, SourceIP: "10.0.0.1", CreatedOn: now,
+		Changes: []QAuditChange{{Field: "name", Before: "null", After: `"topic1"`}}}
+	e2 := QAuditEntry{TraceID: "trace2", ActorUUID: "uuid1", RouteName: "users:create", ResourceType: "users",
+		Resource: "UserX", StatusCode: 200, SourceIP: "10.0.0.1", CreatedOn: now.Add(time.Hour)}
+	e3 := QAuditEntry{TraceID: "trace3", ActorUUID: "uuid2", RouteName: "topics:delete", ProjectUUID: "argo_uuid",
+		ResourceType: "topics", Resource: "topic1", StatusCode: 404, SourceIP: "10.0.0.2", CreatedOn: now.Add(2 * time.Hour),
+		ExpiresOn: now.Add(24 * time.Hour)}
+	suite.Nil(suite.store.InsertAuditEntry(suite.ctx, e1))
+	suite.Nil(suite.store.InsertAuditEntry(suite.ctx, e2))
+	suite.Nil(suite.store.InsertAuditEntry(suite.ctx, e3))
+
+	// the most recent entries come first
+	entries, total, nextPageToken, err := suite.store.QueryAuditEntries(suite.ctx, QAuditFilter{}, "", 2)
+	suite.Nil(err)
+	suite.Equal(int64(3), total)
+	suite.Equal(2, len(entries))
+	suite.Equal("trace3", entries[0].TraceID)
+	suite.Equal("trace2", entries[1].TraceID)
+	suite.NotEqual("", nextPageToken)
+
+	entries, _, nextPageToken, err = suite.store.QueryAuditEntries(suite.ctx, QAuditFilter{}, nextPageToken, 2)
+	suite.Nil(err)
+	suite.Equal(1, len(entries))
+	suite.Equal("trace1", entries[0].TraceID)
+	suite.Equal(e1.Changes, entries[0].Changes)
+	suite.Equal("", nextPageToken)
+
+	entries, total, _, err = suite.store.QueryAuditEntries(suite.ctx,
+		QAuditFilter{ProjectUUID: "argo_uuid", ActorUUID: "uuid1", From: now, To: now.Add(time.Hour)}, "", 0)
+	suite.Nil(err)
+	suite.Equal(int64(1), total)
+	suite.Equal("trace1", entries[0].TraceID)
+
+	_, _, _, err = suite.store.QueryAuditEntries(suite.ctx, QAuditFilter{}, "invalid", 0)
+	suite.NotNil(err)
+}
+
+func (suite *MongoStoreIntegrationTestSuite) TestRateLimits() {
+
+	limits := []QRateLimit{
+		{RouteClass: "publish", RequestsPerSecond: 10, BytesPerSecond: 1024},
+		{RouteClass: "pull", RequestsPerSecond: 5},
+	}
+	suite.Nil(suite.store.UpdateRateLimits(suite.ctx, "projects", "argo_uuid", limits))
+	qLimits, err := suite.store.QueryRateLimits(suite.ctx, "projects", "argo_uuid")
+	suite.Nil(err)
+	suite.Equal(limits, qLimits)
+
+	// updating the limits replaces them
+	suite.Nil(suite.store.UpdateRateLimits(suite.ctx, "projects", "argo_uuid", limits[1:]))
+	qLimits, _ = suite.store.QueryRateLimits(suite.ctx, "projects", "argo_uuid")
+	suite.Equal(limits[1:], qLimits)
+
+	qLimits, _ = suite.store.QueryRateLimits(suite.ctx, "users", "argo_uuid")
+	suite.Equal([]QRateLimit{}, qLimits)
+
+	suite.Nil(suite.store.UpdateRateLimits(suite.ctx, "projects", "argo_uuid", nil))
+	qLimits, _ = suite.store.QueryRateLimits(suite.ctx, "projects", "argo_uuid")
+	suite.Equal([]QRateLimit{}, qLimits)
+
+	window := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	counter, err := suite.store.IncrementRateCounter(suite.ctx, "projects:argo_uuid:publish", window, 1, 100, window.Add(time.Minute))
+	suite.Nil(err)
+	suite.Equal(int64(1), counter.Requests)
+	suite.Equal(int64(100), counter.Bytes)
+
+	counter, _ = suite.store.IncrementRateCounter(suite.ctx, "projects:argo_uuid:publish", window, 0, 50, window.Add(time.Minute))
+	suite.Equal(int64(1), counter.Requests)
+	suite.Equal(int64(150), counter.Bytes)
+
+	// every window has a counter of its own
+	counter, _ = suite.store.IncrementRateCounter(suite.ctx, "projects:argo_uuid:publish", window.Add(time.Second), 1, 0, window.Add(time.Minute))
+	suite.Equal(int64(1), counter.Requests)
+	suite.Equal(int64(0), counter.Bytes)
+}
+
+func (suite *MongoStoreIntegrationTestSuite) TestProjectQuotas() {
+
+	qQuota, err := suite.store.QueryProjectQuota(suite.ctx, "argo_uuid")
+	suite.Nil(err)
+	suite.Equal(QProjectQuota{ProjectUUID: "argo_uuid"}, qQuota)
+
+	suite.Nil(suite.store.UpdateProjectQuota(suite.ctx, QProjectQuota{ProjectUUID: "argo_uuid", MaxTopics: 10, DailyBytes: 1024}))
+	// updating the quotas replaces them
+	suite.Nil(suite.store.UpdateProjectQuota(suite.ctx, QProjectQuota{ProjectUUID: "argo_uuid", MaxTopics: 5, MaxUsers: 3}))
+	qQuota, _ = suite.store.QueryProjectQuota(suite.ctx, "argo_uuid")
+	suite.Equal(int64(5), qQuota.MaxTopics)
+	suite.Equal(int64(3), qQuota.MaxUsers)
+	suite.Equal(int64(0), qQuota.DailyBytes)
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	suite.Nil(suite.store.IncrementDailyProjectUsage(suite.ctx, "argo_uuid", day, 2, 100))
+	suite.Nil(suite.store.IncrementDailyProjectUsage(suite.ctx, "argo_uuid", day, 1, 50))
+	qUsage, err := suite.store.QueryDailyProjectUsage(suite.ctx, "argo_uuid", day)
+	suite.Nil(err)
+	suite.Equal(int64(3), qUsage.Messages)
+	suite.Equal(int64(150), qUsage.Bytes)
+
+	// every day has a usage of its own
+	qUsage, _ = suite.store.QueryDailyProjectUsage(suite.ctx, "argo_uuid", day.AddDate(0, 0, 1))
+	suite.Equal(QDailyProjectUsage{ProjectUUID: "argo_uuid", Date: day.AddDate(0, 0, 1)}, qUsage)
+
+	// the usage is only increased while it stays within the maximums
+	reserved, err := suite.store.ReserveDailyProjectUsage(suite.ctx, "argo_uuid", day.AddDate(0, 0, 2), 2, 100, 3, 150)
+	suite.Nil(err)
+	suite.True(reserved)
+	reserved, err = suite.store.ReserveDailyProjectUsage(suite.ctx, "argo_uuid", day.AddDate(0, 0, 2), 2, 10, 3, 150)
+	suite.Nil(err)
+	suite.False(reserved)
+	reserved, _ = suite.store.ReserveDailyProjectUsage(suite.ctx, "argo_uuid", day.AddDate(0, 0, 2), 1, 50, 3, 150)
+	suite.True(reserved)
+	qUsage, _ = suite.store.QueryDailyProjectUsage(suite.ctx, "argo_uuid", day.AddDate(0, 0, 2))
+	suite.Equal(int64(3), qUsage.Messages)
+	suite.Equal(int64(150), qUsage.Bytes)
+
+	suite.Nil(suite.store.RemoveProjectQuota(suite.ctx, "argo_uuid"))
+	qQuota, _ = suite.store.QueryProjectQuota(suite.ctx, "argo_uuid")
+	suite.Equal(QProjectQuota{ProjectUUID: "argo_uuid"}, qQuota)
+	qUsage, _ = suite.store.QueryDailyProjectUsage(suite.ctx, "argo_uuid", day)
+	suite.Equal(int64(0), qUsage.Messages)
+}
+
+func (suite *MongoStoreIntegrationTestSuite) TestNotificationTemplates() {
+
+	qTmpls, err := suite.store.QueryNotificationTemplates(suite.ctx, "")
+	suite.Nil(err)
+	suite.Equal(0, len(qTmpls))
+
+	suite.Nil(suite.store.UpdateNotificationTemplate(suite.ctx, QNotificationTemplate{Name: "registration_pending", Subject: "s1", Body: "b1"}))
+	// updating a template replaces it
+	suite.Nil(suite.store.UpdateNotificationTemplate(suite.ctx, QNotificationTemplate{Name: "registration_pending", Subject: "s2", Body: "b2"}))
+	suite.Nil(suite.store.UpdateNotificationTemplate(suite.ctx, QNotificationTemplate{Name: "registration_declined", Subject: "s3", Body: "b3"}))
+
+	qTmpls, _ = suite.store.QueryNotificationTemplates(suite.ctx, "registration_pending")
+	suite.Equal(1, len(qTmpls))
+	suite.Equal("s2", qTmpls[0].Subject)
+	suite.Equal("b2", qTmpls[0].Body)
+
+	qTmpls, _ = suite.store.QueryNotificationTemplates(suite.ctx, "")
+	suite.Equal(2, len(qTmpls))
+
+	suite.Nil(suite.store.DeleteNotificationTemplate(suite.ctx, "registration_pending"))
+	qTmpls, _ = suite.store.QueryNotificationTemplates(suite.ctx, "")
+	suite.Equal(1, len(qTmpls))
+	suite.Equal("registration_declined", qTmpls[0].Name)
+}
+
+func (suite *MongoStoreIntegrationTestSuite) TestIdempotencyKeys() {
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	suite.Nil(suite.store.InsertIdempotencyKey(suite.ctx, "argo_uuid", "topic_idem", "k1", []string{"1", "2"}, now, now.Add(time.Hour)))
+	suite.Nil(suite.store.InsertIdempotencyKey(suite.ctx, "argo_uuid", "topic_idem", "k2", []string{"3"}, now, now.Add(-time.Second)))
+	suite.Nil(suite.store.InsertIdempotencyKey(suite.ctx, "argo_uuid", "topic_other", "k1", []string{"7"}, now, now.Add(time.Hour)))
+
+	// expired keys are not returned even if they are still stored
+	keys, err := suite.store.QueryIdempotencyKeys(suite.ctx, "argo_uuid", "topic_idem", []string{"k1", "k2", "k3"}, now)
+	suite.Nil(err)
+	suite.Equal(1, len(keys))
+	suite.Equal("k1", keys[0].Key)
+	suite.Equal([]string{"1", "2"}, keys[0].MessageIDs)
+	suite.Equal(now.Add(time.Hour), keys[0].ExpiresOn)
+
+	// an expired key gets overwritten
+	suite.Nil(suite.store.InsertIdempotencyKey(suite.ctx, "argo_uuid", "topic_idem", "k2", []string{"4"}, now, now.Add(time.Hour)))
+	keys, _ = suite.store.QueryIdempotencyKeys(suite.ctx, "argo_uuid", "topic_idem", []string{"k2"}, now)
+	suite.Equal([]string{"4"}, keys[0].MessageIDs)
+
+	// a live key can not be reserved again, a new or expired one is stored as pending
+	reserved, err := suite.store.ReserveIdempotencyKey(suite.ctx, "argo_uuid", "topic_idem", "k1", now, now.Add(time.Hour))
+	suite.Nil(err)
+	suite.False(reserved)
+	reserved, err = suite.store.ReserveIdempotencyKey(suite.ctx, "argo_uuid", "topic_idem", "k3", now, now.Add(time.Hour))
+	suite.Nil(err)
+	suite.True(reserved)
+	reserved, _ = suite.store.ReserveIdempotencyKey(suite.ctx, "argo_uuid", "topic_idem", "k3", now, now.Add(time.Hour))
+	suite.False(reserved)
+	keys, _ = suite.store.QueryIdempotencyKeys(suite.ctx, "argo_uuid", "topic_idem", []string{"k3"}, now)
+	suite.True(keys[0].Pending)
+
+	// only pending keys get released
+	suite.Nil(suite.store.ReleaseIdempotencyKey(suite.ctx, "argo_uuid", "topic_idem", "k1"))
+	suite.Nil(suite.store.ReleaseIdempotencyKey(suite.ctx, "argo_uuid", "topic_idem", "k3"))
+	keys, _ = suite.store.QueryIdempotencyKeys(suite.ctx, "argo_uuid", "topic_idem", []string{"k1", "k3"}, now)
+	suite.Equal(1, len(keys))
+	suite.Equal("k1", keys[0].Key)
+
+	suite.Nil(suite.store.RemoveTopicIdempotencyKeys(suite.ctx, "argo_uuid", "topic_idem"))
+	keys, _ = suite.store.QueryIdempotencyKeys(suite.ctx, "argo_uuid", "topic_idem", []string{"k1", "k2"}, now)
+	suite.Equal(0, len(keys))
+	keys, _ = suite.store.QueryIdempotencyKeys(suite.ctx, "argo_uuid", "topic_other", []string{"k1"}, now)
+	suite.Equal(1, len(keys))
+
+	suite.Nil(suite.store.RemoveProjectIdempotencyKeys(suite.ctx, "argo_uuid"))
+	keys, _ = suite.store.QueryIdempotencyKeys(suite.ctx, "argo_uuid", "topic_other", []string{"k1"}, now)
+	suite.Equal(0, len(keys))
+}
+
+func (suite *MongoStoreIntegrationTestSuite) TestSchemaInvalidations() {
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	suite.Nil(suite.store.InsertSchemaInvalidation(suite.ctx, "argo_uuid", "uuid1", now.Add(-time.Minute)))
+	suite.Nil(suite.store.InsertSchemaInvalidation(suite.ctx, "argo_uuid", "uuid2", now))
+
+	invs, err := suite.store.QuerySchemaInvalidations(suite.ctx, now)
+	suite.Nil(err)
+	suite.Equal([]QSchemaInvalidation{{ProjectUUID: "argo_uuid", SchemaUUID: "uuid2", InvalidatedOn: now}}, invs)
+
+	invs, _ = suite.store.QuerySchemaInvalidations(suite.ctx, now.Add(-time.Hour))
+	suite.Equal(2, len(invs))
+}
+
 func (suite *MongoStoreIntegrationTestSuite) SetupSuite() {
 	suite.ctx = context.Background()
 	suite.store.Initialize()
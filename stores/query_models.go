@@ -33,6 +33,12 @@ type QSub struct {
 	ConsumeRate         float64     `bson:"consume_rate"`
 	CreatedOn           time.Time   `bson:"created_on"`
 	ACL                 []string    `bson:"acl"`
+	ExpiredMsgNum       int64       `bson:"expired_msg_num"`
+	SchemaDecode        bool        `bson:"schema_decode"`
+	// OutputFormat is the format that the subscription delivers its messages in
+	OutputFormat string `bson:"output_format"`
+	// StoredBytes is the total number of bytes consumed, as they were stored in the broker
+	StoredBytes int64 `bson:"stored_bytes"`
 }
 
 // QPushConfig holds optional configuration for push operations
@@ -50,11 +56,192 @@ type QPushConfig struct {
 	MattermostUsername  string `bson:"mattermost_username"`
 	MattermostChannel   string `bson:"mattermost_channel"`
 	Base64Decode        bool   `bson:"base_64_decode"`
+	SchemaDecode        bool   `bson:"schema_decode"`
 }
 
-// QAcl holds a list of authorized users queried from topic or subscription collections
+// QAcl holds a list of authorized users queried from topic or subscription collections.
+// Actions restricts acl entries to specific actions, entries that are not present in it are allowed every action
 type QAcl struct {
-	ACL []string `bson:"acl"`
+	ACL     []string            `bson:"acl"`
+	Actions map[string][]string `bson:"acl_actions"`
+}
+
+// aclActionsFields returns the document fields that hold the action restrictions of the given acl entries
+func aclActionsFields(entries []string) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for _, entry := range entries {
+		fields["acl_actions."+entry] = ""
+	}
+	return fields
+}
+
+// Allows checks if any of the given acl entries is allowed an action on the resource, an empty action matches any
+func (qAcl QAcl) Allows(entries []string, action string) bool {
+	for _, item := range qAcl.ACL {
+		for _, entry := range entries {
+			if item != entry {
+				continue
+			}
+
+			actions, restricted := qAcl.Actions[entry]
+			if !restricted || action == "" {
+				return true
+			}
+
+			for _, allowed := range actions {
+				if allowed == action {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// GroupACLPrefix marks the acl entries that reference a group instead of a user
+const GroupACLPrefix = "group:"
+
+// GroupACLEntry returns the acl entry that references the group with the given uuid
+func GroupACLEntry(groupUUID string) string {
+	return GroupACLPrefix + groupUUID
+}
+
+// QGroup holds a named group of users of a project, that can be referenced in topic and subscription acls
+type QGroup struct {
+	UUID        string    `bson:"uuid"`
+	ProjectUUID string    `bson:"project_uuid"`
+	Name        string    `bson:"name"`
+	Members     []string  `bson:"members"`
+	CreatedOn   time.Time `bson:"created_on"`
+}
+
+// QAuditEntry holds the record of an administrative or security relevant action that was performed through the api
+type QAuditEntry struct {
+	ID           interface{}    `bson:"_id,omitempty"`
+	TraceID      string         `bson:"trace_id"`
+	ActorUUID    string         `bson:"actor_uuid"`
+	RouteName    string         `bson:"route_name"`
+	ProjectUUID  string         `bson:"project_uuid"`
+	ResourceType string         `bson:"resource_type"`
+	Resource     string         `bson:"resource"`
+	Changes      []QAuditChange `bson:"changes"`
+	StatusCode   int            `bson:"status_code"`
+	SourceIP     string         `bson:"source_ip"`
+	CreatedOn    time.Time      `bson:"created_on"`
+	// ExpiresOn is when the entry gets discarded, it is not set for the entries that are kept forever
+	ExpiresOn time.Time `bson:"expires_on,omitempty"`
+}
+
+// QAuditChange holds the json encoded value of a field of a resource before and after an action
+type QAuditChange struct {
+	Field  string `bson:"field"`
+	Before string `bson:"before"`
+	After  string `bson:"after"`
+}
+
+// QAuditFilter narrows down the audit entries of a query, empty fields match every entry
+type QAuditFilter struct {
+	ProjectUUID  string
+	ActorUUID    string
+	RouteName    string
+	ResourceType string
+	Resource     string
+	From         time.Time
+	To           time.Time
+}
+
+// matches checks if an audit entry passes the filter
+func (filter QAuditFilter) matches(entry QAuditEntry) bool {
+	return (filter.ProjectUUID == "" || filter.ProjectUUID == entry.ProjectUUID) &&
+		(filter.ActorUUID == "" || filter.ActorUUID == entry.ActorUUID) &&
+		(filter.RouteName == "" || filter.RouteName == entry.RouteName) &&
+		(filter.ResourceType == "" || filter.ResourceType == entry.ResourceType) &&
+		(filter.Resource == "" || filter.Resource == entry.Resource) &&
+		(filter.From.IsZero() || !entry.CreatedOn.Before(filter.From)) &&
+		(filter.To.IsZero() || !entry.CreatedOn.After(filter.To))
+}
+
+// query returns the mongo query that selects the audit entries which pass the filter
+func (filter QAuditFilter) query() map[string]interface{} {
+	query := map[string]interface{}{}
+	fields := map[string]string{
+		"project_uuid":  filter.ProjectUUID,
+		"actor_uuid":    filter.ActorUUID,
+		"route_name":    filter.RouteName,
+		"resource_type": filter.ResourceType,
+		"resource":      filter.Resource,
+	}
+	for field, value := range fields {
+		if value != "" {
+			query[field] = value
+		}
+	}
+
+	createdOn := map[string]interface{}{}
+	if !filter.From.IsZero() {
+		createdOn["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		createdOn["$lte"] = filter.To
+	}
+	if len(createdOn) > 0 {
+		query["created_on"] = createdOn
+	}
+
+	return query
+}
+
+// QRateLimits holds the rate limits of a project or a user
+type QRateLimits struct {
+	ID        interface{}  `bson:"_id,omitempty"`
+	ScopeType string       `bson:"scope_type"`
+	ScopeUUID string       `bson:"scope_uuid"`
+	Limits    []QRateLimit `bson:"limits"`
+}
+
+// QRateLimit is the limit of the requests and bytes per second of a class of routes, 0 means no limit
+type QRateLimit struct {
+	RouteClass        string `bson:"route_class"`
+	RequestsPerSecond int64  `bson:"requests_per_second"`
+	BytesPerSecond    int64  `bson:"bytes_per_second"`
+}
+
+// QRateCounter counts the requests and bytes that a rate limit has been charged with during a window of time
+type QRateCounter struct {
+	ID        interface{} `bson:"_id,omitempty"`
+	Key       string      `bson:"key"`
+	Window    time.Time   `bson:"window"`
+	Requests  int64       `bson:"requests"`
+	Bytes     int64       `bson:"bytes"`
+	ExpiresOn time.Time   `bson:"expires_on"`
+}
+
+// QProjectQuota holds the quotas of a project, 0 means no quota
+type QProjectQuota struct {
+	ID               interface{} `bson:"_id,omitempty"`
+	ProjectUUID      string      `bson:"project_uuid"`
+	MaxTopics        int64       `bson:"max_topics"`
+	MaxSubscriptions int64       `bson:"max_subscriptions"`
+	MaxUsers         int64       `bson:"max_users"`
+	DailyMessages    int64       `bson:"daily_messages"`
+	DailyBytes       int64       `bson:"daily_bytes"`
+}
+
+// QDailyProjectUsage holds the number of messages and bytes published to all of a project's topics during a day
+type QDailyProjectUsage struct {
+	ID          interface{} `bson:"_id,omitempty"`
+	ProjectUUID string      `bson:"project_uuid"`
+	Date        time.Time   `bson:"date"`
+	Messages    int64       `bson:"msg_count"`
+	Bytes       int64       `bson:"bytes"`
+}
+
+// QNotificationTemplate holds a notification template that has been edited, replacing its default
+type QNotificationTemplate struct {
+	ID      interface{} `bson:"_id,omitempty"`
+	Name    string      `bson:"name"`
+	Subject string      `bson:"subject"`
+	Body    string      `bson:"body"`
 }
 
 // QopMetric are the results of the QopMetric query
@@ -76,19 +263,20 @@ type QProject struct {
 
 // QUserRegistration holds information about a UserRegister query
 type QUserRegistration struct {
-	UUID            string `bson:"uuid"`
-	Name            string `bson:"name"`
-	FirstName       string `bson:"first_name"`
-	LastName        string `bson:"last_name"`
-	Organization    string `bson:"organization"`
-	Description     string `bson:"description"`
-	Email           string `bson:"email"`
-	ActivationToken string `bson:"activation_token"`
-	Status          string `bson:"status"`
-	DeclineComment  string `bson:"decline_comment"`
-	RegisteredAt    string `bson:"registered_at"`
-	ModifiedBy      string `bson:"modified_by"`
-	ModifiedAt      string `bson:"modified_at"`
+	UUID             string `bson:"uuid"`
+	Name             string `bson:"name"`
+	FirstName        string `bson:"first_name"`
+	LastName         string `bson:"last_name"`
+	Organization     string `bson:"organization"`
+	Description      string `bson:"description"`
+	Email            string `bson:"email"`
+	ActivationToken  string `bson:"activation_token"`
+	Status           string `bson:"status"`
+	DeclineComment   string `bson:"decline_comment"`
+	RegisteredAt     string `bson:"registered_at"`
+	ModifiedBy       string `bson:"modified_by"`
+	ModifiedAt       string `bson:"modified_at"`
+	EmailConfirmedAt string `bson:"email_confirmed_at"`
 }
 
 // QUser are the results of the QUser query
@@ -107,6 +295,12 @@ type QUser struct {
 	CreatedOn    time.Time       `bson:"created_on"`
 	ModifiedOn   time.Time       `bson:"modified_on"`
 	CreatedBy    string          `bson:"created_by"`
+	// DN is the subject of the client certificate that authenticates the user
+	DN string `bson:"dn"`
+	// IssuerDN is the subject of the CA that has to have issued the client certificate
+	IssuerDN string `bson:"issuer_dn"`
+	// OIDCSubject is the subject claim of the OIDC tokens that authenticate the user
+	OIDCSubject string `bson:"oidc_subject"`
 }
 
 //QProjectRoles include information about projects and roles that user has
@@ -133,6 +327,13 @@ type QTopic struct {
 	SchemaUUID    string      `bson:"schema_uuid"`
 	CreatedOn     time.Time   `bson:"created_on"`
 	ACL           []string    `bson:"acl"`
+	MessageTTL    int64       `bson:"message_ttl"`
+	// QuarantineTopic is the name of the topic, under the same project, that receives the rejected messages
+	QuarantineTopic string `bson:"quarantine_topic"`
+	// Compression is the algorithm that compresses the payloads of the messages before they get stored in the broker
+	Compression string `bson:"compression"`
+	// StoredBytes is the total number of bytes published, as they were stored in the broker
+	StoredBytes int64 `bson:"stored_bytes"`
 }
 
 // QDailyTopicMsgCount holds information about the daily number of messages published to a topic
@@ -163,11 +364,83 @@ type QProjectResourceCount struct {
 
 // QSchema is the query model representing a schema
 type QSchema struct {
-	ProjectUUID string `bson:"project_uuid"`
-	UUID        string `bson:"uuid"`
-	Name        string `bson:"name"`
-	Type        string `bson:"type"`
-	RawSchema   string `bson:"raw_schema"`
+	ProjectUUID   string `bson:"project_uuid"`
+	UUID          string `bson:"uuid"`
+	Name          string `bson:"name"`
+	Type          string `bson:"type"`
+	RawSchema     string `bson:"raw_schema"`
+	Revision      int64  `bson:"revision"`
+	Compatibility string `bson:"compatibility"`
+	// References holds the schema revisions that the current content of the schema depends on
+	References []QSchemaReference `bson:"references"`
+}
+
+// QSchemaReference is the query model of a reference from a schema to a revision of another schema of the same project
+type QSchemaReference struct {
+	Name       string `bson:"name"`
+	SchemaUUID string `bson:"schema_uuid"`
+	Revision   int64  `bson:"revision"`
+}
+
+// QSchemaRevision is the query model representing an immutable revision of a schema
+type QSchemaRevision struct {
+	ID          int64     `bson:"id"`
+	ProjectUUID string    `bson:"project_uuid"`
+	SchemaUUID  string    `bson:"schema_uuid"`
+	Revision    int64     `bson:"revision"`
+	Type        string    `bson:"type"`
+	RawSchema   string    `bson:"raw_schema"`
+	CreatedOn   time.Time `bson:"created_on"`
+	// References holds the schema revisions that the content of the revision depends on
+	References []QSchemaReference `bson:"references"`
+}
+
+// QSchemaInvalidation records a change to a schema, so that every instance can discard what it has cached about it
+type QSchemaInvalidation struct {
+	ProjectUUID   string    `bson:"project_uuid"`
+	SchemaUUID    string    `bson:"schema_uuid"`
+	InvalidatedOn time.Time `bson:"invalidated_on"`
+}
+
+// QCounter is the query model of a named sequence that hands out increasing ids
+type QCounter struct {
+	Name  string `bson:"name"`
+	Value int64  `bson:"value"`
+}
+
+// QScheduledMessage holds a message that a subscription has held back until its delivery time
+type QScheduledMessage struct {
+	ProjectUUID  string    `bson:"project_uuid"`
+	Subscription string    `bson:"subscription"`
+	Offset       int64     `bson:"offset"`
+	DeliverAfter time.Time `bson:"deliver_after"`
+	DeliveredAt  time.Time `bson:"delivered_at"`
+	Message      string    `bson:"message"`
+}
+
+// QIdempotencyKey holds the message ids that were published to a topic under an idempotency key.
+// A pending key has been reserved by a publish that has not completed yet
+type QIdempotencyKey struct {
+	ProjectUUID string    `bson:"project_uuid"`
+	Topic       string    `bson:"topic"`
+	Key         string    `bson:"key"`
+	MessageIDs  []string  `bson:"message_ids"`
+	Pending     bool      `bson:"pending"`
+	CreatedOn   time.Time `bson:"created_on"`
+	ExpiresOn   time.Time `bson:"expires_on"`
+}
+
+// QAPIKey holds a named api key of a user, which can be scoped to a project and to a subset of the user's roles
+type QAPIKey struct {
+	UserUUID string `bson:"user_uuid"`
+	Name     string `bson:"name"`
+	// TokenHash is the peppered hmac-sha256 hash of the key, the key itself is never stored
+	TokenHash   string    `bson:"token_hash"`
+	ProjectUUID string    `bson:"project_uuid"`
+	Roles       []string  `bson:"roles"`
+	CreatedOn   time.Time `bson:"created_on"`
+	ExpiresOn   time.Time `bson:"expires_on"`
+	LastUsedOn  time.Time `bson:"last_used_on"`
 }
 
 func (qUsr *QUser) isInProject(projectUUID string) bool {
@@ -180,7 +453,8 @@ func (qUsr *QUser) isInProject(projectUUID string) bool {
 	return false
 }
 
-func (qUsr *QUser) getProjectRoles(projectUUID string) []string {
+// ProjectRoles returns the roles of the user in the given project, along with any service wide roles
+func (qUsr *QUser) ProjectRoles(projectUUID string) []string {
 
 	result := []string{}
 	for _, item := range qUsr.Projects {
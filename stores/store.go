@@ -14,8 +14,12 @@ type Store interface {
 	//	###### TOPIC QUERIES ######
 
 	LinkTopicSchema(ctx context.Context, projectUUID, name, schemaUUID string) error
+	ModTopicMessageTTL(ctx context.Context, projectUUID string, name string, messageTTL int64) error
+	ModTopicQuarantineTopic(ctx context.Context, projectUUID string, name string, quarantineTopic string) error
+	ModTopicCompression(ctx context.Context, projectUUID string, name string, compression string) error
 	QueryTopicsByACL(ctx context.Context, projectUUID, user string) ([]QTopic, error)
 	QueryTopics(ctx context.Context, projectUUID string, userUUID string, name string, pageToken string, pageSize int64) ([]QTopic, int64, string, error)
+	QueryAllTopics(ctx context.Context) ([]QTopic, error)
 	UpdateTopicLatestPublish(ctx context.Context, projectUUID string, name string, date time.Time) error
 	UpdateTopicPublishRate(ctx context.Context, projectUUID string, name string, rate float64) error
 	RemoveTopic(ctx context.Context, projectUUID string, name string) error
@@ -25,6 +29,7 @@ type Store interface {
 	IncrementTopicMsgNum(ctx context.Context, projectUUID string, name string, num int64) error
 	IncrementDailyTopicMsgCount(ctx context.Context, projectUUID string, topicName string, num int64, date time.Time) error
 	IncrementTopicBytes(ctx context.Context, projectUUID string, name string, totalBytes int64) error
+	IncrementTopicStoredBytes(ctx context.Context, projectUUID string, name string, storedBytes int64) error
 
 	//	###### SUBSCRIPTION QUERIES ######
 
@@ -35,17 +40,40 @@ type Store interface {
 	UpdateSubConsumeRate(ctx context.Context, projectUUID string, name string, rate float64) error
 	RemoveSub(ctx context.Context, projectUUID string, name string) error
 	IncrementSubBytes(ctx context.Context, projectUUID string, name string, totalBytes int64) error
+	IncrementSubStoredBytes(ctx context.Context, projectUUID string, name string, storedBytes int64) error
 	IncrementSubMsgNum(ctx context.Context, projectUUID string, name string, num int64) error
+	IncrementSubExpiredMsgNum(ctx context.Context, projectUUID string, name string, num int64) error
 	InsertSub(ctx context.Context, projectUUID string, name string, topic string, offest int64, ack int, pushCfg QPushConfig, createdOn time.Time) error
 	QueryOneSub(ctx context.Context, projectUUID string, name string) (QSub, error)
 	QueryPushSubs(ctx context.Context) []QSub
 	SubscriptionsCount(ctx context.Context, startDate, endDate time.Time, projectUUIDs []string) (map[string]int64, error)
 	ModAck(ctx context.Context, projectUUID string, name string, ack int) error
+	ModSubOutputFormat(ctx context.Context, projectUUID string, name string, outputFormat string) error
 	UpdateSubOffset(ctx context.Context, projectUUID string, name string, offset int64)
 	UpdateSubPull(ctx context.Context, projectUUID string, name string, offset int64, ts string) error
 	UpdateSubOffsetAck(ctx context.Context, projectUUID string, name string, offset int64, ts string) error
 	ModSubPush(ctx context.Context, projectUUID string, name string, pushCfg QPushConfig) error
 
+	// ##### SCHEDULED MESSAGES QUERIES #####
+
+	InsertScheduledMessage(ctx context.Context, projectUUID string, subscription string, offset int64, deliverAfter time.Time, message string) error
+	QueryDueScheduledMessages(ctx context.Context, projectUUID string, subscription string, maxOffset int64, dueBefore time.Time, deliveredBefore time.Time, limit int64) ([]QScheduledMessage, error)
+	UpdateScheduledMessagesDelivery(ctx context.Context, projectUUID string, subscription string, offsets []int64, deliveredAt time.Time) error
+	AckScheduledMessages(ctx context.Context, projectUUID string, subscription string, offsets []int64) ([]int64, error)
+	DeleteScheduledMessages(ctx context.Context, projectUUID string, subscription string, offsets []int64) error
+	CountScheduledMessages(ctx context.Context, projectUUID string, subscription string, dueAfter time.Time) (int64, error)
+	RemoveSubScheduledMessages(ctx context.Context, projectUUID string, subscription string) error
+	RemoveProjectScheduledMessages(ctx context.Context, projectUUID string) error
+
+	// ##### IDEMPOTENCY KEY QUERIES #####
+
+	ReserveIdempotencyKey(ctx context.Context, projectUUID string, topic string, key string, createdOn time.Time, expiresOn time.Time) (bool, error)
+	InsertIdempotencyKey(ctx context.Context, projectUUID string, topic string, key string, msgIDs []string, createdOn time.Time, expiresOn time.Time) error
+	ReleaseIdempotencyKey(ctx context.Context, projectUUID string, topic string, key string) error
+	QueryIdempotencyKeys(ctx context.Context, projectUUID string, topic string, keys []string, expiresAfter time.Time) ([]QIdempotencyKey, error)
+	RemoveTopicIdempotencyKeys(ctx context.Context, projectUUID string, topic string) error
+	RemoveProjectIdempotencyKeys(ctx context.Context, projectUUID string) error
+
 	// ###### USER QUERIES ######
 
 	HasUsers(ctx context.Context, projectUUID string, users []string) (bool, []string)
@@ -54,9 +82,19 @@ type Store interface {
 	UpdateUser(ctx context.Context, uuid, fname, lname, org, desc string, projects []QProjectRoles, name string, email string, serviceRoles []string, modifiedOn time.Time) error
 	AppendToUserProjects(ctx context.Context, userUUID string, projectUUID string, pRoles ...string) error
 	UpdateUserToken(ctx context.Context, uuid string, token string) error
+	UpdateUserDN(ctx context.Context, uuid string, dn string, issuerDN string) error
+	UpdateUserOIDCSubject(ctx context.Context, uuid string, subject string) error
 	RemoveUser(ctx context.Context, uuid string) error
 	InsertUser(ctx context.Context, uuid string, projects []QProjectRoles, name string, firstName string, lastName string, org string, desc string, token string, email string, serviceRoles []string, createdOn time.Time, modifiedOn time.Time, createdBy string) error
 	GetUserFromToken(ctx context.Context, token string) (QUser, error)
+	GetUserFromEmail(ctx context.Context, email string) (QUser, error)
+	GetUserFromDN(ctx context.Context, dn string, issuerDN string) (QUser, error)
+	GetUserFromOIDCSubject(ctx context.Context, subject string) (QUser, error)
+	InsertAPIKey(ctx context.Context, key QAPIKey) error
+	QueryAPIKeys(ctx context.Context, userUUID string, name string) ([]QAPIKey, error)
+	GetAPIKeyByHash(ctx context.Context, tokenHash string) (QAPIKey, error)
+	UpdateAPIKeyLastUsed(ctx context.Context, userUUID string, name string, lastUsedOn time.Time) error
+	RemoveAPIKeys(ctx context.Context, userUUID string, name string) error
 	UsersCount(ctx context.Context, startDate, endDate time.Time, projectUUIDs []string) (map[string]int64, error)
 	GetUserRoles(ctx context.Context, projectUUID string, token string) ([]string, string)
 
@@ -79,25 +117,65 @@ type Store interface {
 	DeleteRegistration(ctx context.Context, uuid string) error
 	QueryRegistrations(ctx context.Context, regUUID, status, activationToken, name, email, org string) ([]QUserRegistration, error)
 	UpdateRegistration(ctx context.Context, regUUID, status, declineComment, modifiedBy, modifiedAt string) error
+	ConfirmRegistrationEmail(ctx context.Context, regUUID, confirmedAt string) error
 
 	// ##### SCHEMA QUERIES #####
 
-	InsertSchema(ctx context.Context, projectUUID, schemaUUID, name, schemaType, rawSchemaString string) error
+	InsertSchema(ctx context.Context, projectUUID, schemaUUID, name, schemaType, rawSchemaString, compatibility string, revision int64, references []QSchemaReference) error
 	QuerySchemas(ctx context.Context, projectUUID, schemaUUID, name string) ([]QSchema, error)
-	UpdateSchema(ctx context.Context, schemaUUID, name, schemaType, rawSchemaString string) error
+	UpdateSchema(ctx context.Context, schemaUUID, name, schemaType, rawSchemaString, compatibility string, revision int64, references []QSchemaReference) error
 	DeleteSchema(ctx context.Context, schemaUUID string) error
+	QuerySchemasByReference(ctx context.Context, projectUUID, schemaUUID string) ([]QSchema, error)
+	InsertSchemaRevision(ctx context.Context, projectUUID, schemaUUID string, revision int64, schemaType, rawSchemaString string, references []QSchemaReference, createdOn time.Time) error
+	QuerySchemaRevisions(ctx context.Context, projectUUID, schemaUUID string, revision int64) ([]QSchemaRevision, error)
+	QuerySchemaRevisionByID(ctx context.Context, projectUUID string, id int64) ([]QSchemaRevision, error)
+	QuerySchemaRevisionsByReference(ctx context.Context, projectUUID, schemaUUID string) ([]QSchemaRevision, error)
+	InsertSchemaInvalidation(ctx context.Context, projectUUID, schemaUUID string, invalidatedOn time.Time) error
+	QuerySchemaInvalidations(ctx context.Context, since time.Time) ([]QSchemaInvalidation, error)
 
 	// ##### ACL QUERIES ######
 	QueryACL(ctx context.Context, projectUUID string, resource string, name string) (QAcl, error)
-	ExistsInACL(ctx context.Context, projectUUID string, resource string, resourceName string, userUUID string) error
-	ModACL(ctx context.Context, projectUUID string, resource string, name string, acl []string) error
+	ExistsInACL(ctx context.Context, projectUUID string, resource string, resourceName string, userUUID string, action string) error
+	ModACL(ctx context.Context, projectUUID string, resource string, name string, acl []string, actions map[string][]string) error
 	AppendToACL(ctx context.Context, projectUUID string, resource string, name string, acl []string) error
 	RemoveFromACL(ctx context.Context, projectUUID string, resource string, name string, acl []string) error
 
+	// ##### GROUP QUERIES ######
+	InsertGroup(ctx context.Context, group QGroup) error
+	QueryGroups(ctx context.Context, projectUUID string, uuid string, name string) ([]QGroup, error)
+	AppendToGroup(ctx context.Context, projectUUID string, name string, members []string) error
+	RemoveFromGroup(ctx context.Context, projectUUID string, name string, members []string) error
+	RemoveGroups(ctx context.Context, projectUUID string, name string) error
+
+	// ##### AUDIT QUERIES ######
+	InsertAuditEntry(ctx context.Context, entry QAuditEntry) error
+	QueryAuditEntries(ctx context.Context, filter QAuditFilter, pageToken string, pageSize int64) ([]QAuditEntry, int64, string, error)
+
+	// ##### RATE LIMIT QUERIES #####
+	QueryRateLimits(ctx context.Context, scopeType string, scopeUUID string) ([]QRateLimit, error)
+	UpdateRateLimits(ctx context.Context, scopeType string, scopeUUID string, limits []QRateLimit) error
+	IncrementRateCounter(ctx context.Context, key string, window time.Time, requests int64, bytes int64, expiresOn time.Time) (QRateCounter, error)
+
+	// ##### QUOTA QUERIES #####
+	QueryProjectQuota(ctx context.Context, projectUUID string) (QProjectQuota, error)
+	UpdateProjectQuota(ctx context.Context, quota QProjectQuota) error
+	QueryDailyProjectUsage(ctx context.Context, projectUUID string, date time.Time) (QDailyProjectUsage, error)
+	IncrementDailyProjectUsage(ctx context.Context, projectUUID string, date time.Time, messages int64, bytes int64) error
+	ReserveDailyProjectUsage(ctx context.Context, projectUUID string, date time.Time, messages int64, bytes int64, maxMessages int64, maxBytes int64) (bool, error)
+	RemoveProjectQuota(ctx context.Context, projectUUID string) error
+
+	// ##### NOTIFICATION QUERIES #####
+	QueryNotificationTemplates(ctx context.Context, name string) ([]QNotificationTemplate, error)
+	UpdateNotificationTemplate(ctx context.Context, template QNotificationTemplate) error
+	DeleteNotificationTemplate(ctx context.Context, name string) error
+
 	// ##### ROLES QUERIES #####
 	HasResourceRoles(ctx context.Context, resource string, roles []string) bool
 	InsertResourceRoles(ctx context.Context, resource string, roles []string) error
 	GetAllRoles(ctx context.Context) []string
+	QueryResourceRoles(ctx context.Context) ([]QRole, error)
+	AddResourceRole(ctx context.Context, resource string, role string) error
+	RemoveResourceRole(ctx context.Context, resource string, role string) error
 
 	// ##### OP METRICS QUERIES #####
 	InsertOpMetric(ctx context.Context, hostname string, cpu float64, mem float64) error
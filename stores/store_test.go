@@ -22,17 +22,17 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal("mockbase", store.Database)
 
 	eTopList := []QTopic{
-		{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}},
-		{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}},
-		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}},
-		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}},
+		{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
+		{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
+		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
+		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
 	}
 
 	eSubList := []QSub{
-		{3, "argo_uuid", "sub4", "topic4", 0, 0, "", "http_endpoint", "endpoint.foo", 1, "autogen", "auth-header-1", 10, "linear", 300, 0, 0, "push-id-1", true, "", "", "", true, time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}},
-		{2, "argo_uuid", "sub3", "topic3", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}},
-		{1, "argo_uuid", "sub2", "topic2", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}},
-		{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}},
+		{3, "argo_uuid", "sub4", "topic4", 0, 0, "", "http_endpoint", "endpoint.foo", 1, "autogen", "auth-header-1", 10, "linear", 300, 0, 0, "push-id-1", true, "", "", "", true, time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0},
+		{2, "argo_uuid", "sub3", "topic3", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0},
+		{1, "argo_uuid", "sub2", "topic2", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0},
+		{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0},
 	}
 	// retrieve all topics
 	tpList, ts1, pg1, _ := store.QueryTopics(ctx, "argo_uuid", "", "", "", 0)
@@ -42,8 +42,8 @@ func (suite *StoreTestSuite) TestMockStore() {
 
 	// retrieve first 2
 	eTopList1st2 := []QTopic{
-		{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}},
-		{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}},
+		{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
+		{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
 	}
 	tpList2, ts2, pg2, _ := store.QueryTopics(ctx, "argo_uuid", "", "", "", 2)
 	suite.Equal(eTopList1st2, tpList2)
@@ -52,7 +52,7 @@ func (suite *StoreTestSuite) TestMockStore() {
 
 	// retrieve the last one
 	eTopList3 := []QTopic{
-		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}},
+		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
 	}
 	tpList3, ts3, pg3, _ := store.QueryTopics(ctx, "argo_uuid", "", "", "0", 1)
 	suite.Equal(eTopList3, tpList3)
@@ -61,7 +61,7 @@ func (suite *StoreTestSuite) TestMockStore() {
 
 	// retrieve a single topic
 	eTopList4 := []QTopic{
-		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}},
+		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
 	}
 	tpList4, ts4, pg4, _ := store.QueryTopics(ctx, "argo_uuid", "", "topic1", "", 0)
 	suite.Equal(eTopList4, tpList4)
@@ -71,7 +71,7 @@ func (suite *StoreTestSuite) TestMockStore() {
 	// retrieve a single topic
 	store.LinkTopicSchema(ctx, "argo_uuid", "topic1", "schema_uuid_1")
 	eTopListSchema := []QTopic{
-		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "schema_uuid_1", time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}},
+		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "schema_uuid_1", time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
 	}
 	tpListSchema, _, _, _ := store.QueryTopics(ctx, "argo_uuid", "", "topic1", "", 0)
 	suite.Equal(eTopListSchema, tpListSchema)
@@ -79,8 +79,8 @@ func (suite *StoreTestSuite) TestMockStore() {
 
 	// retrieve user's topics
 	eTopList5 := []QTopic{
-		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}},
-		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}},
+		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
+		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
 	}
 	tpList5, ts5, pg5, _ := store.QueryTopics(ctx, "argo_uuid", "uuid1", "", "", 0)
 	suite.Equal(eTopList5, tpList5)
@@ -89,7 +89,7 @@ func (suite *StoreTestSuite) TestMockStore() {
 
 	// retrieve use's topic with pagination
 	eTopList6 := []QTopic{
-		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}},
+		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
 	}
 
 	tpList6, ts6, pg6, _ := store.QueryTopics(ctx, "argo_uuid", "uuid1", "", "", 1)
@@ -105,8 +105,8 @@ func (suite *StoreTestSuite) TestMockStore() {
 
 	// retrieve first 2 subs
 	eSubListFirstPage := []QSub{
-		{3, "argo_uuid", "sub4", "topic4", 0, 0, "", "http_endpoint", "endpoint.foo", 1, "autogen", "auth-header-1", 10, "linear", 300, 0, 0, "push-id-1", true, "", "", "", true, time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}},
-		{2, "argo_uuid", "sub3", "topic3", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}}}
+		{3, "argo_uuid", "sub4", "topic4", 0, 0, "", "http_endpoint", "endpoint.foo", 1, "autogen", "auth-header-1", 10, "linear", 300, 0, 0, "push-id-1", true, "", "", "", true, time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0},
+		{2, "argo_uuid", "sub3", "topic3", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0}}
 
 	subList2, ts2, pg2, err2 := store.QuerySubs(ctx, "argo_uuid", "", "", "", 2)
 	suite.Equal(eSubListFirstPage, subList2)
@@ -115,8 +115,8 @@ func (suite *StoreTestSuite) TestMockStore() {
 
 	// retrieve next 2 subs
 	eSubListNextPage := []QSub{
-		{1, "argo_uuid", "sub2", "topic2", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}},
-		{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}},
+		{1, "argo_uuid", "sub2", "topic2", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0},
+		{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0},
 	}
 
 	subList3, ts3, pg3, err3 := store.QuerySubs(ctx, "argo_uuid", "", "", "1", 2)
@@ -213,23 +213,40 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal(true, store.HasResourceRoles(ctx, "topics:list_all", []string{"publisher"}))
 	suite.Equal(true, store.HasResourceRoles(ctx, "topics:publish", []string{"publisher"}))
 
+	// bind and unbind roles
+	suite.Nil(store.AddResourceRole(ctx, "topics:publish", "reader"))
+	suite.Nil(store.AddResourceRole(ctx, "topics:publish", "reader"))
+	suite.Nil(store.AddResourceRole(ctx, "metrics:opMetrics", "reader"))
+	resRoles, _ := store.QueryResourceRoles(ctx)
+	suite.Equal([]QRole{
+		{"topics:list_all", []string{"admin", "reader", "publisher"}},
+		{"topics:publish", []string{"admin", "publisher", "reader"}},
+		{"metrics:opMetrics", []string{"reader"}},
+	}, resRoles)
+	suite.Nil(store.RemoveResourceRole(ctx, "topics:publish", "reader"))
+	suite.Equal(false, store.HasResourceRoles(ctx, "topics:publish", []string{"reader"}))
+	suite.Equal(true, store.HasResourceRoles(ctx, "metrics:opMetrics", []string{"reader"}))
+	suite.Nil(store.RemoveResourceRole(ctx, "", "reader"))
+	suite.Equal(false, store.HasResourceRoles(ctx, "metrics:opMetrics", []string{"reader"}))
+	suite.Equal(false, store.HasResourceRoles(ctx, "topics:list_all", []string{"reader"}))
+
 	store.InsertTopic(ctx, "argo_uuid", "topicFresh", "", time.Date(2020, 9, 11, 0, 0, 0, 0, time.UTC))
 	store.InsertSub(ctx, "argo_uuid", "subFresh", "topicFresh", 0, 10, QPushConfig{}, time.Date(2020, 12, 19, 0, 0, 0, 0, time.UTC))
 
 	eTopList2 := []QTopic{
-		{4, "argo_uuid", "topicFresh", 0, 0, time.Time{}, 0, "", time.Date(2020, 9, 11, 0, 0, 0, 0, time.UTC), []string{}},
-		{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}},
-		{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}},
-		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}},
-		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}},
+		{4, "argo_uuid", "topicFresh", 0, 0, time.Time{}, 0, "", time.Date(2020, 9, 11, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
+		{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
+		{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
+		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
+		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}, 0, "", "", 0},
 	}
 
 	eSubList2 := []QSub{
-		{4, "argo_uuid", "subFresh", "topicFresh", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Time{}, 0, time.Date(2020, 12, 19, 0, 0, 0, 0, time.UTC), []string{}},
-		{3, "argo_uuid", "sub4", "topic4", 0, 0, "", "http_endpoint", "endpoint.foo", 1, "autogen", "auth-header-1", 10, "linear", 300, 0, 0, "push-id-1", true, "", "", "", true, time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}},
-		{2, "argo_uuid", "sub3", "topic3", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}},
-		{1, "argo_uuid", "sub2", "topic2", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}},
-		{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}}}
+		{4, "argo_uuid", "subFresh", "topicFresh", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Time{}, 0, time.Date(2020, 12, 19, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0},
+		{3, "argo_uuid", "sub4", "topic4", 0, 0, "", "http_endpoint", "endpoint.foo", 1, "autogen", "auth-header-1", 10, "linear", 300, 0, 0, "push-id-1", true, "", "", "", true, time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0},
+		{2, "argo_uuid", "sub3", "topic3", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0},
+		{1, "argo_uuid", "sub2", "topic2", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0},
+		{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0}}
 
 	tpList, _, _, _ = store.QueryTopics(ctx, "argo_uuid", "", "", "", 0)
 	suite.Equal(eTopList2, tpList)
@@ -253,7 +270,7 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal("not found", err.Error())
 
 	sb, err := store.QueryOneSub(ctx, "argo_uuid", "sub1")
-	esb := QSub{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}}
+	esb := QSub{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", "", 0, "", "", 10, "", 0, 0, 0, "", false, "", "", "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC), []string{}, 0, false, "", 0}
 	suite.Equal(esb, sb)
 
 	// Test modify ack deadline in store
@@ -261,6 +278,13 @@ func (suite *StoreTestSuite) TestMockStore() {
 	subAck, _ := store.QueryOneSub(ctx, "argo_uuid", "sub1")
 	suite.Equal(66, subAck.Ack)
 
+	// Test modify output format in store
+	suite.Nil(store.ModSubOutputFormat(ctx, "argo_uuid", "sub1", "cloudevents"))
+	subFormat, _ := store.QueryOneSub(ctx, "argo_uuid", "sub1")
+	suite.Equal("cloudevents", subFormat.OutputFormat)
+	suite.Nil(store.ModSubOutputFormat(ctx, "argo_uuid", "sub1", ""))
+	suite.Equal("not found", store.ModSubOutputFormat(ctx, "argo_uuid", "unknown", "cloudevents").Error())
+
 	// Test mod push sub
 	qCfg := QPushConfig{
 		Type:                "http_endpoint",
@@ -292,38 +316,47 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal("not found", e2.Error())
 
 	// exists in acl
-	existsE1 := store.ExistsInACL(ctx, "argo_uuid", "topics", "topic1", "uuid1")
+	existsE1 := store.ExistsInACL(ctx, "argo_uuid", "topics", "topic1", "uuid1", "")
 	suite.Nil(existsE1)
 
-	existsE2 := store.ExistsInACL(ctx, "argo_uuid", "topics", "topic1", "unknown")
+	existsE2 := store.ExistsInACL(ctx, "argo_uuid", "topics", "topic1", "unknown", "")
 	suite.Equal("not found", existsE2.Error())
 
+	// acl entries restricted to specific actions
+	suite.Nil(store.ModACL(ctx, "argo_uuid", "topics", "topic1", []string{"uuid1", "uuid2"}, map[string][]string{"uuid1": {"publish"}}))
+	suite.Nil(store.ExistsInACL(ctx, "argo_uuid", "topics", "topic1", "uuid1", "publish"))
+	suite.Nil(store.ExistsInACL(ctx, "argo_uuid", "topics", "topic1", "uuid1", ""))
+	suite.Equal("not found", store.ExistsInACL(ctx, "argo_uuid", "topics", "topic1", "uuid1", "viewMetrics").Error())
+	suite.Nil(store.ExistsInACL(ctx, "argo_uuid", "topics", "topic1", "uuid2", "viewMetrics"))
+	suite.Equal(errors.New("wrong resource type"), store.ModACL(ctx, "argo_uuid", "topics", "unknown", nil, nil))
+	suite.Nil(store.ModACL(ctx, "argo_uuid", "topics", "topic1", []string{"uuid1", "uuid2"}, nil))
+
 	// Query ACLS
-	ExpectedACL01 := QAcl{[]string{"uuid1", "uuid2"}}
+	ExpectedACL01 := QAcl{ACL: []string{"uuid1", "uuid2"}}
 	QAcl01, _ := store.QueryACL(ctx, "argo_uuid", "topics", "topic1")
 	suite.Equal(ExpectedACL01, QAcl01)
 
-	ExpectedACL02 := QAcl{[]string{"uuid1", "uuid2", "uuid4"}}
+	ExpectedACL02 := QAcl{ACL: []string{"uuid1", "uuid2", "uuid4"}}
 	QAcl02, _ := store.QueryACL(ctx, "argo_uuid", "topics", "topic2")
 	suite.Equal(ExpectedACL02, QAcl02)
 
-	ExpectedACL03 := QAcl{[]string{"uuid3"}}
+	ExpectedACL03 := QAcl{ACL: []string{"uuid3"}}
 	QAcl03, _ := store.QueryACL(ctx, "argo_uuid", "topics", "topic3")
 	suite.Equal(ExpectedACL03, QAcl03)
 
-	ExpectedACL04 := QAcl{[]string{"uuid1", "uuid2"}}
+	ExpectedACL04 := QAcl{ACL: []string{"uuid1", "uuid2"}}
 	QAcl04, _ := store.QueryACL(ctx, "argo_uuid", "subscriptions", "sub1")
 	suite.Equal(ExpectedACL04, QAcl04)
 
-	ExpectedACL05 := QAcl{[]string{"uuid1", "uuid3"}}
+	ExpectedACL05 := QAcl{ACL: []string{"uuid1", "uuid3"}}
 	QAcl05, _ := store.QueryACL(ctx, "argo_uuid", "subscriptions", "sub2")
 	suite.Equal(ExpectedACL05, QAcl05)
 
-	ExpectedACL06 := QAcl{[]string{"uuid4", "uuid2", "uuid1"}}
+	ExpectedACL06 := QAcl{ACL: []string{"uuid4", "uuid2", "uuid1"}}
 	QAcl06, _ := store.QueryACL(ctx, "argo_uuid", "subscriptions", "sub3")
 	suite.Equal(ExpectedACL06, QAcl06)
 
-	ExpectedACL07 := QAcl{[]string{"uuid2", "uuid4", "uuid7"}}
+	ExpectedACL07 := QAcl{ACL: []string{"uuid2", "uuid4", "uuid7"}}
 	QAcl07, _ := store.QueryACL(ctx, "argo_uuid", "subscriptions", "sub4")
 	suite.Equal(ExpectedACL07, QAcl07)
 
@@ -332,17 +365,17 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal(errors.New("not found"), err08)
 
 	// test mod acl
-	eModACL1 := store.ModACL(ctx, "argo_uuid", "topics", "topic1", []string{"u1", "u2"})
+	eModACL1 := store.ModACL(ctx, "argo_uuid", "topics", "topic1", []string{"u1", "u2"}, nil)
 	suite.Nil(eModACL1)
 	tACL := store.TopicsACL["topic1"].ACL
 	suite.Equal([]string{"u1", "u2"}, tACL)
 
-	eModACL2 := store.ModACL(ctx, "argo_uuid", "subscriptions", "sub1", []string{"u1", "u2"})
+	eModACL2 := store.ModACL(ctx, "argo_uuid", "subscriptions", "sub1", []string{"u1", "u2"}, nil)
 	suite.Nil(eModACL2)
 	sACL := store.SubsACL["sub1"].ACL
 	suite.Equal([]string{"u1", "u2"}, sACL)
 
-	eModACL3 := store.ModACL(ctx, "argo_uuid", "mistype", "sub1", []string{"u1", "u2"})
+	eModACL3 := store.ModACL(ctx, "argo_uuid", "mistype", "sub1", []string{"u1", "u2"}, nil)
 	suite.Equal("wrong resource type", eModACL3.Error())
 
 	// test append acl
@@ -515,6 +548,167 @@ func (suite *StoreTestSuite) TestMockStore() {
 	usrGet, _ := store.GetUserFromToken(ctx, "A3B94A94V3A")
 	suite.Equal(usr10[0], usrGet)
 
+	usrGet, _ = store.GetUserFromEmail(ctx, "Test@test.com")
+	suite.Equal("uuid0", usrGet.UUID)
+	_, err = store.GetUserFromEmail(ctx, "")
+	suite.Equal(errors.New("not found"), err)
+	_, err = store.GetUserFromEmail(ctx, "foo-email")
+	suite.Equal(errors.New("multiple users"), err)
+
+	store.UpdateUserOIDCSubject(ctx, "uuid0", "aai-subject-0")
+	usrGet, _ = store.GetUserFromOIDCSubject(ctx, "aai-subject-0")
+	suite.Equal("uuid0", usrGet.UUID)
+	_, err = store.GetUserFromOIDCSubject(ctx, "Test")
+	suite.Equal(errors.New("not found"), err)
+	store.UpdateUserOIDCSubject(ctx, "uuid0", "")
+	_, err = store.GetUserFromOIDCSubject(ctx, "aai-subject-0")
+	suite.Equal(errors.New("not found"), err)
+
+	store.UpdateUserDN(ctx, "uuid0", "CN=Test,O=ARGO,C=GR", "CN=ARGO CA,O=ARGO,C=GR")
+	usrGet, _ = store.GetUserFromDN(ctx, "CN=Test,O=ARGO,C=GR", "CN=ARGO CA,O=ARGO,C=GR")
+	suite.Equal("uuid0", usrGet.UUID)
+	_, err = store.GetUserFromDN(ctx, "CN=Other,O=ARGO,C=GR", "CN=ARGO CA,O=ARGO,C=GR")
+	suite.Equal(errors.New("not found"), err)
+	_, err = store.GetUserFromDN(ctx, "CN=Test,O=ARGO,C=GR", "CN=Other CA,O=ARGO,C=GR")
+	suite.Equal(errors.New("not found"), err)
+
+	// api keys
+	key := QAPIKey{UserUUID: "uuid0", Name: "ci", TokenHash: "hash0", Roles: []string{"publisher"}}
+	suite.Nil(store.InsertAPIKey(ctx, key))
+	store.InsertAPIKey(ctx, QAPIKey{UserUUID: "uuid0", Name: "other", TokenHash: "hash1"})
+	keys, _ := store.QueryAPIKeys(ctx, "uuid0", "")
+	suite.Equal(2, len(keys))
+	keys, _ = store.QueryAPIKeys(ctx, "uuid0", "ci")
+	suite.Equal([]QAPIKey{key}, keys)
+	keyGet, _ := store.GetAPIKeyByHash(ctx, "hash0")
+	suite.Equal(key, keyGet)
+	_, err = store.GetAPIKeyByHash(ctx, "unknown")
+	suite.Equal(errors.New("not found"), err)
+	lastUsed := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.UpdateAPIKeyLastUsed(ctx, "uuid0", "ci", lastUsed)
+	keyGet, _ = store.GetAPIKeyByHash(ctx, "hash0")
+	suite.Equal(lastUsed, keyGet.LastUsedOn)
+	suite.Nil(store.RemoveAPIKeys(ctx, "uuid0", "ci"))
+	suite.Equal(errors.New("not found"), store.RemoveAPIKeys(ctx, "uuid0", "ci"))
+	suite.Nil(store.RemoveAPIKeys(ctx, "uuid0", ""))
+	keys, _ = store.QueryAPIKeys(ctx, "uuid0", "")
+	suite.Equal(0, len(keys))
+
+	// groups
+	group := QGroup{UUID: "group_uuid", ProjectUUID: "argo_uuid", Name: "team", Members: []string{"uuid1"}}
+	suite.Nil(store.InsertGroup(ctx, group))
+	groups, _ := store.QueryGroups(ctx, "argo_uuid", "", "")
+	suite.Equal([]QGroup{group}, groups)
+	groups, _ = store.QueryGroups(ctx, "argo_uuid", "group_uuid", "")
+	suite.Equal([]QGroup{group}, groups)
+	groups, _ = store.QueryGroups(ctx, "argo_uuid_2", "", "team")
+	suite.Equal(0, len(groups))
+	suite.Nil(store.AppendToGroup(ctx, "argo_uuid", "team", []string{"uuid2", "uuid1"}))
+	groups, _ = store.QueryGroups(ctx, "argo_uuid", "", "team")
+	suite.Equal([]string{"uuid1", "uuid2"}, groups[0].Members)
+	suite.Nil(store.RemoveFromGroup(ctx, "argo_uuid", "team", []string{"uuid1"}))
+	groups, _ = store.QueryGroups(ctx, "argo_uuid", "", "team")
+	suite.Equal([]string{"uuid2"}, groups[0].Members)
+	suite.Equal(errors.New("not found"), store.AppendToGroup(ctx, "argo_uuid", "unknown", []string{"uuid2"}))
+
+	// the members of a group in an acl exist in it
+	suite.NotNil(store.ExistsInACL(ctx, "argo_uuid", "topics", "topic3", "uuid2", ""))
+	store.AppendToACL(ctx, "argo_uuid", "topics", "topic3", []string{GroupACLEntry("group_uuid")})
+	suite.Nil(store.ExistsInACL(ctx, "argo_uuid", "topics", "topic3", "uuid2", ""))
+	suite.NotNil(store.ExistsInACL(ctx, "argo_uuid", "topics", "topic3", "uuid1", ""))
+	store.ModACL(ctx, "argo_uuid", "topics", "topic3", store.TopicsACL["topic3"].ACL, map[string][]string{GroupACLEntry("group_uuid"): {"publish"}})
+	suite.Nil(store.ExistsInACL(ctx, "argo_uuid", "topics", "topic3", "uuid2", "publish"))
+	suite.NotNil(store.ExistsInACL(ctx, "argo_uuid", "topics", "topic3", "uuid2", "viewMetrics"))
+	store.RemoveFromACL(ctx, "argo_uuid", "topics", "topic3", []string{GroupACLEntry("group_uuid")})
+	suite.Equal(map[string][]string{}, store.TopicsACL["topic3"].Actions)
+	store.ModACL(ctx, "argo_uuid", "topics", "topic3", store.TopicsACL["topic3"].ACL, nil)
+
+	suite.Nil(store.RemoveGroups(ctx, "argo_uuid", "team"))
+	suite.Equal(errors.New("not found"), store.RemoveGroups(ctx, "argo_uuid", "team"))
+	suite.Nil(store.RemoveGroups(ctx, "argo_uuid", ""))
+
+	// audit log
+	auditTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	suite.Nil(store.InsertAuditEntry(ctx, QAuditEntry{RouteName: "topics:create", ProjectUUID: "argo_uuid", CreatedOn: auditTime}))
+	suite.Nil(store.InsertAuditEntry(ctx, QAuditEntry{RouteName: "users:create", CreatedOn: auditTime.Add(time.Hour)}))
+	suite.Nil(store.InsertAuditEntry(ctx, QAuditEntry{RouteName: "topics:delete", ProjectUUID: "argo_uuid", CreatedOn: auditTime.Add(2 * time.Hour)}))
+	entries, total, nextAuditPage, _ := store.QueryAuditEntries(ctx, QAuditFilter{}, "", 2)
+	suite.Equal(int64(3), total)
+	suite.Equal("1", nextAuditPage)
+	suite.Equal("topics:delete", entries[0].RouteName)
+	suite.Equal("users:create", entries[1].RouteName)
+	entries, _, nextAuditPage, _ = store.QueryAuditEntries(ctx, QAuditFilter{}, "1", 2)
+	suite.Equal("", nextAuditPage)
+	suite.Equal("topics:create", entries[0].RouteName)
+	entries, total, _, _ = store.QueryAuditEntries(ctx, QAuditFilter{ProjectUUID: "argo_uuid", To: auditTime.Add(time.Hour)}, "", 0)
+	suite.Equal(int64(1), total)
+	suite.Equal("topics:create", entries[0].RouteName)
+	_, _, _, err = store.QueryAuditEntries(ctx, QAuditFilter{}, "invalid", 0)
+	suite.NotNil(err)
+
+	// rate limits
+	limits := []QRateLimit{{RouteClass: "publish", RequestsPerSecond: 10, BytesPerSecond: 1024}}
+	suite.Nil(store.UpdateRateLimits(ctx, "projects", "argo_uuid", limits))
+	qLimits, _ := store.QueryRateLimits(ctx, "projects", "argo_uuid")
+	suite.Equal(limits, qLimits)
+	qLimits, _ = store.QueryRateLimits(ctx, "users", "argo_uuid")
+	suite.Equal([]QRateLimit{}, qLimits)
+	suite.Nil(store.UpdateRateLimits(ctx, "projects", "argo_uuid", nil))
+	qLimits, _ = store.QueryRateLimits(ctx, "projects", "argo_uuid")
+	suite.Equal([]QRateLimit{}, qLimits)
+
+	window := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	counter, _ := store.IncrementRateCounter(ctx, "projects:argo_uuid:publish", window, 1, 100, window.Add(time.Minute))
+	suite.Equal(QRateCounter{Key: "projects:argo_uuid:publish", Window: window, Requests: 1, Bytes: 100, ExpiresOn: window.Add(time.Minute)}, counter)
+	counter, _ = store.IncrementRateCounter(ctx, "projects:argo_uuid:publish", window, 1, 50, window.Add(time.Minute))
+	suite.Equal(int64(2), counter.Requests)
+	suite.Equal(int64(150), counter.Bytes)
+	counter, _ = store.IncrementRateCounter(ctx, "projects:argo_uuid:publish", window.Add(time.Second), 1, 50, window.Add(time.Minute))
+	suite.Equal(int64(1), counter.Requests)
+
+	// project quotas
+	qQuota, _ := store.QueryProjectQuota(ctx, "argo_uuid")
+	suite.Equal(QProjectQuota{ProjectUUID: "argo_uuid"}, qQuota)
+	suite.Nil(store.UpdateProjectQuota(ctx, QProjectQuota{ProjectUUID: "argo_uuid", MaxTopics: 10, DailyBytes: 1024}))
+	suite.Nil(store.UpdateProjectQuota(ctx, QProjectQuota{ProjectUUID: "argo_uuid", MaxTopics: 5}))
+	qQuota, _ = store.QueryProjectQuota(ctx, "argo_uuid")
+	suite.Equal(QProjectQuota{ProjectUUID: "argo_uuid", MaxTopics: 5}, qQuota)
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	suite.Nil(store.IncrementDailyProjectUsage(ctx, "argo_uuid", day, 2, 100))
+	suite.Nil(store.IncrementDailyProjectUsage(ctx, "argo_uuid", day, 1, 50))
+	qUsage, _ := store.QueryDailyProjectUsage(ctx, "argo_uuid", day)
+	suite.Equal(QDailyProjectUsage{ProjectUUID: "argo_uuid", Date: day, Messages: 3, Bytes: 150}, qUsage)
+	qUsage, _ = store.QueryDailyProjectUsage(ctx, "argo_uuid", day.AddDate(0, 0, 1))
+	suite.Equal(QDailyProjectUsage{ProjectUUID: "argo_uuid", Date: day.AddDate(0, 0, 1)}, qUsage)
+	reserved, err := store.ReserveDailyProjectUsage(ctx, "argo_uuid", day, 2, 50, 5, 200)
+	suite.Nil(err)
+	suite.True(reserved)
+	reserved, _ = store.ReserveDailyProjectUsage(ctx, "argo_uuid", day, 1, 1, 5, 200)
+	suite.False(reserved)
+	reserved, _ = store.ReserveDailyProjectUsage(ctx, "argo_uuid", day, 1, 1, 0, 0)
+	suite.True(reserved)
+	qUsage, _ = store.QueryDailyProjectUsage(ctx, "argo_uuid", day)
+	suite.Equal(QDailyProjectUsage{ProjectUUID: "argo_uuid", Date: day, Messages: 6, Bytes: 201}, qUsage)
+
+	suite.Nil(store.RemoveProjectQuota(ctx, "argo_uuid"))
+	suite.Equal(0, len(store.ProjectQuotas))
+	suite.Equal(0, len(store.DailyProjectUsage))
+
+	// notification templates
+	qTmpls, _ := store.QueryNotificationTemplates(ctx, "")
+	suite.Equal([]QNotificationTemplate{}, qTmpls)
+	suite.Nil(store.UpdateNotificationTemplate(ctx, QNotificationTemplate{Name: "registration_pending", Subject: "s1", Body: "b1"}))
+	suite.Nil(store.UpdateNotificationTemplate(ctx, QNotificationTemplate{Name: "registration_pending", Subject: "s2", Body: "b2"}))
+	suite.Nil(store.UpdateNotificationTemplate(ctx, QNotificationTemplate{Name: "registration_declined", Subject: "s3", Body: "b3"}))
+	qTmpls, _ = store.QueryNotificationTemplates(ctx, "registration_pending")
+	suite.Equal([]QNotificationTemplate{{Name: "registration_pending", Subject: "s2", Body: "b2"}}, qTmpls)
+	qTmpls, _ = store.QueryNotificationTemplates(ctx, "")
+	suite.Equal(2, len(qTmpls))
+	suite.Nil(store.DeleteNotificationTemplate(ctx, "registration_pending"))
+	qTmpls, _ = store.QueryNotificationTemplates(ctx, "")
+	suite.Equal([]QNotificationTemplate{{Name: "registration_declined", Subject: "s3", Body: "b3"}}, qTmpls)
+
 	// test paginated query users
 	store2 := NewMockStore("", "")
 
@@ -583,14 +777,16 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Nil(qpmcerr1)
 
 	// test InsertSchema
-	eis := store.InsertSchema(ctx, "argo_uuid", "uuid1", "s1-insert", "json", "raw")
+	eis := store.InsertSchema(ctx, "argo_uuid", "uuid1", "s1-insert", "json", "raw", "NONE", 1, nil)
 	qs1, _ := store.QuerySchemas(ctx, "argo_uuid", "uuid1", "s1-insert")
 	suite.Equal(QSchema{
-		ProjectUUID: "argo_uuid",
-		UUID:        "uuid1",
-		Name:        "s1-insert",
-		Type:        "json",
-		RawSchema:   "raw",
+		ProjectUUID:   "argo_uuid",
+		UUID:          "uuid1",
+		Name:          "s1-insert",
+		Type:          "json",
+		RawSchema:     "raw",
+		Revision:      1,
+		Compatibility: "NONE",
 	}, qs1[0])
 	suite.Nil(eis)
 
@@ -610,7 +806,7 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal(expectedSchemas[0], qqs3[0])
 
 	// test update schema
-	_ = store2.UpdateSchema(ctx, "schema_uuid_1", "new-name", "new-type", "new-raw-schema")
+	_ = store2.UpdateSchema(ctx, "schema_uuid_1", "new-name", "new-type", "new-raw-schema", "", 0, nil)
 	eus := QSchema{UUID: "schema_uuid_1", ProjectUUID: "argo_uuid", Type: "new-type", Name: "new-name", RawSchema: "new-raw-schema"}
 	qus, _ := store2.QuerySchemas(ctx, "argo_uuid", "schema_uuid_1", "")
 	suite.Equal(eus, qus[0])
@@ -626,6 +822,67 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal([]QSchema{}, expd)
 	suite.Nil(ed)
 
+	// test schema revisions
+	suite.Nil(store4.InsertSchemaRevision(ctx, "argo_uuid", "schema_uuid_2", 2, "json", "raw2", nil, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)))
+	suite.Nil(store4.InsertSchemaRevision(ctx, "argo_uuid", "schema_uuid_2", 1, "json", "raw1", nil, time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)))
+	suite.Equal("exists", store4.InsertSchemaRevision(ctx, "argo_uuid", "schema_uuid_2", 1, "json", "raw1", nil, time.Now()).Error())
+	// the compatibility mode and the revision get updated along with the rest of the schema
+	suite.Nil(store4.UpdateSchema(ctx, "schema_uuid_2", "", "", "raw2", "FULL", 2, nil))
+	qsr, _ := store4.QuerySchemas(ctx, "argo_uuid", "schema_uuid_2", "")
+	suite.Equal(int64(2), qsr[0].Revision)
+	suite.Equal("FULL", qsr[0].Compatibility)
+	revs, _ := store4.QuerySchemaRevisions(ctx, "argo_uuid", "schema_uuid_2", 0)
+	suite.Equal(2, len(revs))
+	suite.Equal(int64(1), revs[0].Revision)
+	suite.Equal("raw1", revs[0].RawSchema)
+	revs, _ = store4.QuerySchemaRevisions(ctx, "argo_uuid", "schema_uuid_2", 2)
+	suite.Equal(1, len(revs))
+	suite.Equal("raw2", revs[0].RawSchema)
+	suite.Equal(int64(1), revs[0].ID)
+	revs, _ = store4.QuerySchemaRevisionByID(ctx, "argo_uuid", 2)
+	suite.Equal(1, len(revs))
+	suite.Equal("raw1", revs[0].RawSchema)
+	revs, _ = store4.QuerySchemaRevisionByID(ctx, "argo_uuid2", 2)
+	suite.Equal(0, len(revs))
+	suite.Nil(store4.DeleteSchema(ctx, "schema_uuid_2"))
+	suite.Equal(0, len(store4.SchemaRevisions))
+	// ids are not reused after the revisions are removed
+	suite.Nil(store4.InsertSchemaRevision(ctx, "argo_uuid", "schema_uuid_1", 1, "json", "raw1", nil, time.Now()))
+	suite.Equal(int64(3), store4.SchemaRevisions[0].ID)
+
+	// test schema references
+	refs := []QSchemaReference{{Name: "address.json", SchemaUUID: "schema_uuid_1", Revision: 1}}
+	suite.Nil(store4.InsertSchemaRevision(ctx, "argo_uuid", "schema_uuid_3", 2, "avro", "raw2", refs, time.Now()))
+	revs, _ = store4.QuerySchemaRevisions(ctx, "argo_uuid", "schema_uuid_3", 2)
+	suite.Equal(refs, revs[0].References)
+	suite.Nil(store4.UpdateSchema(ctx, "schema_uuid_3", "", "", "raw2", "", 2, refs))
+	qsr, _ = store4.QuerySchemasByReference(ctx, "argo_uuid", "schema_uuid_1")
+	suite.Equal(1, len(qsr))
+	suite.Equal("schema_uuid_3", qsr[0].UUID)
+	suite.Equal(refs, qsr[0].References)
+	qsr, _ = store4.QuerySchemasByReference(ctx, "argo_uuid2", "schema_uuid_1")
+	suite.Empty(qsr)
+	revs, _ = store4.QuerySchemaRevisionsByReference(ctx, "argo_uuid", "schema_uuid_1")
+	suite.Equal(1, len(revs))
+	suite.Equal("schema_uuid_3", revs[0].SchemaUUID)
+	revs, _ = store4.QuerySchemaRevisionsByReference(ctx, "argo_uuid2", "schema_uuid_1")
+	suite.Empty(revs)
+	suite.Equal("not found", store4.UpdateSchema(ctx, "unknown", "", "", "raw2", "", 2, refs).Error())
+	// keeping the revision keeps the references
+	suite.Nil(store4.UpdateSchema(ctx, "schema_uuid_3", "", "", "", "BACKWARD", 0, nil))
+	qsr, _ = store4.QuerySchemas(ctx, "argo_uuid", "schema_uuid_3", "")
+	suite.Equal(refs, qsr[0].References)
+	suite.Equal("BACKWARD", qsr[0].Compatibility)
+
+	// test schema invalidations
+	invalidatedOn := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	suite.Nil(store4.InsertSchemaInvalidation(ctx, "argo_uuid", "schema_uuid_2", invalidatedOn))
+	suite.Nil(store4.InsertSchemaInvalidation(ctx, "argo_uuid", "schema_uuid_3", invalidatedOn.Add(time.Minute)))
+	invs, _ := store4.QuerySchemaInvalidations(ctx, invalidatedOn.Add(time.Second))
+	suite.Equal([]QSchemaInvalidation{{ProjectUUID: "argo_uuid", SchemaUUID: "schema_uuid_3", InvalidatedOn: invalidatedOn.Add(time.Minute)}}, invs)
+	invs, _ = store4.QuerySchemaInvalidations(ctx, invalidatedOn)
+	suite.Equal(2, len(invs))
+
 	// test user registration
 	_ = store.RegisterUser(ctx, "ruuid1", "n1", "f1", "l1", "e1", "o1", "d1", "time", "atkn", "pending")
 	expur1 := []QUserRegistration{{
@@ -665,6 +922,11 @@ func (suite *StoreTestSuite) TestMockStore() {
 	ur2, _ := store.QueryRegistrations(ctx, "ur-uuid1", "accepted", "", "", "", "")
 	suite.Equal(expur2, ur2)
 
+	suite.Nil(store.ConfirmRegistrationEmail(ctx, "ruuid1", "2020-05-18T22:26:58Z"))
+	ur13, _ := store.QueryRegistrations(ctx, "ruuid1", "", "", "", "", "")
+	suite.Equal("2020-05-18T22:26:58Z", ur13[0].EmailConfirmedAt)
+	suite.Equal("not found", store.ConfirmRegistrationEmail(ctx, "unknown", "2020-05-18T22:26:58Z").Error())
+
 	suite.Equal(2, len(store.UserRegistrations))
 	_ = store.DeleteRegistration(ctx, "ruuid1")
 	suite.Equal(1, len(store.UserRegistrations))
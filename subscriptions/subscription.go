@@ -29,6 +29,9 @@ const (
 	UnSupportedRetryPolicyError       = `Retry policy can only be of 'linear' or 'slowstart' type`
 	UnSupportedAuthorizationHeader    = `Authorization header type can only be of 'autogen' or 'disabled' type`
 	UnsupportedPushConfig             = `Push configuration type can only be of 'http_endpoint' or 'mattermost'`
+	AMSOutputFormat                   = "ams"
+	CloudEventsOutputFormat           = "cloudevents"
+	UnsupportedOutputFormat           = `Output format can only be 'ams' or 'cloudevents'`
 )
 
 var supportedRetryPolicyTypes = []string{
@@ -46,6 +49,11 @@ var supportedPushConfigTypes = []string{
 	MattermostPushConfig,
 }
 
+var supportedOutputFormats = []string{
+	AMSOutputFormat,
+	CloudEventsOutputFormat,
+}
+
 // Subscription struct to hold information for a given topic
 type Subscription struct {
 	ProjectUUID   string     `json:"-"`
@@ -62,6 +70,8 @@ type Subscription struct {
 	CreatedOn     string     `json:"createdOn"`
 	LatestConsume time.Time  `json:"-"`
 	ConsumeRate   float64    `json:"-"`
+	// OutputFormat is the format that pulled and pushed messages are delivered in, the ams format when empty
+	OutputFormat string `json:"outputFormat,omitempty"`
 }
 
 // PushConfig holds optional configuration for push operations
@@ -77,6 +87,7 @@ type PushConfig struct {
 	MattermostUsername  string              `json:"mattermostUsername"`
 	MattermostChannel   string              `json:"mattermostChannel"`
 	Base64Decode        bool                `json:"base64Decode"`
+	SchemaDecode        bool                `json:"schemaDecode,omitempty"`
 }
 
 // SubMetrics holds the subscription's metric details
@@ -85,6 +96,8 @@ type SubMetrics struct {
 	TotalBytes    int64     `json:"total_bytes"`
 	LatestConsume time.Time `json:"-"`
 	ConsumeRate   float64   `json:"-"`
+	ExpiredMsgNum int64     `json:"number_of_expired_messages"`
+	StoredBytes   int64     `json:"stored_bytes"`
 }
 
 // RetryPolicy holds information on retry policies
@@ -109,6 +122,8 @@ type PaginatedSubscriptions struct {
 type SubPullOptions struct {
 	RetImm string `json:"returnImmediately,omitempty"`
 	MaxMsg string `json:"maxMessages,omitempty"`
+	// Decode returns the payloads decoded with the topic's schema, alongside the original data
+	Decode string `json:"decode,omitempty"`
 }
 
 // SetOffset structure is used for input in set Offset Request
@@ -133,6 +148,11 @@ type AckDeadline struct {
 	AckDeadline int `json:"ackDeadlineSeconds"`
 }
 
+// OutputFormat holds the format that a subscription delivers its messages in
+type OutputFormat struct {
+	OutputFormat string `json:"outputFormat"`
+}
+
 type NamesList struct {
 	Subscriptions []string `json:"subscriptions"`
 }
@@ -154,6 +174,17 @@ func IsRetryPolicySupported(retPol string) bool {
 	return false
 }
 
+// IsOutputFormatSupported checks if the provided output format is supported by the service
+func IsOutputFormatSupported(outputFormat string) bool {
+
+	for _, f := range supportedOutputFormats {
+		if f == outputFormat {
+			return true
+		}
+	}
+	return false
+}
+
 // IsAuthorizationHeaderTypeSupported checks if the provided authorization header type is supported by the service
 func IsAuthorizationHeaderTypeSupported(authzType string) bool {
 
@@ -185,6 +216,8 @@ func FindMetric(ctx context.Context, projectUUID string, name string, store stor
 		result.TotalBytes = item.TotalBytes
 		result.LatestConsume = item.LatestConsume
 		result.ConsumeRate = item.ConsumeRate
+		result.ExpiredMsgNum = item.ExpiredMsgNum
+		result.StoredBytes = item.StoredBytes
 
 	}
 	return result, err
@@ -223,6 +256,13 @@ func GetAckDeadlineFromJSON(input []byte) (AckDeadline, error) {
 	return s, err
 }
 
+// GetOutputFormatFromJSON retrieves the output format info from a json definition
+func GetOutputFormatFromJSON(input []byte) (OutputFormat, error) {
+	s := OutputFormat{}
+	err := json.Unmarshal(input, &s)
+	return s, err
+}
+
 // GetFromJSON retrieves Sub Info From Json
 func GetFromJSON(input []byte) (Subscription, error) {
 	s := Subscription{}
@@ -406,6 +446,7 @@ func Find(ctx context.Context, projectUUID, userUUID, name, pageToken string, pa
 		curSub.NextOffset = item.NextOffset
 		curSub.Ack = item.Ack
 		curSub.CreatedOn = item.CreatedOn.UTC().Format("2006-01-02T15:04:05Z")
+		curSub.OutputFormat = item.OutputFormat
 		if item.PushType != "" {
 			rp := RetryPolicy{
 				PolicyType: item.RetPolicy,
@@ -437,6 +478,7 @@ func Find(ctx context.Context, projectUUID, userUUID, name, pageToken string, pa
 				MattermostUsername:  item.MattermostUsername,
 				Type:                item.PushType,
 				Base64Decode:        item.Base64Decode,
+				SchemaDecode:        item.SchemaDecode,
 			}
 		}
 		curSub.LatestConsume = item.LatestConsume
@@ -489,7 +531,11 @@ func LoadPushSubs(store stores.Store) PaginatedSubscriptions {
 
 // Create creates a new subscription
 func Create(ctx context.Context, projectUUID string, name string, topic string, offset int64, ack int,
-	pushCfg PushConfig, createdOn time.Time, store stores.Store) (Subscription, error) {
+	pushCfg PushConfig, outputFormat string, createdOn time.Time, store stores.Store) (Subscription, error) {
+
+	if outputFormat != "" && !IsOutputFormatSupported(outputFormat) {
+		return Subscription{}, errors.New("wrong value")
+	}
 
 	if HasSub(ctx, projectUUID, name, store) {
 		return Subscription{}, errors.New("exists")
@@ -517,6 +563,7 @@ func Create(ctx context.Context, projectUUID string, name string, topic string,
 		MattermostUrl:       pushCfg.MattermostUrl,
 		MattermostUsername:  pushCfg.MattermostUsername,
 		Base64Decode:        pushCfg.Base64Decode,
+		SchemaDecode:        pushCfg.SchemaDecode,
 	}
 
 	err := store.InsertSub(ctx, projectUUID, name, topic, offset, ack, qPushCfg, createdOn)
@@ -524,6 +571,13 @@ func Create(ctx context.Context, projectUUID string, name string, topic string,
 		return Subscription{}, errors.New("backend error")
 	}
 
+	if outputFormat != "" && outputFormat != AMSOutputFormat {
+		err = store.ModSubOutputFormat(ctx, projectUUID, name, outputFormat)
+		if err != nil {
+			return Subscription{}, errors.New("backend error")
+		}
+	}
+
 	results, err := Find(ctx, projectUUID, "", name, "", 0, store)
 	if len(results.Subscriptions) != 1 {
 		return Subscription{}, errors.New("backend error")
@@ -554,6 +608,29 @@ func ModAck(ctx context.Context, projectUUID string, name string, ack int, store
 	return store.ModAck(ctx, projectUUID, name, ack)
 }
 
+// ModOutputFormat updates the format that the subscription delivers its messages in
+func ModOutputFormat(ctx context.Context, projectUUID string, name string, outputFormat string, store stores.Store) error {
+	if !IsOutputFormatSupported(outputFormat) {
+		return errors.New("wrong value")
+	}
+
+	if HasSub(ctx, projectUUID, name, store) == false {
+		return errors.New("not found")
+	}
+
+	// the ams format is the default one
+	if outputFormat == AMSOutputFormat {
+		outputFormat = ""
+	}
+
+	return store.ModSubOutputFormat(ctx, projectUUID, name, outputFormat)
+}
+
+// IsCloudEvents checks whether the subscription delivers its messages as cloud events
+func (sub *Subscription) IsCloudEvents() bool {
+	return sub.OutputFormat == CloudEventsOutputFormat
+}
+
 // ModSubPush updates the subscription push config
 func ModSubPush(ctx context.Context, projectUUID string, name string, pushCfg PushConfig, store stores.Store) error {
 
@@ -578,6 +655,7 @@ func ModSubPush(ctx context.Context, projectUUID string, name string, pushCfg Pu
 		MattermostChannel:   pushCfg.MattermostChannel,
 		MattermostUrl:       pushCfg.MattermostUrl,
 		MattermostUsername:  pushCfg.MattermostUsername,
+		SchemaDecode:        pushCfg.SchemaDecode,
 	}
 
 	return store.ModSubPush(ctx, projectUUID, name, qPushCfg)
@@ -590,7 +668,12 @@ func RemoveSub(ctx context.Context, projectUUID string, name string, store store
 		return errors.New("not found")
 	}
 
-	return store.RemoveSub(ctx, projectUUID, name)
+	if err := store.RemoveSub(ctx, projectUUID, name); err != nil {
+		return err
+	}
+
+	// drop any messages that the subscription still holds back for later delivery
+	return store.RemoveSubScheduledMessages(ctx, projectUUID, name)
 }
 
 // HasSub returns true if project & subscription combination exist
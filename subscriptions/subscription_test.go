@@ -363,17 +363,46 @@ func (suite *SubTestSuite) TestCreateSubStore() {
 	store := stores.NewMockStore(APIcfg.StoreHost, APIcfg.StoreDB)
 
 	sub, err := Create(suite.ctx, "argo_uuid", "sub1", "topic1", 0, 300,
-		PushConfig{}, time.Date(2019, 7, 7, 0, 0, 0, 0, time.UTC), store)
+		PushConfig{}, "", time.Date(2019, 7, 7, 0, 0, 0, 0, time.UTC), store)
 	suite.Equal(Subscription{}, sub)
 	suite.Equal("exists", err.Error())
 
 	sub2, err2 := Create(suite.ctx, "argo_uuid", "subNew", "topicNew", 0, 0,
-		PushConfig{}, time.Date(2019, 7, 7, 0, 0, 0, 0, time.UTC), store)
+		PushConfig{}, "", time.Date(2019, 7, 7, 0, 0, 0, 0, time.UTC), store)
 	expSub := New("argo_uuid", "ARGO", "subNew", "topicNew")
 	expSub.CreatedOn = "2019-07-07T00:00:00Z"
 	suite.Equal(expSub, sub2)
 	suite.Equal(nil, err2)
 
+	sub3, err3 := Create(suite.ctx, "argo_uuid", "subEvents", "topicNew", 0, 0,
+		PushConfig{}, CloudEventsOutputFormat, time.Date(2019, 7, 7, 0, 0, 0, 0, time.UTC), store)
+	suite.Nil(err3)
+	suite.Equal(CloudEventsOutputFormat, sub3.OutputFormat)
+	suite.True(sub3.IsCloudEvents())
+
+	_, err4 := Create(suite.ctx, "argo_uuid", "subXML", "topicNew", 0, 0,
+		PushConfig{}, "xml", time.Date(2019, 7, 7, 0, 0, 0, 0, time.UTC), store)
+	suite.Equal("wrong value", err4.Error())
+}
+
+func (suite *SubTestSuite) TestModOutputFormat() {
+
+	APIcfg := config.NewAPICfg()
+	APIcfg.LoadStrJSON(suite.cfgStr)
+
+	store := stores.NewMockStore(APIcfg.StoreHost, APIcfg.StoreDB)
+
+	suite.Nil(ModOutputFormat(suite.ctx, "argo_uuid", "sub1", CloudEventsOutputFormat, store))
+	sl, _ := Find(suite.ctx, "argo_uuid", "", "sub1", "", 0, store)
+	suite.Equal(CloudEventsOutputFormat, sl.Subscriptions[0].OutputFormat)
+
+	// switching back to the ams format clears the field
+	suite.Nil(ModOutputFormat(suite.ctx, "argo_uuid", "sub1", AMSOutputFormat, store))
+	sl, _ = Find(suite.ctx, "argo_uuid", "", "sub1", "", 0, store)
+	suite.Equal("", sl.Subscriptions[0].OutputFormat)
+
+	suite.Equal(errors.New("wrong value"), ModOutputFormat(suite.ctx, "argo_uuid", "sub1", "xml", store))
+	suite.Equal(errors.New("not found"), ModOutputFormat(suite.ctx, "argo_uuid", "unknown", AMSOutputFormat, store))
 }
 
 func (suite *SubTestSuite) TestModAck() {
@@ -421,6 +450,7 @@ func (suite *SubTestSuite) TestModSubPush() {
 		MattermostUrl:      "",
 		MattermostUsername: "",
 		MattermostChannel:  "",
+		SchemaDecode:       true,
 	}
 	err1 := ModSubPush(suite.ctx, "argo_uuid", "sub1", cfg, store)
 
@@ -433,6 +463,7 @@ func (suite *SubTestSuite) TestModSubPush() {
 	suite.Equal(400, sub1.RetPeriod)
 	suite.Equal("hash-1", sub1.VerificationHash)
 	suite.True(sub1.Verified)
+	suite.True(sub1.SchemaDecode)
 
 	// test error case
 	err2 := ModSubPush(suite.ctx, "argo_uuid", "unknown", PushConfig{}, store)
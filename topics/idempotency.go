@@ -0,0 +1,75 @@
+package topics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+// ErrIdempotencyKeyInFlight is returned when another publish has reserved an idempotency key and not completed yet
+var ErrIdempotencyKeyInFlight = errors.New("idempotency key in flight")
+
+// ReserveIdempotencyKeys reserves the given keys of a topic for a publish that is about to take place.
+// It returns the message ids of the keys that were already published, the rest of the keys are now reserved
+// by the caller, who has to either remember or release them. A key that another publish has reserved fails
+// with ErrIdempotencyKeyInFlight, after releasing the keys that got reserved so far
+func ReserveIdempotencyKeys(ctx context.Context, projectUUID string, name string, keys []string, window time.Duration, store stores.Store) (map[string][]string, error) {
+
+	known := map[string][]string{}
+	reserved := []string{}
+	createdOn := time.Now().UTC()
+
+	for _, key := range keys {
+
+		ok, err := store.ReserveIdempotencyKey(ctx, projectUUID, name, key, createdOn, createdOn.Add(window))
+		if err != nil {
+			ReleaseIdempotencyKeys(ctx, projectUUID, name, reserved, store)
+			return nil, err
+		}
+
+		if ok {
+			reserved = append(reserved, key)
+			continue
+		}
+
+		qKeys, err := store.QueryIdempotencyKeys(ctx, projectUUID, name, []string{key}, createdOn)
+		if err != nil {
+			ReleaseIdempotencyKeys(ctx, projectUUID, name, reserved, store)
+			return nil, err
+		}
+
+		// a key that expired in the meantime is treated as still being in flight, a retry will reserve it
+		if len(qKeys) == 0 || qKeys[0].Pending {
+			ReleaseIdempotencyKeys(ctx, projectUUID, name, reserved, store)
+			return nil, ErrIdempotencyKeyInFlight
+		}
+
+		known[key] = qKeys[0].MessageIDs
+	}
+
+	return known, nil
+}
+
+// RememberIdempotencyKey stores the ids of the messages that were published to the topic under the given key
+// so that publish retries with the same key, within the window, return them instead of publishing again
+func RememberIdempotencyKey(ctx context.Context, projectUUID string, name string, key string, msgIDs []string, window time.Duration, store stores.Store) error {
+	createdOn := time.Now().UTC()
+	return store.InsertIdempotencyKey(ctx, projectUUID, name, key, msgIDs, createdOn, createdOn.Add(window))
+}
+
+// ReleaseIdempotencyKeys gives up the reservation of the given keys, so that a retry of a publish
+// that did not complete can reserve them again
+func ReleaseIdempotencyKeys(ctx context.Context, projectUUID string, name string, keys []string, store stores.Store) error {
+
+	var releaseErr error
+
+	for _, key := range keys {
+		if err := store.ReleaseIdempotencyKey(ctx, projectUUID, name, key); err != nil {
+			releaseErr = err
+		}
+	}
+
+	return releaseErr
+}
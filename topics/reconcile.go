@@ -0,0 +1,153 @@
+package topics
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/projects"
+	"github.com/ARGOeu/argo-messaging/stores"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReconciliationReport holds the outcome of comparing the topics of the store with the topics of the broker
+type ReconciliationReport struct {
+	DryRun bool `json:"dry_run"`
+	// BrokerOrphans are broker topics that have no matching topic in the store
+	BrokerOrphans []string `json:"broker_orphans"`
+	// StoreOrphans are store topics that have no matching topic on the broker
+	StoreOrphans []string `json:"store_orphans"`
+	// Deleted are the broker orphans that have been removed from the broker
+	Deleted []string `json:"deleted"`
+	// Failed are the broker orphans that could not be removed from the broker
+	Failed []string `json:"failed"`
+}
+
+// ExportJSON exports whole ReconciliationReport Structure as a json string
+func (rr *ReconciliationReport) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(rr, "", "   ")
+	return string(output[:]), err
+}
+
+// isManagedBrokerTopic checks whether a broker topic follows the project_uuid.topic_name convention
+// used by the service, so that internal topics (e.g. __consumer_offsets) are never reported or deleted
+func isManagedBrokerTopic(topic string) bool {
+	return !strings.HasPrefix(topic, "_") && strings.Contains(topic, ".")
+}
+
+// Reconcile compares the topics of the store with the topics of the broker and reports the orphans
+// on either side. Unless dryRun is set, broker orphans are deleted from the broker.
+// Store orphans are only reported, since the broker creates its topics lazily on the first publish
+func Reconcile(ctx context.Context, dryRun bool, store stores.Store, broker brokers.Broker) (ReconciliationReport, error) {
+
+	report := ReconciliationReport{
+		DryRun:        dryRun,
+		BrokerOrphans: []string{},
+		StoreOrphans:  []string{},
+		Deleted:       []string{},
+		Failed:        []string{},
+	}
+
+	// retrieve the broker topics first, so a topic created in the meantime
+	// will be present in the store results and won't be marked as an orphan
+	brokerTopics, err := broker.ListTopics(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	storeTopics, err := store.QueryAllTopics(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	brokerIndex := make(map[string]bool)
+	for _, t := range brokerTopics {
+		if isManagedBrokerTopic(t) {
+			brokerIndex[t] = true
+		}
+	}
+
+	storeIndex := make(map[string]bool)
+	projectNames := make(map[string]string)
+	for _, t := range storeTopics {
+		fullTopic := t.ProjectUUID + "." + t.Name
+		storeIndex[fullTopic] = true
+		if brokerIndex[fullTopic] {
+			continue
+		}
+		if _, ok := projectNames[t.ProjectUUID]; !ok {
+			projectNames[t.ProjectUUID] = projects.GetNameByUUID(ctx, t.ProjectUUID, store)
+		}
+		report.StoreOrphans = append(report.StoreOrphans, "/projects/"+projectNames[t.ProjectUUID]+"/topics/"+t.Name)
+	}
+
+	for t := range brokerIndex {
+		if !storeIndex[t] {
+			report.BrokerOrphans = append(report.BrokerOrphans, t)
+		}
+	}
+
+	sort.Strings(report.BrokerOrphans)
+	sort.Strings(report.StoreOrphans)
+
+	if dryRun {
+		return report, nil
+	}
+
+	for _, t := range report.BrokerOrphans {
+		err := broker.DeleteTopic(ctx, t)
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"trace_id": ctx.Value("trace_id"),
+					"type":     "service_log",
+					"topic":    t,
+					"error":    err.Error(),
+				},
+			).Error("Couldn't delete orphan topic from broker")
+			report.Failed = append(report.Failed, t)
+			continue
+		}
+		report.Deleted = append(report.Deleted, t)
+	}
+
+	return report, nil
+}
+
+// ScheduleReconciliation runs the topic reconciliation periodically until the context is cancelled
+func ScheduleReconciliation(ctx context.Context, interval time.Duration, dryRun bool, store stores.Store, broker brokers.Broker) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := Reconcile(ctx, dryRun, store, broker)
+			if err != nil {
+				log.WithFields(
+					log.Fields{
+						"type":  "service_log",
+						"error": err.Error(),
+					},
+				).Error("Scheduled topic reconciliation failed")
+				continue
+			}
+			log.WithFields(
+				log.Fields{
+					"type":           "service_log",
+					"dry_run":        report.DryRun,
+					"broker_orphans": report.BrokerOrphans,
+					"store_orphans":  report.StoreOrphans,
+					"deleted":        report.Deleted,
+					"failed":         report.Failed,
+				},
+			).Info("Scheduled topic reconciliation completed")
+		}
+	}
+}
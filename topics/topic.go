@@ -4,10 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	"encoding/base64"
 	"time"
 
+	"github.com/ARGOeu/argo-messaging/messages"
 	"github.com/ARGOeu/argo-messaging/projects"
 	"github.com/ARGOeu/argo-messaging/schemas"
 	"github.com/ARGOeu/argo-messaging/stores"
@@ -23,11 +25,32 @@ type Topic struct {
 	PublishRate   float64   `json:"-"`
 	Schema        string    `json:"schema,omitempty"`
 	CreatedOn     string    `json:"created_on"`
+	MessageTTL    int64     `json:"messageTTL,omitempty"`
+	// QuarantineTopic receives the messages that get rejected during publishing, e.g. because they don't match the schema
+	QuarantineTopic string `json:"quarantineTopic,omitempty"`
+	// Compression is the algorithm that compresses the payloads of the topic's messages before they get stored
+	Compression string `json:"compression,omitempty"`
+}
+
+// MessageTTL holds the time (in seconds) after publishing that the messages of a topic expire
+type MessageTTL struct {
+	MessageTTL int64 `json:"messageTTL"`
+}
+
+// QuarantineTopic holds the full name of the topic that receives the rejected messages of a topic
+type QuarantineTopic struct {
+	QuarantineTopic string `json:"quarantineTopic"`
+}
+
+// Compression holds the algorithm that compresses the payloads of a topic's messages
+type Compression struct {
+	Compression string `json:"compression"`
 }
 
 type TopicMetrics struct {
 	MsgNum        int64     `json:"number_of_messages"`
 	TotalBytes    int64     `json:"total_bytes"`
+	StoredBytes   int64     `json:"stored_bytes"`
 	LatestPublish time.Time `json:"-"`
 	PublishRate   float64   `json:"-"`
 }
@@ -75,6 +98,7 @@ func FindMetric(ctx context.Context, projectUUID string, name string, store stor
 
 		result.MsgNum = item.MsgNum
 		result.TotalBytes = item.TotalBytes
+		result.StoredBytes = item.StoredBytes
 		result.PublishRate = item.PublishRate
 		result.LatestPublish = item.LatestPublish
 	}
@@ -121,6 +145,11 @@ func Find(ctx context.Context, projectUUID, userUUID, name, pageToken string, pa
 		curTop.LatestPublish = item.LatestPublish
 		curTop.PublishRate = item.PublishRate
 		curTop.CreatedOn = item.CreatedOn.UTC().Format("2006-01-02T15:04:05Z")
+		curTop.MessageTTL = item.MessageTTL
+		curTop.Compression = item.Compression
+		if item.QuarantineTopic != "" {
+			curTop.QuarantineTopic = FormatTopicRef(projectName, item.QuarantineTopic)
+		}
 
 		if item.SchemaUUID != "" {
 			sl, err := schemas.Find(ctx, projectUUID, item.SchemaUUID, "", store)
@@ -170,8 +199,39 @@ func (tl *PaginatedTopics) ExportJSON() (string, error) {
 	return string(output[:]), err
 }
 
+// GetMessageTTLFromJSON retrieves the message ttl info from a json definition
+func GetMessageTTLFromJSON(input []byte) (MessageTTL, error) {
+	m := MessageTTL{}
+	err := json.Unmarshal(input, &m)
+	return m, err
+}
+
+// GetQuarantineTopicFromJSON retrieves the quarantine topic info from a json definition
+func GetQuarantineTopicFromJSON(input []byte) (QuarantineTopic, error) {
+	q := QuarantineTopic{}
+	err := json.Unmarshal(input, &q)
+	return q, err
+}
+
+// GetCompressionFromJSON retrieves the compression info from a json definition
+func GetCompressionFromJSON(input []byte) (Compression, error) {
+	c := Compression{}
+	err := json.Unmarshal(input, &c)
+	return c, err
+}
+
+// FormatTopicRef formats the full resource reference for a topic
+// format is projects/{project}/topics/{topic}
+func FormatTopicRef(projectName, topicName string) string {
+	return fmt.Sprintf("projects/%s/topics/%s", projectName, topicName)
+}
+
 // CreateTopic creates a new topic
-func CreateTopic(ctx context.Context, projectUUID string, name string, schemaUUID string, createdOn time.Time, store stores.Store) (Topic, error) {
+func CreateTopic(ctx context.Context, projectUUID string, name string, schemaUUID string, messageTTL int64, createdOn time.Time, store stores.Store) (Topic, error) {
+
+	if messageTTL < 0 {
+		return Topic{}, errors.New("wrong value")
+	}
 
 	if HasTopic(ctx, projectUUID, name, store) {
 		return Topic{}, errors.New("exists")
@@ -182,6 +242,13 @@ func CreateTopic(ctx context.Context, projectUUID string, name string, schemaUUI
 		return Topic{}, errors.New("backend error")
 	}
 
+	if messageTTL > 0 {
+		err = store.ModTopicMessageTTL(ctx, projectUUID, name, messageTTL)
+		if err != nil {
+			return Topic{}, errors.New("backend error")
+		}
+	}
+
 	results, err := Find(ctx, projectUUID, "", name, "", 0, store)
 
 	if len(results.Topics) != 1 {
@@ -191,6 +258,51 @@ func CreateTopic(ctx context.Context, projectUUID string, name string, schemaUUI
 	return results.Topics[0], err
 }
 
+// ModMessageTTL updates the time after which the messages of the given topic expire. A zero value disables expiry
+func ModMessageTTL(ctx context.Context, projectUUID string, name string, messageTTL int64, store stores.Store) error {
+	if messageTTL < 0 {
+		return errors.New("wrong value")
+	}
+
+	if HasTopic(ctx, projectUUID, name, store) == false {
+		return errors.New("not found")
+	}
+
+	return store.ModTopicMessageTTL(ctx, projectUUID, name, messageTTL)
+}
+
+// ModQuarantineTopic updates the topic, under the same project, that receives the rejected messages of the given topic.
+// An empty quarantine topic disables quarantine
+func ModQuarantineTopic(ctx context.Context, projectUUID string, name string, quarantineTopic string, store stores.Store) error {
+	if quarantineTopic == name {
+		return errors.New("wrong value")
+	}
+
+	if HasTopic(ctx, projectUUID, name, store) == false {
+		return errors.New("not found")
+	}
+
+	if quarantineTopic != "" && HasTopic(ctx, projectUUID, quarantineTopic, store) == false {
+		return errors.New("quarantine not found")
+	}
+
+	return store.ModTopicQuarantineTopic(ctx, projectUUID, name, quarantineTopic)
+}
+
+// ModCompression updates the algorithm that compresses the payloads of the messages published to the given topic.
+// An empty algorithm disables compression, while the already stored messages keep their own compression
+func ModCompression(ctx context.Context, projectUUID string, name string, compression string, store stores.Store) error {
+	if !messages.IsValidCompression(compression) {
+		return errors.New("wrong value")
+	}
+
+	if HasTopic(ctx, projectUUID, name, store) == false {
+		return errors.New("not found")
+	}
+
+	return store.ModTopicCompression(ctx, projectUUID, name, compression)
+}
+
 // AttachSchemaToTopic links the provided schema with the given topic
 func AttachSchemaToTopic(ctx context.Context, projectUUID, name, schemaUUID string, store stores.Store) error {
 	return store.LinkTopicSchema(ctx, projectUUID, name, schemaUUID)
@@ -207,7 +319,13 @@ func RemoveTopic(ctx context.Context, projectUUID string, name string, store sto
 		return errors.New("not found")
 	}
 
-	return store.RemoveTopic(ctx, projectUUID, name)
+	err := store.RemoveTopic(ctx, projectUUID, name)
+	if err != nil {
+		return err
+	}
+
+	// a topic that gets re-created should not deduplicate against the messages of the removed one
+	return store.RemoveTopicIdempotencyKeys(ctx, projectUUID, name)
 }
 
 // HasTopic returns true if project & topic combination exist
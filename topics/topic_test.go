@@ -9,6 +9,7 @@ import (
 
 	"time"
 
+	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
 	"github.com/ARGOeu/argo-messaging/stores"
 	"github.com/stretchr/testify/suite"
@@ -55,23 +56,23 @@ func (suite *TopicTestSuite) TestGetPaginatedTopics() {
 
 	// retrieve all topics
 	expPt1 := PaginatedTopics{Topics: []Topic{
-		{"argo_uuid", "topic4", "/projects/ARGO/topics/topic4", time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, "", "2020-11-19T00:00:00Z"},
-		{"argo_uuid", "topic3", "/projects/ARGO/topics/topic3", time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, "projects/ARGO/schemas/schema-3", "2020-11-20T00:00:00Z"},
-		{"argo_uuid", "topic2", "/projects/ARGO/topics/topic2", time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "projects/ARGO/schemas/schema-1", "2020-11-21T00:00:00Z"},
-		{"argo_uuid", "topic1", "/projects/ARGO/topics/topic1", time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", "2020-11-22T00:00:00Z"}},
+		{"argo_uuid", "topic4", "/projects/ARGO/topics/topic4", time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, "", "2020-11-19T00:00:00Z", 0, "", ""},
+		{"argo_uuid", "topic3", "/projects/ARGO/topics/topic3", time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, "projects/ARGO/schemas/schema-3", "2020-11-20T00:00:00Z", 0, "", ""},
+		{"argo_uuid", "topic2", "/projects/ARGO/topics/topic2", time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "projects/ARGO/schemas/schema-1", "2020-11-21T00:00:00Z", 0, "", ""},
+		{"argo_uuid", "topic1", "/projects/ARGO/topics/topic1", time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", "2020-11-22T00:00:00Z", 0, "", ""}},
 		NextPageToken: "", TotalSize: 4}
 	pgTopics1, err1 := Find(suite.ctx, "argo_uuid", "", "", "", 0, store)
 
 	// retrieve first 2 topics
 	expPt2 := PaginatedTopics{Topics: []Topic{
-		{"argo_uuid", "topic4", "/projects/ARGO/topics/topic4", time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, "", "2020-11-19T00:00:00Z"},
-		{"argo_uuid", "topic3", "/projects/ARGO/topics/topic3", time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, "projects/ARGO/schemas/schema-3", "2020-11-20T00:00:00Z"}},
+		{"argo_uuid", "topic4", "/projects/ARGO/topics/topic4", time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC), 0, "", "2020-11-19T00:00:00Z", 0, "", ""},
+		{"argo_uuid", "topic3", "/projects/ARGO/topics/topic3", time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), 8.99, "projects/ARGO/schemas/schema-3", "2020-11-20T00:00:00Z", 0, "", ""}},
 		NextPageToken: "MQ==", TotalSize: 4}
 	pgTopics2, err2 := Find(suite.ctx, "argo_uuid", "", "", "", 2, store)
 
 	// retrieve the next topic
 	expPt3 := PaginatedTopics{Topics: []Topic{
-		{"argo_uuid", "topic1", "/projects/ARGO/topics/topic1", time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", "2020-11-22T00:00:00Z"}},
+		{"argo_uuid", "topic1", "/projects/ARGO/topics/topic1", time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", "2020-11-22T00:00:00Z", 0, "", ""}},
 		NextPageToken: "", TotalSize: 4}
 	pgTopics3, err3 := Find(suite.ctx, "argo_uuid", "", "", "MA==", 1, store)
 
@@ -80,14 +81,14 @@ func (suite *TopicTestSuite) TestGetPaginatedTopics() {
 
 	// retrieve topics for a specific user
 	expPt5 := PaginatedTopics{Topics: []Topic{
-		{"argo_uuid", "topic2", "/projects/ARGO/topics/topic2", time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "projects/ARGO/schemas/schema-1", "2020-11-21T00:00:00Z"},
-		{"argo_uuid", "topic1", "/projects/ARGO/topics/topic1", time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", "2020-11-22T00:00:00Z"}},
+		{"argo_uuid", "topic2", "/projects/ARGO/topics/topic2", time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "projects/ARGO/schemas/schema-1", "2020-11-21T00:00:00Z", 0, "", ""},
+		{"argo_uuid", "topic1", "/projects/ARGO/topics/topic1", time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC), 10, "", "2020-11-22T00:00:00Z", 0, "", ""}},
 		NextPageToken: "", TotalSize: 2}
 	pgTopics5, err5 := Find(suite.ctx, "argo_uuid", "uuid1", "", "", 2, store)
 
 	// retrieve topics for a specific user with pagination
 	expPt6 := PaginatedTopics{Topics: []Topic{
-		{"argo_uuid", "topic2", "/projects/ARGO/topics/topic2", time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "projects/ARGO/schemas/schema-1", "2020-11-21T00:00:00Z"}},
+		{"argo_uuid", "topic2", "/projects/ARGO/topics/topic2", time.Date(2019, 5, 8, 0, 0, 0, 0, time.UTC), 5.45, "projects/ARGO/schemas/schema-1", "2020-11-21T00:00:00Z", 0, "", ""}},
 		NextPageToken: "MA==", TotalSize: 2}
 	pgTopics6, err6 := Find(suite.ctx, "argo_uuid", "uuid1", "", "", 1, store)
 
@@ -134,16 +135,75 @@ func (suite *TopicTestSuite) TestCreateTopicStore() {
 
 	store := stores.NewMockStore(APIcfg.StoreHost, APIcfg.StoreDB)
 
-	tp, err := CreateTopic(suite.ctx, "argo_uuid", "topic1", "", time.Time{}, store)
+	tp, err := CreateTopic(suite.ctx, "argo_uuid", "topic1", "", 0, time.Time{}, store)
 	suite.Equal(Topic{}, tp)
 	suite.Equal("exists", err.Error())
 
-	tp2, err2 := CreateTopic(suite.ctx, "argo_uuid", "topicNew", "schema_uuid_1", time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), store)
+	tp2, err2 := CreateTopic(suite.ctx, "argo_uuid", "topicNew", "schema_uuid_1", 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), store)
 	expTopic := New("argo_uuid", "ARGO", "topicNew")
 	expTopic.Schema = "projects/ARGO/schemas/schema-1"
 	expTopic.CreatedOn = "2019-05-07T00:00:00Z"
 	suite.Equal(expTopic, tp2)
 	suite.Equal(nil, err2)
+
+	tp3, err3 := CreateTopic(suite.ctx, "argo_uuid", "topicTTL", "", 3600, time.Date(2019, 5, 7, 0, 0, 0, 0, time.UTC), store)
+	suite.Nil(err3)
+	suite.Equal(int64(3600), tp3.MessageTTL)
+
+	_, err4 := CreateTopic(suite.ctx, "argo_uuid", "topicNegativeTTL", "", -1, time.Time{}, store)
+	suite.Equal("wrong value", err4.Error())
+}
+
+func (suite *TopicTestSuite) TestModMessageTTL() {
+
+	store := stores.NewMockStore("", "")
+
+	suite.Nil(ModMessageTTL(suite.ctx, "argo_uuid", "topic1", 60, store))
+	tl, _ := Find(suite.ctx, "argo_uuid", "", "topic1", "", 0, store)
+	suite.Equal(int64(60), tl.Topics[0].MessageTTL)
+
+	// zero disables expiry
+	suite.Nil(ModMessageTTL(suite.ctx, "argo_uuid", "topic1", 0, store))
+	tl, _ = Find(suite.ctx, "argo_uuid", "", "topic1", "", 0, store)
+	suite.Equal(int64(0), tl.Topics[0].MessageTTL)
+
+	suite.Equal("wrong value", ModMessageTTL(suite.ctx, "argo_uuid", "topic1", -5, store).Error())
+	suite.Equal("not found", ModMessageTTL(suite.ctx, "argo_uuid", "unknown", 60, store).Error())
+}
+
+func (suite *TopicTestSuite) TestModQuarantineTopic() {
+
+	store := stores.NewMockStore("", "")
+
+	suite.Nil(ModQuarantineTopic(suite.ctx, "argo_uuid", "topic1", "topic2", store))
+	tl, _ := Find(suite.ctx, "argo_uuid", "", "topic1", "", 0, store)
+	suite.Equal("projects/ARGO/topics/topic2", tl.Topics[0].QuarantineTopic)
+
+	// empty disables quarantine
+	suite.Nil(ModQuarantineTopic(suite.ctx, "argo_uuid", "topic1", "", store))
+	tl, _ = Find(suite.ctx, "argo_uuid", "", "topic1", "", 0, store)
+	suite.Equal("", tl.Topics[0].QuarantineTopic)
+
+	suite.Equal("wrong value", ModQuarantineTopic(suite.ctx, "argo_uuid", "topic1", "topic1", store).Error())
+	suite.Equal("not found", ModQuarantineTopic(suite.ctx, "argo_uuid", "unknown", "topic2", store).Error())
+	suite.Equal("quarantine not found", ModQuarantineTopic(suite.ctx, "argo_uuid", "topic1", "unknown", store).Error())
+}
+
+func (suite *TopicTestSuite) TestModCompression() {
+
+	store := stores.NewMockStore("", "")
+
+	suite.Nil(ModCompression(suite.ctx, "argo_uuid", "topic1", "zstd", store))
+	tl, _ := Find(suite.ctx, "argo_uuid", "", "topic1", "", 0, store)
+	suite.Equal("zstd", tl.Topics[0].Compression)
+
+	// empty disables compression
+	suite.Nil(ModCompression(suite.ctx, "argo_uuid", "topic1", "", store))
+	tl, _ = Find(suite.ctx, "argo_uuid", "", "topic1", "", 0, store)
+	suite.Equal("", tl.Topics[0].Compression)
+
+	suite.Equal("wrong value", ModCompression(suite.ctx, "argo_uuid", "topic1", "lz4", store).Error())
+	suite.Equal("not found", ModCompression(suite.ctx, "argo_uuid", "unknown", "gzip", store).Error())
 }
 
 func (suite *TopicTestSuite) TestRemoveTopicStore() {
@@ -154,9 +214,44 @@ func (suite *TopicTestSuite) TestRemoveTopicStore() {
 
 	suite.Equal(true, HasTopic(suite.ctx, "argo_uuid", "topic1", store))
 
+	suite.Nil(RememberIdempotencyKey(suite.ctx, "argo_uuid", "topic1", "k1", []string{"1"}, time.Hour, store))
+
 	suite.Equal("not found", RemoveTopic(suite.ctx, "argo_uuid", "topicFoo", store).Error())
 	suite.Equal(nil, RemoveTopic(suite.ctx, "argo_uuid", "topic1", store))
 	suite.Equal(false, HasTopic(suite.ctx, "argo_uuid", "topic1", store))
+
+	// the idempotency keys of the topic are removed along with it
+	suite.Equal(0, len(store.IdempotencyKeys))
+}
+
+func (suite *TopicTestSuite) TestIdempotencyKeys() {
+
+	store := stores.NewMockStore("", "")
+
+	ids, err := ReserveIdempotencyKeys(suite.ctx, "argo_uuid", "topic1", []string{}, time.Hour, store)
+	suite.Nil(err)
+	suite.Equal(map[string][]string{}, ids)
+
+	suite.Nil(RememberIdempotencyKey(suite.ctx, "argo_uuid", "topic1", "k1", []string{"1", "2"}, time.Hour, store))
+	suite.Nil(RememberIdempotencyKey(suite.ctx, "argo_uuid", "topic1", "k2", []string{"3"}, -time.Second, store))
+	suite.Nil(RememberIdempotencyKey(suite.ctx, "argo_uuid", "topic2", "k3", []string{"4"}, time.Hour, store))
+
+	// published keys return their message ids, keys of other topics and keys whose window has passed get reserved
+	ids, err = ReserveIdempotencyKeys(suite.ctx, "argo_uuid", "topic1", []string{"k1", "k2", "k3"}, time.Hour, store)
+	suite.Nil(err)
+	suite.Equal(map[string][]string{"k1": {"1", "2"}}, ids)
+
+	// reserved keys are in flight until they get remembered or released
+	_, err = ReserveIdempotencyKeys(suite.ctx, "argo_uuid", "topic1", []string{"k4", "k2"}, time.Hour, store)
+	suite.Equal(ErrIdempotencyKeyInFlight, err)
+
+	suite.Nil(RememberIdempotencyKey(suite.ctx, "argo_uuid", "topic1", "k2", []string{"5"}, time.Hour, store))
+	suite.Nil(ReleaseIdempotencyKeys(suite.ctx, "argo_uuid", "topic1", []string{"k2", "k3"}, store))
+
+	// the conflicting reservation released k4, a remembered key is not released
+	ids, err = ReserveIdempotencyKeys(suite.ctx, "argo_uuid", "topic1", []string{"k4", "k2", "k3"}, time.Hour, store)
+	suite.Nil(err)
+	suite.Equal(map[string][]string{"k2": {"5"}}, ids)
 }
 
 func (suite *TopicTestSuite) TestHasProjectTopic() {
@@ -169,6 +264,51 @@ func (suite *TopicTestSuite) TestHasProjectTopic() {
 	suite.Equal(true, HasTopic(suite.ctx, "argo_uuid", "topic1", store))
 }
 
+func (suite *TopicTestSuite) TestReconcile() {
+
+	store := stores.NewMockStore("", "")
+	// topic1 and topic3 exist on both sides, topic2 and topic4 have never been created on the broker
+	brk := brokers.MockBroker{Topics: map[string]string{
+		"argo_uuid.topic1":        "",
+		"argo_uuid.topic3":        "",
+		"argo_uuid.topicOrphan":   "",
+		"argo_uuid_deleted.topic": "",
+		"__consumer_offsets":      "",
+	}}
+
+	expReport := ReconciliationReport{
+		DryRun:        true,
+		BrokerOrphans: []string{"argo_uuid.topicOrphan", "argo_uuid_deleted.topic"},
+		StoreOrphans:  []string{"/projects/ARGO/topics/topic2", "/projects/ARGO/topics/topic4"},
+		Deleted:       []string{},
+		Failed:        []string{},
+	}
+
+	// dry run should only report the orphans
+	report, err := Reconcile(suite.ctx, true, store, &brk)
+	suite.Nil(err)
+	suite.Equal(expReport, report)
+	suite.Equal(5, len(brk.Topics))
+
+	// a normal run should also remove the broker orphans
+	expReport.DryRun = false
+	expReport.Deleted = []string{"argo_uuid.topicOrphan", "argo_uuid_deleted.topic"}
+	report, err = Reconcile(suite.ctx, false, store, &brk)
+	suite.Nil(err)
+	suite.Equal(expReport, report)
+	suite.Equal(map[string]string{
+		"argo_uuid.topic1":   "",
+		"argo_uuid.topic3":   "",
+		"__consumer_offsets": "",
+	}, brk.Topics)
+
+	// nothing should be left to repair on the broker side
+	report, err = Reconcile(suite.ctx, false, store, &brk)
+	suite.Nil(err)
+	suite.Equal(0, len(report.BrokerOrphans))
+	suite.Equal(0, len(report.Deleted))
+}
+
 func (suite *TopicTestSuite) TestExportJson() {
 	APIcfg := config.NewAPICfg()
 	APIcfg.LoadStrJSON(suite.cfgStr)